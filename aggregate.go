@@ -0,0 +1,223 @@
+package rrd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Aggregator aggregates values into a single value. It's only called with
+// known values, at least one of them, and may reorder them.
+type Aggregator func(values []float64) float64
+
+// AggregateAverage returns the average of values.
+func AggregateAverage(values []float64) float64 {
+	return AggregateSum(values) / float64(len(values))
+}
+
+// AggregateSum returns the sum of values.
+func AggregateSum(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// AggregateMin returns the minimum of values.
+func AggregateMin(values []float64) float64 {
+	res := values[0]
+	for _, v := range values[1:] {
+		res = math.Min(res, v)
+	}
+	return res
+}
+
+// AggregateMax returns the maximum of values.
+func AggregateMax(values []float64) float64 {
+	res := values[0]
+	for _, v := range values[1:] {
+		res = math.Max(res, v)
+	}
+	return res
+}
+
+// AggregateLast returns the last of values.
+func AggregateLast(values []float64) float64 {
+	return values[len(values)-1]
+}
+
+// AggregatePercentile returns an Aggregator which returns the pth
+// percentile of values, interpolating between the closest ranks.
+// p is clamped to between 0 and 100.
+func AggregatePercentile(p float64) Aggregator {
+	p = math.Max(0, math.Min(100, p))
+	return func(values []float64) float64 {
+		sort.Float64s(values)
+		rank := p / 100 * float64(len(values)-1)
+		lo := int(rank)
+		if lo == len(values)-1 {
+			return values[lo]
+		}
+		return values[lo] + (values[lo+1]-values[lo])*(rank-float64(lo))
+	}
+}
+
+// cfAggregator returns the Aggregator equivalent to cf.
+func cfAggregator(cf ConsolidationFunc) Aggregator {
+	switch cf {
+	case Min:
+		return AggregateMin
+	case Max:
+		return AggregateMax
+	case Last:
+		return AggregateLast
+	}
+	return AggregateAverage
+}
+
+// aggregate returns the result of agg for the known values of values, or
+// NaN if none are known.
+func aggregate(agg Aggregator, values []float64) float64 {
+	known := values[:0]
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			known = append(known, v)
+		}
+	}
+	if len(known) == 0 {
+		return math.NaN()
+	}
+	return agg(known)
+}
+
+// Downsample returns a copy of r re-aggregated to step, which must be a
+// multiple of the step of r, using agg. Each row contains the aggregate of
+// the rows of r within the step ending at its time, ignoring unknown
+// values. Rows are aligned to multiples of step since the epoch.
+func (r *FetchResult) Downsample(step time.Duration, agg Aggregator) (*FetchResult, error) {
+	switch {
+	case agg == nil:
+		return nil, fmt.Errorf("%w: nil aggregator", ErrInvalidArg)
+	case r.Step < time.Second || step < r.Step || step%r.Step != 0:
+		return nil, fmt.Errorf("%w: step %v isn't a multiple of %v", ErrInvalidArg, step, r.Step)
+	}
+
+	res := *r
+	res.Step = step
+	res.Rows = nil
+	secs := int64(step / time.Second)
+	buckets := make([][]float64, len(r.Names))
+	flush := func() {
+		n := len(res.Rows) - 1
+		for i, b := range buckets {
+			res.Rows[n].Values[i] = aggregate(agg, b)
+			buckets[i] = b[:0]
+		}
+	}
+	for _, row := range r.Rows {
+		t := row.Time.Unix()
+		if rem := t % secs; rem != 0 {
+			t += secs - rem
+		}
+		if n := len(res.Rows); n == 0 || res.Rows[n-1].Time.Unix() != t {
+			if n > 0 {
+				flush()
+			}
+			res.Rows = append(res.Rows, FetchResultRow{Time: time.Unix(t, 0), Values: make([]float64, len(r.Names))})
+		}
+		for i := range buckets {
+			v := math.NaN()
+			if i < len(row.Values) {
+				v = row.Values[i]
+			}
+			buckets[i] = append(buckets[i], v)
+		}
+	}
+	if len(res.Rows) > 0 {
+		flush()
+	}
+	return &res, nil
+}
+
+// DownsamplePoints returns a copy of r re-aggregated with agg to the
+// smallest multiple of its step which results in at most points rows.
+func (r *FetchResult) DownsamplePoints(points int, agg Aggregator) (*FetchResult, error) {
+	if points < 1 {
+		return nil, fmt.Errorf("%w: points %v", ErrInvalidArg, points)
+	}
+	if r.Step < time.Second {
+		return nil, fmt.Errorf("%w: step %v", ErrInvalidArg, r.Step)
+	}
+
+	step := r.Step * time.Duration(max(1, (len(r.Rows)+points-1)/points))
+	for {
+		res, err := r.Downsample(step, agg)
+		if err != nil || len(res.Rows) <= points {
+			return res, err
+		}
+		step += r.Step
+	}
+}
+
+// Combine returns a copy of r with an additional data source name whose
+// values are the aggregate, using agg, of the known values of the data
+// sources ds in each row, e.g. the sum of in and out traffic.
+func (r *FetchResult) Combine(name string, agg Aggregator, ds ...string) (*FetchResult, error) {
+	if agg == nil {
+		return nil, fmt.Errorf("%w: nil aggregator", ErrInvalidArg)
+	}
+	cols := make([]int, len(ds))
+	for i, d := range ds {
+		cols[i] = r.column(d)
+		if cols[i] == -1 {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownDS, d)
+		}
+	}
+
+	values := make([]float64, len(cols))
+	return r.Derive(name, func(row []float64) float64 {
+		for i, c := range cols {
+			values[i] = row[c]
+		}
+		return aggregate(agg, values)
+	})
+}
+
+// Derive returns a copy of r with an additional data source name whose
+// values are the result of f for the values of each row, ordered as Names.
+func (r *FetchResult) Derive(name string, f func(values []float64) float64) (*FetchResult, error) {
+	switch {
+	case f == nil:
+		return nil, fmt.Errorf("%w: nil function", ErrInvalidArg)
+	case name == "" || r.column(name) != -1:
+		return nil, fmt.Errorf("%w: data source name %q", ErrInvalidArg, name)
+	}
+
+	res := *r
+	res.Names = append(append(make([]string, 0, len(r.Names)+1), r.Names...), name)
+	res.Rows = make([]FetchResultRow, len(r.Rows))
+	for i, row := range r.Rows {
+		values := make([]float64, len(r.Names), len(r.Names)+1)
+		for j := range values {
+			if j < len(row.Values) {
+				values[j] = row.Values[j]
+			} else {
+				values[j] = math.NaN()
+			}
+		}
+		res.Rows[i] = FetchResultRow{Time: row.Time, Values: append(values, f(values))}
+	}
+	return &res, nil
+}
+
+// column returns the index of the data source name, or -1 if r has none.
+func (r *FetchResult) column(name string) int {
+	for i, n := range r.Names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
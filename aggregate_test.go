@@ -0,0 +1,134 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregators(t *testing.T) {
+	values := []float64{4, 1, 3, 2}
+	tests := []struct {
+		name     string
+		agg      Aggregator
+		expected float64
+	}{
+		{"average", AggregateAverage, 2.5},
+		{"sum", AggregateSum, 10},
+		{"min", AggregateMin, 1},
+		{"max", AggregateMax, 4},
+		{"last", AggregateLast, 2},
+		{"p0", AggregatePercentile(0), 1},
+		{"p50", AggregatePercentile(50), 2.5},
+		{"p90", AggregatePercentile(90), 3.7},
+		{"p100", AggregatePercentile(200), 4},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InDelta(t, tc.expected, tc.agg(append([]float64(nil), values...)), 1e-9)
+		})
+	}
+	assert.True(t, math.IsNaN(aggregate(AggregateSum, []float64{math.NaN()})))
+}
+
+func TestDownsample(t *testing.T) {
+	nan := math.NaN()
+	r := &FetchResult{
+		Step:  time.Minute,
+		Names: []string{"a", "b"},
+		Rows: []FetchResultRow{
+			{Time: time.Unix(60, 0), Values: []float64{1, nan}},
+			{Time: time.Unix(120, 0), Values: []float64{3, nan}},
+			{Time: time.Unix(180, 0), Values: []float64{2, nan}},
+			{Time: time.Unix(240, 0), Values: []float64{nan, 5}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		agg      Aggregator
+		expected [][]float64
+	}{
+		{"average", AggregateAverage, [][]float64{{2, nan}, {2, 5}}},
+		{"min", AggregateMin, [][]float64{{1, nan}, {2, 5}}},
+		{"max", AggregateMax, [][]float64{{3, nan}, {2, 5}}},
+		{"last", AggregateLast, [][]float64{{3, nan}, {2, 5}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := r.Downsample(time.Minute*2, tc.agg)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, time.Minute*2, res.Step)
+			if !assert.Len(t, res.Rows, len(tc.expected)) {
+				return
+			}
+			for i, row := range res.Rows {
+				assert.Equal(t, time.Unix(int64(i+1)*120, 0), row.Time)
+				assertNaNEqual(t, tc.expected[i], row.Values)
+			}
+		})
+	}
+
+	res, err := r.DownsamplePoints(1, AggregateMax)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Minute*4, res.Step)
+		if assert.Len(t, res.Rows, 1) {
+			assert.Equal(t, []float64{3, 5}, res.Rows[0].Values)
+		}
+	}
+	res, err = r.DownsamplePoints(10, AggregateMax)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Minute, res.Step)
+		assert.Len(t, res.Rows, 4)
+	}
+
+	_, err = r.Downsample(time.Second*90, AggregateAverage)
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = r.Downsample(time.Minute*2, nil)
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = r.DownsamplePoints(0, AggregateAverage)
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
+
+func TestCombine(t *testing.T) {
+	nan := math.NaN()
+	r := &FetchResult{
+		Step:  time.Minute,
+		Names: []string{"in", "out"},
+		Rows: []FetchResultRow{
+			{Time: time.Unix(60, 0), Values: []float64{1, 2}},
+			{Time: time.Unix(120, 0), Values: []float64{3, nan}},
+			{Time: time.Unix(180, 0), Values: []float64{nan, nan}},
+		},
+	}
+
+	res, err := r.Combine("total", AggregateSum, "in", "out")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"in", "out", "total"}, res.Names)
+	assert.Equal(t, []string{"in", "out"}, r.Names)
+	var total []float64
+	for _, row := range res.Rows {
+		total = append(total, row.Values[2])
+	}
+	assertNaNEqual(t, []float64{3, 3, nan}, total)
+
+	res, err = r.Derive("ratio", func(v []float64) float64 { return v[0] / v[1] })
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0.5, res.Rows[0].Values[2])
+	}
+
+	_, err = r.Combine("total", AggregateSum, "in", "missing")
+	assert.ErrorIs(t, err, ErrUnknownDS)
+	_, err = r.Combine("in", AggregateSum, "in")
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = r.Derive("x", nil)
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
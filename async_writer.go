@@ -0,0 +1,243 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAsyncFlushInterval is the default interval at which an
+	// AsyncWriter sends its pending samples.
+	DefaultAsyncFlushInterval = time.Second * 10
+
+	// DefaultAsyncMaxPending is the default maximum number of samples an
+	// AsyncWriter holds before rejecting new ones.
+	DefaultAsyncMaxPending = 100000
+)
+
+// AsyncFlushInterval sets the interval at which an AsyncWriter sends its
+// pending samples.
+func AsyncFlushInterval(d time.Duration) func(*AsyncWriter) error {
+	return func(w *AsyncWriter) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: flush interval %v", ErrInvalidArg, d)
+		}
+		w.interval = d
+		return nil
+	}
+}
+
+// AsyncMaxPending sets the maximum number of samples an AsyncWriter holds.
+// Once half of them are pending they are sent without waiting for the flush
+// interval and once full Enqueue returns ErrQueueFull.
+func AsyncMaxPending(n int) func(*AsyncWriter) error {
+	return func(w *AsyncWriter) error {
+		if n < 1 {
+			return fmt.Errorf("%w: max pending %v", ErrInvalidArg, n)
+		}
+		w.maxPending = n
+		return nil
+	}
+}
+
+// AsyncErrorHandler sets the function called with the errors of background
+// flushes, by default they are logged by the clients logger.
+func AsyncErrorHandler(f func(err error)) func(*AsyncWriter) error {
+	return func(w *AsyncWriter) error {
+		if f == nil {
+			return ErrNilOption
+		}
+		w.onError = f
+		return nil
+	}
+}
+
+// flushRequest requests an immediate flush of an AsyncWriter.
+type flushRequest struct {
+	ctx  context.Context
+	done chan error
+}
+
+// AsyncWriter queues updates in memory, coalescing them per file, and sends
+// them to rrdcached as a single batch from a background goroutine, so
+// callers such as metric collection agents never block on the server.
+// Samples which fail to be sent are dropped and reported to the writers
+// error handler.
+// An AsyncWriter is safe for concurrent use.
+type AsyncWriter struct {
+	c          *Client
+	interval   time.Duration
+	maxPending int
+	onError    func(err error)
+
+	m       sync.Mutex
+	pending coalescer
+	closed  bool
+
+	kick    chan struct{}
+	flushes chan flushRequest
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewAsyncWriter returns a new AsyncWriter which sends updates using c.
+// The writer must be closed with Close to send the remaining samples and
+// stop its background goroutine.
+func NewAsyncWriter(c *Client, options ...func(*AsyncWriter) error) (*AsyncWriter, error) {
+	w := &AsyncWriter{
+		c:          c,
+		interval:   DefaultAsyncFlushInterval,
+		maxPending: DefaultAsyncMaxPending,
+		kick:       make(chan struct{}, 1),
+		flushes:    make(chan flushRequest),
+		done:       make(chan struct{}),
+	}
+	w.onError = func(err error) {
+		c.logger.Error("async write failed", "error", err)
+	}
+	for _, f := range options {
+		if f == nil {
+			return nil, ErrNilOption
+		}
+		if err := f(w); err != nil {
+			return nil, err
+		}
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w, nil
+}
+
+// Enqueue queues samples to be added to filename without blocking.
+// Samples with a zero time are given the current time, so they're recorded
+// when queued rather than when sent.
+// If accepting the samples would exceed the maximum pending samples none
+// of them are queued and ErrQueueFull is returned.
+func (w *AsyncWriter) Enqueue(filename string, samples ...Sample) error {
+	if len(samples) == 0 {
+		return ErrNoSamples
+	}
+
+	values := make([]Update, len(samples))
+	now := time.Now()
+	for i, s := range samples {
+		if s.Time.IsZero() {
+			s.Time = now
+		}
+		values[i] = s.Update()
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	switch {
+	case w.closed:
+		return ErrWriterClosed
+	case w.pending.n+len(values) > w.maxPending:
+		return ErrQueueFull
+	}
+
+	w.pending.add(filename, values...)
+	if w.pending.n >= (w.maxPending+1)/2 {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Pending returns the number of samples waiting to be sent.
+func (w *AsyncWriter) Pending() int {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.pending.n
+}
+
+// Drain sends all pending samples and waits for them to be processed by the
+// server, returning the error of the flush if any.
+func (w *AsyncWriter) Drain(ctx context.Context) error {
+	req := flushRequest{ctx: ctx, done: make(chan error, 1)}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.done:
+		return ErrWriterClosed
+	case w.flushes <- req:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-req.done:
+		return err
+	}
+}
+
+// Close sends all pending samples and stops the writer, returning the error
+// of the final flush if any. Close doesn't close the client.
+func (w *AsyncWriter) Close() error {
+	w.m.Lock()
+	if w.closed {
+		w.m.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.m.Unlock()
+
+	close(w.done)
+	w.wg.Wait()
+
+	return w.flush(context.Background())
+}
+
+// run sends pending samples when requested, when the pending limit is
+// approached and at each interval until the writer is closed.
+func (w *AsyncWriter) run() {
+	defer w.wg.Done()
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case req := <-w.flushes:
+			req.done <- w.flush(req.ctx)
+		case <-w.kick:
+			w.report(w.flush(context.Background()))
+		case <-t.C:
+			w.report(w.flush(context.Background()))
+		}
+	}
+}
+
+// report passes err, if not nil, to the error handler.
+func (w *AsyncWriter) report(err error) {
+	if err != nil {
+		w.onError(err)
+	}
+}
+
+// flush sends the pending samples to the server as a single batch.
+func (w *AsyncWriter) flush(ctx context.Context) error {
+	w.m.Lock()
+	pending := w.pending
+	w.pending = coalescer{}
+	w.m.Unlock()
+
+	if pending.n == 0 {
+		return nil
+	}
+
+	cmds, _ := pending.cmds(w.c.maxCommandSize)
+	if err := w.c.BatchWithContext(ctx, cmds...); err != nil {
+		return fmt.Errorf("async writer: %w", err)
+	}
+	return nil
+}
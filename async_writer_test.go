@@ -0,0 +1,191 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	_, err = NewAsyncWriter(c, nil)
+	assert.Equal(t, ErrNilOption, err)
+	_, err = NewAsyncWriter(c, AsyncMaxPending(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewAsyncWriter(c, AsyncFlushInterval(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	w, err := NewAsyncWriter(c, AsyncFlushInterval(time.Hour), AsyncMaxPending(4))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, ErrNoSamples, w.Enqueue("a.rrd"))
+	assert.NoError(t, w.Enqueue("a.rrd", Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}))
+	assert.Equal(t, 1, w.Pending())
+	assert.NoError(t, w.Enqueue("b.rrd", Sample{Time: time.Unix(1499968800, 0), Values: []float64{2}}))
+
+	// Reaching half the limit triggers a flush.
+	assert.Eventually(t, func() bool {
+		return s.count("update a.rrd 1499968800:1") == 1
+	}, time.Second, time.Millisecond*10)
+
+	ctx := context.Background()
+	assert.NoError(t, w.Drain(ctx))
+	assert.Equal(t, 0, w.Pending())
+	assert.Equal(t, 1, s.count("update b.rrd 1499968800:2"))
+
+	// Samples for the same file are coalesced into one update.
+	assert.NoError(t, w.Enqueue("a.rrd", Sample{Time: time.Unix(1499968860, 0), Values: []float64{3}}))
+	assert.NoError(t, w.Close())
+	assert.Equal(t, 0, w.Pending())
+
+	assert.Equal(t, ErrWriterClosed, w.Enqueue("a.rrd", Sample{Values: []float64{1}}))
+	assert.Equal(t, ErrWriterClosed, w.Drain(ctx))
+	assert.NoError(t, w.Close())
+	assert.Equal(t, 1, s.count("update a.rrd 1499968860:3"))
+}
+
+func TestAsyncWriterCoalesce(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	w, err := NewAsyncWriter(c, AsyncFlushInterval(time.Hour), AsyncMaxPending(10))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, w.Close())
+	}()
+
+	assert.NoError(t, w.Enqueue("a.rrd", Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}))
+	assert.NoError(t, w.Enqueue("a.rrd", Sample{Time: time.Unix(1499968860, 0), Values: []float64{2}}))
+
+	samples := make([]Sample, 9)
+	for i := range samples {
+		samples[i] = Sample{Time: time.Unix(int64(1499968800+i*60), 0), Values: []float64{1}}
+	}
+	assert.Equal(t, ErrQueueFull, w.Enqueue("b.rrd", samples...))
+
+	assert.NoError(t, w.Drain(context.Background()))
+	assert.Equal(t, 1, s.count("update a.rrd 1499968800:1 1499968860:2"))
+	assert.Equal(t, 0, s.count("update b.rrd"))
+}
+
+func TestAsyncWriterError(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"1 errors", "1 No such file: /a.rrd"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	var m sync.Mutex
+	var errs []error
+	w, err := NewAsyncWriter(c, AsyncFlushInterval(time.Millisecond*10), AsyncErrorHandler(func(err error) {
+		m.Lock()
+		defer m.Unlock()
+		errs = append(errs, err)
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, w.Close())
+	}()
+
+	assert.NoError(t, w.Enqueue("a.rrd", Sample{Values: []float64{1}}))
+	assert.Eventually(t, func() bool {
+		m.Lock()
+		defer m.Unlock()
+		return len(errs) == 1
+	}, time.Second, time.Millisecond*10)
+
+	m.Lock()
+	defer m.Unlock()
+	var berr *BatchError
+	assert.True(t, errors.As(errs[0], &berr))
+	assert.True(t, IsNotExist(errs[0]))
+}
+
+func TestAsyncWriterChunked(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	// Each value is 12 bytes so only two fit after "update a.rrd".
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), MaxCommandSize(40))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	w, err := NewAsyncWriter(c, AsyncFlushInterval(time.Hour))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, w.Enqueue("a.rrd", Sample{Time: time.Unix(int64(1499968800+i*60), 0), Values: []float64{1}}))
+	}
+	assert.NoError(t, w.Close())
+	assert.Equal(t, 1, s.count("batch"))
+	assert.Equal(t, 1, s.count("update a.rrd 1499968800:1 1499968860:1"))
+	assert.Equal(t, 1, s.count("update a.rrd 1499968920:1 1499968980:1"))
+	assert.Equal(t, 1, s.count("update a.rrd 1499969040:1"))
+}
@@ -0,0 +1,135 @@
+package rrd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// BaseDir sets the base directory of the daemon, as passed to rrdcached
+// with -b, which must be absolute. Filenames of commands are then
+// normalized to be relative to it, so relative and absolute filenames for
+// the same file are consistent, and those outside it, such as
+// "../../etc/passwd", are rejected with ErrInvalidArg before being sent.
+// This protects against path traversal where filenames come from user
+// input, such as via a gateway. Raw commands whose arguments are part of
+// the command string aren't checked.
+func BaseDir(dir string) func(*Client) error {
+	return func(c *Client) error {
+		if !path.IsAbs(dir) {
+			return fmt.Errorf("%w: base dir %q must be absolute", ErrInvalidArg, dir)
+		}
+		c.baseDir = path.Clean(dir)
+		return nil
+	}
+}
+
+// resolvePath returns filename relative to the base directory of c,
+// returning an error if it's outside it. It's returned unchanged if the
+// client has no BaseDir.
+func (c *Client) resolvePath(filename string) (string, error) {
+	if c.baseDir == "" {
+		return filename, nil
+	}
+
+	p := filename
+	if path.IsAbs(p) {
+		if c.baseDir != "/" {
+			rel, ok := strings.CutPrefix(path.Clean(p), c.baseDir+"/")
+			if !ok {
+				return "", c.outsideErr("file", filename)
+			}
+			p = rel
+		}
+		p = strings.TrimLeft(p, "/")
+	}
+
+	p = path.Clean(p)
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return "", c.outsideErr("file", filename)
+	}
+	return p, nil
+}
+
+// outsideErr returns the error for the file or directory name, of kind,
+// being outside the base directory, which only includes the name if the
+// client doesn't redact arguments.
+func (c *Client) outsideErr(kind, name string) error {
+	if c.redact {
+		return fmt.Errorf("%w: %v is outside the base dir", ErrInvalidArg, kind)
+	}
+	return fmt.Errorf("%w: %v %q is outside the base dir %v", ErrInvalidArg, kind, name, c.baseDir)
+}
+
+// resolveCmd returns cmd with its filenames resolved by resolvePath,
+// copying it if any change, or an error if any are outside the base
+// directory.
+// The directories of list, which are relative to the base directory even
+// if absolute, are checked for parent references.
+func (c *Client) resolveCmd(cmd *Cmd) (*Cmd, error) {
+	if c.baseDir == "" || len(cmd.args) == 0 {
+		return cmd, nil
+	}
+
+	var args []interface{}
+	set := func(i int, v interface{}) {
+		if args == nil {
+			args = append([]interface{}(nil), cmd.args...)
+		}
+		args[i] = v
+	}
+
+	switch cmd.verb() {
+	case "list":
+		dir, _ := cmd.args[len(cmd.args)-1].(string)
+		for _, e := range strings.Split(dir, "/") {
+			if e == ".." {
+				return nil, c.outsideErr("directory", dir)
+			}
+		}
+	case "create":
+		// The source and template options also reference files.
+		for i, a := range cmd.args[1:] {
+			o, _ := a.(CreateOption)
+			flag, file, ok := strings.Cut(string(o), " ")
+			if !ok || (flag != "-r" && flag != "-t") {
+				continue
+			}
+			p, err := c.resolvePath(unescapeArg(file))
+			if err != nil {
+				return nil, err
+			}
+			set(i+1, CreateOption(flag+" "+escapeArg(p)))
+		}
+	}
+
+	if filename := cmd.filename(); filename != "" {
+		p, err := c.resolvePath(filename)
+		if err != nil {
+			return nil, err
+		}
+		if p != filename {
+			set(0, p)
+		}
+	}
+
+	if args == nil {
+		return cmd, nil
+	}
+	return &Cmd{cmd: cmd.cmd, args: args}, nil
+}
+
+// unescapeArg returns s with the escaping of escapeArg removed.
+func unescapeArg(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
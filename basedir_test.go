@@ -0,0 +1,118 @@
+package rrd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		base     string
+		filename string
+		expect   string
+	}{
+		{"", "../a.rrd", "../a.rrd"},
+		{"/data", "a.rrd", "a.rrd"},
+		{"/data", "./sub//a.rrd", "sub/a.rrd"},
+		{"/data", "sub/../a.rrd", "a.rrd"},
+		{"/data", "/data/sub/a.rrd", "sub/a.rrd"},
+		{"/data/", "/data/../data/a.rrd", "a.rrd"},
+		{"/", "/sub/a.rrd", "sub/a.rrd"},
+		{"/data", "../a.rrd", ""},
+		{"/data", "sub/../../a.rrd", ""},
+		{"/data", "..", ""},
+		{"/data", ".", ""},
+		{"/data", "/data", ""},
+		{"/data", "/etc/passwd", ""},
+		{"/data", "/database/a.rrd", ""},
+		{"/data", "/data/../etc/passwd", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.base+":"+tc.filename, func(t *testing.T) {
+			c := &Client{}
+			if tc.base != "" {
+				assert.NoError(t, BaseDir(tc.base)(c))
+			}
+			p, err := c.resolvePath(tc.filename)
+			if tc.expect == "" {
+				assert.ErrorIs(t, err, ErrInvalidArg)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expect, p)
+			}
+		})
+	}
+
+	assert.ErrorIs(t, BaseDir("data")(&Client{}), ErrInvalidArg)
+
+	c := &Client{}
+	assert.NoError(t, BaseDir("/data")(c))
+	_, err := c.resolvePath("/secret/a.rrd")
+	assert.ErrorContains(t, err, "/secret/a.rrd")
+	assert.NoError(t, Redact(c))
+	_, err = c.resolvePath("/secret/a.rrd")
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	assert.NotContains(t, err.Error(), "secret")
+	assert.NotContains(t, err.Error(), "/data")
+}
+
+func TestClientBaseDir(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		".":                         {"0 errors"},
+		"create a.rrd -t b\\ c.rrd": {"0 RRD created OK"},
+		"list /sub/":                {"1 RRDs", "/sub/a.rrd"},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), BaseDir("/var/lib/rrdcached/db"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	sample := Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}
+	assert.NoError(t, c.Update("/var/lib/rrdcached/db/sub/a.rrd", sample))
+	assert.NoError(t, c.Update("./sub/a.rrd", sample))
+	assert.Equal(t, 2, s.count("update sub/a.rrd 1499968800:1"))
+
+	assert.ErrorIs(t, c.Update("../../etc/passwd", sample), ErrInvalidArg)
+	assert.ErrorIs(t, c.Update("/etc/passwd", sample), ErrInvalidArg)
+	assert.Equal(t, 0, s.count("update ../../etc/passwd"))
+	assert.Equal(t, 0, s.count("update /etc/passwd"))
+
+	// Source and template files are also resolved.
+	assert.NoError(t, c.Create("/var/lib/rrdcached/db/a.rrd", nil, nil, Template("/var/lib/rrdcached/db/b c.rrd")))
+	assert.ErrorIs(t, c.Create("a.rrd", nil, nil, Source("../b.rrd")), ErrInvalidArg)
+
+	ctx := context.Background()
+	l, err := c.List(ctx, "/sub/")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"/sub/a.rrd"}, l)
+	}
+	_, err = c.List(ctx, "/sub/../../")
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	// Batches and pipelines.
+	assert.NoError(t, c.Batch(NewCmd("update").WithArgs("/var/lib/rrdcached/db/b.rrd", sample.Update())))
+	assert.Equal(t, 1, s.count("update b.rrd 1499968800:1"))
+	assert.ErrorIs(t, c.Batch(NewCmd("update").WithArgs("../b.rrd", sample.Update())), ErrInvalidArg)
+
+	p := c.NewPipeline()
+	p.Add(NewCmd("flush").WithArgs("../b.rrd"))
+	_, err = p.ExecWithContext(ctx)
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
@@ -0,0 +1,254 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// BatchCmdError represents the failure of a single command in a batch.
+type BatchCmdError struct {
+	// Index is the position of the command in the batch, starting at 0.
+	Index int
+
+	// Cmd is the command which failed, without arguments if the client redacts them.
+	Cmd string
+
+	Err error
+}
+
+// BatchError is the error returned when one or more commands in a batch fail.
+type BatchError struct {
+	Errors []BatchCmdError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, ce := range e.Errors {
+		msgs[i] = fmt.Sprintf("[%v] %v: %v", ce.Index, ce.Cmd, ce.Err)
+	}
+	return fmt.Sprintf("batch: %v command(s) failed: %v", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failed commands.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, ce := range e.Errors {
+		errs[i] = ce.Err
+	}
+	return errs
+}
+
+// Batch represents a set of commands, typically updates, which are queued
+// and sent to rrdcached using a single BATCH to reduce round trips.
+// A Batch is not safe for concurrent use.
+type Batch struct {
+	c    *Client
+	cmds []*Cmd
+}
+
+// NewBatch returns a new empty Batch for the client.
+func (c *Client) NewBatch() *Batch {
+	return &Batch{c: c}
+}
+
+// Add queues cmds.
+func (b *Batch) Add(cmds ...*Cmd) {
+	b.cmds = append(b.cmds, cmds...)
+}
+
+// Update queues an update of filename with samples.
+func (b *Batch) Update(filename string, samples ...Sample) error {
+	if len(samples) == 0 {
+		return ErrNoSamples
+	}
+
+	values := sampleUpdates(samples)
+	b.UpdateRaw(filename, values[0], values[1:]...)
+	return nil
+}
+
+// UpdateRaw queues an update of filename with the raw update values.
+// Values which would exceed the clients MaxCommandSize are queued as
+// multiple commands.
+func (b *Batch) UpdateRaw(filename string, value Update, values ...Update) {
+	b.Add(updateCmds(filename, value, values, b.c.maxCommandSize)...)
+}
+
+// Len returns the number of queued commands.
+func (b *Batch) Len() int {
+	return len(b.cmds)
+}
+
+// Cmds returns the queued commands.
+func (b *Batch) Cmds() []*Cmd {
+	return b.cmds
+}
+
+// Exec sends the queued commands and resets the batch.
+// If any of the commands fail a *BatchError is returned.
+func (b *Batch) Exec() error {
+	return b.ExecWithContext(context.Background())
+}
+
+// ExecWithContext sends the queued commands and resets the batch.
+// If any of the commands fail a *BatchError is returned.
+func (b *Batch) ExecWithContext(ctx context.Context) error {
+	if len(b.cmds) == 0 {
+		return nil
+	}
+
+	cmds := b.cmds
+	b.cmds = nil
+	return b.c.batch(ctx, cmds...)
+}
+
+// Batch initiates the bulk load of multiple commands.
+func (c *Client) Batch(cmds ...*Cmd) error {
+	return c.BatchWithContext(context.Background(), cmds...)
+}
+
+// BatchWithContext initiates the bulk load of multiple commands.
+func (c *Client) BatchWithContext(ctx context.Context, cmds ...*Cmd) error {
+	return c.batch(ctx, cmds...)
+}
+
+// batch performs a batch of cmds returning a *BatchError if any of them failed.
+func (c *Client) batch(ctx context.Context, cmds ...*Cmd) (err error) {
+	if c.lanes != nil {
+		lc, done := c.pick()
+		defer done()
+		return lc.batch(ctx, cmds...)
+	}
+
+	cmd := NewCmd("batch")
+	ctx, done := c.instrument(ctx, cmd)
+	defer func() { done(err) }()
+
+	if c.readOnly {
+		return &CommandError{Cmd: cmd.verb(), Err: ErrReadOnly}
+	}
+
+	resolved := make([]*Cmd, len(cmds))
+	for i, bc := range cmds {
+		rc, err := c.resolveCmd(bc)
+		if err != nil {
+			return &CommandError{Cmd: c.cmdString(bc), Err: err}
+		}
+		if err := rc.validate(); err != nil {
+			return &CommandError{Cmd: c.cmdString(bc), Err: err}
+		}
+		resolved[i] = rc
+	}
+	cmds = resolved
+
+	if err := c.limit(ctx, len(cmds)); err != nil {
+		return &CommandError{Cmd: cmd.verb(), Err: err}
+	}
+
+	report, err := c.admit(ctx)
+	if err != nil {
+		return &CommandError{Cmd: cmd.verb(), Err: err}
+	}
+	defer func() { report(err) }()
+
+	ctx, cancel := c.commandContext(ctx)
+	defer cancel()
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if _, err := c.execLocked(ctx, cmd); err != nil {
+		return &CommandError{Cmd: cmd.verb(), Err: err}
+	}
+
+	stop := c.watchContext(ctx)
+	rlines, err := c.execBatch(ctx, cmds...)
+	stop()
+	if err != nil {
+		return &CommandError{Cmd: cmd.verb(), Err: c.ctxErr(ctx, err)}
+	}
+
+	return c.batchError(cmds, rlines)
+}
+
+// batchError returns a *BatchError for the error lines rlines reported for
+// cmds, or nil if there are none.
+func (c *Client) batchError(cmds []*Cmd, rlines []string) error {
+	if len(rlines) == 0 {
+		return nil
+	}
+
+	berr := &BatchError{Errors: make([]BatchCmdError, len(rlines))}
+	for i, l := range rlines {
+		// Lines are of the form "<command number> <error message>".
+		n, msg, err := c.parser(l)
+		if err != nil {
+			return &CommandError{Cmd: "batch", Err: err}
+		}
+		if n < 1 || n > len(cmds) {
+			return &CommandError{Cmd: "batch", Err: NewInvalidResponseError("batch: invalid command number", l)}
+		}
+		berr.Errors[i] = BatchCmdError{
+			Index: n - 1,
+			Cmd:   c.cmdString(cmds[n-1]),
+			Err:   NewError(-1, msg),
+		}
+	}
+
+	return berr
+}
+
+// execBatch sends cmds followed by the batch terminator and returns the error
+// lines reported by rrdcached.
+func (c *Client) execBatch(ctx context.Context, cmds ...*Cmd) ([]string, error) {
+	if err := c.setWriteDeadline(ctx); err != nil {
+		return nil, err
+	}
+
+	b := c.writer.AvailableBuffer()
+	for _, cmd := range cmds {
+		b = cmd.appendTo(b)
+	}
+	if err := c.write(append(b, ".\n"...)); err != nil {
+		return nil, err
+	}
+
+	if err := c.setReadDeadline(ctx); err != nil {
+		return nil, err
+	}
+
+	l, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	cnt, msg, err := c.parser(l)
+	if err != nil {
+		// The error lines, if any, can't be skipped.
+		c.discard()
+		return nil, err
+	}
+
+	switch {
+	case cnt < 0:
+		return nil, NewError(cnt, msg)
+	case cnt == 0:
+		return nil, nil
+	}
+
+	if err := c.setReadDeadline(ctx); err != nil {
+		return nil, err
+	}
+	rlines := make([]string, cnt)
+	for i := range rlines {
+		if rlines[i], err = c.readLine(); err != nil {
+			return nil, err
+		}
+		if err := c.setReadDeadline(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return rlines, nil
+}
@@ -0,0 +1,91 @@
+package rrd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		".": {
+			"1 errors",
+			"2 illegal attempt to update using time 1499968800.000000 when last update time is 1499968800.000000 (minimum one second step)",
+		},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	b := c.NewBatch()
+	assert.NoError(t, b.Exec())
+
+	assert.NoError(t, b.Update("a.rrd", Sample{Time: time.Unix(1499968800, 0), Values: []float64{1, 2}}))
+	assert.NoError(t, b.Update("b.rrd",
+		Sample{Time: time.Unix(1499968800, 0), Values: []float64{3}},
+		Sample{Time: time.Unix(1499968860, 0), Values: []float64{4}},
+	))
+	b.UpdateRaw("c.rrd", "1499968800:5")
+	assert.Equal(t, ErrNoSamples, b.Update("d.rrd"))
+	assert.Equal(t, 3, b.Len())
+
+	err = b.Exec()
+	var berr *BatchError
+	if assert.True(t, errors.As(err, &berr)) && assert.Len(t, berr.Errors, 1) {
+		e := berr.Errors[0]
+		assert.Equal(t, 1, e.Index)
+		assert.Equal(t, "update b.rrd 1499968800:3 1499968860:4", e.Cmd)
+		assert.True(t, IsIllegalUpdate(e.Err))
+	}
+	assert.True(t, IsIllegalUpdate(err))
+	assert.Equal(t, 0, b.Len())
+
+	assert.Equal(t, 1, s.count("update a.rrd 1499968800:1:2"))
+	assert.Equal(t, 1, s.count("update c.rrd 1499968800:5"))
+
+}
+
+func TestBatchError(t *testing.T) {
+	c := &Client{parser: ParseResponseLine}
+	cmds := []*Cmd{NewCmd("flush").WithArgs("a.rrd"), NewCmd("flush").WithArgs("b.rrd")}
+
+	assert.NoError(t, c.batchError(cmds, nil))
+
+	err := c.batchError(cmds, []string{"2 No such file: b.rrd"})
+	var berr *BatchError
+	if assert.True(t, errors.As(err, &berr)) {
+		assert.Equal(t, []BatchCmdError{{Index: 1, Cmd: "flush b.rrd", Err: NewError(-1, "No such file: b.rrd")}}, berr.Errors)
+	}
+	assert.True(t, IsNotExist(err))
+
+	c.redact = true
+	err = c.batchError(cmds, []string{"1 No such file: a.rrd"})
+	if assert.True(t, errors.As(err, &berr)) {
+		assert.Equal(t, "flush", berr.Errors[0].Cmd)
+	}
+
+	for _, l := range []string{"3 bad", "0 bad", "bad"} {
+		err = c.batchError(cmds, []string{l})
+		assert.False(t, errors.As(err, &berr))
+		var cerr *CommandError
+		if assert.True(t, errors.As(err, &cerr), err) {
+			assert.Equal(t, "batch", cerr.Cmd)
+		}
+	}
+}
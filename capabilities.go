@@ -0,0 +1,93 @@
+package rrd
+
+import (
+	"context"
+	"strings"
+)
+
+// Capabilities represents the commands supported by a rrdcached server.
+type Capabilities struct {
+	cmds map[string]struct{}
+}
+
+// Has returns true if the server supports cmd, false otherwise.
+func (c *Capabilities) Has(cmd string) bool {
+	_, ok := c.cmds[strings.ToLower(cmd)]
+	return ok
+}
+
+// Commands returns the sorted lower case names of the supported commands.
+func (c *Capabilities) Commands() []string {
+	return sortedKeys(c.cmds)
+}
+
+// parseCapabilities returns the Capabilities described by the command
+// overview returned by help e.g. "UPDATE <filename> <values> [<values> ...]".
+func parseCapabilities(lines []string) *Capabilities {
+	c := &Capabilities{cmds: make(map[string]struct{}, len(lines))}
+	for _, l := range lines {
+		f := strings.Fields(l)
+		if len(f) == 0 || strings.ToUpper(f[0]) != f[0] {
+			continue
+		}
+		c.cmds[strings.ToLower(f[0])] = struct{}{}
+	}
+	return c
+}
+
+// DetectCapabilities enables detection of the commands supported by the
+// server when the client is created. Commands which the server doesn't
+// support then fail with ErrNotSupported without being sent.
+func DetectCapabilities(c *Client) error {
+	c.detectCaps = true
+	return nil
+}
+
+// Capabilities returns the commands supported by the server.
+// The result is requested from the server on first use and then cached.
+func (c *Client) Capabilities() (*Capabilities, error) {
+	return c.CapabilitiesWithContext(context.Background())
+}
+
+// CapabilitiesWithContext returns the commands supported by the server.
+// The result is requested from the server on first use and then cached.
+func (c *Client) CapabilitiesWithContext(ctx context.Context) (*Capabilities, error) {
+	c.m.Lock()
+	caps := c.caps
+	c.m.Unlock()
+	if caps != nil {
+		return caps, nil
+	}
+
+	lines, err := c.HelpWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caps = parseCapabilities(lines)
+	c.m.Lock()
+	c.caps = caps
+	c.m.Unlock()
+
+	return caps, nil
+}
+
+// supported returns ErrNotSupported if the server capabilities are known and
+// don't include cmd, nil otherwise.
+// The caller must hold the clients lock.
+func (c *Client) supported(cmd *Cmd) error {
+	if c.caps == nil {
+		return nil
+	}
+
+	switch v := cmd.verb(); v {
+	case "help", "quit":
+		// Always available.
+		return nil
+	default:
+		if !c.caps.Has(v) {
+			return ErrNotSupported
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package rrd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var helpOverview = []string{
+	"10 Command overview",
+	"UPDATE <filename> <values> [<values> ...]",
+	"FLUSH <filename>",
+	"FLUSHALL",
+	"PENDING <filename>",
+	"FORGET <filename>",
+	"QUEUE",
+	"STATS",
+	"HELP [<command>]",
+	"BATCH",
+	"QUIT",
+}
+
+func TestCapabilities(t *testing.T) {
+	caps := parseCapabilities(helpOverview[1:])
+	assert.True(t, caps.Has("update"))
+	assert.True(t, caps.Has("FLUSHALL"))
+	assert.False(t, caps.Has("list"))
+	assert.Equal(t, []string{"batch", "flush", "flushall", "forget", "help", "pending", "queue", "quit", "stats", "update"}, caps.Commands())
+
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{"help": helpOverview}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), DetectCapabilities)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	caps, err = c.Capabilities()
+	if assert.NoError(t, err) {
+		assert.True(t, caps.Has("flush"))
+	}
+	assert.Equal(t, 1, s.count("help"))
+
+	assert.NoError(t, c.Flush("test.rrd"))
+	assert.ErrorIs(t, c.Suspend("test.rrd"), ErrNotSupported)
+	assert.Equal(t, 0, s.count("suspend"))
+}
+
+func TestNotSupported(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	_, err = c.Exec("unknown")
+	assert.True(t, errors.Is(err, ErrNotSupported), "unexpected error %v", err)
+	assert.False(t, errors.Is(err, ErrNotFound))
+}
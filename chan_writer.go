@@ -0,0 +1,267 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultChanBuffer is the default capacity of the channel of a
+	// ChanWriter.
+	DefaultChanBuffer = 1000
+
+	// DefaultChanMaxPending is the default number of samples a ChanWriter
+	// holds before it applies its OverflowPolicy.
+	DefaultChanMaxPending = 10000
+
+	// DefaultChanFlushInterval is the default interval at which a
+	// ChanWriter sends its pending samples.
+	DefaultChanFlushInterval = time.Second
+)
+
+// OverflowPolicy determines what a ChanWriter does with samples it
+// receives while it holds the maximum pending samples and is still sending
+// previous ones.
+type OverflowPolicy int
+
+const (
+	// Block stops receiving samples until they can be held, so senders
+	// block once the channel is full.
+	Block OverflowPolicy = iota
+
+	// Drop drops the samples, reporting the number dropped to the error
+	// handler as ErrQueueFull, so senders never block on the server.
+	Drop
+)
+
+// FileSample is a sample of the RRD Filename sent to a ChanWriter.
+type FileSample struct {
+	Filename string
+	Sample
+}
+
+// ChanBuffer sets the capacity of the channel of a ChanWriter.
+func ChanBuffer(n int) func(*ChanWriter) error {
+	return func(w *ChanWriter) error {
+		if n < 0 {
+			return fmt.Errorf("%w: chan buffer %v", ErrInvalidArg, n)
+		}
+		w.buffer = n
+		return nil
+	}
+}
+
+// ChanMaxPending sets the number of samples a ChanWriter holds before they
+// are sent without waiting for the flush interval. If more samples are
+// received while they're being sent its OverflowPolicy applies.
+func ChanMaxPending(n int) func(*ChanWriter) error {
+	return func(w *ChanWriter) error {
+		if n < 1 {
+			return fmt.Errorf("%w: max pending %v", ErrInvalidArg, n)
+		}
+		w.maxPending = n
+		return nil
+	}
+}
+
+// ChanFlushInterval sets the interval at which a ChanWriter sends its
+// pending samples.
+func ChanFlushInterval(d time.Duration) func(*ChanWriter) error {
+	return func(w *ChanWriter) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: flush interval %v", ErrInvalidArg, d)
+		}
+		w.interval = d
+		return nil
+	}
+}
+
+// ChanOverflow sets the OverflowPolicy of a ChanWriter, by default Block.
+func ChanOverflow(p OverflowPolicy) func(*ChanWriter) error {
+	return func(w *ChanWriter) error {
+		switch p {
+		case Block, Drop:
+		default:
+			return fmt.Errorf("%w: overflow policy %v", ErrInvalidArg, p)
+		}
+		w.policy = p
+		return nil
+	}
+}
+
+// ChanErrorHandler sets the function called, from the writers background
+// goroutines, with the errors of sending samples and dropped samples, by
+// default they are logged by the clients logger.
+func ChanErrorHandler(f func(err error)) func(*ChanWriter) error {
+	return func(w *ChanWriter) error {
+		if f == nil {
+			return ErrNilOption
+		}
+		w.onError = f
+		return nil
+	}
+}
+
+// ChanWriter receives samples on the channel C and sends them to rrdcached
+// from background goroutines, coalescing them per file into a batch at
+// each flush interval or once the maximum pending samples are held, which
+// suits pipeline style collectors.
+// While a batch is being sent further samples are held, and once the
+// maximum is reached its OverflowPolicy applies. Samples which fail to be
+// sent are dropped and reported to the writers error handler.
+type ChanWriter struct {
+	// C is the channel samples are sent on. Samples with a zero time are
+	// given the time they're received.
+	C chan<- FileSample
+
+	c          *Client
+	in         chan FileSample
+	buffer     int
+	maxPending int
+	interval   time.Duration
+	policy     OverflowPolicy
+	onError    func(err error)
+	dropped    atomic.Int64
+
+	batches chan *coalescer
+	wg      sync.WaitGroup
+	close   sync.Once
+}
+
+// NewChanWriter returns a new ChanWriter which sends updates using c.
+// The writer must be closed with Close to send the remaining samples and
+// stop its background goroutines.
+func NewChanWriter(c *Client, options ...func(*ChanWriter) error) (*ChanWriter, error) {
+	w := &ChanWriter{
+		c:          c,
+		buffer:     DefaultChanBuffer,
+		maxPending: DefaultChanMaxPending,
+		interval:   DefaultChanFlushInterval,
+		batches:    make(chan *coalescer),
+	}
+	w.onError = func(err error) {
+		c.logger.Error("chan write failed", "error", err)
+	}
+	for _, f := range options {
+		if f == nil {
+			return nil, ErrNilOption
+		}
+		if err := f(w); err != nil {
+			return nil, err
+		}
+	}
+
+	w.in = make(chan FileSample, w.buffer)
+	w.C = w.in
+
+	w.wg.Add(2)
+	go w.receive()
+	go w.send()
+
+	return w, nil
+}
+
+// Dropped returns the number of samples dropped by the Drop policy.
+func (w *ChanWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// Close closes C, sends the remaining samples and stops the writer.
+// Samples must not be sent on C once Close has been called. Close doesn't
+// close the client.
+func (w *ChanWriter) Close() error {
+	w.close.Do(func() {
+		close(w.in)
+	})
+	w.wg.Wait()
+	return nil
+}
+
+// receive receives samples until C is closed, passing batches of them to
+// send at each interval or once the maximum pending samples are held.
+func (w *ChanWriter) receive() {
+	defer w.wg.Done()
+	defer close(w.batches)
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	b := &coalescer{}
+	var dropped int64
+	for {
+		select {
+		case s, ok := <-w.in:
+			if !ok {
+				w.reportDropped(dropped)
+				if b.n > 0 {
+					w.batches <- b
+				}
+				return
+			}
+			if s.Time.IsZero() {
+				s.Time = time.Now()
+			}
+
+			if b.n < w.maxPending {
+				b.add(s.Filename, s.Update())
+				if b.n == w.maxPending && w.handoff(b) {
+					b = &coalescer{}
+				}
+				continue
+			}
+
+			// The sender is still busy with the previous batch.
+			if w.policy == Block {
+				w.batches <- b
+				b = &coalescer{}
+				b.add(s.Filename, s.Update())
+				continue
+			}
+			if w.handoff(b) {
+				b = &coalescer{}
+				b.add(s.Filename, s.Update())
+				continue
+			}
+			dropped++
+			w.dropped.Add(1)
+		case <-t.C:
+			w.reportDropped(dropped)
+			dropped = 0
+			if b.n > 0 && w.handoff(b) {
+				b = &coalescer{}
+			}
+		}
+	}
+}
+
+// reportDropped reports n dropped samples, if any, to the error handler.
+func (w *ChanWriter) reportDropped(n int64) {
+	if n > 0 {
+		w.onError(fmt.Errorf("chan writer: %w: dropped %v samples", ErrQueueFull, n))
+	}
+}
+
+// handoff passes b to send without blocking, returning false if it's busy.
+func (w *ChanWriter) handoff(b *coalescer) bool {
+	select {
+	case w.batches <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+// send sends the batches it receives until there are no more.
+func (w *ChanWriter) send() {
+	defer w.wg.Done()
+
+	for b := range w.batches {
+		cmds, _ := b.cmds(w.c.maxCommandSize)
+		if err := w.c.BatchWithContext(context.Background(), cmds...); err != nil {
+			w.onError(fmt.Errorf("chan writer: %w", err))
+		}
+	}
+}
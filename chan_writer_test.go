@@ -0,0 +1,156 @@
+package rrd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChanWriter(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	_, err = NewChanWriter(c, nil)
+	assert.Equal(t, ErrNilOption, err)
+	_, err = NewChanWriter(c, ChanBuffer(-1))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewChanWriter(c, ChanMaxPending(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewChanWriter(c, ChanFlushInterval(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewChanWriter(c, ChanOverflow(OverflowPolicy(-1)))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewChanWriter(c, ChanErrorHandler(nil))
+	assert.Equal(t, ErrNilOption, err)
+
+	w, err := NewChanWriter(c, ChanFlushInterval(time.Hour))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	w.C <- FileSample{Filename: "a.rrd", Sample: Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}}
+	w.C <- FileSample{Filename: "b.rrd", Sample: Sample{Time: time.Unix(1499968800, 0), Values: []float64{2}}}
+	w.C <- FileSample{Filename: "a.rrd", Sample: Sample{Time: time.Unix(1499968860, 0), Values: []float64{3}}}
+
+	// Close sends the remaining samples, coalesced per file.
+	assert.NoError(t, w.Close())
+	assert.NoError(t, w.Close())
+	assert.Equal(t, 1, s.count("batch"))
+	assert.Equal(t, 1, s.count("update a.rrd 1499968800:1 1499968860:3"))
+	assert.Equal(t, 1, s.count("update b.rrd 1499968800:2"))
+	assert.Zero(t, w.Dropped())
+}
+
+// gateConn is a connection whose writes of batches block until the gate
+// is opened.
+type gateConn struct {
+	net.Conn
+	entered chan struct{}
+	open    chan struct{}
+}
+
+func (c *gateConn) Write(b []byte) (int, error) {
+	if bytes.HasPrefix(b, []byte("batch")) {
+		c.entered <- struct{}{}
+		<-c.open
+	}
+	return c.Conn.Write(b)
+}
+
+func TestChanWriterDrop(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	entered := make(chan struct{}, 2)
+	open := make(chan struct{})
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &gateConn{Conn: conn, entered: entered, open: open}, nil
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	var m sync.Mutex
+	var errs []error
+	w, err := NewChanWriter(c,
+		ChanBuffer(0),
+		ChanMaxPending(2),
+		ChanFlushInterval(time.Millisecond*20),
+		ChanOverflow(Drop),
+		ChanErrorHandler(func(err error) {
+			m.Lock()
+			defer m.Unlock()
+			errs = append(errs, err)
+		}),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sample := func(v float64) FileSample {
+		return FileSample{Filename: "a.rrd", Sample: Sample{Time: time.Unix(1499968800+int64(v), 0), Values: []float64{v}}}
+	}
+	w.C <- sample(1)
+	w.C <- sample(2)
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("batch not sent")
+	}
+
+	// While the first batch is being sent the next is held, then samples
+	// are dropped.
+	w.C <- sample(3)
+	w.C <- sample(4)
+	w.C <- sample(5)
+	assert.Eventually(t, func() bool {
+		return w.Dropped() == 1
+	}, time.Second, time.Millisecond*10)
+
+	close(open)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, 2, s.count("batch"))
+	assert.Equal(t, 1, s.count("update a.rrd 1499968801:1 1499968802:2"))
+	assert.Equal(t, 1, s.count("update a.rrd 1499968803:3 1499968804:4"))
+	assert.Equal(t, 0, s.count("update a.rrd 1499968805:5"))
+
+	m.Lock()
+	defer m.Unlock()
+	if assert.Len(t, errs, 1) {
+		assert.ErrorIs(t, errs[0], ErrQueueFull)
+	}
+}
@@ -0,0 +1,149 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker makes the client fail fast with ErrCircuitOpen once
+// threshold consecutive commands have failed to get a response from the
+// server, instead of each command waiting for the connection timeout and
+// retries. After cooldown a single command is permitted to probe the
+// server, closing the circuit if it succeeds or opening it for another
+// cooldown if not. Errors reported by the server, invalid responses, errors
+// returned by a LineFunc and ErrNotSupported don't count as failures.
+func CircuitBreaker(threshold int, cooldown time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if threshold < 1 || cooldown <= 0 {
+			return fmt.Errorf("%w: circuit breaker threshold %v cooldown %v", ErrInvalidArg, threshold, cooldown)
+		}
+		c.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown}
+		return nil
+	}
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed permits commands.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen rejects commands until the cooldown has passed.
+	CircuitOpen
+
+	// CircuitHalfOpen permits a single command to probe the server.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// circuitBreaker tracks consecutive failures to reach the server.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	m        sync.Mutex
+	failures int
+	opened   time.Time
+	probing  bool
+}
+
+// state returns the state of b at now.
+// The caller must hold the lock.
+func (b *circuitBreaker) state(now time.Time) CircuitState {
+	switch {
+	case b.failures < b.threshold:
+		return CircuitClosed
+	case b.probing || now.Sub(b.opened) >= b.cooldown:
+		return CircuitHalfOpen
+	}
+	return CircuitOpen
+}
+
+// allow returns ErrCircuitOpen if a command isn't permitted.
+func (b *circuitBreaker) allow() error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	now := time.Now()
+	switch b.state(now) {
+	case CircuitOpen:
+		return fmt.Errorf("%w: retry in %v", ErrCircuitOpen, b.cooldown-now.Sub(b.opened))
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: probe in progress", ErrCircuitOpen)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// done records the result err of a command permitted by allow, which was
+// performed with ctx.
+func (b *circuitBreaker) done(ctx context.Context, err error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	probe := b.probing
+	b.probing = false
+	switch {
+	case err == nil || reachedServer(err):
+		b.failures = 0
+	case ctx.Err() != nil || errors.Is(err, ErrNotSupported):
+		// Abandoned by the caller or refused by the client so says nothing
+		// about the server.
+	default:
+		b.failures++
+		if probe || b.failures >= b.threshold {
+			b.failures = b.threshold
+			b.opened = time.Now()
+		}
+	}
+}
+
+// reachedServer returns true if err was reported by the server or the
+// server sent a response which couldn't be parsed.
+func reachedServer(err error) bool {
+	var rerr *Error
+	var berr *BatchError
+	var ierr *InvalidResponseError
+	return errors.As(err, &rerr) || errors.As(err, &berr) || errors.As(err, &ierr)
+}
+
+// CircuitState returns the state of the clients circuit breaker, which is
+// always CircuitClosed if the client wasn't created with CircuitBreaker.
+func (c *Client) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	c.breaker.m.Lock()
+	defer c.breaker.m.Unlock()
+	return c.breaker.state(time.Now())
+}
+
+// admit returns ErrCircuitOpen if the clients circuit breaker, if any,
+// doesn't permit a command, otherwise a function which must be called with
+// the result of the command.
+func (c *Client) admit(ctx context.Context) (func(err error), error) {
+	if c.breaker == nil {
+		return func(error) {}, nil
+	}
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	return func(err error) { c.breaker.done(ctx, err) }, nil
+}
@@ -0,0 +1,137 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerOption(t *testing.T) {
+	for _, o := range []func(*Client) error{CircuitBreaker(0, time.Second), CircuitBreaker(1, 0)} {
+		assert.ErrorIs(t, o(&Client{}), ErrInvalidArg)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{threshold: 2, cooldown: time.Millisecond * 50}
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	// Server errors and successes reset the failures.
+	assert.NoError(t, b.allow())
+	b.done(ctx, failed)
+	assert.NoError(t, b.allow())
+	b.done(ctx, &CommandError{Cmd: "flush", Err: NewError(-1, "No such file")})
+	assert.NoError(t, b.allow())
+	b.done(ctx, failed)
+	assert.Equal(t, CircuitClosed, b.state(time.Now()))
+
+	// Commands refused by the client don't count.
+	for i := 0; i < b.threshold; i++ {
+		assert.NoError(t, b.allow())
+		b.done(ctx, &CommandError{Cmd: "suspend", Err: ErrNotSupported})
+	}
+	assert.Equal(t, CircuitClosed, b.state(time.Now()))
+
+	// Commands abandoned by the caller don't count.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.NoError(t, b.allow())
+	b.done(cctx, context.Canceled)
+	assert.Equal(t, CircuitClosed, b.state(time.Now()))
+
+	assert.NoError(t, b.allow())
+	b.done(ctx, failed)
+	assert.Equal(t, CircuitOpen, b.state(time.Now()))
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	// A single probe is permitted after the cooldown, reopening on failure.
+	time.Sleep(b.cooldown)
+	assert.Equal(t, CircuitHalfOpen, b.state(time.Now()))
+	assert.NoError(t, b.allow())
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+	b.done(ctx, failed)
+	assert.Equal(t, CircuitOpen, b.state(time.Now()))
+
+	// And closing on success.
+	time.Sleep(b.cooldown)
+	assert.NoError(t, b.allow())
+	b.done(ctx, nil)
+	assert.Equal(t, CircuitClosed, b.state(time.Now()))
+	assert.NoError(t, b.allow())
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), CircuitBreaker(2, time.Minute), Retry(RetryPolicy{MaxAttempts: 1}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.CloseNoQuit())
+	}()
+
+	assert.NoError(t, c.Ping())
+	assert.Equal(t, CircuitClosed, c.CircuitState())
+
+	assert.NoError(t, s.Close())
+	for i := 0; i < 2; i++ {
+		err := c.Ping()
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+	assert.Equal(t, CircuitOpen, c.CircuitState())
+
+	assert.ErrorIs(t, c.Ping(), ErrCircuitOpen)
+	assert.ErrorIs(t, c.Batch(NewCmd("update").WithArgs("test.rrd", "N:1")), ErrCircuitOpen)
+	p := c.NewPipeline()
+	p.Add(NewCmd("ping"))
+	_, err = p.Exec()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+}
+
+func TestClientCircuitBreakerLocalErrors(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"help":  helpOverview,
+		"stats": {"invalid"},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), DetectCapabilities, CircuitBreaker(2, time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	stop := errors.New("stop")
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, c.Suspend("test.rrd"), ErrNotSupported)
+
+		var ierr *InvalidResponseError
+		_, err := c.Stats()
+		assert.True(t, errors.As(err, &ierr), err)
+
+		err = c.ExecCmdStream(context.Background(), NewCmd("flushall"), func(string) error { return stop })
+		assert.ErrorIs(t, err, stop)
+	}
+	assert.Equal(t, CircuitClosed, c.CircuitState())
+	assert.NoError(t, c.Flush("test.rrd"))
+}
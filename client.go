@@ -5,28 +5,42 @@ package rrd
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"net/url"
 	"os"
-	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	// DefaultPort is the default rrdcached port.
 	DefaultPort = 42217
+
+	// initialLineBuffer is the initial size of the response line buffer.
+	initialLineBuffer = 4096
 )
 
 var (
-	respRe = regexp.MustCompile(`^(-?\d+)\s+(.*)$`)
+	// DefaultMaxLineSize is the default maximum length of a response line.
+	DefaultMaxLineSize = bufio.MaxScanTokenSize
+
+	// DefaultMaxCommandSize is the default maximum length of an update
+	// command line, that of the rrdcached client library.
+	DefaultMaxCommandSize = 4096
 
-	// DefaultTimeout is the default read / write / dial timeout for Clients.
+	// DefaultTimeout is the default read / write / dial timeout for Clients,
+	// see Timeout.
 	DefaultTimeout        = time.Second * 10
 	ErrReconnectionFailed = errors.New("failed to reconnect")
 )
@@ -36,13 +50,88 @@ type Client struct {
 	conn    net.Conn
 	addr    string
 	network string
+	port    int
 	timeout time.Duration
-	scanner *bufio.Scanner
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	noDelay *bool
+
+	// dialTimeout, readTimeout and writeTimeout override timeout if set.
+	dialTimeout     time.Duration
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	commandDeadline time.Duration
+
+	readOnly  bool
+	baseDir   string
+	redact    bool
+	rrdtool   string
+	executor  Executor
+	infoCache *infoCache
+	parser    ResponseParserFunc
+
+	retry       RetryPolicy
+	noReconnect bool
+
+	detectCaps      bool
+	rrdtoolFallback bool
+	caps            *Capabilities
+	logger          *slog.Logger
+	observer        Observer
+	tracer          Tracer
+	limiter         *rateLimiter
+	breaker         *circuitBreaker
+	stats           clientStats
+	tlsConfig       *tls.Config
+	dial            DialFunc
+	customDial      bool
+	proxy           *url.URL
+
+	// lanes are the clients of each connection if the client was created
+	// with Connections, with next the index of the next to use.
+	connections int
+	dispatch    DispatchStrategy
+	lanes       []*lane
+	next        atomic.Uint64
+
+	maxLineSize    int
+	maxCommandSize int
+
+	// used is the time of the last activity on the connection.
+	used        time.Time
+	keepalive   time.Duration
+	idleTimeout time.Duration
+	lazy        bool
+
+	// addrs are the addresses of the servers in priority order if the client
+	// was created with Failover or DNSDiscovery, with cur the index of the
+	// one in use.
+	addrs            []string
+	cur              int
+	addrMu           sync.Mutex
+	backups          []string
+	discovery        time.Duration
+	resolver         resolver
+	failback         atomic.Bool
+	failbackInterval time.Duration
+
+	// stop is closed to stop the background goroutines tracked by wg.
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	healthThreshold time.Duration
+
+	// active is the connection in use by the current command, which Close
+	// interrupts if it can't wait for it to complete.
+	active   net.Conn
+	activeMu sync.Mutex
 
 	m sync.Mutex
 }
 
 // Timeout sets read / write / dial timeout for a rrdcached Client.
+// DialTimeout, ReadTimeout and WriteTimeout take precedence over it.
 func Timeout(timeout time.Duration) func(*Client) error {
 	return func(c *Client) error {
 		c.timeout = timeout
@@ -50,17 +139,286 @@ func Timeout(timeout time.Duration) func(*Client) error {
 	}
 }
 
-// Unix sets the client to use a unix socket.
+// DialTimeout sets the timeout for establishing a connection to the server,
+// including the TLS handshake.
+func DialTimeout(timeout time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("%w: dial timeout %v", ErrInvalidArg, timeout)
+		}
+		c.dialTimeout = timeout
+		return nil
+	}
+}
+
+// ReadTimeout sets the timeout for reading each line of a response, so a
+// large fetch is permitted as long as the server keeps responding.
+func ReadTimeout(timeout time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("%w: read timeout %v", ErrInvalidArg, timeout)
+		}
+		c.readTimeout = timeout
+		return nil
+	}
+}
+
+// WriteTimeout sets the timeout for writing a command to the server.
+func WriteTimeout(timeout time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("%w: write timeout %v", ErrInvalidArg, timeout)
+		}
+		c.writeTimeout = timeout
+		return nil
+	}
+}
+
+// CommandDeadline limits the total time each command may take, including
+// reconnecting and retrying, after which it fails with
+// context.DeadlineExceeded. By default only the individual dial, read and
+// write timeouts apply.
+func CommandDeadline(d time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: command deadline %v", ErrInvalidArg, d)
+		}
+		c.commandDeadline = d
+		return nil
+	}
+}
+
+// timeoutOr returns timeout if set, otherwise the clients timeout.
+func (c *Client) timeoutOr(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return c.timeout
+}
+
+// commandContext returns ctx limited by the clients command deadline, if
+// any, and the function to release its resources.
+func (c *Client) commandContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.commandDeadline == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.commandDeadline)
+}
+
+// NoDelay sets TCP_NODELAY on TCP connections to the server. Go enables it by
+// default, minimising the latency of each command, disabling it enables
+// Nagle's algorithm which delays sending small writes in the hope of sending
+// fewer packets, which may help when many clients share a slow network.
+func NoDelay(noDelay bool) func(*Client) error {
+	return func(c *Client) error {
+		c.noDelay = &noDelay
+		return nil
+	}
+}
+
+// TLS sets the client to connect using TLS with cfg, for use with rrdcached
+// exposed behind a TLS terminating proxy. If cfg doesn't specify a
+// ServerName it's derived from the address for SNI and verification.
+// Client certificates can be provided with cfg.Certificates.
+func TLS(cfg *tls.Config) func(*Client) error {
+	return func(c *Client) error {
+		if cfg == nil {
+			return ErrNilOption
+		}
+		c.tlsConfig = cfg.Clone()
+		return nil
+	}
+}
+
+// DialFunc is a function which establishes connections to the server.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Dialer sets the function used to establish connections to the server,
+// allowing the use of proxied, instrumented or pre-established connections.
+// The clients timeout is applied to ctx and if TLS is set the connection
+// returned by f is wrapped with it.
+func Dialer(f DialFunc) func(*Client) error {
+	return func(c *Client) error {
+		if f == nil {
+			return ErrNilOption
+		}
+		c.dial = f
+		c.customDial = true
+		return nil
+	}
+}
+
+// MaxLineSize sets the maximum length of a response line, which defaults
+// to DefaultMaxLineSize. Responses with longer lines, such as a fetch of
+// many data sources, fail with bufio.ErrTooLong.
+func MaxLineSize(size int) func(*Client) error {
+	return func(c *Client) error {
+		if size <= 0 {
+			return fmt.Errorf("invalid max line size %v", size)
+		}
+		c.maxLineSize = size
+		return nil
+	}
+}
+
+// MaxCommandSize sets the maximum length of an update command line, which
+// defaults to DefaultMaxCommandSize. Updates with more values are split
+// into multiple commands.
+func MaxCommandSize(size int) func(*Client) error {
+	return func(c *Client) error {
+		if size <= 0 {
+			return fmt.Errorf("%w: max command size %v", ErrInvalidArg, size)
+		}
+		c.maxCommandSize = size
+		return nil
+	}
+}
+
+// Port sets the port used for TCP addresses which don't include one,
+// instead of DefaultPort.
+func Port(port int) func(*Client) error {
+	return func(c *Client) error {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("%w: port %v", ErrInvalidArg, port)
+		}
+		c.port = port
+		return nil
+	}
+}
+
+// Unix sets the client to use a unix socket. Alternatively the address
+// passed to NewClient can be given as unix:///path/to/socket.
+// Addresses starting with "@", such as "@rrdcached", are Linux abstract
+// sockets, which are used without Unix.
 func Unix(c *Client) error {
 	c.network = "unix"
 	return nil
 }
 
+// LazyConnect defers connecting to the server until the first command, so
+// NewClient doesn't fail if the server is unavailable.
+func LazyConnect(c *Client) error {
+	c.lazy = true
+	return nil
+}
+
+// ResponseParserFunc parses a response status line returning the number of
+// lines which follow, or the negative error code, and the message.
+type ResponseParserFunc func(line string) (cnt int, msg string, err error)
+
+// ParseResponseLine is the default ResponseParserFunc, which parses lines
+// of the form "<count> <message>".
+func ParseResponseLine(line string) (int, string, error) {
+	// Parsed by hand as it's called for every command.
+	i := 0
+	if i < len(line) && line[i] == '-' {
+		i++
+	}
+	digits := i
+	for i < len(line) && line[i] >= '0' && line[i] <= '9' {
+		i++
+	}
+	if i == digits {
+		return 0, "", NewInvalidResponseError("invalid response line", line)
+	}
+	end := i
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	if i == end {
+		return 0, "", NewInvalidResponseError("invalid response line", line)
+	}
+
+	cnt, err := strconv.Atoi(line[:end])
+	if err != nil {
+		return 0, "", NewInvalidResponseError("invalid response count", line)
+	}
+
+	return cnt, line[i:], nil
+}
+
+// isSpace returns true if b is an ASCII whitespace character.
+func isSpace(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+// ResponseParser sets the parser used for response status lines, allowing
+// the client to be used with rrdcached compatible servers whose responses
+// differ slightly.
+func ResponseParser(f ResponseParserFunc) func(*Client) error {
+	return func(c *Client) error {
+		if f == nil {
+			return ErrNilOption
+		}
+		c.parser = f
+		return nil
+	}
+}
+
+// Logger sets the logger used for diagnostic output such as commands sent
+// and reconnection attempts. By default nothing is logged.
+func Logger(l *slog.Logger) func(*Client) error {
+	return func(c *Client) error {
+		if l == nil {
+			return ErrNilOption
+		}
+		c.logger = l
+		return nil
+	}
+}
+
+// discardHandler is a slog.Handler which discards all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// Redact sets the client to omit command arguments, which may contain
+// sensitive filenames, from errors and logs.
+func Redact(c *Client) error {
+	c.redact = true
+	return nil
+}
+
+// ReadOnly sets the client to reject commands which modify RRDs or the
+// daemons state with ErrReadOnly before they are sent to the server.
+func ReadOnly(c *Client) error {
+	c.readOnly = true
+	return nil
+}
+
 // NewClient returns a new rrdcached client connected to addr.
-// By default addr is treated as a TCP address to use UNIX sockets pass Unix as an option.
-// If addr for a TCP address doesn't include a port the DefaultPort will be used.
+// The addr may be a URL of the form unix:///path/to/socket or tcp://host:port,
+// otherwise it's treated as a TCP address, to use UNIX sockets pass Unix as an option.
+// The rrdcached style unix:/path/to/socket is also accepted, as is a Linux
+// abstract socket such as @rrdcached.
+// If addr for a TCP address doesn't include a port the DefaultPort will be
+// used, see Port. IPv6 addresses may be given as ::1, [::1] or [::1]:42217.
 func NewClient(addr string, options ...func(c *Client) error) (*Client, error) {
-	c := &Client{timeout: DefaultTimeout, network: "tcp", addr: addr}
+	c := &Client{
+		timeout:  DefaultTimeout,
+		network:  "tcp",
+		port:     DefaultPort,
+		addr:     addr,
+		rrdtool:  DefaultRRDTool,
+		parser:   ParseResponseLine,
+		logger:   slog.New(discardHandler{}),
+		retry:    DefaultRetryPolicy,
+		dial:     dialContext,
+		resolver: net.DefaultResolver,
+
+		maxLineSize:      DefaultMaxLineSize,
+		maxCommandSize:   DefaultMaxCommandSize,
+		healthThreshold:  DefaultHealthThreshold,
+		failbackInterval: DefaultFailbackInterval,
+		stop:             make(chan struct{}),
+	}
 	for _, f := range options {
 		if f == nil {
 			return nil, ErrNilOption
@@ -69,150 +427,759 @@ func NewClient(addr string, options ...func(c *Client) error) (*Client, error) {
 			return nil, err
 		}
 	}
-	if c.network == "tcp" {
-		if !strings.Contains(c.addr, ":") {
-			c.addr = fmt.Sprintf("%v:%v", c.addr, DefaultPort)
+	var err error
+	if c.network, c.addr, err = parseAddr(c.network, c.addr); err != nil {
+		return nil, err
+	}
+	if c.proxy != nil && c.network != "tcp" {
+		return nil, fmt.Errorf("%w: proxy not supported for %v", ErrInvalidArg, c.network)
+	}
+	name := c.addr
+	c.addr = c.normalizeAddr(c.addr)
+	if c.connections > 1 {
+		if err := c.newLanes(addr, options); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	for i, a := range c.backups {
+		network, addr, err := parseAddr(c.network, a)
+		if err != nil {
+			return nil, err
+		}
+		if network != c.network {
+			return nil, fmt.Errorf("%w: failover address %q isn't %v", ErrInvalidArg, a, c.network)
 		}
+		c.backups[i] = c.normalizeAddr(addr)
 	}
-	err := c.initConnection()
-	if err != nil {
-		return nil, fmt.Errorf("failed to establish initial connection: %w", err)
+	switch {
+	case c.discovery > 0:
+		if err := c.discover(context.Background(), name); err != nil {
+			return nil, err
+		}
+	case len(c.backups) > 0:
+		c.setAddrs(append([]string{c.addr}, c.backups...))
 	}
+	if !c.lazy {
+		if err := c.initConnection(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to establish initial connection: %w", err)
+		}
+	}
+	if c.detectCaps {
+		if _, err := c.Capabilities(); err != nil {
+			c.Close() // nolint: errcheck
+			return nil, fmt.Errorf("failed to detect capabilities: %w", err)
+		}
+	}
+	c.startKeepalive()
+	c.startIdleTimeout()
+	c.startFailback()
+	c.startDiscovery(name)
 	return c, nil
 }
 
-func (c *Client) initConnection() error {
-	var err error
-	if c.conn, err = net.DialTimeout(c.network, c.addr, c.timeout); err != nil {
-		return fmt.Errorf("failed to dial: %w", err)
+// parseAddr returns the network and address for addr, which may be a URL
+// with a unix or tcp scheme, or a plain address which uses network.
+func parseAddr(network, addr string) (string, string, error) {
+	scheme, rest, ok := strings.Cut(addr, "://")
+	switch {
+	case ok:
+		rest = strings.TrimSuffix(rest, "/")
+	case strings.HasPrefix(addr, "unix:"):
+		scheme, rest = "unix", strings.TrimPrefix(addr, "unix:")
+	case isAbstract(addr):
+		scheme, rest = "unix", addr
+	default:
+		return network, addr, nil
 	}
 
-	c.scanner = bufio.NewScanner(bufio.NewReader(c.conn))
-	c.scanner.Split(bufio.ScanLines)
+	switch scheme {
+	case "unix":
+		if rest == "" {
+			return "", "", fmt.Errorf("%w: no socket path in %q", ErrInvalidArg, addr)
+		}
+		if isAbstract(rest) && !abstractSockets {
+			return "", "", fmt.Errorf("%w: abstract socket %q not supported on %v", ErrInvalidArg, rest, runtime.GOOS)
+		}
+		return "unix", rest, nil
+	case "tcp":
+		if rest == "" {
+			return "", "", fmt.Errorf("%w: no host in %q", ErrInvalidArg, addr)
+		}
+		return "tcp", rest, nil
+	default:
+		return "", "", fmt.Errorf("%w: unsupported address scheme %q", ErrInvalidArg, scheme)
+	}
+}
+
+// normalizeAddr returns addr with the clients port, DefaultPort unless
+// set by Port, added to TCP addresses which don't include one. IPv6
+// literals, optionally with a zone, may be given with or without brackets.
+func (c *Client) normalizeAddr(addr string) string {
+	if c.network != "tcp" {
+		return addr
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		if port != "" {
+			return addr
+		}
+		addr = host
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	return net.JoinHostPort(host, strconv.Itoa(c.port))
+}
+
+// initConnection connects to the server, trying each of the failover
+// servers in turn, starting with the current, if configured.
+func (c *Client) initConnection(ctx context.Context) error {
+	addrs := c.candidates()
+	if len(addrs) == 0 {
+		return c.connect(ctx, c.addr)
+	}
+
+	var errs []error
+	for i, addr := range addrs {
+		err := c.connect(ctx, addr)
+		if err == nil {
+			if i > 0 {
+				c.logger.WarnContext(ctx, "failed over", "from", addrs[0], "to", addr)
+			}
+			c.setCurrent(addr)
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%v: %w", addr, err))
+	}
+	return errors.Join(errs...)
+}
+
+// connect establishes a connection to addr, making it the clients connection.
+func (c *Client) connect(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeoutOr(c.dialTimeout))
+	defer cancel()
+
+	conn, err := c.dialAddr(ctx, addr)
+	if err != nil {
+		return err
+	}
+	c.conn = &statsConn{Conn: conn, stats: &c.stats}
+	c.addr = addr
+	c.used = time.Now()
+
+	c.reader = bufio.NewReaderSize(c.conn, min(c.maxLineSize, initialLineBuffer))
+	c.writer = bufio.NewWriterSize(c.conn, initialLineBuffer)
 
 	return nil
 }
 
-// setDeadline updates the deadline on the connection based on the clients configured timeout.
-func (c *Client) setDeadline() error {
-	return c.conn.SetDeadline(time.Now().Add(c.timeout))
+// dialAddr returns a new connection to addr.
+func (c *Client) dialAddr(ctx context.Context, addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if c.proxy != nil {
+		conn, err = c.dialProxy(ctx, addr)
+	} else {
+		conn, err = c.dial(ctx, c.network, addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
+	if c.noDelay != nil {
+		if tc, ok := conn.(interface{ SetNoDelay(bool) error }); ok {
+			if err := tc.SetNoDelay(*c.noDelay); err != nil {
+				conn.Close() // nolint: errcheck
+				return nil, fmt.Errorf("failed to set no delay: %w", err)
+			}
+		}
+	}
+
+	if c.tlsConfig != nil {
+		cfg := c.tlsConfig
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName = serverName(addr)
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close() // nolint: errcheck
+			return nil, fmt.Errorf("failed to handshake: %w", err)
+		}
+		conn = tc
+	}
+
+	return conn, nil
+}
+
+// stopBackground stops the clients background goroutines and waits for them to exit.
+func (c *Client) stopBackground() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.wg.Wait()
+	})
+}
+
+// serverName returns the host of addr for use as the TLS server name.
+func serverName(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// cmdTimeoutKey is the context key for a per command timeout.
+type cmdTimeoutKey struct{}
+
+// CommandTimeout returns a copy of ctx which overrides the clients timeout
+// for commands executed with it, for example to allow a fetch over a large
+// range more time than a ping.
+func CommandTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, cmdTimeoutKey{}, timeout)
+}
+
+// ExecOption configures the execution of a single command.
+type ExecOption func(ctx context.Context) context.Context
+
+// WithTimeout returns an ExecOption which overrides the clients timeout.
+func WithTimeout(timeout time.Duration) ExecOption {
+	return func(ctx context.Context) context.Context {
+		return CommandTimeout(ctx, timeout)
+	}
+}
+
+// Status is the status line of a response.
+type Status struct {
+	// Count is the number of lines which followed the status line, or
+	// the negative error code.
+	Count int
+	Msg   string
+}
+
+// statusKey is the context key for the Status of a command.
+type statusKey struct{}
+
+// WithStatus returns an ExecOption which stores the status line of the
+// response in st, for callers such as proxies which need the message of
+// responses which also have lines.
+func WithStatus(st *Status) ExecOption {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, statusKey{}, st)
+	}
+}
+
+// setDeadline updates the read and write deadlines on the connection, see
+// setReadDeadline and setWriteDeadline.
+func (c *Client) setDeadline(ctx context.Context) error {
+	if err := c.setWriteDeadline(ctx); err != nil {
+		return err
+	}
+	return c.setReadDeadline(ctx)
+}
+
+// setReadDeadline updates the read deadline on the connection based on the
+// clients read timeout, see deadline.
+func (c *Client) setReadDeadline(ctx context.Context) error {
+	d, err := c.deadline(ctx, c.readTimeout)
+	if err != nil {
+		return err
+	}
+	return c.conn.SetReadDeadline(d)
+}
+
+// setWriteDeadline updates the write deadline on the connection based on
+// the clients write timeout, see deadline.
+func (c *Client) setWriteDeadline(ctx context.Context) error {
+	d, err := c.deadline(ctx, c.writeTimeout)
+	if err != nil {
+		return err
+	}
+	return c.conn.SetWriteDeadline(d)
+}
+
+// deadline returns the deadline for an operation with timeout, or the
+// clients timeout if not set, overridden by the timeout set by
+// CommandTimeout and limited by the deadline of ctx if earlier.
+// It returns the error of ctx if it's already done.
+func (c *Client) deadline(ctx context.Context, timeout time.Duration) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+	timeout = c.timeoutOr(timeout)
+	if t, ok := ctx.Value(cmdTimeoutKey{}).(time.Duration); ok {
+		timeout = t
+	}
+	c.used = time.Now()
+	deadline := c.used.Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return deadline, nil
+}
+
+// watchContext interrupts any blocked read or write on the current connection
+// when ctx is done. The returned stop function must be called once the
+// connection is no longer in use by the caller.
+func (c *Client) watchContext(ctx context.Context) (stop func() bool) {
+	conn := c.conn
+	c.setActive(conn)
+	if ctx.Done() == nil {
+		// ctx can't be cancelled.
+		return func() bool {
+			c.setActive(nil)
+			return true
+		}
+	}
+	stopCtx := context.AfterFunc(ctx, func() {
+		interrupt(conn)
+	})
+	return func() bool {
+		c.setActive(nil)
+		return stopCtx()
+	}
+}
+
+// interrupt unblocks any pending read or write on conn.
+func interrupt(conn net.Conn) {
+	// Setting a deadline in the past unblocks pending operations.
+	conn.SetDeadline(time.Unix(1, 0)) // nolint: errcheck
+}
+
+// setActive records conn as the connection in use by the current command.
+func (c *Client) setActive(conn net.Conn) {
+	c.activeMu.Lock()
+	defer c.activeMu.Unlock()
+	c.active = conn
+}
+
+// interruptActive interrupts the current command, if any.
+func (c *Client) interruptActive() {
+	c.activeMu.Lock()
+	defer c.activeMu.Unlock()
+	if c.active != nil {
+		interrupt(c.active)
+	}
+}
+
+// ctxErr returns the error of ctx if it's done, otherwise err.
+// If ctx is done or err is a timeout the connection is closed, as the
+// response may have only been partially read, so the next command reconnects.
+func (c *Client) ctxErr(ctx context.Context, err error) error {
+	ctxErr := ctx.Err()
+	if ctxErr == nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+
+	c.dropConn() // nolint: errcheck
+
+	if ctxErr != nil {
+		return ctxErr
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		// The connection deadline fired before the context noticed.
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// writeAll writes all of b to w, looping on short writes until all bytes
+// have been written or w returns an error such as a deadline being exceeded.
+func writeAll(w io.Writer, b []byte) error {
+	total := len(b)
+	for len(b) > 0 {
+		n, err := w.Write(b)
+		b = b[n:]
+		switch {
+		case err != nil && len(b) > 0 && len(b) < total:
+			return fmt.Errorf("%w: wrote %d of %d bytes: %w", io.ErrShortWrite, total-len(b), total, err)
+		case err != nil:
+			return err
+		case n == 0 && len(b) > 0:
+			// Writer made no progress without reporting an error.
+			return fmt.Errorf("%w: wrote %d of %d bytes", io.ErrShortWrite, total-len(b), total)
+		}
+	}
+	return nil
 }
 
 // Exec executes cmd on the server and returns the response.
-func (c *Client) Exec(cmd string) ([]string, error) {
-	return c.ExecCmd(NewCmd(cmd))
+func (c *Client) Exec(cmd string, opts ...ExecOption) ([]string, error) {
+	return c.ExecWithContext(context.Background(), cmd, opts...)
+}
+
+// ExecWithContext executes cmd on the server and returns the response.
+func (c *Client) ExecWithContext(ctx context.Context, cmd string, opts ...ExecOption) ([]string, error) {
+	return c.ExecCmdWithContext(ctx, NewCmd(cmd), opts...)
 }
 
-func (c *Client) reconnect() error {
-	c.Close()
-	fmt.Fprintf(os.Stderr, "reconnecting to %s\n", c.addr)
-	err := ErrReconnectionFailed
-	for retries := 0; err != nil; retries++ {
-		fmt.Fprintf(os.Stderr, "retrying to connect to %s\n", c.addr)
-		err = c.initConnection()
+// reconnect replaces the connection to the server, making up to the retry
+// policies maximum attempts with backoff between them.
+func (c *Client) reconnect(ctx context.Context) error {
+	c.dropConn() // nolint: errcheck
+	if c.noReconnect {
+		return ErrNotConnected
+	}
+
+	c.logger.InfoContext(ctx, "reconnecting", "addr", c.addr)
+	for attempt := 1; ; attempt++ {
+		err := c.initConnection(ctx)
+		c.stats.reconnected(err)
+		if c.observer != nil {
+			c.observer.Reconnected(ctx, err)
+		}
 		if err == nil {
-			fmt.Fprintf(os.Stderr, "successfully reconnected to %s\n", c.addr)
-			break
+			c.logger.InfoContext(ctx, "reconnected", "addr", c.addr)
+			return nil
+		}
+		c.logger.WarnContext(ctx, "reconnect failed", "addr", c.addr, "attempt", attempt, "error", err)
+		if attempt >= c.retry.attempts() {
+			c.logger.ErrorContext(ctx, "reconnect giving up", "addr", c.addr)
+			return fmt.Errorf("%w: %v", ErrReconnectionFailed, err)
 		}
-		fmt.Fprintf(os.Stderr, "failed to reconnect to %s: %v\n", c.addr, err)
-		if retries > 9 {
-			fmt.Fprintf(os.Stderr, "failed to reconnect to %s. giving up.\n", c.addr)
-			return ErrReconnectionFailed
+
+		t := time.NewTimer(c.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
 		}
-		time.Sleep(time.Second * 2)
 	}
-	return nil
 }
 
 // ExecCmd executes cmd on the server and returns the response.
-func (c *Client) ExecCmd(cmd *Cmd) ([]string, error) {
+// Errors are returned as a *CommandError which identifies cmd.
+func (c *Client) ExecCmd(cmd *Cmd, opts ...ExecOption) ([]string, error) {
+	return c.ExecCmdWithContext(context.Background(), cmd, opts...)
+}
+
+// ExecCmdWithContext executes cmd on the server and returns the response.
+// The deadline of ctx limits the clients timeout and if ctx is cancelled
+// while waiting for the server the connection is closed and ctx.Err() is
+// returned, with the next command reconnecting.
+// Errors are returned as a *CommandError which identifies cmd.
+func (c *Client) ExecCmdWithContext(ctx context.Context, cmd *Cmd, opts ...ExecOption) ([]string, error) {
+	for _, o := range opts {
+		ctx = o(ctx)
+	}
+	lines, err := c.execCmd(ctx, cmd)
+	if err != nil {
+		return nil, &CommandError{Cmd: c.cmdString(cmd), Err: err}
+	}
+	return lines, nil
+}
+
+// LineFunc is called for each line of a streamed response.
+// Returning an error stops the stream, discarding the rest of the response.
+type LineFunc func(line string) error
+
+// ExecCmdStream executes cmd on the server calling f for each line of the
+// response as it's read, instead of buffering the entire response.
+// Errors are returned as a *CommandError which identifies cmd.
+func (c *Client) ExecCmdStream(ctx context.Context, cmd *Cmd, f LineFunc, opts ...ExecOption) error {
+	for _, o := range opts {
+		ctx = o(ctx)
+	}
+	if err := c.execStream(ctx, cmd, f); err != nil {
+		return &CommandError{Cmd: c.cmdString(cmd), Err: err}
+	}
+	return nil
+}
+
+// cmdString returns the string representation of cmd for use in errors and
+// logs, which only includes the command verb if the client redacts arguments.
+func (c *Client) cmdString(cmd *Cmd) string {
+	if c.redact {
+		return cmd.verb()
+	}
+	return strings.TrimSpace(cmd.String())
+}
+
+// linesPool pools the response line slices of commands whose lines aren't
+// retained once processed, such as update.
+var linesPool = sync.Pool{
+	New: func() interface{} {
+		l := make([]string, 0, 8)
+		return &l
+	},
+}
+
+// execLines executes cmd on the server calling f with the response lines,
+// which must not be retained once f returns.
+// Errors executing cmd are returned as a *CommandError which identifies cmd,
+// those returned by f as is.
+func (c *Client) execLines(ctx context.Context, cmd *Cmd, f func(lines []string) error) error {
+	p := linesPool.Get().(*[]string)
+	defer func() {
+		clear(*p)
+		*p = (*p)[:0]
+		linesPool.Put(p)
+	}()
+
+	if err := c.execStream(ctx, cmd, func(l string) error {
+		*p = append(*p, l)
+		return nil
+	}); err != nil {
+		return &CommandError{Cmd: c.cmdString(cmd), Err: err}
+	}
+	return f(*p)
+}
+
+// execCmd executes cmd on the server and returns the response.
+func (c *Client) execCmd(ctx context.Context, cmd *Cmd) ([]string, error) {
+	var lines []string
+	if err := c.execStream(ctx, cmd, func(l string) error {
+		lines = append(lines, l)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// execStream executes cmd on the server calling f for each response line.
+func (c *Client) execStream(ctx context.Context, cmd *Cmd, f LineFunc) (err error) {
+	if c.lanes != nil {
+		lc, done := c.pick()
+		defer done()
+		return lc.execStream(ctx, cmd, f)
+	}
+
+	ctx, done := c.instrument(ctx, cmd)
+	defer func() { done(err) }()
+
+	if c.readOnly && cmd.mutating() {
+		return ErrReadOnly
+	}
+
+	if cmd, err = c.resolveCmd(cmd); err != nil {
+		return err
+	}
+
+	if err := cmd.validate(); err != nil {
+		return err
+	}
+
+	if err := c.limit(ctx, 1); err != nil {
+		return err
+	}
+
+	report, err := c.admit(ctx)
+	if err != nil {
+		return err
+	}
+	// Only the result of the command counts, not that of any fallback.
+	var result error
+	defer func() { report(result) }()
+
+	ctx, cancel := c.commandContext(ctx)
+	defer cancel()
+
+	var ferr error
 	c.m.Lock()
-	defer c.m.Unlock()
+	err = c.supported(cmd)
+	if err == nil {
+		err = c.execLockedStream(ctx, cmd, func(l string) error {
+			ferr = f(l)
+			return ferr
+		})
+	}
+	c.m.Unlock()
 
-	if c.conn == nil {
-		errR := c.reconnect()
-		if errR != nil {
-			return nil, fmt.Errorf("failed to connect: %w", errR)
-		}
+	result = err
+	if ferr != nil {
+		// The server responded.
+		result = nil
 	}
-	if err := c.setDeadline(); err != nil {
+
+	if c.canFallback(cmd, err) {
+		return c.fallback(ctx, cmd, f)
+	}
+	return err
+}
+
+// execLocked executes cmd on the server and returns the response.
+// The caller must hold the clients lock.
+func (c *Client) execLocked(ctx context.Context, cmd *Cmd) ([]string, error) {
+	var lines []string
+	if err := c.execLockedStream(ctx, cmd, func(l string) error {
+		lines = append(lines, l)
+		return nil
+	}); err != nil {
 		return nil, err
 	}
+	return lines, nil
+}
 
-	for {
-		if _, err := c.conn.Write([]byte(cmd.String())); err != nil {
-			if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
-				fmt.Printf("write to connection caused [%v]; trying to reestablish connection...\n", err)
-				err2 := c.reconnect()
-				if err2 != nil {
-					fmt.Printf("failed to reestablish connection: %v\n", err2)
-					return nil, fmt.Errorf("failed to write (%s) and failed to reestablish: %w", err.Error(), err2)
-				}
-				fmt.Printf("connection reestablished.\n")
-				continue
-			}
-			return nil, fmt.Errorf("failed to write: %w", err)
+// execLockedStream executes cmd on the server calling f for each response line.
+// The caller must hold the clients lock.
+func (c *Client) execLockedStream(ctx context.Context, cmd *Cmd, f LineFunc) error {
+	if err := c.ensureConn(ctx); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	if err := c.roundTrip(ctx, cmd, f); err != nil {
+		return c.ctxErr(ctx, err)
+	}
+	return nil
+}
+
+// roundTrip writes cmd to the connection and reads the response, calling f
+// for each line.
+// If the response can't be read completely, including if f returns an error,
+// the connection is closed so the next command reconnects.
+func (c *Client) roundTrip(ctx context.Context, cmd *Cmd, f LineFunc) error {
+	stop := c.watchContext(ctx)
+	defer func() { stop() }()
+
+	for attempt := 1; ; attempt++ {
+		err := c.setWriteDeadline(ctx)
+		if err == nil {
+			err = c.writeCmd(cmd)
+		}
+		if err == nil {
+			break
+		}
+
+		if ctx.Err() != nil || c.noReconnect || attempt >= c.retry.attempts() || !c.retry.retryable(err) {
+			return fmt.Errorf("failed to write: %w", err)
 		}
-		break
+
+		c.logger.WarnContext(ctx, "write failed, reestablishing connection", "addr", c.addr, "error", err)
+		stop()
+		if err2 := c.reconnect(ctx); err2 != nil {
+			return fmt.Errorf("failed to write (%s) and failed to reestablish: %w", err.Error(), err2)
+		}
+		stop = c.watchContext(ctx)
+		c.stats.retries.Add(1)
+		if st := statsFrom(ctx); st != nil {
+			st.retries++
+		}
+	}
+	if c.logger.Enabled(ctx, slog.LevelDebug) {
+		c.logger.DebugContext(ctx, "rrdcached command", "cmd", c.cmdString(cmd))
 	}
-	fmt.Printf("rrdcached command: [%s]\n", strings.TrimSpace(cmd.String()))
 
-	if err := c.setDeadline(); err != nil {
-		return nil, err
+	return c.readResponse(ctx, f)
+}
+
+// writeCmd writes cmd to the connection with a single write.
+func (c *Client) writeCmd(cmd *Cmd) error {
+	// Formatting into the writers buffer avoids a copy unless cmd doesn't
+	// fit, in which case Write passes it straight through.
+	return c.write(cmd.appendTo(c.writer.AvailableBuffer()))
+}
+
+// write writes b, typically appended to the writers available buffer, to
+// the connection with a single write.
+func (c *Client) write(b []byte) error {
+	_, err := c.writer.Write(b)
+	if err == nil {
+		err = c.writer.Flush()
+	}
+	if err != nil {
+		// Errors are sticky, so discard the unwritten data for a retry.
+		c.writer.Reset(c.conn)
 	}
+	return err
+}
 
-	if !c.scanner.Scan() {
-		return nil, fmt.Errorf("scan error: %w", c.scanErr())
+// readResponse reads a response from the connection, calling f for each line.
+// If the response can't be read completely, including if f returns an error,
+// the connection is closed so the next command reconnects.
+func (c *Client) readResponse(ctx context.Context, f LineFunc) error {
+	if err := c.setReadDeadline(ctx); err != nil {
+		return err
 	}
 
-	l := c.scanner.Text()
-	matches := respRe.FindStringSubmatch(l)
-	if len(matches) != 3 {
-		return nil, fmt.Errorf("not 3 matches: '%s'", l)
+	l, err := c.readLine()
+	if err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			c.discard()
+		}
+		return fmt.Errorf("read error: %w", err)
 	}
 
-	cnt, err := strconv.Atoi(matches[1])
+	cnt, msg, err := c.parser(l)
 	if err != nil {
-		// This should be impossible given the regexp matched.
-		return nil, fmt.Errorf("failed to convert to int '%s': %w", matches[1], err)
+		c.discard()
+		return err
+	}
+	if st := statsFrom(ctx); st != nil {
+		st.code = cnt
+	}
+	if st, ok := ctx.Value(statusKey{}).(*Status); ok {
+		*st = Status{Count: cnt, Msg: msg}
 	}
 
 	switch {
 	case cnt < 0:
 		// rrdcached reported an error.
-		return nil, NewError(cnt, matches[2])
+		return NewError(cnt, msg)
 	case cnt == 0:
 		// message is the line e.g. first.
-		return []string{matches[2]}, nil
+		return f(msg)
 	}
 
-	if err := c.setDeadline(); err != nil {
-		return nil, err
-	}
-	lines := make([]string, 0, cnt)
-	for len(lines) < cnt && c.scanner.Scan() {
-		lines = append(lines, c.scanner.Text())
-		if err := c.setDeadline(); err != nil {
-			return nil, err
+	for i := 0; i < cnt; i++ {
+		if err := c.setReadDeadline(ctx); err != nil {
+			return err
+		}
+
+		l, err := c.readLine()
+		if err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				c.discard()
+			}
+			return err
 		}
-	}
 
-	if len(lines) != cnt {
-		// Short response.
-		return nil, c.scanErr()
+		if err := f(l); err != nil {
+			if i < cnt-1 {
+				c.discard()
+			}
+			return err
+		}
 	}
 
-	return lines, nil
+	return nil
 }
 
-// Close closes the connection to the server.
+// discard closes the connection, which has unread response data, so that
+// the next command reconnects.
+func (c *Client) discard() {
+	c.dropConn() // nolint: errcheck
+}
+
+// Close gracefully closes the connection to the server, waiting for any
+// command in progress to complete before sending quit, and stops any
+// background activity such as Keepalive.
+// A command issued after Close reconnects.
 func (c *Client) Close() error {
+	return c.CloseWithContext(context.Background())
+}
+
+// CloseWithContext gracefully closes the connection to the server like
+// Close, but if ctx is done before the command in progress completes it's
+// interrupted and the connection closed without waiting further.
+// AsyncWriters using the client should be closed first, so their pending
+// samples are sent.
+func (c *Client) CloseWithContext(ctx context.Context) error {
+	if c.lanes != nil {
+		return c.closeLanes(func(lc *Client) error { return lc.CloseWithContext(ctx) })
+	}
+
+	c.stopBackground()
+	c.lockContext(ctx)
+	defer c.m.Unlock()
+
 	if c.conn == nil {
 		return nil
 	}
-	errD := c.setDeadline()
-	_, errW := c.conn.Write([]byte("quit"))
-	err := c.conn.Close()
+
+	errD := c.setWriteDeadline(ctx)
+	var errW error
+	if errD == nil {
+		errW = writeAll(c.conn, []byte(NewCmd("quit").String()))
+	}
+	err := c.dropConn()
 	if err != nil {
 		return err
 	} else if errD != nil {
@@ -222,11 +1189,98 @@ func (c *Client) Close() error {
 	return errW
 }
 
-// scanError returns the error from the scanner if non-nil,
-// io.ErrUnexpectedEOF otherwise.
-func (c *Client) scanErr() error {
-	if err := c.scanner.Err(); err != nil {
-		return err
+// CloseNoQuit closes the connection to the server without sending quit,
+// interrupting any command in progress, and stops any background activity
+// such as Keepalive.
+func (c *Client) CloseNoQuit() error {
+	if c.lanes != nil {
+		return c.closeLanes((*Client).CloseNoQuit)
+	}
+
+	c.stopBackground()
+	c.interruptActive()
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.dropConn()
+}
+
+// lockContext acquires the clients lock, interrupting the command in
+// progress if ctx is done first.
+func (c *Client) lockContext(ctx context.Context) {
+	locked := make(chan struct{})
+	go func() {
+		c.m.Lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		c.interruptActive()
+		<-locked
 	}
-	return io.ErrUnexpectedEOF
+}
+
+// dropConn closes the connection to the server, if any, without sending
+// quit so the next command reconnects.
+func (c *Client) dropConn() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// readLine reads a response line, without the line terminator.
+// It returns io.ErrUnexpectedEOF if the connection is closed before a
+// complete line is read and bufio.ErrTooLong if the line is longer than the
+// clients max line size.
+func (c *Client) readLine() (string, error) {
+	frag, err := c.reader.ReadSlice('\n')
+	if err == nil && len(frag)-1 <= c.maxLineSize {
+		// The common case of a line which fits in the buffer.
+		return string(bytes.TrimRight(frag, "\r\n")), nil
+	}
+
+	var line []byte
+	for {
+		line = append(line, frag...)
+		if len(bytes.TrimRight(line, "\r\n")) > c.maxLineSize {
+			return "", bufio.ErrTooLong
+		}
+
+		switch {
+		case err == nil:
+			return string(bytes.TrimRight(line, "\r\n")), nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			frag, err = c.reader.ReadSlice('\n')
+		case errors.Is(err, io.EOF):
+			return "", io.ErrUnexpectedEOF
+		default:
+			return "", err
+		}
+	}
+}
+
+// readBinary reads a binary response block of n bytes, which is followed
+// by a line terminator.
+func (c *Client) readBinary(ctx context.Context, n int) ([]byte, error) {
+	if err := c.setReadDeadline(ctx); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, n+1)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	if data[n] != '\n' {
+		return nil, NewInvalidResponseError("binary data not terminated", "")
+	}
+
+	return data[:n], nil
 }
@@ -0,0 +1,201 @@
+package rrd
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientStats represents runtime statistics of a client, as opposed to
+// Stats which are reported by the server.
+type ClientStats struct {
+	// RateLimited is true if the client was created with RateLimit.
+	RateLimited bool
+
+	// Throttled is the number of commands delayed by the rate limit.
+	Throttled int64
+
+	// ThrottleWait is the total time commands were delayed by the rate limit.
+	ThrottleWait time.Duration
+
+	// Available is the number of commands which can currently be sent
+	// without being delayed by the rate limit.
+	Available float64
+
+	// Commands are the statistics of the commands executed, by lower case
+	// command verb. Batches and pipelines are counted as the commands
+	// "batch" and "pipeline".
+	Commands map[string]CommandStats
+
+	// Latency summarises the time taken by all commands.
+	Latency LatencyStats
+
+	// BytesRead and BytesWritten are the number of bytes read from and
+	// written to the server.
+	BytesRead    int64
+	BytesWritten int64
+
+	// Reconnects is the number of times the connection was reestablished
+	// and ReconnectFailures the number of failed attempts to do so.
+	Reconnects        int64
+	ReconnectFailures int64
+
+	// Retries is the number of commands written again after reconnecting.
+	Retries int64
+}
+
+// CommandStats represents the statistics of a command.
+type CommandStats struct {
+	// Count is the number of times the command was executed.
+	Count int64
+
+	// Errors is the number of times the command failed.
+	Errors int64
+
+	// Latency summarises the time taken by the command.
+	Latency LatencyStats
+}
+
+// LatencyStats summarises the time taken by commands.
+type LatencyStats struct {
+	Count int64
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// Mean returns the mean latency, or zero if there were no commands.
+func (l LatencyStats) Mean() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Count)
+}
+
+// add adds a command which took d to l.
+func (l *LatencyStats) add(d time.Duration) {
+	if l.Count == 0 || d < l.Min {
+		l.Min = d
+	}
+	if d > l.Max {
+		l.Max = d
+	}
+	l.Count++
+	l.Total += d
+}
+
+// merge adds the commands summarised by o to l.
+func (l *LatencyStats) merge(o LatencyStats) {
+	if o.Count == 0 {
+		return
+	}
+	if l.Count == 0 || o.Min < l.Min {
+		l.Min = o.Min
+	}
+	if o.Max > l.Max {
+		l.Max = o.Max
+	}
+	l.Count += o.Count
+	l.Total += o.Total
+}
+
+// clientStats are the counters of a client reported by ClientStats.
+type clientStats struct {
+	bytesRead         atomic.Int64
+	bytesWritten      atomic.Int64
+	reconnects        atomic.Int64
+	reconnectFailures atomic.Int64
+	retries           atomic.Int64
+
+	m        sync.Mutex
+	commands map[string]*CommandStats
+	latency  LatencyStats
+}
+
+// command records the execution of cmd which took d and failed if err
+// isn't nil.
+func (s *clientStats) command(cmd string, d time.Duration, err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	cs, ok := s.commands[cmd]
+	if !ok {
+		if s.commands == nil {
+			s.commands = make(map[string]*CommandStats)
+		}
+		cs = &CommandStats{}
+		s.commands[cmd] = cs
+	}
+	cs.Count++
+	if err != nil {
+		cs.Errors++
+	}
+	cs.Latency.add(d)
+	s.latency.add(d)
+}
+
+// reconnected records a reconnection attempt which failed if err isn't nil.
+func (s *clientStats) reconnected(err error) {
+	if err != nil {
+		s.reconnectFailures.Add(1)
+		return
+	}
+	s.reconnects.Add(1)
+}
+
+// statsConn is a connection which counts the bytes transferred.
+type statsConn struct {
+	net.Conn
+	stats *clientStats
+}
+
+func (c *statsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.stats.bytesRead.Add(int64(n))
+	return n, err
+}
+
+func (c *statsConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.stats.bytesWritten.Add(int64(n))
+	return n, err
+}
+
+// ClientStats returns the runtime statistics of the client, summed over
+// its connections if it has multiple Connections.
+func (c *Client) ClientStats() ClientStats {
+	s := ClientStats{Commands: make(map[string]CommandStats)}
+	if c.limiter != nil {
+		s.RateLimited = true
+		s.Throttled, s.ThrottleWait, s.Available = c.limiter.stats()
+	}
+
+	if c.lanes == nil {
+		s.add(&c.stats)
+	}
+	for _, l := range c.lanes {
+		s.add(&l.c.stats)
+	}
+	return s
+}
+
+// add adds the counters of cs to s.
+func (s *ClientStats) add(cs *clientStats) {
+	s.BytesRead += cs.bytesRead.Load()
+	s.BytesWritten += cs.bytesWritten.Load()
+	s.Reconnects += cs.reconnects.Load()
+	s.ReconnectFailures += cs.reconnectFailures.Load()
+	s.Retries += cs.retries.Load()
+
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	s.Latency.merge(cs.latency)
+	for cmd, c := range cs.commands {
+		t := s.Commands[cmd]
+		t.Count += c.Count
+		t.Errors += c.Errors
+		t.Latency.merge(c.Latency)
+		s.Commands[cmd] = t
+	}
+}
@@ -0,0 +1,74 @@
+package rrd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientStats(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"flush missing.rrd": {"-1 No such file: missing.rrd"},
+		".":                 {"0 errors"},
+		"PING":              {"0 PONG"},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Retry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.Equal(t, ClientStats{Commands: map[string]CommandStats{}}, c.ClientStats())
+
+	assert.NoError(t, c.Ping())
+	assert.NoError(t, c.Ping())
+	assert.Error(t, c.Flush("missing.rrd"))
+	_, err = c.ExecCmd(NewCmd("PING"))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Batch(NewCmd("update").WithArgs("test.rrd", "N:1")))
+
+	// Write on the closed connection is retried on a new one.
+	assert.NoError(t, c.conn.Close())
+	assert.NoError(t, c.Ping())
+
+	stats := c.ClientStats()
+	if assert.Len(t, stats.Commands, 3) {
+		ping := stats.Commands["ping"]
+		assert.Equal(t, int64(4), ping.Count)
+		assert.Equal(t, int64(0), ping.Errors)
+		assert.Equal(t, int64(4), ping.Latency.Count)
+		assert.Equal(t, CommandStats{Count: 1, Errors: 1, Latency: stats.Commands["flush"].Latency}, stats.Commands["flush"])
+		assert.Equal(t, int64(1), stats.Commands["batch"].Count)
+	}
+	assert.Equal(t, int64(6), stats.Latency.Count)
+	assert.LessOrEqual(t, stats.Latency.Min, stats.Latency.Mean())
+	assert.LessOrEqual(t, stats.Latency.Mean(), stats.Latency.Max)
+	assert.Greater(t, stats.BytesRead, int64(0))
+	assert.Greater(t, stats.BytesWritten, int64(0))
+	assert.Equal(t, int64(1), stats.Reconnects)
+	assert.Equal(t, int64(0), stats.ReconnectFailures)
+	assert.Equal(t, int64(1), stats.Retries)
+}
+
+func TestLatencyStats(t *testing.T) {
+	var l LatencyStats
+	assert.Equal(t, time.Duration(0), l.Mean())
+
+	for _, d := range []time.Duration{3, 1, 2} {
+		l.add(d * time.Millisecond)
+	}
+	assert.Equal(t, LatencyStats{Count: 3, Total: 6 * time.Millisecond, Min: time.Millisecond, Max: 3 * time.Millisecond}, l)
+	assert.Equal(t, 2*time.Millisecond, l.Mean())
+}
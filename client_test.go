@@ -1,8 +1,24 @@
 package rrd
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
 	"net"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -79,6 +95,79 @@ func TestClientDisconnect(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestClientClose(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.Close())
+
+	c2, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, c2.CloseNoQuit())
+
+	// Commands after close reconnect.
+	assert.NoError(t, c2.Ping())
+	assert.NoError(t, c2.Close())
+
+	assert.Eventually(t, func() bool {
+		return s.count(cmdQuit) == 2
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestClientCloseContext(t *testing.T) {
+	// A server which never responds.
+	l, err := newLocalListener()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close() // nolint: errcheck
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()        // nolint: errcheck
+		io.Copy(io.Discard, conn) // nolint: errcheck
+	}()
+
+	c, err := NewClient(l.Addr().String(), Timeout(time.Second*5), NoReconnect)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Ping()
+	}()
+
+	// Wait for the ping to be in progress.
+	assert.Eventually(t, func() bool {
+		c.activeMu.Lock()
+		defer c.activeMu.Unlock()
+		return c.active != nil
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	start := time.Now()
+	assert.NoError(t, c.CloseWithContext(ctx))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Error(t, <-errc)
+}
+
 func TestClientWriteFail(t *testing.T) {
 	s := newServer(t)
 	if s == nil {
@@ -92,7 +181,7 @@ func TestClientWriteFail(t *testing.T) {
 	if !assert.NoError(t, err) {
 		return
 	}
-	assert.NoError(t, c.conn.(*net.TCPConn).CloseWrite())
+	assert.NoError(t, c.conn.(*statsConn).Conn.(*net.TCPConn).CloseWrite())
 
 	_, err = c.Exec("version")
 	assert.Error(t, err)
@@ -127,6 +216,136 @@ func TestClientFailConn(t *testing.T) {
 	assert.NoError(t, c.Close())
 }
 
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network string
+		expect  string
+		err     bool
+	}{
+		{addr: "localhost:1234", network: "tcp", expect: "localhost:1234"},
+		{addr: "localhost", network: "tcp", expect: "localhost"},
+		{addr: "tcp://localhost:1234", network: "tcp", expect: "localhost:1234"},
+		{addr: "tcp://localhost/", network: "tcp", expect: "localhost"},
+		{addr: "tcp://[::1]:1234", network: "tcp", expect: "[::1]:1234"},
+		{addr: "unix:///var/run/rrdcached.sock", network: "unix", expect: "/var/run/rrdcached.sock"},
+		{addr: "unix:/var/run/rrdcached.sock", network: "unix", expect: "/var/run/rrdcached.sock"},
+		{addr: "unix:rrdcached.sock", network: "unix", expect: "rrdcached.sock"},
+		{addr: "unix:@rrdcached", network: "unix", expect: "@rrdcached"},
+		{addr: "@rrdcached", network: "unix", expect: "@rrdcached"},
+		{addr: "unix://", err: true},
+		{addr: "tcp://", err: true},
+		{addr: "udp://localhost:1234", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			network, addr, err := parseAddr("tcp", tc.addr)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrInvalidArg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.network, network)
+			assert.Equal(t, tc.expect, addr)
+		})
+	}
+
+	_, err := NewClient("udp://localhost")
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewClient("localhost", Failover("unix:///var/run/rrdcached.sock"))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
+
+func TestNormalizeAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		port   int
+		expect string
+	}{
+		{addr: "localhost", expect: "localhost:42217"},
+		{addr: "localhost:1234", expect: "localhost:1234"},
+		{addr: "localhost:", expect: "localhost:42217"},
+		{addr: "127.0.0.1", expect: "127.0.0.1:42217"},
+		{addr: "127.0.0.1:1234", expect: "127.0.0.1:1234"},
+		{addr: "::1", expect: "[::1]:42217"},
+		{addr: "[::1]", expect: "[::1]:42217"},
+		{addr: "[::1]:1234", expect: "[::1]:1234"},
+		{addr: "2001:db8::1", expect: "[2001:db8::1]:42217"},
+		{addr: "fe80::1%eth0", expect: "[fe80::1%eth0]:42217"},
+		{addr: "[fe80::1%eth0]", expect: "[fe80::1%eth0]:42217"},
+		{addr: "[fe80::1%eth0]:1234", expect: "[fe80::1%eth0]:1234"},
+		{addr: "localhost", port: 1234, expect: "localhost:1234"},
+		{addr: "::1", port: 1234, expect: "[::1]:1234"},
+		{addr: "localhost:5678", port: 1234, expect: "localhost:5678"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			c := &Client{network: "tcp", port: DefaultPort}
+			if tc.port != 0 {
+				assert.NoError(t, Port(tc.port)(c))
+			}
+			assert.Equal(t, tc.expect, c.normalizeAddr(tc.addr))
+		})
+	}
+
+	c := &Client{network: "unix", port: DefaultPort}
+	assert.Equal(t, "/var/run/rrdcached.sock", c.normalizeAddr("/var/run/rrdcached.sock"))
+}
+
+func TestClientPort(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	host, port, err := net.SplitHostPort(s.Addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	p, err := strconv.Atoi(port)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c, err := NewClient(host, Port(p), Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	assert.Equal(t, s.Addr, c.Addr())
+	assert.NoError(t, c.Ping())
+
+	for _, p := range []int{0, -1, 65536} {
+		_, err := NewClient(host, Port(p))
+		assert.ErrorIs(t, err, ErrInvalidArg)
+	}
+}
+
+func TestClientURL(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient("tcp://"+s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	assert.Equal(t, s.Addr, c.Addr())
+	assert.NoError(t, c.Ping())
+}
+
 func TestClientUnix(t *testing.T) {
 	c, err := NewClient("/myscock.sock", Unix)
 	if assert.Error(t, err) {
@@ -136,3 +355,873 @@ func TestClientUnix(t *testing.T) {
 	// Should never get here
 	assert.NoError(t, c.Close())
 }
+
+func TestClientReadOnly(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), ReadOnly)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+
+	_, err = c.Last("test.rrd")
+	assert.NoError(t, err)
+
+	_, err = c.Info("test.rrd")
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, c.UpdateRaw("test.rrd", "1499968801:U"), ErrReadOnly)
+	assert.ErrorIs(t, c.Forget("test.rrd"), ErrReadOnly)
+	assert.ErrorIs(t, c.Flush("test.rrd"), ErrReadOnly)
+	assert.ErrorIs(t, c.FlushAll(), ErrReadOnly)
+	assert.ErrorIs(t, c.Batch(NewCmd("ping")), ErrReadOnly)
+
+	_, err = c.Exec("UPDATE test.rrd 1499968801:U")
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	err = c.Create(
+		"test.rrd",
+		[]DS{NewDS("DS:watts:GAUGE:300:0:24000")},
+		[]RRA{NewRRA("RRA:AVERAGE:0.5:1:864000")},
+	)
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	// Connection must still be usable after rejected commands.
+	assert.NoError(t, c.Ping())
+}
+
+// throttledWriter is an io.Writer which writes at most max bytes per call,
+// returning err once limit bytes have been written.
+type throttledWriter struct {
+	bytes.Buffer
+	max   int
+	limit int
+	err   error
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	if w.Len() >= w.limit {
+		return 0, w.err
+	}
+	if len(b) > w.max {
+		b = b[:w.max]
+	}
+	if rem := w.limit - w.Len(); len(b) > rem {
+		b = b[:rem]
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestWriteAll(t *testing.T) {
+	data := []byte("update test.rrd 1499968801:1:2:3 1499968802:4:5:6\n")
+
+	w := &throttledWriter{max: 3, limit: len(data)}
+	assert.NoError(t, writeAll(w, data))
+	assert.Equal(t, data, w.Bytes())
+
+	w = &throttledWriter{max: 3, limit: 10, err: os.ErrDeadlineExceeded}
+	err := writeAll(w, data)
+	assert.True(t, errors.Is(err, io.ErrShortWrite))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	assert.Equal(t, data[:10], w.Bytes())
+
+	w = &throttledWriter{max: 3, limit: 0, err: os.ErrDeadlineExceeded}
+	err = writeAll(w, data)
+	assert.False(t, errors.Is(err, io.ErrShortWrite))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+
+	w = &throttledWriter{max: 0, limit: len(data)}
+	assert.True(t, errors.Is(writeAll(w, data), io.ErrShortWrite))
+}
+
+func TestClientCommandError(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	notFound := []string{"-1 No such file or directory."}
+	s.responses = map[string][]string{
+		"fetch":           notFound,
+		"fetchbin":        notFound,
+		"info secret.rrd": notFound,
+		"info known.rrd": {
+			"2 Info for known.rrd follows",
+			"ds[watts].index 1 0",
+			"ds[watts].type 2 GAUGE",
+		},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	named := NamedSample{Time: time.Unix(1499968800, 0), Values: map[string]float64{"volts": 1}}
+	calls := []struct {
+		name     string
+		call     func(c *Client) error
+		full     string
+		redacted string
+		err      error
+	}{
+		{"pending", func(c *Client) error { _, err := c.Pending("secret.rrd"); return err }, "pending secret.rrd", "pending", NewError(-1, "No such file or directory.")},
+		{"fetch", func(c *Client) error { _, err := c.Fetch("secret.rrd", Average); return err }, "fetch secret.rrd AVERAGE", "fetch", NewError(-1, "No such file or directory.")},
+		{"fetchbin", func(c *Client) error { _, err := c.FetchBin("secret.rrd", Average); return err }, "fetchbin secret.rrd AVERAGE", "fetchbin", NewError(-1, "No such file or directory.")},
+		{"info", func(c *Client) error { _, err := c.Info("secret.rrd"); return err }, "info secret.rrd", "info", NewError(-1, "No such file or directory.")},
+		{"update-named-info", func(c *Client) error { return c.UpdateNamed("secret.rrd", named) }, "info secret.rrd", "info", NewError(-1, "No such file or directory.")},
+		{"update-named-ds", func(c *Client) error { return c.UpdateNamed("known.rrd", named) }, "update known.rrd", "update", ErrUnknownDS},
+	}
+
+	for _, redact := range []bool{false, true} {
+		options := []func(*Client) error{Timeout(time.Second * 2)}
+		if redact {
+			options = append(options, Redact)
+		}
+		c, err := NewClient(s.Addr, options...)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for _, tc := range calls {
+			t.Run(fmt.Sprintf("%v-redact=%v", tc.name, redact), func(t *testing.T) {
+				err := tc.call(c)
+				var cerr *CommandError
+				if !assert.True(t, errors.As(err, &cerr), err) {
+					return
+				}
+				expect := tc.full
+				if redact {
+					expect = tc.redacted
+					assert.NotContains(t, err.Error(), "secret.rrd")
+					assert.NotContains(t, err.Error(), "known.rrd")
+				}
+				assert.Equal(t, expect, cerr.Cmd)
+				if errors.Is(tc.err, ErrUnknownDS) {
+					assert.ErrorIs(t, cerr.Err, tc.err)
+				} else {
+					assert.Equal(t, tc.err, cerr.Err)
+				}
+			})
+		}
+		assert.NoError(t, c.Close())
+	}
+}
+
+func TestParseResponseLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		cnt   int
+		msg   string
+		valid bool
+	}{
+		{"message", "0 PONG", 0, "PONG", true},
+		{"lines", "12 Info for test.rrd follows", 12, "Info for test.rrd follows", true},
+		{"error", "-1 No such file: test.rrd", -1, "No such file: test.rrd", true},
+		{"empty-message", "0 ", 0, "", true},
+		{"tab", "0\tPONG", 0, "PONG", true},
+		{"spaces", "3   lines follow ", 3, "lines follow ", true},
+		{"sign-only", "- PONG", 0, "", false},
+		{"letter-count", "1a PONG", 0, "", false},
+		{"leading-whitespace", " 0 PONG", 0, "", false},
+		{"no-message", "0", 0, "", false},
+		{"no-count", "PONG", 0, "", false},
+		{"empty", "", 0, "", false},
+		{"overflow", "99999999999999999999 lines", 0, "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cnt, msg, err := ParseResponseLine(tc.line)
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.cnt, cnt)
+				assert.Equal(t, tc.msg, msg)
+			}
+		})
+	}
+}
+
+func FuzzParseResponseLine(f *testing.F) {
+	for _, l := range []string{"0 PONG", "12 Info for test.rrd follows", "-1 No such file", "0\tPONG", "- PONG", "0", ""} {
+		f.Add(l)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		_, msg, err := ParseResponseLine(line)
+		if err != nil {
+			var ierr *InvalidResponseError
+			assert.True(t, errors.As(err, &ierr))
+			return
+		}
+		assert.True(t, strings.HasSuffix(line, msg))
+	})
+}
+
+func TestClientResponseParser(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{"ping": {"  0 PONG"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	_, err := NewClient(s.Addr, ResponseParser(nil))
+	assert.Equal(t, ErrNilOption, err)
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Error(t, c.Ping())
+	assert.NoError(t, c.Close())
+
+	trimmed := func(line string) (int, string, error) {
+		return ParseResponseLine(strings.TrimSpace(line))
+	}
+	c, err = NewClient(s.Addr, Timeout(time.Second*2), ResponseParser(trimmed))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+}
+
+func TestClientResponseParserInvalid(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"info":  {"2 Info for a.rrd follows", "step 1 60", "rrd_version 2 0003"},
+		"stats": {"1 Statistics follow", "QueueLength: 0"},
+		".":     {"2 errors", "1 bad", "2 bad"},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	// Rejects status lines with a count of 2, so their lines are unread.
+	reject := func(line string) (int, string, error) {
+		if strings.HasPrefix(line, "2 ") {
+			return 0, "", NewInvalidResponseError("rejected", line)
+		}
+		return ParseResponseLine(line)
+	}
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), ResponseParser(reject))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	var ierr *InvalidResponseError
+	_, err = c.Info("a.rrd")
+	assert.True(t, errors.As(err, &ierr), err)
+	_, err = c.Stats()
+	assert.NoError(t, err)
+
+	err = c.Batch(NewCmd("flush").WithArgs("a.rrd"), NewCmd("flush").WithArgs("b.rrd"))
+	assert.True(t, errors.As(err, &ierr), err)
+	_, err = c.Stats()
+	assert.NoError(t, err)
+}
+
+func TestClientLogger(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	_, err := NewClient(s.Addr, Logger(nil))
+	assert.Equal(t, ErrNilOption, err)
+
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Logger(l), Redact)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Flush("secret.rrd"))
+	assert.Contains(t, buf.String(), "cmd=flush")
+	assert.NotContains(t, buf.String(), "secret.rrd")
+}
+
+// testCert returns a self signed certificate for 127.0.0.1 and ::1.
+func testCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "rrdcached"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	cert, err := x509.ParseCertificate(der)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestClientTLS(t *testing.T) {
+	cert, pool := testCert(t)
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.Listener = tls.NewListener(s.Listener, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	_, err := NewClient(s.Addr, TLS(nil))
+	assert.Equal(t, ErrNilOption, err)
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), TLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+}
+
+func TestClientContext(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	// Short response which causes the client to wait for more lines.
+	s.responses = map[string][]string{"fetch": {"5 Success", "FlushVersion: 1"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*10))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	start := time.Now()
+	_, err = c.FetchWithContext(ctx, "test.rrd", Average)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, time.Since(start) < time.Second*5)
+
+	// The client must reconnect after the interrupted command.
+	assert.NoError(t, c.PingWithContext(context.Background()))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+	}()
+	_, err = c.ExecCmdWithContext(ctx, NewCmd("fetch").WithArgs("test.rrd", Average))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, c.Ping())
+
+	_, err = c.InfoWithContext(ctx, "test.rrd")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, c.Ping())
+}
+
+func TestClientCommandTimeout(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	// Short response which causes the client to wait for more lines.
+	s.responses = map[string][]string{"fetch": {"5 Success", "FlushVersion: 1"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*10))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	start := time.Now()
+	_, err = c.ExecCmd(NewCmd("fetch").WithArgs("test.rrd", Average), WithTimeout(time.Millisecond*50))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.True(t, time.Since(start) < time.Second*5)
+	assert.NoError(t, c.Ping())
+
+	start = time.Now()
+	_, err = c.FetchWithContext(CommandTimeout(context.Background(), time.Millisecond*50), "test.rrd", Average)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.True(t, time.Since(start) < time.Second*5)
+	assert.NoError(t, c.Ping())
+}
+
+func TestClientTimeoutOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		option func(time.Duration) func(*Client) error
+	}{
+		{"dial", DialTimeout},
+		{"read", ReadTimeout},
+		{"write", WriteTimeout},
+		{"command", CommandDeadline},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, d := range []time.Duration{0, -time.Second} {
+				_, err := NewClient("", tc.option(d))
+				assert.ErrorIs(t, err, ErrInvalidArg)
+			}
+		})
+	}
+}
+
+func TestClientReadTimeout(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	// Short response which causes the client to wait for more lines.
+	s.responses = map[string][]string{"fetch": {"5 Success", "FlushVersion: 1"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*10), ReadTimeout(time.Millisecond*50))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.Equal(t, time.Millisecond*50, c.timeoutOr(c.readTimeout))
+	assert.Equal(t, time.Second*10, c.timeoutOr(c.writeTimeout))
+	assert.Equal(t, time.Second*10, c.timeoutOr(c.dialTimeout))
+
+	start := time.Now()
+	_, err = c.Fetch("test.rrd", Average)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.True(t, time.Since(start) < time.Second*5)
+	assert.NoError(t, c.Ping())
+}
+
+func TestClientCommandDeadline(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	// Short response which causes the client to wait for more lines.
+	s.responses = map[string][]string{"fetch": {"5 Success", "FlushVersion: 1"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*10), CommandDeadline(time.Millisecond*50))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	start := time.Now()
+	_, err = c.Fetch("test.rrd", Average)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, time.Since(start) < time.Second*5)
+	assert.NoError(t, c.Ping())
+}
+
+func TestClientWithStatus(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	var st Status
+	lines, err := c.Exec("stats", WithStatus(&st))
+	assert.NoError(t, err)
+	assert.Len(t, lines, 9)
+	assert.Equal(t, Status{Count: 9, Msg: "Statistics follow"}, st)
+
+	_, err = c.Exec("pending test.rrd", WithStatus(&st))
+	assert.Error(t, err)
+	assert.Equal(t, Status{Count: -1, Msg: "No such file or directory."}, st)
+}
+
+func TestClientDialer(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	_, err := NewClient(s.Addr, Dialer(nil))
+	assert.Equal(t, ErrNilOption, err)
+
+	errDial := errors.New("dial failed")
+	_, err = NewClient(s.Addr, Dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errDial
+	}))
+	assert.ErrorIs(t, err, errDial)
+
+	var dials int
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Dialer(dial))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+	assert.NoError(t, c.conn.Close())
+	assert.NoError(t, c.Ping())
+	assert.Equal(t, 2, dials)
+}
+
+// noDelayConn is a net.Conn which records calls to SetNoDelay.
+type noDelayConn struct {
+	net.Conn
+	noDelay []bool
+	err     error
+}
+
+func (c *noDelayConn) SetNoDelay(noDelay bool) error {
+	c.noDelay = append(c.noDelay, noDelay)
+	return c.err
+}
+
+func TestClientNoDelay(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	var conn *noDelayConn
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		nc, err := d.DialContext(ctx, network, addr)
+		conn = &noDelayConn{Conn: nc}
+		return conn, err
+	}
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Dialer(dial))
+	if assert.NoError(t, err) {
+		assert.Nil(t, conn.noDelay)
+		assert.NoError(t, c.Close())
+	}
+
+	c, err = NewClient(s.Addr, Timeout(time.Second*2), Dialer(dial), NoDelay(false))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []bool{false}, conn.noDelay)
+		assert.NoError(t, c.Ping())
+		assert.NoError(t, c.Close())
+	}
+
+	errNoDelay := errors.New("no delay failed")
+	_, err = NewClient(s.Addr, Timeout(time.Second*2), NoDelay(true), Dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		conn.err = errNoDelay
+		return c, err
+	}))
+	assert.ErrorIs(t, err, errNoDelay)
+}
+
+func TestClientMaxLineSize(t *testing.T) {
+	long := "/" + strings.Repeat("a", DefaultMaxLineSize) + ".rrd"
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{"list": {"1 RRDs", long}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	_, err := NewClient(s.Addr, MaxLineSize(0))
+	assert.Error(t, err)
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = c.List(context.Background(), "/")
+	assert.ErrorIs(t, err, bufio.ErrTooLong)
+
+	// The client must reconnect after the partially read response.
+	assert.NoError(t, c.Ping())
+	assert.NoError(t, c.Close())
+
+	c, err = NewClient(s.Addr, Timeout(time.Second*2), MaxLineSize(DefaultMaxLineSize*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	l, err := c.List(context.Background(), "/")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{long}, l)
+	}
+}
+
+// benchConn is a net.Conn which responds to each write with resp, without
+// allocating, for benchmarking the clients overhead.
+type benchConn struct {
+	net.Conn
+	resp    []byte
+	pending []byte
+}
+
+func (c *benchConn) Write(b []byte) (int, error) {
+	c.pending = c.resp
+	return len(b), nil
+}
+
+func (c *benchConn) Read(b []byte) (int, error) {
+	if len(c.pending) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *benchConn) SetDeadline(time.Time) error { return nil }
+
+func (c *benchConn) Close() error { return nil }
+
+// newBenchClient returns a client whose connection responds to each
+// command with resp.
+func newBenchClient(b *testing.B, resp string) *Client {
+	b.Helper()
+	conn := &benchConn{resp: []byte(resp)}
+	c, err := NewClient("bench:42217", Dialer(func(context.Context, string, string) (net.Conn, error) {
+		return conn, nil
+	}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return c
+}
+
+func BenchmarkParseResponseLine(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ParseResponseLine("0 errors, enqueued 1 value(s)."); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	c := newBenchClient(b, "0 errors, enqueued 1 value(s).\n")
+	samples := []Sample{{Time: time.Unix(1000000, 0), Values: []float64{1.5, 2}}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Update("test.rrd", samples...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdateRaw(b *testing.B) {
+	c := newBenchClient(b, "0 errors, enqueued 1 value(s).\n")
+	u := Update("1000000:1.5:2")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.UpdateRaw("test.rrd", u); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExecCmd(b *testing.B) {
+	c := newBenchClient(b, "3 Info\nfilename 2 test.rrd\nstep 1 300\nlast_update 1 1000000\n")
+	cmd := NewCmd("info").WithArgs("test.rrd")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.ExecCmd(cmd); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// newRoundTripClient returns a client connected to a new mock server.
+func newRoundTripClient(b *testing.B, options ...func(*Client) error) *Client {
+	b.Helper()
+	s := newServerStopped(b)
+	if s == nil {
+		b.FailNow()
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	b.Cleanup(func() {
+		assert.NoError(b, s.Close())
+	})
+
+	c, err := NewClient(s.Addr, append([]func(*Client) error{Timeout(time.Second * 2)}, options...)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		assert.NoError(b, c.Close())
+	})
+	return c
+}
+
+func BenchmarkRoundTripUpdate(b *testing.B) {
+	tests := []struct {
+		name    string
+		options []func(*Client) error
+	}{
+		{name: "default"},
+		{name: "nagle", options: []func(*Client) error{NoDelay(false)}},
+	}
+
+	for _, tc := range tests {
+		b.Run(tc.name, func(b *testing.B) {
+			c := newRoundTripClient(b, tc.options...)
+			samples := []Sample{{Time: time.Unix(1000000, 0), Values: []float64{1.5, 2}}}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := c.Update("test.rrd", samples...); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "updates/s")
+		})
+	}
+}
+
+func BenchmarkRoundTripBatch(b *testing.B) {
+	c := newRoundTripClient(b)
+	cmds := make([]*Cmd, 100)
+	for i := range cmds {
+		cmds[i] = updateCmd("test.rrd", Update(fmt.Sprintf("%v:1.5:2", 1000000+i)))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Batch(cmds...); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N*len(cmds))/b.Elapsed().Seconds(), "updates/s")
+}
+
+func BenchmarkRoundTripFetch(b *testing.B) {
+	c := newRoundTripClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Fetch("test.rrd", Average); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,9 +1,48 @@
 package rrd
 
 import (
+	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
 )
 
+// mutatingCmds is the set of commands which modify RRDs or the daemons state.
+var mutatingCmds = map[string]struct{}{
+	"update":     {},
+	"create":     {},
+	"forget":     {},
+	"flush":      {},
+	"flushall":   {},
+	"wrote":      {},
+	"tune":       {},
+	"suspend":    {},
+	"suspendall": {},
+	"resume":     {},
+	"resumeall":  {},
+	"batch":      {},
+}
+
+// fileCmds is the set of commands whose first argument is a rrd filename.
+var fileCmds = map[string]struct{}{
+	"update":   {},
+	"create":   {},
+	"forget":   {},
+	"flush":    {},
+	"pending":  {},
+	"wrote":    {},
+	"info":     {},
+	"first":    {},
+	"last":     {},
+	"fetch":    {},
+	"fetchbin": {},
+	"tune":     {},
+	"suspend":  {},
+	"resume":   {},
+}
+
 // Cmd represents a rrdcached command.
 type Cmd struct {
 	cmd  string
@@ -21,7 +60,107 @@ func (c *Cmd) WithArgs(args ...interface{}) *Cmd {
 	return c
 }
 
+// escapeArg returns s with spaces and backslashes escaped, so it's treated
+// as a single field by rrdcached.
+func escapeArg(s string) string {
+	if !strings.ContainsAny(s, " \\") {
+		return s
+	}
+	return string(appendEscaped(nil, s))
+}
+
+// appendEscaped appends s to b with spaces and backslashes escaped.
+func appendEscaped(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\\' {
+			b = append(b, '\\')
+		}
+		b = append(b, s[i])
+	}
+	return b
+}
+
+// appendArg appends the protocol representation of the argument a to b.
+func appendArg(b []byte, a interface{}) []byte {
+	switch v := a.(type) {
+	case string:
+		return appendEscaped(b, v)
+	case Update:
+		return append(b, v...)
+	case ConsolidationFunc:
+		return append(b, v...)
+	case int:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case fmt.Stringer:
+		return append(b, v.String()...)
+	}
+	return fmt.Append(b, a)
+}
+
+// cmdBufPool pools the buffers commands are formatted into for sending.
+var cmdBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// appendTo appends the protocol representation of c, including the line
+// terminator, to b.
+func (c *Cmd) appendTo(b []byte) []byte {
+	b = append(b, c.cmd...)
+	for _, a := range c.args {
+		b = append(b, ' ')
+		b = appendArg(b, a)
+	}
+	return append(b, '\n')
+}
+
+// String returns the protocol representation of c.
+// String arguments, such as filenames, are escaped so they are treated as a
+// single field, other arguments such as CreateOption are formatted as is.
 func (c *Cmd) String() string {
-	args := append([]interface{}{c.cmd}, c.args...)
-	return fmt.Sprintln(args...)
+	return string(c.appendTo(nil))
+}
+
+// validate returns an error if c contains characters which can't be
+// represented in the line based protocol.
+func (c *Cmd) validate() error {
+	buf := cmdBufPool.Get().(*[]byte)
+	defer cmdBufPool.Put(buf)
+
+	*buf = c.appendTo((*buf)[:0])
+	s := (*buf)[:len(*buf)-1]
+	if i := bytes.IndexAny(s, "\r\n\x00"); i != -1 {
+		return fmt.Errorf("%w: invalid character %q", ErrInvalidArg, s[i])
+	}
+	return nil
+}
+
+// verb returns the lower case command name of c, excluding any arguments
+// which were passed as part of the raw command string.
+func (c *Cmd) verb() string {
+	v := strings.TrimSpace(c.cmd)
+	if i := strings.IndexFunc(v, unicode.IsSpace); i >= 0 {
+		v = v[:i]
+	}
+	return strings.ToLower(v)
+}
+
+// mutating returns true if c modifies RRDs or the daemons state, false otherwise.
+func (c *Cmd) mutating() bool {
+	_, ok := mutatingCmds[c.verb()]
+	return ok
+}
+
+// filename returns the rrd filename c operates on, or "" if it doesn't
+// operate on a single file.
+func (c *Cmd) filename() string {
+	if _, ok := fileCmds[c.verb()]; !ok || len(c.args) == 0 {
+		return ""
+	}
+	s, _ := c.args[0].(string)
+	return s
 }
@@ -0,0 +1,531 @@
+// Command gorrd provides command line access to rrdcached, for use where
+// rrdtool isn't installed or only rrdcached is reachable.
+//
+// Usage:
+//
+//	gorrd [flags] <command> [command flags] [args]
+//
+// The server address is taken from -addr, which defaults to the
+// RRDCACHED_ADDRESS environment variable, and may be given as
+// unix:///path/to/socket or tcp://host:port.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdbackup"
+	"github.com/thz/go-rrd/rrdsync"
+	"github.com/thz/go-rrd/rrdwhisper"
+)
+
+// errUsage is returned when a command is invoked incorrectly.
+var errUsage = errors.New("usage")
+
+// command is a gorrd subcommand.
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, c *rrd.Client, out *output, args []string) error
+}
+
+var commands = map[string]command{
+	"info":    {usage: "info <file>", help: "show the configuration of an RRD", run: runInfo},
+	"list":    {usage: "list [prefix]", help: "list the RRDs under prefix", run: runList},
+	"fetch":   {usage: "fetch [-cf CF] [-start time] [-end time] [-csv] <file>", help: "fetch data from an RRD", run: runFetch},
+	"update":  {usage: "update <file> <time:value[:value...]>...", help: "update an RRD", run: runUpdate},
+	"create":  {usage: "create [-step duration] [-start time] [-no-overwrite] <file> <DS:...>... <RRA:...>...", help: "create an RRD", run: runCreate},
+	"flush":   {usage: "flush [file...]", help: "flush RRDs, or all if none are given", run: runFlush},
+	"stats":   {usage: "stats", help: "show server statistics", run: runStats},
+	"pending": {usage: "pending <file>", help: "show the updates pending for an RRD", run: runPending},
+	"whisper": {usage: "whisper [-ds name] [-no-overwrite] <src.wsp> <dst.rrd>", help: "import a Graphite whisper file", run: runWhisper},
+	"backup":  {usage: "backup <archive.tar> [prefix]", help: "back up the RRDs under prefix to an archive", run: runBackup},
+	"restore": {usage: "restore [-no-overwrite] <archive.tar>", help: "restore the RRDs of an archive", run: runRestore},
+	"sync":    {usage: "sync [-history duration] <dst-addr> [prefix]", help: "mirror RRDs to another server", run: runSync},
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs gorrd with args writing output to stdout and errors to stderr,
+// returning the exit code.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gorrd", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", envDefault("RRDCACHED_ADDRESS", "localhost"), "rrdcached address")
+	timeout := fs.Duration("timeout", rrd.DefaultTimeout, "timeout for each command")
+	jsonOut := fs.Bool("json", false, "output JSON instead of a table")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "gorrd: unknown command %q\n", name)
+		fs.Usage()
+		return 2
+	}
+
+	c, err := rrd.NewClient(*addr, rrd.Timeout(*timeout))
+	if err != nil {
+		fmt.Fprintf(stderr, "gorrd: %v\n", err)
+		return 1
+	}
+	defer c.Close() // nolint: errcheck
+
+	out := &output{w: stdout, json: *jsonOut}
+	if err := cmd.run(ctx, c, out, fs.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "usage: gorrd %v\n", cmd.usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "gorrd %v: %v\n", name, err)
+		return 1
+	}
+
+	return 0
+}
+
+// usage prints the usage of gorrd.
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: gorrd [flags] <command> [command flags] [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %v\t%v\n", name, commands[name].help)
+	}
+	tw.Flush() // nolint: errcheck
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+}
+
+// envDefault returns the value of the environment variable key or def if
+// it's not set.
+func envDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// output writes command results as either a table or JSON.
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+// write writes v as JSON if enabled otherwise calls table to write the
+// tab separated rows of a table.
+func (o *output) write(v interface{}, table func(w io.Writer)) error {
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// parseTime parses s as "now", a duration relative to now such as "-1h",
+// a unix timestamp or an RFC 3339 time.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	switch {
+	case s == "now":
+		return now, nil
+	case strings.HasPrefix(s, "-"), strings.HasPrefix(s, "+"):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// timeFlag is a flag.Value which parses times with parseTime.
+type timeFlag struct {
+	t   time.Time
+	now time.Time
+}
+
+func (f *timeFlag) String() string {
+	if f.t.IsZero() {
+		return ""
+	}
+	return f.t.Format(time.RFC3339)
+}
+
+func (f *timeFlag) Set(s string) error {
+	t, err := parseTime(s, f.now)
+	if err != nil {
+		return err
+	}
+	f.t = t
+	return nil
+}
+
+// flags returns a FlagSet for the command name which reports errors as
+// errUsage.
+func flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseFlags parses args with fs, returning errUsage on failure.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return nil
+}
+
+// formatValue returns the table representation of v, with NaN as unknown.
+func formatValue(v float64) string {
+	if math.IsNaN(v) {
+		return "U"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func runInfo(ctx context.Context, c *rrd.Client, out *output, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	info, err := c.InfoWithContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	m := make(map[string]interface{}, len(info))
+	for _, i := range info {
+		m[i.Key] = i.Value
+	}
+	return out.write(m, func(w io.Writer) {
+		for _, i := range info {
+			fmt.Fprintf(w, "%v\t%v\n", i.Key, i.Value)
+		}
+	})
+}
+
+func runList(ctx context.Context, c *rrd.Client, out *output, args []string) error {
+	var prefix string
+	switch len(args) {
+	case 0:
+	case 1:
+		prefix = args[0]
+	default:
+		return errUsage
+	}
+
+	names, err := c.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	return out.write(names, func(w io.Writer) {
+		for _, n := range names {
+			fmt.Fprintln(w, n)
+		}
+	})
+}
+
+func runFetch(ctx context.Context, c *rrd.Client, out *output, args []string) error {
+	now := time.Now()
+	fs := flags("fetch")
+	cf := fs.String("cf", string(rrd.Average), "consolidation function")
+	start := &timeFlag{t: now.Add(-time.Hour * 24), now: now}
+	end := &timeFlag{t: now, now: now}
+	fs.Var(start, "start", "start time")
+	fs.Var(end, "end", "end time")
+	csvOut := fs.Bool("csv", false, "output CSV")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	f, err := c.FetchRangeWithContext(ctx, fs.Arg(0), rrd.ConsolidationFunc(strings.ToUpper(*cf)), start.t, end.t)
+	if err != nil {
+		return err
+	}
+	if *csvOut {
+		return f.WriteCSV(out.w, rrd.CSVTimeFormat(rrd.CSVUnixTime), rrd.CSVNaN("U"))
+	}
+
+	return out.write(f, func(w io.Writer) {
+		fmt.Fprintf(w, "time\t%v\n", strings.Join(f.Names, "\t"))
+		for _, row := range f.Rows {
+			vals := make([]string, len(row.Values))
+			for i, v := range row.Values {
+				vals[i] = formatValue(v)
+			}
+			fmt.Fprintf(w, "%v\t%v\n", row.Time.Unix(), strings.Join(vals, "\t"))
+		}
+	})
+}
+
+func runUpdate(ctx context.Context, c *rrd.Client, _ *output, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	samples := make([]rrd.Sample, len(args)-1)
+	for i, a := range args[1:] {
+		s, err := rrd.ParseSample(a)
+		if err != nil {
+			return err
+		}
+		samples[i] = s
+	}
+
+	return c.UpdateWithContext(ctx, args[0], samples...)
+}
+
+func runCreate(ctx context.Context, c *rrd.Client, _ *output, args []string) error {
+	now := time.Now()
+	fs := flags("create")
+	step := fs.Duration("step", 0, "base interval of the RRD")
+	start := &timeFlag{now: now}
+	fs.Var(start, "start", "time of the first value")
+	noOverwrite := fs.Bool("no-overwrite", false, "don't overwrite an existing RRD")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 3 {
+		return errUsage
+	}
+
+	def := rrd.NewCreateRRD(nil, nil)
+	for _, a := range fs.Args()[1:] {
+		switch {
+		case strings.HasPrefix(a, "DS:"):
+			def.WithDS(rrd.NewDS(a))
+		case strings.HasPrefix(a, "RRA:"):
+			def.WithRRA(rrd.NewRRA(a))
+		default:
+			return fmt.Errorf("%w: %q isn't a DS or RRA", errUsage, a)
+		}
+	}
+	if *step > 0 {
+		def.WithStep(*step)
+	}
+	if !start.t.IsZero() {
+		def.WithStart(start.t)
+	}
+	if *noOverwrite {
+		def.WithNoOverwrite()
+	}
+
+	return c.CreateFromWithContext(ctx, fs.Arg(0), def)
+}
+
+func runFlush(ctx context.Context, c *rrd.Client, _ *output, args []string) error {
+	if len(args) == 0 {
+		return c.FlushAllWithContext(ctx)
+	}
+
+	for _, f := range args {
+		if err := c.FlushWithContext(ctx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runStats(ctx context.Context, c *rrd.Client, out *output, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	s, err := c.StatsWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return out.write(s, func(w io.Writer) {
+		fmt.Fprintf(w, "QueueLength\t%v\n", s.QueueLength)
+		fmt.Fprintf(w, "UpdatesReceived\t%v\n", s.UpdatesReceived)
+		fmt.Fprintf(w, "FlushesReceived\t%v\n", s.FlushesReceived)
+		fmt.Fprintf(w, "UpdatesWritten\t%v\n", s.UpdatesWritten)
+		fmt.Fprintf(w, "DataSetsWritten\t%v\n", s.DataSetsWritten)
+		fmt.Fprintf(w, "TreeNodesNumber\t%v\n", s.TreeNodesNumber)
+		fmt.Fprintf(w, "TreeDepth\t%v\n", s.TreeDepth)
+		fmt.Fprintf(w, "JournalBytes\t%v\n", s.JournalBytes)
+		fmt.Fprintf(w, "JournalRotate\t%v\n", s.JournalRotate)
+	})
+}
+
+func runPending(ctx context.Context, c *rrd.Client, out *output, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	samples, err := c.PendingWithContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	rows := make([]rrd.FetchResultRow, len(samples))
+	for i, s := range samples {
+		rows[i] = rrd.FetchResultRow{Time: s.Time, Values: s.Values}
+	}
+	return out.write(rows, func(w io.Writer) {
+		for _, r := range rows {
+			vals := make([]string, len(r.Values))
+			for i, v := range r.Values {
+				vals[i] = formatValue(v)
+			}
+			fmt.Fprintf(w, "%v\t%v\n", r.Time.Unix(), strings.Join(vals, "\t"))
+		}
+	})
+}
+
+func runWhisper(ctx context.Context, c *rrd.Client, _ *output, args []string) error {
+	fs := flags("whisper")
+	ds := fs.String("ds", rrdwhisper.DefaultDSName, "name of the data source")
+	noOverwrite := fs.Bool("no-overwrite", false, "don't overwrite an existing RRD")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errUsage
+	}
+
+	opts := []func(*rrdwhisper.Importer) error{rrdwhisper.DSName(*ds)}
+	if *noOverwrite {
+		opts = append(opts, rrdwhisper.NoOverwrite)
+	}
+	imp, err := rrdwhisper.NewImporter(c, opts...)
+	if err != nil {
+		return err
+	}
+
+	return imp.Import(ctx, fs.Arg(0), fs.Arg(1))
+}
+
+func runSync(ctx context.Context, c *rrd.Client, out *output, args []string) error {
+	fs := flags("sync")
+	history := fs.Duration("history", 0, "history replayed to created RRDs, defaults to the span of the source archives")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return errUsage
+	}
+
+	dst, err := rrd.NewClient(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer dst.Close() // nolint: errcheck
+
+	var opts []func(*rrdsync.Syncer) error
+	if *history > 0 {
+		opts = append(opts, rrdsync.History(*history))
+	}
+	s, err := rrdsync.NewSyncer(c, dst, opts...)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.Sync(ctx, fs.Arg(1))
+	if res != nil {
+		werr := out.write(res, func(w io.Writer) {
+			fmt.Fprintln(w, "FILES\tCREATED\tROWS")
+			fmt.Fprintf(w, "%v\t%v\t%v\n", res.Files, res.Created, res.Rows)
+		})
+		if err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+func runBackup(ctx context.Context, c *rrd.Client, out *output, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	var prefix string
+	if len(args) == 2 {
+		prefix = args[1]
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := rrdbackup.Backup(ctx, c, f, prefix)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return out.write(map[string]int{"files": n}, func(w io.Writer) {
+		fmt.Fprintf(w, "backed up %v RRDs\n", n)
+	})
+}
+
+func runRestore(ctx context.Context, c *rrd.Client, out *output, args []string) error {
+	fs := flags("restore")
+	noOverwrite := fs.Bool("no-overwrite", false, "don't overwrite existing RRDs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	var opts []rrdbackup.RestoreOption
+	if *noOverwrite {
+		opts = append(opts, rrdbackup.NoOverwrite())
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+
+	n, err := rrdbackup.Restore(ctx, c, f, opts...)
+	if werr := out.write(map[string]int{"files": n}, func(w io.Writer) {
+		fmt.Fprintf(w, "restored %v RRDs\n", n)
+	}); err == nil {
+		err = werr
+	}
+	return err
+}
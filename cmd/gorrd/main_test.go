@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Unix(1499968800, 0)
+	tests := []struct {
+		val    string
+		expect time.Time
+		err    bool
+	}{
+		{val: "now", expect: now},
+		{val: "-1h", expect: now.Add(-time.Hour)},
+		{val: "+5m", expect: now.Add(time.Minute * 5)},
+		{val: "1499900000", expect: time.Unix(1499900000, 0)},
+		{val: "2017-07-13T18:00:00Z", expect: time.Date(2017, 7, 13, 18, 0, 0, 0, time.UTC)},
+		{val: "-1x", err: true},
+		{val: "yesterday", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.val, func(t *testing.T) {
+			ts, err := parseTime(tc.val, now)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tc.expect.Equal(ts), "expected %v got %v", tc.expect, ts)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	s.Handle("info",
+		"3 Info for test.rrd follows",
+		"filename 2 test.rrd",
+		"step 1 300",
+		"ds[watts].max 0 2.4000000000e+04",
+	)
+	s.Handle("list", "2 RRDs", "/test.rrd", "/sub/other.rrd")
+	s.Handle("fetch",
+		"8 Success",
+		"FlushVersion: 1",
+		"Start: 1499908800",
+		"End: 1499909400",
+		"Step: 300",
+		"DSCount: 2",
+		"DSName: watts amps",
+		"1499909100: 8.00000000000000000e+00 1.5e+00",
+		"1499909400: nan -nan",
+	)
+	s.Handle("update", "0 errors, enqueued 2 value(s).")
+	s.Handle("pending", "2 updates pending", "1499909100:1:U", "1499909400:2:3")
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		expect string
+		cmd    string
+	}{
+		{
+			name:   "info",
+			args:   []string{"info", "test.rrd"},
+			expect: "filename       test.rrd\nstep           300\nds[watts].max  24000\n",
+			cmd:    "info test.rrd",
+		},
+		{
+			name:   "info-json",
+			args:   []string{"-json", "info", "test.rrd"},
+			expect: "{\n  \"ds[watts].max\": 24000,\n  \"filename\": \"test.rrd\",\n  \"step\": 300\n}\n",
+		},
+		{
+			name:   "list",
+			args:   []string{"list"},
+			expect: "/test.rrd\n/sub/other.rrd\n",
+		},
+		{
+			name:   "fetch",
+			args:   []string{"fetch", "-cf", "max", "-start", "1499908800", "-end", "1499909400", "test.rrd"},
+			expect: "time        watts  amps\n1499909100  8      1.5\n1499909400  U      U\n",
+			cmd:    "fetch test.rrd MAX 1499908800 1499909400",
+		},
+		{
+			name:   "fetch-csv",
+			args:   []string{"fetch", "-csv", "-start", "1499908800", "-end", "1499909400", "test.rrd"},
+			expect: "time,watts,amps\n1499909100,8,1.5\n1499909400,U,U\n",
+			cmd:    "fetch test.rrd AVERAGE 1499908800 1499909400",
+		},
+		{
+			name: "update",
+			args: []string{"update", "test.rrd", "1499909100:1:U", "1499909400:2:3"},
+			cmd:  "update test.rrd 1499909100:1:U 1499909400:2:3",
+		},
+		{
+			name: "create",
+			args: []string{"create", "-step", "5m", "-no-overwrite", "test.rrd", "DS:watts:GAUGE:300:0:24000", "RRA:AVERAGE:0.5:1:288"},
+			cmd:  "create test.rrd -s 300 -O DS:watts:GAUGE:300:0:24000 RRA:AVERAGE:0.5:1:288",
+		},
+		{
+			name: "flush",
+			args: []string{"flush", "a.rrd", "b.rrd"},
+			cmd:  "flush b.rrd",
+		},
+		{
+			name: "flushall",
+			args: []string{"flush"},
+			cmd:  "flushall",
+		},
+		{
+			name:   "stats",
+			args:   []string{"stats"},
+			expect: "QueueLength      0\n",
+		},
+		{
+			name:   "pending",
+			args:   []string{"pending", "test.rrd"},
+			expect: "1499909100  1  U\n1499909400  2  3\n",
+		},
+		{
+			name:   "pending-json",
+			args:   []string{"-json", "pending", "test.rrd"},
+			expect: `"values": [` + "\n      1,\n      null\n    ]",
+		},
+		{name: "no-command", code: 2},
+		{name: "unknown-command", args: []string{"unknown"}, code: 2},
+		{name: "usage", args: []string{"info"}, code: 2},
+		{name: "bad-flag", args: []string{"fetch", "-start", "never", "test.rrd"}, code: 2},
+		{name: "bad-sample", args: []string{"update", "test.rrd", "x"}, code: 1},
+		{name: "whisper-usage", args: []string{"whisper", "test.wsp"}, code: 2},
+		{name: "sync-usage", args: []string{"sync"}, code: 2},
+		{name: "backup-usage", args: []string{"backup"}, code: 2},
+		{name: "restore-missing", args: []string{"restore", "missing.tar"}, code: 1},
+		{name: "whisper-missing", args: []string{"whisper", "missing.wsp", "test.rrd"}, code: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s.Reset()
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-addr", "tcp://" + s.Addr, "-timeout", "2s"}, tc.args...)
+			code := run(context.Background(), args, &stdout, &stderr)
+			if !assert.Equal(t, tc.code, code, stderr.String()) || tc.code != 0 {
+				return
+			}
+
+			if strings.HasSuffix(tc.name, "-json") {
+				assert.True(t, json.Valid(stdout.Bytes()))
+				assert.Contains(t, stdout.String(), tc.expect)
+			} else if tc.name == "stats" {
+				assert.True(t, strings.HasPrefix(stdout.String(), tc.expect), stdout.String())
+			} else {
+				assert.Equal(t, tc.expect, stdout.String())
+			}
+			if tc.cmd != "" {
+				assert.Contains(t, s.Received(), tc.cmd)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package rrd
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,8 +13,14 @@ type Info struct {
 	Value interface{}
 }
 
+// InfoMap returns the configuration information for the specified RRD as a map.
 func (c *Client) InfoMap(filename string) (map[string]interface{}, error) {
-	infoList, err := c.Info(filename)
+	return c.InfoMapWithContext(context.Background(), filename)
+}
+
+// InfoMapWithContext returns the configuration information for the specified RRD as a map.
+func (c *Client) InfoMapWithContext(ctx context.Context, filename string) (map[string]interface{}, error) {
+	infoList, err := c.InfoWithContext(ctx, filename)
 	if err != nil {
 		return nil, err
 	}
@@ -25,42 +32,121 @@ func (c *Client) InfoMap(filename string) (map[string]interface{}, error) {
 }
 
 // Info returns the configuration information for the specified RRD.
+// If the client was created with InfoCache the result may be cached.
 func (c *Client) Info(filename string) ([]*Info, error) {
-	lines, err := c.ExecCmd(NewCmd("info").WithArgs(filename))
+	return c.InfoWithContext(context.Background(), filename)
+}
+
+// InfoWithContext returns the configuration information for the specified RRD.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) InfoWithContext(ctx context.Context, filename string) ([]*Info, error) {
+	if c.infoCache == nil {
+		return c.info(ctx, filename)
+	}
+
+	key := c.cacheKey(filename)
+	if info, ok := c.infoCache.get(key); ok {
+		return info, nil
+	}
+
+	info, err := c.info(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	c.infoCache.set(key, info)
+
+	return info, nil
+}
+
+// InfoStruct returns the configuration information for the specified RRD
+// parsed into a RRDInfo.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) InfoStruct(filename string) (*RRDInfo, error) {
+	return c.InfoStructWithContext(context.Background(), filename)
+}
+
+// InfoStructWithContext returns the configuration information for the
+// specified RRD parsed into a RRDInfo.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) InfoStructWithContext(ctx context.Context, filename string) (*RRDInfo, error) {
+	info, err := c.InfoWithContext(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRRDInfo(info)
+}
+
+// Exists returns true if the RRD filename exists, false otherwise.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) Exists(filename string) (bool, error) {
+	return c.ExistsWithContext(context.Background(), filename)
+}
+
+// ExistsWithContext returns true if the RRD filename exists, false otherwise.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) ExistsWithContext(ctx context.Context, filename string) (bool, error) {
+	_, err := c.InfoWithContext(ctx, filename)
+	switch {
+	case err == nil:
+		return true, nil
+	case IsNotExist(err):
+		return false, nil
+	}
+	return false, err
+}
+
+// info returns the uncached configuration information for the specified RRD.
+func (c *Client) info(ctx context.Context, filename string) ([]*Info, error) {
+	lines, err := c.ExecCmdWithContext(ctx, NewCmd("info").WithArgs(filename))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get info for '%s': %w", filename, err)
+		return nil, err
 	}
 
 	data := make([]*Info, len(lines))
 	for i, line := range lines {
-		parts := strings.SplitN(line, " ", 3)
-		if len(parts) != 3 {
-			return nil, fmt.Errorf("unexpected response: not 3 parts '%s'", line)
-		}
-		info := &Info{Key: parts[0]}
-		switch parts[1] {
-		case "2":
-			// string
-			info.Value = parts[2]
-		case "1":
-			// int
-			v, err := strconv.ParseInt(parts[2], 10, 64)
-			if err != nil {
-				return nil, NewInvalidResponseError(fmt.Sprintf("info: invalid int for key %v", info.Key), line)
-			}
-			info.Value = v
-		case "0":
-			// float
-			v, err := strconv.ParseFloat(parts[2], 64)
-			if err != nil {
-				return nil, NewInvalidResponseError(fmt.Sprintf("info: invalid float for key %v", info.Key), line)
-			}
-			info.Value = v
-		default:
-			return nil, NewInvalidResponseError(fmt.Sprintf("info: unknown type %v for key %v", parts[1], info.Key), line)
+		if data[i], err = parseInfoLine(line); err != nil {
+			return nil, err
 		}
-		data[i] = info
 	}
 
 	return data, nil
 }
+
+// parseInfoLine parses the info response line, of the form
+// "<key> <type> <value>".
+func parseInfoLine(line string) (*Info, error) {
+	key, rest, ok := strings.Cut(line, " ")
+	if !ok || key == "" {
+		return nil, NewInvalidResponseError("info: missing type", line)
+	}
+	typ, val, ok := strings.Cut(rest, " ")
+	if !ok {
+		return nil, NewInvalidResponseError(fmt.Sprintf("info: missing value for key %v", key), line)
+	}
+
+	info := &Info{Key: key}
+	switch typ {
+	case "2":
+		// string
+		info.Value = val
+	case "1":
+		// int
+		v, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, NewInvalidResponseError(fmt.Sprintf("info: invalid int for key %v", key), line)
+		}
+		info.Value = v
+	case "0":
+		// float
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, NewInvalidResponseError(fmt.Sprintf("info: invalid float for key %v", key), line)
+		}
+		info.Value = v
+	default:
+		return nil, NewInvalidResponseError(fmt.Sprintf("info: unknown type %v for key %v", typ, key), line)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,58 @@
+package rrd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInfoLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		expect *Info
+	}{
+		{name: "string", line: "filename 2 test file.rrd", expect: &Info{Key: "filename", Value: "test file.rrd"}},
+		{name: "empty-string", line: "rra[0].cf 2 ", expect: &Info{Key: "rra[0].cf", Value: ""}},
+		{name: "int", line: "step 1 300", expect: &Info{Key: "step", Value: int64(300)}},
+		{name: "float", line: "ds[watts].max 0 1.0000000000e+02", expect: &Info{Key: "ds[watts].max", Value: 100.0}},
+		{name: "no-type", line: "step"},
+		{name: "no-value", line: "step 1"},
+		{name: "no-key", line: " 1 300"},
+		{name: "invalid-int", line: "step 1 five"},
+		{name: "invalid-float", line: "ds[watts].max 0 max"},
+		{name: "unknown-type", line: "step 3 300"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := parseInfoLine(tc.line)
+			if tc.expect == nil {
+				var ierr *InvalidResponseError
+				assert.True(t, errors.As(err, &ierr))
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expect, info)
+			}
+		})
+	}
+}
+
+func FuzzParseInfoLine(f *testing.F) {
+	for _, l := range []string{"filename 2 test.rrd", "step 1 300", "ds[watts].min 0 nan", "step", "step 1", " 1 1"} {
+		f.Add(l)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		info, err := parseInfoLine(line)
+		if err != nil {
+			var ierr *InvalidResponseError
+			assert.True(t, errors.As(err, &ierr))
+			return
+		}
+		assert.NotEmpty(t, info.Key)
+		assert.NotNil(t, info.Value)
+	})
+}
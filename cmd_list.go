@@ -2,21 +2,183 @@ package rrd
 
 import (
 	"context"
-
-	"github.com/paraopsde/go-x/pkg/util"
-	"go.uber.org/zap"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
 )
 
+// listConfig is the configuration of a list.
+type listConfig struct {
+	recursive bool
+	match     []func(name string) bool
+}
+
+// ListOption is an option of List, ListStream and Walk.
+type ListOption func(*listConfig) error
+
+// ListRecursive lists the RRDs of all directories below the prefix using
+// rrdcached's LIST RECURSIVE, which requires rrdcached 1.5.5 or later.
+func ListRecursive() ListOption {
+	return func(c *listConfig) error {
+		c.recursive = true
+		return nil
+	}
+}
+
+// ListGlob only lists names matching the path.Match pattern. Names are
+// matched without any leading slash, so "*/*.rrd" matches "/sub/other.rrd".
+func ListGlob(pattern string) ListOption {
+	return func(c *listConfig) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: glob %q: %v", ErrInvalidArg, pattern, err)
+		}
+		c.match = append(c.match, func(name string) bool {
+			ok, _ := path.Match(pattern, strings.TrimPrefix(name, "/"))
+			return ok
+		})
+		return nil
+	}
+}
+
+// ListRegexp only lists names matching re.
+func ListRegexp(re *regexp.Regexp) ListOption {
+	return func(c *listConfig) error {
+		if re == nil {
+			return ErrNilOption
+		}
+		c.match = append(c.match, re.MatchString)
+		return nil
+	}
+}
+
+// newListConfig returns the list configuration of opts.
+func newListConfig(opts []ListOption) (*listConfig, error) {
+	cfg := &listConfig{}
+	for _, o := range opts {
+		if o == nil {
+			return nil, ErrNilOption
+		}
+		if err := o(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// cmd returns the list command for prefix.
+func (lc *listConfig) cmd(prefix string) *Cmd {
+	if lc.recursive {
+		return NewCmd("list").WithArgs("RECURSIVE", prefix)
+	}
+	return NewCmd("list").WithArgs(prefix)
+}
+
+// matches returns true if name matches all the filters of lc.
+func (lc *listConfig) matches(name string) bool {
+	for _, m := range lc.match {
+		if !m(name) {
+			return false
+		}
+	}
+	return true
+}
+
 // List returns the list of available RRDs
-func (c *Client) List(ctx context.Context, prefix string) ([]string, error) {
-	log := util.CtxLogOrPanic(ctx)
+func (c *Client) List(ctx context.Context, prefix string, opts ...ListOption) ([]string, error) {
+	cfg, err := newListConfig(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	lines, err := c.ExecCmd(NewCmd("list").WithArgs(prefix))
+	lines, err := c.ExecCmdWithContext(ctx, cfg.cmd(prefix))
 	if err != nil {
 		return nil, err
 	}
 
-	log.Info("got list result", zap.Any("lines", lines))
+	c.logger.DebugContext(ctx, "got list result", "count", len(lines))
+
+	if len(cfg.match) == 0 {
+		return lines, nil
+	}
+	names := lines[:0]
+	for _, l := range lines {
+		if cfg.matches(l) {
+			names = append(names, l)
+		}
+	}
+	return names, nil
+}
+
+// ListStream calls f with each available RRD as it's read, instead of
+// buffering the entire list.
+func (c *Client) ListStream(ctx context.Context, prefix string, f func(name string) error, opts ...ListOption) error {
+	cfg, err := newListConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	return c.ExecCmdStream(ctx, cfg.cmd(prefix), LineFunc(func(name string) error {
+		if !cfg.matches(name) {
+			return nil
+		}
+		return f(name)
+	}))
+}
 
-	return lines, nil
+// Walk calls fn with each RRD below prefix, in all directories, which
+// matches the filters of opts. If fn returns fs.SkipAll the walk stops
+// without error, any other error stops the walk and is returned.
+// LIST RECURSIVE is used if the server supports it, otherwise each
+// directory, listed with a trailing slash, is listed in turn.
+func (c *Client) Walk(ctx context.Context, prefix string, fn func(name string) error, opts ...ListOption) error {
+	cfg, err := newListConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	var called bool
+	walk := func(name string) error {
+		if strings.HasSuffix(name, "/") || !cfg.matches(name) {
+			return nil
+		}
+		called = true
+		return fn(name)
+	}
+
+	rec := *cfg
+	rec.recursive = true
+	err = c.ExecCmdStream(ctx, rec.cmd(prefix), LineFunc(walk))
+	var serr *Error
+	if errors.As(err, &serr) && !called && ctx.Err() == nil {
+		// Not supported by the server, walk the directories ourselves.
+		err = c.walkDirs(ctx, prefix, walk)
+	}
+	if errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkDirs calls fn with each entry below dir, listing each directory in
+// turn.
+func (c *Client) walkDirs(ctx context.Context, dir string, fn func(name string) error) error {
+	lines, err := c.ExecCmdWithContext(ctx, NewCmd("list").WithArgs(dir))
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if strings.HasSuffix(l, "/") {
+			if err := c.walkDirs(ctx, l, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,127 @@
+package rrd
+
+import (
+	"context"
+	"io/fs"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListOptions(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"list RECURSIVE /": {"3 RRDs", "/test.rrd", "/sub/other.rrd", "/sub/deep/more.rrd"},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	tests := []struct {
+		name     string
+		opts     []ListOption
+		expected []string
+	}{
+		{"plain", nil, []string{"/test.rrd", "/sub/other.rrd"}},
+		{"glob", []ListOption{ListGlob("*/*.rrd")}, []string{"/sub/other.rrd"}},
+		{"regexp", []ListOption{ListRegexp(regexp.MustCompile(`^/test`))}, []string{"/test.rrd"}},
+		{"recursive", []ListOption{ListRecursive()}, []string{"/test.rrd", "/sub/other.rrd", "/sub/deep/more.rrd"}},
+		{"recursive-glob", []ListOption{ListRecursive(), ListGlob("sub/*/*")}, []string{"/sub/deep/more.rrd"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := c.List(context.Background(), "/", tc.opts...)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, l)
+			}
+
+			var streamed []string
+			err = c.ListStream(context.Background(), "/", func(name string) error {
+				streamed = append(streamed, name)
+				return nil
+			}, tc.opts...)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, streamed)
+			}
+		})
+	}
+
+	_, err = c.List(context.Background(), "/", ListGlob("["))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = c.List(context.Background(), "/", ListRegexp(nil))
+	assert.ErrorIs(t, err, ErrNilOption)
+	_, err = c.List(context.Background(), "/", nil)
+	assert.ErrorIs(t, err, ErrNilOption)
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses map[string][]string
+	}{
+		{"recursive", map[string][]string{
+			"list RECURSIVE /": {"3 RRDs", "/a.rrd", "/sub/b.rrd", "/sub/deep/c.rrd"},
+		}},
+		{"fallback", map[string][]string{
+			"list RECURSIVE /": {"-1 No such file: RECURSIVE"},
+			"list /":           {"2 RRDs", "/a.rrd", "/sub/"},
+			"list /sub/":       {"2 RRDs", "/sub/b.rrd", "/sub/deep/"},
+			"list /sub/deep/":  {"1 RRDs", "/sub/deep/c.rrd"},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerStopped(t)
+			if s == nil {
+				return
+			}
+			s.responses = tc.responses
+			s.Start()
+			defer func() {
+				assert.NoError(t, s.Close())
+			}()
+
+			c, err := NewClient(s.Addr, Timeout(time.Second*2))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			var names []string
+			err = c.Walk(context.Background(), "/", func(name string) error {
+				names = append(names, name)
+				return nil
+			})
+			if assert.NoError(t, err) {
+				assert.Equal(t, []string{"/a.rrd", "/sub/b.rrd", "/sub/deep/c.rrd"}, names)
+			}
+
+			names = nil
+			err = c.Walk(context.Background(), "/", func(name string) error {
+				names = append(names, name)
+				return fs.SkipAll
+			}, ListGlob("sub/*"))
+			if assert.NoError(t, err) {
+				assert.Equal(t, []string{"/sub/b.rrd"}, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package rrd
+
+import (
+	"context"
+)
+
+// Suspend requests rrdcached stop writing updates for filename to disk until
+// it's resumed. Updates are still accepted and queued while suspended.
+func (c *Client) Suspend(filename string) error {
+	return c.SuspendWithContext(context.Background(), filename)
+}
+
+// SuspendWithContext requests rrdcached stop writing updates for filename to
+// disk until it's resumed. Updates are still accepted and queued while suspended.
+func (c *Client) SuspendWithContext(ctx context.Context, filename string) error {
+	_, err := c.ExecCmdWithContext(ctx, NewCmd("suspend").WithArgs(filename))
+	return err
+}
+
+// Resume requests rrdcached resume writing updates for filename to disk.
+func (c *Client) Resume(filename string) error {
+	return c.ResumeWithContext(context.Background(), filename)
+}
+
+// ResumeWithContext requests rrdcached resume writing updates for filename to disk.
+func (c *Client) ResumeWithContext(ctx context.Context, filename string) error {
+	_, err := c.ExecCmdWithContext(ctx, NewCmd("resume").WithArgs(filename))
+	return err
+}
+
+// SuspendAll requests rrdcached stop writing updates for all files to disk
+// until they're resumed.
+func (c *Client) SuspendAll() error {
+	return c.SuspendAllWithContext(context.Background())
+}
+
+// SuspendAllWithContext requests rrdcached stop writing updates for all files
+// to disk until they're resumed.
+func (c *Client) SuspendAllWithContext(ctx context.Context) error {
+	_, err := c.ExecWithContext(ctx, "suspendall")
+	return err
+}
+
+// ResumeAll requests rrdcached resume writing updates for all files to disk.
+func (c *Client) ResumeAll() error {
+	return c.ResumeAllWithContext(context.Background())
+}
+
+// ResumeAllWithContext requests rrdcached resume writing updates for all files to disk.
+func (c *Client) ResumeAllWithContext(ctx context.Context) error {
+	_, err := c.ExecWithContext(ctx, "resumeall")
+	return err
+}
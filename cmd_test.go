@@ -2,6 +2,7 @@ package rrd
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -14,6 +15,8 @@ func TestCmd(t *testing.T) {
 	}{
 		{"ping", NewCmd("ping"), "ping"},
 		{"flush", NewCmd("flush").WithArgs("test.rrd"), "flush test.rrd"},
+		{"escaped", NewCmd("flush").WithArgs(`my dir\test.rrd`), `flush my\ dir\\test.rrd`},
+		{"options", NewCmd("create").WithArgs("a b.rrd", Step(time.Minute)), `create a\ b.rrd -s 60`},
 	}
 
 	for _, tc := range tests {
@@ -22,3 +25,55 @@ func TestCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestCmdValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *Cmd
+		err  bool
+	}{
+		{"ok", NewCmd("flush").WithArgs("my test.rrd"), false},
+		{"newline", NewCmd("flush").WithArgs("test.rrd\nflushall"), true},
+		{"return", NewCmd("flush").WithArgs("test.rrd\r"), true},
+		{"nul", NewCmd("flush").WithArgs("test\x00.rrd"), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cmd.validate()
+			if tc.err {
+				assert.ErrorIs(t, err, ErrInvalidArg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCmdFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      *Cmd
+		expected string
+	}{
+		{"update", NewCmd("update").WithArgs("test.rrd", "N:1"), "test.rrd"},
+		{"upper", NewCmd("FLUSH").WithArgs("test.rrd"), "test.rrd"},
+		{"no-args", NewCmd("flush"), ""},
+		{"no-file", NewCmd("stats"), ""},
+		{"list", NewCmd("list").WithArgs("/"), ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cmd.filename())
+		})
+	}
+}
+
+func BenchmarkCmdString(b *testing.B) {
+	cmd := NewCmd("update").WithArgs("my dir/test.rrd", Update("1000000:1.5:2"), Update("1000060:2:U"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = cmd.String()
+	}
+}
@@ -0,0 +1,29 @@
+package rrd
+
+import (
+	"context"
+)
+
+// Tune changes the configuration of the RRD filename as specified by opts.
+// The options are validated before the command is sent.
+func (c *Client) Tune(filename string, opts ...TuneOption) error {
+	return c.TuneWithContext(context.Background(), filename, opts...)
+}
+
+// TuneWithContext changes the configuration of the RRD filename as specified by opts.
+// The options are validated before the command is sent.
+func (c *Client) TuneWithContext(ctx context.Context, filename string, opts ...TuneOption) error {
+	if err := validateTune(opts); err != nil {
+		return err
+	}
+
+	args := make([]interface{}, 0, len(opts)+1)
+	args = append(args, filename)
+	for _, o := range opts {
+		args = append(args, o)
+	}
+
+	defer c.InvalidateInfo(filename)
+	_, err := c.ExecCmdWithContext(ctx, NewCmd("tune").WithArgs(args...))
+	return err
+}
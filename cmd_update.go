@@ -0,0 +1,154 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Update adds samples to filename.
+func (c *Client) Update(filename string, samples ...Sample) error {
+	return c.UpdateWithContext(context.Background(), filename, samples...)
+}
+
+// UpdateWithContext adds samples to filename.
+func (c *Client) UpdateWithContext(ctx context.Context, filename string, samples ...Sample) error {
+	if len(samples) == 0 {
+		return ErrNoSamples
+	}
+
+	values := sampleUpdates(samples)
+	return c.UpdateRawWithContext(ctx, filename, values[0], values[1:]...)
+}
+
+// UpdateNamed adds samples to filename with their values ordered by its
+// data sources, so callers needn't depend on the order they were defined.
+// rrdcached doesn't support update templates so the data sources are
+// looked up with InfoStruct, which may be cached if the client was created
+// with InfoCache. Data sources without a value in a sample are unknown.
+func (c *Client) UpdateNamed(filename string, samples ...NamedSample) error {
+	return c.UpdateNamedWithContext(context.Background(), filename, samples...)
+}
+
+// UpdateNamedWithContext adds samples to filename with their values
+// ordered by its data sources, see UpdateNamed.
+func (c *Client) UpdateNamedWithContext(ctx context.Context, filename string, samples ...NamedSample) error {
+	if len(samples) == 0 {
+		return ErrNoSamples
+	}
+
+	info, err := c.InfoStructWithContext(ctx, filename)
+	if err != nil {
+		return err
+	}
+
+	s := newSampler(info)
+	values := make([]Sample, len(samples))
+	for i, ns := range samples {
+		if values[i], err = s.sample(ns); err != nil {
+			return &CommandError{Cmd: c.cmdString(NewCmd("update").WithArgs(filename)), Err: err}
+		}
+	}
+
+	if err := c.UpdateWithContext(ctx, filename, values...); err != nil {
+		// The RRD may have been recreated with different data sources.
+		c.InvalidateInfo(filename)
+		return err
+	}
+	return nil
+}
+
+// UpdateRaw adds the raw update values to filename.
+func (c *Client) UpdateRaw(filename string, value Update, values ...Update) error {
+	return c.UpdateRawWithContext(context.Background(), filename, value, values...)
+}
+
+// UpdateRawWithContext adds the raw update values to filename.
+// Values which would exceed the clients MaxCommandSize are sent in multiple
+// commands, in order. If one fails the values of the previous commands
+// remain enqueued.
+func (c *Client) UpdateRawWithContext(ctx context.Context, filename string, value Update, values ...Update) error {
+	cmds := updateCmds(filename, value, values, c.maxCommandSize)
+	for _, cmd := range cmds {
+		if err := c.execLines(ctx, cmd, func(lines []string) error {
+			return checkUpdate(lines, len(cmd.args)-1)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateCmd returns a new update command for filename with values.
+func updateCmd(filename string, value Update, values ...Update) *Cmd {
+	args := make([]interface{}, len(values)+2)
+	args[0] = filename
+	args[1] = value
+	for i, v := range values {
+		args[i+2] = v
+	}
+	return NewCmd("update").WithArgs(args...)
+}
+
+// updateCmds returns the update commands for filename with values, split
+// so each command line is at most max bytes, unless a single value exceeds
+// it.
+func updateCmds(filename string, value Update, values []Update, max int) []*Cmd {
+	base := len("update \n") + len(filename) + strings.Count(filename, " ") + strings.Count(filename, `\`)
+	size := base + 1 + len(value)
+	var cmds []*Cmd
+	var start int
+	for i, v := range values {
+		if size+1+len(v) > max {
+			cmds = append(cmds, updateCmd(filename, value, values[start:i]...))
+			value, start, size = v, i+1, base
+		}
+		size += 1 + len(v)
+	}
+	return append(cmds, updateCmd(filename, value, values[start:]...))
+}
+
+// sampleUpdates returns the Update representations of samples.
+func sampleUpdates(samples []Sample) []Update {
+	values := make([]Update, len(samples))
+	for i, s := range samples {
+		values[i] = s.Update()
+	}
+	return values
+}
+
+// checkUpdate checks the response lines of an update of cnt values.
+// On success rrdcached responds with the single message "errors, enqueued
+// <n> value(s).", otherwise the lines describe the values which failed.
+func checkUpdate(lines []string, cnt int) error {
+	if len(lines) == 1 {
+		if m, ok := enqueuedCount(lines[0]); ok {
+			n, err := strconv.Atoi(m)
+			if err != nil {
+				return NewInvalidResponseError("update: invalid enqueued count", lines...)
+			}
+			if n != cnt {
+				return NewError(n-cnt, fmt.Sprintf("update: enqueued %v of %v values", n, cnt))
+			}
+			return nil
+		}
+	}
+
+	return NewError(-len(lines), strings.Join(lines, "\n"))
+}
+
+// enqueuedCount returns the count of the successful update message
+// "errors, enqueued <n> value(s).", with the trailing period optional, and
+// true if l is such a message, false otherwise.
+func enqueuedCount(l string) (string, bool) {
+	n, ok := strings.CutPrefix(l, "errors, enqueued ")
+	if !ok {
+		return "", false
+	}
+	n, ok = strings.CutSuffix(strings.TrimSuffix(n, "."), " value(s)")
+	if !ok || n == "" || strings.Trim(n, "0123456789") != "" {
+		return "", false
+	}
+	return n, true
+}
@@ -0,0 +1,153 @@
+package rrd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckUpdate(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		cnt   int
+		valid bool
+	}{
+		{"ok", []string{"errors, enqueued 1 value(s)."}, 1, true},
+		{"ok-multi", []string{"errors, enqueued 3 value(s)."}, 3, true},
+		{"short", []string{"errors, enqueued 1 value(s)."}, 2, false},
+		{"no-period", []string{"errors, enqueued 2 value(s)"}, 2, true},
+		{"no-count", []string{"errors, enqueued  value(s)."}, 1, false},
+		{"invalid-count", []string{"errors, enqueued x value(s)."}, 1, false},
+		{"error-line", []string{"illegal attempt to update using time 1499968801.000000"}, 1, false},
+		{"error-lines", []string{"bad value 1", "bad value 2"}, 2, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkUpdate(tc.lines, tc.cnt)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestClientUpdateNamed(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"info": {
+			"5 Info for test.rrd follows",
+			"filename 2 test.rrd",
+			"ds[watts].index 1 1",
+			"ds[watts].type 2 GAUGE",
+			"ds[amps].index 1 0",
+			"ds[amps].type 2 GAUGE",
+		},
+		"update test.rrd 1499995020:0.3:10 1499995080:U:11": {"0 errors, enqueued 2 value(s)."},
+		"update": {"-1 No such file"},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), InfoCache(time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.UpdateNamed("test.rrd",
+		NamedSample{Time: time.Unix(1499995020, 0), Values: map[string]float64{"watts": 10, "amps": 0.3}},
+		NamedSample{Time: time.Unix(1499995080, 0), Values: map[string]float64{"watts": 11}},
+	))
+	assert.Equal(t, 1, s.count("info test.rrd"))
+
+	err = c.UpdateNamed("test.rrd", NamedSample{Values: map[string]float64{"volts": 230}})
+	assert.ErrorIs(t, err, ErrUnknownDS)
+	assert.Equal(t, 1, s.count("info test.rrd"))
+
+	// A failed update invalidates the cached data sources.
+	assert.Error(t, c.UpdateNamed("test.rrd", NamedSample{Values: map[string]float64{"watts": 12}}))
+	assert.NoError(t, c.UpdateNamed("test.rrd",
+		NamedSample{Time: time.Unix(1499995020, 0), Values: map[string]float64{"watts": 10, "amps": 0.3}},
+		NamedSample{Time: time.Unix(1499995080, 0), Values: map[string]float64{"watts": 11}},
+	))
+	assert.Equal(t, 2, s.count("info test.rrd"))
+
+	assert.ErrorIs(t, c.UpdateNamed("test.rrd"), ErrNoSamples)
+}
+
+func TestUpdateCmds(t *testing.T) {
+	values := []Update{"1:1", "2:22", "3:333", "4:4444"}
+	tests := []struct {
+		name     string
+		filename string
+		max      int
+		expect   []string
+	}{
+		{"one", "test.rrd", DefaultMaxCommandSize, []string{"update test.rrd 1:1 2:22 3:333 4:4444\n"}},
+		{"exact", "test.rrd", 38, []string{"update test.rrd 1:1 2:22 3:333 4:4444\n"}},
+		{"split", "test.rrd", 37, []string{"update test.rrd 1:1 2:22 3:333\n", "update test.rrd 4:4444\n"}},
+		{"pairs", "test.rrd", 28, []string{"update test.rrd 1:1 2:22\n", "update test.rrd 3:333\n", "update test.rrd 4:4444\n"}},
+		{"escaped", `a b\c.rrd`, 28, []string{`update a\ b\\c.rrd 1:1 2:22` + "\n", `update a\ b\\c.rrd 3:333` + "\n", `update a\ b\\c.rrd 4:4444` + "\n"}},
+		{"oversized", "test.rrd", 1, []string{"update test.rrd 1:1\n", "update test.rrd 2:22\n", "update test.rrd 3:333\n", "update test.rrd 4:4444\n"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cmds := updateCmds(tc.filename, values[0], values[1:], tc.max)
+			lines := make([]string, len(cmds))
+			for i, cmd := range cmds {
+				lines[i] = cmd.String()
+			}
+			assert.Equal(t, tc.expect, lines)
+		})
+	}
+}
+
+func TestClientUpdateChunked(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"update test.rrd 1:1 2:2": {"0 errors, enqueued 2 value(s)."},
+		"update test.rrd 3:3":     {"0 errors, enqueued 1 value(s)."},
+		"batch":                   {"0 Go ahead.  End with dot '.' on its own line."},
+		".":                       {"0 errors"},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), MaxCommandSize(len("update test.rrd 1:1 2:2\n")))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.UpdateRaw("test.rrd", "1:1", "2:2", "3:3"))
+	assert.Equal(t, 2, s.count("update test.rrd"))
+
+	b := c.NewBatch()
+	b.UpdateRaw("test.rrd", "1:1", "2:2", "3:3")
+	assert.Equal(t, 2, b.Len())
+	assert.NoError(t, b.Exec())
+	assert.Equal(t, 4, s.count("update test.rrd"))
+
+	_, err = NewClient(s.Addr, MaxCommandSize(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -16,22 +18,63 @@ var (
 	valueRe = regexp.MustCompile(`^(\w+):\s+(\w+)`)
 )
 
+// maxFetchBinRecords is the maximum number of records of a fetchbin data
+// source, which protects against allocating huge buffers for malformed
+// responses.
+const maxFetchBinRecords = 1 << 24
+
 // Flush requests rrdcached flushed all values pending for filename to disk.
+// It's not an error if filename has no pending values, however if filename
+// doesn't exist the returned error satisfies IsNotExist.
 func (c *Client) Flush(filename string) error {
-	_, err := c.ExecCmd(NewCmd("flush").WithArgs(filename))
+	return c.FlushWithContext(context.Background(), filename)
+}
+
+// FlushWithContext requests rrdcached flushed all values pending for filename to disk.
+// It's not an error if filename has no pending values, however if filename
+// doesn't exist the returned error satisfies IsNotExist.
+func (c *Client) FlushWithContext(ctx context.Context, filename string) error {
+	_, err := c.ExecCmdWithContext(ctx, NewCmd("flush").WithArgs(filename))
 	return err
 }
 
 // FlushAll requests the rrdcached start to flush all pending values to disk.
+// It returns once the flush has been started, not when it completes.
 func (c *Client) FlushAll() error {
-	_, err := c.Exec("flushall")
+	return c.FlushAllWithContext(context.Background())
+}
+
+// FlushAllWithContext requests the rrdcached start to flush all pending values to disk.
+// It returns once the flush has been started, not when it completes.
+func (c *Client) FlushAllWithContext(ctx context.Context) error {
+	_, err := c.ExecWithContext(ctx, "flushall")
 	return err
 }
 
 // Pending returns any "pending" updates for a file, in order.
-func (c *Client) Pending(filename string) ([]string, error) {
-	// TODO(steve): parse if needed when we know what the data looks like.
-	return c.ExecCmd(NewCmd("pending").WithArgs(filename))
+func (c *Client) Pending(filename string) ([]Sample, error) {
+	return c.PendingWithContext(context.Background(), filename)
+}
+
+// PendingWithContext returns any "pending" updates for a file, in order.
+func (c *Client) PendingWithContext(ctx context.Context, filename string) ([]Sample, error) {
+	lines, err := c.ExecCmdWithContext(ctx, NewCmd("pending").WithArgs(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	samples := make([]Sample, len(lines))
+	for i, l := range lines {
+		if samples[i], err = ParseSample(l); err != nil {
+			return nil, err
+		}
+	}
+
+	return samples, nil
 }
 
 // FetchCommon represents the common fields between fetch and fetchbin
@@ -89,7 +132,7 @@ func decodeField(field, val, line string, fv reflect.Value) error {
 			return NewInvalidResponseError(fmt.Sprintf("decodeField: unsupported type %v for field %v", i, field), line)
 		}
 	default:
-		return fmt.Errorf("unsupported type %T for field %v", fv, field)
+		return NewInvalidResponseError(fmt.Sprintf("decodeField: unsupported type %v for field %v", fv.Type(), field), line)
 	}
 
 	return nil
@@ -107,84 +150,206 @@ func decodeTime(field, val, line string, fv reflect.Value) error {
 	return nil
 }
 
-// fetch performs the common action between fetch and fetchbin.
-func (c *Client) fetch(cmd, filename, cf string, r interface{}, options ...interface{}) ([]string, error) {
+// fetch performs the fetch command cmd, decoding the header into r and
+// returning the remaining lines.
+func (c *Client) fetch(ctx context.Context, cmd, filename string, cf ConsolidationFunc, r interface{}, options ...interface{}) ([]string, error) {
+	cf, err := cf.normalize()
+	if err != nil {
+		return nil, err
+	}
+
 	args := append([]interface{}{filename, cf}, options...)
-	lines, err := c.ExecCmd(NewCmd(cmd).WithArgs(args...))
+	lines, err := c.ExecCmdWithContext(ctx, NewCmd(cmd).WithArgs(args...))
 	if err != nil {
-		return nil, fmt.Errorf("failed to exec cmd '%s(%v)': %w", cmd, args, err)
+		return nil, err
 	}
 
-	v := reflect.Indirect(reflect.ValueOf(r))
 	for i, l := range lines {
-		if strings.HasPrefix(l, "DSName:") {
-			r, ok := r.(*Fetch)
-			if !ok {
-				return nil, NewInvalidResponseError(cmd+": unexpected ds name", l)
-			}
-			l = l[7:]
-			l = strings.TrimSpace(l)
-			r.Names = strings.Split(l, " ")
-			if len(r.Names) != r.Count {
-				return nil, NewInvalidResponseError(cmd+": invalid ds name count", l)
-			}
+		done, err := decodeFetchHeader(cmd, r, l)
+		if err != nil {
+			return nil, err
+		}
+		if done {
 			return lines[i+1:], nil
-		} else if strings.HasPrefix(l, "DSName-") {
-			return lines[i:], nil
-		} else if matches := valueRe.FindStringSubmatch(l); len(matches) == 3 {
-			field := strings.TrimPrefix(matches[1], "DS")
-			fv := v.FieldByName(field)
-			if !fv.IsValid() {
-				return nil, NewInvalidResponseError("unknown field", l)
-			}
-			if err := decodeField(field, matches[2], l, fv); err != nil {
-				return nil, err
-			}
 		}
 	}
 
 	return nil, NewInvalidResponseError(cmd+": missing ds name", lines...)
 }
 
+// decodeFetchHeader decodes the fetch header line l into r, returning true
+// once the header is complete.
+func decodeFetchHeader(cmd string, r interface{}, l string) (bool, error) {
+	if strings.HasPrefix(l, "DSName:") {
+		r, ok := r.(*Fetch)
+		if !ok {
+			return false, NewInvalidResponseError(cmd+": unexpected ds name", l)
+		}
+		l = l[7:]
+		l = strings.TrimSpace(l)
+		r.Names = strings.Split(l, " ")
+		if len(r.Names) != r.Count {
+			return false, NewInvalidResponseError(cmd+": invalid ds name count", l)
+		}
+		return true, nil
+	} else if matches := valueRe.FindStringSubmatch(l); len(matches) == 3 {
+		field := strings.TrimPrefix(matches[1], "DS")
+		fv := reflect.Indirect(reflect.ValueOf(r)).FieldByName(field)
+		if !fv.IsValid() || !fv.CanSet() {
+			return false, NewInvalidResponseError("unknown field", l)
+		}
+		if err := decodeField(field, matches[2], l, fv); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// parseFetchRow parses the fetch row l for n data sources.
+func parseFetchRow(l string, n int) (FetchRow, error) {
+	if n < 0 {
+		return FetchRow{}, NewInvalidResponseError(fmt.Sprintf("fetch: invalid ds count %v", n), l)
+	}
+
+	ts, vals, ok := strings.Cut(l, ":")
+	if !ok {
+		return FetchRow{}, NewInvalidResponseError("fetch: unsupported value", l)
+	}
+
+	i, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return FetchRow{}, NewInvalidResponseError("fetch: invalid ds", l)
+	}
+
+	fr := FetchRow{
+		Time: time.Unix(i, 0),
+		Data: make([]*float64, n),
+	}
+	for i, val := range strings.Fields(vals) {
+		if i >= n {
+			return FetchRow{}, NewInvalidResponseError("fetch: too many ds vals", l)
+		}
+		if val == "nan" || val == "-nan" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return FetchRow{}, NewInvalidResponseError("fetch: invalid ds val", l)
+		}
+		fr.Data[i] = &v
+	}
+
+	return fr, nil
+}
+
 // Fetch returns the free text results of a fetch command with the given options.
-func (c *Client) Fetch(filename, cf string, options ...interface{}) (*Fetch, error) {
-	return c.FetchWithContext(context.Background(), filename, cf, options)
+func (c *Client) Fetch(filename string, cf ConsolidationFunc, options ...interface{}) (*Fetch, error) {
+	return c.FetchWithContext(context.Background(), filename, cf, options...)
 }
 
-func (c *Client) FetchWithContext(ctx context.Context, filename, cf string, options ...interface{}) (*Fetch, error) {
+// FetchWithContext returns the free text results of a fetch command with the given options.
+func (c *Client) FetchWithContext(ctx context.Context, filename string, cf ConsolidationFunc, options ...interface{}) (*Fetch, error) {
 	r := &Fetch{}
-	lines, err := c.fetch("fetch", filename, cf, r, options...)
+	lines, err := c.fetch(ctx, "fetch", filename, cf, r, options...)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, l := range lines {
-		parts := strings.SplitN(l, ":", 2)
-		if len(parts) != 2 {
-			return nil, NewInvalidResponseError("fetch: unsupported value", l)
+		fr, err := parseFetchRow(l, len(r.Names))
+		if err != nil {
+			return nil, err
 		}
+		r.Rows = append(r.Rows, fr)
+	}
 
-		i, err := strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			return nil, NewInvalidResponseError("fetch: invalid ds", l)
+	return r, nil
+}
+
+// FetchStream performs a fetch command with the given options calling f
+// for each row as it's read, instead of buffering the entire response.
+// The Fetch passed to f contains the header of the response but no rows.
+func (c *Client) FetchStream(ctx context.Context, filename string, cf ConsolidationFunc, f func(h *Fetch, row FetchRow) error, options ...interface{}) error {
+	cf, err := cf.normalize()
+	if err != nil {
+		return err
+	}
+
+	r := &Fetch{}
+	header := true
+	args := append([]interface{}{filename, cf}, options...)
+	if err := c.ExecCmdStream(ctx, NewCmd("fetch").WithArgs(args...), func(l string) error {
+		if header {
+			done, err := decodeFetchHeader("fetch", r, l)
+			header = !done
+			return err
 		}
 
-		fr := FetchRow{
-			Time: time.Unix(i, 0),
-			Data: make([]*float64, len(r.Names)),
+		fr, err := parseFetchRow(l, len(r.Names))
+		if err != nil {
+			return err
 		}
-		for i, val := range strings.Split(strings.TrimSpace(parts[1]), " ") {
-			if val == "nan" || val == "-nan" {
-				continue
-			}
+		return f(r, fr)
+	}); err != nil {
+		return err
+	}
 
-			v, err := strconv.ParseFloat(val, 64)
-			if err != nil {
-				return nil, NewInvalidResponseError("fetch: invalid ds val", l)
+	if header {
+		return NewInvalidResponseError("fetch: missing ds name")
+	}
+
+	return nil
+}
+
+// FetchResult represents a time series returned by a fetch command.
+// Unknown values are represented as math.NaN().
+// In JSON times are RFC 3339, the step is in seconds and unknown values
+// are null, see also Columns.
+type FetchResult struct {
+	Start time.Time        `json:"start"`
+	End   time.Time        `json:"end"`
+	Step  time.Duration    `json:"step"`
+	Names []string         `json:"names"`
+	Rows  []FetchResultRow `json:"rows"`
+}
+
+// FetchResultRow represents a single row of a FetchResult.
+type FetchResultRow struct {
+	Time   time.Time `json:"time"`
+	Values []float64 `json:"values"`
+}
+
+// FetchRange returns the time series of filename for cf between start and end.
+func (c *Client) FetchRange(filename string, cf ConsolidationFunc, start, end time.Time) (*FetchResult, error) {
+	return c.FetchRangeWithContext(context.Background(), filename, cf, start, end)
+}
+
+// FetchRangeWithContext returns the time series of filename for cf between start and end.
+func (c *Client) FetchRangeWithContext(ctx context.Context, filename string, cf ConsolidationFunc, start, end time.Time) (*FetchResult, error) {
+	f, err := c.FetchWithContext(ctx, filename, cf, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	r := &FetchResult{
+		Start: f.Start,
+		End:   f.End,
+		Step:  f.Step,
+		Names: f.Names,
+		Rows:  make([]FetchResultRow, len(f.Rows)),
+	}
+	for i, row := range f.Rows {
+		vals := make([]float64, len(row.Data))
+		for j, v := range row.Data {
+			if v == nil {
+				vals[j] = math.NaN()
+			} else {
+				vals[j] = *v
 			}
-			fr.Data[i] = &v
 		}
-		r.Rows = append(r.Rows, fr)
+		r.Rows[i] = FetchResultRow{Time: row.Time, Values: vals}
 	}
 
 	return r, nil
@@ -213,17 +378,25 @@ func newFetchBinDS(line string) (*FetchBinDS, error) {
 		return nil, NewInvalidResponseError("fetchbin: row invalid ds", line)
 	}
 
+	name, ok := strings.CutPrefix(parts[0], "DSName-")
+	if !ok {
+		return nil, NewInvalidResponseError("fetchbin: row invalid ds name", line)
+	}
+	if r.Name, ok = strings.CutSuffix(name, ":"); !ok || r.Name == "" {
+		return nil, NewInvalidResponseError("fetchbin: row invalid ds name", line)
+	}
+
 	v, err := strconv.Atoi(parts[2])
-	if err != nil {
+	if err != nil || v < 0 || v > maxFetchBinRecords {
 		return nil, NewInvalidResponseError("fetchbin: row invalid records", line)
 	}
 	r.Records = v
 
-	if v, err = strconv.Atoi(parts[3]); err != nil {
+	if v, err = strconv.Atoi(parts[3]); err != nil || (v != 4 && v != 8) {
 		return nil, NewInvalidResponseError("fetchbin: row invalid size", line)
 	}
 	r.Size = v
-	r.Name = parts[0][7 : len(parts[0])-1]
+
 	switch parts[4] {
 	case "LITTLE":
 		r.Endian = binary.LittleEndian
@@ -237,70 +410,100 @@ func newFetchBinDS(line string) (*FetchBinDS, error) {
 }
 
 // FetchBin returns the text/binary results of a fetch command with the given options.
-func (c *Client) FetchBin(filename, cf string, options ...interface{}) (*FetchBin, error) {
-	r := &FetchBin{}
-	lines, err := c.fetch("fetchbin", filename, cf, r, options...)
+func (c *Client) FetchBin(filename string, cf ConsolidationFunc, options ...interface{}) (*FetchBin, error) {
+	return c.FetchBinWithContext(context.Background(), filename, cf, options...)
+}
+
+// FetchBinWithContext returns the text/binary results of a fetch command with the given options.
+func (c *Client) FetchBinWithContext(ctx context.Context, filename string, cf ConsolidationFunc, options ...interface{}) (*FetchBin, error) {
+	cf, err := cf.normalize()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(lines) != r.Count {
-		return nil, NewInvalidResponseError("fetchbin: invalid ds count", lines...)
-	}
-
-	// The line count is actually wrong for fetchbin. We get at least 2 lines per DS,
-	// so we need to manually read more.
-	if err = c.ensureLines(&lines, r.Count*2); err != nil {
-		return nil, err
-	}
+	// The binary data is read from the connection the command is sent on.
+	lc, done := c.pick()
+	defer done()
 
-	var ds *FetchBinDS
-	r.DS = make([]*FetchBinDS, r.Count)
-	for i := 0; i < r.Count; i++ {
-		if err = c.ensureLines(&lines, 2); err != nil {
-			return nil, err
+	r := &FetchBin{}
+	args := append([]interface{}{filename, cf}, options...)
+	// The response line count only includes the DS header lines, each of
+	// which is followed by its binary data, which is read directly.
+	if err := lc.ExecCmdStream(ctx, NewCmd("fetchbin").WithArgs(args...), func(l string) error {
+		if !strings.HasPrefix(l, "DSName-") {
+			if len(r.DS) > 0 {
+				return NewInvalidResponseError("fetchbin: unexpected line", l)
+			}
+			_, err := decodeFetchHeader("fetchbin", r, l)
+			return err
 		}
 
-		if ds, err = newFetchBinDS(lines[0]); err != nil {
-			return nil, err
+		ds, err := newFetchBinDS(l)
+		if err != nil {
+			return err
 		}
-		r.DS[i] = ds
-
-		// Rebuild data from lines
-		data := []byte(lines[1])
-		lines = lines[2:]
-		for wanted := ds.Records * ds.Size; len(data) < wanted; {
-			if err := c.ensureLines(&lines, 1); err != nil {
-				return nil, err
-			}
-			data = append(data, '\n')
-			data = append(data, lines[0]...)
-			lines = lines[1:]
+
+		data, err := lc.readBinary(ctx, ds.Records*ds.Size)
+		if err != nil {
+			return err
 		}
 
-		if err := c.readBin(ds, data); err != nil {
-			return nil, err
+		if err := lc.readBin(ds, data); err != nil {
+			return err
 		}
+		r.DS = append(r.DS, ds)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(r.DS) != r.Count {
+		return nil, NewInvalidResponseError(fmt.Sprintf("fetchbin: invalid ds count %v", len(r.DS)))
 	}
 
 	return r, nil
 }
 
-// ensureLines ensures there's at least cnt in lines.
-func (c *Client) ensureLines(lines *[]string, cnt int) error {
-	for len(*lines) < cnt {
-		if err := c.setDeadline(); err != nil {
-			return err
+// Float64s returns the data of d as float64s.
+func (d *FetchBinDS) Float64s() []float64 {
+	vals := make([]float64, len(d.Data))
+	for i, v := range d.Data {
+		switch v := v.(type) {
+		case float64:
+			vals[i] = v
+		case float32:
+			vals[i] = float64(v)
 		}
+	}
+	return vals
+}
 
-		if !c.scanner.Scan() {
-			return c.scanErr()
+// Rows returns the data of r as rows, in the same form as FetchRange.
+// Missing values, where a data source has fewer records than others, are
+// represented as math.NaN().
+func (r *FetchBin) Rows() []FetchResultRow {
+	var n int
+	vals := make([][]float64, len(r.DS))
+	for i, ds := range r.DS {
+		vals[i] = ds.Float64s()
+		if len(vals[i]) > n {
+			n = len(vals[i])
 		}
-
-		*lines = append(*lines, c.scanner.Text())
 	}
 
-	return nil
+	rows := make([]FetchResultRow, n)
+	for i := range rows {
+		rows[i].Time = r.Start.Add(r.Step * time.Duration(i+1))
+		rows[i].Values = make([]float64, len(vals))
+		for j, v := range vals {
+			if i < len(v) {
+				rows[i].Values[j] = v[i]
+			} else {
+				rows[i].Values[j] = math.NaN()
+			}
+		}
+	}
+	return rows
 }
 
 // readBin reads binary as specified in ds.
@@ -333,20 +536,36 @@ func (c *Client) readBin(ds *FetchBinDS, data []byte) error {
 
 // Forget requests rrdcached remove filename from the cache.
 // Any pending updates WILL BE LOST.
+// If filename isn't in the cache the returned error matches ErrNotFound.
 func (c *Client) Forget(filename string) error {
-	_, err := c.ExecCmd(NewCmd("forget").WithArgs(filename))
+	return c.ForgetWithContext(context.Background(), filename)
+}
+
+// ForgetWithContext requests rrdcached remove filename from the cache.
+// Any pending updates WILL BE LOST.
+// If filename isn't in the cache the returned error matches ErrNotFound.
+func (c *Client) ForgetWithContext(ctx context.Context, filename string) error {
+	defer c.InvalidateInfo(filename)
+	_, err := c.ExecCmdWithContext(ctx, NewCmd("forget").WithArgs(filename))
 	return err
 }
 
-// Queue represents a file queue.
-type Queue struct {
-	Size int64
-	File string
+// QueueEntry represents a file on the rrdcached output queue.
+type QueueEntry struct {
+	File string `json:"file"`
+
+	// Pending is the number of values pending for File.
+	Pending int64 `json:"pending"`
 }
 
 // Queue returns the files that are on the rrdcached output queue.
-func (c *Client) Queue(filename string) ([]*Queue, error) {
-	lines, err := c.ExecCmd(NewCmd("queue").WithArgs(filename))
+func (c *Client) Queue() ([]QueueEntry, error) {
+	return c.QueueWithContext(context.Background())
+}
+
+// QueueWithContext returns the files that are on the rrdcached output queue.
+func (c *Client) QueueWithContext(ctx context.Context) ([]QueueEntry, error) {
+	lines, err := c.ExecWithContext(ctx, "queue")
 	if err != nil {
 		return nil, err
 	}
@@ -355,7 +574,7 @@ func (c *Client) Queue(filename string) ([]*Queue, error) {
 		return nil, nil
 	}
 
-	queued := make([]*Queue, len(lines))
+	queued := make([]QueueEntry, len(lines))
 	for i, l := range lines {
 		parts := strings.SplitN(l, " ", 2)
 		if len(parts) != 2 {
@@ -366,7 +585,7 @@ func (c *Client) Queue(filename string) ([]*Queue, error) {
 			return nil, NewInvalidResponseError("queue: invalid num", l)
 		}
 
-		queued[i] = &Queue{Size: v, File: strings.TrimSpace(parts[1])}
+		queued[i] = QueueEntry{File: strings.TrimSpace(parts[1]), Pending: v}
 	}
 
 	return queued, nil
@@ -374,32 +593,43 @@ func (c *Client) Queue(filename string) ([]*Queue, error) {
 
 // Help returns command help.
 func (c *Client) Help(cmd ...string) ([]string, error) {
+	return c.HelpWithContext(context.Background(), cmd...)
+}
+
+// HelpWithContext returns command help.
+func (c *Client) HelpWithContext(ctx context.Context, cmd ...string) ([]string, error) {
 	switch len(cmd) {
 	case 0:
-		return c.Exec("help")
+		return c.ExecWithContext(ctx, "help")
 	case 1:
-		return c.ExecCmd(NewCmd("help").WithArgs(cmd[0]))
+		return c.ExecCmdWithContext(ctx, NewCmd("help").WithArgs(cmd[0]))
 	default:
 		return nil, fmt.Errorf("more than one cmd specified")
 	}
 }
 
 // Stats represents rrdcached stats.
+// Stats reported by the server which aren't represented are ignored.
 type Stats struct {
-	QueueLength     int64
-	UpdatesReceived int64
-	FlushesReceived int64
-	UpdatesWritten  int64
-	DataSetsWritten int64
-	TreeNodesNumber int64
-	TreeDepth       int64
-	JournalBytes    int64
-	JournalRotate   int64
+	QueueLength     int64 `json:"queue_length"`
+	UpdatesReceived int64 `json:"updates_received"`
+	FlushesReceived int64 `json:"flushes_received"`
+	UpdatesWritten  int64 `json:"updates_written"`
+	DataSetsWritten int64 `json:"data_sets_written"`
+	TreeNodesNumber int64 `json:"tree_nodes_number"`
+	TreeDepth       int64 `json:"tree_depth"`
+	JournalBytes    int64 `json:"journal_bytes"`
+	JournalRotate   int64 `json:"journal_rotate"`
 }
 
 // Stats returns stats about rrdcached.
 func (c *Client) Stats() (*Stats, error) {
-	lines, err := c.Exec("stats")
+	return c.StatsWithContext(context.Background())
+}
+
+// StatsWithContext returns stats about rrdcached.
+func (c *Client) StatsWithContext(ctx context.Context) (*Stats, error) {
+	lines, err := c.ExecWithContext(ctx, "stats")
 	if err != nil {
 		return nil, err
 	}
@@ -425,31 +655,37 @@ func (c *Client) Stats() (*Stats, error) {
 
 // Ping sends a ping to the server.
 func (c *Client) Ping() error {
-	_, err := c.Exec("ping")
-	return err
+	return c.PingWithContext(context.Background())
 }
 
-// Update adds more data to filename.
-func (c *Client) Update(filename string, value Update, values ...Update) error {
-	args := make([]interface{}, len(values)+2)
-	args[0] = filename
-	args[1] = value
-	for i, v := range values {
-		args[i+2] = v
-	}
-	_, err := c.ExecCmd(NewCmd("update").WithArgs(args...))
+// PingWithContext sends a ping to the server.
+func (c *Client) PingWithContext(ctx context.Context) error {
+	_, err := c.ExecWithContext(ctx, "ping")
 	return err
 }
 
 // Wrote sends a wrote command for filename to rrdcached.
 func (c *Client) Wrote(filename string) error {
-	_, err := c.ExecCmd(NewCmd("wrote").WithArgs(filename))
+	return c.WroteWithContext(context.Background(), filename)
+}
+
+// WroteWithContext sends a wrote command for filename to rrdcached.
+func (c *Client) WroteWithContext(ctx context.Context, filename string) error {
+	_, err := c.ExecCmdWithContext(ctx, NewCmd("wrote").WithArgs(filename))
 	return err
 }
 
 // First returns the timestamp of the first CDP for the given RRA.
 func (c *Client) First(filename string, rra int) (time.Time, error) {
-	return c.parseTime(c.ExecCmd(NewCmd("first").WithArgs(filename, rra)))
+	return c.FirstWithContext(context.Background(), filename, rra)
+}
+
+// FirstWithContext returns the timestamp of the first CDP for the given RRA.
+func (c *Client) FirstWithContext(ctx context.Context, filename string, rra int) (time.Time, error) {
+	if rra < 0 {
+		return time.Time{}, fmt.Errorf("first: %w %v", ErrInvalidRRAIndex, rra)
+	}
+	return c.parseTime(c.ExecCmdWithContext(ctx, NewCmd("first").WithArgs(filename, rra)))
 }
 
 // partsTime parses the time stored in the first line and returns it
@@ -473,85 +709,44 @@ func (c *Client) parseTime(lines []string, err error) (time.Time, error) {
 
 // Last returns the timestamp of the last update to the specified RRD.
 func (c *Client) Last(filename string) (time.Time, error) {
-	return c.parseTime(c.ExecCmd(NewCmd("last").WithArgs(filename)))
+	return c.LastWithContext(context.Background(), filename)
 }
 
-// Create creates the RRD according to the supplied parameters.
-func (c *Client) Create(filename string, ds []DS, rra []RRA, options ...CreateOption) error {
-	args := []interface{}{filename}
-	for _, v := range options {
-		args = append(args, v)
-	}
-	for _, v := range ds {
-		args = append(args, v)
-	}
-	for _, v := range rra {
-		args = append(args, v)
-	}
-	_, err := c.ExecCmd(NewCmd("create").WithArgs(args...))
-	return err
+// LastWithContext returns the timestamp of the last update to the specified RRD.
+func (c *Client) LastWithContext(ctx context.Context, filename string) (time.Time, error) {
+	return c.parseTime(c.ExecCmdWithContext(ctx, NewCmd("last").WithArgs(filename)))
 }
 
-// Batch initiates the bulk load of multiple commands.
-func (c *Client) Batch(cmds ...*Cmd) error {
-	_, err := c.Exec("batch")
-	if err != nil {
-		return err
-	}
-
-	lines := make([]string, len(cmds)+1)
-	for i, c := range cmds {
-		lines[i] = c.String()
-	}
-	lines[len(cmds)] = ".\n"
-
-	if err = c.setDeadline(); err != nil {
-		return err
-	}
-
-	if _, err = c.conn.Write([]byte(strings.Join(lines, ""))); err != nil {
-		return err
-	}
-
-	if err = c.setDeadline(); err != nil {
-		return err
-	}
-
-	if !c.scanner.Scan() {
-		return c.scanErr()
-	}
-
-	l := c.scanner.Text()
-	matches := respRe.FindStringSubmatch(l)
-	if len(matches) != 3 {
-		return NewInvalidResponseError("batch: invalid matches", l)
+// FlushMany requests rrdcached flush all values pending for filenames to disk
+// using a single batch instead of one round trip per file.
+// The returned map contains an entry for each file which failed to flush, a
+// failure of one file does not prevent the others from being flushed.
+// The error is only non-nil if the batch as a whole failed.
+func (c *Client) FlushMany(ctx context.Context, filenames []string) (map[string]error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	cnt, err := strconv.Atoi(matches[1])
-	if err != nil {
-		// This should be impossible given the regexp matched.
-		return NewInvalidResponseError("batch: invalid count", l)
+	errs := make(map[string]error)
+	if len(filenames) == 0 {
+		return errs, nil
 	}
 
-	if cnt == 0 {
-		return nil
+	cmds := make([]*Cmd, len(filenames))
+	for i, f := range filenames {
+		cmds[i] = NewCmd("flush").WithArgs(f)
 	}
 
-	if err := c.setDeadline(); err != nil {
-		return err
-	}
-	rlines := make([]string, 0, cnt)
-	for c.scanner.Scan() && len(rlines) < cnt {
-		rlines = append(rlines, c.scanner.Text())
-		if err := c.setDeadline(); err != nil {
-			return err
+	err := c.batch(ctx, cmds...)
+	var berr *BatchError
+	switch {
+	case errors.As(err, &berr):
+		for _, e := range berr.Errors {
+			errs[filenames[e.Index]] = e.Err
 		}
+	case err != nil:
+		return nil, err
 	}
 
-	if len(rlines) != cnt {
-		// Short response.
-		return c.scanErr()
-	}
-
-	return NewError(0-cnt, strings.Join(rlines, "\n"))
+	return errs, nil
 }
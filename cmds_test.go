@@ -1,7 +1,10 @@
 package rrd
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
+	"math"
 	"testing"
 	"time"
 
@@ -40,6 +43,11 @@ func TestCmds(t *testing.T) {
 		assert.NoError(t, c.Flush("test.rrd"))
 	}
 
+	flushInvalid := func(t *testing.T) {
+		assert.ErrorIs(t, c.Flush("test.rrd\nflushall"), ErrInvalidArg)
+		assert.ErrorIs(t, c.Batch(NewCmd("flush").WithArgs("test.rrd\n")), ErrInvalidArg)
+	}
+
 	flushall := func(t *testing.T) {
 		assert.NoError(t, c.FlushAll())
 	}
@@ -79,6 +87,58 @@ func TestCmds(t *testing.T) {
 		assert.Equal(t, expected, f)
 	}
 
+	fetchRange := func(t *testing.T) {
+		start, end := time.Unix(1499908800, 0), time.Unix(1499995500, 0)
+		f, err := c.FetchRange("test.rrd", Average, start, end)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, start, f.Start)
+		assert.Equal(t, end, f.End)
+		assert.Equal(t, time.Minute*5, f.Step)
+		assert.Equal(t, []string{"watts", "amps"}, f.Names)
+		if !assert.Len(t, f.Rows, 2) {
+			return
+		}
+		assert.Equal(t, time.Unix(1499909100, 0), f.Rows[0].Time)
+		assert.Equal(t, []float64{8, 1733.3512369791667}, f.Rows[0].Values)
+		assert.Equal(t, time.Unix(1499909400, 0), f.Rows[1].Time)
+		if assert.Len(t, f.Rows[1].Values, 2) {
+			assert.True(t, math.IsNaN(f.Rows[1].Values[0]))
+			assert.True(t, math.IsNaN(f.Rows[1].Values[1]))
+		}
+	}
+
+	fetchStream := func(t *testing.T) {
+		var rows []FetchRow
+		err := c.FetchStream(context.Background(), "test.rrd", Average, func(h *Fetch, row FetchRow) error {
+			assert.Equal(t, []string{"watts", "amps"}, h.Names)
+			assert.Equal(t, time.Minute*5, h.Step)
+			rows = append(rows, row)
+			return nil
+		})
+		if !assert.NoError(t, err) || !assert.Len(t, rows, 2) {
+			return
+		}
+		assert.Equal(t, time.Unix(1499909100, 0), rows[0].Time)
+		assert.Equal(t, []*float64{nil, nil}, rows[1].Data)
+
+		errStop := errors.New("stop")
+		err = c.FetchStream(context.Background(), "test.rrd", Average, func(h *Fetch, row FetchRow) error {
+			return errStop
+		})
+		assert.ErrorIs(t, err, errStop)
+		assert.NoError(t, c.Ping())
+	}
+
+	fetchInvalidCF := func(t *testing.T) {
+		_, err := c.Fetch("test.rrd", "AVG")
+		assert.True(t, errors.Is(err, ErrInvalidCF))
+
+		_, err = c.FetchBin("test.rrd", "AVG")
+		assert.True(t, errors.Is(err, ErrInvalidCF))
+	}
+
 	fetchbin := func(t *testing.T) {
 		f, err := c.FetchBin("test.rrd", Average)
 		if !assert.NoError(t, err) {
@@ -111,6 +171,18 @@ func TestCmds(t *testing.T) {
 		}
 
 		assert.Equal(t, expected, f)
+
+		rows := f.Rows()
+		if assert.Len(t, rows, 2) {
+			assert.Equal(t, time.Unix(1499909100, 0), rows[0].Time)
+			assert.Equal(t, []float64{5.432309224871e-311, 0}, rows[0].Values)
+			assert.Equal(t, time.Unix(1499909400, 0), rows[1].Time)
+			assert.Equal(t, float64(0), rows[1].Values[0])
+			assert.True(t, math.IsNaN(rows[1].Values[1]))
+		}
+
+		// The connection must still be in sync after the binary data.
+		assert.NoError(t, c.Ping())
 	}
 
 	forget := func(t *testing.T) {
@@ -118,12 +190,12 @@ func TestCmds(t *testing.T) {
 	}
 
 	queue := func(t *testing.T) {
-		q, err := c.Queue("test.rrd")
+		q, err := c.Queue()
 		if !assert.NoError(t, err) {
 			return
 		}
-		expected := []*Queue{
-			{Size: 10, File: "test.rrd"},
+		expected := []QueueEntry{
+			{File: "test.rrd", Pending: 10},
 		}
 		assert.Equal(t, expected, q)
 	}
@@ -156,7 +228,9 @@ func TestCmds(t *testing.T) {
 	}
 
 	update := func(t *testing.T) {
-		assert.NoError(t, c.Update("test.rrd", "1499968801:U"))
+		assert.NoError(t, c.UpdateRaw("test.rrd", "1499968801:U"))
+		assert.NoError(t, c.Update("test.rrd", Sample{Time: time.Unix(1499968801, 0), Values: []float64{math.NaN()}}))
+		assert.Equal(t, ErrNoSamples, c.Update("test.rrd"))
 	}
 
 	wrote := func(t *testing.T) {
@@ -170,6 +244,9 @@ func TestCmds(t *testing.T) {
 		}
 
 		assert.Equal(t, time.Unix(1240782000, 0), ts)
+
+		_, err = c.First("test.rrd", -1)
+		assert.ErrorIs(t, err, ErrInvalidRRAIndex)
 	}
 
 	last := func(t *testing.T) {
@@ -203,6 +280,31 @@ func TestCmds(t *testing.T) {
 		assert.Equal(t, expected, i)
 	}
 
+	infoStruct := func(t *testing.T) {
+		i, err := c.InfoStruct("test.rrd")
+		if !assert.NoError(t, err) {
+			return
+		}
+		expected := &RRDInfo{
+			Filename:   "test.rrd",
+			Version:    "0003",
+			Step:       time.Minute * 5,
+			LastUpdate: time.Unix(1499981928, 0),
+			HeaderSize: 1760,
+			DS: map[string]DSInfo{
+				"watts": {
+					Name:             "watts",
+					Type:             "GAUGE",
+					MinimalHeartbeat: time.Minute * 5,
+					Max:              24000,
+					LastDS:           "U",
+					UnknownSec:       228,
+				},
+			},
+		}
+		assert.Equal(t, expected, i)
+	}
+
 	create := func(t *testing.T) {
 		err := c.Create(
 			"test.rrd",
@@ -210,6 +312,44 @@ func TestCmds(t *testing.T) {
 			[]RRA{NewRRA("RRA:AVERAGE:0.5:1:864000")},
 		)
 		assert.NoError(t, err)
+
+		err = c.Create(
+			"test.rrd",
+			[]DS{NewDS("DS:watts:GAUGE:300:0:24000")},
+			[]RRA{NewRRA("RRA:AVG:0.5:1:864000")},
+		)
+		assert.True(t, errors.Is(err, ErrInvalidCF))
+	}
+
+	tune := func(t *testing.T) {
+		assert.NoError(t, c.Tune("test.rrd", TuneHeartbeat("watts", time.Minute*10), TuneMax("watts", 30000)))
+		assert.ErrorIs(t, c.Tune("test.rrd"), ErrNoTuneOptions)
+	}
+
+	suspend := func(t *testing.T) {
+		assert.NoError(t, c.Suspend("test.rrd"))
+		assert.ErrorIs(t, c.Resume("test.rrd"), ErrNotFound)
+		assert.NoError(t, c.SuspendAll())
+		assert.NoError(t, c.ResumeAll())
+	}
+
+	list := func(t *testing.T) {
+		l, err := c.List(context.Background(), "/")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"/test.rrd", "/sub/other.rrd"}, l)
+	}
+
+	listStream := func(t *testing.T) {
+		var l []string
+		err := c.ListStream(context.Background(), "/", func(name string) error {
+			l = append(l, name)
+			return nil
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"/test.rrd", "/sub/other.rrd"}, l)
+		}
 	}
 
 	batch := func(t *testing.T) {
@@ -217,7 +357,16 @@ func TestCmds(t *testing.T) {
 		if !assert.Error(t, err) {
 			return
 		}
-		assert.Equal(t, "1 Can't use 'ping' here.\n2 Can't use 'ping' here. (-2)", err.Error())
+		assert.Equal(t, "batch: 2 command(s) failed: [0] ping: Can't use 'ping' here. (-1); [1] ping: Can't use 'ping' here. (-1)", err.Error())
+
+		var berr *BatchError
+		if assert.True(t, errors.As(err, &berr)) {
+			expected := []BatchCmdError{
+				{Index: 0, Cmd: "ping", Err: NewError(-1, "Can't use 'ping' here.")},
+				{Index: 1, Cmd: "ping", Err: NewError(-1, "Can't use 'ping' here.")},
+			}
+			assert.Equal(t, expected, berr.Errors)
+		}
 	}
 
 	tests := []struct {
@@ -227,9 +376,13 @@ func TestCmds(t *testing.T) {
 		{"invalid", invalid},
 		{"ping", ping},
 		{"flush", flush},
+		{"flush-invalid", flushInvalid},
 		{"flushall", flushall},
 		{"pending", pending},
 		{"fetch", fetch},
+		{"fetch-range", fetchRange},
+		{"fetch-stream", fetchStream},
+		{"fetch-invalid-cf", fetchInvalidCF},
 		{"fetchbin", fetchbin},
 		{"forget", forget},
 		{"queue", queue},
@@ -241,7 +394,12 @@ func TestCmds(t *testing.T) {
 		{"first", first},
 		{"last", last},
 		{"info", info},
+		{"info-struct", infoStruct},
 		{"create", create},
+		{"tune", tune},
+		{"suspend", suspend},
+		{"list", list},
+		{"list-stream", listStream},
 		{"batch", batch},
 	}
 
@@ -249,3 +407,340 @@ func TestCmds(t *testing.T) {
 		t.Run(tc.name, tc.f)
 	}
 }
+
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		err      func(error) bool
+	}{
+		{"flushed", "0 Successfully flushed /test.rrd.", nil},
+		{"nothing", "0 Nothing to flush: /test.rrd.", nil},
+		{"missing", "-1 No such file: /test.rrd.", IsNotExist},
+		{"internal", "-1 Internal error.", func(err error) bool {
+			var rerr *Error
+			return errors.As(err, &rerr) && rerr.Msg == "Internal error." && !IsNotExist(err)
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerStopped(t)
+			if s == nil {
+				return
+			}
+			s.responses = map[string][]string{"flush": {tc.response}}
+			s.Start()
+			defer func() {
+				assert.NoError(t, s.Close())
+			}()
+
+			c, err := NewClient(s.Addr, Timeout(time.Second*2))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			err = c.Flush("test.rrd")
+			if tc.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, tc.err(err), "unexpected error %v", err)
+			assert.Contains(t, err.Error(), "test.rrd")
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"pending": {
+			"2 updates pending",
+			"1499968801:10:U",
+			"1499968861:12:3.5",
+		},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	p, err := c.Pending("test.rrd")
+	if !assert.NoError(t, err) || !assert.Len(t, p, 2) {
+		return
+	}
+	assert.Equal(t, time.Unix(1499968801, 0), p[0].Time)
+	if assert.Len(t, p[0].Values, 2) {
+		assert.Equal(t, float64(10), p[0].Values[0])
+		assert.True(t, math.IsNaN(p[0].Values[1]))
+	}
+	assert.Equal(t, Sample{Time: time.Unix(1499968861, 0), Values: []float64{12, 3.5}}, p[1])
+}
+
+func TestForget(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{"forget": {"-1 No such file or directory"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	err = c.Forget("test.rrd")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.True(t, IsNotExist(err))
+
+	// The connection is still usable after a server error.
+	assert.NoError(t, c.Ping())
+}
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		response []string
+		expected *Stats
+		err      bool
+	}{
+		{"unknown", []string{"2 Statistics follow", "QueueLength: 3", "FutureStat: 7"}, &Stats{QueueLength: 3}, false},
+		{"invalid-value", []string{"1 Statistics follow", "QueueLength: x"}, nil, true},
+		{"invalid-line", []string{"1 Statistics follow", "QueueLength"}, nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerStopped(t)
+			if s == nil {
+				return
+			}
+			s.responses = map[string][]string{"stats": tc.response}
+			s.Start()
+			defer func() {
+				assert.NoError(t, s.Close())
+			}()
+
+			c, err := NewClient(s.Addr, Timeout(time.Second*2))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			st, err := c.Stats()
+			if tc.err {
+				var ierr *InvalidResponseError
+				assert.True(t, errors.As(err, &ierr), "unexpected error %v", err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, st)
+			}
+		})
+	}
+}
+
+func TestFlushMany(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		".": {
+			"1 errors",
+			"2 No such file: /missing.rrd",
+		},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	errs, err := c.FlushMany(context.Background(), []string{"a.rrd", "missing.rrd", "c.rrd"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, errs, 1) {
+		assert.True(t, IsNotExist(errs["missing.rrd"]))
+	}
+
+	errs, err = c.FlushMany(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+
+	// The connection must still be in sync after the batch.
+	assert.NoError(t, c.Ping())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.FlushMany(ctx, []string{"a.rrd"})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestParseFetchRow(t *testing.T) {
+	one, two := 1.0, 2.0
+	tests := []struct {
+		name   string
+		line   string
+		n      int
+		expect []*float64
+		err    bool
+	}{
+		{name: "valid", line: "1500000000: 1.0000000000e+00 2.0000000000e+00", n: 2, expect: []*float64{&one, &two}},
+		{name: "nan", line: "1500000000: nan -nan", n: 2, expect: []*float64{nil, nil}},
+		{name: "spaces", line: "1500000000:  1  2 ", n: 2, expect: []*float64{&one, &two}},
+		{name: "truncated", line: "1500000000: 1", n: 2, expect: []*float64{&one, nil}},
+		{name: "too-many", line: "1500000000: 1 2", n: 1, err: true},
+		{name: "no-colon", line: "1500000000 1 2", n: 2, err: true},
+		{name: "time", line: "now: 1 2", n: 2, err: true},
+		{name: "value", line: "1500000000: one", n: 1, err: true},
+		{name: "count", line: "1500000000: 1", n: -1, err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fr, err := parseFetchRow(tc.line, tc.n)
+			if tc.err {
+				var ierr *InvalidResponseError
+				assert.True(t, errors.As(err, &ierr))
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, time.Unix(1500000000, 0), fr.Time)
+				assert.Equal(t, tc.expect, fr.Data)
+			}
+		})
+	}
+}
+
+func FuzzParseFetchRow(f *testing.F) {
+	f.Add("1500000000: 1.0000000000e+00 nan", 2)
+	f.Add("1500000000: -nan", 1)
+	f.Add(":", 0)
+	f.Add("1: 1", -1)
+
+	f.Fuzz(func(t *testing.T, line string, n int) {
+		if n > 1024 {
+			n %= 1024
+		}
+		fr, err := parseFetchRow(line, n)
+		if err != nil {
+			var ierr *InvalidResponseError
+			assert.True(t, errors.As(err, &ierr))
+			return
+		}
+		assert.Len(t, fr.Data, n)
+	})
+}
+
+func TestNewFetchBinDS(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		expect *FetchBinDS
+	}{
+		{name: "little", line: "DSName-watts: BinaryData 2 8 LITTLE", expect: &FetchBinDS{Name: "watts", Records: 2, Size: 8, Endian: binary.LittleEndian}},
+		{name: "big", line: "DSName-amps: BinaryData 0 4 BIG", expect: &FetchBinDS{Name: "amps", Records: 0, Size: 4, Endian: binary.BigEndian}},
+		{name: "short", line: "DS: BinaryData 2 8 LITTLE"},
+		{name: "prefix", line: "DSNamewatts: BinaryData 2 8 LITTLE"},
+		{name: "suffix", line: "DSName-watts BinaryData 2 8 LITTLE"},
+		{name: "empty-name", line: "DSName-: BinaryData 2 8 LITTLE"},
+		{name: "fields", line: "DSName-watts: BinaryData 2 8"},
+		{name: "negative-records", line: "DSName-watts: BinaryData -1 8 LITTLE"},
+		{name: "huge-records", line: "DSName-watts: BinaryData 9223372036854775807 8 LITTLE"},
+		{name: "size", line: "DSName-watts: BinaryData 2 3 LITTLE"},
+		{name: "endian", line: "DSName-watts: BinaryData 2 8 MIDDLE"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ds, err := newFetchBinDS(tc.line)
+			if tc.expect == nil {
+				var ierr *InvalidResponseError
+				assert.True(t, errors.As(err, &ierr))
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expect, ds)
+			}
+		})
+	}
+}
+
+func FuzzNewFetchBinDS(f *testing.F) {
+	f.Add("DSName-watts: BinaryData 2 8 LITTLE")
+	f.Add("DSName-: BinaryData -1 4 BIG")
+	f.Add("DSName- a b c")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		ds, err := newFetchBinDS(line)
+		if err != nil {
+			var ierr *InvalidResponseError
+			assert.True(t, errors.As(err, &ierr))
+			return
+		}
+		assert.NotEmpty(t, ds.Name)
+		assert.True(t, ds.Size == 4 || ds.Size == 8)
+		assert.True(t, ds.Records >= 0 && ds.Records <= maxFetchBinRecords)
+	})
+}
+
+func FuzzDecodeFetchHeader(f *testing.F) {
+	for _, l := range []string{
+		"FlushVersion: 1",
+		"Start: 1500000000",
+		"Step: 300",
+		"DSCount: 2",
+		"DSName: watts amps",
+		"DSName:",
+		"Names: x",
+		"Unknown: 1",
+	} {
+		f.Add(l)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		for _, r := range []interface{}{&Fetch{FetchCommon: FetchCommon{Count: 2}}, &FetchBin{}} {
+			if _, err := decodeFetchHeader("fetch", r, line); err != nil {
+				var ierr *InvalidResponseError
+				assert.True(t, errors.As(err, &ierr), err)
+			}
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package rrd
+
+// coalescer holds updates coalesced per file, in the order each file was
+// first added, as used by the writers which send them as a batch.
+// The zero value is an empty coalescer ready to use.
+type coalescer struct {
+	files  []string
+	values map[string][]Update
+	n      int
+}
+
+// add adds values to filename.
+func (co *coalescer) add(filename string, values ...Update) {
+	if co.values == nil {
+		co.values = make(map[string][]Update)
+	}
+	if _, ok := co.values[filename]; !ok {
+		co.files = append(co.files, filename)
+	}
+	co.values[filename] = append(co.values[filename], values...)
+	co.n += len(values)
+}
+
+// cmds returns the update commands for the held updates, one per file
+// unless it would exceed max bytes, and the file of each command.
+func (co *coalescer) cmds(max int) ([]*Cmd, []string) {
+	cmds := make([]*Cmd, 0, len(co.files))
+	files := make([]string, 0, len(co.files))
+	for _, f := range co.files {
+		values := co.values[f]
+		for _, cmd := range updateCmds(f, values[0], values[1:], max) {
+			cmds = append(cmds, cmd)
+			files = append(files, f)
+		}
+	}
+	return cmds, files
+}
@@ -0,0 +1,24 @@
+package rrd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalescer(t *testing.T) {
+	var co coalescer
+	co.add("b.rrd", "1:1", "2:22")
+	co.add("a.rrd", "1:1")
+	co.add("b.rrd", "3:333")
+	assert.Equal(t, 4, co.n)
+	assert.Equal(t, []string{"b.rrd", "a.rrd"}, co.files)
+
+	cmds, files := co.cmds(27)
+	lines := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		lines[i] = cmd.String()
+	}
+	assert.Equal(t, []string{"update b.rrd 1:1 2:22\n", "update b.rrd 3:333\n", "update a.rrd 1:1\n"}, lines)
+	assert.Equal(t, []string{"b.rrd", "b.rrd", "a.rrd"}, files)
+}
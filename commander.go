@@ -0,0 +1,111 @@
+package rrd
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Commander is the set of commands provided by Client, allowing code which
+// uses a client to be tested with a mock implementation.
+type Commander interface {
+	// Raw commands.
+	Exec(cmd string, opts ...ExecOption) ([]string, error)
+	ExecWithContext(ctx context.Context, cmd string, opts ...ExecOption) ([]string, error)
+	ExecCmd(cmd *Cmd, opts ...ExecOption) ([]string, error)
+	ExecCmdWithContext(ctx context.Context, cmd *Cmd, opts ...ExecOption) ([]string, error)
+	ExecCmdStream(ctx context.Context, cmd *Cmd, f LineFunc, opts ...ExecOption) error
+	Batch(cmds ...*Cmd) error
+	BatchWithContext(ctx context.Context, cmds ...*Cmd) error
+	ExecRRDTool(ctx context.Context, cmd *RRDToolCmd) error
+
+	// Creating and modifying RRDs.
+	Create(filename string, ds []DS, rra []RRA, options ...CreateOption) error
+	CreateWithContext(ctx context.Context, filename string, ds []DS, rra []RRA, options ...CreateOption) error
+	CreateFrom(filename string, def *CreateRRD) error
+	CreateFromWithContext(ctx context.Context, filename string, def *CreateRRD) error
+	CreateAndSeed(ctx context.Context, filename string, def *CreateRRD, seed []Sample) error
+	Update(filename string, samples ...Sample) error
+	UpdateWithContext(ctx context.Context, filename string, samples ...Sample) error
+	UpdateRaw(filename string, value Update, values ...Update) error
+	UpdateRawWithContext(ctx context.Context, filename string, value Update, values ...Update) error
+	Tune(filename string, opts ...TuneOption) error
+	TuneWithContext(ctx context.Context, filename string, opts ...TuneOption) error
+	Restore(ctx context.Context, filename string, r io.Reader, force bool) error
+	Resize(ctx context.Context, filename string, rra, rows int) error
+
+	// Reading RRDs.
+	Fetch(filename string, cf ConsolidationFunc, options ...interface{}) (*Fetch, error)
+	FetchWithContext(ctx context.Context, filename string, cf ConsolidationFunc, options ...interface{}) (*Fetch, error)
+	FetchStream(ctx context.Context, filename string, cf ConsolidationFunc, f func(h *Fetch, row FetchRow) error, options ...interface{}) error
+	FetchRange(filename string, cf ConsolidationFunc, start, end time.Time) (*FetchResult, error)
+	FetchRangeWithContext(ctx context.Context, filename string, cf ConsolidationFunc, start, end time.Time) (*FetchResult, error)
+	FetchBin(filename string, cf ConsolidationFunc, options ...interface{}) (*FetchBin, error)
+	FetchBinWithContext(ctx context.Context, filename string, cf ConsolidationFunc, options ...interface{}) (*FetchBin, error)
+	Xport(ctx context.Context, def *XportDef) (*XportResult, error)
+	Export(ctx context.Context, def *XportDef) (*XportResult, error)
+	Dump(ctx context.Context, filename string, w io.Writer) error
+	Query(ctx context.Context, filename string, cf ConsolidationFunc, start, end time.Time, points int) (*FetchResult, error)
+	EnsureExists(filename string, def *CreateRRD) error
+	EnsureExistsWithContext(ctx context.Context, filename string, def *CreateRRD) error
+	First(filename string, rra int) (time.Time, error)
+	FirstWithContext(ctx context.Context, filename string, rra int) (time.Time, error)
+	Last(filename string) (time.Time, error)
+	LastWithContext(ctx context.Context, filename string) (time.Time, error)
+	Info(filename string) ([]*Info, error)
+	InfoWithContext(ctx context.Context, filename string) ([]*Info, error)
+	InfoMap(filename string) (map[string]interface{}, error)
+	InfoMapWithContext(ctx context.Context, filename string) (map[string]interface{}, error)
+	InfoStruct(filename string) (*RRDInfo, error)
+	InfoStructWithContext(ctx context.Context, filename string) (*RRDInfo, error)
+	Exists(filename string) (bool, error)
+	ExistsWithContext(ctx context.Context, filename string) (bool, error)
+	LastUpdate(filename string) (*LastUpdate, error)
+	LastUpdateWithContext(ctx context.Context, filename string) (*LastUpdate, error)
+	InfoTree(filename string) (InfoTree, error)
+	InfoTreeWithContext(ctx context.Context, filename string) (InfoTree, error)
+	InfoValues(filename string) (InfoValues, error)
+	InfoValuesWithContext(ctx context.Context, filename string) (InfoValues, error)
+	InvalidateInfo(filename string)
+	List(ctx context.Context, prefix string, opts ...ListOption) ([]string, error)
+	ListStream(ctx context.Context, prefix string, f func(name string) error, opts ...ListOption) error
+	Walk(ctx context.Context, prefix string, fn func(name string) error, opts ...ListOption) error
+
+	// Cache management.
+	Flush(filename string) error
+	FlushWithContext(ctx context.Context, filename string) error
+	FlushAll() error
+	FlushAllWithContext(ctx context.Context) error
+	FlushMany(ctx context.Context, filenames []string) (map[string]error, error)
+	Forget(filename string) error
+	ForgetWithContext(ctx context.Context, filename string) error
+	Pending(filename string) ([]Sample, error)
+	PendingWithContext(ctx context.Context, filename string) ([]Sample, error)
+	Wrote(filename string) error
+	WroteWithContext(ctx context.Context, filename string) error
+	Suspend(filename string) error
+	SuspendWithContext(ctx context.Context, filename string) error
+	SuspendAll() error
+	SuspendAllWithContext(ctx context.Context) error
+	Resume(filename string) error
+	ResumeWithContext(ctx context.Context, filename string) error
+	ResumeAll() error
+	ResumeAllWithContext(ctx context.Context) error
+
+	// Server information.
+	Ping() error
+	PingWithContext(ctx context.Context) error
+	Health(ctx context.Context) error
+	Help(cmd ...string) ([]string, error)
+	HelpWithContext(ctx context.Context, cmd ...string) ([]string, error)
+	Queue() ([]QueueEntry, error)
+	QueueWithContext(ctx context.Context) ([]QueueEntry, error)
+	Stats() (*Stats, error)
+	StatsWithContext(ctx context.Context) (*Stats, error)
+	Capabilities() (*Capabilities, error)
+	CapabilitiesWithContext(ctx context.Context) (*Capabilities, error)
+
+	Close() error
+}
+
+var _ Commander = (*Client)(nil)
@@ -0,0 +1,111 @@
+package rrd
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// DispatchStrategy selects the connection used for each command by a
+// client with multiple Connections.
+type DispatchStrategy int
+
+const (
+	// RoundRobin uses each connection in turn.
+	RoundRobin DispatchStrategy = iota
+
+	// LeastBusy uses the connection with the fewest commands in progress,
+	// which suits commands, such as large fetches, which vary in duration.
+	LeastBusy
+)
+
+// Connections makes the client maintain n connections to the server,
+// dispatching each command to one of them, so concurrent commands, such as
+// parallel fetches, aren't serialized behind a single connection.
+// Other options apply to each connection, except RateLimit and
+// CircuitBreaker which are shared by them.
+func Connections(n int) func(*Client) error {
+	return func(c *Client) error {
+		if n < 1 {
+			return fmt.Errorf("%w: connections %v", ErrInvalidArg, n)
+		}
+		c.connections = n
+		return nil
+	}
+}
+
+// Dispatch sets the strategy used to select the connection for each
+// command when the client has multiple Connections, by default RoundRobin.
+func Dispatch(s DispatchStrategy) func(*Client) error {
+	return func(c *Client) error {
+		switch s {
+		case RoundRobin, LeastBusy:
+		default:
+			return fmt.Errorf("%w: dispatch strategy %v", ErrInvalidArg, s)
+		}
+		c.dispatch = s
+		return nil
+	}
+}
+
+// lane is one of the connections of a client with multiple Connections.
+type lane struct {
+	c    *Client
+	busy atomic.Int64
+}
+
+// newLanes creates the connections of c, each a client created with
+// options, sharing its rate limit and circuit breaker.
+func (c *Client) newLanes(addr string, options []func(*Client) error) error {
+	options = append(options[:len(options):len(options)], func(lc *Client) error {
+		lc.connections = 0
+		return nil
+	})
+
+	c.lanes = make([]*lane, c.connections)
+	for i := range c.lanes {
+		lc, err := NewClient(addr, options...)
+		if err != nil {
+			c.closeLanes(func(lc *Client) error { return lc.CloseNoQuit() }) // nolint: errcheck
+			return err
+		}
+		lc.limiter = c.limiter
+		lc.breaker = c.breaker
+		c.lanes[i] = &lane{c: lc}
+	}
+	return nil
+}
+
+// pick returns the client to perform a command with, c itself unless it
+// has multiple Connections, and a function to call once it completes.
+func (c *Client) pick() (*Client, func()) {
+	if c.lanes == nil {
+		return c, func() {}
+	}
+
+	var l *lane
+	switch c.dispatch {
+	case LeastBusy:
+		for _, cl := range c.lanes {
+			if l == nil || cl.busy.Load() < l.busy.Load() {
+				l = cl
+			}
+		}
+	default:
+		l = c.lanes[(c.next.Add(1)-1)%uint64(len(c.lanes))]
+	}
+
+	l.busy.Add(1)
+	return l.c, func() { l.busy.Add(-1) }
+}
+
+// closeLanes closes the connections of c with f.
+func (c *Client) closeLanes(f func(*Client) error) error {
+	var errs []error
+	for _, l := range c.lanes {
+		if l != nil {
+			errs = append(errs, f(l.c))
+		}
+	}
+	return errors.Join(errs...)
+}
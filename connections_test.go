@@ -0,0 +1,108 @@
+package rrd
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionsOptions(t *testing.T) {
+	_, err := NewClient("", Connections(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	_, err = NewClient("", Dispatch(DispatchStrategy(-1)))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
+
+func TestClientConnections(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Connections(3), RateLimit(1000, 100))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+		assert.Eventually(t, func() bool {
+			s.mtx.Lock()
+			defer s.mtx.Unlock()
+			return len(s.conns) == 0
+		}, time.Second, time.Millisecond*10)
+	}()
+
+	if !assert.Len(t, c.lanes, 3) {
+		return
+	}
+	s.mtx.Lock()
+	assert.Len(t, s.conns, 3)
+	s.mtx.Unlock()
+	for _, l := range c.lanes {
+		assert.Same(t, c.limiter, l.c.limiter)
+	}
+
+	// Commands are dispatched round robin.
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, c.Ping())
+	}
+	for _, l := range c.lanes {
+		assert.Equal(t, int64(2), l.c.ClientStats().Commands["ping"].Count)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.FetchBin("test.rrd", Average)
+			assert.NoError(t, err)
+			assert.NoError(t, c.Batch(NewCmd("update").WithArgs("test.rrd", "N:1")))
+		}()
+	}
+	wg.Wait()
+
+	stats := c.ClientStats()
+	assert.Equal(t, int64(6), stats.Commands["ping"].Count)
+	assert.Equal(t, int64(10), stats.Commands["fetchbin"].Count)
+	assert.Equal(t, int64(26), stats.Latency.Count)
+	for _, l := range c.lanes {
+		assert.Equal(t, int64(0), l.busy.Load())
+	}
+}
+
+func TestClientLeastBusy(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Connections(2), Dispatch(LeastBusy))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	lc, done := c.pick()
+	assert.Same(t, c.lanes[0].c, lc)
+	lc2, done2 := c.pick()
+	assert.Same(t, c.lanes[1].c, lc2)
+	done()
+	lc, done = c.pick()
+	assert.Same(t, c.lanes[0].c, lc)
+	done()
+	done2()
+}
@@ -0,0 +1,248 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// CreateRRD represents the definition of a RRD.
+type CreateRRD struct {
+	DS      []DS
+	RRA     []RRA
+	Options []CreateOption
+}
+
+// NewCreateRRD returns a new CreateRRD with the given data sources and archives.
+func NewCreateRRD(ds []DS, rra []RRA, options ...CreateOption) *CreateRRD {
+	return &CreateRRD{DS: ds, RRA: rra, Options: options}
+}
+
+// WithDS adds data sources to the definition.
+func (d *CreateRRD) WithDS(ds ...DS) *CreateRRD {
+	d.DS = append(d.DS, ds...)
+	return d
+}
+
+// WithRRA adds round robin archives to the definition.
+func (d *CreateRRD) WithRRA(rra ...RRA) *CreateRRD {
+	d.RRA = append(d.RRA, rra...)
+	return d
+}
+
+// WithCompute adds a COMPUTE data source name, whose values are calculated
+// by the rpn expression, such as "watts,1000,/", from the data sources
+// defined before it.
+func (d *CreateRRD) WithCompute(name, rpn string) *CreateRRD {
+	return d.WithDS(NewCompute(name, rpn))
+}
+
+// HoltWinters configures the Holt-Winters aberrant behavior detection
+// archives added by CreateRRD.WithHoltWinters.
+type HoltWinters struct {
+	// Rows is the number of predictions stored.
+	Rows int
+
+	// Alpha, Beta and Gamma are the adaptation parameters of the intercept,
+	// slope and seasonal coefficients, between 0 and 1 exclusive.
+	Alpha float32
+	Beta  float32
+	Gamma float32
+
+	// Period is the number of primary data points in a seasonal period.
+	Period int
+
+	// SmoothingWindow is the fraction of the period used to smooth the
+	// seasonal coefficients, 0.05 if zero.
+	SmoothingWindow float32
+
+	// A failure is recorded when at least Threshold of the last Window
+	// values are outside the confidence bounds, 7 of 9 if zero.
+	Threshold int
+	Window    int
+
+	// Multiplicative uses MHWPREDICT rather than HWPREDICT.
+	Multiplicative bool
+}
+
+// WithHoltWinters adds the HWPREDICT, SEASONAL, DEVSEASONAL, DEVPREDICT and
+// FAILURES archives configured by hw, referencing each other by index so
+// must be added after the other archives of the definition.
+func (d *CreateRRD) WithHoltWinters(hw HoltWinters) *CreateRRD {
+	if hw.SmoothingWindow == 0 {
+		hw.SmoothingWindow = 0.05
+	}
+	if hw.Threshold == 0 {
+		hw.Threshold = 7
+	}
+	if hw.Window == 0 {
+		hw.Window = 9
+	}
+
+	predict := NewHWPredict
+	if hw.Multiplicative {
+		predict = NewMHWPredict
+	}
+	n := len(d.RRA)
+	return d.WithRRA(
+		predict(hw.Rows, hw.Alpha, hw.Beta, hw.Period, n+2),
+		NewSeasonal(hw.Period, hw.Gamma, n+1, hw.SmoothingWindow),
+		NewDevSeasonal(hw.Period, hw.Gamma, n+1, hw.SmoothingWindow),
+		NewDevPredict(hw.Rows, n+3),
+		NewFailures(hw.Rows, hw.Threshold, hw.Window, n+3),
+	)
+}
+
+// WithOptions adds create options to the definition.
+func (d *CreateRRD) WithOptions(options ...CreateOption) *CreateRRD {
+	d.Options = append(d.Options, options...)
+	return d
+}
+
+// WithStep sets the base step of the definition.
+func (d *CreateRRD) WithStep(step time.Duration) *CreateRRD {
+	return d.WithOptions(Step(step))
+}
+
+// WithStart sets the start time of the definition.
+func (d *CreateRRD) WithStart(start time.Time) *CreateRRD {
+	return d.WithOptions(Start(start))
+}
+
+// WithNoOverwrite prevents an existing RRD being overwritten.
+func (d *CreateRRD) WithNoOverwrite() *CreateRRD {
+	return d.WithOptions(NoOverwrite())
+}
+
+// hasOption returns true if d has an option with flag, false otherwise.
+func (d *CreateRRD) hasOption(flag string) bool {
+	for _, o := range d.Options {
+		if o == CreateOption(flag) || strings.HasPrefix(string(o), flag+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that d defines a valid RRD.
+// Data sources and archives are only optional if a template or source RRD
+// is specified, from which they can be copied.
+func (d *CreateRRD) Validate() error {
+	_, err := d.args()
+	return err
+}
+
+// Cmd returns the validated create command for filename.
+func (d *CreateRRD) Cmd(filename string) (*Cmd, error) {
+	args, err := d.args()
+	if err != nil {
+		return nil, err
+	}
+	return NewCmd("create").WithArgs(append([]interface{}{filename}, args...)...), nil
+}
+
+// args returns the validated create arguments for d.
+func (d *CreateRRD) args() ([]interface{}, error) {
+	copied := d.hasOption("-t") || d.hasOption("-r")
+	switch {
+	case len(d.DS) == 0 && !copied:
+		return nil, ErrNoDS
+	case len(d.RRA) == 0 && !copied:
+		return nil, ErrNoRRA
+	}
+
+	if err := validateDS(d.DS); err != nil {
+		return nil, err
+	}
+	step, err := d.step()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range d.RRA {
+		if _, _, err := rraInfo(r, step); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateHW(d.RRA); err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0, len(d.Options)+len(d.DS)+len(d.RRA))
+	for _, v := range d.Options {
+		args = append(args, v)
+	}
+	for _, v := range d.DS {
+		args = append(args, v)
+	}
+	for _, v := range d.RRA {
+		v, err := v.normalize()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
+
+// Create creates the RRD according to the supplied parameters.
+func (c *Client) Create(filename string, ds []DS, rra []RRA, options ...CreateOption) error {
+	return c.CreateWithContext(context.Background(), filename, ds, rra, options...)
+}
+
+// CreateWithContext creates the RRD according to the supplied parameters.
+func (c *Client) CreateWithContext(ctx context.Context, filename string, ds []DS, rra []RRA, options ...CreateOption) error {
+	return c.CreateFromWithContext(ctx, filename, NewCreateRRD(ds, rra, options...))
+}
+
+// CreateFrom creates the RRD filename as defined by def.
+func (c *Client) CreateFrom(filename string, def *CreateRRD) error {
+	return c.CreateFromWithContext(context.Background(), filename, def)
+}
+
+// CreateFromWithContext creates the RRD filename as defined by def.
+func (c *Client) CreateFromWithContext(ctx context.Context, filename string, def *CreateRRD) error {
+	cmd, err := def.Cmd(filename)
+	if err != nil {
+		return err
+	}
+
+	defer c.InvalidateInfo(filename)
+	_, err = c.ExecCmdWithContext(ctx, cmd)
+	return err
+}
+
+// CreateAndSeed creates filename as defined by def, never overwriting an
+// existing file, and then updates it with seed.
+//
+// If filename already exists the error satisfies IsExist and no updates are
+// sent, so concurrent callers provisioning the same RRD seed it only once.
+//
+// CREATE isn't transactional, if seeding fails the cached values for the file
+// are discarded with FORGET, however rrdcached can't delete files so the RRD
+// remains on disk unseeded and subsequent calls will report IsExist. The
+// error of the update is joined with that of FORGET, if it also fails.
+func (c *Client) CreateAndSeed(ctx context.Context, filename string, def *CreateRRD, seed []Sample) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d := *def
+	if !d.hasOption(string(NoOverwrite())) {
+		d.Options = append([]CreateOption{NoOverwrite()}, d.Options...)
+	}
+	if err := c.CreateFromWithContext(ctx, filename, &d); err != nil {
+		return err
+	}
+
+	if len(seed) == 0 {
+		return nil
+	}
+
+	if err := c.UpdateWithContext(ctx, filename, seed...); err != nil {
+		// Use a fresh context so the rollback happens even if ctx is done.
+		return errors.Join(err, c.Forget(filename))
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package rrd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCreateStep is the step of RRDs created without a step option.
+const defaultCreateStep = 300
+
+// durationUnits are the seconds of the duration suffixes supported by
+// rrdtool create, where a month is 31 days and a year 366.
+var durationUnits = map[byte]int64{
+	's': 1,
+	'm': 60,
+	'h': 3600,
+	'd': 86400,
+	'w': 7 * 86400,
+	'M': 31 * 86400,
+	'y': 366 * 86400,
+}
+
+// scaledCount parses v, a count or a duration with a unit suffix, returning
+// the count of unit seconds it represents.
+func scaledCount(v string, unit int64) (int64, error) {
+	if v == "" {
+		return 0, fmt.Errorf("%w: empty value", ErrInvalidArg)
+	}
+	mult, ok := durationUnits[v[len(v)-1]]
+	if !ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%w: invalid count %q", ErrInvalidArg, v)
+		}
+		return n, nil
+	}
+
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n <= 0 || n*mult%unit != 0 {
+		return 0, fmt.Errorf("%w: invalid duration %q", ErrInvalidArg, v)
+	}
+	return n * mult / unit, nil
+}
+
+// parseLimit parses the minimum or maximum of a data source, U is NaN.
+func parseLimit(v string) (float64, error) {
+	if v == "U" {
+		return math.NaN(), nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid limit %q", ErrInvalidArg, v)
+	}
+	return f, nil
+}
+
+// Info returns the info of the RRD created by d, with the data sources and
+// archives configured as they would be by rrdtool create. State such as the
+// last update isn't set and Holt-Winters archives, which may create
+// further dependent archives, are omitted.
+// Definitions which copy from a template or source RRD aren't supported.
+func (d *CreateRRD) Info() (*RRDInfo, error) {
+	if d.hasOption("-t") || d.hasOption("-r") {
+		return nil, fmt.Errorf("%w: info of copied definition", ErrNotSupported)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	step, err := d.step()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &RRDInfo{
+		Step: time.Duration(step) * time.Second,
+		DS:   make(map[string]DSInfo, len(d.DS)),
+	}
+	for i, ds := range d.DS {
+		di, err := dsInfo(ds, i)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := info.DS[di.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate data source %q", ErrInvalidArg, di.Name)
+		}
+		info.DS[di.Name] = di
+	}
+	for _, rra := range d.RRA {
+		ri, ok, err := rraInfo(rra, step)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			info.RRA = append(info.RRA, ri)
+		}
+	}
+	return info, nil
+}
+
+// step returns the step of d in seconds.
+func (d *CreateRRD) step() (int64, error) {
+	step := int64(defaultCreateStep)
+	for _, o := range d.Options {
+		if v, ok := strings.CutPrefix(string(o), "-s "); ok {
+			var err error
+			if step, err = scaledCount(v, 1); err != nil {
+				return 0, fmt.Errorf("step: %w", err)
+			}
+		}
+	}
+	return step, nil
+}
+
+// dsInfo returns the info of the data source ds at index.
+func dsInfo(ds DS, index int) (DSInfo, error) {
+	parts := strings.Split(string(ds), ":")
+	if len(parts) < 4 || parts[0] != "DS" || ds.Name() == "" {
+		return DSInfo{}, fmt.Errorf("%w: data source %q", ErrInvalidArg, ds)
+	}
+
+	d := DSInfo{Name: ds.Name(), Index: index, Type: parts[2], Min: math.NaN(), Max: math.NaN()}
+	if d.Type == Compute {
+		d.CDef = strings.Join(parts[3:], ":")
+		return d, nil
+	}
+	if len(parts) != 6 {
+		return DSInfo{}, fmt.Errorf("%w: data source %q isn't of the form DS:name:type:heartbeat:min:max", ErrInvalidArg, ds)
+	}
+
+	hb, err := scaledCount(parts[3], 1)
+	if err != nil {
+		return DSInfo{}, fmt.Errorf("data source %v heartbeat: %w", d.Name, err)
+	}
+	d.MinimalHeartbeat = time.Duration(hb) * time.Second
+	if d.Min, err = parseLimit(parts[4]); err != nil {
+		return DSInfo{}, fmt.Errorf("data source %v min: %w", d.Name, err)
+	}
+	if d.Max, err = parseLimit(parts[5]); err != nil {
+		return DSInfo{}, fmt.Errorf("data source %v max: %w", d.Name, err)
+	}
+	return d, nil
+}
+
+// rraInfo returns the info of the archive r of a RRD with step seconds,
+// returning false for Holt-Winters archives.
+func rraInfo(r RRA, step int64) (RRAInfo, bool, error) {
+	r, err := r.normalize()
+	if err != nil {
+		return RRAInfo{}, false, err
+	}
+	parts := strings.Split(string(r), ":")
+	if len(parts) < 2 || parts[0] != "RRA" {
+		return RRAInfo{}, false, fmt.Errorf("%w: archive %q", ErrInvalidArg, r)
+	}
+	if !ConsolidationFunc(parts[1]).Valid() {
+		return RRAInfo{}, false, nil
+	}
+	if len(parts) != 5 {
+		return RRAInfo{}, false, fmt.Errorf("%w: archive %q isn't of the form RRA:CF:xff:steps:rows", ErrInvalidArg, r)
+	}
+
+	xff, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil || xff < 0 || xff >= 1 {
+		return RRAInfo{}, false, fmt.Errorf("%w: archive %q xff must be at least 0 and less than 1", ErrInvalidArg, r)
+	}
+	pdp, err := scaledCount(parts[3], step)
+	if err != nil {
+		return RRAInfo{}, false, fmt.Errorf("archive %v steps: %w", r, err)
+	}
+	rows, err := scaledCount(parts[4], pdp*step)
+	if err != nil {
+		return RRAInfo{}, false, fmt.Errorf("archive %v rows: %w", r, err)
+	}
+	return RRAInfo{CF: parts[1], Rows: rows, PDPPerRow: pdp, XFF: xff}, true, nil
+}
+
+// formatLimit returns the minimum or maximum v of a data source in create
+// format, NaN is U.
+func formatLimit(v float64) string {
+	if math.IsNaN(v) {
+		return "U"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// CreateRRD returns the definition of a RRD with the step, data sources and
+// archives of r, as used to clone a RRD. RRDs with Holt-Winters archives
+// aren't supported.
+func (r *RRDInfo) CreateRRD() (*CreateRRD, error) {
+	if r.Step < time.Second {
+		return nil, fmt.Errorf("%w: step %v", ErrInvalidArg, r.Step)
+	}
+
+	def := NewCreateRRD(make([]DS, len(r.DS)), nil).WithStep(r.Step)
+	for _, d := range r.DS {
+		if d.Index < 0 || d.Index >= len(def.DS) || def.DS[d.Index] != "" {
+			return nil, fmt.Errorf("%w: data source %v index %v", ErrInvalidArg, d.Name, d.Index)
+		}
+		if d.Type == Compute {
+			def.DS[d.Index] = NewDS(fmt.Sprintf("DS:%v:%v:%v", d.Name, d.Type, d.CDef))
+			continue
+		}
+		def.DS[d.Index] = NewDS(fmt.Sprintf("DS:%v:%v:%v:%v:%v",
+			d.Name, d.Type, int64(d.MinimalHeartbeat/time.Second), formatLimit(d.Min), formatLimit(d.Max)))
+	}
+
+	for i, a := range r.RRA {
+		if !ConsolidationFunc(a.CF).Valid() {
+			return nil, fmt.Errorf("%w: rra[%v] %v archive", ErrNotSupported, i, a.CF)
+		}
+		def.WithRRA(NewRRA(fmt.Sprintf("RRA:%v:%v:%v:%v",
+			a.CF, strconv.FormatFloat(a.XFF, 'g', -1, 64), a.PDPPerRow, a.Rows)))
+	}
+	return def, nil
+}
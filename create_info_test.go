@@ -0,0 +1,113 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRRDInfo(t *testing.T) {
+	def := NewCreateRRD(
+		[]DS{
+			NewGauge("watts", time.Minute*2, 0, 24000),
+			NewDS("DS:amps:COUNTER:5m:U:100"),
+			NewCompute("kw", "watts,1000,/"),
+		},
+		[]RRA{
+			NewAverage(0.5, 1, 1440),
+			NewRRA("RRA:max:0.5:1h:1y"),
+			NewHWPredict(1440, 0.1, 0.0035, 288, 0),
+		},
+	).WithStep(time.Minute)
+
+	info, err := def.Info()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Minute, info.Step)
+	assert.Equal(t, DSInfo{Name: "watts", Index: 0, Type: Gauge, MinimalHeartbeat: time.Minute * 2, Min: 0, Max: 24000}, info.DS["watts"])
+	amps := info.DS["amps"]
+	assert.Equal(t, 1, amps.Index)
+	assert.Equal(t, time.Minute*5, amps.MinimalHeartbeat)
+	assert.True(t, math.IsNaN(amps.Min))
+	assert.Equal(t, float64(100), amps.Max)
+	assert.Equal(t, "watts,1000,/", info.DS["kw"].CDef)
+	assert.Equal(t, []RRAInfo{
+		{CF: "AVERAGE", Rows: 1440, PDPPerRow: 1, XFF: 0.5},
+		{CF: "MAX", Rows: 366 * 24, PDPPerRow: 60, XFF: 0.5},
+	}, info.RRA)
+
+	noStep := NewCreateRRD([]DS{NewGauge("a", time.Minute, 0, 1)}, []RRA{NewAverage(0.5, 1, 10)})
+	info, err = noStep.Info()
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Minute*5, info.Step)
+	}
+}
+
+func TestCreateRRDInfoInvalid(t *testing.T) {
+	rra := []RRA{NewAverage(0.5, 1, 10)}
+	ds := []DS{NewGauge("a", time.Minute, 0, 1)}
+	tests := []struct {
+		name string
+		def  *CreateRRD
+		err  error
+	}{
+		{"template", NewCreateRRD(nil, nil, Template("a.rrd")), ErrNotSupported},
+		{"no-ds", NewCreateRRD(nil, rra), ErrNoDS},
+		{"duplicate-ds", NewCreateRRD(append(ds, ds...), rra), ErrInvalidArg},
+		{"invalid-ds", NewCreateRRD([]DS{"DS:a:GAUGE"}, rra), ErrInvalidArg},
+		{"invalid-heartbeat", NewCreateRRD([]DS{"DS:a:GAUGE:x:U:U"}, rra), ErrInvalidArg},
+		{"invalid-min", NewCreateRRD([]DS{"DS:a:GAUGE:60:x:U"}, rra), ErrInvalidArg},
+		{"invalid-rra", NewCreateRRD(ds, []RRA{"RRA:AVERAGE:0.5:1"}), ErrInvalidArg},
+		{"invalid-xff", NewCreateRRD(ds, []RRA{"RRA:AVERAGE:2:1:10"}), ErrInvalidArg},
+		{"invalid-rows", NewCreateRRD(ds, []RRA{"RRA:AVERAGE:0.5:1:7s"}), ErrInvalidArg},
+		{"invalid-step", NewCreateRRD(ds, rra, "-s x"), ErrInvalidArg},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.def.Info()
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestRRDInfoCreateRRD(t *testing.T) {
+	def := NewCreateRRD(
+		[]DS{
+			NewGauge("watts", time.Minute*2, 0, 24000),
+			NewDS("DS:amps:COUNTER:300:U:100"),
+			NewCompute("kw", "watts,1000,/"),
+		},
+		[]RRA{NewAverage(0.5, 1, 1440), NewMax(0.25, 60, 8784)},
+	).WithStep(time.Minute)
+
+	info, err := def.Info()
+	if !assert.NoError(t, err) {
+		return
+	}
+	clone, err := info.CreateRRD()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []DS{
+		"DS:watts:GAUGE:120:0:24000",
+		"DS:amps:COUNTER:300:U:100",
+		"DS:kw:COMPUTE:watts,1000,/",
+	}, clone.DS)
+	assert.Equal(t, []RRA{"RRA:AVERAGE:0.5:1:1440", "RRA:MAX:0.25:60:8784"}, clone.RRA)
+
+	cloned, err := clone.Info()
+	if assert.NoError(t, err) {
+		assert.Empty(t, InfoDiff(info, cloned))
+	}
+
+	info.RRA = append(info.RRA, RRAInfo{CF: HoltWintersPredict, Rows: 10})
+	_, err = info.CreateRRD()
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	_, err = (&RRDInfo{Step: time.Minute, DS: map[string]DSInfo{"a": {Name: "a", Index: 1}}}).CreateRRD()
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
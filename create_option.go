@@ -25,10 +25,10 @@ func NoOverwrite() CreateOption {
 
 // Source returns a new create source option.
 func Source(file string) CreateOption {
-	return CreateOption(fmt.Sprintf("-r %v", file))
+	return CreateOption("-r " + escapeArg(file))
 }
 
 // Template returns a new create template option.
 func Template(file string) CreateOption {
-	return CreateOption(fmt.Sprintf("-t %v", file))
+	return CreateOption("-t " + escapeArg(file))
 }
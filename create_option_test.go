@@ -19,6 +19,7 @@ func TestCreateOption(t *testing.T) {
 		{"start", Start(now), fmt.Sprintf("-b %v", now.Unix())},
 		{"source", Source("test.rrd"), "-r test.rrd"},
 		{"template", Template("test.rrd"), "-t test.rrd"},
+		{"source-escaped", Source("my test.rrd"), `-r my\ test.rrd`},
 		{"no-overwrite", NoOverwrite(), "-O"},
 	}
 
@@ -0,0 +1,181 @@
+package rrd
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAndSeed(t *testing.T) {
+	def := &CreateRRD{
+		DS:      []DS{NewGauge("watts", time.Minute*5, 0, 24000)},
+		RRA:     []RRA{NewAverage(0.5, 1, 864000)},
+		Options: []CreateOption{Step(time.Minute)},
+	}
+	seed := []Sample{
+		{Time: time.Unix(1499968800, 0), Values: []float64{10}},
+		{Time: time.Unix(1499968860, 0), Values: []float64{math.NaN()}},
+	}
+
+	tests := []struct {
+		name      string
+		responses map[string][]string
+		err       func(error) bool
+		updates   int
+		forgets   int
+	}{
+		{"ok", map[string][]string{"update": {"0 errors, enqueued 2 value(s)."}}, nil, 1, 0},
+		{"exists", map[string][]string{"create": {"-1 RRD Error: creating '/test.rrd': File exists"}}, IsExist, 0, 0},
+		{"seed-fail", map[string][]string{"update": {"-1 illegal attempt to update using time 1499968800.000000 when last update time is 1499968800.000000 (minimum one second step)"}}, IsIllegalUpdate, 1, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerStopped(t)
+			if s == nil {
+				return
+			}
+			s.responses = tc.responses
+			s.Start()
+			defer func() {
+				assert.NoError(t, s.Close())
+			}()
+
+			c, err := NewClient(s.Addr, Timeout(time.Second*2))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			err = c.CreateAndSeed(context.Background(), "test.rrd", def, seed)
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, tc.err(err), "unexpected error %v", err)
+			}
+			assert.Equal(t, 1, s.count("create test.rrd -O -s 60 DS:watts:GAUGE:300:0:24000 RRA:AVERAGE:0.5:1:864000"))
+			assert.Equal(t, tc.updates, s.count("update test.rrd 1499968800:10 1499968860:U"))
+			assert.Equal(t, tc.forgets, s.count("forget test.rrd"))
+		})
+	}
+}
+
+func TestCreateAndSeedRedact(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"update": {"-1 illegal attempt to update using time 1499968800.000000 when last update time is 1499968800.000000 (minimum one second step)"},
+		"forget": {"-1 No such file or directory"},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Redact)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	def := &CreateRRD{DS: []DS{NewGauge("watts", time.Minute*5, 0, 24000)}, RRA: []RRA{NewAverage(0.5, 1, 864000)}}
+	err = c.CreateAndSeed(context.Background(), "secret.rrd", def, []Sample{{Time: time.Unix(1499968800, 0), Values: []float64{10}}})
+	assert.True(t, IsIllegalUpdate(err), "unexpected error %v", err)
+	assert.ErrorContains(t, err, "forget")
+	assert.NotContains(t, err.Error(), "secret.rrd")
+	assert.Equal(t, 1, s.count("forget secret.rrd"))
+}
+
+func TestCreateRRD(t *testing.T) {
+	start := time.Unix(1499968800, 0)
+	gauge := NewGauge("watts", time.Minute*5, 0, 24000)
+	avg := NewAverage(0.5, 1, 864000)
+
+	tests := []struct {
+		name string
+		def  *CreateRRD
+		cmd  string
+		err  error
+	}{
+		{
+			"full",
+			NewCreateRRD(nil, nil).WithDS(gauge).WithRRA(avg).WithStep(time.Minute).WithStart(start).WithNoOverwrite(),
+			"create test.rrd -s 60 -b 1499968800 -O DS:watts:GAUGE:300:0:24000 RRA:AVERAGE:0.5:1:864000",
+			nil,
+		},
+		{"normalize", NewCreateRRD([]DS{gauge}, []RRA{"RRA:average:0.5:1:10"}), "create test.rrd DS:watts:GAUGE:300:0:24000 RRA:AVERAGE:0.5:1:10", nil},
+		{"template", NewCreateRRD(nil, nil, Template("other.rrd")), "create test.rrd -t other.rrd", nil},
+		{"no-ds", NewCreateRRD(nil, []RRA{avg}), "", ErrNoDS},
+		{"no-rra", NewCreateRRD([]DS{gauge}, nil), "", ErrNoRRA},
+		{"invalid-cf", NewCreateRRD([]DS{gauge}, []RRA{"RRA:BAD:0.5:1:10"}), "", ErrInvalidCF},
+		{
+			"compute",
+			NewCreateRRD([]DS{gauge}, []RRA{avg}).WithCompute("kw", "watts,1000,/"),
+			"create test.rrd DS:watts:GAUGE:300:0:24000 DS:kw:COMPUTE:watts,1000,/ RRA:AVERAGE:0.5:1:864000",
+			nil,
+		},
+		{"compute-unknown", NewCreateRRD([]DS{gauge}, []RRA{avg}).WithCompute("kw", "volts,1000,/"), "", ErrInvalidArg},
+		{"compute-later", NewCreateRRD(nil, []RRA{avg}).WithCompute("kw", "watts,1000,/").WithDS(gauge), "", ErrInvalidArg},
+		{"compute-stack", NewCreateRRD([]DS{gauge}, []RRA{avg}).WithCompute("kw", "watts,1000"), "", ErrInvalidArg},
+		{
+			"holt-winters",
+			NewCreateRRD([]DS{gauge}, []RRA{avg}).WithHoltWinters(HoltWinters{Rows: 1440, Alpha: 0.1, Beta: 0.0035, Gamma: 0.1, Period: 288}),
+			"create test.rrd DS:watts:GAUGE:300:0:24000 RRA:AVERAGE:0.5:1:864000 RRA:HWPREDICT:1440:0.1:0.0035:288:3 " +
+				"RRA:SEASONAL:288:0.1:2:smoothing-window=0.05 RRA:DEVSEASONAL:288:0.1:2:smoothing-window=0.05 " +
+				"RRA:DEVPREDICT:1440:4 RRA:FAILURES:1440:7:9:4",
+			nil,
+		},
+		{
+			"mhw",
+			NewCreateRRD([]DS{gauge}, nil).WithHoltWinters(HoltWinters{Rows: 10, Alpha: 0.5, Beta: 0.5, Gamma: 0.5, Period: 5, SmoothingWindow: 0.1, Threshold: 2, Window: 3, Multiplicative: true}),
+			"create test.rrd DS:watts:GAUGE:300:0:24000 RRA:MHWPREDICT:10:0.5:0.5:5:2 " +
+				"RRA:SEASONAL:5:0.5:1:smoothing-window=0.1 RRA:DEVSEASONAL:5:0.5:1:smoothing-window=0.1 " +
+				"RRA:DEVPREDICT:10:3 RRA:FAILURES:10:2:3:3",
+			nil,
+		},
+		{"hw-alpha", NewCreateRRD([]DS{gauge}, nil).WithHoltWinters(HoltWinters{Rows: 10, Alpha: 1, Beta: 0.5, Gamma: 0.5, Period: 5}), "", ErrInvalidArg},
+		{"hw-rows", NewCreateRRD([]DS{gauge}, nil).WithHoltWinters(HoltWinters{Alpha: 0.5, Beta: 0.5, Gamma: 0.5, Period: 5}), "", ErrInvalidArg},
+		{"hw-window", NewCreateRRD([]DS{gauge}, nil).WithHoltWinters(HoltWinters{Rows: 10, Alpha: 0.5, Beta: 0.5, Gamma: 0.5, Period: 5, Window: 30}), "", ErrInvalidArg},
+		{"hw-ref", NewCreateRRD([]DS{gauge}, []RRA{avg, NewDevPredict(10, 1)}), "", ErrInvalidArg},
+		{"hw-ref-range", NewCreateRRD([]DS{gauge}, []RRA{NewHWPredict(10, 0.5, 0.5, 5, 2)}), "", ErrInvalidArg},
+		{"ds-form", NewCreateRRD([]DS{"watts:GAUGE:300:0:100"}, []RRA{avg}), "", ErrInvalidArg},
+		{"ds-name-long", NewCreateRRD([]DS{NewGauge("abcdefghijklmnopqrst", time.Minute, 0, 1)}, []RRA{avg}), "", ErrInvalidArg},
+		{"ds-name-charset", NewCreateRRD([]DS{NewGauge("watts.total", time.Minute, 0, 1)}, []RRA{avg}), "", ErrInvalidArg},
+		{"ds-duplicate", NewCreateRRD([]DS{gauge, gauge}, []RRA{avg}), "", ErrInvalidArg},
+		{"ds-type", NewCreateRRD([]DS{"DS:watts:GAGE:300:0:100"}, []RRA{avg}), "", ErrInvalidArg},
+		{"ds-heartbeat", NewCreateRRD([]DS{NewGauge("watts", 0, 0, 1)}, []RRA{avg}), "", ErrInvalidArg},
+		{"ds-limits", NewCreateRRD([]DS{NewGauge("watts", time.Minute, 10, 1)}, []RRA{avg}), "", ErrInvalidArg},
+		{"ds-args", NewCreateRRD([]DS{"DS:watts:GAUGE:300:0"}, []RRA{avg}), "", ErrInvalidArg},
+		{"rra-form", NewCreateRRD([]DS{gauge}, []RRA{"AVERAGE:0.5:1:10"}), "", ErrInvalidArg},
+		{"rra-rows", NewCreateRRD([]DS{gauge}, []RRA{NewAverage(0.5, 1, 0)}), "", ErrInvalidArg},
+		{"rra-steps", NewCreateRRD([]DS{gauge}, []RRA{NewAverage(0.5, -1, 10)}), "", ErrInvalidArg},
+		{"rra-xff", NewCreateRRD([]DS{gauge}, []RRA{NewAverage(1, 1, 10)}), "", ErrInvalidArg},
+		{"rra-duration", NewCreateRRD([]DS{gauge}, []RRA{"RRA:AVERAGE:0.5:90s:1d"}, Step(time.Minute)), "", ErrInvalidArg},
+		{"hw-implicit", NewCreateRRD([]DS{gauge}, []RRA{NewHWPredict(10, 0.5, 0.5, 5, 0)}), "create test.rrd DS:watts:GAUGE:300:0:24000 RRA:HWPREDICT:10:0.5:0.5:5", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, err := tc.def.Cmd("test.rrd")
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.ErrorIs(t, tc.def.Validate(), tc.err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.cmd+"\n", cmd.String())
+				assert.NoError(t, tc.def.Validate())
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package rrd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// CSVUnixTime is the time format which writes times as unix timestamps.
+const CSVUnixTime = "unix"
+
+// csvConfig is the configuration of FetchResult.WriteCSV.
+type csvConfig struct {
+	timeFormat string
+	nan        string
+	columns    []string
+	noHeader   bool
+}
+
+// CSVOption configures FetchResult.WriteCSV.
+type CSVOption func(*csvConfig) error
+
+// CSVTimeFormat sets the layout, as used by time.Format, of the time
+// column, which defaults to time.RFC3339. CSVUnixTime writes unix
+// timestamps.
+func CSVTimeFormat(layout string) CSVOption {
+	return func(c *csvConfig) error {
+		if layout == "" {
+			return fmt.Errorf("%w: empty time format", ErrInvalidArg)
+		}
+		c.timeFormat = layout
+		return nil
+	}
+}
+
+// CSVNaN sets the representation of unknown values, which defaults to an
+// empty field.
+func CSVNaN(s string) CSVOption {
+	return func(c *csvConfig) error {
+		c.nan = s
+		return nil
+	}
+}
+
+// CSVColumns selects the data sources written and their order, which
+// defaults to all of them in the order of the result.
+func CSVColumns(names ...string) CSVOption {
+	return func(c *csvConfig) error {
+		if len(names) == 0 {
+			return fmt.Errorf("%w: no columns", ErrInvalidArg)
+		}
+		c.columns = names
+		return nil
+	}
+}
+
+// CSVNoHeader disables the header row of column names.
+func CSVNoHeader(c *csvConfig) error {
+	c.noHeader = true
+	return nil
+}
+
+// WriteCSV writes r to w as CSV, with a header row of "time" followed by
+// the data source names and a row for each row of r.
+func (r *FetchResult) WriteCSV(w io.Writer, opts ...CSVOption) error {
+	cfg := &csvConfig{timeFormat: time.RFC3339}
+	for _, o := range opts {
+		if o == nil {
+			return ErrNilOption
+		}
+		if err := o(cfg); err != nil {
+			return err
+		}
+	}
+
+	names := r.Names
+	index := make([]int, len(names))
+	for i := range index {
+		index[i] = i
+	}
+	if cfg.columns != nil {
+		names = cfg.columns
+		index = make([]int, len(names))
+		for i, name := range names {
+			index[i] = -1
+			for j, n := range r.Names {
+				if n == name {
+					index[i] = j
+					break
+				}
+			}
+			if index[i] < 0 {
+				return fmt.Errorf("%w: unknown column %q", ErrInvalidArg, name)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	record := make([]string, len(names)+1)
+	if !cfg.noHeader {
+		record[0] = "time"
+		copy(record[1:], names)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range r.Rows {
+		if cfg.timeFormat == CSVUnixTime {
+			record[0] = strconv.FormatInt(row.Time.Unix(), 10)
+		} else {
+			record[0] = row.Time.Format(cfg.timeFormat)
+		}
+		for i, j := range index {
+			switch {
+			case j >= len(row.Values), math.IsNaN(row.Values[j]):
+				record[i+1] = cfg.nan
+			default:
+				record[i+1] = strconv.FormatFloat(row.Values[j], 'g', -1, 64)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,56 @@
+package rrd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchResultWriteCSV(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []CSVOption
+		expect string
+		err    error
+	}{
+		{
+			name:   "default",
+			expect: "time,a,b\n2017-07-14T02:45:00Z,1,\n2017-07-14T02:50:00Z,2.5,3\n",
+		},
+		{
+			name:   "unix-nan",
+			opts:   []CSVOption{CSVTimeFormat(CSVUnixTime), CSVNaN("NaN")},
+			expect: "time,a,b\n1500000300,1,NaN\n1500000600,2.5,3\n",
+		},
+		{
+			name:   "layout-columns",
+			opts:   []CSVOption{CSVTimeFormat(time.DateTime), CSVColumns("b", "a")},
+			expect: "time,b,a\n2017-07-14 02:45:00,,1\n2017-07-14 02:50:00,3,2.5\n",
+		},
+		{
+			name:   "no-header",
+			opts:   []CSVOption{CSVNoHeader, CSVColumns("a")},
+			expect: "2017-07-14T02:45:00Z,1\n2017-07-14T02:50:00Z,2.5\n",
+		},
+		{name: "unknown-column", opts: []CSVOption{CSVColumns("c")}, err: ErrInvalidArg},
+		{name: "no-columns", opts: []CSVOption{CSVColumns()}, err: ErrInvalidArg},
+		{name: "empty-format", opts: []CSVOption{CSVTimeFormat("")}, err: ErrInvalidArg},
+		{name: "nil-option", opts: []CSVOption{nil}, err: ErrNilOption},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := testFetchResult().WriteCSV(&buf, tc.opts...)
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, buf.String())
+		})
+	}
+}
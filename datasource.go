@@ -2,6 +2,7 @@ package rrd
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
@@ -33,6 +34,17 @@ func NewDS(raw string) DS {
 	return DS(raw)
 }
 
+// Name returns the name of the data source d, or an empty string if d
+// isn't valid.
+func (d DS) Name() string {
+	parts := strings.SplitN(string(d), ":", 3)
+	if len(parts) < 3 || parts[0] != "DS" {
+		return ""
+	}
+	name, _, _ := strings.Cut(parts[1], "=")
+	return name
+}
+
 // ds represents the internal data source that supports applying mappings
 type ds struct {
 	name        string
@@ -115,3 +127,44 @@ func NewAbsolute(name string, heartbeat time.Duration, min, max int, options ...
 func NewCompute(name, cdef string, options ...func(d *ds)) DS {
 	return newDS(Compute, name, options, cdef)
 }
+
+// validateDS checks the data sources dss, reporting the first mistake,
+// such as an invalid or duplicate name, with a description of the problem.
+// The expressions of COMPUTE data sources may only reference the data
+// sources defined before them.
+func validateDS(dss []DS) error {
+	names := make([]string, 0, len(dss))
+	for i, d := range dss {
+		parts := strings.SplitN(string(d), ":", 4)
+		if len(parts) < 4 || parts[0] != "DS" {
+			return fmt.Errorf("%w: data source %q isn't of the form DS:name:type:arguments", ErrInvalidArg, d)
+		}
+
+		name := d.Name()
+		switch {
+		case !dsNameRe.MatchString(name):
+			return fmt.Errorf("%w: data source name %q must be 1 to 19 letters, digits or underscores", ErrInvalidArg, name)
+		case slices.Contains(names, name):
+			return fmt.Errorf("%w: duplicate data source %q", ErrInvalidArg, name)
+		}
+
+		switch parts[2] {
+		case Compute:
+			if err := ValidateRPN(parts[3], names...); err != nil {
+				return fmt.Errorf("data source %v: %w", name, err)
+			}
+		case Gauge, Counter, DCounter, Derive, DDerive, Absolute:
+			info, err := dsInfo(d, i)
+			if err != nil {
+				return err
+			}
+			if info.Min > info.Max {
+				return fmt.Errorf("%w: data source %v min %v is greater than its max %v", ErrInvalidArg, name, info.Min, info.Max)
+			}
+		default:
+			return fmt.Errorf("%w: data source %v has unknown type %q", ErrInvalidArg, name, parts[2])
+		}
+		names = append(names, name)
+	}
+	return nil
+}
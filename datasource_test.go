@@ -31,6 +31,13 @@ func TestDS(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			assert.Equal(t, tc.expect, string(tc.ds))
+			assert.Equal(t, testDS, tc.ds.Name())
 		})
 	}
 }
+
+func TestDSNameInvalid(t *testing.T) {
+	for _, d := range []DS{"", "DS:a", "RRA:AVERAGE:0.5:1:10"} {
+		assert.Empty(t, d.Name(), string(d))
+	}
+}
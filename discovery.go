@@ -0,0 +1,154 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resolver is the subset of net.Resolver used by DNSDiscovery.
+type resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DNSDiscovery resolves the address passed to NewClient via DNS, re-resolving
+// it every interval so the client follows DNS based failover.
+//
+// An address starting with an underscore, such as
+// "_rrdcached._tcp.example.com", is resolved as an SRV record with servers
+// used in priority order, otherwise all the A and AAAA records of the host
+// are used. Discovered servers are used as with Failover, with any Failover
+// addresses appended.
+func DNSDiscovery(interval time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if interval <= 0 {
+			return fmt.Errorf("%w: discovery interval %v", ErrInvalidArg, interval)
+		}
+		c.discovery = interval
+		return nil
+	}
+}
+
+// DNSResolver sets the resolver used by DNSDiscovery.
+func DNSResolver(r *net.Resolver) func(*Client) error {
+	return func(c *Client) error {
+		if r == nil {
+			return fmt.Errorf("%w: nil resolver", ErrInvalidArg)
+		}
+		c.resolver = r
+		return nil
+	}
+}
+
+// resolve returns the addresses of the servers for name.
+func (c *Client) resolve(ctx context.Context, name string) ([]string, error) {
+	if c.network != "tcp" {
+		return nil, fmt.Errorf("%w: discovery requires tcp, not %v", ErrInvalidArg, c.network)
+	}
+
+	if strings.HasPrefix(name, "_") {
+		_, srvs, err := c.resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		return srvAddrs(srvs), nil
+	}
+
+	host, port, err := net.SplitHostPort(c.normalizeAddr(name))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArg, err)
+	}
+	hosts, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(hosts))
+	for i, h := range hosts {
+		addrs[i] = net.JoinHostPort(h, port)
+	}
+	return addrs, nil
+}
+
+// srvAddrs returns the addresses of srvs, which are expected to be sorted
+// by priority, skipping any which indicate the service isn't available.
+func srvAddrs(srvs []*net.SRV) []string {
+	addrs := make([]string, 0, len(srvs))
+	for _, s := range srvs {
+		target := strings.TrimSuffix(s.Target, ".")
+		if target == "" {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(target, strconv.Itoa(int(s.Port))))
+	}
+	return addrs
+}
+
+// discover resolves name and updates the addresses of the servers,
+// returning an error if no servers were found.
+func (c *Client) discover(ctx context.Context, name string) error {
+	addrs, err := c.resolve(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %q: %w", name, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("failed to resolve %q: no servers found", name)
+	}
+
+	c.setAddrs(append(addrs, c.backups...))
+	return nil
+}
+
+// startDiscovery starts the goroutine which periodically re-resolves name
+// if DNSDiscovery is enabled.
+func (c *Client) startDiscovery(name string) {
+	if c.discovery == 0 {
+		return
+	}
+
+	c.wg.Add(1)
+	go c.discoveryLoop(name)
+}
+
+// discoveryLoop re-resolves name every discovery interval until stopped,
+// keeping the current servers if resolving fails.
+func (c *Client) discoveryLoop(name string) {
+	defer c.wg.Done()
+
+	t := time.NewTicker(c.discovery)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+		}
+
+		prev := c.Addrs()
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		err := c.discover(ctx, name)
+		cancel()
+		if err != nil {
+			c.logger.Warn("discovery failed", "name", name, "error", err)
+			continue
+		}
+		if addrs := c.Addrs(); !slices.Equal(prev, addrs) {
+			c.logger.Info("discovered servers changed", "name", name, "addrs", addrs)
+		}
+	}
+}
+
+// Addrs returns the addresses of the servers the client may use in priority
+// order, as configured by Failover or found by DNSDiscovery.
+func (c *Client) Addrs() []string {
+	c.addrMu.Lock()
+	defer c.addrMu.Unlock()
+	if len(c.addrs) == 0 {
+		return []string{c.addr}
+	}
+	return slices.Clone(c.addrs)
+}
@@ -0,0 +1,178 @@
+package rrd
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver is a resolver which returns configured results.
+type fakeResolver struct {
+	mtx   sync.Mutex
+	srvs  map[string][]*net.SRV
+	hosts map[string][]string
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	srvs, ok := r.srvs[name]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return name, srvs, nil
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	hosts, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return hosts, nil
+}
+
+func (r *fakeResolver) setSRV(name string, srvs ...*net.SRV) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.srvs[name] = srvs
+}
+
+func withResolver(r resolver) func(*Client) error {
+	return func(c *Client) error {
+		c.resolver = r
+		return nil
+	}
+}
+
+// srv returns an SRV record for the server at addr.
+func srv(t *testing.T, addr string) *net.SRV {
+	host, port, err := net.SplitHostPort(addr)
+	assert.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	assert.NoError(t, err)
+	return &net.SRV{Target: host + ".", Port: uint16(p)}
+}
+
+func TestSRVAddrs(t *testing.T) {
+	srvs := []*net.SRV{
+		{Target: "a.example.com.", Port: 42217},
+		{Target: ".", Port: 0},
+		{Target: "::1", Port: 1234},
+	}
+	assert.Equal(t, []string{"a.example.com:42217", "[::1]:1234"}, srvAddrs(srvs))
+}
+
+func TestClientResolve(t *testing.T) {
+	r := &fakeResolver{
+		srvs: map[string][]*net.SRV{
+			"_rrdcached._tcp.example.com": {
+				{Target: "a.example.com.", Port: 1000},
+				{Target: "b.example.com.", Port: 1001},
+			},
+		},
+		hosts: map[string][]string{
+			"example.com": {"10.0.0.1", "2001:db8::1"},
+		},
+	}
+	c := &Client{network: "tcp", port: DefaultPort, resolver: r}
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		expect []string
+		err    bool
+	}{
+		{name: "_rrdcached._tcp.example.com", expect: []string{"a.example.com:1000", "b.example.com:1001"}},
+		{name: "example.com", expect: []string{"10.0.0.1:42217", "[2001:db8::1]:42217"}},
+		{name: "example.com:1234", expect: []string{"10.0.0.1:1234", "[2001:db8::1]:1234"}},
+		{name: "missing.example.com", err: true},
+		{name: "_missing._tcp.example.com", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			addrs, err := c.resolve(ctx, tc.name)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, addrs)
+		})
+	}
+
+	unix := &Client{network: "unix", resolver: r}
+	_, err := unix.resolve(ctx, "example.com")
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
+
+func TestClientDNSDiscovery(t *testing.T) {
+	_, err := NewClient("", DNSDiscovery(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewClient("", DNSResolver(nil))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	s1 := newServer(t)
+	if s1 == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s1.Close())
+	}()
+	s2 := newServer(t)
+	if s2 == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s2.Close())
+	}()
+
+	const name = "_rrdcached._tcp.example.com"
+	r := &fakeResolver{srvs: map[string][]*net.SRV{name: {srv(t, s1.Addr)}}}
+
+	_, err = NewClient("_missing._tcp.example.com", DNSDiscovery(time.Millisecond*10), withResolver(r))
+	assert.Error(t, err)
+
+	c, err := NewClient(name,
+		Timeout(time.Second*2),
+		DNSDiscovery(time.Millisecond*10),
+		withResolver(r),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+	assert.Equal(t, s1.Addr, c.Addr())
+	assert.Equal(t, []string{s1.Addr}, c.Addrs())
+
+	// Adding a lower priority server keeps the current one.
+	r.setSRV(name, srv(t, s1.Addr), srv(t, s2.Addr))
+	assert.Eventually(t, func() bool {
+		return len(c.Addrs()) == 2
+	}, time.Second, time.Millisecond*10)
+	assert.NoError(t, c.Ping())
+	assert.Equal(t, s1.Addr, c.Addr())
+
+	// Removing the current server moves the client to the new one.
+	r.setSRV(name, srv(t, s2.Addr))
+	assert.Eventually(t, func() bool {
+		return c.Ping() == nil && s2.count("ping") > 0
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, s2.Addr, c.Addr())
+
+	// Resolution failures keep the existing servers.
+	r.setSRV(name)
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, []string{s2.Addr}, c.Addrs())
+	assert.NoError(t, c.Ping())
+}
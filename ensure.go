@@ -0,0 +1,103 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SchemaMismatchError is the error returned by EnsureExists when an
+// existing RRD isn't compatible with the requested definition. It matches
+// ErrSchemaMismatch.
+type SchemaMismatchError struct {
+	Filename string
+
+	// Diffs are the differences from the existing RRD to the definition.
+	Diffs []InfoDifference
+}
+
+func (e *SchemaMismatchError) Error() string {
+	msgs := make([]string, len(e.Diffs))
+	for i, d := range e.Diffs {
+		msgs[i] = d.String()
+	}
+	return fmt.Sprintf("%v: %v: %v", e.Filename, ErrSchemaMismatch, strings.Join(msgs, ", "))
+}
+
+// Is returns true if target is ErrSchemaMismatch, false otherwise.
+func (e *SchemaMismatchError) Is(target error) bool {
+	return target == ErrSchemaMismatch
+}
+
+// EnsureExists creates filename from def if it doesn't exist, otherwise
+// checks that the existing RRD is compatible with def, returning a
+// *SchemaMismatchError if not. See EnsureExistsWithContext.
+func (c *Client) EnsureExists(filename string, def *CreateRRD) error {
+	return c.EnsureExistsWithContext(context.Background(), filename, def)
+}
+
+// EnsureExistsWithContext creates filename from def if it doesn't exist,
+// otherwise checks that the existing RRD is compatible with def, returning
+// a *SchemaMismatchError if not.
+// The step, data sources and archives are compared, ignoring the
+// parameters of Holt-Winters archives and the order of archives. Existing
+// RRDs aren't checked if def copies from a template or source RRD.
+func (c *Client) EnsureExistsWithContext(ctx context.Context, filename string, def *CreateRRD) error {
+	var expected *RRDInfo
+	if !def.hasOption("-t") && !def.hasOption("-r") {
+		var err error
+		if expected, err = def.Info(); err != nil {
+			return err
+		}
+	}
+
+	info, err := c.InfoStructWithContext(ctx, filename)
+	if IsNotExist(err) {
+		create := *def
+		if !create.hasOption("-O") {
+			create.Options = append(append([]CreateOption{}, def.Options...), NoOverwrite())
+		}
+		if err = c.CreateFromWithContext(ctx, filename, &create); !IsExist(err) {
+			return err
+		}
+		// Created concurrently, check it.
+		info, err = c.InfoStructWithContext(ctx, filename)
+	}
+	if err != nil || expected == nil {
+		return err
+	}
+
+	if diffs := schemaDiff(info, expected); len(diffs) > 0 {
+		return &SchemaMismatchError{Filename: filename, Diffs: diffs}
+	}
+	return nil
+}
+
+// schemaDiff returns the differences between the schema of the existing RRD
+// described by info and expected which make them incompatible.
+func schemaDiff(info, expected *RRDInfo) []InfoDifference {
+	a, b := *info, *expected
+	a.RRA, b.RRA = standardRRAs(info.RRA), standardRRAs(expected.RRA)
+
+	var diffs []InfoDifference
+	for _, d := range InfoDiff(&a, &b) {
+		if d.Kind == DiffChanged && strings.HasPrefix(d.Path, "rra[") && strings.HasSuffix(d.Path, ".index") {
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// standardRRAs returns the archives of rras which aren't Holt-Winters
+// archives, without parameters.
+func standardRRAs(rras []RRAInfo) []RRAInfo {
+	var res []RRAInfo
+	for _, r := range rras {
+		if ConsolidationFunc(r.CF).Valid() {
+			r.Params = nil
+			res = append(res, r)
+		}
+	}
+	return res
+}
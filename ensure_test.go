@@ -0,0 +1,86 @@
+package rrd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureExists(t *testing.T) {
+	info := []string{
+		"12 Info for test.rrd follows",
+		"filename 2 test.rrd",
+		"step 1 300",
+		"ds[watts].index 1 0",
+		"ds[watts].type 2 GAUGE",
+		"ds[watts].minimal_heartbeat 1 600",
+		"ds[watts].min 0 0.0000000000e+00",
+		"ds[watts].max 0 nan",
+		"rra[0].cf 2 AVERAGE",
+		"rra[0].rows 1 288",
+		"rra[0].cur_row 1 12",
+		"rra[0].pdp_per_row 1 1",
+		"rra[0].xff 0 5.0000000000e-01",
+	}
+	def := func() *CreateRRD {
+		return NewCreateRRD([]DS{"DS:watts:GAUGE:10m:0:U"}, []RRA{NewAverage(0.5, 1, 288)})
+	}
+
+	tests := []struct {
+		name    string
+		info    []string
+		def     *CreateRRD
+		creates int
+		diffs   []string
+	}{
+		{"match", info, def(), 0, nil},
+		{"missing", []string{"-1 No such file: /test.rrd"}, def(), 1, nil},
+		{"mismatch", info, def().WithStep(time.Minute).WithRRA(NewMax(0.5, 1, 288)), 0, []string{
+			"changed step: 5m0s -> 1m0s",
+			"added rra[1]",
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerStopped(t)
+			if s == nil {
+				return
+			}
+			s.responses = map[string][]string{"info": tc.info}
+			s.Start()
+			defer func() {
+				assert.NoError(t, s.Close())
+			}()
+
+			c, err := NewClient(s.Addr, Timeout(time.Second*2))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			err = c.EnsureExists("test.rrd", tc.def)
+			assert.Equal(t, tc.creates, s.count("create test.rrd -O"))
+			if tc.diffs == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrSchemaMismatch)
+			var serr *SchemaMismatchError
+			if !assert.True(t, errors.As(err, &serr)) {
+				return
+			}
+			assert.Equal(t, "test.rrd", serr.Filename)
+			diffs := make([]string, len(serr.Diffs))
+			for i, d := range serr.Diffs {
+				diffs[i] = d.String()
+			}
+			assert.Equal(t, tc.diffs, diffs)
+		})
+	}
+}
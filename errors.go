@@ -9,6 +9,97 @@ import (
 var (
 	// ErrNilOption is returned by NewClient if an option is nil.
 	ErrNilOption = errors.New("nil option")
+
+	// ErrCircuitOpen is returned without contacting the server while the
+	// clients CircuitBreaker is open.
+	ErrCircuitOpen = errors.New("circuit open")
+
+	// ErrExist is matched by errors returned from the server when creating
+	// a rrd which already exists, see IsExist.
+	ErrExist = errors.New("already exists")
+
+	// ErrIllegalUpdate is matched by errors returned from the server for an
+	// update which isn't after the last update, see IsIllegalUpdate.
+	ErrIllegalUpdate = errors.New("illegal update")
+
+	// ErrInvalidArg is returned if a command argument contains characters,
+	// such as a newline, which can't be sent to the server.
+	ErrInvalidArg = errors.New("invalid argument")
+
+	// ErrInvalidCF is returned if an unknown consolidation function is used.
+	ErrInvalidCF = errors.New("invalid consolidation function")
+
+	// ErrInvalidRRAIndex is returned by First if the archive index is negative.
+	ErrInvalidRRAIndex = errors.New("invalid rra index")
+
+	// ErrInvalidTuneOption is returned by Tune if an option is invalid or
+	// conflicts with another.
+	ErrInvalidTuneOption = errors.New("invalid tune option")
+
+	// ErrInvalidPoolSize is returned by NewPool if size is less than one.
+	ErrInvalidPoolSize = errors.New("invalid pool size")
+
+	// ErrNoDS is returned when creating a RRD without any data sources.
+	ErrNoDS = errors.New("no data sources")
+
+	// ErrNoRRA is returned when creating a RRD without any round robin archives.
+	ErrNoRRA = errors.New("no round robin archives")
+
+	// ErrNoSamples is returned by Update if no samples are specified.
+	ErrNoSamples = errors.New("no samples")
+
+	// ErrNoTuneOptions is returned by Tune if no options are specified.
+	ErrNoTuneOptions = errors.New("no tune options")
+
+	// ErrNotConnected is returned if the connection to the server has failed
+	// and the client was created with NoReconnect.
+	ErrNotConnected = errors.New("not connected")
+
+	// ErrNotSupported is returned, or matched by errors returned from the
+	// server, when the server doesn't support a command.
+	ErrNotSupported = errors.New("command not supported")
+
+	// ErrNotFound is matched by errors returned from the server for a
+	// non-existing or non-cached rrd, see IsNotExist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrPermissionDenied is matched by errors returned from the server when
+	// it doesn't have permission to access a rrd.
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrPoolClosed is returned by Pool methods once the pool has been closed.
+	ErrPoolClosed = errors.New("pool closed")
+
+	// ErrQueueFull is returned by AsyncWriter.Enqueue when accepting the
+	// samples would exceed the writers maximum pending samples, and
+	// reported by a ChanWriter when it drops samples.
+	ErrQueueFull = errors.New("queue full")
+
+	// ErrRateLimited is returned if a command can't be sent within its
+	// context deadline due to the clients RateLimit.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrReadOnly is returned by ExecCmd if the client is read only and cmd modifies data.
+	ErrReadOnly = errors.New("command not permitted on read only client")
+
+	// ErrSchemaMismatch is matched by the error returned by EnsureExists if
+	// an existing RRD doesn't match the requested definition.
+	ErrSchemaMismatch = errors.New("schema mismatch")
+
+	// ErrSlowResponse is matched by the error returned by Health if the
+	// server took longer than the clients HealthThreshold to respond.
+	ErrSlowResponse = errors.New("slow response")
+
+	// ErrUnknownDS is returned if a RRD or fetch result doesn't have a
+	// referenced data source.
+	ErrUnknownDS = errors.New("unknown data source")
+
+	// ErrUnhealthy is matched by the error returned by Health if the server
+	// isn't healthy.
+	ErrUnhealthy = errors.New("unhealthy")
+
+	// ErrWriterClosed is returned by AsyncWriter methods once the writer has been closed.
+	ErrWriterClosed = errors.New("writer closed")
 )
 
 // Error represents a error returned from the rrdcached server.
@@ -26,22 +117,50 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("%v (%v)", e.Msg, e.Code)
 }
 
+// Is returns true if target is the sentinel error which e represents, so
+// server errors can be checked with errors.Is, false otherwise.
+// The supported sentinels are ErrNotFound, ErrExist, ErrPermissionDenied,
+// ErrIllegalUpdate and ErrNotSupported.
+func (e *Error) Is(target error) bool {
+	if e.Code != -1 {
+		return false
+	}
+
+	switch target {
+	case ErrNotFound:
+		return e.notExist()
+	case ErrExist:
+		return strings.Contains(e.Msg, "File exists")
+	case ErrPermissionDenied:
+		return strings.Contains(e.Msg, "Permission denied")
+	case ErrIllegalUpdate:
+		return strings.HasPrefix(e.Msg, "illegal attempt to update using time")
+	case ErrNotSupported:
+		return strings.HasPrefix(e.Msg, "Unknown command")
+	}
+	return false
+}
+
+// notExist returns true if e represents a failure due to a non-existing rrd.
+// Commands which check the file report "No such file: <file>" where as those
+// which check the cache, such as forget and pending, report the ENOENT error.
+func (e *Error) notExist() bool {
+	return (strings.HasPrefix(e.Msg, "No such file:") || strings.HasPrefix(e.Msg, "No such file or directory"))
+}
+
 // IsExist returns true if err represents a failure due to a existing rrd, false otherwise.
 func IsExist(err error) bool {
-	err2, ok := err.(*Error)
-	return ok && err2.Code == -1 && strings.Contains(err2.Msg, "File exists")
+	return errors.Is(err, ErrExist)
 }
 
 // IsNotExist returns true if err represents a failure due to a non-existing rrd, false otherwise.
 func IsNotExist(err error) bool {
-	err2, ok := err.(*Error)
-	return ok && err2.Code == -1 && strings.HasPrefix(err2.Msg, "No such file:")
+	return errors.Is(err, ErrNotFound)
 }
 
 // IsIllegalUpdate returns true if err represents a failure due to an illegal update, false otherwise.
 func IsIllegalUpdate(err error) bool {
-	err2, ok := err.(*Error)
-	return ok && err2.Code == -1 && strings.HasPrefix(err2.Msg, "illegal attempt to update using time")
+	return errors.Is(err, ErrIllegalUpdate)
 }
 
 // InvalidResponseError is the error returned when the response data was invalid.
@@ -58,3 +177,19 @@ func NewInvalidResponseError(reason string, lines ...string) *InvalidResponseErr
 func (e *InvalidResponseError) Error() string {
 	return fmt.Sprintf("%v (%v)", e.Reason, strings.Join(e.Data, ", "))
 }
+
+// CommandError is the error returned when executing a command fails.
+type CommandError struct {
+	// Cmd is the command which failed, without arguments if the client redacts them.
+	Cmd string
+	Err error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Cmd, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
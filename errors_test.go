@@ -1,6 +1,7 @@
 package rrd
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,6 +15,7 @@ func TestErrors(t *testing.T) {
 	}{
 		{"error", NewError(-1, "file not found"), "file not found (-1)"},
 		{"invalid-response", NewInvalidResponseError("bad line", "bad line1", "bad line2"), "bad line (bad line1, bad line2)"},
+		{"command", &CommandError{Cmd: "info test.rrd", Err: NewError(-1, "No such file: test.rrd")}, "info test.rrd: No such file: test.rrd (-1)"},
 	}
 
 	for _, tc := range tests {
@@ -23,6 +25,11 @@ func TestErrors(t *testing.T) {
 	}
 }
 
+// is returns a function which returns true if its error matches target.
+func is(target error) func(error) bool {
+	return func(err error) bool { return errors.Is(err, target) }
+}
+
 func TestErrorHelpers(t *testing.T) {
 	tests := []struct {
 		name string
@@ -31,7 +38,13 @@ func TestErrorHelpers(t *testing.T) {
 	}{
 		{"exists", NewError(-1, "RRD Error: creating '/test.rrd': File exists"), IsExist},
 		{"not-exists", NewError(-1, "No such file: /test-missing.rrd"), IsNotExist},
+		{"not-cached", NewError(-1, "No such file or directory"), IsNotExist},
+		{"not-found", NewError(-1, "No such file or directory"), is(ErrNotFound)},
 		{"illegal-update", NewError(-1, "illegal attempt to update using time 1499968801.000000 when last update time is 1499968801.000000 (minimum one second step)"), IsIllegalUpdate},
+		{"sentinel-exists", NewError(-1, "RRD Error: creating '/test.rrd': File exists"), is(ErrExist)},
+		{"sentinel-permission", NewError(-1, "RRD Error: opening '/test.rrd': Permission denied"), is(ErrPermissionDenied)},
+		{"sentinel-illegal-update", NewError(-1, "illegal attempt to update using time 1 when last update time is 1 (minimum one second step)"), is(ErrIllegalUpdate)},
+		{"sentinel-not-supported", NewError(-1, "Unknown command: TUNE"), is(ErrNotSupported)},
 	}
 
 	err2 := NewError(-1, "Some other error")
@@ -41,6 +54,7 @@ func TestErrorHelpers(t *testing.T) {
 				return
 			}
 			assert.True(t, tc.f(tc.err))
+			assert.True(t, tc.f(&CommandError{Cmd: "test", Err: tc.err}))
 
 			if !assert.Error(t, err2) {
 				return
@@ -0,0 +1,307 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// exportDefaultRange is the range exported if XportDef has no start.
+const exportDefaultRange = 24 * time.Hour
+
+// exportDefaultStep is the step in seconds used if XportDef has no step and
+// no DEFs.
+const exportDefaultStep = 300
+
+// exportDef is a parsed DEF.
+type exportDef struct {
+	vname    string
+	filename string
+	ds       string
+	cf       ConsolidationFunc
+}
+
+// exportCDef is a parsed CDEF.
+type exportCDef struct {
+	vname string
+	expr  *rpnExpr
+}
+
+// exportPlan is a parsed XportDef.
+type exportPlan struct {
+	// cdefs are in the order they were defined, so each only references
+	// variables defined before it.
+	defs  []exportDef
+	cdefs []exportCDef
+
+	xports []exportXport
+}
+
+// exportXport is a parsed XPORT.
+type exportXport struct {
+	vname  string
+	legend string
+}
+
+// splitEscaped splits s at colons which aren't escaped with a backslash,
+// unescaping those which are.
+func splitEscaped(s string) []string {
+	var fields []string
+	for {
+		f := unescapedField(s)
+		fields = append(fields, f)
+		n := len(f) + strings.Count(f, ":")
+		if n >= len(s) {
+			return fields
+		}
+		s = s[n+1:]
+	}
+}
+
+// validVname returns true if vname is a valid rrdtool variable name.
+func validVname(vname string) bool {
+	if vname == "" || len(vname) > 255 {
+		return false
+	}
+	for _, r := range vname {
+		if !isDSNameChar(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDSNameChar returns true if r is valid in a data source name.
+func isDSNameChar(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// parseExport parses the definitions of d.
+func parseExport(d *XportDef) (*exportPlan, error) {
+	p := &exportPlan{}
+	vars := make(map[string]bool)
+	define := func(vname string) error {
+		switch {
+		case !validVname(vname):
+			return fmt.Errorf("%w: invalid vname %q", ErrInvalidArg, vname)
+		case vars[vname]:
+			return fmt.Errorf("%w: duplicate vname %q", ErrInvalidArg, vname)
+		}
+		vars[vname] = true
+		return nil
+	}
+
+	for _, def := range d.Defs {
+		kind, rest, _ := strings.Cut(def, ":")
+		switch kind {
+		case "DEF":
+			// DEF:<vname>=<rrdfile>:<ds-name>:<CF>
+			vname, rest, ok := strings.Cut(rest, "=")
+			fields := splitEscaped(rest)
+			if !ok || len(fields) < 3 || fields[0] == "" || fields[1] == "" {
+				return nil, fmt.Errorf("%w: invalid definition %q", ErrInvalidArg, def)
+			}
+			if len(fields) > 3 {
+				return nil, fmt.Errorf("%w: DEF options %q", ErrNotSupported, def)
+			}
+			cf, err := ConsolidationFunc(fields[2]).normalize()
+			if err != nil {
+				return nil, err
+			}
+			if err := define(vname); err != nil {
+				return nil, err
+			}
+			p.defs = append(p.defs, exportDef{vname: vname, filename: fields[0], ds: fields[1], cf: cf})
+		case "CDEF":
+			// CDEF:<vname>=<rpn expression>
+			vname, expr, ok := strings.Cut(rest, "=")
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid definition %q", ErrInvalidArg, def)
+			}
+			e, err := parseRPN(expr, vars)
+			if err != nil {
+				return nil, err
+			}
+			if err := define(vname); err != nil {
+				return nil, err
+			}
+			p.cdefs = append(p.cdefs, exportCDef{vname: vname, expr: e})
+		case "XPORT":
+			// XPORT:<vname>[:<legend>]
+			vname, legend, _ := strings.Cut(rest, ":")
+			if !vars[vname] {
+				return nil, fmt.Errorf("%w: unknown vname %q", ErrInvalidArg, vname)
+			}
+			if legend == "" {
+				legend = vname
+			}
+			p.xports = append(p.xports, exportXport{vname: vname, legend: legend})
+		default:
+			return nil, fmt.Errorf("%w: definition %q", ErrNotSupported, def)
+		}
+	}
+
+	if len(p.xports) == 0 {
+		return nil, fmt.Errorf("%w: no XPORT definitions", ErrInvalidArg)
+	}
+	return p, nil
+}
+
+// exportKey identifies the fetch of a DEF, each RRD and consolidation
+// function is only fetched once.
+type exportKey struct {
+	filename string
+	cf       ConsolidationFunc
+}
+
+// Export exports the data described by def like Xport, but without
+// requiring the rrdtool binary. Each RRD referenced by a DEF is fetched from
+// the server and the results aligned on a common step, which defaults to the
+// least common multiple of their steps, averaging or repeating values as
+// required. CDEFs are then evaluated against the aligned values.
+//
+// Only DEFs without options, CDEFs and XPORTs are supported. CDEFs support
+// the arithmetic, comparison and stack operators, IF, LIMIT, UN, ISINF,
+// MIN, MAX, MINNAN, MAXNAN, ADDNAN, ABS, SQRT, LOG, EXP, FLOOR, CEIL, UNKN,
+// INF, NEGINF, TIME, STEPWIDTH, COUNT, PREV and PREV(vname), which allows
+// rates to be computed with e.g. "CDEF:rate=x,PREV(x),-,STEPWIDTH,/".
+// XPORTs without a legend use their vname.
+func (c *Client) Export(ctx context.Context, def *XportDef) (*XportResult, error) {
+	p, err := parseExport(def)
+	if err != nil {
+		return nil, fmt.Errorf("export: %w", err)
+	}
+
+	end, start := def.End, def.Start
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		start = end.Add(-exportDefaultRange)
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("export: %w: start %v not before end %v", ErrInvalidArg, start, end)
+	}
+
+	fetched := make(map[exportKey]*FetchResult)
+	step := int64(def.Step / time.Second)
+	for _, d := range p.defs {
+		k := exportKey{filename: d.filename, cf: d.cf}
+		if _, ok := fetched[k]; ok {
+			continue
+		}
+		r, err := c.FetchRangeWithContext(ctx, d.filename, d.cf, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("export: fetch %v: %w", d.filename, err)
+		}
+		if r.Step < time.Second {
+			return nil, NewInvalidResponseError("export: invalid step", r.Step.String())
+		}
+		fetched[k] = r
+		if def.Step <= 0 {
+			step = lcm(max(step, 1), int64(r.Step/time.Second))
+		}
+	}
+
+	if step <= 0 {
+		step = exportDefaultStep
+	}
+
+	first := start.Unix() / step * step
+	last := (end.Unix() + step - 1) / step * step
+	n := int((last - first) / step)
+	res := &XportResult{
+		Start:   time.Unix(first, 0),
+		End:     time.Unix(last, 0),
+		Step:    time.Duration(step) * time.Second,
+		Times:   make([]time.Time, n),
+		Columns: make([]XportColumn, len(p.xports)),
+	}
+	for i := range res.Times {
+		res.Times[i] = time.Unix(first+int64(i+1)*step, 0)
+	}
+
+	vars := make(map[string][]float64, len(p.defs)+len(p.cdefs))
+	for _, d := range p.defs {
+		r := fetched[exportKey{filename: d.filename, cf: d.cf}]
+		col := -1
+		for i, name := range r.Names {
+			if name == d.ds {
+				col = i
+				break
+			}
+		}
+		if col == -1 {
+			return nil, fmt.Errorf("export: %w: %v has no data source %q", ErrInvalidArg, d.filename, d.ds)
+		}
+		vars[d.vname] = resample(r, col, res.Times, step)
+	}
+
+	for _, cd := range p.cdefs {
+		values := make([]float64, n)
+		rc := &rpnContext{step: float64(step), vars: vars, self: values}
+		for i, t := range res.Times {
+			rc.row, rc.time = i, float64(t.Unix())
+			v, err := cd.expr.eval(rc)
+			if err != nil {
+				return nil, fmt.Errorf("export: CDEF %v: %w", cd.vname, err)
+			}
+			values[i] = v
+		}
+		vars[cd.vname] = values
+	}
+
+	for i, x := range p.xports {
+		res.Columns[i] = XportColumn{Legend: x.legend, Values: vars[x.vname]}
+	}
+	return res, nil
+}
+
+// resample returns the values of column col of r for the rows ending at
+// times, which are step seconds apart. Each value is the time weighted
+// average of the known values of r overlapping its row, or NaN if none are
+// known.
+func resample(r *FetchResult, col int, times []time.Time, step int64) []float64 {
+	values := make([]float64, len(times))
+	srcStep := int64(r.Step / time.Second)
+	j := 0
+	for i, t := range times {
+		hi := t.Unix()
+		lo := hi - step
+		for j < len(r.Rows) && r.Rows[j].Time.Unix() <= lo {
+			j++
+		}
+
+		var sum, weight float64
+		for k := j; k < len(r.Rows); k++ {
+			rowEnd := r.Rows[k].Time.Unix()
+			rowStart := rowEnd - srcStep
+			if rowStart >= hi {
+				break
+			}
+			if col >= len(r.Rows[k].Values) || math.IsNaN(r.Rows[k].Values[col]) {
+				continue
+			}
+			w := float64(min(hi, rowEnd) - max(lo, rowStart))
+			sum += r.Rows[k].Values[col] * w
+			weight += w
+		}
+		if weight == 0 {
+			values[i] = math.NaN()
+		} else {
+			values[i] = sum / weight
+		}
+	}
+	return values
+}
+
+// lcm returns the least common multiple of a and b, which must be positive.
+func lcm(a, b int64) int64 {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
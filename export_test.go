@@ -0,0 +1,156 @@
+package rrd
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testExportFetch is a fetch response with a one minute step.
+var testExportFetch = []string{
+	"10 Success",
+	"FlushVersion: 1",
+	"Start: 1000020",
+	"End: 1000260",
+	"Step: 60",
+	"DSCount: 2",
+	"DSName: watts amps",
+	"1000080: 1 10",
+	"1000140: 2 20",
+	"1000200: nan 30",
+	"1000260: 4 40",
+}
+
+func TestExport(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{"fetch": testExportFetch}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	nan := math.NaN()
+	tests := []struct {
+		name    string
+		step    time.Duration
+		defs    []string
+		times   []int64
+		columns []XportColumn
+	}{
+		{
+			name: "cdefs",
+			defs: []string{
+				"DEF:w=a.rrd:watts:AVERAGE",
+				"DEF:a=a.rrd:amps:AVERAGE",
+				"CDEF:sum=w,a,+",
+				"CDEF:rate=a,PREV(a),-,STEPWIDTH,/",
+				"CDEF:scaled=w,1000,*",
+				"XPORT:sum:Sum",
+				"XPORT:rate",
+				"XPORT:scaled:Milliwatts",
+			},
+			times: []int64{1000080, 1000140, 1000200, 1000260},
+			columns: []XportColumn{
+				{Legend: "Sum", Values: []float64{11, 22, nan, 44}},
+				{Legend: "rate", Values: []float64{nan, 10.0 / 60, 10.0 / 60, 10.0 / 60}},
+				{Legend: "Milliwatts", Values: []float64{1000, 2000, nan, 4000}},
+			},
+		},
+		{
+			name: "step",
+			step: time.Minute * 2,
+			defs: []string{
+				"DEF:w=a.rrd:watts:AVERAGE",
+				"DEF:a=a.rrd:amps:AVERAGE",
+				"XPORT:w",
+				"XPORT:a",
+			},
+			times: []int64{1000080, 1000200, 1000320},
+			columns: []XportColumn{
+				{Legend: "w", Values: []float64{1, 2, 4}},
+				{Legend: "a", Values: []float64{10, 25, 40}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := c.Export(context.Background(), &XportDef{
+				Start: time.Unix(1000020, 0),
+				End:   time.Unix(1000260, 0),
+				Step:  tc.step,
+				Defs:  tc.defs,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			times := make([]int64, len(r.Times))
+			for i, t := range r.Times {
+				times[i] = t.Unix()
+			}
+			assert.Equal(t, tc.times, times)
+			if !assert.Len(t, r.Columns, len(tc.columns)) {
+				return
+			}
+			for i, col := range tc.columns {
+				assert.Equal(t, col.Legend, r.Columns[i].Legend)
+				assertNaNEqual(t, col.Values, r.Columns[i].Values)
+			}
+		})
+	}
+
+	_, err = c.Export(context.Background(), &XportDef{
+		Start: time.Unix(1000020, 0),
+		End:   time.Unix(1000260, 0),
+		Defs:  []string{"DEF:v=a.rrd:volts:AVERAGE", "XPORT:v"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
+
+func TestParseExport(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []string
+		err  error
+	}{
+		{"valid", []string{`DEF:a=c\:/a.rrd:x:average`, "CDEF:b=a,2,*", "XPORT:b:B"}, nil},
+		{"no-xport", []string{"DEF:a=a.rrd:x:AVERAGE"}, ErrInvalidArg},
+		{"unknown-xport", []string{"XPORT:a"}, ErrInvalidArg},
+		{"duplicate", []string{"DEF:a=a.rrd:x:AVERAGE", "CDEF:a=a", "XPORT:a"}, ErrInvalidArg},
+		{"invalid-vname", []string{"DEF:a b=a.rrd:x:AVERAGE", "XPORT:a b"}, ErrInvalidArg},
+		{"invalid-cf", []string{"DEF:a=a.rrd:x:SUM", "XPORT:a"}, ErrInvalidCF},
+		{"missing-ds", []string{"DEF:a=a.rrd", "XPORT:a"}, ErrInvalidArg},
+		{"def-options", []string{"DEF:a=a.rrd:x:AVERAGE:step=60", "XPORT:a"}, ErrNotSupported},
+		{"cdef-forward", []string{"CDEF:b=a,2,*", "DEF:a=a.rrd:x:AVERAGE", "XPORT:b"}, ErrInvalidArg},
+		{"vdef", []string{"DEF:a=a.rrd:x:AVERAGE", "VDEF:m=a,MAXIMUM", "XPORT:a"}, ErrNotSupported},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := parseExport(&XportDef{Defs: tc.defs})
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, []exportDef{{vname: "a", filename: "c:/a.rrd", ds: "x", cf: Average}}, p.defs)
+			assert.Equal(t, []exportXport{{vname: "b", legend: "B"}}, p.xports)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package rrd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultFailbackInterval is the default interval at which a client which
+	// has failed over checks if its primary server has recovered.
+	DefaultFailbackInterval = time.Second * 30
+)
+
+// Failover configures addrs as backup servers, in priority order, which the
+// client fails over to if it can't connect to the server passed to NewClient,
+// the primary. Once failed over the primary is periodically checked and
+// the client fails back to it once it has recovered.
+// Backup addresses use the same network and options as the primary.
+func Failover(addrs ...string) func(*Client) error {
+	return func(c *Client) error {
+		if len(addrs) == 0 {
+			return fmt.Errorf("%w: no failover addresses", ErrInvalidArg)
+		}
+		c.backups = append([]string(nil), addrs...)
+		return nil
+	}
+}
+
+// FailbackInterval sets the interval at which a client which has failed
+// over checks if its primary server has recovered.
+func FailbackInterval(d time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: failback interval %v", ErrInvalidArg, d)
+		}
+		c.failbackInterval = d
+		return nil
+	}
+}
+
+// Addr returns the address of the server the client is using, which may be
+// a backup server if the client was created with Failover or DNSDiscovery.
+func (c *Client) Addr() string {
+	c.addrMu.Lock()
+	defer c.addrMu.Unlock()
+	if len(c.addrs) == 0 {
+		return c.addr
+	}
+	return c.addrs[c.cur]
+}
+
+// setAddrs sets the addresses of the servers in priority order, keeping the
+// current server if it's still present, otherwise flagging the client to
+// reconnect to the new primary on its next command.
+func (c *Client) setAddrs(addrs []string) {
+	c.addrMu.Lock()
+	defer c.addrMu.Unlock()
+
+	var cur string
+	if len(c.addrs) > 0 {
+		cur = c.addrs[c.cur]
+	}
+	c.addrs = addrs
+	c.cur = 0
+	for i, a := range addrs {
+		if a == cur {
+			c.cur = i
+			return
+		}
+	}
+	if cur != "" {
+		c.failback.Store(true)
+	}
+}
+
+// setCurrent records addr as the address of the server in use.
+func (c *Client) setCurrent(addr string) {
+	c.addrMu.Lock()
+	defer c.addrMu.Unlock()
+	for i, a := range c.addrs {
+		if a == addr {
+			c.cur = i
+			return
+		}
+	}
+}
+
+// candidates returns the addresses to try when connecting, starting with the
+// current server followed by the others in priority order.
+func (c *Client) candidates() []string {
+	c.addrMu.Lock()
+	defer c.addrMu.Unlock()
+	if len(c.addrs) == 0 {
+		return nil
+	}
+
+	addrs := make([]string, 0, len(c.addrs))
+	addrs = append(addrs, c.addrs[c.cur])
+	for i, a := range c.addrs {
+		if i != c.cur {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// primary returns the address of the primary server and true if the client
+// is using a different server.
+func (c *Client) primary() (string, bool) {
+	c.addrMu.Lock()
+	defer c.addrMu.Unlock()
+	if len(c.addrs) == 0 {
+		return c.addr, false
+	}
+	return c.addrs[0], c.cur != 0
+}
+
+// startFailback starts the failback goroutine if the client has backup servers.
+func (c *Client) startFailback() {
+	if len(c.backups) == 0 && c.discovery == 0 {
+		return
+	}
+
+	c.wg.Add(1)
+	go c.failbackLoop()
+}
+
+// failbackLoop checks the primary server while failed over, flagging the
+// client to fail back on its next command once it's healthy.
+func (c *Client) failbackLoop() {
+	defer c.wg.Done()
+
+	t := time.NewTicker(c.failbackInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+		}
+
+		primary, failedOver := c.primary()
+		if !failedOver || c.failback.Load() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		err := c.probe(ctx, primary)
+		cancel()
+		if err != nil {
+			c.logger.Debug("primary still unavailable", "addr", primary, "error", err)
+			continue
+		}
+		c.failback.Store(true)
+	}
+}
+
+// probe checks the server at addr responds to a ping on a new connection.
+func (c *Client) probe(ctx context.Context, addr string) error {
+	conn, err := c.dialAddr(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close() // nolint: errcheck
+
+	if d, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(d); err != nil {
+			return err
+		}
+	}
+
+	if err := writeAll(conn, []byte(NewCmd("ping").String())); err != nil {
+		return err
+	}
+
+	l, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	cnt, msg, err := c.parser(strings.TrimRight(l, "\r\n"))
+	if err != nil {
+		return err
+	}
+	if cnt < 0 {
+		return NewError(cnt, msg)
+	}
+
+	return writeAll(conn, []byte(NewCmd("quit").String()))
+}
+
+// ensureConn fails back to the primary server if it has recovered and
+// connects if not connected.
+// The caller must hold the clients lock.
+func (c *Client) ensureConn(ctx context.Context) error {
+	if c.failback.Swap(false) {
+		if primary, failedOver := c.primary(); failedOver {
+			c.setCurrent(primary)
+		}
+		if c.conn != nil && c.addr != c.Addr() {
+			c.logger.InfoContext(ctx, "failing back", "from", c.addr, "to", c.Addr())
+			c.dropConn() // nolint: errcheck
+		}
+	}
+
+	if c.conn != nil {
+		return nil
+	}
+
+	return c.reconnect(ctx)
+}
@@ -0,0 +1,109 @@
+package rrd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newServerAt returns a running server listening on addr or nil if an error occurred.
+func newServerAt(t *testing.T, addr string) *server {
+	l, err := net.Listen("tcp", addr)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	s := &server{
+		Addr:     addr,
+		Listener: l,
+		conns:    make(map[net.Conn]struct{}),
+		done:     make(chan struct{}),
+		t:        t,
+	}
+	s.Start()
+	return s
+}
+
+func TestClientFailover(t *testing.T) {
+	_, err := NewClient("", Failover())
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewClient("", FailbackInterval(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	primary := newServer(t)
+	if primary == nil {
+		return
+	}
+	backup := newServer(t)
+	if backup == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, backup.Close())
+	}()
+
+	c, err := NewClient(primary.Addr,
+		Timeout(time.Second*2),
+		Failover(backup.Addr),
+		FailbackInterval(time.Millisecond*20),
+		Retry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close() // nolint: errcheck
+
+	assert.NoError(t, c.Ping())
+	assert.Equal(t, primary.Addr, c.Addr())
+
+	// Primary fails so the client fails over to the backup.
+	addr := primary.Addr
+	assert.NoError(t, primary.Close())
+	assert.Eventually(t, func() bool {
+		return c.Ping() == nil && c.Addr() == backup.Addr
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, 1, backup.count("ping"))
+
+	// Primary recovers so the client fails back.
+	primary = newServerAt(t, addr)
+	if primary == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, primary.Close())
+	}()
+	assert.Eventually(t, func() bool {
+		return c.Ping() == nil && c.Addr() == addr
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestClientFailoverInitial(t *testing.T) {
+	// A primary which isn't listening.
+	down, err := newLocalListener()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, down.Close())
+
+	backup := newServer(t)
+	if backup == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, backup.Close())
+	}()
+
+	c, err := NewClient(down.Addr().String(), Timeout(time.Second*2), Failover(backup.Addr))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.Equal(t, backup.Addr, c.Addr())
+	assert.NoError(t, c.Ping())
+	assert.Equal(t, 1, backup.count("ping"))
+}
@@ -0,0 +1,173 @@
+package rrd
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Interpolation is a method of filling gaps in a FetchResult.
+type Interpolation int
+
+const (
+	// InterpolateLinear fills gaps with values on the line between the
+	// known values either side of them, gaps at the start or end of the
+	// result aren't filled.
+	InterpolateLinear Interpolation = iota
+
+	// InterpolatePrevious fills gaps with the preceding known value, gaps at
+	// the start of the result aren't filled.
+	InterpolatePrevious
+
+	// InterpolateZero fills gaps with zero.
+	InterpolateZero
+)
+
+// String implements fmt.Stringer.
+func (i Interpolation) String() string {
+	switch i {
+	case InterpolateLinear:
+		return "linear"
+	case InterpolatePrevious:
+		return "previous"
+	case InterpolateZero:
+		return "zero"
+	}
+	return fmt.Sprintf("Interpolation(%d)", int(i))
+}
+
+// Gap is a run of consecutive unknown values of a data source.
+type Gap struct {
+	DS string
+
+	// Start and End are the times of the first and last unknown rows.
+	Start time.Time
+	End   time.Time
+
+	// Rows is the number of unknown rows.
+	Rows int
+}
+
+// gap is a run of unknown values of a column, from row start to end
+// inclusive.
+type gap struct {
+	start, end int
+}
+
+// gaps returns the runs of unknown values of column col.
+func (r *FetchResult) gaps(col int) []gap {
+	var res []gap
+	start := -1
+	for i, row := range r.Rows {
+		if col < len(row.Values) && !math.IsNaN(row.Values[col]) {
+			if start != -1 {
+				res = append(res, gap{start: start, end: i - 1})
+				start = -1
+			}
+			continue
+		}
+		if start == -1 {
+			start = i
+		}
+	}
+	if start != -1 {
+		res = append(res, gap{start: start, end: len(r.Rows) - 1})
+	}
+	return res
+}
+
+// Gaps returns the runs of unknown values of each data source, ordered by
+// data source as Names and then time.
+func (r *FetchResult) Gaps() []Gap {
+	var res []Gap
+	for col, name := range r.Names {
+		for _, g := range r.gaps(col) {
+			res = append(res, Gap{
+				DS:    name,
+				Start: r.Rows[g.start].Time,
+				End:   r.Rows[g.end].Time,
+				Rows:  g.end - g.start + 1,
+			})
+		}
+	}
+	return res
+}
+
+// Coverage returns the percentage of known values of each data source.
+// Data sources of a result without rows have no coverage.
+func (r *FetchResult) Coverage() map[string]float64 {
+	res := make(map[string]float64, len(r.Names))
+	for col, name := range r.Names {
+		if len(r.Rows) == 0 {
+			res[name] = 0
+			continue
+		}
+		var known int
+		for _, row := range r.Rows {
+			if col < len(row.Values) && !math.IsNaN(row.Values[col]) {
+				known++
+			}
+		}
+		res[name] = float64(known) * 100 / float64(len(r.Rows))
+	}
+	return res
+}
+
+// Interpolate returns a copy of r with the gaps of each data source of at
+// most maxRows rows filled using method. If maxRows is zero gaps of any
+// length are filled.
+func (r *FetchResult) Interpolate(method Interpolation, maxRows int) (*FetchResult, error) {
+	switch {
+	case method < InterpolateLinear || method > InterpolateZero:
+		return nil, fmt.Errorf("%w: interpolation %v", ErrInvalidArg, method)
+	case maxRows < 0:
+		return nil, fmt.Errorf("%w: max rows %v", ErrInvalidArg, maxRows)
+	}
+
+	res := *r
+	res.Rows = make([]FetchResultRow, len(r.Rows))
+	for i, row := range r.Rows {
+		values := make([]float64, len(r.Names))
+		for j := range values {
+			if j < len(row.Values) {
+				values[j] = row.Values[j]
+			} else {
+				values[j] = math.NaN()
+			}
+		}
+		res.Rows[i] = FetchResultRow{Time: row.Time, Values: values}
+	}
+
+	for col := range r.Names {
+		for _, g := range res.gaps(col) {
+			if maxRows > 0 && g.end-g.start+1 > maxRows {
+				continue
+			}
+			res.fill(col, g, method)
+		}
+	}
+	return &res, nil
+}
+
+// fill fills the gap g of column col using method.
+func (r *FetchResult) fill(col int, g gap, method Interpolation) {
+	prev, next := g.start-1, g.end+1
+	for i := g.start; i <= g.end; i++ {
+		v := &r.Rows[i].Values[col]
+		switch method {
+		case InterpolateZero:
+			*v = 0
+		case InterpolatePrevious:
+			if prev >= 0 {
+				*v = r.Rows[prev].Values[col]
+			}
+		case InterpolateLinear:
+			if prev < 0 || next >= len(r.Rows) {
+				return
+			}
+			p, n := r.Rows[prev], r.Rows[next]
+			frac := float64(r.Rows[i].Time.Sub(p.Time)) / float64(n.Time.Sub(p.Time))
+			*v = p.Values[col] + (n.Values[col]-p.Values[col])*frac
+		}
+	}
+}
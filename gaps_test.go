@@ -0,0 +1,86 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testGapsResult returns a FetchResult with gaps at the start, middle and
+// end of its columns.
+func testGapsResult() *FetchResult {
+	nan := math.NaN()
+	r := &FetchResult{
+		Step:  time.Minute,
+		Names: []string{"a", "b"},
+	}
+	for i, v := range [][]float64{
+		{nan, 1},
+		{1, 2},
+		{nan, 3},
+		{nan, 4},
+		{4, nan},
+	} {
+		r.Rows = append(r.Rows, FetchResultRow{Time: time.Unix(int64(i+1)*60, 0), Values: v})
+	}
+	return r
+}
+
+func TestGaps(t *testing.T) {
+	r := testGapsResult()
+	assert.Equal(t, []Gap{
+		{DS: "a", Start: time.Unix(60, 0), End: time.Unix(60, 0), Rows: 1},
+		{DS: "a", Start: time.Unix(180, 0), End: time.Unix(240, 0), Rows: 2},
+		{DS: "b", Start: time.Unix(300, 0), End: time.Unix(300, 0), Rows: 1},
+	}, r.Gaps())
+	assert.Equal(t, map[string]float64{"a": 40, "b": 80}, r.Coverage())
+
+	empty := &FetchResult{Names: []string{"a"}}
+	assert.Empty(t, empty.Gaps())
+	assert.Equal(t, map[string]float64{"a": 0}, empty.Coverage())
+}
+
+func TestInterpolate(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		method  Interpolation
+		maxRows int
+		a       []float64
+		b       []float64
+	}{
+		{InterpolateLinear, 0, []float64{nan, 1, 2, 3, 4}, []float64{1, 2, 3, 4, nan}},
+		{InterpolateLinear, 1, []float64{nan, 1, nan, nan, 4}, []float64{1, 2, 3, 4, nan}},
+		{InterpolatePrevious, 0, []float64{nan, 1, 1, 1, 4}, []float64{1, 2, 3, 4, 4}},
+		{InterpolateZero, 0, []float64{0, 1, 0, 0, 4}, []float64{1, 2, 3, 4, 0}},
+		{InterpolateZero, 1, []float64{0, 1, nan, nan, 4}, []float64{1, 2, 3, 4, 0}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.method.String(), func(t *testing.T) {
+			r := testGapsResult()
+			res, err := r.Interpolate(tc.method, tc.maxRows)
+			if !assert.NoError(t, err) {
+				return
+			}
+			var a, b []float64
+			for _, row := range res.Rows {
+				a = append(a, row.Values[0])
+				b = append(b, row.Values[1])
+			}
+			assertNaNEqual(t, tc.a, a)
+			assertNaNEqual(t, tc.b, b)
+
+			// The original is unchanged.
+			assert.True(t, math.IsNaN(r.Rows[2].Values[0]))
+		})
+	}
+
+	r := testGapsResult()
+	_, err := r.Interpolate(Interpolation(10), 0)
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = r.Interpolate(InterpolateZero, -1)
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	assert.Equal(t, "Interpolation(10)", Interpolation(10).String())
+}
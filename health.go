@@ -0,0 +1,65 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultHealthThreshold is the default maximum round trip time for the
+	// server to be considered healthy by Health.
+	DefaultHealthThreshold = time.Second
+)
+
+// HealthThreshold sets the maximum round trip time for the server to be
+// considered healthy by Health.
+func HealthThreshold(d time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: health threshold %v", ErrInvalidArg, d)
+		}
+		c.healthThreshold = d
+		return nil
+	}
+}
+
+// HealthError is returned by Health if the server isn't healthy.
+// It matches ErrUnhealthy and either ErrSlowResponse or the error of the
+// failed check.
+type HealthError struct {
+	// Latency is the round trip time of the check.
+	Latency time.Duration
+
+	Err error
+}
+
+func (e *HealthError) Error() string {
+	return fmt.Sprintf("%v: %v (latency %v)", ErrUnhealthy, e.Err, e.Latency)
+}
+
+// Unwrap returns the cause of the failure.
+func (e *HealthError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if target is ErrUnhealthy, false otherwise.
+func (e *HealthError) Is(target error) bool {
+	return target == ErrUnhealthy
+}
+
+// Health checks the server is reachable and responsive with a ping, for use
+// in readiness and liveness probes. If the ping fails or its round trip time
+// exceeds the clients HealthThreshold a *HealthError is returned.
+func (c *Client) Health(ctx context.Context) error {
+	start := time.Now()
+	err := c.PingWithContext(ctx)
+	latency := time.Since(start)
+	switch {
+	case err != nil:
+		return &HealthError{Latency: latency, Err: err}
+	case latency > c.healthThreshold:
+		return &HealthError{Latency: latency, Err: fmt.Errorf("%w: exceeds %v", ErrSlowResponse, c.healthThreshold)}
+	}
+	return nil
+}
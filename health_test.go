@@ -0,0 +1,46 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientHealth(t *testing.T) {
+	_, err := NewClient("", HealthThreshold(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+
+	ctx := context.Background()
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), NoReconnect)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close() // nolint: errcheck
+	assert.NoError(t, c.Health(ctx))
+
+	slow, err := NewClient(s.Addr, Timeout(time.Second*2), HealthThreshold(time.Nanosecond))
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = slow.Health(ctx)
+	assert.ErrorIs(t, err, ErrUnhealthy)
+	assert.ErrorIs(t, err, ErrSlowResponse)
+	var herr *HealthError
+	if assert.True(t, errors.As(err, &herr)) {
+		assert.Greater(t, herr.Latency, time.Duration(0))
+	}
+	assert.NoError(t, slow.Close())
+
+	assert.NoError(t, s.Close())
+	err = c.Health(ctx)
+	assert.ErrorIs(t, err, ErrUnhealthy)
+	assert.False(t, errors.Is(err, ErrSlowResponse))
+}
@@ -0,0 +1,80 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IdleTimeout closes the connection once it has been idle for d, so long
+// lived processes which use the server sporadically don't hold a connection
+// open. The next command reconnects transparently. Keepalive pings count as
+// use, so have no effect if the keepalive interval is less than d.
+func IdleTimeout(d time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: idle timeout %v", ErrInvalidArg, d)
+		}
+		c.idleTimeout = d
+		return nil
+	}
+}
+
+// startIdleTimeout starts the idle timeout goroutine if enabled.
+func (c *Client) startIdleTimeout() {
+	if c.idleTimeout == 0 {
+		return
+	}
+
+	c.wg.Add(1)
+	go c.idleLoop()
+}
+
+// idleLoop closes the connection whenever it has been idle for the idle
+// timeout until stopped.
+func (c *Client) idleLoop() {
+	defer c.wg.Done()
+
+	t := time.NewTimer(c.idleTimeout)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			t.Reset(c.idleCheck())
+		}
+	}
+}
+
+// idleCheck closes the connection if it's idle, returning the time until
+// the next check.
+func (c *Client) idleCheck() time.Duration {
+	if !c.m.TryLock() {
+		// In use so not idle.
+		return c.idleTimeout
+	}
+	defer c.m.Unlock()
+
+	if c.conn == nil {
+		return c.idleTimeout
+	}
+	if idle := time.Since(c.used); idle < c.idleTimeout {
+		return c.idleTimeout - idle
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeoutOr(c.writeTimeout))
+	defer cancel()
+	c.logger.DebugContext(ctx, "closing idle connection", "addr", c.addr)
+	err := c.setWriteDeadline(ctx)
+	if err == nil {
+		err = writeAll(c.conn, []byte(NewCmd("quit").String()))
+	}
+	if err2 := c.dropConn(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		c.logger.WarnContext(ctx, "failed to close idle connection", "addr", c.addr, "error", err)
+	}
+	return c.idleTimeout
+}
@@ -0,0 +1,82 @@
+package rrd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientLazyConnect(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), LazyConnect)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	assert.Nil(t, c.conn)
+
+	assert.NoError(t, c.Ping())
+	assert.NotNil(t, c.conn)
+
+	// The server needn't be available.
+	l, err := newLocalListener()
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr := l.Addr().String()
+	assert.NoError(t, l.Close())
+
+	c2, err := NewClient(addr, Timeout(time.Second*2), LazyConnect, NoReconnect)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Error(t, c2.Ping())
+	assert.NoError(t, c2.Close())
+}
+
+func TestClientIdleTimeout(t *testing.T) {
+	_, err := NewClient("", IdleTimeout(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), IdleTimeout(time.Millisecond*50))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	connected := func() bool {
+		c.m.Lock()
+		defer c.m.Unlock()
+		return c.conn != nil
+	}
+
+	// The idle connection is closed gracefully.
+	assert.Eventually(t, func() bool { return !connected() }, time.Second, time.Millisecond*10)
+	assert.Eventually(t, func() bool { return s.count("quit") == 1 }, time.Second, time.Millisecond*10)
+
+	// And reestablished by the next command.
+	assert.NoError(t, c.Ping())
+	assert.True(t, connected())
+	assert.Equal(t, int64(1), c.ClientStats().Reconnects)
+	assert.Eventually(t, func() bool { return !connected() }, time.Second, time.Millisecond*10)
+}
@@ -0,0 +1,191 @@
+package rrd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	infoDSRe  = regexp.MustCompile(`^ds\[([^\]]+)\]\.(\w+)$`)
+	infoRRARe = regexp.MustCompile(`^rra\[(\d+)\]\.(\w+)$`)
+)
+
+// RRDInfo represents the structured configuration information of an RRD.
+// In JSON times are RFC 3339, durations are in seconds and unknown values
+// are null.
+type RRDInfo struct {
+	Filename   string            `json:"filename"`
+	Version    string            `json:"version"`
+	Step       time.Duration     `json:"step"`
+	LastUpdate time.Time         `json:"last_update"`
+	HeaderSize int64             `json:"header_size"`
+	DS         map[string]DSInfo `json:"ds"`
+	RRA        []RRAInfo         `json:"rra"`
+}
+
+// DSInfo represents the configuration and state of a RRD data source.
+type DSInfo struct {
+	Name             string        `json:"name"`
+	Index            int           `json:"index"`
+	Type             string        `json:"type"`
+	MinimalHeartbeat time.Duration `json:"minimal_heartbeat"`
+	Min              float64       `json:"min"`
+	Max              float64       `json:"max"`
+	CDef             string        `json:"cdef,omitempty"`
+	LastDS           string        `json:"last_ds"`
+	Value            float64       `json:"value"`
+	UnknownSec       int64         `json:"unknown_sec"`
+}
+
+// RRAInfo represents the configuration and state of a RRD round robin archive.
+type RRAInfo struct {
+	CF        string  `json:"cf"`
+	Rows      int64   `json:"rows"`
+	CurRow    int64   `json:"cur_row"`
+	PDPPerRow int64   `json:"pdp_per_row"`
+	XFF       float64 `json:"xff"`
+
+	// Params contains any additional parameters such as those of Holt-Winters RRAs.
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// NewRRDInfo returns a new RRDInfo created from info.
+// Per archive state such as cdp_prep is ignored.
+func NewRRDInfo(info []*Info) (*RRDInfo, error) {
+	r := &RRDInfo{DS: make(map[string]DSInfo)}
+	for _, i := range info {
+		var err error
+		switch i.Key {
+		case "filename":
+			r.Filename, err = infoString(i)
+		case "rrd_version":
+			r.Version, err = infoString(i)
+		case "step":
+			r.Step, err = infoDuration(i)
+		case "last_update":
+			var v int64
+			v, err = infoInt(i)
+			r.LastUpdate = time.Unix(v, 0)
+		case "header_size":
+			r.HeaderSize, err = infoInt(i)
+		default:
+			if m := infoDSRe.FindStringSubmatch(i.Key); m != nil {
+				err = r.setDS(m[1], m[2], i)
+			} else if m := infoRRARe.FindStringSubmatch(i.Key); m != nil {
+				err = r.setRRA(m[1], m[2], i)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// setDS sets field of the data source name to the value of i.
+func (r *RRDInfo) setDS(name, field string, i *Info) error {
+	d, ok := r.DS[name]
+	if !ok {
+		d.Name = name
+	}
+
+	var err error
+	switch field {
+	case "index":
+		var v int64
+		v, err = infoInt(i)
+		d.Index = int(v)
+	case "type":
+		d.Type, err = infoString(i)
+	case "minimal_heartbeat":
+		d.MinimalHeartbeat, err = infoDuration(i)
+	case "min":
+		d.Min, err = infoFloat(i)
+	case "max":
+		d.Max, err = infoFloat(i)
+	case "cdef":
+		d.CDef, err = infoString(i)
+	case "last_ds":
+		d.LastDS, err = infoString(i)
+	case "value":
+		d.Value, err = infoFloat(i)
+	case "unknown_sec":
+		d.UnknownSec, err = infoInt(i)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.DS[name] = d
+	return nil
+}
+
+// setRRA sets field of the archive at index idx to the value of i.
+func (r *RRDInfo) setRRA(idx, field string, i *Info) error {
+	n, err := strconv.Atoi(idx)
+	if err != nil {
+		return NewInvalidResponseError(fmt.Sprintf("info: invalid rra index %v", idx), i.Key)
+	}
+	for len(r.RRA) <= n {
+		r.RRA = append(r.RRA, RRAInfo{})
+	}
+
+	a := &r.RRA[n]
+	switch field {
+	case "cf":
+		a.CF, err = infoString(i)
+	case "rows":
+		a.Rows, err = infoInt(i)
+	case "cur_row":
+		a.CurRow, err = infoInt(i)
+	case "pdp_per_row":
+		a.PDPPerRow, err = infoInt(i)
+	case "xff":
+		a.XFF, err = infoFloat(i)
+	default:
+		if a.Params == nil {
+			a.Params = make(map[string]interface{})
+		}
+		a.Params[field] = i.Value
+	}
+
+	return err
+}
+
+// infoString returns the value of i as a string.
+func infoString(i *Info) (string, error) {
+	v, ok := i.Value.(string)
+	if !ok {
+		return "", NewInvalidResponseError(fmt.Sprintf("info: expected string for key %v", i.Key), fmt.Sprint(i.Value))
+	}
+	return v, nil
+}
+
+// infoInt returns the value of i as an int64.
+func infoInt(i *Info) (int64, error) {
+	v, ok := i.Value.(int64)
+	if !ok {
+		return 0, NewInvalidResponseError(fmt.Sprintf("info: expected int for key %v", i.Key), fmt.Sprint(i.Value))
+	}
+	return v, nil
+}
+
+// infoFloat returns the value of i as a float64, accepting ints.
+func infoFloat(i *Info) (float64, error) {
+	switch v := i.Value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	}
+	return 0, NewInvalidResponseError(fmt.Sprintf("info: expected float for key %v", i.Key), fmt.Sprint(i.Value))
+}
+
+// infoDuration returns the value of i, in seconds, as a time.Duration.
+func infoDuration(i *Info) (time.Duration, error) {
+	v, err := infoInt(i)
+	return time.Duration(v) * time.Second, err
+}
@@ -0,0 +1,99 @@
+package rrd
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// InfoCache enables caching of Info results, and hence InfoMap, per file for ttl.
+// Cached entries are invalidated by Create and Forget for the same file.
+// As Info includes state such as last_update, cached results may be up to
+// ttl out of date.
+func InfoCache(ttl time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		c.infoCache = newInfoCache(ttl)
+		return nil
+	}
+}
+
+// infoCacheEntry represents a cached Info result.
+type infoCacheEntry struct {
+	info    []*Info
+	expires time.Time
+}
+
+// infoCache is a concurrency safe cache of Info results.
+type infoCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]infoCacheEntry
+	mtx     sync.Mutex
+}
+
+// newInfoCache returns a new infoCache whose entries expire after ttl.
+func newInfoCache(ttl time.Duration) *infoCache {
+	return &infoCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]infoCacheEntry),
+	}
+}
+
+// get returns a copy of the cached info for key if present and not expired.
+func (ic *infoCache) get(key string) ([]*Info, bool) {
+	ic.mtx.Lock()
+	defer ic.mtx.Unlock()
+
+	e, ok := ic.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !ic.now().Before(e.expires) {
+		delete(ic.entries, key)
+		return nil, false
+	}
+	return copyInfo(e.info), true
+}
+
+// set caches a copy of info for key.
+func (ic *infoCache) set(key string, info []*Info) {
+	ic.mtx.Lock()
+	defer ic.mtx.Unlock()
+
+	ic.entries[key] = infoCacheEntry{info: copyInfo(info), expires: ic.now().Add(ic.ttl)}
+}
+
+// invalidate removes any cached info for key.
+func (ic *infoCache) invalidate(key string) {
+	ic.mtx.Lock()
+	defer ic.mtx.Unlock()
+
+	delete(ic.entries, key)
+}
+
+// copyInfo returns a deep copy of info.
+func copyInfo(info []*Info) []*Info {
+	r := make([]*Info, len(info))
+	for i, v := range info {
+		cp := *v
+		r[i] = &cp
+	}
+	return r
+}
+
+// cacheKey returns the key used to cache data for filename, relative to
+// the BaseDir if set so each file has a single key.
+func (c *Client) cacheKey(filename string) string {
+	if p, err := c.resolvePath(filename); err == nil {
+		filename = p
+	}
+	return filepath.Clean(filename)
+}
+
+// InvalidateInfo removes any cached Info result for filename.
+func (c *Client) InvalidateInfo(filename string) {
+	if c.infoCache != nil {
+		c.infoCache.invalidate(c.cacheKey(filename))
+	}
+}
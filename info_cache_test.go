@@ -0,0 +1,79 @@
+package rrd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoCache(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), InfoCache(time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	now := time.Now()
+	c.infoCache.now = func() time.Time { return now }
+
+	info := func(filename string) {
+		i, err := c.Info(filename)
+		if assert.NoError(t, err) {
+			assert.Len(t, i, 12)
+		}
+	}
+
+	info("test.rrd")
+	info("test.rrd")
+	info("./test.rrd")
+	assert.Equal(t, 1, s.count("info "))
+
+	// Modifying results must not modify the cache.
+	i, err := c.InfoMap("test.rrd")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test.rrd", i["filename"])
+	}
+	l, err := c.Info("test.rrd")
+	if assert.NoError(t, err) {
+		l[0].Value = "changed"
+	}
+	i, err = c.InfoMap("test.rrd")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test.rrd", i["filename"])
+	}
+	assert.Equal(t, 1, s.count("info "))
+
+	info("other.rrd")
+	assert.Equal(t, 2, s.count("info "))
+
+	// Expiry.
+	now = now.Add(time.Minute)
+	info("test.rrd")
+	assert.Equal(t, 3, s.count("info "))
+
+	// Explicit invalidation.
+	c.InvalidateInfo("test.rrd")
+	info("test.rrd")
+	assert.Equal(t, 4, s.count("info "))
+
+	// Invalidation by modifying commands.
+	assert.NoError(t, c.Forget("test.rrd"))
+	info("test.rrd")
+	assert.Equal(t, 5, s.count("info "))
+
+	assert.NoError(t, c.Create("./test.rrd", []DS{NewDS("DS:watts:GAUGE:300:0:24000")}, []RRA{NewRRA("RRA:AVERAGE:0.5:1:864000")}))
+	info("test.rrd")
+	assert.Equal(t, 6, s.count("info "))
+}
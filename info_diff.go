@@ -0,0 +1,168 @@
+package rrd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DiffKind represents the kind of an InfoDifference.
+type DiffKind string
+
+// Info Difference Kinds
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// InfoDifference represents a single schema difference between two RRDs.
+// Path identifies the entry using the info key format e.g. "step",
+// "ds[watts]" or "ds[watts].type". RRA paths use the index of the archive
+// in the original RRD, unless the archive was added.
+type InfoDifference struct {
+	Kind DiffKind
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+func (d InfoDifference) String() string {
+	switch d.Kind {
+	case DiffAdded, DiffRemoved:
+		return fmt.Sprintf("%v %v", d.Kind, d.Path)
+	default:
+		return fmt.Sprintf("%v %v: %v -> %v", d.Kind, d.Path, d.Old, d.New)
+	}
+}
+
+// rraKey identifies an archive independent of its position.
+type rraKey struct {
+	cf        string
+	pdpPerRow int64
+}
+
+// InfoDiff returns the schema differences between a and b.
+// Only the configuration of the RRDs is compared, state such as the last
+// update or the current row of an archive is ignored.
+// Data sources are matched by name, so a renamed data source is reported as
+// removed and added. Archives are matched by consolidation function and
+// steps per row, so a reordered archive is reported as a changed index.
+func InfoDiff(a, b *RRDInfo) []InfoDifference {
+	var diffs []InfoDifference
+	changed := func(path string, from, to interface{}) {
+		if !infoEqual(from, to) {
+			diffs = append(diffs, InfoDifference{Kind: DiffChanged, Path: path, Old: from, New: to})
+		}
+	}
+
+	changed("step", a.Step, b.Step)
+
+	for _, name := range dsNames(a, b) {
+		path := fmt.Sprintf("ds[%v]", name)
+		da, okA := a.DS[name]
+		db, okB := b.DS[name]
+		switch {
+		case !okB:
+			diffs = append(diffs, InfoDifference{Kind: DiffRemoved, Path: path, Old: da})
+		case !okA:
+			diffs = append(diffs, InfoDifference{Kind: DiffAdded, Path: path, New: db})
+		default:
+			changed(path+".index", da.Index, db.Index)
+			changed(path+".type", da.Type, db.Type)
+			changed(path+".minimal_heartbeat", da.MinimalHeartbeat, db.MinimalHeartbeat)
+			changed(path+".min", da.Min, db.Min)
+			changed(path+".max", da.Max, db.Max)
+			changed(path+".cdef", da.CDef, db.CDef)
+		}
+	}
+
+	matched := make(map[int]bool)
+	for i, ra := range a.RRA {
+		path := fmt.Sprintf("rra[%v]", i)
+		j := findRRA(b.RRA, i, ra, matched)
+		if j == -1 {
+			diffs = append(diffs, InfoDifference{Kind: DiffRemoved, Path: path, Old: ra})
+			continue
+		}
+		matched[j] = true
+		rb := b.RRA[j]
+		changed(path+".index", i, j)
+		changed(path+".rows", ra.Rows, rb.Rows)
+		changed(path+".xff", ra.XFF, rb.XFF)
+		for _, k := range paramNames(ra.Params, rb.Params) {
+			changed(path+"."+k, ra.Params[k], rb.Params[k])
+		}
+	}
+	for j, rb := range b.RRA {
+		if !matched[j] {
+			diffs = append(diffs, InfoDifference{Kind: DiffAdded, Path: fmt.Sprintf("rra[%v]", j), New: rb})
+		}
+	}
+
+	return diffs
+}
+
+// findRRA returns the index of an unmatched archive in rras with the same
+// consolidation function and steps per row as r, preferring the archive at
+// idx, or -1 if there is none.
+func findRRA(rras []RRAInfo, idx int, r RRAInfo, matched map[int]bool) int {
+	key := rraKey{cf: r.CF, pdpPerRow: r.PDPPerRow}
+	found := -1
+	for i, o := range rras {
+		if matched[i] || (rraKey{cf: o.CF, pdpPerRow: o.PDPPerRow}) != key {
+			continue
+		}
+		if i == idx {
+			return i
+		}
+		if found == -1 {
+			found = i
+		}
+	}
+	return found
+}
+
+// dsNames returns the sorted union of the data source names of a and b.
+func dsNames(a, b *RRDInfo) []string {
+	seen := make(map[string]struct{}, len(a.DS))
+	for n := range a.DS {
+		seen[n] = struct{}{}
+	}
+	for n := range b.DS {
+		seen[n] = struct{}{}
+	}
+	return sortedKeys(seen)
+}
+
+// paramNames returns the sorted union of the keys of a and b.
+func paramNames(a, b map[string]interface{}) []string {
+	seen := make(map[string]struct{}, len(a))
+	for k := range a {
+		seen[k] = struct{}{}
+	}
+	for k := range b {
+		seen[k] = struct{}{}
+	}
+	return sortedKeys(seen)
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// infoEqual returns true if a and b are equal, treating NaNs as equal.
+func infoEqual(a, b interface{}) bool {
+	fa, okA := a.(float64)
+	fb, okB := b.(float64)
+	if okA && okB && math.IsNaN(fa) && math.IsNaN(fb) {
+		return true
+	}
+	return a == b
+}
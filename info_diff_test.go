@@ -0,0 +1,106 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRRDInfo returns a new RRDInfo for use in tests.
+func testRRDInfo() *RRDInfo {
+	return &RRDInfo{
+		Step: time.Minute * 5,
+		DS: map[string]DSInfo{
+			"watts": {Name: "watts", Index: 0, Type: Gauge, MinimalHeartbeat: time.Minute * 10, Min: 0, Max: math.NaN()},
+			"amps":  {Name: "amps", Index: 1, Type: Gauge, MinimalHeartbeat: time.Minute * 10, Min: 0, Max: 100},
+		},
+		RRA: []RRAInfo{
+			{CF: "AVERAGE", Rows: 288, PDPPerRow: 1, XFF: 0.5},
+			{CF: "AVERAGE", Rows: 336, PDPPerRow: 6, XFF: 0.5},
+			{CF: "MAX", Rows: 336, PDPPerRow: 6, XFF: 0.5},
+		},
+	}
+}
+
+func TestInfoDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		f      func(r *RRDInfo)
+		expect []InfoDifference
+	}{
+		{"identical", func(r *RRDInfo) {}, nil},
+		{"state", func(r *RRDInfo) {
+			r.LastUpdate = time.Now()
+			r.RRA[0].CurRow = 100
+		}, nil},
+		{"step", func(r *RRDInfo) { r.Step = time.Minute }, []InfoDifference{
+			{Kind: DiffChanged, Path: "step", Old: time.Minute * 5, New: time.Minute},
+		}},
+		{"ds-changed", func(r *RRDInfo) {
+			d := r.DS["watts"]
+			d.Type = Counter
+			d.Max = 100
+			r.DS["watts"] = d
+		}, []InfoDifference{
+			{Kind: DiffChanged, Path: "ds[watts].type", Old: Gauge, New: Counter},
+			{Kind: DiffChanged, Path: "ds[watts].max", Old: math.NaN(), New: float64(100)},
+		}},
+		{"ds-renamed", func(r *RRDInfo) {
+			d := r.DS["amps"]
+			delete(r.DS, "amps")
+			d.Name = "current"
+			r.DS["current"] = d
+		}, []InfoDifference{
+			{Kind: DiffRemoved, Path: "ds[amps]", Old: testRRDInfo().DS["amps"]},
+			{Kind: DiffAdded, Path: "ds[current]", New: DSInfo{Name: "current", Index: 1, Type: Gauge, MinimalHeartbeat: time.Minute * 10, Max: 100}},
+		}},
+		{"rra-reordered", func(r *RRDInfo) {
+			r.RRA[1], r.RRA[2] = r.RRA[2], r.RRA[1]
+		}, []InfoDifference{
+			{Kind: DiffChanged, Path: "rra[1].index", Old: 1, New: 2},
+			{Kind: DiffChanged, Path: "rra[2].index", Old: 2, New: 1},
+		}},
+		{"rra-changed", func(r *RRDInfo) { r.RRA[0].Rows = 600 }, []InfoDifference{
+			{Kind: DiffChanged, Path: "rra[0].rows", Old: int64(288), New: int64(600)},
+		}},
+		{"rra-added-removed", func(r *RRDInfo) {
+			r.RRA[2] = RRAInfo{CF: "MIN", Rows: 336, PDPPerRow: 6, XFF: 0.5}
+			r.RRA = append(r.RRA, RRAInfo{CF: "HWPREDICT", Rows: 100, Params: map[string]interface{}{"alpha": 0.1}})
+		}, []InfoDifference{
+			{Kind: DiffRemoved, Path: "rra[2]", Old: testRRDInfo().RRA[2]},
+			{Kind: DiffAdded, Path: "rra[2]", New: RRAInfo{CF: "MIN", Rows: 336, PDPPerRow: 6, XFF: 0.5}},
+			{Kind: DiffAdded, Path: "rra[3]", New: RRAInfo{CF: "HWPREDICT", Rows: 100, Params: map[string]interface{}{"alpha": 0.1}}},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testRRDInfo()
+			tc.f(b)
+			diffs := InfoDiff(testRRDInfo(), b)
+			if !assert.Len(t, diffs, len(tc.expect)) {
+				return
+			}
+			for i, d := range diffs {
+				e := tc.expect[i]
+				assert.Equal(t, e.Kind, d.Kind)
+				assert.Equal(t, e.Path, d.Path)
+				assertInfoValue(t, e.Old, d.Old)
+				assertInfoValue(t, e.New, d.New)
+			}
+		})
+	}
+}
+
+// assertInfoValue asserts that the InfoDifference values expected and actual
+// are equal, treating NaNs as equal.
+func assertInfoValue(t *testing.T, expected, actual interface{}) {
+	if e, ok := expected.(float64); ok && math.IsNaN(e) {
+		a, ok := actual.(float64)
+		assert.True(t, ok && math.IsNaN(a), "expected NaN got %v", actual)
+		return
+	}
+	assert.Equal(t, expected, actual)
+}
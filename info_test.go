@@ -0,0 +1,110 @@
+package rrd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRRDInfo(t *testing.T) {
+	info := []*Info{
+		{Key: "filename", Value: "test.rrd"},
+		{Key: "rrd_version", Value: "0003"},
+		{Key: "step", Value: int64(300)},
+		{Key: "last_update", Value: int64(1499981928)},
+		{Key: "header_size", Value: int64(1760)},
+		{Key: "ds[watts].index", Value: int64(0)},
+		{Key: "ds[watts].type", Value: "GAUGE"},
+		{Key: "ds[watts].minimal_heartbeat", Value: int64(300)},
+		{Key: "ds[watts].min", Value: float64(0)},
+		{Key: "ds[watts].max", Value: float64(24000)},
+		{Key: "ds[watts].last_ds", Value: "U"},
+		{Key: "ds[watts].value", Value: float64(0)},
+		{Key: "ds[watts].unknown_sec", Value: int64(228)},
+		{Key: "rra[0].cf", Value: "AVERAGE"},
+		{Key: "rra[0].rows", Value: int64(864000)},
+		{Key: "rra[0].cur_row", Value: int64(10)},
+		{Key: "rra[0].pdp_per_row", Value: int64(1)},
+		{Key: "rra[0].xff", Value: float64(0.5)},
+		{Key: "rra[0].cdp_prep[0].value", Value: float64(0)},
+		{Key: "rra[1].cf", Value: "HWPREDICT"},
+		{Key: "rra[1].alpha", Value: float64(0.1)},
+	}
+
+	r, err := NewRRDInfo(info)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := &RRDInfo{
+		Filename:   "test.rrd",
+		Version:    "0003",
+		Step:       time.Minute * 5,
+		LastUpdate: time.Unix(1499981928, 0),
+		HeaderSize: 1760,
+		DS: map[string]DSInfo{
+			"watts": {
+				Name:             "watts",
+				Type:             Gauge,
+				MinimalHeartbeat: time.Minute * 5,
+				Max:              24000,
+				LastDS:           "U",
+				UnknownSec:       228,
+			},
+		},
+		RRA: []RRAInfo{
+			{CF: "AVERAGE", Rows: 864000, CurRow: 10, PDPPerRow: 1, XFF: 0.5},
+			{CF: "HWPREDICT", Params: map[string]interface{}{"alpha": float64(0.1)}},
+		},
+	}
+	assert.Equal(t, expected, r)
+
+	_, err = NewRRDInfo([]*Info{{Key: "step", Value: "300"}})
+	assert.Error(t, err)
+}
+
+func TestExists(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   []string
+		exists bool
+		err    bool
+	}{
+		{"exists", nil, true, false},
+		{"missing", []string{"-1 No such file: /test.rrd"}, false, false},
+		{"error", []string{"-1 Permission denied"}, false, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerStopped(t)
+			if s == nil {
+				return
+			}
+			if tc.info != nil {
+				s.responses = map[string][]string{"info": tc.info}
+			}
+			s.Start()
+			defer func() {
+				assert.NoError(t, s.Close())
+			}()
+
+			c, err := NewClient(s.Addr, Timeout(time.Second*2))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			exists, err := c.Exists("test.rrd")
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.exists, exists)
+		})
+	}
+}
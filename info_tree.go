@@ -0,0 +1,135 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// InfoTree represents the configuration information of an RRD as a tree.
+// Each element of an info key, split on dots and bracket indices, is a
+// level in the tree so "ds[watts].type" is stored as
+// tree["ds"]["watts"]["type"]. Values are either an InfoTree or the info
+// value of a leaf.
+type InfoTree map[string]interface{}
+
+// NewInfoTree returns a new InfoTree created from info.
+func NewInfoTree(info []*Info) (InfoTree, error) {
+	t := make(InfoTree)
+	for _, i := range info {
+		path, err := infoPath(i.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		n := t
+		for _, p := range path[:len(path)-1] {
+			switch v := n[p].(type) {
+			case nil:
+				c := make(InfoTree)
+				n[p] = c
+				n = c
+			case InfoTree:
+				n = v
+			default:
+				return nil, NewInvalidResponseError(fmt.Sprintf("info: key %v conflicts with value", i.Key), i.Key)
+			}
+		}
+
+		leaf := path[len(path)-1]
+		if _, ok := n[leaf]; ok {
+			return nil, NewInvalidResponseError(fmt.Sprintf("info: duplicate key %v", i.Key), i.Key)
+		}
+		n[leaf] = i.Value
+	}
+
+	return t, nil
+}
+
+// infoPath splits the info key into its path elements, for example
+// "rra[0].cdp_prep[1].value" returns [rra 0 cdp_prep 1 value].
+func infoPath(key string) ([]string, error) {
+	var path []string
+	invalid := func() ([]string, error) {
+		return nil, NewInvalidResponseError(fmt.Sprintf("info: invalid key %v", key), key)
+	}
+
+	for s := key; s != ""; {
+		switch {
+		case s[0] == '[':
+			i := strings.IndexByte(s, ']')
+			if i <= 1 {
+				return invalid()
+			}
+			path = append(path, s[1:i])
+			s = s[i+1:]
+			if s != "" {
+				if s[0] == '.' {
+					s = s[1:]
+				} else if s[0] != '[' {
+					return invalid()
+				}
+			}
+		default:
+			i := strings.IndexAny(s, ".[")
+			if i == 0 {
+				return invalid()
+			}
+			if i == -1 {
+				i = len(s)
+			}
+			path = append(path, s[:i])
+			s = s[i:]
+			if strings.HasPrefix(s, ".") {
+				s = s[1:]
+				if s == "" {
+					return invalid()
+				}
+			}
+		}
+	}
+
+	if len(path) == 0 {
+		return invalid()
+	}
+	return path, nil
+}
+
+// Get returns the value of the tree at the info key format path, such as
+// "ds[watts]" or "rra[0].cf", and true if present, false otherwise.
+func (t InfoTree) Get(path string) (interface{}, bool) {
+	p, err := infoPath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var v interface{} = t
+	for _, k := range p {
+		n, ok := v.(InfoTree)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = n[k]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// InfoTree returns the configuration information for the specified RRD as a tree.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) InfoTree(filename string) (InfoTree, error) {
+	return c.InfoTreeWithContext(context.Background(), filename)
+}
+
+// InfoTreeWithContext returns the configuration information for the
+// specified RRD as a tree.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) InfoTreeWithContext(ctx context.Context, filename string) (InfoTree, error) {
+	info, err := c.InfoWithContext(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewInfoTree(info)
+}
@@ -0,0 +1,88 @@
+package rrd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoPath(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected []string
+	}{
+		{"step", []string{"step"}},
+		{"ds[watts].type", []string{"ds", "watts", "type"}},
+		{"rra[0].cdp_prep[1].value", []string{"rra", "0", "cdp_prep", "1", "value"}},
+		{"ds[watts]", []string{"ds", "watts"}},
+		{"a[1][2]", []string{"a", "1", "2"}},
+		{"", nil},
+		{"ds[].type", nil},
+		{"ds[watts", nil},
+		{"ds[watts]type", nil},
+		{"step.", nil},
+		{".step", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.key, func(t *testing.T) {
+			p, err := infoPath(tc.key)
+			if tc.expected == nil {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestInfoTree(t *testing.T) {
+	info := []*Info{
+		{Key: "filename", Value: "test.rrd"},
+		{Key: "step", Value: int64(300)},
+		{Key: "ds[watts].type", Value: "GAUGE"},
+		{Key: "ds[watts].min", Value: float64(0)},
+		{Key: "rra[0].cf", Value: "AVERAGE"},
+		{Key: "rra[0].cdp_prep[0].value", Value: float64(1)},
+	}
+
+	tree, err := NewInfoTree(info)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := InfoTree{
+		"filename": "test.rrd",
+		"step":     int64(300),
+		"ds": InfoTree{
+			"watts": InfoTree{"type": "GAUGE", "min": float64(0)},
+		},
+		"rra": InfoTree{
+			"0": InfoTree{
+				"cf":       "AVERAGE",
+				"cdp_prep": InfoTree{"0": InfoTree{"value": float64(1)}},
+			},
+		},
+	}
+	assert.Equal(t, expected, tree)
+
+	v, ok := tree.Get("rra[0].cf")
+	assert.True(t, ok)
+	assert.Equal(t, "AVERAGE", v)
+
+	v, ok = tree.Get("ds[watts]")
+	assert.True(t, ok)
+	assert.Equal(t, InfoTree{"type": "GAUGE", "min": float64(0)}, v)
+
+	_, ok = tree.Get("ds[amps].type")
+	assert.False(t, ok)
+	_, ok = tree.Get("step.value")
+	assert.False(t, ok)
+
+	_, err = NewInfoTree(append(info, &Info{Key: "step.value", Value: int64(1)}))
+	assert.Error(t, err)
+	_, err = NewInfoTree(append(info, &Info{Key: "ds[watts]", Value: int64(1)}))
+	assert.Error(t, err)
+}
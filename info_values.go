@@ -0,0 +1,248 @@
+package rrd
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// InfoValues represents the configuration information of an RRD by info
+// key, such as "step" or "ds[watts].type", with typed getters which avoid
+// type assertions of the values.
+type InfoValues map[string]interface{}
+
+// NewInfoValues returns a new InfoValues created from info.
+func NewInfoValues(info []*Info) InfoValues {
+	v := make(InfoValues, len(info))
+	for _, i := range info {
+		v[i.Key] = i.Value
+	}
+	return v
+}
+
+// GetString returns the string value of key and true, or false if it
+// isn't present or isn't a string.
+func (v InfoValues) GetString(key string) (string, bool) {
+	s, ok := v[key].(string)
+	return s, ok
+}
+
+// GetInt returns the integer value of key and true, or false if it isn't
+// present or isn't an integer.
+func (v InfoValues) GetInt(key string) (int64, bool) {
+	n, ok := v[key].(int64)
+	return n, ok
+}
+
+// GetFloat returns the numeric value of key, which may be an integer, and
+// true, or false if it isn't present or isn't numeric. Unknown values are
+// NaN.
+func (v InfoValues) GetFloat(key string) (float64, bool) {
+	switch n := v[key].(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// GetDuration returns the integer value of key in seconds, such as "step",
+// as a time.Duration and true, or false if it isn't present or isn't an
+// integer.
+func (v InfoValues) GetDuration(key string) (time.Duration, bool) {
+	n, ok := v.GetInt(key)
+	return time.Duration(n) * time.Second, ok
+}
+
+// Step returns the base step of the RRD.
+func (v InfoValues) Step() (time.Duration, bool) {
+	return v.GetDuration("step")
+}
+
+// LastUpdate returns the time of the last update of the RRD.
+func (v InfoValues) LastUpdate() (time.Time, bool) {
+	n, ok := v.GetInt("last_update")
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(n, 0), true
+}
+
+// DSNames returns the names of the data sources in index order.
+func (v InfoValues) DSNames() []string {
+	type ds struct {
+		name  string
+		index int64
+	}
+	var dss []ds
+	for k := range v {
+		if m := infoDSRe.FindStringSubmatch(k); m != nil && m[2] == "index" {
+			idx, _ := v.GetInt(k)
+			dss = append(dss, ds{name: m[1], index: idx})
+		}
+	}
+	sort.Slice(dss, func(i, j int) bool { return dss[i].index < dss[j].index })
+
+	names := make([]string, len(dss))
+	for i, d := range dss {
+		names[i] = d.name
+	}
+	return names
+}
+
+// RRACount returns the number of round robin archives.
+func (v InfoValues) RRACount() int {
+	var n int
+	for k := range v {
+		if m := infoRRARe.FindStringSubmatch(k); m != nil && m[2] == "cf" {
+			n++
+		}
+	}
+	return n
+}
+
+// DS returns the values of the data source name, which can be checked
+// with Exists.
+func (v InfoValues) DS(name string) InfoDS {
+	return InfoDS{v: v, prefix: "ds[" + name + "]."}
+}
+
+// RRA returns the values of the archive at index idx, which can be checked
+// with Exists.
+func (v InfoValues) RRA(idx int) InfoRRA {
+	return InfoRRA{v: v, prefix: "rra[" + strconv.Itoa(idx) + "]."}
+}
+
+// InfoDS represents the info values of a data source.
+type InfoDS struct {
+	v      InfoValues
+	prefix string
+}
+
+// Exists returns true if the data source exists, false otherwise.
+func (d InfoDS) Exists() bool {
+	_, ok := d.v[d.prefix+"index"]
+	return ok
+}
+
+// GetString returns the string value of field, such as "type".
+func (d InfoDS) GetString(field string) (string, bool) {
+	return d.v.GetString(d.prefix + field)
+}
+
+// GetInt returns the integer value of field, such as "unknown_sec".
+func (d InfoDS) GetInt(field string) (int64, bool) {
+	return d.v.GetInt(d.prefix + field)
+}
+
+// GetFloat returns the numeric value of field, such as "value".
+func (d InfoDS) GetFloat(field string) (float64, bool) {
+	return d.v.GetFloat(d.prefix + field)
+}
+
+// Index returns the index of the data source.
+func (d InfoDS) Index() (int, bool) {
+	n, ok := d.GetInt("index")
+	return int(n), ok
+}
+
+// Type returns the type of the data source, such as GAUGE.
+func (d InfoDS) Type() (string, bool) {
+	return d.GetString("type")
+}
+
+// Heartbeat returns the minimal heartbeat of the data source.
+func (d InfoDS) Heartbeat() (time.Duration, bool) {
+	return d.v.GetDuration(d.prefix + "minimal_heartbeat")
+}
+
+// Min returns the minimum value of the data source, NaN if unlimited.
+func (d InfoDS) Min() (float64, bool) {
+	return d.GetFloat("min")
+}
+
+// Max returns the maximum value of the data source, NaN if unlimited.
+func (d InfoDS) Max() (float64, bool) {
+	return d.GetFloat("max")
+}
+
+// CDef returns the expression of a COMPUTE data source.
+func (d InfoDS) CDef() (string, bool) {
+	return d.GetString("cdef")
+}
+
+// LastDS returns the last value the data source was updated with.
+func (d InfoDS) LastDS() (string, bool) {
+	return d.GetString("last_ds")
+}
+
+// InfoRRA represents the info values of a round robin archive.
+type InfoRRA struct {
+	v      InfoValues
+	prefix string
+}
+
+// Exists returns true if the archive exists, false otherwise.
+func (r InfoRRA) Exists() bool {
+	_, ok := r.v[r.prefix+"cf"]
+	return ok
+}
+
+// GetString returns the string value of field, such as "cf".
+func (r InfoRRA) GetString(field string) (string, bool) {
+	return r.v.GetString(r.prefix + field)
+}
+
+// GetInt returns the integer value of field, such as "rows".
+func (r InfoRRA) GetInt(field string) (int64, bool) {
+	return r.v.GetInt(r.prefix + field)
+}
+
+// GetFloat returns the numeric value of field, such as the "alpha" of a
+// Holt-Winters archive.
+func (r InfoRRA) GetFloat(field string) (float64, bool) {
+	return r.v.GetFloat(r.prefix + field)
+}
+
+// CF returns the consolidation function of the archive, such as AVERAGE,
+// or its Holt-Winters type.
+func (r InfoRRA) CF() (string, bool) {
+	return r.GetString("cf")
+}
+
+// Rows returns the number of rows of the archive.
+func (r InfoRRA) Rows() (int64, bool) {
+	return r.GetInt("rows")
+}
+
+// PDPPerRow returns the number of primary data points consolidated into
+// each row of the archive.
+func (r InfoRRA) PDPPerRow() (int64, bool) {
+	return r.GetInt("pdp_per_row")
+}
+
+// XFF returns the xfiles factor of the archive.
+func (r InfoRRA) XFF() (float64, bool) {
+	return r.GetFloat("xff")
+}
+
+// InfoValues returns the configuration information for the specified RRD
+// with typed getters.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) InfoValues(filename string) (InfoValues, error) {
+	return c.InfoValuesWithContext(context.Background(), filename)
+}
+
+// InfoValuesWithContext returns the configuration information for the
+// specified RRD with typed getters.
+// If the client was created with InfoCache the result may be cached.
+func (c *Client) InfoValuesWithContext(ctx context.Context, filename string) (InfoValues, error) {
+	info, err := c.InfoWithContext(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewInfoValues(info), nil
+}
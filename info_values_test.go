@@ -0,0 +1,134 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoValues(t *testing.T) {
+	v := NewInfoValues([]*Info{
+		{Key: "filename", Value: "test.rrd"},
+		{Key: "step", Value: int64(300)},
+		{Key: "last_update", Value: int64(1499981928)},
+		{Key: "ds[watts].index", Value: int64(1)},
+		{Key: "ds[watts].type", Value: "GAUGE"},
+		{Key: "ds[watts].minimal_heartbeat", Value: int64(600)},
+		{Key: "ds[watts].min", Value: float64(0)},
+		{Key: "ds[watts].max", Value: math.NaN()},
+		{Key: "ds[kw].index", Value: int64(0)},
+		{Key: "ds[kw].type", Value: "COMPUTE"},
+		{Key: "ds[kw].cdef", Value: "watts,1000,/"},
+		{Key: "rra[0].cf", Value: "AVERAGE"},
+		{Key: "rra[0].rows", Value: int64(1440)},
+		{Key: "rra[0].pdp_per_row", Value: int64(1)},
+		{Key: "rra[0].xff", Value: 0.5},
+		{Key: "rra[1].cf", Value: "HWPREDICT"},
+		{Key: "rra[1].alpha", Value: 0.1},
+	})
+
+	s, ok := v.GetString("filename")
+	assert.True(t, ok)
+	assert.Equal(t, "test.rrd", s)
+	_, ok = v.GetString("step")
+	assert.False(t, ok)
+
+	n, ok := v.GetInt("step")
+	assert.True(t, ok)
+	assert.Equal(t, int64(300), n)
+	_, ok = v.GetInt("missing")
+	assert.False(t, ok)
+
+	f, ok := v.GetFloat("step")
+	assert.True(t, ok)
+	assert.Equal(t, float64(300), f)
+	_, ok = v.GetFloat("filename")
+	assert.False(t, ok)
+
+	step, ok := v.Step()
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute*5, step)
+	last, ok := v.LastUpdate()
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1499981928, 0), last)
+
+	assert.Equal(t, []string{"kw", "watts"}, v.DSNames())
+	assert.Equal(t, 2, v.RRACount())
+
+	ds := v.DS("watts")
+	assert.True(t, ds.Exists())
+	idx, ok := ds.Index()
+	assert.True(t, ok)
+	assert.Equal(t, 1, idx)
+	typ, _ := ds.Type()
+	assert.Equal(t, Gauge, typ)
+	hb, ok := ds.Heartbeat()
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute*10, hb)
+	min, ok := ds.Min()
+	assert.True(t, ok)
+	assert.Zero(t, min)
+	max, ok := ds.Max()
+	assert.True(t, ok)
+	assert.True(t, math.IsNaN(max))
+	_, ok = ds.CDef()
+	assert.False(t, ok)
+
+	cdef, ok := v.DS("kw").CDef()
+	assert.True(t, ok)
+	assert.Equal(t, "watts,1000,/", cdef)
+
+	assert.False(t, v.DS("amps").Exists())
+	_, ok = v.DS("amps").Heartbeat()
+	assert.False(t, ok)
+
+	rra := v.RRA(0)
+	assert.True(t, rra.Exists())
+	cf, _ := rra.CF()
+	assert.Equal(t, "AVERAGE", cf)
+	rows, _ := rra.Rows()
+	assert.Equal(t, int64(1440), rows)
+	pdp, _ := rra.PDPPerRow()
+	assert.Equal(t, int64(1), pdp)
+	xff, _ := rra.XFF()
+	assert.Equal(t, 0.5, xff)
+
+	alpha, ok := v.RRA(1).GetFloat("alpha")
+	assert.True(t, ok)
+	assert.Equal(t, 0.1, alpha)
+	assert.False(t, v.RRA(2).Exists())
+}
+
+func TestClientInfoValues(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	v, err := c.InfoValues("test.rrd")
+	if !assert.NoError(t, err) {
+		return
+	}
+	hb, ok := v.DS("watts").Heartbeat()
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute*5, hb)
+	max, ok := v.DS("watts").Max()
+	assert.True(t, ok)
+	assert.Equal(t, float64(24000), max)
+	last, ok := v.DS("watts").LastDS()
+	assert.True(t, ok)
+	assert.Equal(t, "U", last)
+}
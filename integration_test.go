@@ -49,7 +49,7 @@ func TestIntegration(t *testing.T) {
 	}
 
 	update := func(t *testing.T) {
-		err := c.Update(*rrdFile, NewUpdate(now, 10))
+		err := c.Update(*rrdFile, Sample{Time: now, Values: []float64{10}})
 		assert.NoError(t, err)
 	}
 
@@ -161,3 +161,37 @@ func TestIntegration(t *testing.T) {
 		t.Run(tc.name, tc.f)
 	}
 }
+
+func BenchmarkIntegrationUpdate(b *testing.B) {
+	c, err := NewClient(*rrdAddress, Timeout(time.Second*10))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() {
+		assert.NoError(b, c.Close())
+	}()
+
+	file := "bench-" + *rrdFile
+	err = c.Create(file, []DS{NewGauge("watts", time.Minute*5, 0, 1000)}, []RRA{NewAverage(0.5, 1, 1000)}, Step(time.Second))
+	if err != nil && !IsExist(err) {
+		b.Fatal(err)
+	}
+
+	// Updates must be after the last, so start at the current time and
+	// advance by a second.
+	start := time.Now()
+	if last, err := c.Last(file); err == nil && !last.Before(start) {
+		start = last.Add(time.Second)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := Sample{Time: start.Add(time.Duration(i) * time.Second), Values: []float64{float64(i % 1000)}}
+		if err := c.Update(file, s); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "updates/s")
+}
@@ -0,0 +1,314 @@
+package rrd
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+)
+
+// jsonNull is the JSON representation of unknown values.
+var jsonNull = []byte("null")
+
+// jsonFloat is a float64 which is represented in JSON as null if it's
+// NaN or infinite, as JSON numbers can't represent them.
+type jsonFloat float64
+
+// MarshalJSON implements json.Marshaler.
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return jsonNull, nil
+	}
+	return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, decoding null as NaN.
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, jsonNull) {
+		*f = jsonFloat(math.NaN())
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = jsonFloat(v)
+	return nil
+}
+
+// toJSONFloats returns values as jsonFloats.
+func toJSONFloats(values []float64) []jsonFloat {
+	if values == nil {
+		return nil
+	}
+	res := make([]jsonFloat, len(values))
+	for i, v := range values {
+		res[i] = jsonFloat(v)
+	}
+	return res
+}
+
+// fromJSONFloats returns values as float64s.
+func fromJSONFloats(values []jsonFloat) []float64 {
+	if values == nil {
+		return nil
+	}
+	res := make([]float64, len(values))
+	for i, v := range values {
+		res[i] = float64(v)
+	}
+	return res
+}
+
+// seconds returns d in whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r FetchResult) MarshalJSON() ([]byte, error) {
+	type alias FetchResult
+	return json.Marshal(struct {
+		alias
+		Step int64 `json:"step"`
+	}{alias(r), seconds(r.Step)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *FetchResult) UnmarshalJSON(data []byte) error {
+	type alias FetchResult
+	v := struct {
+		*alias
+		Step int64 `json:"step"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.Step = time.Duration(v.Step) * time.Second
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r FetchResultRow) MarshalJSON() ([]byte, error) {
+	type alias FetchResultRow
+	return json.Marshal(struct {
+		alias
+		Values []jsonFloat `json:"values"`
+	}{alias(r), toJSONFloats(r.Values)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *FetchResultRow) UnmarshalJSON(data []byte) error {
+	type alias FetchResultRow
+	v := struct {
+		*alias
+		Values []jsonFloat `json:"values"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.Values = fromJSONFloats(v.Values)
+	return nil
+}
+
+// FetchColumns is the columnar representation of a FetchResult, with a
+// column of values per data source and row times implied by the time of
+// the first row and the step, which is more compact for large fetches.
+type FetchColumns struct {
+	Start time.Time     `json:"start"`
+	End   time.Time     `json:"end"`
+	Step  time.Duration `json:"step"`
+	Names []string      `json:"names"`
+
+	// First is the time of the first row, row i has the time
+	// First + i * Step.
+	First time.Time `json:"first"`
+
+	// Columns contains the values of each data source, ordered as Names.
+	Columns [][]float64 `json:"columns"`
+}
+
+// Columns returns the columnar representation of r, which must have evenly
+// spaced rows as returned by fetch.
+func (r *FetchResult) Columns() *FetchColumns {
+	c := &FetchColumns{
+		Start:   r.Start,
+		End:     r.End,
+		Step:    r.Step,
+		Names:   r.Names,
+		Columns: make([][]float64, len(r.Names)),
+	}
+	if len(r.Rows) > 0 {
+		c.First = r.Rows[0].Time
+	}
+	for i := range c.Columns {
+		c.Columns[i] = make([]float64, len(r.Rows))
+		for j, row := range r.Rows {
+			if i < len(row.Values) {
+				c.Columns[i][j] = row.Values[i]
+			} else {
+				c.Columns[i][j] = math.NaN()
+			}
+		}
+	}
+	return c
+}
+
+// Result returns the FetchResult represented by c.
+func (c *FetchColumns) Result() *FetchResult {
+	var n int
+	for _, col := range c.Columns {
+		n = max(n, len(col))
+	}
+
+	r := &FetchResult{
+		Start: c.Start,
+		End:   c.End,
+		Step:  c.Step,
+		Names: c.Names,
+		Rows:  make([]FetchResultRow, n),
+	}
+	for i := range r.Rows {
+		row := FetchResultRow{
+			Time:   c.First.Add(c.Step * time.Duration(i)),
+			Values: make([]float64, len(c.Columns)),
+		}
+		for j, col := range c.Columns {
+			if i < len(col) {
+				row.Values[j] = col[i]
+			} else {
+				row.Values[j] = math.NaN()
+			}
+		}
+		r.Rows[i] = row
+	}
+	return r
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c FetchColumns) MarshalJSON() ([]byte, error) {
+	type alias FetchColumns
+	cols := make([][]jsonFloat, len(c.Columns))
+	for i, col := range c.Columns {
+		cols[i] = toJSONFloats(col)
+	}
+	return json.Marshal(struct {
+		alias
+		Step    int64         `json:"step"`
+		Columns [][]jsonFloat `json:"columns"`
+	}{alias(c), seconds(c.Step), cols})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *FetchColumns) UnmarshalJSON(data []byte) error {
+	type alias FetchColumns
+	v := struct {
+		*alias
+		Step    int64         `json:"step"`
+		Columns [][]jsonFloat `json:"columns"`
+	}{alias: (*alias)(c)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c.Step = time.Duration(v.Step) * time.Second
+	c.Columns = make([][]float64, len(v.Columns))
+	for i, col := range v.Columns {
+		c.Columns[i] = fromJSONFloats(col)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r RRDInfo) MarshalJSON() ([]byte, error) {
+	type alias RRDInfo
+	return json.Marshal(struct {
+		alias
+		Step int64 `json:"step"`
+	}{alias(r), seconds(r.Step)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *RRDInfo) UnmarshalJSON(data []byte) error {
+	type alias RRDInfo
+	v := struct {
+		*alias
+		Step int64 `json:"step"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.Step = time.Duration(v.Step) * time.Second
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DSInfo) MarshalJSON() ([]byte, error) {
+	type alias DSInfo
+	return json.Marshal(struct {
+		alias
+		MinimalHeartbeat int64     `json:"minimal_heartbeat"`
+		Min              jsonFloat `json:"min"`
+		Max              jsonFloat `json:"max"`
+		Value            jsonFloat `json:"value"`
+	}{alias(d), seconds(d.MinimalHeartbeat), jsonFloat(d.Min), jsonFloat(d.Max), jsonFloat(d.Value)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *DSInfo) UnmarshalJSON(data []byte) error {
+	type alias DSInfo
+	v := struct {
+		*alias
+		MinimalHeartbeat int64     `json:"minimal_heartbeat"`
+		Min              jsonFloat `json:"min"`
+		Max              jsonFloat `json:"max"`
+		Value            jsonFloat `json:"value"`
+	}{alias: (*alias)(d)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	d.MinimalHeartbeat = time.Duration(v.MinimalHeartbeat) * time.Second
+	d.Min, d.Max, d.Value = float64(v.Min), float64(v.Max), float64(v.Value)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a RRAInfo) MarshalJSON() ([]byte, error) {
+	type alias RRAInfo
+	var params map[string]interface{}
+	if a.Params != nil {
+		params = make(map[string]interface{}, len(a.Params))
+		for k, p := range a.Params {
+			if f, ok := p.(float64); ok {
+				p = jsonFloat(f)
+			}
+			params[k] = p
+		}
+	}
+	return json.Marshal(struct {
+		alias
+		XFF    jsonFloat              `json:"xff"`
+		Params map[string]interface{} `json:"params,omitempty"`
+	}{alias(a), jsonFloat(a.XFF), params})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Numeric params are decoded
+// as float64 and null params as NaN.
+func (a *RRAInfo) UnmarshalJSON(data []byte) error {
+	type alias RRAInfo
+	v := struct {
+		*alias
+		XFF jsonFloat `json:"xff"`
+	}{alias: (*alias)(a)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	a.XFF = float64(v.XFF)
+	for k, p := range a.Params {
+		if p == nil {
+			a.Params[k] = math.NaN()
+		}
+	}
+	return nil
+}
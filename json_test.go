@@ -0,0 +1,201 @@
+package rrd
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testFetchResult returns a FetchResult with two data sources and rows.
+func testFetchResult() *FetchResult {
+	return &FetchResult{
+		Start: time.Unix(1500000000, 0).UTC(),
+		End:   time.Unix(1500000600, 0).UTC(),
+		Step:  time.Minute * 5,
+		Names: []string{"a", "b"},
+		Rows: []FetchResultRow{
+			{Time: time.Unix(1500000300, 0).UTC(), Values: []float64{1, math.NaN()}},
+			{Time: time.Unix(1500000600, 0).UTC(), Values: []float64{2.5, 3}},
+		},
+	}
+}
+
+// assertNaNEqual asserts that expected and actual are equal, treating NaNs
+// as equal.
+func assertNaNEqual(t *testing.T, expected, actual []float64) {
+	t.Helper()
+	if !assert.Len(t, actual, len(expected)) {
+		return
+	}
+	for i, v := range expected {
+		if math.IsNaN(v) {
+			assert.True(t, math.IsNaN(actual[i]), "index %v: %v", i, actual[i])
+		} else {
+			assert.Equal(t, v, actual[i], "index %v", i)
+		}
+	}
+}
+
+func TestFetchResultJSON(t *testing.T) {
+	r := testFetchResult()
+	data, err := json.Marshal(r)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.JSONEq(t, `{
+		"start": "2017-07-14T02:40:00Z",
+		"end": "2017-07-14T02:50:00Z",
+		"step": 300,
+		"names": ["a", "b"],
+		"rows": [
+			{"time": "2017-07-14T02:45:00Z", "values": [1, null]},
+			{"time": "2017-07-14T02:50:00Z", "values": [2.5, 3]}
+		]
+	}`, string(data))
+
+	var r2 FetchResult
+	if !assert.NoError(t, json.Unmarshal(data, &r2)) {
+		return
+	}
+	assert.Equal(t, r.Start, r2.Start)
+	assert.Equal(t, r.Step, r2.Step)
+	assert.Equal(t, r.Names, r2.Names)
+	if assert.Len(t, r2.Rows, 2) {
+		assert.Equal(t, r.Rows[0].Time, r2.Rows[0].Time)
+		assertNaNEqual(t, r.Rows[0].Values, r2.Rows[0].Values)
+		assertNaNEqual(t, r.Rows[1].Values, r2.Rows[1].Values)
+	}
+
+	assert.Error(t, json.Unmarshal([]byte(`{"rows":[{"values":["x"]}]}`), &r2))
+}
+
+func TestFetchColumnsJSON(t *testing.T) {
+	r := testFetchResult()
+	c := r.Columns()
+	data, err := json.Marshal(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.JSONEq(t, `{
+		"start": "2017-07-14T02:40:00Z",
+		"end": "2017-07-14T02:50:00Z",
+		"step": 300,
+		"names": ["a", "b"],
+		"first": "2017-07-14T02:45:00Z",
+		"columns": [[1, 2.5], [null, 3]]
+	}`, string(data))
+
+	var c2 FetchColumns
+	if !assert.NoError(t, json.Unmarshal(data, &c2)) {
+		return
+	}
+	r2 := c2.Result()
+	assert.Equal(t, r.Step, r2.Step)
+	if assert.Len(t, r2.Rows, 2) {
+		for i, row := range r.Rows {
+			assert.Equal(t, row.Time, r2.Rows[i].Time)
+			assertNaNEqual(t, row.Values, r2.Rows[i].Values)
+		}
+	}
+
+	// Short columns are padded with unknown values.
+	r3 := (&FetchColumns{Step: time.Second, Names: []string{"a", "b"}, Columns: [][]float64{{1, 2}, {3}}}).Result()
+	if assert.Len(t, r3.Rows, 2) {
+		assertNaNEqual(t, []float64{2, math.NaN()}, r3.Rows[1].Values)
+	}
+}
+
+func TestRRDInfoJSON(t *testing.T) {
+	info := &RRDInfo{
+		Filename:   "test.rrd",
+		Version:    "0003",
+		Step:       time.Minute * 5,
+		LastUpdate: time.Unix(1500000000, 0).UTC(),
+		HeaderSize: 1000,
+		DS: map[string]DSInfo{
+			"watts": {
+				Name:             "watts",
+				Type:             "GAUGE",
+				MinimalHeartbeat: time.Minute * 10,
+				Min:              0,
+				Max:              math.NaN(),
+				LastDS:           "U",
+				Value:            math.NaN(),
+			},
+		},
+		RRA: []RRAInfo{
+			{CF: "AVERAGE", Rows: 10, PDPPerRow: 1, XFF: 0.5},
+			{CF: "HWPREDICT", Rows: 10, PDPPerRow: 1, XFF: math.NaN(), Params: map[string]interface{}{"alpha": 0.1, "seasonal": math.NaN()}},
+		},
+	}
+
+	data, err := json.Marshal(info)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.JSONEq(t, `{
+		"filename": "test.rrd",
+		"version": "0003",
+		"step": 300,
+		"last_update": "2017-07-14T02:40:00Z",
+		"header_size": 1000,
+		"ds": {
+			"watts": {
+				"name": "watts",
+				"index": 0,
+				"type": "GAUGE",
+				"minimal_heartbeat": 600,
+				"min": 0,
+				"max": null,
+				"last_ds": "U",
+				"value": null,
+				"unknown_sec": 0
+			}
+		},
+		"rra": [
+			{"cf": "AVERAGE", "rows": 10, "cur_row": 0, "pdp_per_row": 1, "xff": 0.5},
+			{"cf": "HWPREDICT", "rows": 10, "cur_row": 0, "pdp_per_row": 1, "xff": null, "params": {"alpha": 0.1, "seasonal": null}}
+		]
+	}`, string(data))
+
+	var info2 RRDInfo
+	if !assert.NoError(t, json.Unmarshal(data, &info2)) {
+		return
+	}
+	assert.Equal(t, info.Step, info2.Step)
+	assert.Equal(t, info.LastUpdate, info2.LastUpdate)
+	ds := info2.DS["watts"]
+	assert.Equal(t, time.Minute*10, ds.MinimalHeartbeat)
+	assert.Equal(t, float64(0), ds.Min)
+	assert.True(t, math.IsNaN(ds.Max))
+	if assert.Len(t, info2.RRA, 2) {
+		assert.Equal(t, 0.5, info2.RRA[0].XFF)
+		assert.Nil(t, info2.RRA[0].Params)
+		assert.True(t, math.IsNaN(info2.RRA[1].XFF))
+		assert.Equal(t, 0.1, info2.RRA[1].Params["alpha"])
+		assert.True(t, math.IsNaN(info2.RRA[1].Params["seasonal"].(float64)))
+	}
+}
+
+func TestStatsJSON(t *testing.T) {
+	data, err := json.Marshal(&Stats{QueueLength: 1, TreeDepth: 2})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"queue_length": 1,
+		"updates_received": 0,
+		"flushes_received": 0,
+		"updates_written": 0,
+		"data_sets_written": 0,
+		"tree_nodes_number": 0,
+		"tree_depth": 2,
+		"journal_bytes": 0,
+		"journal_rotate": 0
+	}`, string(data))
+
+	data, err = json.Marshal([]QueueEntry{{File: "test.rrd", Pending: 3}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"file": "test.rrd", "pending": 3}]`, string(data))
+}
@@ -0,0 +1,73 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Keepalive sends a ping on connections which have been idle for interval,
+// keeping NAT and firewall state alive and detecting a dead server before
+// the next command. A connection which fails the ping is closed, so the
+// next command reconnects.
+func Keepalive(interval time.Duration) func(*Client) error {
+	return func(c *Client) error {
+		if interval <= 0 {
+			return fmt.Errorf("%w: keepalive interval %v", ErrInvalidArg, interval)
+		}
+		c.keepalive = interval
+		return nil
+	}
+}
+
+// startKeepalive starts the keepalive goroutine if enabled.
+func (c *Client) startKeepalive() {
+	if c.keepalive == 0 {
+		return
+	}
+
+	c.wg.Add(1)
+	go c.keepaliveLoop()
+}
+
+// keepaliveLoop pings the server whenever the connection has been idle for
+// the keepalive interval until stopped.
+func (c *Client) keepaliveLoop() {
+	defer c.wg.Done()
+
+	t := time.NewTimer(c.keepalive)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			t.Reset(c.keepaliveCheck())
+		}
+	}
+}
+
+// keepaliveCheck pings the server if the connection is idle, returning the
+// time until the next check.
+func (c *Client) keepaliveCheck() time.Duration {
+	if !c.m.TryLock() {
+		// In use so not idle.
+		return c.keepalive
+	}
+	defer c.m.Unlock()
+
+	if c.conn == nil {
+		return c.keepalive
+	}
+	if idle := time.Since(c.used); idle < c.keepalive {
+		return c.keepalive - idle
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	if _, err := c.execLocked(ctx, NewCmd("ping")); err != nil {
+		c.logger.WarnContext(ctx, "keepalive failed, closing connection", "addr", c.addr, "error", err)
+		c.discard()
+	}
+	return c.keepalive
+}
@@ -0,0 +1,40 @@
+package rrd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientKeepalive(t *testing.T) {
+	_, err := NewClient("", Keepalive(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Keepalive(time.Millisecond*20), NoReconnect)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	// Idle connections are pinged.
+	assert.Eventually(t, func() bool {
+		return s.count("ping") >= 2
+	}, time.Second, time.Millisecond*10)
+
+	// A dead server is detected without a command being sent.
+	assert.NoError(t, s.Close())
+	assert.Eventually(t, func() bool {
+		c.m.Lock()
+		defer c.m.Unlock()
+		return c.conn == nil
+	}, time.Second, time.Millisecond*10)
+	assert.ErrorIs(t, c.Ping(), ErrNotConnected)
+}
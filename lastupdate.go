@@ -0,0 +1,77 @@
+package rrd
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+)
+
+// LastUpdate represents the last update of a RRD as reported by rrdtool
+// lastupdate.
+type LastUpdate struct {
+	Time time.Time
+
+	// Names are the names of the data sources, ordered by index.
+	Names []string
+
+	// Values are the values of the last update of each data source, ordered
+	// as Names. Unknown values are represented as math.NaN().
+	Values []float64
+}
+
+// Value returns the last value of the data source ds and true, or false if
+// there is no such data source.
+func (l *LastUpdate) Value(ds string) (float64, bool) {
+	for i, n := range l.Names {
+		if n == ds {
+			return l.Values[i], true
+		}
+	}
+	return 0, false
+}
+
+// LastUpdate returns the time and values of the last update of filename.
+// See LastUpdateWithContext.
+func (c *Client) LastUpdate(filename string) (*LastUpdate, error) {
+	return c.LastUpdateWithContext(context.Background(), filename)
+}
+
+// LastUpdateWithContext returns the time and values of the last update of
+// filename. rrdcached has no lastupdate command so, unless the client is
+// read only, pending values of filename are flushed and the result is
+// derived from its uncached info.
+func (c *Client) LastUpdateWithContext(ctx context.Context, filename string) (*LastUpdate, error) {
+	if !c.readOnly {
+		if err := c.FlushWithContext(ctx, filename); err != nil {
+			return nil, err
+		}
+	}
+
+	lines, err := c.info(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := NewRRDInfo(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &LastUpdate{
+		Time:   info.LastUpdate,
+		Names:  make([]string, len(info.DS)),
+		Values: make([]float64, len(info.DS)),
+	}
+	for _, ds := range info.DS {
+		if ds.Index < 0 || ds.Index >= len(l.Names) {
+			return nil, NewInvalidResponseError("lastupdate: invalid data source index", ds.Name, strconv.Itoa(ds.Index))
+		}
+		v, err := strconv.ParseFloat(ds.LastDS, 64)
+		if err != nil {
+			v = math.NaN()
+		}
+		l.Names[ds.Index] = ds.Name
+		l.Values[ds.Index] = v
+	}
+	return l, nil
+}
@@ -0,0 +1,55 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastUpdate(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"info": {
+			"7 Info for test.rrd follows",
+			"filename 2 test.rrd",
+			"last_update 1 1499981928",
+			"ds[watts].index 1 0",
+			"ds[watts].last_ds 2 1234.5",
+			"ds[amps].index 1 1",
+			"ds[amps].last_ds 2 U",
+			"ds[volts].index 1 2",
+		},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	l, err := c.LastUpdate("test.rrd")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Unix(1499981928, 0), l.Time)
+	assert.Equal(t, []string{"watts", "amps", "volts"}, l.Names)
+	assertNaNEqual(t, []float64{1234.5, math.NaN(), math.NaN()}, l.Values)
+	assert.Equal(t, 1, s.count("flush test.rrd"))
+
+	v, ok := l.Value("watts")
+	assert.True(t, ok)
+	assert.Equal(t, 1234.5, v)
+	_, ok = l.Value("missing")
+	assert.False(t, ok)
+}
@@ -84,8 +84,14 @@ var (
 			"ds[watts].last_ds 2 U",
 			"ds[watts].unknown_sec 1 228",
 		},
-		"create": {"0 RRD created OK"},
-		"batch":  {"0 Go ahead.  End with dot '.' on its own line."},
+		"create":     {"0 RRD created OK"},
+		"tune":       {"0 Success"},
+		"list":       {"2 RRDs", "/test.rrd", "/sub/other.rrd"},
+		"suspend":    {"0 Success"},
+		"resume":     {"-1 No such file or directory"},
+		"suspendall": {"0 3 files suspended"},
+		"resumeall":  {"0 3 files resumed"},
+		"batch":      {"0 Go ahead.  End with dot '.' on its own line."},
 		".": {
 			"2 errors",
 			"1 Can't use 'ping' here.",
@@ -111,12 +117,19 @@ type server struct {
 	Addr     string
 	Listener net.Listener
 
-	t        *testing.T
+	t        testing.TB
 	conns    map[net.Conn]struct{}
 	done     chan struct{}
 	wg       sync.WaitGroup
 	failConn bool
 	mtx      sync.Mutex
+
+	// responses overrides the default commands responses, keyed by the
+	// full command line or its first word.
+	responses map[string][]string
+
+	// received records the lines received from clients.
+	received []string
 }
 
 // sconn represents a server connection
@@ -126,7 +139,7 @@ type sconn struct {
 }
 
 // newServer returns a running server or nil if an error occurred.
-func newServer(t *testing.T) *server {
+func newServer(t testing.TB) *server {
 	s := newServerStopped(t)
 	s.Start()
 
@@ -134,7 +147,7 @@ func newServer(t *testing.T) *server {
 }
 
 // newServerStopped returns a stopped servers or nil if an error occurred.
-func newServerStopped(t *testing.T) *server {
+func newServerStopped(t testing.TB) *server {
 	l, err := newLocalListener()
 	if !assert.NoError(t, err) {
 		return nil
@@ -213,6 +226,9 @@ func (s *server) handle(conn net.Conn) {
 	var batch bool
 	for sc.Scan() {
 		l := sc.Text()
+		s.mtx.Lock()
+		s.received = append(s.received, l)
+		s.mtx.Unlock()
 		parts := strings.Split(l, " ")
 		switch parts[0] {
 		case cmdQuit:
@@ -225,7 +241,13 @@ func (s *server) handle(conn net.Conn) {
 			continue
 		}
 
-		resp, ok := commands[parts[0]]
+		resp, ok := s.responses[l]
+		if !ok {
+			resp, ok = s.responses[parts[0]]
+		}
+		if !ok {
+			resp, ok = commands[parts[0]]
+		}
 		var err error
 		if ok {
 			err = s.write(c, resp...)
@@ -245,6 +267,19 @@ func (s *server) handle(conn net.Conn) {
 	}
 }
 
+// count returns the number of received lines which start with prefix.
+func (s *server) count(prefix string) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var n int
+	for _, l := range s.received {
+		if strings.HasPrefix(l, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
 // closeConn closes a client connection and removes it from our map of connections.
 func (s *server) closeConn(conn net.Conn) {
 	s.mtx.Lock()
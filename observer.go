@@ -0,0 +1,43 @@
+package rrd
+
+import (
+	"context"
+	"time"
+)
+
+// Observer is notified of the operations performed by a client, allowing
+// metrics to be collected without wrapping every call.
+// Methods are called synchronously so must not block.
+type Observer interface {
+	// CommandDone is called when cmd, the lower case command verb, completes
+	// after d with err, which is nil if it succeeded.
+	CommandDone(ctx context.Context, cmd string, d time.Duration, err error)
+
+	// Reconnected is called when an attempt to reestablish the connection to
+	// the server completes with err, which is nil if it succeeded.
+	Reconnected(ctx context.Context, err error)
+}
+
+// Observe sets the Observer of the client.
+func Observe(o Observer) func(*Client) error {
+	return func(c *Client) error {
+		if o == nil {
+			return ErrNilOption
+		}
+		c.observer = o
+		return nil
+	}
+}
+
+// observe returns a function which reports the completion of cmd, started
+// now, to the clients stats and observer if any.
+func (c *Client) observe(ctx context.Context, cmd *Cmd) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		verb, d := cmd.verb(), time.Since(start)
+		c.stats.command(verb, d, err)
+		if c.observer != nil {
+			c.observer.CommandDone(ctx, verb, d, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package rrd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingObserver is an Observer which records the notifications it receives.
+type recordingObserver struct {
+	m          sync.Mutex
+	cmds       []string
+	errs       []error
+	reconnects []error
+}
+
+func (o *recordingObserver) CommandDone(_ context.Context, cmd string, _ time.Duration, err error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.cmds = append(o.cmds, cmd)
+	o.errs = append(o.errs, err)
+}
+
+func (o *recordingObserver) Reconnected(_ context.Context, err error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.reconnects = append(o.reconnects, err)
+}
+
+func TestClientObserver(t *testing.T) {
+	_, err := NewClient("", Observe(nil))
+	assert.Equal(t, ErrNilOption, err)
+
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	o := &recordingObserver{}
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Observe(o))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+	_, err = c.Exec("invalid")
+	assert.Error(t, err)
+	assert.NoError(t, c.Batch(NewCmd("update").WithArgs("test.rrd", "N:1")))
+
+	c.m.Lock()
+	c.discard()
+	c.m.Unlock()
+	assert.NoError(t, c.Ping())
+
+	o.m.Lock()
+	defer o.m.Unlock()
+	assert.Equal(t, []string{"ping", "invalid", "batch", "ping"}, o.cmds)
+	if assert.Len(t, o.errs, 4) {
+		assert.NoError(t, o.errs[0])
+		assert.Error(t, o.errs[1])
+		assert.NoError(t, o.errs[2])
+		assert.NoError(t, o.errs[3])
+	}
+	assert.Equal(t, []error{nil}, o.reconnects)
+}
@@ -0,0 +1,191 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// PipelineResult is the result of a single command in a pipeline.
+type PipelineResult struct {
+	// Lines is the response to the command, as returned by ExecCmd.
+	Lines []string
+
+	// Err is a *CommandError if the server reported an error for the command.
+	Err error
+}
+
+// Pipeline represents a set of commands which are written to rrdcached
+// together before their responses are read, so a burst of commands such
+// as updates and flushes across many files costs a single round trip.
+// rrdcached processes the commands of a connection in order, so responses
+// are matched to commands by position.
+// A Pipeline is not safe for concurrent use.
+type Pipeline struct {
+	c    *Client
+	cmds []*Cmd
+}
+
+// NewPipeline returns a new empty Pipeline for the client.
+func (c *Client) NewPipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Add queues cmds.
+func (p *Pipeline) Add(cmds ...*Cmd) {
+	p.cmds = append(p.cmds, cmds...)
+}
+
+// Len returns the number of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec sends the queued commands, resets the pipeline and returns the
+// results in the order the commands were added.
+func (p *Pipeline) Exec() ([]PipelineResult, error) {
+	return p.ExecWithContext(context.Background())
+}
+
+// ExecWithContext sends the queued commands, resets the pipeline and
+// returns the results in the order the commands were added.
+// Errors reported by the server for individual commands are returned in
+// their result, the returned error is only set if the pipeline couldn't be
+// performed, in which case it's unknown which of the commands were processed.
+func (p *Pipeline) ExecWithContext(ctx context.Context) ([]PipelineResult, error) {
+	if len(p.cmds) == 0 {
+		return nil, nil
+	}
+
+	cmds := p.cmds
+	p.cmds = nil
+	return p.c.pipeline(ctx, cmds)
+}
+
+// pipeline writes cmds to the server and then reads their responses.
+func (c *Client) pipeline(ctx context.Context, cmds []*Cmd) (res []PipelineResult, err error) {
+	if c.lanes != nil {
+		lc, done := c.pick()
+		defer done()
+		return lc.pipeline(ctx, cmds)
+	}
+
+	ctx, done := c.instrument(ctx, NewCmd("pipeline"))
+	defer func() { done(err) }()
+
+	resolved := make([]*Cmd, len(cmds))
+	for i, cmd := range cmds {
+		switch cmd.verb() {
+		case "batch", "quit":
+			return nil, &CommandError{Cmd: cmd.verb(), Err: fmt.Errorf("%w: can't be pipelined", ErrInvalidArg)}
+		}
+		if c.readOnly && cmd.mutating() {
+			return nil, &CommandError{Cmd: c.cmdString(cmd), Err: ErrReadOnly}
+		}
+		rc, err := c.resolveCmd(cmd)
+		if err != nil {
+			return nil, &CommandError{Cmd: c.cmdString(cmd), Err: err}
+		}
+		if err := rc.validate(); err != nil {
+			return nil, &CommandError{Cmd: c.cmdString(cmd), Err: err}
+		}
+		resolved[i] = rc
+	}
+	cmds = resolved
+
+	if err := c.limit(ctx, len(cmds)); err != nil {
+		return nil, fmt.Errorf("pipeline: %w", err)
+	}
+
+	report, err := c.admit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline: %w", err)
+	}
+	defer func() { report(err) }()
+
+	ctx, cancel := c.commandContext(ctx)
+	defer cancel()
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for _, cmd := range cmds {
+		if err := c.supported(cmd); err != nil {
+			return nil, &CommandError{Cmd: c.cmdString(cmd), Err: err}
+		}
+	}
+
+	if err := c.ensureConn(ctx); err != nil {
+		return nil, fmt.Errorf("pipeline: failed to connect: %w", err)
+	}
+
+	stop := c.watchContext(ctx)
+	defer stop()
+
+	if err := c.setDeadline(ctx); err != nil {
+		return nil, fmt.Errorf("pipeline: %w", err)
+	}
+
+	// Write concurrently with reading the responses, so a large pipeline
+	// can't deadlock with the server blocked writing responses.
+	conn := c.conn
+	b := c.pipelineData(ctx, cmds)
+	werr := make(chan error, 1)
+	go func() {
+		werr <- writeAll(conn, b)
+	}()
+
+	res, err = c.readPipeline(ctx, cmds)
+	if err != nil {
+		err = c.ctxErr(ctx, err)
+		// The remaining responses can't be matched to their commands.
+		c.discard()
+		<-werr
+		return nil, fmt.Errorf("pipeline: %w", err)
+	}
+
+	if err := <-werr; err != nil {
+		c.discard()
+		return nil, fmt.Errorf("pipeline: failed to write: %w", err)
+	}
+
+	return res, nil
+}
+
+// pipelineData returns the protocol representation of cmds.
+func (c *Client) pipelineData(ctx context.Context, cmds []*Cmd) []byte {
+	debug := c.logger.Enabled(ctx, slog.LevelDebug)
+	var b []byte
+	for _, cmd := range cmds {
+		b = cmd.appendTo(b)
+		if debug {
+			c.logger.DebugContext(ctx, "rrdcached command", "cmd", c.cmdString(cmd))
+		}
+	}
+	return b
+}
+
+// readPipeline reads the responses to cmds.
+func (c *Client) readPipeline(ctx context.Context, cmds []*Cmd) ([]PipelineResult, error) {
+	res := make([]PipelineResult, len(cmds))
+	for i, cmd := range cmds {
+		var lines []string
+		err := c.readResponse(ctx, func(l string) error {
+			lines = append(lines, l)
+			return nil
+		})
+
+		var rerr *Error
+		switch {
+		case err == nil:
+			res[i].Lines = lines
+		case errors.As(err, &rerr):
+			res[i].Err = &CommandError{Cmd: c.cmdString(cmd), Err: err}
+		default:
+			return nil, fmt.Errorf("failed to read response %v: %w", i, err)
+		}
+	}
+
+	return res, nil
+}
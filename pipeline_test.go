@@ -0,0 +1,128 @@
+package rrd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	p := c.NewPipeline()
+	res, err := p.Exec()
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	p.Add(
+		NewCmd("ping"),
+		NewCmd("pending").WithArgs("test.rrd"),
+		NewCmd("queue"),
+		NewCmd("flush").WithArgs("test.rrd"),
+	)
+	assert.Equal(t, 4, p.Len())
+
+	res, err = p.Exec()
+	assert.Equal(t, 0, p.Len())
+	if !assert.NoError(t, err) || !assert.Len(t, res, 4) {
+		return
+	}
+
+	assert.Equal(t, []string{"PONG"}, res[0].Lines)
+	assert.NoError(t, res[0].Err)
+	assert.True(t, IsNotExist(res[1].Err))
+	var cerr *CommandError
+	if assert.ErrorAs(t, res[1].Err, &cerr) {
+		assert.Equal(t, "pending test.rrd", cerr.Cmd)
+	}
+	assert.Equal(t, []string{"10 test.rrd"}, res[2].Lines)
+	assert.Equal(t, []string{"Nothing to flush: /test.rrd."}, res[3].Lines)
+
+	// The connection is still in sync.
+	assert.NoError(t, c.Ping())
+}
+
+func TestPipelineLarge(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*5))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	// Enough commands that neither side can buffer all the data.
+	const n = 20000
+	p := c.NewPipeline()
+	for i := 0; i < n; i++ {
+		p.Add(NewCmd("stats"))
+	}
+
+	res, err := p.Exec()
+	if assert.NoError(t, err) && assert.Len(t, res, n) {
+		assert.Len(t, res[n-1].Lines, 9)
+	}
+}
+
+func TestPipelineInvalid(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), ReadOnly)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	tests := []struct {
+		name string
+		cmd  *Cmd
+		err  error
+	}{
+		{"batch", NewCmd("batch"), ErrInvalidArg},
+		{"quit", NewCmd("quit"), ErrInvalidArg},
+		{"read-only", NewCmd("update").WithArgs("test.rrd", "N:1"), ErrReadOnly},
+		{"invalid-arg", NewCmd("first").WithArgs("test.rrd\nflushall"), ErrInvalidArg},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := c.NewPipeline()
+			p.Add(NewCmd("ping"), tc.cmd)
+			_, err := p.Exec()
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	assert.Equal(t, 0, s.count("ping"))
+}
@@ -0,0 +1,172 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPoolHealthCheck is the default idle time after which a pooled
+	// client is checked with a ping before being handed out.
+	DefaultPoolHealthCheck = time.Second * 30
+)
+
+// pooledClient is an idle client in a Pool.
+type pooledClient struct {
+	c    *Client
+	used time.Time
+}
+
+// Pool is a fixed size pool of rrdcached clients, which allows concurrent
+// callers to execute commands without being serialized behind a single
+// connection.
+type Pool struct {
+	addr        string
+	options     []func(*Client) error
+	healthCheck time.Duration
+	idle        chan pooledClient
+
+	// done is closed when the pool is closed, waking waiting callers of Get.
+	done chan struct{}
+
+	m      sync.Mutex
+	closed bool
+}
+
+// NewPool returns a new Pool of size clients connected to addr, each created
+// with NewClient and options.
+func NewPool(addr string, size int, options ...func(*Client) error) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("%w %v", ErrInvalidPoolSize, size)
+	}
+
+	p := &Pool{
+		addr:        addr,
+		options:     options,
+		healthCheck: DefaultPoolHealthCheck,
+		idle:        make(chan pooledClient, size),
+		done:        make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		c, err := NewClient(addr, options...)
+		if err != nil {
+			p.Close() // nolint: errcheck
+			return nil, err
+		}
+		p.idle <- pooledClient{c: c, used: time.Now()}
+	}
+
+	return p, nil
+}
+
+// Get returns an idle client from the pool, waiting for one to be returned
+// if all are in use. The client must be returned with Put once finished.
+// Clients which have been idle for a while are checked with a ping and
+// replaced if they are no longer healthy.
+// Once the pool is closed, including while waiting, ErrPoolClosed is
+// returned.
+func (p *Pool) Get(ctx context.Context) (*Client, error) {
+	if p.isClosed() {
+		return nil, ErrPoolClosed
+	}
+
+	var pc pooledClient
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case pc = <-p.idle:
+	}
+
+	if p.isClosed() {
+		pc.c.Close() // nolint: errcheck
+		return nil, ErrPoolClosed
+	}
+
+	if time.Since(pc.used) < p.healthCheck {
+		return pc.c, nil
+	}
+
+	if err := pc.c.PingWithContext(ctx); err == nil {
+		return pc.c, nil
+	}
+
+	// Unhealthy, replace it.
+	c, err := NewClient(p.addr, p.options...)
+	if err != nil {
+		// Keep the unhealthy client, so the pool stays at size, without
+		// updating its last use so it's checked again next time.
+		p.put(pc)
+		return nil, fmt.Errorf("pool: failed to replace client: %w", err)
+	}
+	pc.c.Close() // nolint: errcheck
+	return c, nil
+}
+
+// Put returns c, obtained from Get, to the pool.
+func (p *Pool) Put(c *Client) {
+	p.put(pooledClient{c: c, used: time.Now()})
+}
+
+// put adds pc to the idle clients, closing it if the pool is closed or
+// already full, such as if a client was returned twice.
+func (p *Pool) put(pc pooledClient) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.closed {
+		pc.c.Close() // nolint: errcheck
+		return
+	}
+	select {
+	case p.idle <- pc:
+	default:
+		pc.c.Close() // nolint: errcheck
+	}
+}
+
+// Do calls f with a client from the pool, returning it to the pool once f
+// completes.
+func (p *Pool) Do(ctx context.Context, f func(c *Client) error) error {
+	c, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Put(c)
+
+	return f(c)
+}
+
+// isClosed returns true if the pool has been closed, false otherwise.
+func (p *Pool) isClosed() bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.closed
+}
+
+// Close closes the idle clients in the pool, clients which are in use are
+// closed when they are returned.
+func (p *Pool) Close() error {
+	p.m.Lock()
+	if p.closed {
+		p.m.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.m.Unlock()
+
+	var err error
+	for {
+		select {
+		case pc := <-p.idle:
+			if err2 := pc.c.Close(); err2 != nil && err == nil {
+				err = err2
+			}
+		default:
+			return err
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package rrd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	_, err := NewPool(s.Addr, 0)
+	assert.ErrorIs(t, err, ErrInvalidPoolSize)
+
+	p, err := NewPool(s.Addr, 2, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, p.Do(context.Background(), func(c *Client) error {
+				return c.Ping()
+			}))
+		}()
+	}
+	wg.Wait()
+
+	// All clients in use.
+	c1, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	c2, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = p.Get(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	p.Put(c1)
+	p.Put(c2)
+
+	// Idle clients are health checked.
+	p.healthCheck = 0
+	before := s.count("ping")
+	assert.NoError(t, p.Do(context.Background(), func(c *Client) error { return nil }))
+	assert.Equal(t, before+1, s.count("ping"))
+
+	assert.NoError(t, p.Close())
+	_, err = p.Get(context.Background())
+	assert.Equal(t, ErrPoolClosed, err)
+	assert.NoError(t, p.Close())
+}
+
+func TestPoolGetAfterClose(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	p, err := NewPool(s.Addr, 1, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c, err := p.Get(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// A caller waiting for a client is woken when the pool is closed.
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.Get(context.Background())
+		errs <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+	assert.NoError(t, p.Close())
+
+	select {
+	case err := <-errs:
+		assert.Equal(t, ErrPoolClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("Get not woken by Close")
+	}
+
+	// Clients returned after closing are closed.
+	p.Put(c)
+	assert.Equal(t, 0, len(p.idle))
+}
+
+func TestPoolDoublePut(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	p, err := NewPool(s.Addr, 1, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c, err := p.Get(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	p.Put(c)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Put(c)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("second Put blocked")
+	}
+	assert.Equal(t, 1, len(p.idle))
+	assert.NoError(t, p.Close())
+}
@@ -0,0 +1,225 @@
+package rrd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// proxyHandshake performs the proxy handshake on conn, returning the
+// address requested or an empty string if refused.
+type proxyHandshake func(t *testing.T, conn net.Conn, br *bufio.Reader) string
+
+// newProxy returns the address of a proxy which performs handshake then
+// forwards the connection, and the number of connections it handled.
+func newProxy(t *testing.T, handshake proxyHandshake) (string, *atomic.Int64) {
+	l, err := newLocalListener()
+	if !assert.NoError(t, err) {
+		return "", nil
+	}
+	t.Cleanup(func() { l.Close() }) // nolint: errcheck
+
+	var conns atomic.Int64
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go func() {
+				defer conn.Close() // nolint: errcheck
+				br := bufio.NewReader(conn)
+				addr := handshake(t, conn, br)
+				if addr == "" {
+					return
+				}
+				target, err := net.Dial("tcp", addr)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer target.Close()   // nolint: errcheck
+				go io.Copy(target, br) // nolint: errcheck
+				io.Copy(conn, target)  // nolint: errcheck
+			}()
+		}
+	}()
+	return l.Addr().String(), &conns
+}
+
+// socks5Handshake returns a SOCKS5 handshake which requires user and pass
+// if user isn't empty.
+func socks5Handshake(user, pass string) proxyHandshake {
+	return func(t *testing.T, conn net.Conn, br *bufio.Reader) string {
+		b := make([]byte, 2)
+		if _, err := io.ReadFull(br, b); !assert.NoError(t, err) {
+			return ""
+		}
+		methods := make([]byte, b[1])
+		if _, err := io.ReadFull(br, methods); !assert.NoError(t, err) {
+			return ""
+		}
+		if user == "" {
+			conn.Write([]byte{5, 0}) // nolint: errcheck
+		} else {
+			conn.Write([]byte{5, 2}) // nolint: errcheck
+			io.ReadFull(br, b[:2])   // nolint: errcheck
+			name := make([]byte, b[1])
+			io.ReadFull(br, name)  // nolint: errcheck
+			io.ReadFull(br, b[:1]) // nolint: errcheck
+			pw := make([]byte, b[0])
+			io.ReadFull(br, pw) // nolint: errcheck
+			if string(name) != user || string(pw) != pass {
+				conn.Write([]byte{1, 1}) // nolint: errcheck
+				return ""
+			}
+			conn.Write([]byte{1, 0}) // nolint: errcheck
+		}
+
+		req := make([]byte, 4)
+		if _, err := io.ReadFull(br, req); !assert.NoError(t, err) {
+			return ""
+		}
+		var host string
+		switch req[3] {
+		case socks5IPv4, socks5IPv6:
+			ip := make([]byte, net.IPv4len)
+			if req[3] == socks5IPv6 {
+				ip = make([]byte, net.IPv6len)
+			}
+			io.ReadFull(br, ip) // nolint: errcheck
+			host = net.IP(ip).String()
+		case socks5Domain:
+			io.ReadFull(br, b[:1]) // nolint: errcheck
+			name := make([]byte, b[0])
+			io.ReadFull(br, name) // nolint: errcheck
+			host = string(name)
+		}
+		io.ReadFull(br, b) // nolint: errcheck
+		port := binary.BigEndian.Uint16(b)
+
+		if host == "refused.invalid" {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) // nolint: errcheck
+			return ""
+		}
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 1}) // nolint: errcheck
+		return net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+}
+
+// httpHandshake is a HTTP CONNECT handshake which requires the basic auth
+// user "user" and password "pass".
+func httpHandshake(t *testing.T, conn net.Conn, br *bufio.Reader) string {
+	req, err := http.ReadRequest(br)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	assert.Equal(t, http.MethodConnect, req.Method)
+
+	req.Header.Set("Authorization", req.Header.Get("Proxy-Authorization"))
+	if user, pass, _ := req.BasicAuth(); user != "user" || pass != "pass" {
+		conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")) // nolint: errcheck
+		return ""
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")) // nolint: errcheck
+	return req.Host
+}
+
+func TestClientProxy(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	socks, socksConns := newProxy(t, socks5Handshake("", ""))
+	socksAuth, _ := newProxy(t, socks5Handshake("user", "pass"))
+	httpProxy, httpConns := newProxy(t, httpHandshake)
+
+	tests := []struct {
+		name  string
+		proxy string
+		conns *atomic.Int64
+	}{
+		{"socks5", "socks5://" + socks, socksConns},
+		{"socks5h", "socks5h://" + socks, socksConns},
+		{"socks5-auth", "socks5://user:pass@" + socksAuth, nil},
+		{"http", "http://user:pass@" + httpProxy, httpConns},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var before int64
+			if tc.conns != nil {
+				before = tc.conns.Load()
+			}
+
+			c, err := NewClient(s.Addr, Timeout(time.Second*2), Proxy(tc.proxy))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			assert.NoError(t, c.Ping())
+			if tc.conns != nil {
+				assert.Equal(t, before+1, tc.conns.Load())
+			}
+		})
+	}
+}
+
+func TestClientProxyFail(t *testing.T) {
+	socks, _ := newProxy(t, socks5Handshake("user", "pass"))
+	httpProxy, _ := newProxy(t, httpHandshake)
+
+	tests := []struct {
+		name   string
+		addr   string
+		proxy  string
+		expect string
+	}{
+		{"socks5-refused", "refused.invalid:42217", "socks5://user:pass@" + socks, "connection refused"},
+		{"socks5-auth", "localhost:42217", "socks5://user:bad@" + socks, "authentication failed"},
+		{"socks5-no-auth", "localhost:42217", "socks5://" + socks, "unexpected SOCKS authentication method"},
+		{"http-auth", "localhost:42217", "http://" + httpProxy, "407"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewClient(tc.addr, Timeout(time.Second*2), Proxy(tc.proxy))
+			assert.ErrorContains(t, err, tc.expect)
+		})
+	}
+}
+
+func TestProxyOption(t *testing.T) {
+	tests := []struct {
+		name  string
+		addr  string
+		proxy string
+	}{
+		{"scheme", "localhost", "ftp://proxy"},
+		{"no-host", "localhost", "socks5://"},
+		{"invalid", "localhost", "http://[::1"},
+		{"unix", "unix:///var/run/rrdcached.sock", "socks5://proxy"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewClient(tc.addr, Proxy(tc.proxy))
+			assert.ErrorIs(t, err, ErrInvalidArg)
+		})
+	}
+}
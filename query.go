@@ -0,0 +1,139 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// QueryPlan describes the archive of a RRD selected to answer a query.
+type QueryPlan struct {
+	// RRA is the index of the archive in RRDInfo.RRA.
+	RRA int
+
+	// CF is the consolidation function of the archive.
+	CF ConsolidationFunc
+
+	// Step is the resolution of the archive.
+	Step time.Duration
+
+	// Start and End are the query range aligned to Step.
+	Start time.Time
+	End   time.Time
+
+	// Rows is the number of rows in the range.
+	Rows int
+
+	// FullMatch is true if the archive covers the whole range, otherwise
+	// it's the archive which covers most of it.
+	FullMatch bool
+}
+
+// PlanQuery selects the archive of the RRD described by info best suited to
+// return points rows with cf between start and end, as rrdtool graph does
+// for a graph points pixels wide. Of the archives which cover the whole
+// range the one whose resolution is closest to (end - start) / points is
+// selected, if none do the one which covers most of the range. If points is
+// zero or less the highest resolution is preferred.
+func PlanQuery(info *RRDInfo, cf ConsolidationFunc, start, end time.Time, points int) (*QueryPlan, error) {
+	cf, err := cf.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: start %v not before end %v", ErrInvalidArg, start, end)
+	}
+	pdpStep := int64(info.Step / time.Second)
+	if pdpStep <= 0 {
+		return nil, fmt.Errorf("%w: step %v", ErrInvalidArg, info.Step)
+	}
+
+	var want int64
+	if points > 0 {
+		want = (end.Unix() - start.Unix()) / int64(points)
+	}
+
+	s, e := start.Unix(), end.Unix()
+	last := info.LastUpdate.Unix()
+	best, bestMatch, bestDiff := -1, int64(-1), int64(math.MaxInt64)
+	var full bool
+	for i, rra := range info.RRA {
+		if ConsolidationFunc(rra.CF) != cf || rra.PDPPerRow <= 0 {
+			continue
+		}
+		step := pdpStep * rra.PDPPerRow
+		calEnd := last - last%step
+		calStart := calEnd - step*rra.Rows
+		diff := abs64(want - step)
+		if calStart <= s && calEnd >= e {
+			if !full || diff < bestDiff {
+				best, bestDiff, full = i, diff, true
+			}
+			continue
+		}
+		if full {
+			continue
+		}
+		match := e - s
+		if calStart > s {
+			match -= calStart - s
+		}
+		if calEnd < e {
+			match -= e - calEnd
+		}
+		if match > bestMatch || (match == bestMatch && diff < bestDiff) {
+			best, bestMatch, bestDiff = i, match, diff
+		}
+	}
+	if best == -1 {
+		return nil, fmt.Errorf("%w: %v has no %v archive", ErrInvalidCF, info.Filename, cf)
+	}
+
+	step := pdpStep * info.RRA[best].PDPPerRow
+	s -= s % step
+	if r := e % step; r != 0 {
+		e += step - r
+	}
+	return &QueryPlan{
+		RRA:       best,
+		CF:        cf,
+		Step:      time.Duration(step) * time.Second,
+		Start:     time.Unix(s, 0),
+		End:       time.Unix(e, 0),
+		Rows:      int((e - s) / step),
+		FullMatch: full,
+	}, nil
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Query returns the time series of filename for cf between start and end at
+// the resolution of the archive selected by PlanQuery for points rows.
+// As rrdcached always fetches from the highest resolution archive covering
+// the range, rows of a higher resolution than the plan are consolidated
+// with cf by the client.
+func (c *Client) Query(ctx context.Context, filename string, cf ConsolidationFunc, start, end time.Time, points int) (*FetchResult, error) {
+	info, err := c.InfoStructWithContext(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := PlanQuery(info, cf, start, end, points)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.FetchRangeWithContext(ctx, filename, plan.CF, plan.Start, plan.End)
+	if err != nil {
+		return nil, err
+	}
+	if r.Step >= time.Second && r.Step < plan.Step && plan.Step%r.Step == 0 {
+		return r.Downsample(plan.Step, cfAggregator(plan.CF))
+	}
+	return r, nil
+}
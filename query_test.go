@@ -0,0 +1,132 @@
+package rrd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testQueryInfo returns the info of a RRD with a one minute step keeping a
+// day of minutes, a week of five minutes and a year of hours.
+func testQueryInfo() *RRDInfo {
+	return &RRDInfo{
+		Filename:   "test.rrd",
+		Step:       time.Minute,
+		LastUpdate: time.Unix(1008000, 0),
+		RRA: []RRAInfo{
+			{CF: "AVERAGE", Rows: 1440, PDPPerRow: 1},
+			{CF: "AVERAGE", Rows: 2016, PDPPerRow: 5},
+			{CF: "AVERAGE", Rows: 8760, PDPPerRow: 60},
+			{CF: "MAX", Rows: 1440, PDPPerRow: 1},
+		},
+	}
+}
+
+func TestPlanQuery(t *testing.T) {
+	end := time.Unix(1008000, 0)
+	tests := []struct {
+		name   string
+		cf     ConsolidationFunc
+		start  time.Time
+		points int
+		rra    int
+		step   time.Duration
+		full   bool
+		err    error
+	}{
+		{"hour", Average, end.Add(-time.Hour), 0, 0, time.Minute, true, nil},
+		{"hour-points", Average, end.Add(-time.Hour), 12, 1, time.Minute * 5, true, nil},
+		{"days", Average, end.Add(-time.Hour * 48), 0, 1, time.Minute * 5, true, nil},
+		{"days-points", Average, end.Add(-time.Hour * 48), 48, 2, time.Hour, true, nil},
+		{"partial", Average, end.Add(-time.Hour * 24 * 800), 0, 2, time.Hour, false, nil},
+		{"max", "max", end.Add(-time.Hour), 0, 3, time.Minute, true, nil},
+		{"missing-cf", Last, end.Add(-time.Hour), 0, 0, 0, false, ErrInvalidCF},
+		{"invalid-cf", "SUM", end.Add(-time.Hour), 0, 0, 0, false, ErrInvalidCF},
+		{"invalid-range", Average, end, 0, 0, 0, false, ErrInvalidArg},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := PlanQuery(testQueryInfo(), tc.cf, tc.start, end, tc.points)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.rra, p.RRA)
+			assert.Equal(t, tc.step, p.Step)
+			assert.Equal(t, tc.full, p.FullMatch)
+			assert.Equal(t, 0, int(p.Start.Unix()%int64(tc.step/time.Second)))
+			assert.Equal(t, end, p.End)
+			assert.Equal(t, int(p.End.Sub(p.Start)/p.Step), p.Rows)
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		"info": {
+			"10 Info for test.rrd follows",
+			"filename 2 test.rrd",
+			"step 1 60",
+			"last_update 1 1008000",
+			"ds[watts].index 1 0",
+			"rra[0].cf 2 AVERAGE",
+			"rra[0].rows 1 1440",
+			"rra[0].pdp_per_row 1 1",
+			"rra[1].cf 2 AVERAGE",
+			"rra[1].rows 1 2016",
+			"rra[1].pdp_per_row 1 5",
+		},
+		"fetch": {
+			"16 Success",
+			"FlushVersion: 1",
+			"Start: 1007400",
+			"End: 1008000",
+			"Step: 60",
+			"DSCount: 1",
+			"DSName: watts",
+			"1007460: 1",
+			"1007520: 2",
+			"1007580: 3",
+			"1007640: 4",
+			"1007700: 5",
+			"1007760: 6",
+			"1007820: 7",
+			"1007880: nan",
+			"1007940: 9",
+			"1008000: 10",
+		},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	r, err := c.Query(context.Background(), "test.rrd", Average, time.Unix(1007400, 0), time.Unix(1008000, 0), 2)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Minute*5, r.Step)
+	assert.Equal(t, []FetchResultRow{
+		{Time: time.Unix(1007700, 0), Values: []float64{3}},
+		{Time: time.Unix(1008000, 0), Values: []float64{8}},
+	}, r.Rows)
+	assert.Equal(t, 1, s.count("fetch test.rrd AVERAGE 1007400 1008000"))
+}
@@ -0,0 +1,102 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit limits the client to perSecond commands per second, allowing
+// bursts of up to burst commands, so a misbehaving caller can't overwhelm a
+// shared server. Commands exceeding the limit wait until they are permitted
+// or their context is done. Batches and pipelines count each of their commands.
+func RateLimit(perSecond float64, burst int) func(*Client) error {
+	return func(c *Client) error {
+		if !(perSecond > 0) || math.IsInf(perSecond, 1) || burst < 1 {
+			return fmt.Errorf("%w: rate limit %v burst %v", ErrInvalidArg, perSecond, burst)
+		}
+		c.limiter = newRateLimiter(perSecond, burst)
+		return nil
+	}
+}
+
+// rateLimiter is a token bucket rate limiter.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	m         sync.Mutex
+	tokens    float64
+	last      time.Time
+	throttled int64
+	waited    time.Duration
+}
+
+// newRateLimiter returns a new full rateLimiter.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// advance adds the tokens accumulated since the last call.
+// The caller must hold the lock.
+func (l *rateLimiter) advance(now time.Time) {
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// wait blocks until n commands are permitted or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.m.Lock()
+	l.advance(time.Now())
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		l.m.Unlock()
+		return nil
+	}
+
+	d := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		l.tokens += float64(n)
+		l.m.Unlock()
+		return fmt.Errorf("%w: wait of %v exceeds context deadline", ErrRateLimited, d)
+	}
+	l.throttled++
+	l.waited += d
+	l.m.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		l.m.Lock()
+		l.tokens += float64(n)
+		l.m.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// stats returns the number of throttled commands, the total time they were
+// delayed and the number of commands currently available.
+func (l *rateLimiter) stats() (int64, time.Duration, float64) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.advance(time.Now())
+	return l.throttled, l.waited, math.Max(l.tokens, 0)
+}
+
+// limit waits until n commands are permitted by the clients rate limit, if any.
+func (c *Client) limit(ctx context.Context, n int) error {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.wait(ctx, n)
+}
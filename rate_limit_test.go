@@ -0,0 +1,104 @@
+package rrd
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitOption(t *testing.T) {
+	tests := []struct {
+		name      string
+		perSecond float64
+		burst     int
+	}{
+		{"zero-rate", 0, 1},
+		{"negative-rate", -1, 1},
+		{"nan-rate", math.NaN(), 1},
+		{"inf-rate", math.Inf(1), 1},
+		{"zero-burst", 1, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewClient("", RateLimit(tc.perSecond, tc.burst))
+			assert.ErrorIs(t, err, ErrInvalidArg)
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(100, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	assert.NoError(t, l.wait(ctx, 1))
+	assert.NoError(t, l.wait(ctx, 1))
+	throttled, _, _ := l.stats()
+	assert.Equal(t, int64(0), throttled)
+
+	// The bucket is empty so the next commands wait.
+	assert.NoError(t, l.wait(ctx, 2))
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*15)
+
+	throttled, waited, _ := l.stats()
+	assert.Equal(t, int64(1), throttled)
+	assert.Greater(t, waited, time.Duration(0))
+
+	// Waits which would exceed the deadline fail immediately.
+	ctx2, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.wait(ctx2, 100), ErrRateLimited)
+
+	// Cancelled waits return the tokens.
+	ctx3, cancel3 := context.WithCancel(ctx)
+	cancel3()
+	assert.ErrorIs(t, l.wait(ctx3, 10), context.Canceled)
+	throttled, _, _ = l.stats()
+	assert.Equal(t, int64(2), throttled)
+
+	time.Sleep(time.Millisecond * 30)
+	_, _, available := l.stats()
+	assert.Equal(t, float64(2), available)
+}
+
+func TestClientRateLimit(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), RateLimit(50, 2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, c.Ping())
+	}
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*30)
+
+	stats := c.ClientStats()
+	assert.True(t, stats.RateLimited)
+	assert.Equal(t, int64(2), stats.Throttled)
+	assert.Greater(t, stats.ThrottleWait, time.Duration(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	p := c.NewPipeline()
+	for i := 0; i < 10; i++ {
+		p.Add(NewCmd("ping"))
+	}
+	_, err = p.ExecWithContext(ctx)
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
@@ -0,0 +1,75 @@
+package rrd
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Samples returns the samples which replay the rows of r after after, up to
+// the last update of info, to a RRD with the data sources of info.
+//
+// The per second rates of COUNTER, DERIVE and ABSOLUTE data sources are
+// converted back to the values which result in the same rates, over the
+// interval since the previous row or Step for the first. bases are the last
+// values of COUNTER, DERIVE, DCOUNTER and DDERIVE data sources, which are
+// updated as rows are converted. COMPUTE data sources are always unknown.
+func (r *FetchResult) Samples(info *RRDInfo, after time.Time, bases map[string]float64) ([]Sample, error) {
+	n := len(info.DS)
+	cols := make([]int, n)
+	types := make([]string, n)
+	names := make([]string, n)
+	for _, d := range info.DS {
+		if d.Index < 0 || d.Index >= n {
+			return nil, fmt.Errorf("%w: data source %v index %v", ErrInvalidArg, d.Name, d.Index)
+		}
+		cols[d.Index], types[d.Index], names[d.Index] = -1, d.Type, d.Name
+		for i, name := range r.Names {
+			if name == d.Name {
+				cols[d.Index] = i
+			}
+		}
+		if cols[d.Index] == -1 && d.Type != Compute {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownDS, d.Name)
+		}
+	}
+
+	prev := time.Time{}
+	var samples []Sample
+	for _, row := range r.Rows {
+		interval := r.Step.Seconds()
+		if !prev.IsZero() {
+			interval = row.Time.Sub(prev).Seconds()
+		}
+		prev = row.Time
+		if !row.Time.After(after) || row.Time.After(info.LastUpdate) {
+			continue
+		}
+
+		s := Sample{Time: row.Time, Values: make([]float64, n)}
+		for i := range s.Values {
+			v := math.NaN()
+			if cols[i] >= 0 && cols[i] < len(row.Values) {
+				v = row.Values[cols[i]]
+			}
+			if !math.IsNaN(v) {
+				switch types[i] {
+				case Counter, Derive:
+					// Only integers are accepted.
+					bases[names[i]] += v * interval
+					v = math.Round(bases[names[i]])
+				case DCounter, DDerive:
+					bases[names[i]] += v * interval
+					v = bases[names[i]]
+				case Absolute:
+					v *= interval
+				case Compute:
+					v = math.NaN()
+				}
+			}
+			s.Values[i] = v
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
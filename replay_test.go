@@ -0,0 +1,67 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchResultSamples(t *testing.T) {
+	nan := math.NaN()
+	r := &FetchResult{
+		Step:  time.Minute,
+		Names: []string{"g", "c", "a", "d"},
+		Rows: []FetchResultRow{
+			{Time: time.Unix(60, 0), Values: []float64{1, 1, 1, 0.5}},
+			{Time: time.Unix(120, 0), Values: []float64{2, 0.5, nan, 0.5}},
+			// Coarser rows have a longer interval.
+			{Time: time.Unix(240, 0), Values: []float64{nan, 0.25, 2, 0.25}},
+			{Time: time.Unix(300, 0), Values: []float64{4, 1, 1, 1}},
+		},
+	}
+	info := &RRDInfo{
+		LastUpdate: time.Unix(240, 0),
+		DS: map[string]DSInfo{
+			"g": {Name: "g", Index: 0, Type: Gauge},
+			"c": {Name: "c", Index: 1, Type: Counter},
+			"a": {Name: "a", Index: 2, Type: Absolute},
+			"d": {Name: "d", Index: 3, Type: DDerive},
+			"x": {Name: "x", Index: 4, Type: Compute},
+		},
+	}
+
+	bases := map[string]float64{"c": 100, "d": 0.5}
+	samples, err := r.Samples(info, time.Unix(60, 0), bases)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Sample{
+		{Time: time.Unix(120, 0), Values: []float64{2, 130, -1, 30.5, -1}},
+		{Time: time.Unix(240, 0), Values: []float64{-1, 160, 240, 60.5, -1}},
+	}, nanSafe(samples))
+	assert.Equal(t, map[string]float64{"c": 160, "d": 60.5}, bases)
+
+	info.DS["m"] = DSInfo{Name: "m", Index: 5, Type: Gauge}
+	_, err = r.Samples(info, time.Time{}, map[string]float64{})
+	assert.ErrorIs(t, err, ErrUnknownDS)
+
+	delete(info.DS, "m")
+	info.DS["g"] = DSInfo{Name: "g", Index: 9, Type: Gauge}
+	_, err = r.Samples(info, time.Time{}, map[string]float64{})
+	assert.ErrorIs(t, err, ErrInvalidArg)
+}
+
+// nanSafe returns samples with NaN values replaced by -1, so they can be
+// compared with assert.Equal.
+func nanSafe(samples []Sample) []Sample {
+	for _, s := range samples {
+		for i, v := range s.Values {
+			if math.IsNaN(v) {
+				s.Values[i] = -1
+			}
+		}
+	}
+	return samples
+}
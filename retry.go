@@ -0,0 +1,104 @@
+package rrd
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how a client reconnects to the server and which
+// failed writes are retried on a new connection.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of connection attempts per reconnect
+	// and of write attempts per command, values less than one mean one.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+
+	// MaxBackoff limits the delay between attempts.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor the delay is increased by after each attempt.
+	Multiplier float64
+
+	// Jitter is the fraction, between 0 and 1, by which each delay is
+	// randomly varied to avoid synchronised retries from many clients.
+	Jitter float64
+
+	// Retryable returns true if a failed write should be retried on a new
+	// connection, if nil IsRetryable is used.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by clients unless overridden.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    10,
+	InitialBackoff: time.Millisecond * 100,
+	MaxBackoff:     time.Second * 5,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// Retry sets the RetryPolicy of the client.
+func Retry(p RetryPolicy) func(*Client) error {
+	return func(c *Client) error {
+		c.retry = p
+		return nil
+	}
+}
+
+// NoReconnect disables automatic reconnection, once the connection to the
+// server fails all commands return ErrNotConnected.
+func NoReconnect(c *Client) error {
+	c.noReconnect = true
+	return nil
+}
+
+// IsRetryable returns true if err is a connection failure after which the
+// command can be retried on a new connection, false otherwise.
+func IsRetryable(err error) bool {
+	var nerr net.Error
+	switch {
+	case errors.Is(err, syscall.EPIPE),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, net.ErrClosed),
+		errors.Is(err, os.ErrDeadlineExceeded):
+		return true
+	case errors.As(err, &nerr) && nerr.Timeout():
+		return true
+	}
+	return false
+}
+
+// attempts returns the maximum number of attempts permitted by p.
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryable returns true if err should be retried according to p.
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff returns the delay before attempt, where the first retry is attempt one.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(math.Max(p.Multiplier, 1), float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,93 @@
+package rrd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Millisecond * 100, MaxBackoff: time.Second, Multiplier: 2}
+	assert.Equal(t, time.Millisecond*100, p.backoff(1))
+	assert.Equal(t, time.Millisecond*200, p.backoff(2))
+	assert.Equal(t, time.Millisecond*800, p.backoff(4))
+	assert.Equal(t, time.Second, p.backoff(5))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(1)
+		assert.True(t, d >= time.Millisecond*50 && d <= time.Millisecond*150, "unexpected backoff %v", d)
+	}
+
+	assert.Equal(t, 1, RetryPolicy{}.attempts())
+	assert.Equal(t, 3, RetryPolicy{MaxAttempts: 3}.attempts())
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{fmt.Errorf("write: %w", syscall.EPIPE), true},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.ECONNRESET)}, true},
+		{net.ErrClosed, true},
+		{os.ErrDeadlineExceeded, true},
+		{io.EOF, false},
+		{errors.New("other"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsRetryable(tc.err))
+		})
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), Retry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Write on the closed connection is retried on a new one.
+	assert.NoError(t, c.conn.Close())
+	assert.NoError(t, c.Ping())
+
+	// Reconnect gives up after the policies attempts.
+	assert.NoError(t, s.Close())
+	assert.NoError(t, c.conn.Close())
+	assert.ErrorIs(t, c.Ping(), ErrReconnectionFailed)
+}
+
+func TestClientNoReconnect(t *testing.T) {
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), NoReconnect)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, c.conn.Close())
+	assert.ErrorIs(t, c.Ping(), net.ErrClosed)
+
+	c.conn = nil
+	assert.ErrorIs(t, c.Ping(), ErrNotConnected)
+}
@@ -0,0 +1,277 @@
+package rrd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// rpnContext is the state an rpn expression is evaluated against for a row.
+type rpnContext struct {
+	// row is the index of the row being evaluated.
+	row int
+
+	// time and step are the time of the row and the step, in seconds.
+	time float64
+	step float64
+
+	// vars are the values of the variables the expression can reference.
+	vars map[string][]float64
+
+	// self are the values of the expression for the preceding rows.
+	self []float64
+}
+
+// value returns the value of vname at row, or NaN if row is out of range.
+func (c *rpnContext) value(vname string, row int) float64 {
+	values := c.vars[vname]
+	if row < 0 || row >= len(values) {
+		return math.NaN()
+	}
+	return values[row]
+}
+
+// rpnOp is an operator of an rpn expression, which pops in values and pushes
+// the results of f.
+type rpnOp struct {
+	in int
+	f  func(args []float64) []float64
+}
+
+func rpnUnary(f func(float64) float64) rpnOp {
+	return rpnOp{in: 1, f: func(a []float64) []float64 { return []float64{f(a[0])} }}
+}
+
+func rpnBinary(f func(a, b float64) float64) rpnOp {
+	return rpnOp{in: 2, f: func(a []float64) []float64 { return []float64{f(a[0], a[1])} }}
+}
+
+// rpnCompare returns a comparison operator, whose result is unknown if
+// either operand is.
+func rpnCompare(f func(a, b float64) bool) rpnOp {
+	return rpnBinary(func(a, b float64) float64 {
+		switch {
+		case math.IsNaN(a) || math.IsNaN(b):
+			return math.NaN()
+		case f(a, b):
+			return 1
+		}
+		return 0
+	})
+}
+
+func rpnBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// rpnOps are the supported operators, a subset of those of rrdtool.
+var rpnOps = map[string]rpnOp{
+	"+": rpnBinary(func(a, b float64) float64 { return a + b }),
+	"-": rpnBinary(func(a, b float64) float64 { return a - b }),
+	"*": rpnBinary(func(a, b float64) float64 { return a * b }),
+	"/": rpnBinary(func(a, b float64) float64 { return a / b }),
+	"%": rpnBinary(math.Mod),
+	"ADDNAN": rpnBinary(func(a, b float64) float64 {
+		switch {
+		case math.IsNaN(a):
+			return b
+		case math.IsNaN(b):
+			return a
+		}
+		return a + b
+	}),
+	"LT": rpnCompare(func(a, b float64) bool { return a < b }),
+	"LE": rpnCompare(func(a, b float64) bool { return a <= b }),
+	"GT": rpnCompare(func(a, b float64) bool { return a > b }),
+	"GE": rpnCompare(func(a, b float64) bool { return a >= b }),
+	"EQ": rpnCompare(func(a, b float64) bool { return a == b }),
+	"NE": rpnCompare(func(a, b float64) bool { return a != b }),
+	"MIN": rpnBinary(func(a, b float64) float64 {
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.NaN()
+		}
+		return math.Min(a, b)
+	}),
+	"MAX": rpnBinary(func(a, b float64) float64 {
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.NaN()
+		}
+		return math.Max(a, b)
+	}),
+	"MINNAN": rpnBinary(func(a, b float64) float64 {
+		switch {
+		case math.IsNaN(a):
+			return b
+		case math.IsNaN(b):
+			return a
+		}
+		return math.Min(a, b)
+	}),
+	"MAXNAN": rpnBinary(func(a, b float64) float64 {
+		switch {
+		case math.IsNaN(a):
+			return b
+		case math.IsNaN(b):
+			return a
+		}
+		return math.Max(a, b)
+	}),
+	"UN":      rpnUnary(func(a float64) float64 { return rpnBool(math.IsNaN(a)) }),
+	"ISINF":   rpnUnary(func(a float64) float64 { return rpnBool(math.IsInf(a, 0)) }),
+	"ABS":     rpnUnary(math.Abs),
+	"SQRT":    rpnUnary(math.Sqrt),
+	"LOG":     rpnUnary(math.Log),
+	"EXP":     rpnUnary(math.Exp),
+	"FLOOR":   rpnUnary(math.Floor),
+	"CEIL":    rpnUnary(math.Ceil),
+	"SIN":     rpnUnary(math.Sin),
+	"COS":     rpnUnary(math.Cos),
+	"ATAN":    rpnUnary(math.Atan),
+	"DEG2RAD": rpnUnary(func(a float64) float64 { return a * math.Pi / 180 }),
+	"RAD2DEG": rpnUnary(func(a float64) float64 { return a * 180 / math.Pi }),
+	"POW":     rpnBinary(math.Pow),
+	"IF": {in: 3, f: func(a []float64) []float64 {
+		if !math.IsNaN(a[0]) && a[0] != 0 {
+			return a[1:2]
+		}
+		return a[2:3]
+	}},
+	"LIMIT": {in: 3, f: func(a []float64) []float64 {
+		if math.IsNaN(a[0]) || math.IsNaN(a[1]) || math.IsNaN(a[2]) || a[0] < a[1] || a[0] > a[2] {
+			return []float64{math.NaN()}
+		}
+		return a[0:1]
+	}},
+	"DUP": {in: 1, f: func(a []float64) []float64 { return []float64{a[0], a[0]} }},
+	"POP": {in: 1, f: func([]float64) []float64 { return nil }},
+	"EXC": {in: 2, f: func(a []float64) []float64 { return []float64{a[1], a[0]} }},
+}
+
+// rpnConsts are the supported constants.
+var rpnConsts = map[string]float64{
+	"UNKN":   math.NaN(),
+	"INF":    math.Inf(1),
+	"NEGINF": math.Inf(-1),
+}
+
+// rpnToken is a parsed token of an rpn expression.
+type rpnToken struct {
+	text string
+	eval func(c *rpnContext, stack []float64) ([]float64, error)
+}
+
+// rpnExpr is a parsed rpn expression as used by CDEFs.
+type rpnExpr struct {
+	tokens []rpnToken
+}
+
+// parseRPN parses the rpn expression expr, which may reference the
+// variables vars.
+func parseRPN(expr string, vars map[string]bool) (*rpnExpr, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("%w: empty rpn expression", ErrInvalidArg)
+	}
+
+	e := &rpnExpr{}
+	for _, tok := range strings.Split(expr, ",") {
+		t, err := parseRPNToken(tok, vars)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rpn %q: %v", ErrInvalidArg, expr, err)
+		}
+		e.tokens = append(e.tokens, t)
+	}
+	return e, nil
+}
+
+// ValidateRPN checks that expr is a valid rpn expression, such as
+// "watts,1000,/", which results in a single value. It may only reference
+// vars, and only supports the operators used by CDEFs of Export.
+func ValidateRPN(expr string, vars ...string) error {
+	defined := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		defined[v] = true
+	}
+	e, err := parseRPN(expr, defined)
+	if err != nil {
+		return err
+	}
+
+	// Variables which aren't in the context evaluate as unknown.
+	if _, err := e.eval(&rpnContext{}); err != nil {
+		return fmt.Errorf("%w: rpn %q: %v", ErrInvalidArg, expr, err)
+	}
+	return nil
+}
+
+// push returns an rpn token which pushes the value returned by f.
+func push(tok string, f func(c *rpnContext) float64) rpnToken {
+	return rpnToken{text: tok, eval: func(c *rpnContext, stack []float64) ([]float64, error) {
+		return append(stack, f(c)), nil
+	}}
+}
+
+// parseRPNToken parses a single token of an rpn expression.
+func parseRPNToken(tok string, vars map[string]bool) (rpnToken, error) {
+	if op, ok := rpnOps[tok]; ok {
+		return rpnToken{text: tok, eval: func(_ *rpnContext, stack []float64) ([]float64, error) {
+			if len(stack) < op.in {
+				return nil, fmt.Errorf("%v: stack underflow", tok)
+			}
+			n := len(stack) - op.in
+			args := append([]float64(nil), stack[n:]...)
+			return append(stack[:n], op.f(args)...), nil
+		}}, nil
+	}
+	if v, ok := rpnConsts[tok]; ok {
+		return push(tok, func(*rpnContext) float64 { return v }), nil
+	}
+
+	switch {
+	case tok == "TIME":
+		return push(tok, func(c *rpnContext) float64 { return c.time }), nil
+	case tok == "STEPWIDTH":
+		return push(tok, func(c *rpnContext) float64 { return c.step }), nil
+	case tok == "COUNT":
+		return push(tok, func(c *rpnContext) float64 { return float64(c.row + 1) }), nil
+	case tok == "PREV":
+		return push(tok, func(c *rpnContext) float64 {
+			if c.row == 0 || c.row > len(c.self) {
+				return math.NaN()
+			}
+			return c.self[c.row-1]
+		}), nil
+	case strings.HasPrefix(tok, "PREV(") && strings.HasSuffix(tok, ")"):
+		vname := tok[5 : len(tok)-1]
+		if !vars[vname] {
+			return rpnToken{}, fmt.Errorf("unknown variable %q", vname)
+		}
+		return push(tok, func(c *rpnContext) float64 { return c.value(vname, c.row-1) }), nil
+	case vars[tok]:
+		return push(tok, func(c *rpnContext) float64 { return c.value(tok, c.row) }), nil
+	}
+
+	v, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return rpnToken{}, fmt.Errorf("unknown token %q", tok)
+	}
+	return push(tok, func(*rpnContext) float64 { return v }), nil
+}
+
+// eval evaluates e for the row of c.
+func (e *rpnExpr) eval(c *rpnContext) (float64, error) {
+	stack := make([]float64, 0, 8)
+	for _, t := range e.tokens {
+		var err error
+		if stack, err = t.eval(c, stack); err != nil {
+			return 0, err
+		}
+	}
+	if len(stack) != 1 {
+		return 0, fmt.Errorf("rpn left %v values on the stack", len(stack))
+	}
+	return stack[0], nil
+}
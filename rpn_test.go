@@ -0,0 +1,102 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRPN(t *testing.T) {
+	nan := math.NaN()
+	vars := map[string][]float64{
+		"a": {1, 2, 3},
+		"b": {4, nan, 6},
+	}
+	defined := map[string]bool{"a": true, "b": true}
+
+	tests := []struct {
+		expr     string
+		expected []float64
+	}{
+		{"a,b,+", []float64{5, nan, 9}},
+		{"a,b,ADDNAN", []float64{5, 2, 9}},
+		{"b,a,-,2,/", []float64{1.5, nan, 1.5}},
+		{"a,2,GT,a,0,IF", []float64{0, 0, 3}},
+		{"b,UN,0,b,IF", []float64{4, 0, 6}},
+		{"a,b,MAX", []float64{4, nan, 6}},
+		{"a,b,MAXNAN", []float64{4, 2, 6}},
+		{"a,2,3,LIMIT", []float64{nan, 2, 3}},
+		{"a,DUP,*", []float64{1, 4, 9}},
+		{"a,b,EXC,POP", []float64{4, nan, 6}},
+		{"a,PREV(a),-", []float64{nan, 1, 1}},
+		{"PREV,UN,a,PREV,a,+,IF", []float64{1, 3, 6}},
+		{"COUNT,TIME,STEPWIDTH,*,+", []float64{61, 62, 63}},
+		{"UNKN,INF,NEGINF,+,+", []float64{nan, nan, nan}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			e, err := parseRPN(tc.expr, defined)
+			if !assert.NoError(t, err) {
+				return
+			}
+			res := make([]float64, len(tc.expected))
+			c := &rpnContext{time: 1, step: 60, vars: vars, self: res}
+			for i := range res {
+				c.row = i
+				res[i], err = e.eval(c)
+				if !assert.NoError(t, err) {
+					return
+				}
+			}
+			assertNaNEqual(t, tc.expected, res)
+		})
+	}
+}
+
+func TestRPNInvalid(t *testing.T) {
+	defined := map[string]bool{"a": true}
+	for _, expr := range []string{"", "a,c,+", "a,,+", "PREV(c)", "a,FOO"} {
+		_, err := parseRPN(expr, defined)
+		assert.ErrorIs(t, err, ErrInvalidArg, expr)
+	}
+
+	for _, expr := range []string{"+", "a,a", "a,IF"} {
+		e, err := parseRPN(expr, defined)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+		_, err = e.eval(&rpnContext{vars: map[string][]float64{"a": {1}}})
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestValidateRPN(t *testing.T) {
+	tests := []struct {
+		expr string
+		vars []string
+		ok   bool
+	}{
+		{"a,1000,/", []string{"a"}, true},
+		{"a,b,+,2,/", []string{"a", "b"}, true},
+		{"a,UN,0,a,IF", []string{"a"}, true},
+		{"a,SIN,a,COS,POW", []string{"a"}, true},
+		{"a,b,+", []string{"a"}, false},
+		{"a,FOO", []string{"a"}, false},
+		{"a,1", []string{"a"}, false},
+		{"a,+", []string{"a"}, false},
+		{"", nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			err := ValidateRPN(tc.expr, tc.vars...)
+			if tc.ok {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidArg)
+		})
+	}
+}
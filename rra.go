@@ -2,16 +2,39 @@ package rrd
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 )
 
-// Round Robin Algorithms
+// ConsolidationFunc represents a RRA consolidation function.
+type ConsolidationFunc string
+
+// Consolidation Functions
 const (
-	Average = "AVERAGE"
-	Min     = "MIN"
-	Max     = "MAX"
-	Last    = "LAST"
+	Average ConsolidationFunc = "AVERAGE"
+	Min     ConsolidationFunc = "MIN"
+	Max     ConsolidationFunc = "MAX"
+	Last    ConsolidationFunc = "LAST"
+)
 
+// Valid returns true if cf, ignoring case, is a known consolidation function, false otherwise.
+func (cf ConsolidationFunc) Valid() bool {
+	_, err := cf.normalize()
+	return err == nil
+}
+
+// normalize returns the canonical upper case form of cf or ErrInvalidCF if it's unknown.
+func (cf ConsolidationFunc) normalize() (ConsolidationFunc, error) {
+	switch v := ConsolidationFunc(strings.ToUpper(string(cf))); v {
+	case Average, Min, Max, Last:
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidCF, string(cf))
+}
+
+// Round Robin Algorithms
+const (
 	HoltWintersPredict          = "HWPREDICT"
 	MultipliedHoltWinterPredict = "MHWPREDICT"
 	Seasonal                    = "SEASONAL"
@@ -28,6 +51,28 @@ func NewRRA(val string) RRA {
 	return RRA(val)
 }
 
+// normalize returns r with its consolidation function in canonical form or
+// ErrInvalidCF if it's unknown. Raw RRAs which don't start with RRA: and
+// Holt-Winters RRAs are returned unchanged.
+func (r RRA) normalize() (RRA, error) {
+	parts := strings.SplitN(string(r), ":", 3)
+	if len(parts) < 2 || parts[0] != "RRA" {
+		return r, nil
+	}
+
+	switch strings.ToUpper(parts[1]) {
+	case HoltWintersPredict, MultipliedHoltWinterPredict, Seasonal, DevSeasonal, DevPredict, Failures:
+		return r, nil
+	}
+
+	cf, err := ConsolidationFunc(parts[1]).normalize()
+	if err != nil {
+		return "", err
+	}
+	parts[1] = string(cf)
+	return RRA(strings.Join(parts, ":")), nil
+}
+
 func newRRA(rra string, vals ...interface{}) RRA {
 	parts := make([]string, len(vals)+2)
 	parts[0] = "RRA"
@@ -40,50 +85,179 @@ func newRRA(rra string, vals ...interface{}) RRA {
 
 // NewAverage returns a new AVERAGE RRA.
 func NewAverage(xff float32, steps, rows int) RRA {
-	return newRRA(Average, xff, steps, rows)
+	return newRRA(string(Average), xff, steps, rows)
 }
 
 // NewMin returns a new MIN RRA.
 func NewMin(xff float32, steps, rows int) RRA {
-	return newRRA(Min, xff, steps, rows)
+	return newRRA(string(Min), xff, steps, rows)
 }
 
 // NewMax returns a new AVERAGE RRA.
 func NewMax(xff float32, steps, rows int) RRA {
-	return newRRA(Max, xff, steps, rows)
+	return newRRA(string(Max), xff, steps, rows)
 }
 
 // NewLast returns a new AVERAGE RRA.
 func NewLast(xff float32, steps, rows int) RRA {
-	return newRRA(Last, xff, steps, rows)
+	return newRRA(string(Last), xff, steps, rows)
 }
 
-// NewHWPredict returns a new HWPREDICT RRA.
+// NewHWPredict returns a new HWPREDICT RRA, whose seasonal coefficients are
+// stored in the SEASONAL RRA at the 1 based index idx. If idx is zero it's
+// omitted and rrdtool creates the dependent archives.
 func NewHWPredict(rows int, alpha, beta float32, period int, idx int) RRA {
-	return newRRA(HoltWintersPredict, rows, alpha, beta, period, idx)
+	return newRRA(HoltWintersPredict, hwVals(idx, rows, alpha, beta, period)...)
 }
 
-// NewMHWPredict returns a new HWPREDICT RRA.
+// NewMHWPredict returns a new MHWPREDICT RRA, the multiplicative version of
+// HWPREDICT.
 func NewMHWPredict(rows int, alpha, beta float32, period int, idx int) RRA {
-	return newRRA(MultipliedHoltWinterPredict, rows, alpha, beta, period, idx)
+	return newRRA(MultipliedHoltWinterPredict, hwVals(idx, rows, alpha, beta, period)...)
 }
 
-// NewSeasonal returns a new HWPREDICT RRA.
+// NewSeasonal returns a new SEASONAL RRA for the HWPREDICT RRA at idx.
 func NewSeasonal(period int, gamma float32, idx int, window float32) RRA {
 	return newRRA(Seasonal, period, gamma, idx, fmt.Sprintf("smoothing-window=%v", window))
 }
 
-// NewDevSeasonal returns a new HWPREDICT RRA.
+// NewDevSeasonal returns a new DEVSEASONAL RRA for the HWPREDICT RRA at idx.
 func NewDevSeasonal(period int, gamma float32, idx int, window float32) RRA {
 	return newRRA(DevSeasonal, period, gamma, idx, fmt.Sprintf("smoothing-window=%v", window))
 }
 
-// NewDevPredict returns a new HWPREDICT RRA.
+// NewDevPredict returns a new DEVPREDICT RRA for the DEVSEASONAL RRA at idx.
 func NewDevPredict(rows, idx int) RRA {
 	return newRRA(DevPredict, rows, idx)
 }
 
-// NewFailures returns a new HWPREDICT RRA.
+// NewFailures returns a new FAILURES RRA for the DEVSEASONAL RRA at idx,
+// recording a failure when at least threshold of the last window values
+// are outside the confidence bounds.
 func NewFailures(rows, threshold, window, idx int) RRA {
 	return newRRA(Failures, rows, threshold, window, idx)
 }
+
+// hwVals returns vals with idx appended if it isn't zero.
+func hwVals(idx int, vals ...interface{}) []interface{} {
+	if idx != 0 {
+		vals = append(vals, idx)
+	}
+	return vals
+}
+
+// hwDeps are the types of the archives each Holt-Winters archive type
+// references by index.
+var hwDeps = map[string][]string{
+	HoltWintersPredict:          {Seasonal},
+	MultipliedHoltWinterPredict: {Seasonal},
+	Seasonal:                    {HoltWintersPredict, MultipliedHoltWinterPredict},
+	DevSeasonal:                 {HoltWintersPredict, MultipliedHoltWinterPredict},
+	DevPredict:                  {DevSeasonal},
+	Failures:                    {DevSeasonal},
+}
+
+// hwRRA is a parsed Holt-Winters archive.
+type hwRRA struct {
+	typ string
+
+	// ints and floats are the integer and coefficient parameters, in order.
+	ints   []int64
+	floats []float64
+
+	// idx is the 1 based index of the referenced archive, 0 if omitted.
+	idx int
+}
+
+// parseHWRRA parses r, returning false if it isn't a Holt-Winters archive.
+func parseHWRRA(r RRA) (*hwRRA, bool, error) {
+	parts := strings.Split(string(r), ":")
+	if len(parts) < 2 || parts[0] != "RRA" {
+		return nil, false, nil
+	}
+	h := &hwRRA{typ: strings.ToUpper(parts[1])}
+	if _, ok := hwDeps[h.typ]; !ok {
+		return nil, false, nil
+	}
+
+	// The layout of the parameters, i for integers and f for coefficients.
+	var layout string
+	switch h.typ {
+	case HoltWintersPredict, MultipliedHoltWinterPredict:
+		layout = "iffi"
+	case Seasonal, DevSeasonal:
+		layout = "if"
+	case DevPredict:
+		layout = "i"
+	case Failures:
+		layout = "iii"
+	}
+
+	params := parts[2:]
+	if h.typ == Seasonal || h.typ == DevSeasonal {
+		if n := len(params); n > 0 && strings.HasPrefix(params[n-1], "smoothing-window=") {
+			w, err := strconv.ParseFloat(strings.TrimPrefix(params[n-1], "smoothing-window="), 64)
+			if err != nil || w < 0 || w > 1 {
+				return nil, true, fmt.Errorf("%w: archive %q smoothing window", ErrInvalidArg, r)
+			}
+			params = params[:n-1]
+		}
+	}
+	if len(params) != len(layout) && len(params) != len(layout)+1 {
+		return nil, true, fmt.Errorf("%w: archive %q", ErrInvalidArg, r)
+	}
+
+	for i, p := range params {
+		if i == len(layout) || layout[i] == 'i' {
+			v, err := strconv.ParseInt(p, 10, 64)
+			if err != nil || v < 1 {
+				return nil, true, fmt.Errorf("%w: archive %q parameter %v", ErrInvalidArg, r, i+1)
+			}
+			if i == len(layout) {
+				h.idx = int(v)
+			} else {
+				h.ints = append(h.ints, v)
+			}
+			continue
+		}
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v <= 0 || v >= 1 {
+			return nil, true, fmt.Errorf("%w: archive %q parameter %v", ErrInvalidArg, r, i+1)
+		}
+		h.floats = append(h.floats, v)
+	}
+
+	if h.typ == Failures && (h.ints[1] > h.ints[2] || h.ints[2] > 28) {
+		return nil, true, fmt.Errorf("%w: archive %q threshold %v window %v", ErrInvalidArg, r, h.ints[1], h.ints[2])
+	}
+	return h, true, nil
+}
+
+// validateHW checks the parameters of the Holt-Winters archives in rras
+// and that the archives they reference are of the expected type.
+func validateHW(rras []RRA) error {
+	hws := make([]*hwRRA, len(rras))
+	for i, r := range rras {
+		h, ok, err := parseHWRRA(r)
+		if err != nil {
+			return err
+		}
+		if ok {
+			hws[i] = h
+		}
+	}
+
+	for i, h := range hws {
+		if h == nil || h.idx == 0 {
+			continue
+		}
+		var ref *hwRRA
+		if h.idx <= len(hws) && h.idx != i+1 {
+			ref = hws[h.idx-1]
+		}
+		if ref == nil || !slices.Contains(hwDeps[h.typ], ref.typ) {
+			return fmt.Errorf("%w: archive %q references archive %v which isn't a %v", ErrInvalidArg, rras[i], h.idx, strings.Join(hwDeps[h.typ], " or "))
+		}
+	}
+	return nil
+}
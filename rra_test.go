@@ -1,6 +1,7 @@
 package rrd
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,6 +20,7 @@ func TestRRA(t *testing.T) {
 		{"max", NewMax(0.5, 60, 129600), "RRA:MAX:0.5:60:129600"},
 		{"last", NewLast(0.5, 60, 129600), "RRA:LAST:0.5:60:129600"},
 		{"hwpredict", NewHWPredict(10, 0.5, 0.5, 50, 1), "RRA:HWPREDICT:10:0.5:0.5:50:1"},
+		{"hwpredict-implicit", NewHWPredict(10, 0.5, 0.5, 50, 0), "RRA:HWPREDICT:10:0.5:0.5:50"},
 		{"mhwpredict", NewMHWPredict(10, 0.5, 0.5, 50, 1), "RRA:MHWPREDICT:10:0.5:0.5:50:1"},
 		{"seasonal", NewSeasonal(10, 0.5, 1, 0.3), "RRA:SEASONAL:10:0.5:1:smoothing-window=0.3"},
 		{"devseasonal", NewDevSeasonal(10, 0.5, 1, 0.3), "RRA:DEVSEASONAL:10:0.5:1:smoothing-window=0.3"},
@@ -32,3 +34,59 @@ func TestRRA(t *testing.T) {
 		})
 	}
 }
+
+func TestConsolidationFunc(t *testing.T) {
+	tests := []struct {
+		cf     ConsolidationFunc
+		expect ConsolidationFunc
+	}{
+		{Average, Average},
+		{"average", Average},
+		{"Max", Max},
+		{"min", Min},
+		{"LAST", Last},
+		{"AVG", ""},
+		{"", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.cf), func(t *testing.T) {
+			cf, err := tc.cf.normalize()
+			if tc.expect == "" {
+				assert.False(t, tc.cf.Valid())
+				assert.True(t, errors.Is(err, ErrInvalidCF))
+				return
+			}
+			assert.True(t, tc.cf.Valid())
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expect, cf)
+			}
+		})
+	}
+}
+
+func TestRRANormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		rra    RRA
+		expect RRA
+	}{
+		{"canonical", NewAverage(0.5, 1, 100), "RRA:AVERAGE:0.5:1:100"},
+		{"lower", NewRRA("RRA:max:0.5:1:100"), "RRA:MAX:0.5:1:100"},
+		{"hwpredict", NewHWPredict(10, 0.5, 0.5, 50, 1), "RRA:HWPREDICT:10:0.5:0.5:50:1"},
+		{"invalid", NewRRA("RRA:AVG:0.5:1:100"), ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := tc.rra.normalize()
+			if tc.expect == "" {
+				assert.True(t, errors.Is(err, ErrInvalidCF))
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expect, r)
+			}
+		})
+	}
+}
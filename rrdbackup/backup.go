@@ -0,0 +1,329 @@
+// Package rrdbackup backs up RRDs from a rrdcached server to a portable
+// archive and restores them to another, which fills the gap left by
+// rrdcached not supporting rrdtool dump and restore.
+//
+// An archive is a tar file with a JSON entry per RRD, named after the RRD
+// with a .json suffix, holding its info and the rows of each of its
+// archives. Restoring recreates the RRD from its info and replays the rows,
+// so it's only as lossless as the replay: the consolidated rows of the
+// archives are restored rather than their exact state.
+package rrdbackup
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// Version is the version of the archive format written by Backup.
+const Version = 1
+
+// maxUpdateSamples is the maximum number of samples sent in a single update.
+const maxUpdateSamples = 500
+
+// File is the backup of a single RRD.
+type File struct {
+	// Version is the version of the archive format.
+	Version int `json:"version"`
+
+	// Filename is the name of the RRD on the server it was backed up from.
+	Filename string `json:"filename"`
+
+	// Info is the info of the RRD.
+	Info *rrd.RRDInfo `json:"info"`
+
+	// Archives are the rows of each of the standard archives of the RRD.
+	Archives []Archive `json:"archives"`
+}
+
+// Archive is the backup of the rows of a round robin archive.
+type Archive struct {
+	// RRA is the index of the archive.
+	RRA int `json:"rra"`
+
+	// CF is the consolidation function of the archive.
+	CF rrd.ConsolidationFunc `json:"cf"`
+
+	// Rows are the rows of the archive. They may be at a finer resolution
+	// than the archive if the server selected a finer archive covering
+	// the same range.
+	Rows *rrd.FetchColumns `json:"rows"`
+}
+
+// Backup writes a tar archive of the RRDs under prefix, listed
+// recursively with opts, to w and returns the number of RRDs backed up.
+func Backup(ctx context.Context, c *rrd.Client, w io.Writer, prefix string, opts ...rrd.ListOption) (int, error) {
+	// Walk holds the connection while listing, so RRDs are backed up once
+	// it's complete.
+	var files []string
+	if err := c.Walk(ctx, prefix, func(filename string) error {
+		files = append(files, filename)
+		return nil
+	}, opts...); err != nil {
+		return 0, fmt.Errorf("list: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	for i, filename := range files {
+		f, err := BackupFile(ctx, c, filename)
+		if err != nil {
+			return i, fmt.Errorf("%v: %w", filename, err)
+		}
+		if err := writeFile(tw, f); err != nil {
+			return i, fmt.Errorf("%v: %w", filename, err)
+		}
+	}
+	return len(files), tw.Close()
+}
+
+// BackupFile returns the backup of the RRD filename.
+// Holt-Winters archives are skipped as their rows can't be restored.
+func BackupFile(ctx context.Context, c *rrd.Client, filename string) (*File, error) {
+	info, err := c.InfoStructWithContext(ctx, filename)
+	if err != nil {
+		return nil, fmt.Errorf("info: %w", err)
+	}
+
+	f := &File{Version: Version, Filename: filename, Info: info}
+	for i, a := range info.RRA {
+		cf := rrd.ConsolidationFunc(a.CF)
+		if !standardCF(cf) {
+			continue
+		}
+		span := info.Step * time.Duration(a.PDPPerRow*a.Rows)
+		r, err := c.FetchRangeWithContext(ctx, filename, cf, info.LastUpdate.Add(-span), info.LastUpdate)
+		if err != nil {
+			return nil, fmt.Errorf("fetch rra %v: %w", i, err)
+		}
+		f.Archives = append(f.Archives, Archive{RRA: i, CF: cf, Rows: r.Columns()})
+	}
+	return f, nil
+}
+
+// standardCF returns true if cf is a consolidation function whose rows can
+// be fetched and replayed.
+func standardCF(cf rrd.ConsolidationFunc) bool {
+	switch cf {
+	case rrd.Average, rrd.Min, rrd.Max, rrd.Last:
+		return true
+	}
+	return false
+}
+
+// writeFile writes f to tw.
+func writeFile(tw *tar.Writer, f *File) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    strings.TrimPrefix(f.Filename, "/") + ".json",
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: f.Info.LastUpdate,
+	}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// RestoreOption is an option for Restore.
+type RestoreOption func(*restoreConfig) error
+
+type restoreConfig struct {
+	cf          rrd.ConsolidationFunc
+	noOverwrite bool
+}
+
+// newRestoreConfig returns the restore configuration with opts applied.
+func newRestoreConfig(opts []RestoreOption) (*restoreConfig, error) {
+	cfg := &restoreConfig{cf: rrd.Average}
+	for _, o := range opts {
+		if o == nil {
+			return nil, rrd.ErrNilOption
+		}
+		if err := o(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// RestoreCF sets the consolidation function of the archives which are
+// replayed, which defaults to rrd.Average. RRDs without archives of cf
+// use that of their first archive.
+func RestoreCF(cf rrd.ConsolidationFunc) RestoreOption {
+	return func(c *restoreConfig) error {
+		if !standardCF(cf) {
+			return fmt.Errorf("%w: %q", rrd.ErrInvalidCF, cf)
+		}
+		c.cf = cf
+		return nil
+	}
+}
+
+// NoOverwrite causes Restore to fail for RRDs which already exist, instead
+// of replacing them.
+func NoOverwrite() RestoreOption {
+	return func(c *restoreConfig) error {
+		c.noOverwrite = true
+		return nil
+	}
+}
+
+// Restore restores the RRDs of the tar archive read from r, as written by
+// Backup, to c and returns the number of RRDs restored. A RRD which fails
+// to restore doesn't prevent the others from being restored, the errors
+// of all those which failed are returned.
+func Restore(ctx context.Context, c *rrd.Client, r io.Reader, opts ...RestoreOption) (int, error) {
+	cfg, err := newRestoreConfig(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	tr := tar.NewReader(r)
+	var n int
+	var errs []error
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, errors.Join(append(errs, err)...)
+		}
+		if hdr.Typeflag != tar.TypeReg || !strings.HasSuffix(hdr.Name, ".json") {
+			continue
+		}
+
+		f := &File{}
+		if err := json.NewDecoder(tr).Decode(f); err != nil {
+			return n, errors.Join(append(errs, fmt.Errorf("%v: %w", hdr.Name, err))...)
+		}
+		if err := restoreFile(ctx, c, f, cfg); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", f.Filename, err))
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// RestoreFile recreates the RRD of f on c and replays its rows.
+func RestoreFile(ctx context.Context, c *rrd.Client, f *File, opts ...RestoreOption) error {
+	cfg, err := newRestoreConfig(opts)
+	if err != nil {
+		return err
+	}
+	return restoreFile(ctx, c, f, cfg)
+}
+
+// restoreFile recreates the RRD of f on c and replays its rows.
+//
+// The rows of each period are replayed from the highest resolution archive
+// which covers it, so the heartbeat of the data sources must exceed the
+// resolution of the coarser archives for their older rows to be known.
+func restoreFile(ctx context.Context, c *rrd.Client, f *File, cfg *restoreConfig) error {
+	switch {
+	case f.Version != Version:
+		return fmt.Errorf("%w: version %v", rrd.ErrNotSupported, f.Version)
+	case f.Filename == "" || f.Info == nil:
+		return fmt.Errorf("%w: incomplete backup", rrd.ErrInvalidArg)
+	}
+
+	def, err := f.Info.CreateRRD()
+	if err != nil {
+		return fmt.Errorf("clone schema: %w", err)
+	}
+
+	rows := f.rows(cfg.cf)
+	start := f.Info.LastUpdate
+	if len(rows.Rows) > 0 {
+		start = rows.Rows[0].Time.Add(-rows.Step)
+	}
+	step := int64(f.Info.Step / time.Second)
+	if step > 0 {
+		s := start.Unix()
+		start = time.Unix(s-s%step, 0)
+	}
+	def.WithStart(start)
+	if cfg.noOverwrite {
+		def.WithNoOverwrite()
+	}
+	if err := c.CreateFromWithContext(ctx, f.Filename, def); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	samples, err := rows.Samples(f.Info, start, make(map[string]float64))
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(samples); i += maxUpdateSamples {
+		chunk := samples[i:min(i+maxUpdateSamples, len(samples))]
+		if err := c.UpdateWithContext(ctx, f.Filename, chunk...); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+	}
+	return nil
+}
+
+// archiveRows returns the rows of the archives of f with cf.
+func (f *File) archiveRows(cf rrd.ConsolidationFunc) []*rrd.FetchResult {
+	var archives []*rrd.FetchResult
+	for _, a := range f.Archives {
+		if a.CF == cf && a.Rows != nil {
+			archives = append(archives, a.Rows.Result())
+		}
+	}
+	return archives
+}
+
+// rows returns the rows of the archives of f with cf, or that of its first
+// archive with rows if none have it, merged so each period is covered by
+// the highest resolution archive which has it.
+func (f *File) rows(cf rrd.ConsolidationFunc) *rrd.FetchResult {
+	archives := f.archiveRows(cf)
+	if len(archives) == 0 {
+		for _, a := range f.Archives {
+			if a.Rows != nil {
+				archives = f.archiveRows(a.CF)
+				break
+			}
+		}
+	}
+
+	res := &rrd.FetchResult{Step: f.Info.Step}
+	if len(archives) == 0 {
+		return res
+	}
+	sort.SliceStable(archives, func(i, j int) bool { return archives[i].Step < archives[j].Step })
+	res.Step, res.Names = archives[0].Step, archives[0].Names
+
+	var merged []rrd.FetchResultRow
+	var cut time.Time
+	for _, a := range archives {
+		var rows []rrd.FetchResultRow
+		for _, row := range a.Rows {
+			if cut.IsZero() || !row.Time.After(cut) {
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		merged = append(rows, merged...)
+		cut = rows[0].Time.Add(-a.Step)
+		res.Step = a.Step
+	}
+	res.Rows = merged
+	return res
+}
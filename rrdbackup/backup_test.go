@@ -0,0 +1,214 @@
+package rrdbackup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+// newTestClient returns a client connected to a new rrdtest.Server.
+func newTestClient(t *testing.T) (*rrd.Client, *rrdtest.Server) {
+	t.Helper()
+
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+	})
+	s.HandleFunc("update", func(_ string, args []string) []string {
+		return []string{fmt.Sprintf("0 errors, enqueued %v value(s).", len(args)-1)}
+	})
+
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+	return c, s
+}
+
+// newTestSource returns a client connected to a source server on which
+// a.rrd has a GAUGE and a COUNTER data source with a minute step, and
+// archives with one and two minute resolutions.
+func newTestSource(t *testing.T) *rrd.Client {
+	c, s := newTestClient(t)
+	s.Handle("list", "1 RRDs", "/a.rrd")
+	s.Handle("info",
+		"21 Info for a.rrd follows",
+		"filename 2 a.rrd",
+		"step 1 60",
+		"last_update 1 1000260",
+		"ds[watts].index 1 0",
+		"ds[watts].type 2 GAUGE",
+		"ds[watts].minimal_heartbeat 1 120",
+		"ds[watts].min 0 nan",
+		"ds[watts].max 0 nan",
+		"ds[hits].index 1 1",
+		"ds[hits].type 2 COUNTER",
+		"ds[hits].minimal_heartbeat 1 120",
+		"ds[hits].min 0 0",
+		"ds[hits].max 0 nan",
+		"rra[0].cf 2 AVERAGE",
+		"rra[0].rows 1 4",
+		"rra[0].pdp_per_row 1 1",
+		"rra[0].xff 0 0.5",
+		"rra[1].cf 2 AVERAGE",
+		"rra[1].rows 1 4",
+		"rra[1].pdp_per_row 1 2",
+		"rra[1].xff 0 0.5",
+	)
+	s.HandleFunc("fetch", func(_ string, args []string) []string {
+		if len(args) > 2 && args[2] == "1000020" {
+			return []string{
+				"10 Success",
+				"FlushVersion: 1",
+				"Start: 1000020",
+				"End: 1000260",
+				"Step: 60",
+				"DSCount: 2",
+				"DSName: watts hits",
+				"1000080: 1 0.5",
+				"1000140: 2 1",
+				"1000200: nan nan",
+				"1000260: 4 2",
+			}
+		}
+		return []string{
+			"10 Success",
+			"FlushVersion: 1",
+			"Start: 999780",
+			"End: 1000260",
+			"Step: 120",
+			"DSCount: 2",
+			"DSName: watts hits",
+			"999900: 1 1",
+			"1000020: 3 1",
+			"1000140: 1.5 0.75",
+			"1000260: 4 2",
+		}
+	})
+	return c
+}
+
+func TestBackupRestore(t *testing.T) {
+	src := newTestSource(t)
+	var buf bytes.Buffer
+	n, err := Backup(context.Background(), src, &buf, "/")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, n)
+
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	hdr, err := tr.Next()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "a.rrd.json", hdr.Name)
+	f := &File{}
+	if !assert.NoError(t, json.NewDecoder(tr).Decode(f)) {
+		return
+	}
+	assert.Equal(t, Version, f.Version)
+	assert.Equal(t, "/a.rrd", f.Filename)
+	if assert.Len(t, f.Archives, 2) {
+		assert.Equal(t, time.Minute*2, f.Archives[1].Rows.Step)
+	}
+
+	tests := []struct {
+		name   string
+		opts   []RestoreOption
+		create string
+	}{
+		{
+			name:   "overwrite",
+			create: "create /a.rrd -s 60 -b 999780 DS:watts:GAUGE:120:U:U DS:hits:COUNTER:120:0:U RRA:AVERAGE:0.5:1:4 RRA:AVERAGE:0.5:2:4",
+		},
+		{
+			name:   "no-overwrite",
+			opts:   []RestoreOption{NoOverwrite()},
+			create: "create /a.rrd -s 60 -b 999780 -O DS:watts:GAUGE:120:U:U DS:hits:COUNTER:120:0:U RRA:AVERAGE:0.5:1:4 RRA:AVERAGE:0.5:2:4",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dst, ds := newTestClient(t)
+			ds.Handle("create", "0 RRD created OK")
+
+			n, err := Restore(context.Background(), dst, bytes.NewReader(buf.Bytes()), tc.opts...)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, 1, n)
+			assert.Equal(t, []string{
+				tc.create,
+				"update /a.rrd 999900:1:120 1000020:3:240 1000080:1:270 1000140:2:330 1000200:U:U 1000260:4:450",
+			}, ds.Received())
+		})
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	dst, ds := newTestClient(t)
+	ds.Handle("create", "-1 RRD Error: opening '/a.rrd': File exists")
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	info := &rrd.RRDInfo{Step: time.Minute, DS: map[string]rrd.DSInfo{
+		"watts": {Name: "watts", Type: rrd.Gauge, MinimalHeartbeat: time.Minute},
+	}, RRA: []rrd.RRAInfo{{CF: "AVERAGE", Rows: 10, PDPPerRow: 1, XFF: 0.5}}}
+	for _, f := range []*File{
+		{Version: Version + 1, Filename: "/new.rrd", Info: info},
+		{Version: Version, Filename: "/a.rrd", Info: info},
+	} {
+		assert.NoError(t, writeFile(tw, f))
+	}
+	assert.NoError(t, tw.Close())
+
+	n, err := Restore(context.Background(), dst, &buf, NoOverwrite())
+	assert.Equal(t, 0, n)
+	assert.ErrorIs(t, err, rrd.ErrNotSupported)
+	assert.True(t, rrd.IsExist(err))
+
+	_, err = Restore(context.Background(), dst, &buf, nil)
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+
+	_, err = Restore(context.Background(), dst, &buf, RestoreCF("HWPREDICT"))
+	assert.ErrorIs(t, err, rrd.ErrInvalidCF)
+}
+
+func TestFileRows(t *testing.T) {
+	info := &rrd.RRDInfo{Step: time.Minute}
+	start := time.Unix(1500000000, 0)
+	cols := &rrd.FetchColumns{Step: time.Minute, Names: []string{"watts"}, First: start, Columns: [][]float64{{1, 2}}}
+
+	tests := map[string]struct {
+		archives []Archive
+		rows     int
+	}{
+		"none":         {nil, 0},
+		"no-rows":      {[]Archive{{CF: rrd.Max}}, 0},
+		"no-rows-many": {[]Archive{{CF: rrd.Max}, {CF: rrd.Max, RRA: 1}}, 0},
+		"fallback":     {[]Archive{{CF: rrd.Max}, {CF: rrd.Min, RRA: 1, Rows: cols}}, 2},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := &File{Info: info, Archives: tc.archives}
+			res := f.rows(rrd.Average)
+			assert.Len(t, res.Rows, tc.rows)
+		})
+	}
+}
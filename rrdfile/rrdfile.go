@@ -0,0 +1,524 @@
+// Package rrdfile reads and writes RRD files in the native rrdtool binary
+// format directly, without cgo, rrdtool or rrdcached, so tools colocated with
+// the files can read them when the daemon is down, and bulk imports and test
+// fixtures can be created without it.
+//
+// The native format is the in-memory layout of rrdtool's structures, so
+// depends on the byte order, word size and alignment of the platform which
+// created the file. Files created on platforms with 32 or 64 bit longs and
+// either byte order are supported, the layout being detected from the float
+// cookie of the header.
+//
+// Data written to rrdcached but not yet flushed isn't visible in the file,
+// so files should be flushed before they're read if the daemon is running,
+// and files mustn't be updated while the daemon has updates pending for them.
+package rrdfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+const (
+	// cookie is the magic at the start of RRD files.
+	cookie = "RRD\x00"
+
+	// floatCookie is the value used to detect the float format and
+	// alignment of the file.
+	floatCookie = 8.642135e130
+
+	nameSize   = 20
+	lastDSSize = 30
+	univalSize = 8
+	parCount   = 10
+	parSize    = parCount * univalSize
+	versionLen = 5
+
+	// maxCount is the maximum number of data sources or archives accepted,
+	// to guard against allocating memory for corrupt files.
+	maxCount = 1 << 16
+)
+
+// ErrInvalidFile is returned when a file isn't a valid RRD file.
+var ErrInvalidFile = errors.New("invalid rrd file")
+
+// layout is the platform dependent layout of a file.
+type layout struct {
+	order binary.ByteOrder
+
+	// long is the size of unsigned long and time_t.
+	long int
+
+	// align is the alignment of doubles.
+	align int
+}
+
+// alignUp returns off rounded up to a multiple of n.
+func alignUp(off, n int) int {
+	return (off + n - 1) / n * n
+}
+
+// univalAlign returns the alignment of the unival union of longs and doubles.
+func (l layout) univalAlign() int {
+	return max(l.long, l.align)
+}
+
+// headSize returns the size of stat_head_t.
+func (l layout) headSize() int {
+	off := alignUp(len(cookie)+versionLen, l.align) + 8 + 3*l.long
+	return alignUp(alignUp(off, l.univalAlign())+parSize, l.univalAlign())
+}
+
+// dsDefSize returns the size of ds_def_t.
+func (l layout) dsDefSize() int {
+	return alignUp(2*nameSize, l.univalAlign()) + parSize
+}
+
+// rraDefOffsets returns the offsets of the row count and par fields of
+// rra_def_t and its size.
+func (l layout) rraDefOffsets() (rows, par, size int) {
+	rows = alignUp(nameSize, l.long)
+	par = alignUp(rows+2*l.long, l.univalAlign())
+	return rows, par, alignUp(par+parSize, l.univalAlign())
+}
+
+// liveHeadSize returns the size of the live header, which only has
+// microseconds since version 3.
+func (l layout) liveHeadSize(version int) int {
+	if version < 3 {
+		return l.long
+	}
+	return 2 * l.long
+}
+
+// pdpPrepSize returns the size of pdp_prep_t.
+func (l layout) pdpPrepSize() int {
+	return alignUp(lastDSSize, l.univalAlign()) + parSize
+}
+
+// sections are the offsets of the sections of a header, and its size.
+type sections struct {
+	ds, rra, live, pdp, cdp, ptr, size int
+}
+
+// sections returns the sections of the header of a file with version,
+// nds data sources and nrra archives.
+func (l layout) sections(version, nds, nrra int) sections {
+	_, _, rraSize := l.rraDefOffsets()
+	var s sections
+	s.ds = l.headSize()
+	s.rra = s.ds + nds*l.dsDefSize()
+	s.live = s.rra + nrra*rraSize
+	s.pdp = s.live + l.liveHeadSize(version)
+	s.cdp = s.pdp + nds*l.pdpPrepSize()
+	s.ptr = s.cdp + nrra*nds*parSize
+	s.size = s.ptr + nrra*l.long
+	return s
+}
+
+// DS is a data source of a RRD file.
+type DS struct {
+	Name             string
+	Type             string
+	MinimalHeartbeat time.Duration
+	Min              float64
+	Max              float64
+
+	// LastDS is the last value the data source was updated with.
+	LastDS string
+
+	// Value is the sum of the known values of the current primary data
+	// point and UnknownSec the seconds of it which are unknown.
+	Value      float64
+	UnknownSec int64
+}
+
+// CDPPrep is the state of the consolidated data point being built by an
+// archive for a data source. It's only meaningful for the standard
+// consolidation functions.
+type CDPPrep struct {
+	// Value is the consolidated value of the primary data points so far.
+	Value float64
+
+	// UnknownPDPs is the number of unknown primary data points so far.
+	UnknownPDPs int64
+}
+
+// RRA is a round robin archive of a RRD file.
+type RRA struct {
+	CF        string
+	Rows      int64
+	PDPPerRow int64
+
+	// XFF is the xfiles factor, only set for the standard consolidation
+	// functions.
+	XFF float64
+
+	// CurRow is the index of the most recently written row.
+	CurRow int64
+
+	// CDPPrep is the state of the archive for each data source.
+	CDPPrep []CDPPrep
+
+	offset int64
+}
+
+// File is a RRD file.
+type File struct {
+	Version    string
+	Step       time.Duration
+	LastUpdate time.Time
+	DS         []DS
+	RRA        []RRA
+
+	// HeaderSize is the size of the header, after which the rows of the
+	// archives are stored.
+	HeaderSize int64
+
+	version int
+	head    header
+	sec     sections
+	r       io.ReaderAt
+	w       io.WriterAt
+}
+
+// Open opens the RRD file path for reading, which must be closed by the
+// caller with the returned io.Closer once finished with.
+func Open(path string) (*File, io.Closer, error) {
+	return OpenFile(path, os.O_RDONLY)
+}
+
+// OpenFile is like Open but opens path with flag, which must include
+// os.O_RDWR for the file to be updated.
+func OpenFile(path string, flag int) (*File, io.Closer, error) {
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rf, err := Parse(f)
+	if err != nil {
+		f.Close() // nolint: errcheck
+		return nil, nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return rf, f, nil
+}
+
+// header encodes and decodes the fields of a header held in memory.
+type header struct {
+	layout
+	buf []byte
+}
+
+func (r header) cstring(off, n int) string {
+	b := r.buf[off : off+n]
+	if i := bytes.IndexByte(b, 0); i != -1 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func (r header) ulong(off int) int64 {
+	if r.long == 4 {
+		return int64(r.order.Uint32(r.buf[off:]))
+	}
+	return int64(r.order.Uint64(r.buf[off:]))
+}
+
+func (r header) double(off int) float64 {
+	return math.Float64frombits(r.order.Uint64(r.buf[off:]))
+}
+
+// detectLayout returns the layout of the file whose header starts with buf.
+func detectLayout(buf []byte) (layout, error) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, off := range []int{16, 12} {
+			if math.Float64frombits(order.Uint64(buf[off:])) != floatCookie {
+				continue
+			}
+			if off == 12 {
+				return layout{order: order, long: 4, align: 4}, nil
+			}
+			// With 32 bit longs the data source and archive counts share
+			// the 64 bits which would be the data source count.
+			if order.Uint64(buf[24:]) > math.MaxUint32 {
+				return layout{order: order, long: 4, align: 8}, nil
+			}
+			return layout{order: order, long: 8, align: 8}, nil
+		}
+	}
+	return layout{}, fmt.Errorf("%w: unsupported float format", ErrInvalidFile)
+}
+
+// readAt reads n bytes from r at off.
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Parse parses the header of the RRD file read from r, which is used to
+// read rows on demand. If r is also an io.WriterAt the file can be updated.
+func Parse(r io.ReaderAt) (*File, error) {
+	// stat_head_t is between 112 and 128 bytes depending on the layout.
+	buf := make([]byte, 128)
+	n, err := r.ReadAt(buf, 0)
+	switch {
+	case n < 112:
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidFile, err)
+	case string(buf[:len(cookie)]) != cookie:
+		return nil, fmt.Errorf("%w: bad cookie", ErrInvalidFile)
+	}
+
+	l, err := detectLayout(buf)
+	if err != nil {
+		return nil, err
+	}
+	h := header{layout: l, buf: buf}
+	f := &File{Version: h.cstring(len(cookie), versionLen), r: r}
+	if f.version, err = parseVersion(f.Version); err != nil {
+		return nil, err
+	}
+	f.w, _ = r.(io.WriterAt)
+
+	off := alignUp(len(cookie)+versionLen, l.align) + 8
+	dsCnt, rraCnt, step := h.ulong(off), h.ulong(off+l.long), h.ulong(off+2*l.long)
+	switch {
+	case dsCnt <= 0 || dsCnt > maxCount:
+		return nil, fmt.Errorf("%w: data source count %v", ErrInvalidFile, dsCnt)
+	case rraCnt <= 0 || rraCnt > maxCount:
+		return nil, fmt.Errorf("%w: archive count %v", ErrInvalidFile, rraCnt)
+	case step <= 0:
+		return nil, fmt.Errorf("%w: step %v", ErrInvalidFile, step)
+	}
+	f.Step = time.Duration(step) * time.Second
+
+	f.sec = l.sections(f.version, int(dsCnt), int(rraCnt))
+	if h.buf, err = readAt(r, 0, f.sec.size); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidFile, err)
+	}
+	f.head = h
+	f.HeaderSize = int64(f.sec.size)
+
+	f.DS = make([]DS, dsCnt)
+	for i := range f.DS {
+		off := f.sec.ds + i*l.dsDefSize()
+		par := off + alignUp(2*nameSize, l.univalAlign())
+		d := DS{Name: h.cstring(off, nameSize), Type: h.cstring(off+nameSize, nameSize)}
+		if d.Type != rrd.Compute {
+			d.MinimalHeartbeat = time.Duration(h.ulong(par)) * time.Second
+			d.Min, d.Max = h.double(par+univalSize), h.double(par+2*univalSize)
+		}
+		f.DS[i] = d
+	}
+
+	rraRows, rraPar, rraSize := l.rraDefOffsets()
+	f.RRA = make([]RRA, rraCnt)
+	data := f.HeaderSize
+	for i := range f.RRA {
+		off := f.sec.rra + i*rraSize
+		a := RRA{
+			CF:        h.cstring(off, nameSize),
+			Rows:      h.ulong(off + rraRows),
+			PDPPerRow: h.ulong(off + rraRows + l.long),
+			offset:    data,
+		}
+		if a.Rows <= 0 || a.PDPPerRow <= 0 {
+			return nil, fmt.Errorf("%w: archive %v: %v rows of %v pdps", ErrInvalidFile, i, a.Rows, a.PDPPerRow)
+		}
+		if rrd.ConsolidationFunc(a.CF).Valid() {
+			a.XFF = h.double(off + rraPar)
+		}
+		f.RRA[i] = a
+		data += a.Rows * int64(dsCnt) * 8
+	}
+
+	if err := f.readState(); err != nil {
+		return nil, err
+	}
+
+	if _, err := readAt(r, data-1, 1); err != nil {
+		return nil, fmt.Errorf("%w: truncated data: %v", ErrInvalidFile, err)
+	}
+
+	return f, nil
+}
+
+// readState reads the state of f which changes with each update from its
+// header: the last update, the primary and consolidated data points being
+// built and the current rows.
+func (f *File) readState() error {
+	h := f.head
+	f.LastUpdate = time.Unix(h.ulong(f.sec.live), 0)
+	if f.version >= 3 {
+		f.LastUpdate = f.LastUpdate.Add(time.Duration(h.ulong(f.sec.live+h.long)) * time.Microsecond)
+	}
+
+	for i := range f.DS {
+		d := &f.DS[i]
+		off := f.sec.pdp + i*h.pdpPrepSize()
+		scratch := off + alignUp(lastDSSize, h.univalAlign())
+		d.LastDS = h.cstring(off, lastDSSize)
+		d.UnknownSec = h.ulong(scratch)
+		d.Value = h.double(scratch + univalSize)
+	}
+
+	for i := range f.RRA {
+		a := &f.RRA[i]
+		a.CDPPrep = make([]CDPPrep, len(f.DS))
+		for j := range a.CDPPrep {
+			off := f.sec.cdp + (i*len(f.DS)+j)*parSize
+			a.CDPPrep[j] = CDPPrep{Value: h.double(off), UnknownPDPs: h.ulong(off + univalSize)}
+		}
+
+		a.CurRow = h.ulong(f.sec.ptr + i*h.long)
+		if a.CurRow < 0 || a.CurRow >= a.Rows {
+			return fmt.Errorf("%w: archive %v: current row %v", ErrInvalidFile, i, a.CurRow)
+		}
+	}
+	return nil
+}
+
+// parseVersion returns the numeric value of the file version v.
+func parseVersion(v string) (int, error) {
+	switch v {
+	case "0001":
+		return 1, nil
+	case "0002":
+		return 2, nil
+	case "0003":
+		return 3, nil
+	case "0004":
+		return 4, nil
+	case "0005":
+		return 5, nil
+	}
+	return 0, fmt.Errorf("%w: unsupported version %q", ErrInvalidFile, v)
+}
+
+// Info returns the RRDInfo representation of f, as returned by rrdcached
+// for the same file. The cdef of COMPUTE data sources and the parameters
+// of Holt-Winters archives aren't decoded.
+func (f *File) Info() *rrd.RRDInfo {
+	info := &rrd.RRDInfo{
+		Version:    f.Version,
+		Step:       f.Step,
+		LastUpdate: time.Unix(f.LastUpdate.Unix(), 0),
+		HeaderSize: f.HeaderSize,
+		DS:         make(map[string]rrd.DSInfo, len(f.DS)),
+		RRA:        make([]rrd.RRAInfo, len(f.RRA)),
+	}
+	for i, d := range f.DS {
+		info.DS[d.Name] = rrd.DSInfo{
+			Name:             d.Name,
+			Index:            i,
+			Type:             d.Type,
+			MinimalHeartbeat: d.MinimalHeartbeat,
+			Min:              d.Min,
+			Max:              d.Max,
+			LastDS:           d.LastDS,
+			Value:            d.Value,
+			UnknownSec:       d.UnknownSec,
+		}
+	}
+	for i, a := range f.RRA {
+		info.RRA[i] = rrd.RRAInfo{
+			CF:        a.CF,
+			Rows:      a.Rows,
+			CurRow:    a.CurRow,
+			PDPPerRow: a.PDPPerRow,
+			XFF:       a.XFF,
+		}
+	}
+	return info
+}
+
+// Rows returns all the rows of archive i ordered by time. Rows which have
+// never been written are unknown.
+func (f *File) Rows(i int) (*rrd.FetchResult, error) {
+	if i < 0 || i >= len(f.RRA) {
+		return nil, fmt.Errorf("%w: archive %v out of range", rrd.ErrInvalidRRAIndex, i)
+	}
+
+	a := f.RRA[i]
+	nds := len(f.DS)
+	buf, err := readAt(f.r, a.offset, int(a.Rows)*nds*8)
+	if err != nil {
+		return nil, fmt.Errorf("%w: archive %v rows: %v", ErrInvalidFile, i, err)
+	}
+
+	step := int64(f.Step/time.Second) * a.PDPPerRow
+	last := f.LastUpdate.Unix()
+	last -= last % step
+	res := &rrd.FetchResult{
+		Start: time.Unix(last-a.Rows*step, 0),
+		End:   time.Unix(last, 0),
+		Step:  time.Duration(step) * time.Second,
+		Names: make([]string, nds),
+		Rows:  make([]rrd.FetchResultRow, a.Rows),
+	}
+	for j, d := range f.DS {
+		res.Names[j] = d.Name
+	}
+	for k := range res.Rows {
+		// The oldest row follows the current row.
+		off := int((a.CurRow+1+int64(k))%a.Rows) * nds * 8
+		row := rrd.FetchResultRow{
+			Time:   time.Unix(last-(a.Rows-1-int64(k))*step, 0),
+			Values: make([]float64, nds),
+		}
+		for j := range row.Values {
+			row.Values[j] = math.Float64frombits(f.head.order.Uint64(buf[off+j*8:]))
+		}
+		res.Rows[k] = row
+	}
+	return res, nil
+}
+
+// Fetch returns the rows of f for cf between start and end from the
+// archive rrdtool fetch would select, with rows outside of the archive
+// unknown.
+func (f *File) Fetch(cf rrd.ConsolidationFunc, start, end time.Time) (*rrd.FetchResult, error) {
+	plan, err := rrd.PlanQuery(f.Info(), cf, start, end, 0)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := f.Rows(plan.RRA)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &rrd.FetchResult{
+		Start: plan.Start,
+		End:   plan.End,
+		Step:  plan.Step,
+		Names: rows.Names,
+	}
+	j := 0
+	for t := plan.Start.Add(plan.Step); !t.After(plan.End); t = t.Add(plan.Step) {
+		for j < len(rows.Rows) && rows.Rows[j].Time.Before(t) {
+			j++
+		}
+		row := rrd.FetchResultRow{Time: t}
+		if j < len(rows.Rows) && rows.Rows[j].Time.Equal(t) {
+			row.Values = rows.Rows[j].Values
+		} else {
+			row.Values = make([]float64, len(rows.Names))
+			for k := range row.Values {
+				row.Values[k] = math.NaN()
+			}
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res, nil
+}
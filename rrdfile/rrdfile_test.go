@@ -0,0 +1,269 @@
+package rrdfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+)
+
+// builder builds a RRD file with the layout of a C compiler, padding fields
+// to their natural alignment.
+type builder struct {
+	buf   bytes.Buffer
+	order binary.AppendByteOrder
+	long  int
+	align int
+}
+
+func (b *builder) pad(n int) {
+	for b.buf.Len()%n != 0 {
+		b.buf.WriteByte(0)
+	}
+}
+
+func (b *builder) str(s string, n int) {
+	b.buf.WriteString(s)
+	b.buf.Write(make([]byte, n-len(s)))
+}
+
+func (b *builder) ulong(v int64) {
+	b.pad(b.long)
+	if b.long == 4 {
+		b.buf.Write(b.order.AppendUint32(nil, uint32(v)))
+		return
+	}
+	b.buf.Write(b.order.AppendUint64(nil, uint64(v)))
+}
+
+func (b *builder) double(v float64) {
+	b.pad(b.align)
+	b.buf.Write(b.order.AppendUint64(nil, math.Float64bits(v)))
+}
+
+// par writes a par or scratch array of unival, starting with the longs and
+// doubles of vals.
+func (b *builder) par(vals ...interface{}) {
+	b.pad(max(b.long, b.align))
+	start := b.buf.Len()
+	for i, v := range vals {
+		b.buf.Write(make([]byte, start+i*univalSize-b.buf.Len()))
+		switch v := v.(type) {
+		case int64:
+			b.ulong(v)
+		case float64:
+			b.double(v)
+		}
+	}
+	b.buf.Write(make([]byte, start+parSize-b.buf.Len()))
+}
+
+// testFile returns a version 3 RRD file with the layout of b, which has a
+// GAUGE and COUNTER data source with a minute step, an AVERAGE archive with
+// 4 rows and a MAX archive with 3 rows of 2 minutes.
+func testFile(b *builder) []byte {
+	nan := math.NaN()
+	b.str(cookie, 4)
+	b.str("0003", 5)
+	b.double(floatCookie)
+	b.ulong(2)
+	b.ulong(2)
+	b.ulong(60)
+	b.par()
+
+	b.str("watts", nameSize)
+	b.str("GAUGE", nameSize)
+	b.par(int64(120), 0.0, nan)
+	b.str("hits", nameSize)
+	b.str("COUNTER", nameSize)
+	b.par(int64(120), 0.0, 1000.0)
+
+	b.str("AVERAGE", nameSize)
+	b.ulong(4)
+	b.ulong(1)
+	b.par(0.5)
+	b.str("MAX", nameSize)
+	b.ulong(3)
+	b.ulong(2)
+	b.par(0.25)
+	b.pad(max(b.long, b.align))
+
+	b.ulong(1000250)
+	b.ulong(500000)
+
+	b.str("12", lastDSSize)
+	b.par(int64(10), 3.5)
+	b.str("UNKN", lastDSSize)
+	b.par(int64(50), 0.0)
+
+	for i := 0; i < 4; i++ {
+		b.par(float64(i), int64(i%2))
+	}
+	b.ulong(1)
+	b.ulong(2)
+
+	for _, v := range []float64{1, 10, 2, 20, 3, 30, 4, 40, 5, 50, 6, 60, nan, 70} {
+		b.double(v)
+	}
+	return b.buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		b          *builder
+		headerSize int64
+	}{
+		{name: "amd64", b: &builder{order: binary.LittleEndian, long: 8, align: 8}, headerSize: 128 + 2*120 + 2*120 + 16 + 2*112 + 4*80 + 2*8},
+		{name: "386", b: &builder{order: binary.LittleEndian, long: 4, align: 4}, headerSize: 112 + 2*120 + 2*108 + 8 + 2*112 + 4*80 + 2*4},
+		{name: "arm", b: &builder{order: binary.LittleEndian, long: 4, align: 8}, headerSize: 120 + 2*120 + 2*112 + 8 + 2*112 + 4*80 + 2*4},
+		{name: "ppc64", b: &builder{order: binary.BigEndian, long: 8, align: 8}, headerSize: 128 + 2*120 + 2*120 + 16 + 2*112 + 4*80 + 2*8},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data := testFile(tc.b)
+			f, err := Parse(bytes.NewReader(data))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, tc.headerSize, f.HeaderSize)
+			assert.Equal(t, "0003", f.Version)
+			assert.Equal(t, time.Minute, f.Step)
+			assert.Equal(t, time.Unix(1000250, 500000000), f.LastUpdate)
+			if assert.Len(t, f.DS, 2) {
+				assert.Equal(t, "watts", f.DS[0].Name)
+				assert.Equal(t, rrd.Gauge, f.DS[0].Type)
+				assert.Equal(t, time.Minute*2, f.DS[0].MinimalHeartbeat)
+				assert.True(t, math.IsNaN(f.DS[0].Max))
+				assert.Equal(t, "12", f.DS[0].LastDS)
+				assert.Equal(t, int64(10), f.DS[0].UnknownSec)
+				assert.Equal(t, 3.5, f.DS[0].Value)
+				assert.Equal(t, DS{
+					Name:             "hits",
+					Type:             rrd.Counter,
+					MinimalHeartbeat: time.Minute * 2,
+					Max:              1000,
+					LastDS:           "UNKN",
+					UnknownSec:       50,
+				}, f.DS[1])
+			}
+			assert.Equal(t, []RRA{
+				{CF: "AVERAGE", Rows: 4, PDPPerRow: 1, XFF: 0.5, CurRow: 1, CDPPrep: []CDPPrep{{0, 0}, {1, 1}}, offset: tc.headerSize},
+				{CF: "MAX", Rows: 3, PDPPerRow: 2, XFF: 0.25, CurRow: 2, CDPPrep: []CDPPrep{{2, 0}, {3, 1}}, offset: tc.headerSize + 4*2*8},
+			}, f.RRA)
+
+			rows, err := f.Rows(0)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, &rrd.FetchResult{
+				Start: time.Unix(999960, 0),
+				End:   time.Unix(1000200, 0),
+				Step:  time.Minute,
+				Names: []string{"watts", "hits"},
+				Rows: []rrd.FetchResultRow{
+					{Time: time.Unix(1000020, 0), Values: []float64{3, 30}},
+					{Time: time.Unix(1000080, 0), Values: []float64{4, 40}},
+					{Time: time.Unix(1000140, 0), Values: []float64{1, 10}},
+					{Time: time.Unix(1000200, 0), Values: []float64{2, 20}},
+				},
+			}, rows)
+
+			rows, err = f.Rows(1)
+			if assert.NoError(t, err) && assert.Len(t, rows.Rows, 3) {
+				assert.Equal(t, time.Unix(999960, 0), rows.Rows[0].Time)
+				assert.Equal(t, []float64{5, 50}, rows.Rows[0].Values)
+				assert.True(t, math.IsNaN(rows.Rows[2].Values[0]))
+			}
+
+			_, err = f.Rows(2)
+			assert.ErrorIs(t, err, rrd.ErrInvalidRRAIndex)
+
+			info := f.Info()
+			assert.Equal(t, time.Unix(1000250, 0), info.LastUpdate)
+			assert.Equal(t, 1, info.DS["hits"].Index)
+			assert.Equal(t, int64(2), info.RRA[1].CurRow)
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	data := testFile(&builder{order: binary.LittleEndian, long: 8, align: 8})
+	f, err := Parse(bytes.NewReader(data))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r, err := f.Fetch(rrd.Average, time.Unix(999900, 0), time.Unix(1000100, 0))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Unix(999900, 0), r.Start)
+	assert.Equal(t, time.Unix(1000140, 0), r.End)
+	if assert.Len(t, r.Rows, 4) {
+		assert.True(t, math.IsNaN(r.Rows[0].Values[0]))
+		assert.Equal(t, time.Unix(1000020, 0), r.Rows[1].Time)
+		assert.Equal(t, []float64{3, 30}, r.Rows[1].Values)
+		assert.Equal(t, []float64{1, 10}, r.Rows[3].Values)
+	}
+
+	_, err = f.Fetch(rrd.Min, time.Unix(999900, 0), time.Unix(1000100, 0))
+	assert.ErrorIs(t, err, rrd.ErrInvalidCF)
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid := testFile(&builder{order: binary.LittleEndian, long: 8, align: 8})
+	corrupt := func(off int, b ...byte) []byte {
+		data := append([]byte(nil), valid...)
+		copy(data[off:], b)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty"},
+		{name: "cookie", data: corrupt(0, 'X')},
+		{name: "version", data: corrupt(4, '9')},
+		{name: "float-cookie", data: corrupt(16, 1, 2, 3)},
+		{name: "no-ds", data: corrupt(24, 0)},
+		{name: "step", data: corrupt(40, 0)},
+		{name: "truncated", data: valid[:len(valid)-1]},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(bytes.NewReader(tc.data))
+			assert.ErrorIs(t, err, ErrInvalidFile)
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.rrd")
+	data := testFile(&builder{order: binary.LittleEndian, long: 8, align: 8})
+	if !assert.NoError(t, os.WriteFile(path, data, 0o600)) {
+		return
+	}
+
+	f, c, err := Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	assert.Len(t, f.RRA, 2)
+
+	_, _, err = Open(filepath.Join(t.TempDir(), "missing.rrd"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,263 @@
+package rrdfile
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// Update updates f with samples, as rrdtool update does, writing the rows
+// they complete and the new state of f to the file. Samples without a time
+// are for the current time and unknown values are NaN.
+//
+// COMPUTE data sources are always unknown and archives with Holt-Winters
+// consolidation functions aren't supported.
+func (f *File) Update(samples ...rrd.Sample) error {
+	switch {
+	case f.w == nil:
+		return ErrReadOnly
+	case len(samples) == 0:
+		return rrd.ErrNoSamples
+	}
+	for i, a := range f.RRA {
+		if !standardCF(a.CF) {
+			return fmt.Errorf("%w: update of archive %v with consolidation function %v", rrd.ErrNotSupported, i, a.CF)
+		}
+	}
+
+	var err error
+	for _, s := range samples {
+		if err = f.update(s); err != nil {
+			break
+		}
+	}
+
+	// Write the state of the samples which succeeded, as their rows have
+	// been written.
+	f.writeState()
+	if _, werr := f.w.WriteAt(f.head.buf, 0); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// update updates f with s.
+func (f *File) update(s rrd.Sample) error {
+	t := s.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if f.version < 3 {
+		t = t.Truncate(time.Second)
+	}
+	switch {
+	case len(s.Values) != len(f.DS):
+		return fmt.Errorf("%w: %v values for %v data sources", rrd.ErrInvalidArg, len(s.Values), len(f.DS))
+	case !t.After(f.LastUpdate):
+		return fmt.Errorf("%w: %v not after last update %v", rrd.ErrIllegalUpdate, t.Unix(), f.LastUpdate.Unix())
+	}
+
+	interval := t.Sub(f.LastUpdate).Seconds()
+	pdpNew := make([]float64, len(f.DS))
+	for i := range f.DS {
+		d := &f.DS[i]
+		pdpNew[i] = d.pdp(s.Values[i], interval)
+		d.LastDS = "U"
+		if !math.IsNaN(s.Values[i]) {
+			d.LastDS = strconv.FormatFloat(s.Values[i], 'f', -1, 64)
+		}
+	}
+
+	step := int64(f.Step / time.Second)
+	last := f.LastUpdate.Unix()
+	procPDP, occuPDP := last-last%step, t.Unix()-t.Unix()%step
+	if procPDP == occuPDP {
+		// Still within the same primary data point.
+		for i := range f.DS {
+			f.DS[i].accumulate(pdpNew[i], interval, interval)
+		}
+		f.LastUpdate = t
+		return nil
+	}
+
+	preInt := time.Unix(occuPDP, 0).Sub(f.LastUpdate).Seconds()
+	postInt := t.Sub(time.Unix(occuPDP, 0)).Seconds()
+	pdpTemp := make([]float64, len(f.DS))
+	for i := range f.DS {
+		d := &f.DS[i]
+		d.accumulate(pdpNew[i], interval, preInt)
+		known := occuPDP - procPDP - d.UnknownSec
+		if d.UnknownSec > int64(d.MinimalHeartbeat/time.Second) || known <= 0 {
+			pdpTemp[i] = math.NaN()
+		} else {
+			pdpTemp[i] = d.Value / float64(known)
+		}
+
+		d.Value, d.UnknownSec = 0, 0
+		d.accumulate(pdpNew[i], interval, postInt)
+	}
+
+	elapsed := (occuPDP - procPDP) / step
+	for i := range f.RRA {
+		if err := f.consolidate(i, procPDP/step, elapsed, pdpTemp); err != nil {
+			return err
+		}
+	}
+	f.LastUpdate = t
+	return nil
+}
+
+// pdp returns the contribution of v, updated interval seconds after the
+// last update, to the primary data point of d, or NaN if it's unknown.
+func (d *DS) pdp(v, interval float64) float64 {
+	if math.IsNaN(v) || interval > d.MinimalHeartbeat.Seconds() {
+		return math.NaN()
+	}
+
+	var pdp float64
+	switch d.Type {
+	case rrd.Counter, rrd.Derive, rrd.DCounter, rrd.DDerive:
+		prev, err := strconv.ParseFloat(d.LastDS, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		pdp = v - prev
+		switch {
+		case pdp >= 0:
+		case d.Type == rrd.Counter:
+			// Counter wrap, first assuming a 32 bit counter then 64.
+			if pdp += math.MaxUint32 + 1; pdp < 0 {
+				pdp += math.MaxUint64 - math.MaxUint32
+			}
+		case d.Type == rrd.DCounter:
+			return math.NaN()
+		}
+	case rrd.Absolute:
+		pdp = v
+	case rrd.Gauge:
+		pdp = v * interval
+	default:
+		return math.NaN()
+	}
+
+	rate := pdp / interval
+	if (!math.IsNaN(d.Min) && rate < d.Min) || (!math.IsNaN(d.Max) && rate > d.Max) {
+		return math.NaN()
+	}
+	return pdp
+}
+
+// accumulate adds secs seconds of pdp, which was updated over interval
+// seconds, to the primary data point being built by d.
+func (d *DS) accumulate(pdp, interval, secs float64) {
+	if math.IsNaN(pdp) {
+		d.UnknownSec += int64(secs)
+		return
+	}
+	d.Value += pdp / interval * secs
+}
+
+// consolidate consolidates elapsed primary data points with the values
+// pdps, following the primary data point procPDP, into archive i writing
+// the rows they complete.
+func (f *File) consolidate(i int, procPDP, elapsed int64, pdps []float64) error {
+	a := &f.RRA[i]
+	cf := rrd.ConsolidationFunc(a.CF)
+	offset := a.PDPPerRow - procPDP%a.PDPPerRow
+	if elapsed < offset {
+		for j := range a.CDPPrep {
+			a.CDPPrep[j].add(cf, pdps[j], elapsed)
+		}
+		return nil
+	}
+
+	// The first row completes the consolidated data point in progress and
+	// any further rows are made only of pdps.
+	steps := (elapsed-offset)/a.PDPPerRow + 1
+	rem := (elapsed - offset) % a.PDPPerRow
+	first := make([]float64, len(pdps))
+	full := make([]float64, len(pdps))
+	for j := range a.CDPPrep {
+		c := &a.CDPPrep[j]
+		c.add(cf, pdps[j], offset)
+		first[j] = c.result(cf, a.PDPPerRow, a.XFF)
+
+		*c = CDPPrep{Value: math.NaN()}
+		c.add(cf, pdps[j], a.PDPPerRow)
+		full[j] = c.result(cf, a.PDPPerRow, a.XFF)
+
+		*c = CDPPrep{Value: math.NaN()}
+		c.add(cf, pdps[j], rem)
+	}
+
+	// Only the last Rows rows are kept.
+	skip := max(steps-a.Rows, 0)
+	a.CurRow = (a.CurRow + skip) % a.Rows
+	for k := skip; k < steps; k++ {
+		a.CurRow = (a.CurRow + 1) % a.Rows
+		values := full
+		if k == 0 {
+			values = first
+		}
+		if err := f.writeRow(i, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRow writes values to the current row of archive i.
+func (f *File) writeRow(i int, values []float64) error {
+	a := f.RRA[i]
+	buf := make([]byte, len(values)*8)
+	for j, v := range values {
+		f.head.order.PutUint64(buf[j*8:], math.Float64bits(v))
+	}
+	if _, err := f.w.WriteAt(buf, a.offset+a.CurRow*int64(len(values))*8); err != nil {
+		return fmt.Errorf("archive %v row %v: %w", i, a.CurRow, err)
+	}
+	return nil
+}
+
+// add adds n primary data points with value v to c.
+func (c *CDPPrep) add(cf rrd.ConsolidationFunc, v float64, n int64) {
+	switch {
+	case n == 0:
+		return
+	case math.IsNaN(v):
+		c.UnknownPDPs += n
+		return
+	}
+
+	switch {
+	case math.IsNaN(c.Value):
+		c.Value = v
+		if cf == rrd.Average {
+			c.Value *= float64(n)
+		}
+	case cf == rrd.Average:
+		c.Value += v * float64(n)
+	case cf == rrd.Min:
+		c.Value = math.Min(c.Value, v)
+	case cf == rrd.Max:
+		c.Value = math.Max(c.Value, v)
+	case cf == rrd.Last:
+		c.Value = v
+	}
+}
+
+// result returns the value of the consolidated data point c of pdpPerRow
+// primary data points, which is unknown if the proportion of unknown
+// primary data points exceeds xff.
+func (c *CDPPrep) result(cf rrd.ConsolidationFunc, pdpPerRow int64, xff float64) float64 {
+	if float64(c.UnknownPDPs) > float64(pdpPerRow)*xff || math.IsNaN(c.Value) {
+		return math.NaN()
+	}
+	if cf == rrd.Average {
+		return c.Value / float64(pdpPerRow-c.UnknownPDPs)
+	}
+	return c.Value
+}
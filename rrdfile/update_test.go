@@ -0,0 +1,163 @@
+package rrdfile
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+)
+
+// sample returns a sample for the unix time ts.
+func sample(ts int64, values ...float64) rrd.Sample {
+	return rrd.Sample{Time: time.Unix(ts, 0), Values: values}
+}
+
+// rowValues returns the values of the rows of r with NaN replaced by -1, so
+// they can be compared with assert.Equal.
+func rowValues(r *rrd.FetchResult) [][]float64 {
+	values := make([][]float64, len(r.Rows))
+	for i, row := range r.Rows {
+		for _, v := range row.Values {
+			if math.IsNaN(v) {
+				v = -1
+			}
+			values[i] = append(values[i], v)
+		}
+	}
+	return values
+}
+
+func TestUpdate(t *testing.T) {
+	f, err := New(testInfo(t), time.Unix(999960, 0))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	nan := math.NaN()
+	err = f.Update(
+		sample(1000020, 10, 100),
+		sample(1000080, 20, 160),
+		// Within a step.
+		sample(1000110, 40, 190),
+		sample(1000140, 40, 220),
+		// Exceeds the heartbeat.
+		sample(1000500, 50, 300),
+		sample(1000560, 60, nan),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = f.Update(sample(1000590, 70, 400))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); !assert.NoError(t, err) {
+		return
+	}
+	f, err = Parse(bytes.NewReader(buf.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Unix(1000590, 0), f.LastUpdate)
+	assert.Equal(t, "70", f.DS[0].LastDS)
+	assert.Equal(t, 70.0*30, f.DS[0].Value)
+	assert.Equal(t, "400", f.DS[1].LastDS)
+	assert.Equal(t, int64(30), f.DS[1].UnknownSec)
+
+	r, err := f.Rows(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Unix(1000560, 0), r.End)
+	assert.Equal(t, [][]float64{
+		{10, -1},
+		{20, 1},
+		{40, 1},
+		{-1, -1}, {-1, -1}, {-1, -1}, {-1, -1}, {-1, -1}, {-1, -1},
+		{60, -1},
+	}, rowValues(r))
+
+	r, err = f.Rows(1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Unix(1000080, 0), r.Rows[0].Time)
+	assert.Equal(t, [][]float64{
+		{20, 1},
+		{40, 1},
+		{-1, -1},
+		{-1, -1},
+		{60, -1},
+	}, rowValues(r))
+}
+
+func TestUpdateErrors(t *testing.T) {
+	f, err := New(testInfo(t), time.Unix(999960, 0))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.ErrorIs(t, f.Update(), rrd.ErrNoSamples)
+	assert.ErrorIs(t, f.Update(sample(1000020, 1)), rrd.ErrInvalidArg)
+	assert.ErrorIs(t, f.Update(sample(1000020, 1, 2), sample(1000020, 1, 2)), rrd.ErrIllegalUpdate)
+	// The first sample succeeded.
+	assert.Equal(t, time.Unix(1000020, 0), f.LastUpdate)
+
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); !assert.NoError(t, err) {
+		return
+	}
+	f, err = Parse(bytes.NewReader(buf.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Unix(1000020, 0), f.LastUpdate)
+	assert.ErrorIs(t, f.Update(sample(1000080, 1, 2)), ErrReadOnly)
+}
+
+func TestDSPDP(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name   string
+		typ    string
+		lastDS string
+		max    float64
+		v      float64
+		expect float64
+	}{
+		{name: "gauge", typ: rrd.Gauge, v: 2, expect: 120},
+		{name: "gauge-max", typ: rrd.Gauge, max: 1, v: 2, expect: nan},
+		{name: "unknown", typ: rrd.Gauge, v: nan, expect: nan},
+		{name: "counter", typ: rrd.Counter, lastDS: "100", v: 160, expect: 60},
+		{name: "counter-first", typ: rrd.Counter, lastDS: "U", v: 160, expect: nan},
+		{name: "counter-wrap", typ: rrd.Counter, lastDS: "4294967290", v: 5, expect: 11},
+		{name: "dcounter-reset", typ: rrd.DCounter, lastDS: "1.5", v: 0.5, expect: nan},
+		{name: "derive", typ: rrd.Derive, lastDS: "100", v: 40, expect: -60},
+		{name: "absolute", typ: rrd.Absolute, v: 30, expect: 30},
+		{name: "compute", typ: rrd.Compute, v: 30, expect: nan},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			max := tc.max
+			if max == 0 {
+				max = nan
+			}
+			d := &DS{Type: tc.typ, MinimalHeartbeat: time.Minute * 2, Min: nan, Max: max, LastDS: tc.lastDS}
+			v := d.pdp(tc.v, 60)
+			if math.IsNaN(tc.expect) {
+				assert.True(t, math.IsNaN(v), "expected NaN got %v", v)
+				return
+			}
+			assert.Equal(t, tc.expect, v)
+		})
+	}
+
+	d := &DS{Type: rrd.Gauge, MinimalHeartbeat: time.Minute, Min: nan, Max: nan}
+	assert.True(t, math.IsNaN(d.pdp(1, 61)))
+}
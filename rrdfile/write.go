@@ -0,0 +1,244 @@
+package rrdfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// ErrReadOnly is returned by Update if the file wasn't opened for writing.
+var ErrReadOnly = errors.New("file not writable")
+
+// nativeLayout returns the layout of files created by rrdtool on the
+// current platform.
+func nativeLayout() layout {
+	l := layout{order: binary.NativeEndian, long: strconv.IntSize / 8, align: 8}
+	if runtime.GOARCH == "386" {
+		l.align = 4
+	}
+	return l
+}
+
+func (h header) putCString(off, n int, s string) {
+	b := h.buf[off : off+n]
+	clear(b)
+	copy(b[:n-1], s)
+}
+
+func (h header) putULong(off int, v int64) {
+	if h.long == 4 {
+		h.order.PutUint32(h.buf[off:], uint32(v))
+		return
+	}
+	h.order.PutUint64(h.buf[off:], uint64(v))
+}
+
+func (h header) putDouble(off int, v float64) {
+	h.order.PutUint64(h.buf[off:], math.Float64bits(v))
+}
+
+// writeState writes the state of f which changes with each update to its
+// header, the inverse of readState.
+func (f *File) writeState() {
+	h := f.head
+	h.putULong(f.sec.live, f.LastUpdate.Unix())
+	if f.version >= 3 {
+		h.putULong(f.sec.live+h.long, int64(f.LastUpdate.Nanosecond())/int64(time.Microsecond))
+	}
+
+	for i, d := range f.DS {
+		off := f.sec.pdp + i*h.pdpPrepSize()
+		scratch := off + alignUp(lastDSSize, h.univalAlign())
+		h.putCString(off, lastDSSize, d.LastDS)
+		h.putULong(scratch, d.UnknownSec)
+		h.putDouble(scratch+univalSize, d.Value)
+	}
+
+	for i, a := range f.RRA {
+		for j, c := range a.CDPPrep {
+			off := f.sec.cdp + (i*len(f.DS)+j)*parSize
+			h.putDouble(off, c.Value)
+			h.putULong(off+univalSize, c.UnknownPDPs)
+		}
+		h.putULong(f.sec.ptr+i*h.long, a.CurRow)
+	}
+}
+
+// buffer is an in memory io.ReaderAt and io.WriterAt.
+type buffer struct {
+	b []byte
+}
+
+func (b *buffer) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(b.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (b *buffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := off + int64(len(p)); end > int64(len(b.b)) {
+		b.b = append(b.b, make([]byte, end-int64(len(b.b)))...)
+	}
+	return copy(b.b[off:], p), nil
+}
+
+// New returns a new in memory RRD file with the schema of info, such as
+// returned by rrd.CreateRRD.Info, and no updates since start. The file has
+// the layout rrdtool uses on the current platform and all its rows are
+// unknown. It can be updated and written with WriteTo.
+//
+// Only the standard consolidation functions are supported and COMPUTE data
+// sources aren't, as their cdef can't be encoded.
+func New(info *rrd.RRDInfo, start time.Time) (*File, error) {
+	step := int64(info.Step / time.Second)
+	switch {
+	case step <= 0:
+		return nil, fmt.Errorf("%w: step %v", rrd.ErrInvalidArg, info.Step)
+	case len(info.DS) == 0:
+		return nil, rrd.ErrNoDS
+	case len(info.RRA) == 0:
+		return nil, rrd.ErrNoRRA
+	}
+
+	version := "0003"
+	ds := make([]rrd.DSInfo, len(info.DS))
+	seen := make([]bool, len(info.DS))
+	for _, d := range info.DS {
+		switch {
+		case d.Index < 0 || d.Index >= len(ds) || seen[d.Index]:
+			return nil, fmt.Errorf("%w: data source %v index %v", rrd.ErrInvalidArg, d.Name, d.Index)
+		case d.Name == "" || len(d.Name) >= nameSize:
+			return nil, fmt.Errorf("%w: data source name %q", rrd.ErrInvalidArg, d.Name)
+		case d.Type == rrd.Compute:
+			return nil, fmt.Errorf("%w: COMPUTE data source %v", rrd.ErrNotSupported, d.Name)
+		case d.Type == rrd.DCounter || d.Type == rrd.DDerive:
+			version = "0005"
+		}
+		ds[d.Index], seen[d.Index] = d, true
+	}
+	for i, a := range info.RRA {
+		switch {
+		case !standardCF(a.CF):
+			return nil, fmt.Errorf("%w: archive %v consolidation function %v", rrd.ErrNotSupported, i, a.CF)
+		case a.Rows <= 0 || a.PDPPerRow <= 0:
+			return nil, fmt.Errorf("%w: archive %v: %v rows of %v pdps", rrd.ErrInvalidArg, i, a.Rows, a.PDPPerRow)
+		}
+	}
+
+	l := nativeLayout()
+	v, _ := parseVersion(version)
+	sec := l.sections(v, len(ds), len(info.RRA))
+	size := int64(sec.size)
+	for _, a := range info.RRA {
+		size += a.Rows * int64(len(ds)) * 8
+	}
+	h := header{layout: l, buf: make([]byte, size)}
+
+	h.putCString(0, len(cookie), cookie)
+	h.putCString(len(cookie), versionLen, version)
+	off := alignUp(len(cookie)+versionLen, l.align)
+	h.putDouble(off, floatCookie)
+	h.putULong(off+8, int64(len(ds)))
+	h.putULong(off+8+l.long, int64(len(info.RRA)))
+	h.putULong(off+8+2*l.long, step)
+
+	for i, d := range ds {
+		off := sec.ds + i*l.dsDefSize()
+		par := off + alignUp(2*nameSize, l.univalAlign())
+		h.putCString(off, nameSize, d.Name)
+		h.putCString(off+nameSize, nameSize, d.Type)
+		h.putULong(par, int64(d.MinimalHeartbeat/time.Second))
+		h.putDouble(par+univalSize, d.Min)
+		h.putDouble(par+2*univalSize, d.Max)
+	}
+
+	rraRows, rraPar, rraSize := l.rraDefOffsets()
+	for i, a := range info.RRA {
+		off := sec.rra + i*rraSize
+		h.putCString(off, nameSize, a.CF)
+		h.putULong(off+rraRows, a.Rows)
+		h.putULong(off+rraRows+l.long, a.PDPPerRow)
+		h.putDouble(off+rraPar, a.XFF)
+	}
+
+	for off := int64(sec.size); off < size; off += 8 {
+		h.putDouble(int(off), math.NaN())
+	}
+
+	// Initialise the state as rrdtool create does, treating the time
+	// before start as unknown.
+	f := &File{version: v, head: h, sec: sec, LastUpdate: start, DS: make([]DS, len(ds)), RRA: make([]RRA, len(info.RRA))}
+	last := start.Unix()
+	for i := range f.DS {
+		f.DS[i] = DS{LastDS: "U", UnknownSec: last % step}
+	}
+	for i, a := range info.RRA {
+		pdps := (last - last%step) % (step * a.PDPPerRow) / step
+		f.RRA[i] = RRA{CurRow: a.Rows - 1, CDPPrep: make([]CDPPrep, len(ds))}
+		for j := range f.RRA[i].CDPPrep {
+			f.RRA[i].CDPPrep[j] = CDPPrep{Value: math.NaN(), UnknownPDPs: pdps}
+		}
+	}
+	f.writeState()
+
+	return Parse(&buffer{b: h.buf})
+}
+
+// Create creates the RRD file path with the schema of info and no updates
+// since start, as New does, failing if it already exists. The returned
+// file can be updated and must be closed by the caller with the returned
+// io.Closer once finished with.
+func Create(path string, info *rrd.RRDInfo, start time.Time) (*File, io.Closer, error) {
+	f, err := New(info, start)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := f.WriteTo(file); err != nil {
+		file.Close() // nolint: errcheck
+		return nil, nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	f.r, f.w = file, file
+	return f, file, nil
+}
+
+// WriteTo writes the header and rows of f to w.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	size := f.HeaderSize
+	for _, a := range f.RRA {
+		size += a.Rows * int64(len(f.DS)) * 8
+	}
+	if _, err := w.Write(f.head.buf); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, io.NewSectionReader(f.r, f.HeaderSize, size-f.HeaderSize))
+	return f.HeaderSize + n, err
+}
+
+// standardCF returns true if cf is one of the standard consolidation
+// functions, whose archives can be created and updated.
+func standardCF(cf string) bool {
+	switch rrd.ConsolidationFunc(cf) {
+	case rrd.Average, rrd.Min, rrd.Max, rrd.Last:
+		return true
+	}
+	return false
+}
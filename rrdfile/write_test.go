@@ -0,0 +1,150 @@
+package rrdfile
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+)
+
+// testInfo returns the info of a RRD with a GAUGE and a COUNTER data source
+// with a minute step, an AVERAGE archive with 10 rows and a MAX archive with
+// 5 rows of 2 minutes.
+func testInfo(t *testing.T) *rrd.RRDInfo {
+	t.Helper()
+
+	info, err := rrd.NewCreateRRD(
+		[]rrd.DS{rrd.NewDS("DS:watts:GAUGE:120:U:U"), rrd.NewDS("DS:hits:COUNTER:120:0:U")},
+		[]rrd.RRA{rrd.NewAverage(0.5, 1, 10), rrd.NewMax(0.5, 2, 5)},
+		rrd.Step(time.Minute),
+	).Info()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return info
+}
+
+func TestNew(t *testing.T) {
+	f, err := New(testInfo(t), time.Unix(1000050, 0))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, f.HeaderSize+(10+5)*2*8, n)
+
+	f, err = Parse(bytes.NewReader(buf.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "0003", f.Version)
+	assert.Equal(t, time.Minute, f.Step)
+	assert.Equal(t, time.Unix(1000050, 0), f.LastUpdate)
+	assert.Equal(t, DS{
+		Name:             "hits",
+		Type:             rrd.Counter,
+		MinimalHeartbeat: time.Minute * 2,
+		Max:              math.Inf(1),
+		LastDS:           "U",
+		UnknownSec:       30,
+	}, withInf(f.DS[1]))
+	if assert.Len(t, f.RRA, 2) {
+		assert.Equal(t, int64(9), f.RRA[0].CurRow)
+		assert.Equal(t, int64(4), f.RRA[1].CurRow)
+		assert.Equal(t, 0.5, f.RRA[1].XFF)
+		// 1000020 is the second minute of a two minute row.
+		assert.Equal(t, int64(1), f.RRA[1].CDPPrep[0].UnknownPDPs)
+		assert.True(t, math.IsNaN(f.RRA[1].CDPPrep[0].Value))
+	}
+
+	rows, err := f.Rows(1)
+	if assert.NoError(t, err) {
+		for _, r := range rows.Rows {
+			assert.True(t, math.IsNaN(r.Values[0]))
+		}
+	}
+}
+
+// withInf returns d with NaN limits replaced with infinities, so it can be
+// compared with assert.Equal.
+func withInf(d DS) DS {
+	if math.IsNaN(d.Min) {
+		d.Min = math.Inf(-1)
+	}
+	if math.IsNaN(d.Max) {
+		d.Max = math.Inf(1)
+	}
+	return d
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(info *rrd.RRDInfo)
+		err    error
+	}{
+		{name: "step", modify: func(info *rrd.RRDInfo) { info.Step = 0 }, err: rrd.ErrInvalidArg},
+		{name: "no-ds", modify: func(info *rrd.RRDInfo) { info.DS = nil }, err: rrd.ErrNoDS},
+		{name: "no-rra", modify: func(info *rrd.RRDInfo) { info.RRA = nil }, err: rrd.ErrNoRRA},
+		{name: "index", modify: func(info *rrd.RRDInfo) {
+			d := info.DS["hits"]
+			d.Index = 0
+			info.DS["hits"] = d
+		}, err: rrd.ErrInvalidArg},
+		{name: "name", modify: func(info *rrd.RRDInfo) {
+			d := info.DS["hits"]
+			d.Name = "a_data_source_name_too_long"
+			info.DS["hits"] = d
+		}, err: rrd.ErrInvalidArg},
+		{name: "compute", modify: func(info *rrd.RRDInfo) {
+			d := info.DS["hits"]
+			d.Type = rrd.Compute
+			info.DS["hits"] = d
+		}, err: rrd.ErrNotSupported},
+		{name: "hw", modify: func(info *rrd.RRDInfo) { info.RRA[0].CF = "HWPREDICT" }, err: rrd.ErrNotSupported},
+		{name: "rows", modify: func(info *rrd.RRDInfo) { info.RRA[0].Rows = 0 }, err: rrd.ErrInvalidArg},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			info := testInfo(t)
+			tc.modify(info)
+			_, err := New(info, time.Unix(999990, 0))
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.rrd")
+	f, c, err := Create(path, testInfo(t), time.Unix(999960, 0))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, f.Update(sample(1000020, 10, 100)))
+	assert.NoError(t, c.Close())
+
+	f, c, err = Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	assert.Equal(t, time.Unix(1000020, 0), f.LastUpdate)
+	assert.Equal(t, "100", f.DS[1].LastDS)
+	assert.Error(t, f.Update(sample(1000080, 10, 100)))
+
+	_, _, err = Create(path, testInfo(t), time.Unix(999960, 0))
+	assert.ErrorIs(t, err, os.ErrExist)
+}
@@ -0,0 +1,396 @@
+// Package rrdgraph renders classic RRD graphs by running rrdtool graph with
+// --daemon pointing at a rrdcached server, so Go services can serve them.
+//
+// Graphs are described with typed elements, such as Def, Line and GPrint,
+// which are formatted as rrdtool graph arguments:
+//
+//	g := rrdgraph.New(
+//		rrdgraph.Def{Name: "watts", File: "power.rrd", DS: "watts", CF: rrd.Average},
+//		rrdgraph.VDef{Name: "peak", RPN: "watts,MAXIMUM"},
+//		rrdgraph.Line{Width: 2, Value: "watts", Color: "FF0000", Legend: "Power"},
+//		rrdgraph.GPrint{Value: "peak", Format: "Peak %6.2lf W"},
+//	)
+//	png, err := rrdgraph.Render(ctx, c, g)
+package rrdgraph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// Format is the format of a rendered graph.
+type Format string
+
+const (
+	PNG Format = "PNG"
+	SVG Format = "SVG"
+	PDF Format = "PDF"
+	EPS Format = "EPS"
+)
+
+// ContentType returns the MIME type of images in format f.
+func (f Format) ContentType() string {
+	switch f {
+	case SVG:
+		return "image/svg+xml"
+	case PDF:
+		return "application/pdf"
+	case EPS:
+		return "application/postscript"
+	}
+	return "image/png"
+}
+
+var (
+	// vnameRe matches valid rrdtool variable names.
+	vnameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,255}$`)
+
+	// colorRe matches valid rrdtool colors, RRGGBB with an optional alpha.
+	colorRe = regexp.MustCompile(`^[0-9a-fA-F]{6}([0-9a-fA-F]{2})?$`)
+
+	// escaper escapes colons, which separate the fields of elements.
+	escaper = strings.NewReplacer(":", `\:`)
+)
+
+// Element is an element of a graph, formatted by String as its rrdtool
+// graph argument.
+type Element interface {
+	fmt.Stringer
+
+	// validate returns an error if the element is invalid.
+	validate() error
+}
+
+// validName returns an ErrInvalidArg error if name isn't a valid variable
+// name for the element kind.
+func validName(kind, name string) error {
+	if !vnameRe.MatchString(name) {
+		return fmt.Errorf("%w: %v name %q", rrd.ErrInvalidArg, kind, name)
+	}
+	return nil
+}
+
+// validColor returns an ErrInvalidArg error if color isn't empty or a valid
+// color.
+func validColor(kind, color string) error {
+	if color != "" && !colorRe.MatchString(strings.TrimPrefix(color, "#")) {
+		return fmt.Errorf("%w: %v color %q", rrd.ErrInvalidArg, kind, color)
+	}
+	return nil
+}
+
+// Def fetches data source DS of the RRD File as the variable Name.
+type Def struct {
+	Name string
+	File string
+	DS   string
+	CF   rrd.ConsolidationFunc
+
+	// Step, Start and End optionally override those of the graph.
+	Step  time.Duration
+	Start time.Time
+	End   time.Time
+
+	// Reduce optionally sets the consolidation function used when the
+	// data has to be consolidated to fit the graph.
+	Reduce rrd.ConsolidationFunc
+}
+
+func (d Def) String() string {
+	s := "DEF:" + d.Name + "=" + escaper.Replace(d.File) + ":" + d.DS + ":" + string(d.CF)
+	if d.Step > 0 {
+		s += ":step=" + strconv.FormatInt(int64(d.Step/time.Second), 10)
+	}
+	if !d.Start.IsZero() {
+		s += ":start=" + strconv.FormatInt(d.Start.Unix(), 10)
+	}
+	if !d.End.IsZero() {
+		s += ":end=" + strconv.FormatInt(d.End.Unix(), 10)
+	}
+	if d.Reduce != "" {
+		s += ":reduce=" + string(d.Reduce)
+	}
+	return s
+}
+
+func (d Def) validate() error {
+	switch {
+	case d.File == "":
+		return fmt.Errorf("%w: DEF %v has no file", rrd.ErrInvalidArg, d.Name)
+	case !d.CF.Valid():
+		return fmt.Errorf("%w: DEF %v: %v", rrd.ErrInvalidCF, d.Name, d.CF)
+	case d.Reduce != "" && !d.Reduce.Valid():
+		return fmt.Errorf("%w: DEF %v reduce: %v", rrd.ErrInvalidCF, d.Name, d.Reduce)
+	}
+	if err := validName("DEF", d.Name); err != nil {
+		return err
+	}
+	return validName("DEF data source", d.DS)
+}
+
+// CDef computes the variable Name from the RPN expression, such as
+// "watts,1000,/".
+type CDef struct {
+	Name string
+	RPN  string
+}
+
+func (d CDef) String() string {
+	return "CDEF:" + d.Name + "=" + d.RPN
+}
+
+func (d CDef) validate() error {
+	if d.RPN == "" {
+		return fmt.Errorf("%w: CDEF %v has no expression", rrd.ErrInvalidArg, d.Name)
+	}
+	return validName("CDEF", d.Name)
+}
+
+// VDef computes the single value Name from the RPN expression, such as
+// "watts,MAXIMUM", for use by GPrint.
+type VDef struct {
+	Name string
+	RPN  string
+}
+
+func (d VDef) String() string {
+	return "VDEF:" + d.Name + "=" + d.RPN
+}
+
+func (d VDef) validate() error {
+	if d.RPN == "" {
+		return fmt.Errorf("%w: VDEF %v has no expression", rrd.ErrInvalidArg, d.Name)
+	}
+	return validName("VDEF", d.Name)
+}
+
+// Line draws the variable Value as a line of Width pixels, 1 if zero.
+// If Color is empty the line isn't drawn but is still used for stacking.
+type Line struct {
+	Width  float64
+	Value  string
+	Color  string
+	Legend string
+	Stack  bool
+}
+
+func (l Line) String() string {
+	w := l.Width
+	if w == 0 {
+		w = 1
+	}
+	return "LINE" + strconv.FormatFloat(w, 'f', -1, 64) + ":" + plot(l.Value, l.Color, l.Legend, l.Stack)
+}
+
+func (l Line) validate() error {
+	if l.Width < 0 {
+		return fmt.Errorf("%w: LINE %v width %v", rrd.ErrInvalidArg, l.Value, l.Width)
+	}
+	if err := validName("LINE", l.Value); err != nil {
+		return err
+	}
+	return validColor("LINE", l.Color)
+}
+
+// Area draws the area between the x axis and the variable Value.
+type Area struct {
+	Value  string
+	Color  string
+	Legend string
+	Stack  bool
+}
+
+func (a Area) String() string {
+	return "AREA:" + plot(a.Value, a.Color, a.Legend, a.Stack)
+}
+
+func (a Area) validate() error {
+	if err := validName("AREA", a.Value); err != nil {
+		return err
+	}
+	return validColor("AREA", a.Color)
+}
+
+// plot returns the common fields of the LINE and AREA elements.
+func plot(value, color, legend string, stack bool) string {
+	s := value
+	if color != "" {
+		s += "#" + strings.TrimPrefix(color, "#")
+	}
+	if legend != "" {
+		s += ":" + escaper.Replace(legend)
+	}
+	if stack {
+		if legend == "" {
+			s += ":"
+		}
+		s += ":STACK"
+	}
+	return s
+}
+
+// GPrint prints the VDef variable Value in the legend using the printf
+// style Format, such as "%6.2lf %S".
+type GPrint struct {
+	Value  string
+	Format string
+}
+
+func (p GPrint) String() string {
+	return "GPRINT:" + p.Value + ":" + escaper.Replace(p.Format)
+}
+
+func (p GPrint) validate() error {
+	if p.Format == "" {
+		return fmt.Errorf("%w: GPRINT %v has no format", rrd.ErrInvalidArg, p.Value)
+	}
+	return validName("GPRINT", p.Value)
+}
+
+// Comment prints Text in the legend.
+type Comment struct {
+	Text string
+}
+
+func (c Comment) String() string {
+	return "COMMENT:" + escaper.Replace(c.Text)
+}
+
+func (c Comment) validate() error {
+	return nil
+}
+
+// Raw is an element in rrdtool graph format, for elements which don't have
+// a type such as "HRULE:100#0000FF:Limit". It's used as is.
+type Raw string
+
+func (r Raw) String() string {
+	return string(r)
+}
+
+func (r Raw) validate() error {
+	if r == "" {
+		return fmt.Errorf("%w: empty element", rrd.ErrInvalidArg)
+	}
+	return nil
+}
+
+// Graph describes a graph.
+type Graph struct {
+	// Start and End are the time range of the graph, if zero rrdtool's
+	// defaults of a day ago and now are used.
+	Start time.Time
+	End   time.Time
+
+	// Width and Height are the size of the canvas in pixels, if zero
+	// rrdtool's defaults are used.
+	Width  int
+	Height int
+
+	Title         string
+	VerticalLabel string
+
+	// Format is the image format, PNG if empty.
+	Format Format
+
+	// Options are additional rrdtool graph options e.g. "--lower-limit", "0".
+	Options []string
+
+	Elements []Element
+}
+
+// New returns a new Graph of elements.
+func New(elements ...Element) *Graph {
+	return &Graph{Elements: elements}
+}
+
+// Add adds elements to g, returning g.
+func (g *Graph) Add(elements ...Element) *Graph {
+	g.Elements = append(g.Elements, elements...)
+	return g
+}
+
+// format returns the image format of g.
+func (g *Graph) format() Format {
+	if g.Format == "" {
+		return PNG
+	}
+	return g.Format
+}
+
+// Args returns the validated rrdtool graph arguments for g, excluding the
+// command and filename.
+func (g *Graph) Args() ([]string, error) {
+	switch {
+	case len(g.Elements) == 0:
+		return nil, fmt.Errorf("%w: graph has no elements", rrd.ErrInvalidArg)
+	case g.Width < 0 || g.Height < 0:
+		return nil, fmt.Errorf("%w: graph size %vx%v", rrd.ErrInvalidArg, g.Width, g.Height)
+	case !g.End.IsZero() && !g.Start.IsZero() && !g.End.After(g.Start):
+		return nil, fmt.Errorf("%w: graph end %v not after start %v", rrd.ErrInvalidArg, g.End, g.Start)
+	}
+
+	args := []string{"--imgformat", string(g.format())}
+	if !g.Start.IsZero() {
+		args = append(args, "--start", strconv.FormatInt(g.Start.Unix(), 10))
+	}
+	if !g.End.IsZero() {
+		args = append(args, "--end", strconv.FormatInt(g.End.Unix(), 10))
+	}
+	if g.Width > 0 {
+		args = append(args, "--width", strconv.Itoa(g.Width))
+	}
+	if g.Height > 0 {
+		args = append(args, "--height", strconv.Itoa(g.Height))
+	}
+	if g.Title != "" {
+		args = append(args, "--title", g.Title)
+	}
+	if g.VerticalLabel != "" {
+		args = append(args, "--vertical-label", g.VerticalLabel)
+	}
+	args = append(args, g.Options...)
+
+	for _, e := range g.Elements {
+		if e == nil {
+			return nil, fmt.Errorf("%w: nil element", rrd.ErrInvalidArg)
+		}
+		if err := e.validate(); err != nil {
+			return nil, err
+		}
+		args = append(args, e.String())
+	}
+	return args, nil
+}
+
+// Render renders g with rrdtool graph using the server of c, which flushes
+// the referenced RRDs first, returning the image.
+// The clients Executor must support binary output, which RRDToolPipe
+// doesn't except for SVG. rrdtool connects to the server directly, so
+// rrd.ErrNotSupported is returned if c uses TLS, a Proxy or a Dialer.
+func Render(ctx context.Context, c *rrd.Client, g *Graph) ([]byte, error) {
+	args, err := g.Args()
+	if err != nil {
+		return nil, err
+	}
+	daemon, err := c.DaemonAddr()
+	if err != nil {
+		return nil, fmt.Errorf("graph: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExecRRDTool(ctx, &rrd.RRDToolCmd{
+		Args:   append([]string{"graph", "-", "--daemon", daemon}, args...),
+		Stdout: &buf,
+	}); err != nil {
+		return nil, fmt.Errorf("graph: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,187 @@
+package rrdgraph
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+func TestElements(t *testing.T) {
+	tests := []struct {
+		name   string
+		e      Element
+		expect string
+		err    error
+	}{
+		{name: "def", e: Def{Name: "watts", File: "power.rrd", DS: "watts", CF: rrd.Average}, expect: "DEF:watts=power.rrd:watts:AVERAGE"},
+		{name: "def-options", e: Def{
+			Name:   "w",
+			File:   `c:\power.rrd`,
+			DS:     "watts",
+			CF:     rrd.Max,
+			Step:   time.Minute,
+			Start:  time.Unix(1000000, 0),
+			End:    time.Unix(1000600, 0),
+			Reduce: rrd.Average,
+		}, expect: `DEF:w=c\:\power.rrd:watts:MAX:step=60:start=1000000:end=1000600:reduce=AVERAGE`},
+		{name: "def-cf", e: Def{Name: "w", File: "power.rrd", DS: "watts", CF: "SUM"}, err: rrd.ErrInvalidCF},
+		{name: "def-name", e: Def{Name: "a b", File: "power.rrd", DS: "watts", CF: rrd.Average}, err: rrd.ErrInvalidArg},
+		{name: "def-file", e: Def{Name: "w", DS: "watts", CF: rrd.Average}, err: rrd.ErrInvalidArg},
+		{name: "cdef", e: CDef{Name: "kw", RPN: "watts,1000,/"}, expect: "CDEF:kw=watts,1000,/"},
+		{name: "cdef-rpn", e: CDef{Name: "kw"}, err: rrd.ErrInvalidArg},
+		{name: "vdef", e: VDef{Name: "peak", RPN: "watts,MAXIMUM"}, expect: "VDEF:peak=watts,MAXIMUM"},
+		{name: "line", e: Line{Value: "watts", Color: "#FF0000", Legend: "Power: W"}, expect: `LINE1:watts#FF0000:Power\: W`},
+		{name: "line-width", e: Line{Width: 1.5, Value: "watts", Color: "FF000080", Stack: true}, expect: "LINE1.5:watts#FF000080::STACK"},
+		{name: "line-color", e: Line{Value: "watts", Color: "red"}, err: rrd.ErrInvalidArg},
+		{name: "area", e: Area{Value: "watts", Color: "00FF00", Legend: "Power", Stack: true}, expect: "AREA:watts#00FF00:Power:STACK"},
+		{name: "area-value", e: Area{Color: "00FF00"}, err: rrd.ErrInvalidArg},
+		{name: "gprint", e: GPrint{Value: "peak", Format: "Peak: %6.2lf %S"}, expect: `GPRINT:peak:Peak\: %6.2lf %S`},
+		{name: "gprint-format", e: GPrint{Value: "peak"}, err: rrd.ErrInvalidArg},
+		{name: "comment", e: Comment{Text: "Updated 12:00"}, expect: `COMMENT:Updated 12\:00`},
+		{name: "raw", e: Raw("HRULE:100#0000FF:Limit"), expect: "HRULE:100#0000FF:Limit"},
+		{name: "raw-empty", e: Raw(""), err: rrd.ErrInvalidArg},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.e.validate()
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, tc.e.String())
+		})
+	}
+}
+
+func TestGraphArgs(t *testing.T) {
+	g := New(Def{Name: "watts", File: "power.rrd", DS: "watts", CF: rrd.Average}).
+		Add(Line{Width: 2, Value: "watts", Color: "FF0000", Legend: "Power"})
+	g.Start = time.Unix(1000000, 0)
+	g.End = time.Unix(1086400, 0)
+	g.Width, g.Height = 800, 200
+	g.Title = "Power usage"
+	g.VerticalLabel = "W"
+	g.Format = SVG
+	g.Options = []string{"--lower-limit", "0"}
+
+	args, err := g.Args()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"--imgformat", "SVG",
+			"--start", "1000000",
+			"--end", "1086400",
+			"--width", "800",
+			"--height", "200",
+			"--title", "Power usage",
+			"--vertical-label", "W",
+			"--lower-limit", "0",
+			"DEF:watts=power.rrd:watts:AVERAGE",
+			"LINE2:watts#FF0000:Power",
+		}, args)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(g *Graph)
+	}{
+		{name: "no-elements", modify: func(g *Graph) { g.Elements = nil }},
+		{name: "size", modify: func(g *Graph) { g.Width = -1 }},
+		{name: "range", modify: func(g *Graph) { g.End = g.Start }},
+		{name: "nil", modify: func(g *Graph) { g.Add(nil) }},
+		{name: "element", modify: func(g *Graph) { g.Add(CDef{Name: "x"}) }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := New(CDef{Name: "x", RPN: "1"})
+			g.Start = time.Unix(1000000, 0)
+			tc.modify(g)
+			_, err := g.Args()
+			assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+		})
+	}
+}
+
+func TestFormatContentType(t *testing.T) {
+	assert.Equal(t, "image/png", Format("").ContentType())
+	assert.Equal(t, "image/png", PNG.ContentType())
+	assert.Equal(t, "image/svg+xml", SVG.ContentType())
+	assert.Equal(t, "application/pdf", PDF.ContentType())
+}
+
+// testExecutor is a rrd.Executor which records the commands it runs and
+// writes out to their stdout.
+type testExecutor struct {
+	cmds []*rrd.RRDToolCmd
+	out  string
+	err  error
+}
+
+func (e *testExecutor) Exec(_ context.Context, cmd *rrd.RRDToolCmd) error {
+	e.cmds = append(e.cmds, cmd)
+	if e.err != nil {
+		return e.err
+	}
+	_, err := io.WriteString(cmd.Stdout, e.out)
+	return err
+}
+
+func TestRender(t *testing.T) {
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	e := &testExecutor{out: "\x89PNG"}
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2), rrd.RRDToolExecutor(e))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	g := New(Def{Name: "watts", File: "power.rrd", DS: "watts", CF: rrd.Average}, Area{Value: "watts", Color: "00FF00"})
+	img, err := Render(context.Background(), c, g)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("\x89PNG"), img)
+		if assert.Len(t, e.cmds, 1) {
+			assert.Equal(t, []string{
+				"graph", "-", "--daemon", s.Addr, "--imgformat", "PNG",
+				"DEF:watts=power.rrd:watts:AVERAGE", "AREA:watts#00FF00",
+			}, e.cmds[0].Args)
+		}
+	}
+
+	e.err = errors.New("failed")
+	_, err = Render(context.Background(), c, g)
+	assert.ErrorIs(t, err, e.err)
+
+	_, err = Render(context.Background(), c, New())
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+
+	// rrdtool would bypass the clients dialer.
+	var d net.Dialer
+	dc, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2), rrd.RRDToolExecutor(e), rrd.Dialer(d.DialContext))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, dc.Close())
+	}()
+	_, err = Render(context.Background(), dc, g)
+	assert.ErrorIs(t, err, rrd.ErrNotSupported)
+	assert.Len(t, e.cmds, 2)
+}
@@ -0,0 +1,477 @@
+package rrdgraphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// errPickle is returned when a pickle can't be decoded.
+var errPickle = errors.New("invalid pickle")
+
+// Pickle opcodes used by the Graphite pickle protocol, which sends lists of
+// (path, (timestamp, value)) tuples.
+const (
+	opMark            = '('
+	opStop            = '.'
+	opPop             = '0'
+	opNone            = 'N'
+	opInt             = 'I'
+	opBinInt          = 'J'
+	opBinInt1         = 'K'
+	opBinInt2         = 'M'
+	opLong            = 'L'
+	opFloat           = 'F'
+	opBinFloat        = 'G'
+	opString          = 'S'
+	opBinString       = 'T'
+	opShortBinString  = 'U'
+	opUnicode         = 'V'
+	opBinUnicode      = 'X'
+	opBinBytes        = 'B'
+	opShortBinBytes   = 'C'
+	opEmptyList       = ']'
+	opAppend          = 'a'
+	opAppends         = 'e'
+	opList            = 'l'
+	opEmptyTuple      = ')'
+	opTuple           = 't'
+	opGet             = 'g'
+	opBinGet          = 'h'
+	opLongBinGet      = 'j'
+	opPut             = 'p'
+	opBinPut          = 'q'
+	opLongBinPut      = 'r'
+	opProto           = 0x80
+	opTuple1          = 0x85
+	opTuple2          = 0x86
+	opTuple3          = 0x87
+	opNewTrue         = 0x88
+	opNewFalse        = 0x89
+	opLong1           = 0x8a
+	opShortBinUnicode = 0x8c
+	opMemoize         = 0x94
+	opFrame           = 0x95
+)
+
+// pickleList is a list being built by the unpickler, which is a pointer so
+// appends are visible through references held by the memo.
+type pickleList struct {
+	items []interface{}
+}
+
+// mark is pushed onto the stack by the MARK opcode.
+type mark struct{}
+
+// unpickler decodes the subset of the Python pickle format, protocols 0 to
+// 4, needed for lists and tuples of strings and numbers.
+type unpickler struct {
+	data  []byte
+	pos   int
+	stack []interface{}
+	memo  map[int]interface{}
+
+	// unwrapped is the number of items converted by unwrap.
+	unwrapped int
+}
+
+// unpickle decodes data, returning lists and tuples as []interface{},
+// strings as string, integers as int64 and floats as float64.
+func unpickle(data []byte) (interface{}, error) {
+	u := &unpickler{data: data, memo: make(map[int]interface{})}
+	v, err := u.run()
+	if err == nil {
+		v, err = u.unwrap(v, make(map[*pickleList]bool))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPickle, err)
+	}
+	return v, nil
+}
+
+// unwrap converts pickleLists in v to slices. The memo allows a list to
+// contain itself, which is rejected along with more items than there are
+// bytes of data, which is only possible by referencing lists or tuples many
+// times. active are the lists being converted.
+func (u *unpickler) unwrap(v interface{}, active map[*pickleList]bool) (interface{}, error) {
+	switch v := v.(type) {
+	case *pickleList:
+		if active[v] {
+			return nil, errors.New("recursive list")
+		}
+		active[v] = true
+		defer delete(active, v)
+		return u.unwrap(v.items, active)
+	case []interface{}:
+		u.unwrapped += len(v)
+		if u.unwrapped > len(u.data) {
+			return nil, errors.New("too many items")
+		}
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if items[i], err = u.unwrap(item, active); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return v, nil
+	}
+}
+
+func (u *unpickler) run() (interface{}, error) {
+	for {
+		op, err := u.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case opStop:
+			return u.pop()
+		case opProto:
+			_, err = u.read(1)
+		case opFrame:
+			_, err = u.read(8)
+		case opMark:
+			u.push(mark{})
+		case opPop:
+			_, err = u.pop()
+		case opNone:
+			u.push(nil)
+		case opNewTrue:
+			u.push(true)
+		case opNewFalse:
+			u.push(false)
+		case opInt, opLong:
+			err = u.pushInt(op)
+		case opBinInt:
+			err = u.pushUint(4, true)
+		case opBinInt1:
+			err = u.pushUint(1, false)
+		case opBinInt2:
+			err = u.pushUint(2, false)
+		case opLong1:
+			err = u.pushLong1()
+		case opFloat:
+			err = u.pushFloat()
+		case opBinFloat:
+			var b []byte
+			if b, err = u.read(8); err == nil {
+				u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+			}
+		case opString:
+			err = u.pushQuoted()
+		case opUnicode:
+			var s string
+			if s, err = u.line(); err == nil {
+				u.push(s)
+			}
+		case opShortBinString, opShortBinBytes, opShortBinUnicode:
+			err = u.pushString(1)
+		case opBinString, opBinBytes, opBinUnicode:
+			err = u.pushString(4)
+		case opEmptyList:
+			u.push(&pickleList{})
+		case opEmptyTuple:
+			u.push([]interface{}{})
+		case opList:
+			var items []interface{}
+			if items, err = u.popMark(); err == nil {
+				u.push(&pickleList{items: items})
+			}
+		case opTuple:
+			var items []interface{}
+			if items, err = u.popMark(); err == nil {
+				u.push(items)
+			}
+		case opTuple1, opTuple2, opTuple3:
+			err = u.pushTuple(int(op-opTuple1) + 1)
+		case opAppend:
+			err = u.append(false)
+		case opAppends:
+			err = u.append(true)
+		case opGet:
+			err = u.get(0)
+		case opBinGet:
+			err = u.get(1)
+		case opLongBinGet:
+			err = u.get(4)
+		case opPut:
+			err = u.put(0)
+		case opBinPut:
+			err = u.put(1)
+		case opLongBinPut:
+			err = u.put(4)
+		case opMemoize:
+			err = u.memoize(len(u.memo))
+		default:
+			return nil, fmt.Errorf("unsupported opcode 0x%02x at %v", op, u.pos-1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (u *unpickler) push(v interface{}) {
+	u.stack = append(u.stack, v)
+}
+
+func (u *unpickler) pop() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, errors.New("stack underflow")
+	}
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+	return v, nil
+}
+
+// popMark pops the items pushed since the last mark.
+func (u *unpickler) popMark() ([]interface{}, error) {
+	for i := len(u.stack) - 1; i >= 0; i-- {
+		if _, ok := u.stack[i].(mark); ok {
+			items := append([]interface{}{}, u.stack[i+1:]...)
+			u.stack = u.stack[:i]
+			return items, nil
+		}
+	}
+	return nil, errors.New("mark not found")
+}
+
+func (u *unpickler) byte() (byte, error) {
+	b, err := u.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (u *unpickler) read(n int) ([]byte, error) {
+	if n < 0 || len(u.data)-u.pos < n {
+		return nil, errors.New("unexpected end of data")
+	}
+	b := u.data[u.pos : u.pos+n]
+	u.pos += n
+	return b, nil
+}
+
+// line reads up to the next newline, which is discarded.
+func (u *unpickler) line() (string, error) {
+	i := bytes.IndexByte(u.data[u.pos:], '\n')
+	if i < 0 {
+		return "", errors.New("unterminated line")
+	}
+	s := string(u.data[u.pos : u.pos+i])
+	u.pos += i + 1
+	return s, nil
+}
+
+// size reads an unsigned little endian integer of n bytes.
+func (u *unpickler) size(n int) (int, error) {
+	b, err := u.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	if v > math.MaxInt32 {
+		return 0, fmt.Errorf("size %v too large", v)
+	}
+	return int(v), nil
+}
+
+func (u *unpickler) pushInt(op byte) error {
+	s, err := u.line()
+	if err != nil {
+		return err
+	}
+	if op == opLong {
+		s = strings.TrimSuffix(s, "L")
+	}
+	switch s {
+	case "00":
+		u.push(false)
+		return nil
+	case "01":
+		u.push(true)
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	u.push(v)
+	return nil
+}
+
+func (u *unpickler) pushUint(n int, signed bool) error {
+	b, err := u.read(n)
+	if err != nil {
+		return err
+	}
+	switch n {
+	case 1:
+		u.push(int64(b[0]))
+	case 2:
+		u.push(int64(binary.LittleEndian.Uint16(b)))
+	default:
+		v := binary.LittleEndian.Uint32(b)
+		if signed {
+			u.push(int64(int32(v)))
+		} else {
+			u.push(int64(v))
+		}
+	}
+	return nil
+}
+
+// pushLong1 decodes a little endian two's complement integer.
+func (u *unpickler) pushLong1() error {
+	n, err := u.size(1)
+	if err != nil {
+		return err
+	}
+	if n > 8 {
+		return fmt.Errorf("long of %v bytes too large", n)
+	}
+	b, err := u.read(n)
+	if err != nil {
+		return err
+	}
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	if n > 0 && n < 8 && b[n-1]&0x80 != 0 {
+		v |= math.MaxUint64 << (8 * n)
+	}
+	u.push(int64(v))
+	return nil
+}
+
+func (u *unpickler) pushFloat() error {
+	s, err := u.line()
+	if err != nil {
+		return err
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	u.push(v)
+	return nil
+}
+
+// pushQuoted decodes a quoted protocol 0 string.
+func (u *unpickler) pushQuoted() error {
+	s, err := u.line()
+	if err != nil {
+		return err
+	}
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return fmt.Errorf("invalid string %q", s)
+	}
+	u.push(s[1 : len(s)-1])
+	return nil
+}
+
+// pushString decodes a string prefixed by its length of n bytes.
+func (u *unpickler) pushString(n int) error {
+	l, err := u.size(n)
+	if err != nil {
+		return err
+	}
+	b, err := u.read(l)
+	if err != nil {
+		return err
+	}
+	u.push(string(b))
+	return nil
+}
+
+func (u *unpickler) pushTuple(n int) error {
+	if len(u.stack) < n {
+		return errors.New("stack underflow")
+	}
+	items := append([]interface{}{}, u.stack[len(u.stack)-n:]...)
+	u.stack = u.stack[:len(u.stack)-n]
+	u.push(items)
+	return nil
+}
+
+// append appends the top item, or those since the last mark if many is
+// true, to the list below them.
+func (u *unpickler) append(many bool) error {
+	var items []interface{}
+	if many {
+		var err error
+		if items, err = u.popMark(); err != nil {
+			return err
+		}
+	} else {
+		v, err := u.pop()
+		if err != nil {
+			return err
+		}
+		items = []interface{}{v}
+	}
+
+	if len(u.stack) == 0 {
+		return errors.New("stack underflow")
+	}
+	l, ok := u.stack[len(u.stack)-1].(*pickleList)
+	if !ok {
+		return fmt.Errorf("append to %T", u.stack[len(u.stack)-1])
+	}
+	l.items = append(l.items, items...)
+	return nil
+}
+
+// index reads a memo index of n bytes, or a decimal line if n is 0.
+func (u *unpickler) index(n int) (int, error) {
+	if n > 0 {
+		return u.size(n)
+	}
+	s, err := u.line()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(s)
+}
+
+func (u *unpickler) get(n int) error {
+	i, err := u.index(n)
+	if err != nil {
+		return err
+	}
+	v, ok := u.memo[i]
+	if !ok {
+		return fmt.Errorf("memo %v not found", i)
+	}
+	u.push(v)
+	return nil
+}
+
+func (u *unpickler) put(n int) error {
+	i, err := u.index(n)
+	if err != nil {
+		return err
+	}
+	return u.memoize(i)
+}
+
+func (u *unpickler) memoize(i int) error {
+	if len(u.stack) == 0 {
+		return errors.New("stack underflow")
+	}
+	u.memo[i] = u.stack[len(u.stack)-1]
+	return nil
+}
@@ -0,0 +1,71 @@
+package rrdgraphite
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPickles are pickle.dumps of testMetrics using various protocols.
+var testPickles = map[string]string{
+	"protocol-0": hex.EncodeToString([]byte("(lp0\n(Va.b\np1\n(I1500000000\nF1.5\ntp2\ntp3\na(Vc\np4\n(F1500000060.0\nI2\ntp5\ntp6\na.")),
+	"protocol-2": "80025d7100285803000000612e6271014a002f6859473ff800000000000086710286710358010000006371044741d65a0bcf0000004b02867105867106652e",
+	"protocol-4": "80049530000000000000005d94288c03612e62944a002f6859473ff8000000000000869486948c0163944741d65a0bcf0000004b0286948694652e",
+}
+
+// testMetrics is [("a.b", (1500000000, 1.5)), ("c", (1500000060.0, 2))].
+var testMetrics = []interface{}{
+	[]interface{}{"a.b", []interface{}{int64(1500000000), 1.5}},
+	[]interface{}{"c", []interface{}{1500000060.0, int64(2)}},
+}
+
+func TestUnpickle(t *testing.T) {
+	for name, data := range testPickles {
+		t.Run(name, func(t *testing.T) {
+			b, err := hex.DecodeString(data)
+			if !assert.NoError(t, err) {
+				return
+			}
+			v, err := unpickle(b)
+			assert.NoError(t, err)
+			assert.Equal(t, testMetrics, v)
+		})
+	}
+
+	t.Run("types", func(t *testing.T) {
+		// [(-1, 2**40, -300, True, None, 'x')]
+		b, err := hex.DecodeString("80025d7100284affffffff8a060000000000014ad4feffff884e5801000000787101747102612e")
+		if !assert.NoError(t, err) {
+			return
+		}
+		v, err := unpickle(b)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{
+			[]interface{}{int64(-1), int64(1 << 40), int64(-300), true, nil, "x"},
+		}, v)
+	})
+}
+
+func TestUnpickleInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":       {},
+		"no-stop":     {']'},
+		"underflow":   {'.'},
+		"unsupported": {'}', '.'},
+		"short":       {'X', 10, 0, 0, 0, 'a', '.'},
+		"no-mark":     {'t', '.'},
+		"memo":        {'h', 1, '.'},
+		"append":      {'N', 'N', 'a', '.'},
+		"recursive":   []byte("]p0\ng0\na."),
+		"tuple-cycle": []byte("]p0\ng0\n\x85a."),
+		// l = []; for _ in range(8): l = (l, l)
+		"references": []byte("]p0\ng0\ng0\n\x86p0\ng0\n\x86p0\ng0\n\x86p0\ng0\n\x86p0\ng0\n\x86p0\ng0\n\x86p0\ng0\n\x86p0\ng0\n\x86."),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := unpickle(data)
+			assert.ErrorIs(t, err, errPickle)
+		})
+	}
+}
@@ -0,0 +1,492 @@
+// Package rrdgraphite ingests metrics sent with the Graphite plaintext and
+// pickle protocols into RRDs via rrdcached, allowing existing collectors
+// such as collectd, statsd and Diamond to write to RRDs.
+//
+// Each metric path is mapped to an RRD filename by the first matching
+// template, RRDs are created on first use and samples are written through
+// an rrd.AsyncWriter.
+package rrdgraphite
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+const (
+	// DefaultMaxPickleSize is the default maximum size of a pickle message.
+	DefaultMaxPickleSize = 1 << 20
+
+	// maxLineSize is the maximum length of a plaintext line.
+	maxLineSize = 1 << 16
+
+	// maxDatagramSize is the maximum size of a UDP datagram.
+	maxDatagramSize = 1 << 16
+)
+
+var (
+	// ErrServerClosed is returned by the Serve methods after the server has
+	// been closed.
+	ErrServerClosed = errors.New("rrdgraphite: server closed")
+
+	// ErrInvalidMetric is returned for metrics which can't be mapped to an
+	// RRD filename.
+	ErrInvalidMetric = errors.New("invalid metric")
+)
+
+// DefaultDefinition returns the definition of RRDs created for new metrics
+// by default, a single GAUGE data source with a 60 second step, keeping a
+// day of minutes, a week of five minutes and a year of hours.
+func DefaultDefinition() *rrd.CreateRRD {
+	return rrd.NewCreateRRD(
+		[]rrd.DS{"DS:value:GAUGE:120:U:U"},
+		[]rrd.RRA{
+			"RRA:AVERAGE:0.5:1:1440",
+			"RRA:AVERAGE:0.5:5:2016",
+			"RRA:AVERAGE:0.5:60:8760",
+		},
+	).WithStep(time.Minute)
+}
+
+// Template adds a text/template which maps metrics whose leading nodes
+// match pattern, dot separated path.Match globs such as servers.*.cpu, to
+// RRD filenames. Templates are executed with a Metric and can use the
+// functions join, replace and lower. The first matching template is used,
+// with DefaultTemplate used for metrics which match none.
+func Template(pattern, text string) func(*Server) error {
+	return func(s *Server) error {
+		t, err := newTemplate(pattern, text)
+		if err != nil {
+			return err
+		}
+		s.templates = append(s.templates, t)
+		return nil
+	}
+}
+
+// Definition sets the definition of RRDs created for new metrics, which
+// defaults to DefaultDefinition. It must have a single data source.
+func Definition(def *rrd.CreateRRD) func(*Server) error {
+	return func(s *Server) error {
+		if def == nil {
+			return fmt.Errorf("%w: nil definition", rrd.ErrInvalidArg)
+		}
+		if len(def.DS) != 1 {
+			return fmt.Errorf("%w: definition has %v data sources", rrd.ErrInvalidArg, len(def.DS))
+		}
+		if err := def.Validate(); err != nil {
+			return err
+		}
+		s.def = def
+		return nil
+	}
+}
+
+// NoCreate disables the creation of RRDs for new metrics, samples for
+// RRDs which don't exist are dropped by rrdcached.
+func NoCreate(s *Server) error {
+	s.create = false
+	return nil
+}
+
+// Logger sets the logger used to report invalid metrics and failures,
+// which defaults to slog.Default.
+func Logger(l *slog.Logger) func(*Server) error {
+	return func(s *Server) error {
+		if l == nil {
+			return rrd.ErrNilOption
+		}
+		s.logger = l
+		return nil
+	}
+}
+
+// MaxPickleSize sets the maximum size of a pickle message, which defaults
+// to DefaultMaxPickleSize.
+func MaxPickleSize(n int) func(*Server) error {
+	return func(s *Server) error {
+		if n < 1 {
+			return fmt.Errorf("%w: max pickle size %v", rrd.ErrInvalidArg, n)
+		}
+		s.maxPickleSize = n
+		return nil
+	}
+}
+
+// WriterOptions sets the options of the servers rrd.AsyncWriter.
+func WriterOptions(opts ...func(*rrd.AsyncWriter) error) func(*Server) error {
+	return func(s *Server) error {
+		s.writerOpts = append(s.writerOpts, opts...)
+		return nil
+	}
+}
+
+// Server receives Graphite metrics and writes them to RRDs.
+// A Server is safe for concurrent use.
+type Server struct {
+	c             *rrd.Client
+	w             *rrd.AsyncWriter
+	templates     []*filenameTemplate
+	def           *rrd.CreateRRD
+	create        bool
+	logger        *slog.Logger
+	maxPickleSize int
+	writerOpts    []func(*rrd.AsyncWriter) error
+	now           func() time.Time
+
+	m       sync.Mutex
+	created map[string]struct{}
+	closers map[io.Closer]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewServer returns a new Server which creates and updates RRDs with c.
+// The server must be closed with Close to send its pending samples.
+func NewServer(c *rrd.Client, opts ...func(*Server) error) (*Server, error) {
+	s := &Server{
+		c:             c,
+		def:           DefaultDefinition(),
+		create:        true,
+		logger:        slog.Default(),
+		maxPickleSize: DefaultMaxPickleSize,
+		now:           time.Now,
+		created:       make(map[string]struct{}),
+		closers:       make(map[io.Closer]struct{}),
+	}
+	for _, o := range opts {
+		if o == nil {
+			return nil, rrd.ErrNilOption
+		}
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+
+	def, err := newTemplate("", DefaultTemplate)
+	if err != nil {
+		return nil, err
+	}
+	s.templates = append(s.templates, def)
+
+	if s.w, err = rrd.NewAsyncWriter(c, s.writerOpts...); err != nil {
+		return nil, err
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+// Filename returns the RRD filename metric is stored in.
+func (s *Server) Filename(metric string) (string, error) {
+	m, err := parseMetric(metric)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range s.templates {
+		if t.match(m.Nodes) {
+			return t.execute(m)
+		}
+	}
+	// Unreachable as the default template matches all metrics.
+	return "", fmt.Errorf("%w: %v: no matching template", ErrInvalidMetric, metric)
+}
+
+// Write queues the value of metric at t to be written, creating its RRD
+// if it hasn't been seen before.
+func (s *Server) Write(ctx context.Context, metric string, value float64, t time.Time) error {
+	filename, err := s.Filename(metric)
+	if err != nil {
+		return err
+	}
+	if err := s.ensure(ctx, filename); err != nil {
+		return err
+	}
+	return s.w.Enqueue(filename, rrd.Sample{Time: t, Values: []float64{value}})
+}
+
+// ensure creates filename if it hasn't been seen before.
+func (s *Server) ensure(ctx context.Context, filename string) error {
+	if !s.create {
+		return nil
+	}
+
+	s.m.Lock()
+	_, ok := s.created[filename]
+	s.m.Unlock()
+	if ok {
+		return nil
+	}
+
+	def := *s.def
+	def.Options = append(append([]rrd.CreateOption{}, def.Options...), rrd.NoOverwrite())
+	if err := s.c.CreateFromWithContext(ctx, filename, &def); err != nil && !rrd.IsExist(err) {
+		return fmt.Errorf("create %v: %w", filename, err)
+	}
+
+	s.m.Lock()
+	s.created[filename] = struct{}{}
+	s.m.Unlock()
+	return nil
+}
+
+// parseLine parses a plaintext line of the form "metric value timestamp",
+// where a timestamp of -1 is the current time.
+func (s *Server) parseLine(line string) (string, float64, time.Time, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return "", 0, time.Time{}, fmt.Errorf("%w: %q: expected 3 fields", ErrInvalidMetric, line)
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return "", 0, time.Time{}, fmt.Errorf("%w: %q: value: %v", ErrInvalidMetric, line, err)
+	}
+
+	ts, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return "", 0, time.Time{}, fmt.Errorf("%w: %q: timestamp: %v", ErrInvalidMetric, line, err)
+	}
+	return fields[0], value, s.timestamp(ts), nil
+}
+
+// timestamp returns the time of the unix timestamp ts, or the current time
+// if ts is negative.
+func (s *Server) timestamp(ts float64) time.Time {
+	if ts < 0 {
+		return s.now()
+	}
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// writeLine writes the metric of a plaintext line, logging failures.
+func (s *Server) writeLine(line string, remote net.Addr) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	metric, value, t, err := s.parseLine(line)
+	if err == nil {
+		err = s.Write(s.ctx, metric, value, t)
+	}
+	if err != nil {
+		s.logger.Warn("write failed", "remote", remote, "error", err)
+	}
+}
+
+// track registers c to be closed by Close, returning false if the server
+// is already closed.
+func (s *Server) track(c io.Closer) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return false
+	}
+	s.closers[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// untrack reverses track, returning true if the server has been closed.
+func (s *Server) untrack(c io.Closer) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	delete(s.closers, c)
+	s.wg.Done()
+	return s.closed
+}
+
+// ServeTCP accepts plaintext connections on l until the server is closed
+// when it returns ErrServerClosed.
+func (s *Server) ServeTCP(l net.Listener) error {
+	return s.serve(l, s.serveLines)
+}
+
+// ServePickle accepts pickle connections on l until the server is closed
+// when it returns ErrServerClosed.
+func (s *Server) ServePickle(l net.Listener) error {
+	return s.serve(l, s.servePickle)
+}
+
+// serve accepts connections on l, processing each with f in a new goroutine.
+func (s *Server) serve(l net.Listener, f func(conn net.Conn) error) error {
+	if !s.track(l) {
+		l.Close() // nolint: errcheck
+		return ErrServerClosed
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.untrack(l) {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		if !s.track(conn) {
+			conn.Close() // nolint: errcheck
+			s.untrack(l)
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.untrack(conn)
+			defer conn.Close() // nolint: errcheck
+
+			if err := f(conn); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Warn("read failed", "remote", conn.RemoteAddr(), "error", err)
+			}
+		}()
+	}
+}
+
+// serveLines writes the metrics of plaintext lines read from conn.
+func (s *Server) serveLines(conn net.Conn) error {
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 4096), maxLineSize)
+	for sc.Scan() {
+		s.writeLine(sc.Text(), conn.RemoteAddr())
+	}
+	return sc.Err()
+}
+
+// servePickle writes the metrics of length prefixed pickle messages read
+// from conn.
+func (s *Server) servePickle(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	var hdr [4]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return err
+		}
+		n := binary.BigEndian.Uint32(hdr[:])
+		if n > uint32(s.maxPickleSize) {
+			return fmt.Errorf("pickle of %v bytes exceeds limit of %v", n, s.maxPickleSize)
+		}
+
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+
+		if err := s.writePickle(data); err != nil {
+			return err
+		}
+	}
+}
+
+// writePickle writes the metrics of a pickled list of
+// (metric, (timestamp, value)) tuples, logging those which fail.
+func (s *Server) writePickle(data []byte) error {
+	v, err := unpickle(data)
+	if err != nil {
+		return err
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("%w: expected list got %T", errPickle, v)
+	}
+
+	for _, item := range items {
+		metric, value, t, err := s.pickleMetric(item)
+		if err == nil {
+			err = s.Write(s.ctx, metric, value, t)
+		}
+		if err != nil {
+			s.logger.Warn("write failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// pickleMetric returns the metric, value and time of a pickled
+// (metric, (timestamp, value)) tuple.
+func (s *Server) pickleMetric(item interface{}) (string, float64, time.Time, error) {
+	t, ok := item.([]interface{})
+	if ok && len(t) == 2 {
+		metric, ok1 := t[0].(string)
+		point, ok2 := t[1].([]interface{})
+		if ok1 && ok2 && len(point) == 2 {
+			ts, ok1 := number(point[0])
+			value, ok2 := number(point[1])
+			if ok1 && ok2 {
+				return metric, value, s.timestamp(ts), nil
+			}
+		}
+	}
+	return "", 0, time.Time{}, fmt.Errorf("%w: invalid pickled metric %v", ErrInvalidMetric, item)
+}
+
+// number returns v as a float64 if it's a number or numeric string.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ServeUDP writes the metrics of the plaintext lines of datagrams read from
+// conn until the server is closed when it returns ErrServerClosed.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	if !s.track(conn) {
+		conn.Close() // nolint: errcheck
+		return ErrServerClosed
+	}
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.untrack(conn) {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			s.writeLine(line, addr)
+		}
+	}
+}
+
+// Close stops the server, closing its listeners and connections, and then
+// closes its writer which sends the pending samples.
+func (s *Server) Close() error {
+	s.m.Lock()
+	s.closed = true
+	s.cancel()
+	var errs []error
+	for c := range s.closers {
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	s.m.Unlock()
+
+	s.wg.Wait()
+	if err := s.w.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
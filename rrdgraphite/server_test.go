@@ -0,0 +1,237 @@
+package rrdgraphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+// newTestServer returns a Server which writes to a new rrdtest.Server.
+func newTestServer(t *testing.T, opts ...func(*Server) error) (*Server, *rrdtest.Server) {
+	t.Helper()
+
+	rs, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, rs.Close())
+	})
+
+	c, err := rrd.NewClient(rs.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	s, err := NewServer(c, opts...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s.now = func() time.Time { return time.Unix(1500000300, 0) }
+	return s, rs
+}
+
+// waitCreated waits for n RRDs to have been created.
+func waitCreated(t *testing.T, s *Server, n int) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		s.m.Lock()
+		defer s.m.Unlock()
+		return len(s.created) == n
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestServerOptions(t *testing.T) {
+	_, err := NewServer(nil, nil)
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+	_, err = NewServer(nil, Logger(nil))
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+	_, err = NewServer(nil, MaxPickleSize(0))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+	_, err = NewServer(nil, Definition(nil))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+	_, err = NewServer(nil, Definition(rrd.NewCreateRRD(nil, nil)))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+	_, err = NewServer(nil, Definition(rrd.NewCreateRRD([]rrd.DS{"DS:a:GAUGE:120:U:U"}, nil)))
+	assert.ErrorIs(t, err, rrd.ErrNoRRA)
+	_, err = NewServer(nil, WriterOptions(rrd.AsyncMaxPending(0)))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+}
+
+func TestParseLine(t *testing.T) {
+	s := &Server{now: func() time.Time { return time.Unix(1500000300, 0) }}
+	tests := []struct {
+		line   string
+		metric string
+		value  float64
+		time   time.Time
+		err    bool
+	}{
+		{"a.b 1.5 1500000000", "a.b", 1.5, time.Unix(1500000000, 0), false},
+		{"a.b  -2\t1500000000.5", "a.b", -2, time.Unix(1500000000, 5e8), false},
+		{"a.b 1 -1", "a.b", 1, time.Unix(1500000300, 0), false},
+		{"a.b 1", "", 0, time.Time{}, true},
+		{"a.b x 1500000000", "", 0, time.Time{}, true},
+		{"a.b 1 x", "", 0, time.Time{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.line, func(t *testing.T) {
+			metric, value, ts, err := s.parseLine(tc.line)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrInvalidMetric)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.metric, metric)
+			assert.Equal(t, tc.value, value)
+			assert.True(t, tc.time.Equal(ts), ts)
+		})
+	}
+}
+
+func TestServerTCP(t *testing.T) {
+	s, rs := newTestServer(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ServeTCP(l)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = conn.Write([]byte("a.b 1 1500000000\ninvalid\nc 2 1500000000\na.b 3 1500000060\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Close())
+
+	waitCreated(t, s, 2)
+	assert.Eventually(t, func() bool {
+		return s.w.Pending() == 3
+	}, time.Second*5, time.Millisecond*10)
+	assert.NoError(t, s.Close())
+	assert.ErrorIs(t, <-done, ErrServerClosed)
+
+	assert.Equal(t, []string{
+		"create a/b.rrd -s 60 -O DS:value:GAUGE:120:U:U RRA:AVERAGE:0.5:1:1440 RRA:AVERAGE:0.5:5:2016 RRA:AVERAGE:0.5:60:8760",
+		"create c.rrd -s 60 -O DS:value:GAUGE:120:U:U RRA:AVERAGE:0.5:1:1440 RRA:AVERAGE:0.5:5:2016 RRA:AVERAGE:0.5:60:8760",
+		"batch",
+		"update a/b.rrd 1500000000:1 1500000060:3",
+		"update c.rrd 1500000000:2",
+		".",
+	}, rs.Received())
+
+	assert.ErrorIs(t, s.ServeTCP(l), ErrServerClosed)
+}
+
+func TestServerUDP(t *testing.T) {
+	s, rs := newTestServer(t, NoCreate)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ServeUDP(conn)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close() // nolint: errcheck
+	_, err = client.Write([]byte("a 1 1500000000\nb 2 -1\n"))
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return s.w.Pending() == 2
+	}, time.Second*5, time.Millisecond*10)
+	assert.NoError(t, s.Close())
+	assert.ErrorIs(t, <-done, ErrServerClosed)
+
+	assert.Equal(t, []string{
+		"batch",
+		"update a.rrd 1500000000:1",
+		"update b.rrd 1500000300:2",
+		".",
+	}, rs.Received())
+}
+
+func TestServerPickle(t *testing.T) {
+	def := rrd.NewCreateRRD([]rrd.DS{"DS:v:GAUGE:20:U:U"}, []rrd.RRA{"RRA:MAX:0.5:1:10"}).WithStep(time.Second * 10)
+	s, rs := newTestServer(t, Definition(def), Template("", "g/{{.Path}}.rrd"), MaxPickleSize(100))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ServePickle(l)
+	}()
+
+	data, err := hex.DecodeString(testPickles["protocol-2"])
+	if !assert.NoError(t, err) {
+		return
+	}
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, uint32(len(data))) // nolint: errcheck
+	msg.Write(data)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = conn.Write(msg.Bytes())
+	assert.NoError(t, err)
+
+	// Oversized messages close the connection.
+	_, err = conn.Write([]byte{0, 0, 1, 0})
+	assert.NoError(t, err)
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.NoError(t, conn.Close())
+
+	waitCreated(t, s, 2)
+	assert.NoError(t, s.Close())
+	assert.ErrorIs(t, <-done, ErrServerClosed)
+
+	assert.Equal(t, []string{
+		"create g/a.b.rrd -s 10 -O DS:v:GAUGE:20:U:U RRA:MAX:0.5:1:10",
+		"create g/c.rrd -s 10 -O DS:v:GAUGE:20:U:U RRA:MAX:0.5:1:10",
+		"batch",
+		"update g/a.b.rrd 1500000000:1.5",
+		"update g/c.rrd 1500000060:2",
+		".",
+	}, rs.Received())
+}
+
+func TestServerCreateExists(t *testing.T) {
+	s, rs := newTestServer(t)
+	rs.Handle("create", "-1 RRD Error: creating 'a.rrd': File exists")
+	ctx := s.ctx
+
+	assert.NoError(t, s.Write(ctx, "a", 1, time.Unix(1500000000, 0)))
+	assert.NoError(t, s.Write(ctx, "a", 2, time.Unix(1500000060, 0)))
+	assert.Equal(t, 1, rs.Count("create"))
+
+	rs.Handle("create", "-1 Permission denied")
+	assert.Error(t, s.Write(ctx, "b", 1, time.Unix(1500000000, 0)))
+	assert.ErrorIs(t, s.Write(ctx, "a..b", 1, time.Unix(1500000000, 0)), ErrInvalidMetric)
+
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.Close())
+}
@@ -0,0 +1,103 @@
+package rrdgraphite
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// DefaultTemplate is the filename template used for metrics which don't
+// match any configured template, mapping each node of the metric path to
+// a directory, so servers.web1.cpu is stored in servers/web1/cpu.rrd.
+const DefaultTemplate = `{{join .Nodes "/"}}.rrd`
+
+// funcs are the functions available to filename templates.
+var funcs = template.FuncMap{
+	"join":    strings.Join,
+	"replace": strings.ReplaceAll,
+	"lower":   strings.ToLower,
+}
+
+// Metric is the data passed to filename templates.
+type Metric struct {
+	// Path is the dotted metric path.
+	Path string
+
+	// Nodes are the dot separated components of Path.
+	Nodes []string
+}
+
+// filenameTemplate maps metrics matching pattern to filenames.
+type filenameTemplate struct {
+	pattern []string
+	tmpl    *template.Template
+}
+
+// newTemplate returns a filenameTemplate which applies the text/template
+// text to metrics matching pattern.
+func newTemplate(pattern, text string) (*filenameTemplate, error) {
+	t, err := template.New(pattern).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: template %q: %v", rrd.ErrInvalidArg, text, err)
+	}
+
+	var nodes []string
+	if pattern != "" {
+		nodes = strings.Split(pattern, ".")
+		for _, n := range nodes {
+			if _, err := path.Match(n, ""); err != nil {
+				return nil, fmt.Errorf("%w: pattern %q: %v", rrd.ErrInvalidArg, pattern, err)
+			}
+		}
+	}
+	return &filenameTemplate{pattern: nodes, tmpl: t}, nil
+}
+
+// match returns true if the leading nodes of a metric match the glob
+// patterns of t, false otherwise.
+func (t *filenameTemplate) match(nodes []string) bool {
+	if len(nodes) < len(t.pattern) {
+		return false
+	}
+	for i, p := range t.pattern {
+		if ok, _ := path.Match(p, nodes[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// execute returns the filename of m, which must be a clean relative path.
+func (t *filenameTemplate) execute(m *Metric) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, m); err != nil {
+		return "", fmt.Errorf("%w: %v: %v", ErrInvalidMetric, m.Path, err)
+	}
+
+	filename := buf.String()
+	switch {
+	case filename == "",
+		filepath.IsAbs(filename),
+		filepath.Clean(filename) != filename,
+		filename == "..",
+		strings.HasPrefix(filename, "../"):
+		return "", fmt.Errorf("%w: %v: invalid filename %q", ErrInvalidMetric, m.Path, filename)
+	}
+	return filename, nil
+}
+
+// parseMetric returns the Metric for the dotted path p.
+func parseMetric(p string) (*Metric, error) {
+	nodes := strings.Split(p, ".")
+	for _, n := range nodes {
+		if n == "" || strings.ContainsAny(n, "/\\ \t\x00") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMetric, p)
+		}
+	}
+	return &Metric{Path: p, Nodes: nodes}, nil
+}
@@ -0,0 +1,61 @@
+package rrdgraphite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+)
+
+func TestFilename(t *testing.T) {
+	s, err := NewServer(nil,
+		Template("servers.*.cpu", `hosts/{{index .Nodes 1}}/cpu-{{join (slice .Nodes 3) "-"}}.rrd`),
+		Template("servers", `hosts/{{lower (index .Nodes 1)}}/{{join (slice .Nodes 2) "_"}}.rrd`),
+		Template("bad", `{{join .Nodes "/../"}}`),
+		Template("abs", `/{{.Path}}`),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	tests := []struct {
+		metric   string
+		expected string
+		err      bool
+	}{
+		{"load", "load.rrd", false},
+		{"a.b.c", "a/b/c.rrd", false},
+		{"servers.web1.cpu.0.user", "hosts/web1/cpu-0-user.rrd", false},
+		{"servers.WEB1.mem.used", "hosts/web1/mem_used.rrd", false},
+		{"servers", "", true},
+		{"bad.x", "", true},
+		{"abs.x", "", true},
+		{"a..b", "", true},
+		{"a/b", "", true},
+		{"", "", true},
+		{".a", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.metric, func(t *testing.T) {
+			filename, err := s.Filename(tc.metric)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrInvalidMetric)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, filename)
+		})
+	}
+}
+
+func TestTemplateInvalid(t *testing.T) {
+	_, err := NewServer(nil, Template("a", "{{"))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+
+	_, err = NewServer(nil, Template("a.[", "x.rrd"))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+}
@@ -0,0 +1,168 @@
+package rrdgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client is a client of the RRD service which uses the types of the rrd
+// package, as returned by rrd.Client.
+type Client struct {
+	c RRDClient
+}
+
+// NewClient returns a new Client which calls the RRD service on cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{c: NewRRDClient(cc)}
+}
+
+// fromStatus returns the gRPC status error err wrapping the rrd error
+// which corresponds to its code, if any.
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	var target error
+	switch st.Code() {
+	case codes.NotFound:
+		target = rrd.ErrNotFound
+	case codes.AlreadyExists:
+		target = rrd.ErrExist
+	case codes.InvalidArgument:
+		target = rrd.ErrInvalidArg
+	case codes.PermissionDenied:
+		target = rrd.ErrPermissionDenied
+	case codes.ResourceExhausted:
+		target = rrd.ErrRateLimited
+	case codes.Unimplemented:
+		target = rrd.ErrNotSupported
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %v", target, st.Message())
+}
+
+// Info returns the configuration information of filename.
+func (c *Client) Info(ctx context.Context, filename string) ([]*rrd.Info, error) {
+	resp, err := c.c.Info(ctx, &InfoRequest{Filename: filename})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	info := make([]*rrd.Info, len(resp.GetValues()))
+	for i, v := range resp.GetValues() {
+		ri := &rrd.Info{Key: v.GetKey()}
+		switch val := v.GetValue().(type) {
+		case *InfoValue_IntValue:
+			ri.Value = val.IntValue
+		case *InfoValue_FloatValue:
+			ri.Value = val.FloatValue
+		case *InfoValue_StringValue:
+			ri.Value = val.StringValue
+		}
+		info[i] = ri
+	}
+	return info, nil
+}
+
+// List returns the names of the RRDs under prefix.
+func (c *Client) List(ctx context.Context, prefix string) ([]string, error) {
+	resp, err := c.c.List(ctx, &ListRequest{Prefix: prefix})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return resp.GetNames(), nil
+}
+
+// Fetch returns the time series of filename for cf between start and end,
+// either of which may be zero to use the servers default.
+func (c *Client) Fetch(ctx context.Context, filename string, cf rrd.ConsolidationFunc, start, end time.Time) (*rrd.FetchResult, error) {
+	req := &FetchRequest{Filename: filename, Cf: string(cf)}
+	if !start.IsZero() {
+		req.Start = start.Unix()
+	}
+	if !end.IsZero() {
+		req.End = end.Unix()
+	}
+
+	stream, err := c.c.Fetch(ctx, req)
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	r := &rrd.FetchResult{}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return r, nil
+		} else if err != nil {
+			return nil, fromStatus(err)
+		}
+
+		if h := resp.GetHeader(); h != nil {
+			r.Start = time.Unix(h.GetStart(), 0)
+			r.End = time.Unix(h.GetEnd(), 0)
+			r.Step = time.Duration(h.GetStep()) * time.Second
+			r.Names = h.GetNames()
+		}
+		for _, row := range resp.GetRows() {
+			r.Rows = append(r.Rows, rrd.FetchResultRow{
+				Time:   time.Unix(row.GetTime(), 0),
+				Values: row.GetValues(),
+			})
+		}
+	}
+}
+
+// Update adds samples to filename.
+func (c *Client) Update(ctx context.Context, filename string, samples ...rrd.Sample) error {
+	req := &UpdateRequest{
+		Filename: filename,
+		Samples:  make([]*Sample, len(samples)),
+	}
+	for i, s := range samples {
+		req.Samples[i] = &Sample{Values: s.Values}
+		if !s.Time.IsZero() {
+			req.Samples[i].Time = s.Time.Unix()
+		}
+	}
+
+	if _, err := c.c.Update(ctx, req); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+// Create creates filename as defined by def.
+func (c *Client) Create(ctx context.Context, filename string, def *rrd.CreateRRD) error {
+	req := &CreateRequest{
+		Filename: filename,
+		Ds:       make([]string, len(def.DS)),
+		Rra:      make([]string, len(def.RRA)),
+		Options:  make([]string, len(def.Options)),
+	}
+	for i, v := range def.DS {
+		req.Ds[i] = string(v)
+	}
+	for i, v := range def.RRA {
+		req.Rra[i] = string(v)
+	}
+	for i, v := range def.Options {
+		req.Options[i] = string(v)
+	}
+
+	if _, err := c.c.Create(ctx, req); err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
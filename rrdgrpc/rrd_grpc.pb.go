@@ -0,0 +1,295 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: rrd.proto
+
+package rrdgrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RRD_Info_FullMethodName   = "/rrd.v1.RRD/Info"
+	RRD_List_FullMethodName   = "/rrd.v1.RRD/List"
+	RRD_Fetch_FullMethodName  = "/rrd.v1.RRD/Fetch"
+	RRD_Update_FullMethodName = "/rrd.v1.RRD/Update"
+	RRD_Create_FullMethodName = "/rrd.v1.RRD/Create"
+)
+
+// RRDClient is the client API for RRD service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RRDClient interface {
+	// Info returns the configuration of an RRD.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+	// List returns the names of the RRDs under a prefix.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Fetch streams data from an RRD, the first response includes the header.
+	Fetch(ctx context.Context, in *FetchRequest, opts ...grpc.CallOption) (RRD_FetchClient, error)
+	// Update adds samples to an RRD.
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateResponse, error)
+	// Create creates an RRD.
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
+}
+
+type rRDClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRRDClient(cc grpc.ClientConnInterface) RRDClient {
+	return &rRDClient{cc}
+}
+
+func (c *rRDClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, RRD_Info_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rRDClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, RRD_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rRDClient) Fetch(ctx context.Context, in *FetchRequest, opts ...grpc.CallOption) (RRD_FetchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RRD_ServiceDesc.Streams[0], RRD_Fetch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rRDFetchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RRD_FetchClient interface {
+	Recv() (*FetchResponse, error)
+	grpc.ClientStream
+}
+
+type rRDFetchClient struct {
+	grpc.ClientStream
+}
+
+func (x *rRDFetchClient) Recv() (*FetchResponse, error) {
+	m := new(FetchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rRDClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateResponse, error) {
+	out := new(UpdateResponse)
+	err := c.cc.Invoke(ctx, RRD_Update_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rRDClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
+	out := new(CreateResponse)
+	err := c.cc.Invoke(ctx, RRD_Create_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RRDServer is the server API for RRD service.
+// All implementations must embed UnimplementedRRDServer
+// for forward compatibility
+type RRDServer interface {
+	// Info returns the configuration of an RRD.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+	// List returns the names of the RRDs under a prefix.
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Fetch streams data from an RRD, the first response includes the header.
+	Fetch(*FetchRequest, RRD_FetchServer) error
+	// Update adds samples to an RRD.
+	Update(context.Context, *UpdateRequest) (*UpdateResponse, error)
+	// Create creates an RRD.
+	Create(context.Context, *CreateRequest) (*CreateResponse, error)
+	mustEmbedUnimplementedRRDServer()
+}
+
+// UnimplementedRRDServer must be embedded to have forward compatible implementations.
+type UnimplementedRRDServer struct {
+}
+
+func (UnimplementedRRDServer) Info(context.Context, *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
+}
+func (UnimplementedRRDServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedRRDServer) Fetch(*FetchRequest, RRD_FetchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Fetch not implemented")
+}
+func (UnimplementedRRDServer) Update(context.Context, *UpdateRequest) (*UpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedRRDServer) Create(context.Context, *CreateRequest) (*CreateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedRRDServer) mustEmbedUnimplementedRRDServer() {}
+
+// UnsafeRRDServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RRDServer will
+// result in compilation errors.
+type UnsafeRRDServer interface {
+	mustEmbedUnimplementedRRDServer()
+}
+
+func RegisterRRDServer(s grpc.ServiceRegistrar, srv RRDServer) {
+	s.RegisterService(&RRD_ServiceDesc, srv)
+}
+
+func _RRD_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RRDServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RRD_Info_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RRDServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RRD_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RRDServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RRD_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RRDServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RRD_Fetch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FetchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RRDServer).Fetch(m, &rRDFetchServer{stream})
+}
+
+type RRD_FetchServer interface {
+	Send(*FetchResponse) error
+	grpc.ServerStream
+}
+
+type rRDFetchServer struct {
+	grpc.ServerStream
+}
+
+func (x *rRDFetchServer) Send(m *FetchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RRD_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RRDServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RRD_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RRDServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RRD_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RRDServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RRD_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RRDServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RRD_ServiceDesc is the grpc.ServiceDesc for RRD service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RRD_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rrd.v1.RRD",
+	HandlerType: (*RRDServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Info",
+			Handler:    _RRD_Info_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _RRD_List_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _RRD_Update_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _RRD_Create_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Fetch",
+			Handler:       _RRD_Fetch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rrd.proto",
+}
@@ -0,0 +1,216 @@
+// Package rrdgrpc provides a gRPC service which proxies requests to
+// rrdcached, allowing access from any language with gRPC support, along
+// with a client for it.
+//
+// The service is defined in rrd.proto, from which rrd.pb.go and
+// rrd_grpc.pb.go are generated.
+package rrdgrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative rrd.proto
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fetchBatchSize is the maximum number of rows sent in each fetch response.
+const fetchBatchSize = 512
+
+// Server is an RRDServer which executes requests with clients from a pool.
+type Server struct {
+	UnimplementedRRDServer
+
+	pool *rrd.Pool
+}
+
+// NewServer returns a new Server which executes requests with clients
+// from pool.
+func NewServer(pool *rrd.Pool) *Server {
+	return &Server{pool: pool}
+}
+
+// toStatus returns err as a gRPC status error.
+func toStatus(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, rrd.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, rrd.ErrExist):
+		code = codes.AlreadyExists
+	case errors.Is(err, rrd.ErrInvalidArg),
+		errors.Is(err, rrd.ErrInvalidCF),
+		errors.Is(err, rrd.ErrNoDS),
+		errors.Is(err, rrd.ErrNoRRA),
+		errors.Is(err, rrd.ErrNoSamples),
+		errors.Is(err, rrd.ErrIllegalUpdate):
+		code = codes.InvalidArgument
+	case errors.Is(err, rrd.ErrPermissionDenied), errors.Is(err, rrd.ErrReadOnly):
+		code = codes.PermissionDenied
+	case errors.Is(err, rrd.ErrRateLimited):
+		code = codes.ResourceExhausted
+	case errors.Is(err, rrd.ErrNotSupported):
+		code = codes.Unimplemented
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	default:
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
+
+// Info implements RRDServer.
+func (s *Server) Info(ctx context.Context, req *InfoRequest) (*InfoResponse, error) {
+	var info []*rrd.Info
+	err := s.pool.Do(ctx, func(c *rrd.Client) (err error) {
+		info, err = c.InfoWithContext(ctx, req.GetFilename())
+		return err
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &InfoResponse{Values: make([]*InfoValue, len(info))}
+	for i, v := range info {
+		iv := &InfoValue{Key: v.Key}
+		switch val := v.Value.(type) {
+		case int64:
+			iv.Value = &InfoValue_IntValue{IntValue: val}
+		case float64:
+			iv.Value = &InfoValue_FloatValue{FloatValue: val}
+		case string:
+			iv.Value = &InfoValue_StringValue{StringValue: val}
+		}
+		resp.Values[i] = iv
+	}
+	return resp, nil
+}
+
+// List implements RRDServer.
+func (s *Server) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	var names []string
+	err := s.pool.Do(ctx, func(c *rrd.Client) (err error) {
+		names, err = c.List(ctx, req.GetPrefix())
+		return err
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &ListResponse{Names: names}, nil
+}
+
+// Fetch implements RRDServer, streaming rows in batches as they're read
+// from rrdcached.
+func (s *Server) Fetch(req *FetchRequest, stream RRD_FetchServer) error {
+	ctx := stream.Context()
+	cf := rrd.ConsolidationFunc(req.GetCf())
+	if cf == "" {
+		cf = rrd.Average
+	}
+	var opts []interface{}
+	if req.GetStart() != 0 {
+		opts = append(opts, req.GetStart())
+	}
+	if req.GetEnd() != 0 {
+		opts = append(opts, req.GetEnd())
+	}
+
+	resp := &FetchResponse{}
+	headerSent := false
+	send := func() error {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		headerSent = true
+		resp = &FetchResponse{}
+		return nil
+	}
+
+	err := s.pool.Do(ctx, func(c *rrd.Client) error {
+		return c.FetchStream(ctx, req.GetFilename(), cf, func(h *rrd.Fetch, row rrd.FetchRow) error {
+			if !headerSent && resp.Header == nil {
+				resp.Header = &FetchHeader{
+					Start: h.Start.Unix(),
+					End:   h.End.Unix(),
+					Step:  int64(h.Step / time.Second),
+					Names: h.Names,
+				}
+			}
+
+			vals := make([]float64, len(row.Data))
+			for i, v := range row.Data {
+				if v == nil {
+					vals[i] = math.NaN()
+				} else {
+					vals[i] = *v
+				}
+			}
+			resp.Rows = append(resp.Rows, &FetchRow{Time: row.Time.Unix(), Values: vals})
+			if len(resp.Rows) < fetchBatchSize {
+				return nil
+			}
+			return send()
+		}, opts...)
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+
+	if len(resp.Rows) > 0 {
+		if err := send(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update implements RRDServer.
+func (s *Server) Update(ctx context.Context, req *UpdateRequest) (*UpdateResponse, error) {
+	samples := make([]rrd.Sample, len(req.GetSamples()))
+	for i, v := range req.GetSamples() {
+		if v.GetTime() != 0 {
+			samples[i].Time = time.Unix(v.GetTime(), 0)
+		}
+		samples[i].Values = v.GetValues()
+	}
+
+	err := s.pool.Do(ctx, func(c *rrd.Client) error {
+		return c.UpdateWithContext(ctx, req.GetFilename(), samples...)
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &UpdateResponse{}, nil
+}
+
+// Create implements RRDServer.
+func (s *Server) Create(ctx context.Context, req *CreateRequest) (*CreateResponse, error) {
+	def := rrd.NewCreateRRD(nil, nil)
+	for _, v := range req.GetDs() {
+		def.WithDS(rrd.NewDS(v))
+	}
+	for _, v := range req.GetRra() {
+		def.WithRRA(rrd.NewRRA(v))
+	}
+	for _, v := range req.GetOptions() {
+		def.WithOptions(rrd.CreateOption(v))
+	}
+
+	err := s.pool.Do(ctx, func(c *rrd.Client) error {
+		return c.CreateFromWithContext(ctx, req.GetFilename(), def)
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &CreateResponse{}, nil
+}
@@ -0,0 +1,164 @@
+package rrdgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient returns a Client connected to a Server which proxies to s.
+func newTestClient(t *testing.T, s *rrdtest.Server) *Client {
+	t.Helper()
+
+	pool, err := rrd.NewPool(s.Addr, 2, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, pool.Close())
+	})
+
+	l := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	RegisterRRDServer(gs, NewServer(pool))
+	go gs.Serve(l) // nolint: errcheck
+	t.Cleanup(gs.Stop)
+
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, cc.Close())
+	})
+
+	return NewClient(cc)
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		expect codes.Code
+	}{
+		{rrd.NewError(-1, "No such file: test.rrd"), codes.NotFound},
+		{rrd.NewError(-1, "File exists"), codes.AlreadyExists},
+		{rrd.ErrNoDS, codes.InvalidArgument},
+		{rrd.NewError(-1, "Permission denied"), codes.PermissionDenied},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{errors.New("connection refused"), codes.Unavailable},
+	}
+	for _, tc := range tests {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.expect, status.Code(toStatus(tc.err)))
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	s.Handle("info",
+		"3 Info for test.rrd follows",
+		"filename 2 test.rrd",
+		"step 1 300",
+		"ds[watts].max 0 2.4000000000e+04",
+	)
+	s.Handle("list", "2 RRDs", "/test.rrd", "/sub/other.rrd")
+	rows := make([]string, fetchBatchSize+2)
+	for i := range rows {
+		rows[i] = fmt.Sprintf("%v: %v nan", 1499908800+(i+1)*300, i)
+	}
+	s.Handle("fetch", append([]string{
+		fmt.Sprintf("%v Success", len(rows)+6),
+		"FlushVersion: 1",
+		"Start: 1499908800",
+		fmt.Sprintf("End: %v", 1499908800+len(rows)*300),
+		"Step: 300",
+		"DSCount: 2",
+		"DSName: watts amps",
+	}, rows...)...)
+	s.HandleFunc("update", func(_ string, args []string) []string {
+		if args[0] == "missing.rrd" {
+			return []string{"-1 No such file: missing.rrd"}
+		}
+		return []string{fmt.Sprintf("0 errors, enqueued %v value(s).", len(args)-1)}
+	})
+
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	info, err := c.Info(ctx, "test.rrd")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []*rrd.Info{
+			{Key: "filename", Value: "test.rrd"},
+			{Key: "step", Value: int64(300)},
+			{Key: "ds[watts].max", Value: float64(24000)},
+		}, info)
+	}
+
+	names, err := c.List(ctx, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"/test.rrd", "/sub/other.rrd"}, names)
+	}
+
+	f, err := c.Fetch(ctx, "test.rrd", rrd.Max, time.Unix(1499908800, 0), time.Time{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Unix(1499908800, 0), f.Start)
+		assert.Equal(t, time.Minute*5, f.Step)
+		assert.Equal(t, []string{"watts", "amps"}, f.Names)
+		if assert.Len(t, f.Rows, len(rows)) {
+			last := f.Rows[len(rows)-1]
+			assert.Equal(t, time.Unix(int64(1499908800+len(rows)*300), 0), last.Time)
+			assert.Equal(t, float64(len(rows)-1), last.Values[0])
+			assert.True(t, math.IsNaN(last.Values[1]))
+		}
+	}
+	assert.Contains(t, s.Received(), "fetch test.rrd MAX 1499908800")
+
+	assert.NoError(t, c.Update(ctx, "test.rrd",
+		rrd.Sample{Time: time.Unix(1499909100, 0), Values: []float64{1, math.NaN()}},
+		rrd.Sample{Time: time.Unix(1499909400, 0), Values: []float64{2, 3}},
+	))
+	assert.Contains(t, s.Received(), "update test.rrd 1499909100:1:U 1499909400:2:3")
+
+	err = c.Update(ctx, "missing.rrd", rrd.Sample{Time: time.Unix(1499909100, 0), Values: []float64{1}})
+	assert.ErrorIs(t, err, rrd.ErrNotFound)
+	assert.True(t, strings.Contains(err.Error(), "No such file"))
+
+	assert.ErrorIs(t, c.Update(ctx, "test.rrd"), rrd.ErrInvalidArg)
+
+	def := rrd.NewCreateRRD(
+		[]rrd.DS{rrd.NewGauge("watts", time.Minute*5, 0, 24000)},
+		[]rrd.RRA{rrd.NewAverage(0.5, 1, 288)},
+	).WithStep(time.Minute * 5)
+	assert.NoError(t, c.Create(ctx, "new.rrd", def))
+	assert.Contains(t, s.Received(), "create new.rrd -s 300 DS:watts:GAUGE:300:0:24000 RRA:AVERAGE:0.5:1:288")
+
+	err = c.Create(ctx, "new.rrd", rrd.NewCreateRRD(nil, nil))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+}
@@ -0,0 +1,324 @@
+// Package rrdhttp provides an HTTP gateway to rrdcached, allowing RRDs to be
+// queried and updated with JSON over HTTP without speaking the rrdcached
+// protocol.
+//
+// The endpoints, where path is the RRD's filename relative to the
+// rrdcached base directory, are:
+//
+//	GET  /rrd/{path}/info                         configuration of the RRD
+//	GET  /rrd/{path}/fetch?cf=&start=&end=        data from the RRD
+//	POST /rrd/{path}/update                       update the RRD
+//
+// Times are unix timestamps, RFC 3339 times or durations relative to now,
+// such as -1h. Unknown values are represented as null.
+package rrdhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+const (
+	// Prefix is the path prefix of the RRD endpoints.
+	Prefix = "/rrd/"
+
+	// DefaultFetchRange is the range fetched when no start is given.
+	DefaultFetchRange = time.Hour * 24
+
+	// DefaultMaxBodySize is the default maximum size of a request body.
+	DefaultMaxBodySize = 1 << 20
+)
+
+// errBadRequest is returned for invalid requests.
+var errBadRequest = errors.New("bad request")
+
+// Handler is an http.Handler which serves RRD requests using clients from
+// a pool.
+type Handler struct {
+	pool        *rrd.Pool
+	logger      *slog.Logger
+	maxBodySize int64
+	now         func() time.Time
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// Logger sets the logger used to report failed requests.
+func Logger(l *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = l
+	}
+}
+
+// MaxBodySize sets the maximum size of a request body, which defaults to
+// DefaultMaxBodySize.
+func MaxBodySize(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = n
+	}
+}
+
+// NewHandler returns a new Handler which executes requests with clients
+// from pool.
+func NewHandler(pool *rrd.Pool, opts ...Option) *Handler {
+	h := &Handler{
+		pool:        pool,
+		logger:      slog.Default(),
+		maxBodySize: DefaultMaxBodySize,
+		now:         time.Now,
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	filename, action, err := splitPath(r.URL.Path)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	var method string
+	var f func(w http.ResponseWriter, r *http.Request, filename string) error
+	switch action {
+	case "info":
+		method, f = http.MethodGet, h.info
+	case "fetch":
+		method, f = http.MethodGet, h.fetch
+	case "update":
+		method, f = http.MethodPost, h.update
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		h.writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	if err := f(w, r, filename); err != nil {
+		h.error(w, r, err)
+	}
+}
+
+// splitPath returns the filename and action of the request path p.
+func splitPath(p string) (string, string, error) {
+	rest, ok := strings.CutPrefix(p, Prefix)
+	if !ok {
+		return "", "", fmt.Errorf("%w: path must start with %v", errBadRequest, Prefix)
+	}
+
+	i := strings.LastIndexByte(rest, '/')
+	if i <= 0 {
+		return "", "", fmt.Errorf("%w: no file in path", errBadRequest)
+	}
+
+	filename := rest[:i]
+	for _, e := range strings.Split(filename, "/") {
+		if e == ".." || e == "." || e == "" {
+			return "", "", fmt.Errorf("%w: invalid file %q", errBadRequest, filename)
+		}
+	}
+
+	return filename, rest[i+1:], nil
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// status returns the HTTP status code for err.
+func status(err error) int {
+	switch {
+	case errors.Is(err, errBadRequest),
+		errors.Is(err, rrd.ErrInvalidArg),
+		errors.Is(err, rrd.ErrInvalidCF),
+		errors.Is(err, rrd.ErrNoSamples),
+		errors.Is(err, rrd.ErrIllegalUpdate):
+		return http.StatusBadRequest
+	case errors.Is(err, rrd.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, rrd.ErrPermissionDenied), errors.Is(err, rrd.ErrReadOnly):
+		return http.StatusForbidden
+	case errors.Is(err, rrd.ErrRateLimited), errors.Is(err, rrd.ErrPoolClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// error writes err as a JSON error response.
+func (h *Handler) error(w http.ResponseWriter, r *http.Request, err error) {
+	code := status(err)
+	if code >= http.StatusInternalServerError {
+		h.logger.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+	h.writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+// writeJSON writes v as the JSON body of a response with code.
+func (h *Handler) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Warn("failed to write response", "error", err)
+	}
+}
+
+// parseTime parses s as a unix timestamp, an RFC 3339 time or a duration
+// relative to now.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "now" {
+		return now, nil
+	}
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: invalid time %q", errBadRequest, s)
+}
+
+func (h *Handler) info(w http.ResponseWriter, r *http.Request, filename string) error {
+	var info []*rrd.Info
+	err := h.pool.Do(r.Context(), func(c *rrd.Client) (err error) {
+		info, err = c.InfoWithContext(r.Context(), filename)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	m := make(map[string]interface{}, len(info))
+	for _, i := range info {
+		m[i.Key] = i.Value
+	}
+	h.writeJSON(w, http.StatusOK, m)
+	return nil
+}
+
+// FetchRow is a row of a FetchResponse.
+type FetchRow struct {
+	Time   int64      `json:"time"`
+	Values []*float64 `json:"values"`
+}
+
+// FetchResponse is the body of a fetch response.
+type FetchResponse struct {
+	Start int64      `json:"start"`
+	End   int64      `json:"end"`
+	Step  int64      `json:"step"`
+	Names []string   `json:"names"`
+	Rows  []FetchRow `json:"rows"`
+}
+
+func (h *Handler) fetch(w http.ResponseWriter, r *http.Request, filename string) error {
+	q := r.URL.Query()
+	now := h.now()
+	start, end := now.Add(-DefaultFetchRange), now
+	var err error
+	if v := q.Get("start"); v != "" {
+		if start, err = parseTime(v, now); err != nil {
+			return err
+		}
+	}
+	if v := q.Get("end"); v != "" {
+		if end, err = parseTime(v, now); err != nil {
+			return err
+		}
+	}
+	cf := rrd.Average
+	if v := q.Get("cf"); v != "" {
+		cf = rrd.ConsolidationFunc(strings.ToUpper(v))
+	}
+
+	var f *rrd.Fetch
+	err = h.pool.Do(r.Context(), func(c *rrd.Client) (err error) {
+		f, err = c.FetchWithContext(r.Context(), filename, cf, start.Unix(), end.Unix())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	resp := FetchResponse{
+		Start: f.Start.Unix(),
+		End:   f.End.Unix(),
+		Step:  int64(f.Step / time.Second),
+		Names: f.Names,
+		Rows:  make([]FetchRow, len(f.Rows)),
+	}
+	for i, row := range f.Rows {
+		resp.Rows[i] = FetchRow{Time: row.Time.Unix(), Values: row.Data}
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+// UpdateSample is a sample of an UpdateRequest. A zero time represents now.
+type UpdateSample struct {
+	Time   int64      `json:"time,omitempty"`
+	Values []*float64 `json:"values"`
+}
+
+// UpdateRequest is the body of an update request.
+type UpdateRequest struct {
+	Samples []UpdateSample `json:"samples"`
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request, filename string) error {
+	var req UpdateRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	samples := make([]rrd.Sample, len(req.Samples))
+	for i, s := range req.Samples {
+		if s.Time != 0 {
+			samples[i].Time = time.Unix(s.Time, 0)
+		}
+		samples[i].Values = make([]float64, len(s.Values))
+		for j, v := range s.Values {
+			if v == nil {
+				samples[i].Values[j] = math.NaN()
+			} else {
+				samples[i].Values[j] = *v
+			}
+		}
+	}
+
+	err := h.pool.Do(r.Context(), func(c *rrd.Client) error {
+		return c.UpdateWithContext(r.Context(), filename, samples...)
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,223 @@
+package rrdhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		filename string
+		action   string
+		err      bool
+	}{
+		{path: "/rrd/test.rrd/info", filename: "test.rrd", action: "info"},
+		{path: "/rrd/a/b/test.rrd/fetch", filename: "a/b/test.rrd", action: "fetch"},
+		{path: "/rrd/test.rrd/", filename: "test.rrd", action: ""},
+		{path: "/other/test.rrd/info", err: true},
+		{path: "/rrd/info", err: true},
+		{path: "/rrd/../etc/passwd/info", err: true},
+		{path: "/rrd/a//b/info", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			filename, action, err := splitPath(tc.path)
+			if tc.err {
+				assert.ErrorIs(t, err, errBadRequest)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.filename, filename)
+			assert.Equal(t, tc.action, action)
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		expect int
+	}{
+		{fmt.Errorf("%w: x", errBadRequest), http.StatusBadRequest},
+		{rrd.NewError(-1, "No such file: test.rrd"), http.StatusNotFound},
+		{rrd.NewError(-1, "illegal attempt to update using time 1 when last update time is 2 (minimum one second step)"), http.StatusBadRequest},
+		{rrd.NewError(-1, "Permission denied"), http.StatusForbidden},
+		{rrd.ErrPoolClosed, http.StatusServiceUnavailable},
+		{errors.New("connection refused"), http.StatusBadGateway},
+	}
+	for _, tc := range tests {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.expect, status(tc.err))
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	s.Handle("info",
+		"2 Info for test.rrd follows",
+		"filename 2 test.rrd",
+		"step 1 300",
+	)
+	s.Handle("fetch",
+		"8 Success",
+		"FlushVersion: 1",
+		"Start: 1499908800",
+		"End: 1499909400",
+		"Step: 300",
+		"DSCount: 2",
+		"DSName: watts amps",
+		"1499909100: 8.00000000000000000e+00 1.5e+00",
+		"1499909400: nan -nan",
+	)
+	s.HandleFunc("update", func(_ string, args []string) []string {
+		if args[0] == "missing.rrd" {
+			return []string{"-1 No such file: missing.rrd"}
+		}
+		return []string{fmt.Sprintf("0 errors, enqueued %v value(s).", len(args)-1)}
+	})
+
+	pool, err := rrd.NewPool(s.Addr, 2, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, pool.Close())
+	}()
+
+	h := NewHandler(pool)
+	h.now = func() time.Time { return time.Unix(1499909400, 0) }
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+		expect string
+		cmd    string
+	}{
+		{
+			name:   "info",
+			method: http.MethodGet,
+			path:   "/rrd/test.rrd/info",
+			code:   http.StatusOK,
+			expect: `{"filename":"test.rrd","step":300}`,
+			cmd:    "info test.rrd",
+		},
+		{
+			name:   "fetch",
+			method: http.MethodGet,
+			path:   "/rrd/sub/test.rrd/fetch?cf=max&start=-10m",
+			code:   http.StatusOK,
+			expect: `{"start":1499908800,"end":1499909400,"step":300,"names":["watts","amps"],"rows":[{"time":1499909100,"values":[8,1.5]},{"time":1499909400,"values":[null,null]}]}`,
+			cmd:    "fetch sub/test.rrd MAX 1499908800 1499909400",
+		},
+		{
+			name:   "fetch-default-range",
+			method: http.MethodGet,
+			path:   "/rrd/test.rrd/fetch",
+			code:   http.StatusOK,
+			cmd:    "fetch test.rrd AVERAGE 1499823000 1499909400",
+		},
+		{
+			name:   "fetch-bad-time",
+			method: http.MethodGet,
+			path:   "/rrd/test.rrd/fetch?start=yesterday",
+			code:   http.StatusBadRequest,
+			expect: `{"error":"bad request: invalid time \"yesterday\""}`,
+		},
+		{
+			name:   "update",
+			method: http.MethodPost,
+			path:   "/rrd/test.rrd/update",
+			body:   `{"samples":[{"time":1499909100,"values":[1,null]},{"time":1499909400,"values":[2,3]}]}`,
+			code:   http.StatusNoContent,
+			cmd:    "update test.rrd 1499909100:1:U 1499909400:2:3",
+		},
+		{
+			name:   "update-missing",
+			method: http.MethodPost,
+			path:   "/rrd/missing.rrd/update",
+			body:   `{"samples":[{"time":1499909100,"values":[1]}]}`,
+			code:   http.StatusNotFound,
+		},
+		{
+			name:   "update-no-samples",
+			method: http.MethodPost,
+			path:   "/rrd/test.rrd/update",
+			body:   `{"samples":[]}`,
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "update-invalid-body",
+			method: http.MethodPost,
+			path:   "/rrd/test.rrd/update",
+			body:   `{"values":[1]}`,
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "method-not-allowed",
+			method: http.MethodGet,
+			path:   "/rrd/test.rrd/update",
+			code:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "unknown-action",
+			method: http.MethodGet,
+			path:   "/rrd/test.rrd/graph",
+			code:   http.StatusNotFound,
+		},
+		{
+			name:   "bad-path",
+			method: http.MethodGet,
+			path:   "/rrd/../test.rrd/info",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s.Reset()
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+			if !assert.NoError(t, err) {
+				return
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close() // nolint: errcheck
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.code, resp.StatusCode, string(body))
+			if tc.expect != "" {
+				assert.JSONEq(t, tc.expect, string(body))
+			}
+			if tc.cmd != "" {
+				assert.Contains(t, s.Received(), tc.cmd)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package rrdinflux
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// MaxBodySize is the maximum size of a decompressed write request body.
+const MaxBodySize = 32 << 20
+
+// precisions maps the precision query parameter of write requests to the
+// unit of timestamps.
+var precisions = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"n":  time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// ServeHTTP implements http.Handler, accepting line protocol POSTed to it
+// in the form of the InfluxDB /write and /api/v2/write endpoints. The
+// precision query parameter sets the unit of timestamps, which defaults to
+// nanoseconds, and gzip encoded bodies are supported.
+func (w *Writer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeError(rw, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	precision, ok := precisions[r.URL.Query().Get("precision")]
+	if !ok {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid precision %q", r.URL.Query().Get("precision")))
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		defer gz.Close() // nolint: errcheck
+		body = gz
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, MaxBodySize+1))
+	switch {
+	case err != nil:
+		writeError(rw, http.StatusBadRequest, err)
+		return
+	case len(data) > MaxBodySize:
+		writeError(rw, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+		return
+	}
+
+	if err := w.WriteLines(r.Context(), data, precision); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidLine) || errors.Is(err, ErrUnknownDS) {
+			status = http.StatusBadRequest
+		}
+		writeError(rw, status, err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// writeError writes err as a JSON error response with status.
+func writeError(rw http.ResponseWriter, status int, err error) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()}) // nolint: errcheck
+}
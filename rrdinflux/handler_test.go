@@ -0,0 +1,62 @@
+package rrdinflux
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	w, s := newTestWriter(t)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write([]byte("cpu,host=web1 a=3 1500000120000"))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     io.Reader
+		gzip     bool
+		status   int
+		received string
+	}{
+		{"ns", http.MethodPost, "/write", strings.NewReader("cpu,host=web1 a=1 1500000000000000000"), false, http.StatusNoContent, "update cpu/web1.rrd 1500000000:U:1"},
+		{"s", http.MethodPost, "/api/v2/write?precision=s", strings.NewReader("cpu,host=web1 a=2 1500000060"), false, http.StatusNoContent, "update cpu/web1.rrd 1500000060:U:2"},
+		{"gzip", http.MethodPost, "/write?precision=ms", &gz, true, http.StatusNoContent, "update cpu/web1.rrd 1500000120:U:3"},
+		{"bad-gzip", http.MethodPost, "/write", strings.NewReader("x"), true, http.StatusBadRequest, ""},
+		{"method", http.MethodGet, "/write", nil, false, http.StatusMethodNotAllowed, ""},
+		{"precision", http.MethodPost, "/write?precision=d", strings.NewReader(""), false, http.StatusBadRequest, ""},
+		{"invalid", http.MethodPost, "/write", strings.NewReader("cpu"), false, http.StatusBadRequest, ""},
+		{"unknown-ds", http.MethodPost, "/write", strings.NewReader("cpu,host=web1 z=1"), false, http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s.Reset()
+			r := httptest.NewRequest(tc.method, tc.target, tc.body)
+			if tc.gzip {
+				r.Header.Set("Content-Encoding", "gzip")
+			}
+			rw := httptest.NewRecorder()
+			w.ServeHTTP(rw, r)
+
+			assert.Equal(t, tc.status, rw.Code, rw.Body.String())
+			if tc.status != http.StatusNoContent {
+				assert.Contains(t, rw.Body.String(), `"error"`)
+			}
+			if tc.received != "" {
+				assert.Contains(t, s.Received(), tc.received)
+			}
+		})
+	}
+}
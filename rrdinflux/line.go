@@ -0,0 +1,247 @@
+// Package rrdinflux ingests points written with the InfluxDB line protocol
+// into RRDs via rrdcached, allowing agents such as Telegraf to write to
+// RRDs.
+//
+// Each field of a point is mapped to an RRD and data source by a MapFunc,
+// RRDs are created on first use and the points of each write are sent to
+// rrdcached as a single batch.
+package rrdinflux
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidLine is returned when a line isn't valid line protocol.
+var ErrInvalidLine = errors.New("invalid line protocol")
+
+// Tag is a tag of a point.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Field is a field of a point, whose Value is a float64, int64, uint64,
+// bool or string.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Float returns the value of f as a float64, with booleans as 0 or 1,
+// returning false if f is a string.
+func (f Field) Float() (float64, bool) {
+	switch v := f.Value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Point is a point of the line protocol.
+type Point struct {
+	Measurement string
+
+	// Tags are the tags of the point ordered by key.
+	Tags []Tag
+
+	Fields []Field
+	Time   time.Time
+}
+
+// Tag returns the value of the tag key, or an empty string if p doesn't
+// have it.
+func (p *Point) Tag(key string) string {
+	for _, t := range p.Tags {
+		if t.Key == key {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// Parse parses the lines of data, skipping blank lines and comments.
+// Timestamps are in units of precision and points without a timestamp are
+// given the time now.
+func Parse(data []byte, precision time.Duration, now time.Time) ([]*Point, error) {
+	var points []*Point
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 4096), len(data)+1)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		p, err := ParseLine(line, precision, now)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", n, err)
+		}
+		points = append(points, p)
+	}
+	return points, sc.Err()
+}
+
+// ParseLine parses a single line of the form:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// Timestamps are in units of precision and points without a timestamp are
+// given the time now.
+func ParseLine(line string, precision time.Duration, now time.Time) (*Point, error) {
+	sections := split(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("%w: %q: expected 2 or 3 sections", ErrInvalidLine, line)
+	}
+
+	key := split(sections[0], ',', false)
+	p := &Point{Measurement: unescape(key[0])}
+	if p.Measurement == "" {
+		return nil, fmt.Errorf("%w: %q: missing measurement", ErrInvalidLine, line)
+	}
+	for _, t := range key[1:] {
+		k, v, ok := cutUnescaped(t, '=')
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("%w: %q: invalid tag %q", ErrInvalidLine, line, t)
+		}
+		p.Tags = append(p.Tags, Tag{Key: unescape(k), Value: unescape(v)})
+	}
+	sort.SliceStable(p.Tags, func(i, j int) bool {
+		return p.Tags[i].Key < p.Tags[j].Key
+	})
+
+	for _, f := range split(sections[1], ',', true) {
+		k, v, ok := cutUnescaped(f, '=')
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%w: %q: invalid field %q", ErrInvalidLine, line, f)
+		}
+		value, err := parseValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: field %q: %v", ErrInvalidLine, line, k, err)
+		}
+		p.Fields = append(p.Fields, Field{Key: unescape(k), Value: value})
+	}
+
+	p.Time = now
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: timestamp: %v", ErrInvalidLine, line, err)
+		}
+		p.Time = time.Unix(0, ts*int64(precision))
+	}
+	return p, nil
+}
+
+// parseValue parses a field value.
+func parseValue(v string) (interface{}, error) {
+	switch {
+	case v == "":
+		return nil, errors.New("missing value")
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return nil, errors.New("unterminated string")
+		}
+		return unescapeString(v[1 : len(v)-1]), nil
+	}
+
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	switch v[len(v)-1] {
+	case 'i':
+		return strconv.ParseInt(v[:len(v)-1], 10, 64)
+	case 'u':
+		return strconv.ParseUint(v[:len(v)-1], 10, 64)
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// split splits s at each sep not escaped with a backslash or, if quotes is
+// true, within double quotes. Escapes are retained.
+func split(s string, sep byte, quotes bool) []string {
+	var parts []string
+	var quoted bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"' && quotes:
+			quoted = !quoted
+		case c == sep && !quoted:
+			if i > start || sep != ' ' {
+				parts = append(parts, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(s) || sep != ' ' {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// cutUnescaped slices s around the first sep not escaped with a backslash.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// unescape removes the backslashes escaping commas, equals signs and
+// spaces in measurements, tags and field keys.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`,= \`, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unescapeString removes the backslashes escaping double quotes and
+// backslashes in string field values.
+func unescapeString(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
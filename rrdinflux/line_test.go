@@ -0,0 +1,127 @@
+package rrdinflux
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	now := time.Unix(1500000300, 0)
+	tests := []struct {
+		line      string
+		precision time.Duration
+		expected  *Point
+	}{
+		{
+			line:      "cpu usage=1.5 1500000000000000000",
+			precision: time.Nanosecond,
+			expected: &Point{
+				Measurement: "cpu",
+				Fields:      []Field{{Key: "usage", Value: 1.5}},
+				Time:        time.Unix(1500000000, 0),
+			},
+		},
+		{
+			line:      "cpu,host=web1,cpu=cpu0 user=1i,idle=2u,up=t,down=FALSE 1500000000",
+			precision: time.Second,
+			expected: &Point{
+				Measurement: "cpu",
+				Tags:        []Tag{{Key: "cpu", Value: "cpu0"}, {Key: "host", Value: "web1"}},
+				Fields: []Field{
+					{Key: "user", Value: int64(1)},
+					{Key: "idle", Value: uint64(2)},
+					{Key: "up", Value: true},
+					{Key: "down", Value: false},
+				},
+				Time: time.Unix(1500000000, 0),
+			},
+		},
+		{
+			line:      `my\ disk,path=/var\,log\=x free=1,msg="a \"quoted\", spaced\\ string"`,
+			precision: time.Nanosecond,
+			expected: &Point{
+				Measurement: "my disk",
+				Tags:        []Tag{{Key: "path", Value: "/var,log=x"}},
+				Fields: []Field{
+					{Key: "free", Value: float64(1)},
+					{Key: "msg", Value: `a "quoted", spaced\ string`},
+				},
+				Time: now,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.line, func(t *testing.T) {
+			p, err := ParseLine(tc.line, tc.precision, now)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, p)
+		})
+	}
+}
+
+func TestParseLineInvalid(t *testing.T) {
+	tests := []string{
+		"cpu",
+		"cpu a=1 1 extra",
+		",host=a a=1",
+		"cpu,host a=1",
+		"cpu,=a a=1",
+		"cpu a",
+		"cpu =1",
+		"cpu a=",
+		"cpu a=x",
+		"cpu a=1x",
+		`cpu a="unterminated`,
+		"cpu a=1 x",
+	}
+
+	for _, line := range tests {
+		t.Run(line, func(t *testing.T) {
+			_, err := ParseLine(line, time.Nanosecond, time.Now())
+			assert.ErrorIs(t, err, ErrInvalidLine)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	now := time.Unix(1500000300, 0)
+	points, err := Parse([]byte("# comment\n\ncpu a=1 1500000000\n  mem b=2\n"), time.Second, now)
+	if assert.NoError(t, err) && assert.Len(t, points, 2) {
+		assert.Equal(t, "cpu", points[0].Measurement)
+		assert.Equal(t, time.Unix(1500000000, 0), points[0].Time)
+		assert.Equal(t, "mem", points[1].Measurement)
+		assert.Equal(t, now, points[1].Time)
+	}
+
+	_, err = Parse([]byte("cpu a=1\ncpu\n"), time.Second, now)
+	assert.ErrorIs(t, err, ErrInvalidLine)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestField(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected float64
+		ok       bool
+	}{
+		{1.5, 1.5, true},
+		{int64(-2), -2, true},
+		{uint64(3), 3, true},
+		{true, 1, true},
+		{false, 0, true},
+		{"x", 0, false},
+	}
+
+	for _, tc := range tests {
+		v, ok := Field{Key: "f", Value: tc.value}.Float()
+		assert.Equal(t, tc.ok, ok)
+		assert.Equal(t, tc.expected, v)
+	}
+
+	p := &Point{Tags: []Tag{{Key: "host", Value: "web1"}}}
+	assert.Equal(t, "web1", p.Tag("host"))
+	assert.Equal(t, "", p.Tag("missing"))
+}
@@ -0,0 +1,353 @@
+package rrdinflux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// maxDSName is the maximum length of a data source name.
+const maxDSName = 19
+
+// ErrUnknownDS is returned for fields mapped to a data source which the
+// RRD doesn't have.
+var ErrUnknownDS = errors.New("unknown data source")
+
+// Mapping identifies the data source of an RRD a field is written to.
+type Mapping struct {
+	Filename string
+	DS       string
+}
+
+// MapFunc maps the field of p to the data source it's written to,
+// returning false to discard the field.
+type MapFunc func(p *Point, field string) (Mapping, bool)
+
+// DefaultMap writes each measurement and set of tag values to its own RRD,
+// with a data source for each field. RRDs are named after the measurement
+// followed by a directory for each tag value in key order, so the fields
+// of cpu,host=web1,cpu=cpu0 are written to cpu/cpu0/web1.rrd.
+// Characters which aren't valid in filenames or data source names are
+// replaced with underscores and data source names are truncated to 19
+// characters.
+func DefaultMap(p *Point, field string) (Mapping, bool) {
+	parts := make([]string, 0, len(p.Tags)+1)
+	parts = append(parts, sanitize(p.Measurement, isFilenameChar))
+	for _, t := range p.Tags {
+		parts = append(parts, sanitize(t.Value, isFilenameChar))
+	}
+	for i, part := range parts {
+		if part == "." || part == ".." {
+			parts[i] = "_"
+		}
+	}
+
+	ds := sanitize(field, isDSChar)
+	if len(ds) > maxDSName {
+		ds = ds[:maxDSName]
+	}
+	return Mapping{Filename: strings.Join(parts, "/") + ".rrd", DS: ds}, true
+}
+
+func isFilenameChar(r rune) bool {
+	return isDSChar(r) || r == '-' || r == '.'
+}
+
+func isDSChar(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// sanitize replaces the characters of s which aren't valid with underscores.
+func sanitize(s string, valid func(r rune) bool) string {
+	return strings.Map(func(r rune) rune {
+		if valid(r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// DefaultDefinition returns the definition of RRDs created for new series
+// by default, with a GAUGE data source named after each of ds, a 10 second
+// step, and keeping a day of 10 seconds, a week of minutes and a year of
+// hours.
+func DefaultDefinition(filename string, ds []string) *rrd.CreateRRD {
+	def := rrd.NewCreateRRD(nil, []rrd.RRA{
+		"RRA:AVERAGE:0.5:1:8640",
+		"RRA:AVERAGE:0.5:6:10080",
+		"RRA:AVERAGE:0.5:360:8760",
+	}).WithStep(time.Second * 10)
+	for _, name := range ds {
+		def.WithDS(rrd.NewDS(fmt.Sprintf("DS:%v:GAUGE:20:U:U", name)))
+	}
+	return def
+}
+
+// Map sets the function which maps fields to data sources, which defaults
+// to DefaultMap.
+func Map(f MapFunc) func(*Writer) error {
+	return func(w *Writer) error {
+		if f == nil {
+			return rrd.ErrNilOption
+		}
+		w.mapFn = f
+		return nil
+	}
+}
+
+// Definition sets the function which returns the definition of an RRD
+// created with the data sources ds, which defaults to DefaultDefinition.
+// The start of the definition is set to precede the first update so it
+// mustn't be set by f.
+func Definition(f func(filename string, ds []string) *rrd.CreateRRD) func(*Writer) error {
+	return func(w *Writer) error {
+		if f == nil {
+			return rrd.ErrNilOption
+		}
+		w.def = f
+		return nil
+	}
+}
+
+// NoCreate disables the creation of RRDs which don't exist, fields mapped
+// to them are discarded.
+func NoCreate(w *Writer) error {
+	w.create = false
+	return nil
+}
+
+// Writer writes line protocol points to RRDs.
+// A Writer is safe for concurrent use.
+type Writer struct {
+	c      *rrd.Client
+	mapFn  MapFunc
+	def    func(filename string, ds []string) *rrd.CreateRRD
+	create bool
+	now    func() time.Time
+
+	m       sync.Mutex
+	layouts map[string][]string
+}
+
+// NewWriter returns a new Writer which creates and updates RRDs with c.
+func NewWriter(c *rrd.Client, opts ...func(*Writer) error) (*Writer, error) {
+	w := &Writer{
+		c:       c,
+		mapFn:   DefaultMap,
+		def:     DefaultDefinition,
+		create:  true,
+		now:     time.Now,
+		layouts: make(map[string][]string),
+	}
+	for _, o := range opts {
+		if o == nil {
+			return nil, rrd.ErrNilOption
+		}
+		if err := o(w); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// update is the values of the data sources of an RRD at a point in time.
+type update struct {
+	t      time.Time
+	values map[string]float64
+}
+
+// WriteLines parses and writes the line protocol points of data, whose
+// timestamps are in units of precision.
+func (w *Writer) WriteLines(ctx context.Context, data []byte, precision time.Duration) error {
+	points, err := Parse(data, precision, w.now())
+	if err != nil {
+		return err
+	}
+	return w.Write(ctx, points...)
+}
+
+// Write writes the numeric fields of points to their RRDs as a single
+// batch, creating those which don't exist. String fields are discarded.
+// Fields mapped to data sources an RRD doesn't have are discarded, with
+// the returned error satisfying errors.Is(err, ErrUnknownDS), but don't
+// prevent the remaining fields being written.
+func (w *Writer) Write(ctx context.Context, points ...*Point) error {
+	var files []string
+	updates := make(map[string][]*update)
+	times := make(map[string]map[int64]*update)
+	for _, p := range points {
+		for _, f := range p.Fields {
+			v, ok := f.Float()
+			if !ok {
+				continue
+			}
+			m, ok := w.mapFn(p, f.Key)
+			if !ok {
+				continue
+			}
+
+			byTime, seen := times[m.Filename]
+			if !seen {
+				byTime = make(map[int64]*update)
+				times[m.Filename] = byTime
+				files = append(files, m.Filename)
+			}
+			u, ok := byTime[p.Time.UnixNano()]
+			if !ok {
+				u = &update{t: p.Time, values: make(map[string]float64)}
+				byTime[p.Time.UnixNano()] = u
+				updates[m.Filename] = append(updates[m.Filename], u)
+			}
+			u.values[m.DS] = v
+		}
+	}
+
+	var errs []error
+	b := w.c.NewBatch()
+	var batched []string
+	for _, filename := range files {
+		us := updates[filename]
+		sort.SliceStable(us, func(i, j int) bool {
+			return us[i].t.Before(us[j].t)
+		})
+		layout, err := w.layout(ctx, filename, us)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if layout == nil {
+			continue
+		}
+
+		index := make(map[string]int, len(layout))
+		for i, ds := range layout {
+			index[ds] = i
+		}
+		samples := make([]rrd.Sample, 0, len(us))
+		for _, u := range us {
+			s := rrd.Sample{Time: u.t, Values: make([]float64, len(layout))}
+			for i := range s.Values {
+				s.Values[i] = math.NaN()
+			}
+			for ds, v := range u.values {
+				i, ok := index[ds]
+				if !ok {
+					errs = append(errs, fmt.Errorf("%w: %v: %v", ErrUnknownDS, filename, ds))
+					continue
+				}
+				s.Values[i] = v
+			}
+			samples = append(samples, s)
+		}
+		if err := b.Update(filename, samples...); err != nil {
+			return err
+		}
+		batched = append(batched, filename)
+	}
+
+	if b.Len() > 0 {
+		if err := b.ExecWithContext(ctx); err != nil {
+			w.invalidate(err, batched)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// invalidate removes the cached layouts of the RRDs whose update failed
+// with err, as they may have been deleted or recreated.
+func (w *Writer) invalidate(err error, files []string) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	var berr *rrd.BatchError
+	if !errors.As(err, &berr) {
+		for _, f := range files {
+			delete(w.layouts, f)
+		}
+		return
+	}
+	for _, e := range berr.Errors {
+		if e.Index < len(files) {
+			delete(w.layouts, files[e.Index])
+		}
+	}
+}
+
+// layout returns the names of the data sources of filename in order,
+// creating it with the data sources of us if it doesn't exist. A nil layout
+// is returned if the RRD doesn't exist and creation is disabled.
+func (w *Writer) layout(ctx context.Context, filename string, us []*update) ([]string, error) {
+	w.m.Lock()
+	layout, ok := w.layouts[filename]
+	w.m.Unlock()
+	if ok {
+		return layout, nil
+	}
+
+	info, err := w.c.InfoStructWithContext(ctx, filename)
+	switch {
+	case err == nil:
+		layout = make([]string, len(info.DS))
+		for _, ds := range info.DS {
+			if ds.Index < 0 || ds.Index >= len(layout) {
+				return nil, fmt.Errorf("%v: invalid index %v for ds %v", filename, ds.Index, ds.Name)
+			}
+			layout[ds.Index] = ds.Name
+		}
+	case !rrd.IsNotExist(err):
+		return nil, err
+	case !w.create:
+		return nil, nil
+	default:
+		if layout, err = w.createRRD(ctx, filename, us); err != nil {
+			return nil, err
+		}
+	}
+
+	w.m.Lock()
+	w.layouts[filename] = layout
+	w.m.Unlock()
+	return layout, nil
+}
+
+// createRRD creates filename with a data source for each of those updated
+// by us, in name order, returning their names. The RRD starts before the
+// first of us, which must be ordered by time.
+func (w *Writer) createRRD(ctx context.Context, filename string, us []*update) ([]string, error) {
+	seen := make(map[string]struct{})
+	var ds []string
+	for _, u := range us {
+		for name := range u.values {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				ds = append(ds, name)
+			}
+		}
+	}
+	if len(ds) == 0 {
+		return nil, fmt.Errorf("create %v: no data sources", filename)
+	}
+	sort.Strings(ds)
+
+	def := w.def(filename, ds)
+	def.Options = append(append([]rrd.CreateOption{}, def.Options...),
+		rrd.Start(us[0].t.Add(-time.Second)),
+		rrd.NoOverwrite(),
+	)
+	if err := w.c.CreateFromWithContext(ctx, filename, def); err != nil {
+		if rrd.IsExist(err) {
+			// Created concurrently, use its layout.
+			return w.layout(ctx, filename, nil)
+		}
+		return nil, fmt.Errorf("create %v: %w", filename, err)
+	}
+	return ds, nil
+}
@@ -0,0 +1,154 @@
+package rrdinflux
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+// newTestWriter returns a Writer which writes to a new rrdtest.Server on
+// which web1.rrd exists with the data sources b and a.
+func newTestWriter(t *testing.T, opts ...func(*Writer) error) (*Writer, *rrdtest.Server) {
+	t.Helper()
+
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+	})
+	s.HandleFunc("info", func(_ string, args []string) []string {
+		if args[0] != "cpu/web1.rrd" {
+			return []string{"-1 No such file: " + args[0]}
+		}
+		return []string{
+			"3 Info for cpu/web1.rrd follows",
+			"filename 2 cpu/web1.rrd",
+			"ds[b].index 1 0",
+			"ds[a].index 1 1",
+		}
+	})
+
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	w, err := NewWriter(c, opts...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	w.now = func() time.Time { return time.Unix(1500000300, 0) }
+	return w, s
+}
+
+func TestDefaultMap(t *testing.T) {
+	tests := []struct {
+		line     string
+		field    string
+		expected Mapping
+	}{
+		{"cpu a=1", "a", Mapping{Filename: "cpu.rrd", DS: "a"}},
+		{"cpu,host=web1,cpu=cpu0 a=1", "a", Mapping{Filename: "cpu/cpu0/web1.rrd", DS: "a"}},
+		{"disk,path=/var/log,dev=.. a=1", "a", Mapping{Filename: "disk/_/_var_log.rrd", DS: "a"}},
+		{"my\\ m a=1", "used.percent-of-total", Mapping{Filename: "my_m.rrd", DS: "used_percent_of_tot"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.line, func(t *testing.T) {
+			p, err := ParseLine(tc.line, time.Second, time.Now())
+			if !assert.NoError(t, err) {
+				return
+			}
+			m, ok := DefaultMap(p, tc.field)
+			assert.True(t, ok)
+			assert.Equal(t, tc.expected, m)
+		})
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	_, err := NewWriter(nil, nil)
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+	_, err = NewWriter(nil, Map(nil))
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+	_, err = NewWriter(nil, Definition(nil))
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+}
+
+func TestWriter(t *testing.T) {
+	w, s := newTestWriter(t)
+	ctx := context.Background()
+
+	lines := strings.Join([]string{
+		"cpu,host=web1 a=1,b=2,c=3 1500000060",
+		"cpu,host=web1 a=4 1500000000",
+		"mem,host=web1 used=5,free=6i,desc=\"x\" 1500000000",
+		"mem,host=web1 used=7 1500000060",
+		"cpu,host=web1 b=8 1500000000",
+		"load v=9",
+	}, "\n")
+	err := w.WriteLines(ctx, []byte(lines), time.Second)
+	assert.ErrorIs(t, err, ErrUnknownDS)
+	assert.Contains(t, err.Error(), "cpu/web1.rrd: c")
+
+	assert.Equal(t, []string{
+		"info cpu/web1.rrd",
+		"info mem/web1.rrd",
+		"create mem/web1.rrd -s 10 -b 1499999999 -O DS:free:GAUGE:20:U:U DS:used:GAUGE:20:U:U RRA:AVERAGE:0.5:1:8640 RRA:AVERAGE:0.5:6:10080 RRA:AVERAGE:0.5:360:8760",
+		"info load.rrd",
+		"create load.rrd -s 10 -b 1500000299 -O DS:v:GAUGE:20:U:U RRA:AVERAGE:0.5:1:8640 RRA:AVERAGE:0.5:6:10080 RRA:AVERAGE:0.5:360:8760",
+		"batch",
+		"update cpu/web1.rrd 1500000000:8:4 1500000060:2:1",
+		"update mem/web1.rrd 1500000000:6:5 1500000060:U:7",
+		"update load.rrd 1500000300:9",
+		".",
+	}, s.Received())
+
+	// Layouts are cached.
+	s.Reset()
+	assert.NoError(t, w.WriteLines(ctx, []byte("mem,host=web1 used=1 1500000120"), time.Second))
+	assert.Equal(t, []string{
+		"batch",
+		"update mem/web1.rrd 1500000120:U:1",
+		".",
+	}, s.Received())
+
+	// Failed updates invalidate the layout.
+	s.Reset()
+	s.Handle(".", "1 errors", "1 No such file: mem/web1.rrd")
+	assert.Error(t, w.WriteLines(ctx, []byte("mem,host=web1 used=1 1500000180"), time.Second))
+	s.Handle(".", "0 errors")
+	assert.NoError(t, w.WriteLines(ctx, []byte("mem,host=web1 used=1 1500000240"), time.Second))
+	assert.Equal(t, 1, s.Count("create mem/web1.rrd"))
+}
+
+func TestWriterNoCreate(t *testing.T) {
+	w, s := newTestWriter(t, NoCreate, Map(func(p *Point, field string) (Mapping, bool) {
+		if field == "skip" {
+			return Mapping{}, false
+		}
+		return Mapping{Filename: p.Measurement + ".rrd", DS: field}, true
+	}))
+
+	assert.NoError(t, w.WriteLines(context.Background(), []byte("cpu skip=1\nmissing a=1"), time.Second))
+	assert.Equal(t, []string{"info missing.rrd"}, s.Received())
+}
+
+func TestWriterCreateFailed(t *testing.T) {
+	w, s := newTestWriter(t)
+	s.Handle("create", "-1 RRD Error: Permission denied")
+
+	err := w.WriteLines(context.Background(), []byte("load v=1\ncpu,host=web1 a=1"), time.Second)
+	assert.ErrorIs(t, err, rrd.ErrPermissionDenied)
+	assert.Equal(t, 1, s.Count("update cpu/web1.rrd"))
+}
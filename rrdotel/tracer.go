@@ -0,0 +1,67 @@
+// Package rrdotel provides OpenTelemetry tracing for rrdcached clients.
+package rrdotel
+
+import (
+	"context"
+
+	rrd "github.com/thz/go-rrd"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ScopeName is the instrumentation scope name used for the tracer.
+	ScopeName = "github.com/thz/go-rrd/rrdotel"
+)
+
+// Attribute keys set on command spans.
+const (
+	CommandKey  = attribute.Key("rrdcached.command")
+	FilenameKey = attribute.Key("rrdcached.filename")
+	CodeKey     = attribute.Key("rrdcached.response_code")
+	RetriesKey  = attribute.Key("rrdcached.retries")
+)
+
+// Tracer is a rrd.Tracer which records each command as an OpenTelemetry span.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns a new Tracer using tp, if tp is nil the global
+// TracerProvider is used. Pass it to a client with rrd.Trace.
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(ScopeName)}
+}
+
+// Start implements rrd.Tracer.
+func (t *Tracer) Start(ctx context.Context, info rrd.CommandInfo) (context.Context, rrd.Span) {
+	attrs := []attribute.KeyValue{CommandKey.String(info.Cmd)}
+	if info.Filename != "" {
+		attrs = append(attrs, FilenameKey.String(info.Filename))
+	}
+	ctx, s := t.tracer.Start(ctx, "rrdcached "+info.Cmd,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, span{s}
+}
+
+// span is a rrd.Span wrapping an OpenTelemetry span.
+type span struct {
+	trace.Span
+}
+
+// End implements rrd.Span.
+func (s span) End(res rrd.CommandResult) {
+	s.SetAttributes(CodeKey.Int(res.Code), RetriesKey.Int(res.Retries))
+	if res.Err != nil {
+		s.RecordError(res.Err)
+		s.SetStatus(codes.Error, res.Err.Error())
+	}
+	s.Span.End()
+}
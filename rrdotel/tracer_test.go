@@ -0,0 +1,51 @@
+package rrdotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tr := NewTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	ctx, s := tr.Start(context.Background(), rrd.CommandInfo{Cmd: "update", Filename: "test.rrd"})
+	assert.True(t, trace.SpanFromContext(ctx).SpanContext().IsValid())
+	s.End(rrd.CommandResult{Code: 0, Retries: 1})
+
+	_, s = tr.Start(context.Background(), rrd.CommandInfo{Cmd: "stats"})
+	s.End(rrd.CommandResult{Code: -1, Err: errors.New("failed")})
+
+	spans := rec.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+
+	assert.Equal(t, "rrdcached update", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		CommandKey.String("update"),
+		FilenameKey.String("test.rrd"),
+		CodeKey.Int(0),
+		RetriesKey.Int(1),
+	}, spans[0].Attributes())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	assert.Equal(t, "rrdcached stats", spans[1].Name())
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		CommandKey.String("stats"),
+		CodeKey.Int(-1),
+		RetriesKey.Int(0),
+	}, spans[1].Attributes())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Len(t, spans[1].Events(), 1)
+}
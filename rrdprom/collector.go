@@ -0,0 +1,88 @@
+// Package rrdprom provides Prometheus metrics for rrdcached clients.
+package rrdprom
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects metrics about the commands performed by the clients it
+// observes. It implements both prometheus.Collector and rrd.Observer so can
+// be registered on a prometheus.Registerer and passed to rrd.Observe:
+//
+//	col := rrdprom.NewCollector("myapp")
+//	prometheus.MustRegister(col)
+//	c, err := rrd.NewClient(addr, rrd.Observe(col))
+type Collector struct {
+	commands   *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	reconnects *prometheus.CounterVec
+}
+
+// NewCollector returns a new Collector whose metrics are prefixed with namespace.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rrdcached",
+			Name:      "commands_total",
+			Help:      "Total number of commands performed.",
+		}, []string{"command"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rrdcached",
+			Name:      "command_errors_total",
+			Help:      "Total number of commands which failed.",
+		}, []string{"command"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rrdcached",
+			Name:      "command_duration_seconds",
+			Help:      "Time taken to perform commands.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 8),
+		}, []string{"command"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rrdcached",
+			Name:      "reconnects_total",
+			Help:      "Total number of attempts to reestablish the connection to the server.",
+		}, []string{"result"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.commands.Describe(ch)
+	c.errors.Describe(ch)
+	c.duration.Describe(ch)
+	c.reconnects.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.commands.Collect(ch)
+	c.errors.Collect(ch)
+	c.duration.Collect(ch)
+	c.reconnects.Collect(ch)
+}
+
+// CommandDone implements rrd.Observer.
+func (c *Collector) CommandDone(_ context.Context, cmd string, d time.Duration, err error) {
+	c.commands.WithLabelValues(cmd).Inc()
+	c.duration.WithLabelValues(cmd).Observe(d.Seconds())
+	if err != nil {
+		c.errors.WithLabelValues(cmd).Inc()
+	}
+}
+
+// Reconnected implements rrd.Observer.
+func (c *Collector) Reconnected(_ context.Context, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	c.reconnects.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,48 @@
+package rrdprom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	col := NewCollector("test")
+	reg := prometheus.NewPedanticRegistry()
+	if !assert.NoError(t, reg.Register(col)) {
+		return
+	}
+
+	ctx := context.Background()
+	col.CommandDone(ctx, "ping", time.Millisecond, nil)
+	col.CommandDone(ctx, "ping", time.Millisecond, nil)
+	col.CommandDone(ctx, "update", time.Millisecond*2, errors.New("failed"))
+	col.Reconnected(ctx, errors.New("failed"))
+	col.Reconnected(ctx, nil)
+
+	expected := `
+# HELP test_rrdcached_commands_total Total number of commands performed.
+# TYPE test_rrdcached_commands_total counter
+test_rrdcached_commands_total{command="ping"} 2
+test_rrdcached_commands_total{command="update"} 1
+# HELP test_rrdcached_command_errors_total Total number of commands which failed.
+# TYPE test_rrdcached_command_errors_total counter
+test_rrdcached_command_errors_total{command="update"} 1
+# HELP test_rrdcached_reconnects_total Total number of attempts to reestablish the connection to the server.
+# TYPE test_rrdcached_reconnects_total counter
+test_rrdcached_reconnects_total{result="failure"} 1
+test_rrdcached_reconnects_total{result="success"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"test_rrdcached_commands_total",
+		"test_rrdcached_command_errors_total",
+		"test_rrdcached_reconnects_total",
+	))
+	assert.Equal(t, 2, testutil.CollectAndCount(col, "test_rrdcached_command_duration_seconds"))
+}
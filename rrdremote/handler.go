@@ -0,0 +1,331 @@
+// Package rrdremote implements the Prometheus remote write endpoint,
+// persisting samples to RRDs via rrdcached so rrdtool can act as long term
+// downsampled storage for Prometheus.
+//
+// Each series is mapped to an RRD by a MapFunc and RRDs are created on
+// first use. The samples of each request are sent to rrdcached as a single
+// batch.
+//
+// The remote write messages are defined in remote.proto, from which
+// remote.pb.go is generated.
+package rrdremote
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative remote.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	rrd "github.com/thz/go-rrd"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MaxBodySize is the maximum size of a compressed write request body.
+	MaxBodySize = 32 << 20
+
+	// nameLabel is the label holding the metric name of a series.
+	nameLabel = "__name__"
+)
+
+// MapFunc maps the labels of a series, ordered by name, to the filename of
+// the RRD its samples are written to, returning false to discard them.
+type MapFunc func(labels []*Label) (string, bool)
+
+// DefaultMap writes each series to an RRD named after its labels within a
+// directory named after its metric, so up{instance="a:9100",job="node"} is
+// written to up/instance=a_9100,job=node.rrd, and series without labels
+// other than their name to <name>.rrd. Characters which aren't valid in
+// filenames are replaced with underscores.
+func DefaultMap(labels []*Label) (string, bool) {
+	var name string
+	parts := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if l.Name == nameLabel {
+			name = sanitize(l.Value)
+			continue
+		}
+		parts = append(parts, sanitize(l.Name)+"="+sanitize(l.Value))
+	}
+	if name == "" || name == "." || name == ".." {
+		return "", false
+	}
+
+	if len(parts) == 0 {
+		return name + ".rrd", true
+	}
+	return name + "/" + strings.Join(parts, ",") + ".rrd", true
+}
+
+// sanitize replaces the characters of s which aren't valid in filenames
+// with underscores.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '_', r == '-', r == '.',
+			r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// DefaultDefinition returns the definition of RRDs created for new series
+// by default, a single GAUGE data source with a 60 second step, keeping
+// the average and maximum of two days of minutes, two weeks of five
+// minutes and two years of hours.
+func DefaultDefinition() *rrd.CreateRRD {
+	def := rrd.NewCreateRRD([]rrd.DS{"DS:value:GAUGE:300:U:U"}, nil).WithStep(time.Minute)
+	for _, cf := range []rrd.ConsolidationFunc{rrd.Average, rrd.Max} {
+		def.WithRRA(
+			rrd.NewRRA(fmt.Sprintf("RRA:%v:0.5:1:2880", cf)),
+			rrd.NewRRA(fmt.Sprintf("RRA:%v:0.5:5:4032", cf)),
+			rrd.NewRRA(fmt.Sprintf("RRA:%v:0.5:60:17520", cf)),
+		)
+	}
+	return def
+}
+
+// Map sets the function which maps series to RRDs, which defaults to
+// DefaultMap.
+func Map(f MapFunc) func(*Handler) error {
+	return func(h *Handler) error {
+		if f == nil {
+			return rrd.ErrNilOption
+		}
+		h.mapFn = f
+		return nil
+	}
+}
+
+// Definition sets the definition of RRDs created for new series, which
+// defaults to DefaultDefinition. It must have a single data source and no
+// start, which is set to precede the first sample.
+func Definition(def *rrd.CreateRRD) func(*Handler) error {
+	return func(h *Handler) error {
+		if def == nil {
+			return fmt.Errorf("%w: nil definition", rrd.ErrInvalidArg)
+		}
+		if len(def.DS) != 1 {
+			return fmt.Errorf("%w: definition has %v data sources", rrd.ErrInvalidArg, len(def.DS))
+		}
+		if err := def.Validate(); err != nil {
+			return err
+		}
+		h.def = def
+		return nil
+	}
+}
+
+// NoCreate disables the creation of RRDs for new series, samples for RRDs
+// which don't exist are dropped.
+func NoCreate(h *Handler) error {
+	h.create = false
+	return nil
+}
+
+// Logger sets the logger used to report failed requests and dropped
+// samples, which defaults to slog.Default.
+func Logger(l *slog.Logger) func(*Handler) error {
+	return func(h *Handler) error {
+		if l == nil {
+			return rrd.ErrNilOption
+		}
+		h.logger = l
+		return nil
+	}
+}
+
+// Handler is an http.Handler which serves Prometheus remote write requests.
+// A Handler is safe for concurrent use.
+type Handler struct {
+	c      *rrd.Client
+	mapFn  MapFunc
+	def    *rrd.CreateRRD
+	create bool
+	logger *slog.Logger
+
+	m       sync.Mutex
+	created map[string]struct{}
+}
+
+// NewHandler returns a new Handler which creates and updates RRDs with c.
+func NewHandler(c *rrd.Client, opts ...func(*Handler) error) (*Handler, error) {
+	h := &Handler{
+		c:       c,
+		mapFn:   DefaultMap,
+		def:     DefaultDefinition(),
+		create:  true,
+		logger:  slog.Default(),
+		created: make(map[string]struct{}),
+	}
+	for _, o := range opts {
+		if o == nil {
+			return nil, rrd.ErrNilOption
+		}
+		if err := o(h); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler, accepting snappy compressed
+// WriteRequests POSTed to it.
+//
+// Samples older than the last update of their RRD, such as those resent
+// after a failed request, are dropped rather than failing the request so
+// Prometheus doesn't retry them indefinitely.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	compressed, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case len(compressed) > MaxBodySize:
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("snappy: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var req WriteRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		http.Error(w, fmt.Sprintf("unmarshal: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Write(r.Context(), &req); err != nil {
+		h.logger.ErrorContext(r.Context(), "remote write failed", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Write writes the samples of req to their RRDs as a single batch,
+// creating those which don't exist.
+func (h *Handler) Write(ctx context.Context, req *WriteRequest) error {
+	b := h.c.NewBatch()
+	var files []string
+	for _, ts := range req.Timeseries {
+		if len(ts.Samples) == 0 {
+			continue
+		}
+
+		labels := append([]*Label(nil), ts.Labels...)
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+		filename, ok := h.mapFn(labels)
+		if !ok {
+			continue
+		}
+
+		samples := toSamples(ts.Samples)
+		if err := h.ensure(ctx, filename, samples[0].Time); err != nil {
+			return err
+		}
+		if err := b.Update(filename, samples...); err != nil {
+			return err
+		}
+		files = append(files, filename)
+	}
+
+	if b.Len() == 0 {
+		return nil
+	}
+	err := b.ExecWithContext(ctx)
+	var berr *rrd.BatchError
+	if !errors.As(err, &berr) {
+		return err
+	}
+
+	var errs []error
+	for _, e := range berr.Errors {
+		switch {
+		case rrd.IsIllegalUpdate(e.Err):
+			h.logger.DebugContext(ctx, "dropped old samples", "filename", files[e.Index], "error", e.Err)
+			continue
+		case rrd.IsNotExist(e.Err) && !h.create:
+			h.logger.DebugContext(ctx, "dropped samples of missing rrd", "filename", files[e.Index])
+			continue
+		case rrd.IsNotExist(e.Err):
+			h.forget(files[e.Index])
+		}
+		errs = append(errs, fmt.Errorf("%v: %w", files[e.Index], e.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// toSamples returns the RRD samples of samples, which are ordered by time,
+// keeping only the last of those in the same second as RRDs don't support
+// sub-second updates.
+func toSamples(samples []*Sample) []rrd.Sample {
+	res := make([]rrd.Sample, 0, len(samples))
+	for _, s := range samples {
+		t := time.Unix(s.Timestamp/1000, 0)
+		if n := len(res); n > 0 && res[n-1].Time.Equal(t) {
+			res[n-1].Values[0] = s.Value
+			continue
+		}
+		res = append(res, rrd.Sample{Time: t, Values: []float64{s.Value}})
+	}
+	return res
+}
+
+// ensure creates filename, starting before start, if it hasn't been seen
+// before.
+func (h *Handler) ensure(ctx context.Context, filename string, start time.Time) error {
+	if !h.create {
+		return nil
+	}
+
+	h.m.Lock()
+	_, ok := h.created[filename]
+	h.m.Unlock()
+	if ok {
+		return nil
+	}
+
+	def := *h.def
+	def.Options = append(append([]rrd.CreateOption{}, def.Options...),
+		rrd.Start(start.Add(-time.Second)),
+		rrd.NoOverwrite(),
+	)
+	if err := h.c.CreateFromWithContext(ctx, filename, &def); err != nil && !rrd.IsExist(err) {
+		return fmt.Errorf("create %v: %w", filename, err)
+	}
+
+	h.m.Lock()
+	h.created[filename] = struct{}{}
+	h.m.Unlock()
+	return nil
+}
+
+// forget removes filename from the RRDs known to exist, so it's recreated
+// by the next write.
+func (h *Handler) forget(filename string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	delete(h.created, filename)
+}
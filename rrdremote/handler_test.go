@@ -0,0 +1,191 @@
+package rrdremote
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestHandler returns a Handler which writes to a new rrdtest.Server.
+func newTestHandler(t *testing.T, opts ...func(*Handler) error) (*Handler, *rrdtest.Server) {
+	t.Helper()
+
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+	})
+
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	h, err := NewHandler(c, opts...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return h, s
+}
+
+// series returns a TimeSeries with labels, given as name value pairs, and
+// samples, given as timestamp value pairs.
+func series(labels []string, samples ...float64) *TimeSeries {
+	ts := &TimeSeries{}
+	for i := 0; i < len(labels); i += 2 {
+		ts.Labels = append(ts.Labels, &Label{Name: labels[i], Value: labels[i+1]})
+	}
+	for i := 0; i < len(samples); i += 2 {
+		ts.Samples = append(ts.Samples, &Sample{Timestamp: int64(samples[i]), Value: samples[i+1]})
+	}
+	return ts
+}
+
+// encode returns the snappy compressed encoding of req.
+func encode(t *testing.T, req *WriteRequest) []byte {
+	t.Helper()
+	data, err := proto.Marshal(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return snappy.Encode(nil, data)
+}
+
+func TestDefaultMap(t *testing.T) {
+	tests := []struct {
+		labels   []string
+		expected string
+		ok       bool
+	}{
+		{[]string{"__name__", "up"}, "up.rrd", true},
+		{[]string{"__name__", "up", "instance", "a:9100", "job", "node"}, "up/instance=a_9100,job=node.rrd", true},
+		{[]string{"__name__", "..", "job", "node"}, "", false},
+		{[]string{"job", "node"}, "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expected, func(t *testing.T) {
+			filename, ok := DefaultMap(series(tc.labels).Labels)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, filename)
+		})
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	_, err := NewHandler(nil, nil)
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+	_, err = NewHandler(nil, Map(nil))
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+	_, err = NewHandler(nil, Logger(nil))
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+	_, err = NewHandler(nil, Definition(nil))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+	_, err = NewHandler(nil, Definition(rrd.NewCreateRRD([]rrd.DS{"DS:a:GAUGE:1:U:U", "DS:b:GAUGE:1:U:U"}, nil)))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+	_, err = NewHandler(nil, Definition(rrd.NewCreateRRD([]rrd.DS{"DS:a:GAUGE:1:U:U"}, nil)))
+	assert.ErrorIs(t, err, rrd.ErrNoRRA)
+}
+
+func TestHandler(t *testing.T) {
+	def := rrd.NewCreateRRD([]rrd.DS{"DS:v:GAUGE:120:U:U"}, []rrd.RRA{"RRA:AVERAGE:0.5:1:10"})
+	h, s := newTestHandler(t, Definition(def))
+
+	req := &WriteRequest{Timeseries: []*TimeSeries{
+		series([]string{"job", "node", "__name__", "up"}, 1500000000000, 1, 1500000000500, 2, 1500000060000, math.NaN()),
+		series([]string{"__name__", "load"}, 1500000000000, 0.5),
+		series([]string{"job", "node"}, 1500000000000, 3),
+		series([]string{"__name__", "empty"}),
+	}}
+	body := encode(t, req)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.Equal(t, []string{
+		"create up/job=node.rrd -b 1499999999 -O DS:v:GAUGE:120:U:U RRA:AVERAGE:0.5:1:10",
+		"create load.rrd -b 1499999999 -O DS:v:GAUGE:120:U:U RRA:AVERAGE:0.5:1:10",
+		"batch",
+		"update up/job=node.rrd 1500000000:2 1500000060:U",
+		"update load.rrd 1500000000:0.5",
+		".",
+	}, s.Received())
+
+	// Resent samples are dropped without error, RRDs are only created once.
+	s.Reset()
+	s.Handle(".", "2 errors", "1 illegal attempt to update using time 1500000000 when last update time is 1500000060 (minimum one second step)", "2 illegal attempt to update using time 1500000000 when last update time is 1500000000 (minimum one second step)")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.Equal(t, 0, s.Count("create"))
+
+	// Missing RRDs are recreated by the next request.
+	s.Handle(".", "1 errors", "2 No such file: load.rrd")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	s.Handle(".", "0 errors")
+	assert.NoError(t, h.Write(context.Background(), req))
+	assert.Equal(t, []string{"create load.rrd -b 1499999999 -O DS:v:GAUGE:120:U:U RRA:AVERAGE:0.5:1:10"}, filter(s.Received(), "create"))
+}
+
+// filter returns the lines starting with prefix.
+func filter(lines []string, prefix string) []string {
+	var res []string
+	for _, l := range lines {
+		if strings.HasPrefix(l, prefix) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func TestHandlerNoCreate(t *testing.T) {
+	h, s := newTestHandler(t, NoCreate)
+	s.Handle(".", "1 errors", "1 No such file: up.rrd")
+
+	req := &WriteRequest{Timeseries: []*TimeSeries{series([]string{"__name__", "up"}, 1500000000000, 1)}}
+	assert.NoError(t, h.Write(context.Background(), req))
+	assert.Equal(t, 0, s.Count("create"))
+}
+
+func TestHandlerInvalid(t *testing.T) {
+	h, s := newTestHandler(t)
+	s.Handle("create", "-1 RRD Error: Permission denied")
+
+	tests := []struct {
+		name   string
+		method string
+		body   []byte
+		status int
+	}{
+		{"method", http.MethodGet, nil, http.StatusMethodNotAllowed},
+		{"snappy", http.MethodPost, []byte("not snappy"), http.StatusBadRequest},
+		{"proto", http.MethodPost, snappy.Encode(nil, []byte{0xff}), http.StatusBadRequest},
+		{"create", http.MethodPost, encode(t, &WriteRequest{Timeseries: []*TimeSeries{series([]string{"__name__", "up"}, 1500000000000, 1)}}), http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tc.method, "/api/v1/write", bytes.NewReader(tc.body)))
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
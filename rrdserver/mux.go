@@ -0,0 +1,45 @@
+package rrdserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// Mux is a Handler which dispatches commands to the Handler registered
+// for the command, responding to unregistered commands with an unknown
+// command error.
+type Mux struct {
+	m        sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewMux returns a new empty Mux.
+func NewMux() *Mux {
+	return &Mux{handlers: make(map[string]Handler)}
+}
+
+// Handle registers h as the handler for cmd, which is case insensitive.
+func (m *Mux) Handle(cmd string, h Handler) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.handlers[strings.ToLower(cmd)] = h
+}
+
+// HandleFunc registers f as the handler for cmd, which is case insensitive.
+func (m *Mux) HandleFunc(cmd string, f func(ctx context.Context, req *Request) (*Response, error)) {
+	m.Handle(cmd, HandlerFunc(f))
+}
+
+// ServeRRD implements Handler.
+func (m *Mux) ServeRRD(ctx context.Context, req *Request) (*Response, error) {
+	m.m.RLock()
+	h, ok := m.handlers[req.Cmd]
+	m.m.RUnlock()
+	if !ok {
+		return nil, rrd.NewError(-1, "Unknown command: "+strings.ToUpper(req.Cmd))
+	}
+	return h.ServeRRD(ctx, req)
+}
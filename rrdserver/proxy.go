@@ -0,0 +1,49 @@
+package rrdserver
+
+import (
+	"context"
+	"errors"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// Proxy is a Handler which forwards commands to rrdcached using clients
+// from a pool, relaying the responses unchanged.
+type Proxy struct {
+	pool *rrd.Pool
+}
+
+// NewProxy returns a new Proxy which forwards commands using clients from pool.
+func NewProxy(pool *rrd.Pool) *Proxy {
+	return &Proxy{pool: pool}
+}
+
+// ServeRRD implements Handler.
+func (p *Proxy) ServeRRD(ctx context.Context, req *Request) (*Response, error) {
+	args := make([]interface{}, len(req.Args))
+	for i, a := range req.Args {
+		args[i] = a
+	}
+	cmd := rrd.NewCmd(req.Cmd).WithArgs(args...)
+
+	var st rrd.Status
+	var lines []string
+	err := p.pool.Do(ctx, func(c *rrd.Client) (err error) {
+		lines, err = c.ExecCmdWithContext(ctx, cmd, rrd.WithStatus(&st))
+		return err
+	})
+	if err != nil {
+		// Unwrap the CommandError so the servers error is relayed as is.
+		var rerr *rrd.Error
+		if errors.As(err, &rerr) {
+			return nil, rerr
+		}
+		return nil, err
+	}
+
+	if st.Count == 0 {
+		// The message is returned as the only line.
+		return &Response{Message: st.Msg}, nil
+	}
+	return &Response{Message: st.Msg, Lines: lines}, nil
+}
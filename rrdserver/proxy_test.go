@@ -0,0 +1,58 @@
+package rrdserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+func TestProxy(t *testing.T) {
+	backend, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, backend.Close())
+	}()
+	backend.Handle("last", "0 1499981700")
+	backend.Handle("list", "2 RRDs", "/test.rrd", "/sub/other.rrd")
+	backend.Handle("flush", "-1 No such file: /missing.rrd")
+
+	pool, err := rrd.NewPool(backend.Addr, 2, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, pool.Close())
+	}()
+
+	_, addr := newTestServer(t, NewProxy(pool))
+	c, err := rrd.NewClient(addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+
+	last, err := c.Last("test.rrd")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1499981700, 0), last)
+
+	var st rrd.Status
+	names, err := c.ExecCmd(rrd.NewCmd("list").WithArgs("/"), rrd.WithStatus(&st))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/test.rrd", "/sub/other.rrd"}, names)
+	assert.Equal(t, "RRDs", st.Msg)
+
+	assert.NoError(t, c.Update("my test.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}))
+	assert.Equal(t, 1, backend.Count(`update my\ test.rrd 1499968800:1`))
+
+	err = c.Flush("missing.rrd")
+	assert.True(t, rrd.IsNotExist(err))
+}
@@ -0,0 +1,381 @@
+// Package rrdserver implements the server side of the rrdcached line
+// protocol with pluggable handlers, allowing proxies, shims, multiplexers
+// and test doubles to be built which existing rrdcached clients, such as
+// rrdtool with --daemon, can talk to.
+package rrdserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+const (
+	// DefaultMaxLineSize is the default maximum length of a command line.
+	DefaultMaxLineSize = 64 * 1024
+
+	// batchGoAhead is the response to the batch command.
+	batchGoAhead = "Go ahead.  End with dot '.' on its own line."
+)
+
+// ErrServerClosed is returned by Serve after the server has been closed.
+var ErrServerClosed = errors.New("rrdserver: server closed")
+
+// errLineTooLong is returned by readCmd if a line exceeds the maximum size.
+var errLineTooLong = errors.New("line too long")
+
+// Request is a command received from a client.
+type Request struct {
+	// Cmd is the lower case command verb.
+	Cmd string
+
+	// Args are the unescaped arguments of the command.
+	Args []string
+
+	// RemoteAddr is the address of the client.
+	RemoteAddr net.Addr
+}
+
+// Response is the successful response to a command, which is sent as a
+// status line of the number of lines and Message, which defaults to
+// Success, followed by Lines.
+type Response struct {
+	Message string
+	Lines   []string
+}
+
+// Handler responds to commands.
+//
+// Errors are sent to the client as an error status line of the form
+// "-1 <message>", with the code and message of an *rrd.Error used as is.
+type Handler interface {
+	ServeRRD(ctx context.Context, req *Request) (*Response, error)
+}
+
+// HandlerFunc is an adapter which allows a function to be used as a Handler.
+type HandlerFunc func(ctx context.Context, req *Request) (*Response, error)
+
+// ServeRRD calls f(ctx, req).
+func (f HandlerFunc) ServeRRD(ctx context.Context, req *Request) (*Response, error) {
+	return f(ctx, req)
+}
+
+// Server serves the rrdcached protocol, passing the commands of clients to
+// its Handler. The quit and batch commands are handled by the server, with
+// the commands of a batch passed to the Handler individually.
+type Server struct {
+	// Handler handles the commands of clients.
+	Handler Handler
+
+	// Logger logs connection errors, if set.
+	Logger *slog.Logger
+
+	// MaxLineSize is the maximum length of a command line, by default
+	// DefaultMaxLineSize. Clients which send a longer line are sent an
+	// error and disconnected.
+	MaxLineSize int
+
+	m         sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewServer returns a new Server which passes commands to h.
+func NewServer(h Handler) *Server {
+	return &Server{Handler: h}
+}
+
+// ListenAndServe listens on the network address addr and serves
+// connections with h.
+func ListenAndServe(network, addr string, h Handler) error {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return NewServer(h).Serve(l)
+}
+
+// init initialises the servers state, the caller must hold its lock.
+func (s *Server) init() {
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+		s.conns = make(map[net.Conn]struct{})
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// Serve accepts connections on l, serving each in a new goroutine, until
+// the server is closed when it returns ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	s.m.Lock()
+	s.init()
+	if s.closed {
+		s.m.Unlock()
+		l.Close() // nolint: errcheck
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.wg.Add(1)
+	s.m.Unlock()
+	defer s.wg.Done()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.m.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.m.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.m.Lock()
+		if s.closed {
+			s.m.Unlock()
+			conn.Close() // nolint: errcheck
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.m.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes the servers listeners and connections, cancelling the
+// context of the commands in progress and waiting for them to complete.
+func (s *Server) Close() error {
+	s.m.Lock()
+	s.init()
+	s.closed = true
+	s.cancel()
+	var errs []error
+	for l := range s.listeners {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for c := range s.conns {
+		c.Close() // nolint: errcheck
+	}
+	s.m.Unlock()
+
+	s.wg.Wait()
+	return errors.Join(errs...)
+}
+
+// logger returns the servers logger.
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.New(discardHandler{})
+}
+
+// maxLineSize returns the maximum length of a command line.
+func (s *Server) maxLineSize() int {
+	if s.MaxLineSize > 0 {
+		return s.MaxLineSize
+	}
+	return DefaultMaxLineSize
+}
+
+// serveConn processes the commands sent on conn until the client quits
+// or disconnects.
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.m.Lock()
+		delete(s.conns, conn)
+		s.m.Unlock()
+		conn.Close() // nolint: errcheck
+		s.wg.Done()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	size := s.maxLineSize()
+	for {
+		fields, err := readCmd(r, size)
+		if err != nil {
+			s.readFailed(conn, w, err)
+			return
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		req := &Request{Cmd: strings.ToLower(fields[0]), Args: fields[1:], RemoteAddr: conn.RemoteAddr()}
+		switch req.Cmd {
+		case "quit":
+			return
+		case "batch":
+			if err = s.batch(r, w, req, size); errors.Is(err, errLineTooLong) {
+				s.readFailed(conn, w, err)
+				return
+			}
+		default:
+			err = writeResponse(w, s.serve(req))
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			s.logger().Warn("write failed", "remote", conn.RemoteAddr(), "error", err)
+			return
+		}
+	}
+}
+
+// readFailed handles the error err reading a command from conn, sending an
+// error to the client if the line was too long.
+func (s *Server) readFailed(conn net.Conn, w *bufio.Writer, err error) {
+	if errors.Is(err, errLineTooLong) {
+		if werr := writeResponse(w, []string{"-1 " + err.Error()}); werr == nil {
+			w.Flush() // nolint: errcheck
+		}
+	}
+	if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		s.logger().Warn("read failed", "remote", conn.RemoteAddr(), "error", err)
+	}
+}
+
+// serve returns the status line and lines of the response to req.
+func (s *Server) serve(req *Request) []string {
+	resp, err := s.Handler.ServeRRD(s.ctx, req)
+	if err != nil {
+		var rerr *rrd.Error
+		if errors.As(err, &rerr) && rerr.Code < 0 {
+			return []string{fmt.Sprintf("%v %v", rerr.Code, rerr.Msg)}
+		}
+		return []string{fmt.Sprintf("-1 %v", err)}
+	}
+	if resp == nil {
+		resp = &Response{}
+	}
+	msg := resp.Message
+	if msg == "" {
+		msg = "Success"
+	}
+
+	lines := make([]string, 0, len(resp.Lines)+1)
+	lines = append(lines, fmt.Sprintf("%v %v", len(resp.Lines), msg))
+	return append(lines, resp.Lines...)
+}
+
+// batch processes a batch of commands, which are terminated by a line
+// containing a single dot, responding with the errors of those which
+// failed identified by their one based position in the batch.
+func (s *Server) batch(r *bufio.Reader, w *bufio.Writer, req *Request, size int) error {
+	if err := writeResponse(w, []string{"0 " + batchGoAhead}); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var errs []string
+	for n := 1; ; n++ {
+		fields, err := readCmd(r, size)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 1 && fields[0] == "." {
+			break
+		}
+		if len(fields) == 0 {
+			n--
+			continue
+		}
+
+		resp := s.serve(&Request{Cmd: strings.ToLower(fields[0]), Args: fields[1:], RemoteAddr: req.RemoteAddr})
+		if strings.HasPrefix(resp[0], "-") {
+			_, msg, _ := strings.Cut(resp[0], " ")
+			errs = append(errs, fmt.Sprintf("%v %v", n, msg))
+		}
+	}
+
+	return writeResponse(w, append([]string{fmt.Sprintf("%v errors", len(errs))}, errs...))
+}
+
+// writeResponse writes lines to w.
+func writeResponse(w io.Writer, lines []string) error {
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// readCmd reads a command line from r, returning its unescaped fields, or
+// errLineTooLong if it's longer than size.
+func readCmd(r *bufio.Reader, size int) ([]string, error) {
+	var l []byte
+	for {
+		frag, err := r.ReadSlice('\n')
+		l = append(l, frag...)
+		if len(bytes.TrimRight(l, "\r\n")) > size {
+			return nil, errLineTooLong
+		}
+
+		switch {
+		case err == nil:
+			return splitFields(string(bytes.TrimRight(l, "\r\n"))), nil
+		case errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF) && len(l) > 0:
+			return splitFields(string(bytes.TrimRight(l, "\r"))), nil
+		default:
+			return nil, err
+		}
+	}
+}
+
+// splitFields splits l into space separated fields, treating spaces and
+// backslashes escaped with a backslash as literals.
+func splitFields(l string) []string {
+	var fields []string
+	var b strings.Builder
+	var inField bool
+	for i := 0; i < len(l); i++ {
+		switch {
+		case l[i] == '\\' && i+1 < len(l):
+			i++
+			b.WriteByte(l[i])
+			inField = true
+		case l[i] == ' ':
+			if inField {
+				fields = append(fields, b.String())
+				b.Reset()
+				inField = false
+			}
+		default:
+			b.WriteByte(l[i])
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, b.String())
+	}
+	return fields
+}
+
+// discardHandler is a slog.Handler which discards all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
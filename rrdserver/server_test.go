@@ -0,0 +1,222 @@
+package rrdserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+)
+
+// newTestServer returns a running server with h and its address.
+func newTestServer(t *testing.T, h Handler) (*Server, string) {
+	t.Helper()
+
+	s := NewServer(h)
+	return s, serveTest(t, s)
+}
+
+// serveTest starts s, closing it when the test completes, and returns its
+// address.
+func serveTest(t *testing.T, s *Server) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(l)
+	}()
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+		assert.ErrorIs(t, <-done, ErrServerClosed)
+	})
+
+	return l.Addr().String()
+}
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"", nil},
+		{"ping", []string{"ping"}},
+		{"update  test.rrd N:1", []string{"update", "test.rrd", "N:1"}},
+		{`flush my\ test.rrd`, []string{"flush", "my test.rrd"}},
+		{`flush a\\b`, []string{"flush", `a\b`}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.line, func(t *testing.T) {
+			assert.Equal(t, tc.expected, splitFields(tc.line))
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	var mtx sync.Mutex
+	var updates [][]string
+	received := func() [][]string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return updates
+	}
+	mux := NewMux()
+	mux.HandleFunc("PING", func(context.Context, *Request) (*Response, error) {
+		return &Response{Message: "PONG"}, nil
+	})
+	mux.HandleFunc("last", func(context.Context, *Request) (*Response, error) {
+		return &Response{Message: "1499981700"}, nil
+	})
+	mux.HandleFunc("stats", func(context.Context, *Request) (*Response, error) {
+		return &Response{Message: "Statistics follow", Lines: []string{"QueueLength: 3", "TreeDepth: 2"}}, nil
+	})
+	mux.HandleFunc("update", func(_ context.Context, req *Request) (*Response, error) {
+		if req.Args[0] == "missing.rrd" {
+			return nil, rrd.NewError(-1, "No such file: missing.rrd")
+		}
+		mtx.Lock()
+		updates = append(updates, req.Args)
+		mtx.Unlock()
+		return &Response{Message: "errors, enqueued 1 value(s)."}, nil
+	})
+	mux.HandleFunc("flush", func(context.Context, *Request) (*Response, error) {
+		return nil, errors.New("flush failed")
+	})
+
+	_, addr := newTestServer(t, mux)
+	c, err := rrd.NewClient(addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+
+	last, err := c.Last("test.rrd")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1499981700, 0), last)
+
+	stats, err := c.Stats()
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), stats.QueueLength)
+		assert.Equal(t, int64(2), stats.TreeDepth)
+	}
+
+	assert.NoError(t, c.Update("my test.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}))
+	assert.Equal(t, [][]string{{"my test.rrd", "1499968800:1"}}, received())
+
+	err = c.Update("missing.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}})
+	assert.True(t, rrd.IsNotExist(err))
+
+	err = c.Flush("test.rrd")
+	var rerr *rrd.Error
+	if assert.True(t, errors.As(err, &rerr)) {
+		assert.Equal(t, "flush failed", rerr.Msg)
+	}
+
+	_, err = c.Exec("unknown")
+	assert.ErrorIs(t, err, rrd.ErrNotSupported)
+
+	b := c.NewBatch()
+	assert.NoError(t, b.Update("a.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}))
+	assert.NoError(t, b.Update("missing.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{2}}))
+	assert.NoError(t, b.Update("b.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{3}}))
+	err = b.Exec()
+	var berr *rrd.BatchError
+	if assert.True(t, errors.As(err, &berr)) && assert.Len(t, berr.Errors, 1) {
+		assert.Equal(t, 1, berr.Errors[0].Index)
+		assert.True(t, rrd.IsNotExist(berr.Errors[0].Err))
+	}
+	assert.Len(t, received(), 3)
+
+	// Connection is still usable after a batch.
+	assert.NoError(t, c.Ping())
+}
+
+func TestServerQuit(t *testing.T) {
+	_, addr := newTestServer(t, NewMux())
+	conn, err := net.Dial("tcp", addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close() // nolint: errcheck
+
+	_, err = conn.Write([]byte("bogus\nquit\n"))
+	assert.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	l, err := r.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "-1 Unknown command: BOGUS\n", l)
+
+	_, err = r.ReadString('\n')
+	assert.Error(t, err)
+}
+
+func TestServerMaxLineSize(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("flush", func(context.Context, *Request) (*Response, error) {
+		return nil, nil
+	})
+	s := NewServer(mux)
+	s.MaxLineSize = 16
+	addr := serveTest(t, s)
+
+	for name, data := range map[string]string{
+		"command": "flush a.rrd\nflush 0123456789.rrd\nflush a.rrd\n",
+		"batch":   "flush a.rrd\nbatch\nflush 0123456789.rrd\n.\n",
+		"no-eol":  "flush a.rrd\nflush " + strings.Repeat("a", 8192),
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close() // nolint: errcheck
+
+			_, err = conn.Write([]byte(data))
+			assert.NoError(t, err)
+
+			r := bufio.NewReader(conn)
+			l, err := r.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, "0 Success\n", l)
+			if name == "batch" {
+				l, err = r.ReadString('\n')
+				assert.NoError(t, err)
+				assert.Equal(t, "0 "+batchGoAhead+"\n", l)
+			}
+
+			l, err = r.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, "-1 line too long\n", l)
+
+			_, err = r.ReadString('\n')
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	s := NewServer(NewMux())
+	assert.NoError(t, s.Close())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.ErrorIs(t, s.Serve(l), ErrServerClosed)
+}
@@ -0,0 +1,261 @@
+// Package rrdssh connects rrdcached clients to servers through an SSH
+// tunnel, for the common deployment where rrdcached only listens on a unix
+// socket or localhost port of a remote host. The client address is that of
+// rrdcached as seen by the SSH server:
+//
+//	c, err := rrd.NewClient("unix:///var/run/rrdcached.sock", rrdssh.Transport(&rrdssh.Config{
+//		Addr: "db.example.com",
+//		User: "metrics",
+//	}))
+package rrdssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultPort is the SSH port used if the address doesn't include one.
+const DefaultPort = 22
+
+// ErrNoAgent is the error returned when connecting if no authentication
+// methods are configured and no SSH agent is available.
+var ErrNoAgent = errors.New("rrdssh: no auth methods and SSH_AUTH_SOCK not set")
+
+// Config configures the SSH tunnel used by Transport.
+type Config struct {
+	// Addr is the host of the SSH server, with an optional port,
+	// DefaultPort if not.
+	Addr string
+
+	// User is the user to authenticate as.
+	User string
+
+	// Auth are the methods used to authenticate, such as KeyFile, if empty
+	// the SSH agent at SSH_AUTH_SOCK is used.
+	Auth []ssh.AuthMethod
+
+	// HostKeyCallback verifies the host key of the server, if nil it's
+	// verified against the KnownHosts file.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// KnownHosts is the known_hosts file used to verify the host key of the
+	// server if HostKeyCallback is nil, ~/.ssh/known_hosts if empty.
+	KnownHosts string
+
+	// Dial establishes the connection to the SSH server, if nil a
+	// net.Dialer is used.
+	Dial rrd.DialFunc
+}
+
+// Transport returns a client option which connects to the server through
+// an SSH tunnel configured by cfg, replacing its Dialer. The SSH
+// connection is established when the client connects, shared by clients created with the option, such as those of a
+// client with multiple rrd.Connections, and closed once none use it.
+func Transport(cfg *Config) func(*rrd.Client) error {
+	t, err := newTunnel(cfg)
+	return func(c *rrd.Client) error {
+		if err != nil {
+			return err
+		}
+		return rrd.Dialer(t.Dial)(c)
+	}
+}
+
+// KeyFile returns an authentication method using the private key in the
+// file path, decrypted with passphrase if it's not empty.
+func KeyFile(path, passphrase string) (ssh.AuthMethod, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("rrdssh: read key: %w", err)
+	}
+
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rrdssh: parse key %v: %w", path, err)
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// tunnel is a SSH connection shared by the connections dialed through it.
+type tunnel struct {
+	addr   string
+	config ssh.ClientConfig
+	dial   rrd.DialFunc
+
+	m      sync.Mutex
+	client *ssh.Client
+	refs   int
+}
+
+// newTunnel returns a new tunnel configured by cfg.
+func newTunnel(cfg *Config) (*tunnel, error) {
+	switch {
+	case cfg == nil:
+		return nil, rrd.ErrNilOption
+	case cfg.Addr == "":
+		return nil, fmt.Errorf("%w: no SSH address", rrd.ErrInvalidArg)
+	case cfg.User == "":
+		return nil, fmt.Errorf("%w: no SSH user", rrd.ErrInvalidArg)
+	}
+
+	t := &tunnel{
+		addr: cfg.Addr,
+		config: ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            cfg.Auth,
+			HostKeyCallback: cfg.HostKeyCallback,
+		},
+		dial: cfg.Dial,
+	}
+	if t.dial == nil {
+		t.dial = (&net.Dialer{}).DialContext
+	}
+	if _, _, err := net.SplitHostPort(t.addr); err != nil {
+		t.addr = net.JoinHostPort(t.addr, strconv.Itoa(DefaultPort))
+	}
+
+	if t.config.HostKeyCallback == nil {
+		path := cfg.KnownHosts
+		if path == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("rrdssh: known hosts: %w", err)
+			}
+			path = filepath.Join(home, ".ssh", "known_hosts")
+		}
+		cb, err := knownhosts.New(path)
+		if err != nil {
+			return nil, fmt.Errorf("rrdssh: known hosts: %w", err)
+		}
+		t.config.HostKeyCallback = cb
+	}
+	return t, nil
+}
+
+// Dial returns a new connection to addr on network from the SSH server,
+// connecting to it if needed.
+func (t *tunnel) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.client == nil {
+		client, err := t.connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		t.client = client
+		go func() {
+			client.Wait() // nolint: errcheck
+			t.m.Lock()
+			defer t.m.Unlock()
+			if t.client == client {
+				t.client = nil
+			}
+		}()
+	}
+
+	conn, err := t.client.DialContext(ctx, network, addr)
+	if err != nil {
+		if t.refs == 0 {
+			t.closeClient()
+		}
+		return nil, fmt.Errorf("rrdssh: dial %v %v via %v: %w", network, addr, t.addr, err)
+	}
+	t.refs++
+	return pipe(conn, t.release), nil
+}
+
+// connect returns a new SSH client.
+func (t *tunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	conn, err := t.dial(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, fmt.Errorf("rrdssh: dial %v: %w", t.addr, err)
+	}
+
+	config := t.config
+	if len(config.Auth) == 0 {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			conn.Close() // nolint: errcheck
+			return nil, ErrNoAgent
+		}
+		ac, err := net.Dial("unix", sock)
+		if err != nil {
+			conn.Close() // nolint: errcheck
+			return nil, fmt.Errorf("rrdssh: agent: %w", err)
+		}
+		defer ac.Close() // nolint: errcheck
+		config.Auth = []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(ac).Signers)}
+	}
+
+	// The handshake doesn't support a context, so is limited by a deadline.
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d) // nolint: errcheck
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, t.addr, &config)
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		return nil, fmt.Errorf("rrdssh: connect %v: %w", t.addr, err)
+	}
+	conn.SetDeadline(time.Time{}) // nolint: errcheck
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// release releases a connection, closing the SSH connection once there
+// are none.
+func (t *tunnel) release() {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.refs--; t.refs == 0 {
+		t.closeClient()
+	}
+}
+
+// closeClient closes the SSH connection if open.
+func (t *tunnel) closeClient() {
+	if t.client != nil {
+		t.client.Close() // nolint: errcheck
+		t.client = nil
+	}
+}
+
+// pipe returns a connection which forwards to conn, calling release once
+// both are closed. It supports deadlines, which SSH channels don't.
+func pipe(conn net.Conn, release func()) net.Conn {
+	local, remote := net.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(conn, remote) // nolint: errcheck
+		conn.Close()          // nolint: errcheck
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(remote, conn) // nolint: errcheck
+		remote.Close()        // nolint: errcheck
+	}()
+	go func() {
+		wg.Wait()
+		release()
+	}()
+	return local
+}
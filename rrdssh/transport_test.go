@@ -0,0 +1,326 @@
+package rrdssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer is a SSH server which only supports forwarding.
+type sshServer struct {
+	Addr    string
+	HostKey ssh.PublicKey
+
+	conns  atomic.Int64
+	active atomic.Int64
+	l      net.Listener
+	wg     sync.WaitGroup
+}
+
+// newSSHServer returns a running SSH server which accepts user with key.
+func newSSHServer(t *testing.T, user string, key ssh.PublicKey) *sshServer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(md ssh.ConnMetadata, k ssh.PublicKey) (*ssh.Permissions, error) {
+			if md.User() != user || string(k.Marshal()) != string(key.Marshal()) {
+				return nil, assert.AnError
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	s := &sshServer{Addr: l.Addr().String(), HostKey: signer.PublicKey(), l: l}
+	t.Cleanup(func() {
+		l.Close() // nolint: errcheck
+		s.wg.Wait()
+	})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.handle(conn, config)
+			}()
+		}
+	}()
+	return s
+}
+
+// handle handles a SSH connection.
+func (s *sshServer) handle(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close() // nolint: errcheck
+	sc, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sc.Close() // nolint: errcheck
+	s.conns.Add(1)
+	s.active.Add(1)
+	defer s.active.Add(-1)
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		var network, addr string
+		switch nc.ChannelType() {
+		case "direct-tcpip":
+			var m struct {
+				Host     string
+				Port     uint32
+				OrigHost string
+				OrigPort uint32
+			}
+			if err := ssh.Unmarshal(nc.ExtraData(), &m); err == nil {
+				network, addr = "tcp", net.JoinHostPort(m.Host, strconv.Itoa(int(m.Port)))
+			}
+		case "direct-streamlocal@openssh.com":
+			var m struct {
+				Path      string
+				Reserved0 string
+				Reserved1 uint32
+			}
+			if err := ssh.Unmarshal(nc.ExtraData(), &m); err == nil {
+				network, addr = "unix", m.Path
+			}
+		}
+		if network == "" {
+			nc.Reject(ssh.UnknownChannelType, nc.ChannelType()) // nolint: errcheck
+			continue
+		}
+
+		target, err := net.Dial(network, addr)
+		if err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error()) // nolint: errcheck
+			continue
+		}
+		ch, creqs, err := nc.Accept()
+		if err != nil {
+			target.Close() // nolint: errcheck
+			continue
+		}
+		go ssh.DiscardRequests(creqs)
+		go func() {
+			defer ch.Close()       // nolint: errcheck
+			defer target.Close()   // nolint: errcheck
+			go io.Copy(target, ch) // nolint: errcheck
+			io.Copy(ch, target)    // nolint: errcheck
+		}()
+	}
+}
+
+// newKey returns a new client key, written to a file in dir.
+func newKey(t *testing.T, dir string) (ssh.PublicKey, string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return nil, ""
+	}
+	block, err := ssh.MarshalPrivateKey(key, "test")
+	if !assert.NoError(t, err) {
+		return nil, ""
+	}
+	path := filepath.Join(dir, "id_ed25519")
+	if !assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0600)) {
+		return nil, ""
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if !assert.NoError(t, err) {
+		return nil, ""
+	}
+	return signer.PublicKey(), path
+}
+
+func TestTransport(t *testing.T) {
+	dir := t.TempDir()
+	pub, keyFile := newKey(t, dir)
+	if pub == nil {
+		return
+	}
+	auth, err := KeyFile(keyFile, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ss := newSSHServer(t, "metrics", pub)
+	if ss == nil {
+		return
+	}
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{ss.Addr}, ss.HostKey) + "\n"
+	if !assert.NoError(t, os.WriteFile(knownHosts, []byte(line), 0600)) {
+		return
+	}
+
+	tcp, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, tcp.Close())
+	}()
+	unix, err := rrdtest.NewUnixServer(filepath.Join(dir, "rrdcached.sock"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, unix.Close())
+	}()
+
+	tests := []struct {
+		name    string
+		addr    string
+		options []func(*rrd.Client) error
+	}{
+		{"tcp", tcp.Addr, nil},
+		{"unix", "unix://" + unix.Addr, nil},
+		{"connections", tcp.Addr, []func(*rrd.Client) error{rrd.Connections(2)}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conns := ss.conns.Load()
+			cfg := &Config{
+				Addr:       ss.Addr,
+				User:       "metrics",
+				Auth:       []ssh.AuthMethod{auth},
+				KnownHosts: knownHosts,
+			}
+			options := append(tc.options, rrd.Timeout(time.Second*2), Transport(cfg))
+			c, err := rrd.NewClient(tc.addr, options...)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, c.Ping())
+			assert.Equal(t, conns+1, ss.conns.Load())
+
+			assert.NoError(t, c.Close())
+			assert.Eventually(t, func() bool {
+				return ss.active.Load() == 0
+			}, time.Second*5, time.Millisecond*10)
+		})
+	}
+}
+
+func TestTransportHostKey(t *testing.T) {
+	dir := t.TempDir()
+	pub, _ := newKey(t, dir)
+	if pub == nil {
+		return
+	}
+	ss := newSSHServer(t, "metrics", pub)
+	if ss == nil {
+		return
+	}
+
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{ss.Addr}, pub) + "\n"
+	if !assert.NoError(t, os.WriteFile(knownHosts, []byte(line), 0600)) {
+		return
+	}
+
+	_, err := rrd.NewClient("localhost", Transport(&Config{
+		Addr:       ss.Addr,
+		User:       "metrics",
+		Auth:       []ssh.AuthMethod{ssh.Password("secret")},
+		KnownHosts: knownHosts,
+	}))
+	var kerr *knownhosts.KeyError
+	assert.ErrorAs(t, err, &kerr)
+}
+
+func TestTransportNoAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	ss := newSSHServer(t, "metrics", nil)
+	if ss == nil {
+		return
+	}
+
+	_, err := rrd.NewClient("localhost", Transport(&Config{
+		Addr:            ss.Addr,
+		User:            "metrics",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}))
+	assert.ErrorIs(t, err, ErrNoAgent)
+}
+
+func TestTransportConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    *Config
+		expect error
+	}{
+		{"nil", nil, rrd.ErrNilOption},
+		{"no-addr", &Config{User: "metrics"}, rrd.ErrInvalidArg},
+		{"no-user", &Config{Addr: "localhost"}, rrd.ErrInvalidArg},
+		{"known-hosts", &Config{Addr: "localhost", User: "metrics", KnownHosts: "/nonexistent"}, os.ErrNotExist},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := rrd.NewClient("localhost", Transport(tc.cfg))
+			assert.ErrorIs(t, err, tc.expect)
+		})
+	}
+
+	tn, err := newTunnel(&Config{Addr: "localhost", User: "metrics", HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "localhost:22", tn.addr)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(key, "test", []byte("pass"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	path := filepath.Join(dir, "id_ed25519")
+	if !assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0600)) {
+		return
+	}
+
+	_, err = KeyFile(path, "pass")
+	assert.NoError(t, err)
+	_, err = KeyFile(path, "")
+	assert.Error(t, err)
+	_, err = KeyFile(filepath.Join(dir, "missing"), "")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
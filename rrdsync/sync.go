@@ -0,0 +1,248 @@
+// Package rrdsync mirrors RRDs from one rrdcached server to another, for
+// migrating or replicating RRD trees between hosts.
+//
+// RRDs missing on the destination are created with the schema of the
+// source, then the rows the destination hasn't seen are fetched from the
+// source and replayed as updates. Each sync only replays rows after the
+// last update of the destination so syncs can be repeated to mirror
+// ongoing updates.
+package rrdsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+// maxUpdateSamples is the maximum number of samples sent in a single update.
+const maxUpdateSamples = 500
+
+// ListOptions sets the options used to list the RRDs of the source, such as
+// filters. Listing is always recursive.
+func ListOptions(opts ...rrd.ListOption) func(*Syncer) error {
+	return func(s *Syncer) error {
+		for _, o := range opts {
+			if o == nil {
+				return rrd.ErrNilOption
+			}
+		}
+		s.listOpts = opts
+		return nil
+	}
+}
+
+// CF sets the consolidation function of the archives which are replayed,
+// which defaults to rrd.Average.
+func CF(cf rrd.ConsolidationFunc) func(*Syncer) error {
+	return func(s *Syncer) error {
+		if !cf.Valid() {
+			return fmt.Errorf("%w: %q", rrd.ErrInvalidCF, cf)
+		}
+		s.cf = cf
+		return nil
+	}
+}
+
+// History sets how far before the last update of the source rows are
+// replayed to RRDs created on the destination, which defaults to the span
+// of the highest resolution archive of the source.
+func History(d time.Duration) func(*Syncer) error {
+	return func(s *Syncer) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: history %v", rrd.ErrInvalidArg, d)
+		}
+		s.history = d
+		return nil
+	}
+}
+
+// Logger sets the logger used to report the progress of syncs, which
+// defaults to slog.Default.
+func Logger(l *slog.Logger) func(*Syncer) error {
+	return func(s *Syncer) error {
+		if l == nil {
+			return rrd.ErrNilOption
+		}
+		s.logger = l
+		return nil
+	}
+}
+
+// Result is the result of a sync.
+type Result struct {
+	// Files is the number of RRDs synced.
+	Files int
+
+	// Created is the number of RRDs created on the destination.
+	Created int
+
+	// Rows is the number of rows replayed.
+	Rows int
+}
+
+// Syncer syncs RRDs from a source to a destination server.
+// A Syncer is safe for concurrent use, as long as the same RRD isn't
+// synced concurrently.
+type Syncer struct {
+	src      *rrd.Client
+	dst      *rrd.Client
+	listOpts []rrd.ListOption
+	cf       rrd.ConsolidationFunc
+	history  time.Duration
+	logger   *slog.Logger
+}
+
+// NewSyncer returns a new Syncer which syncs RRDs from src to dst.
+func NewSyncer(src, dst *rrd.Client, opts ...func(*Syncer) error) (*Syncer, error) {
+	s := &Syncer{
+		src:    src,
+		dst:    dst,
+		cf:     rrd.Average,
+		logger: slog.Default(),
+	}
+	for _, o := range opts {
+		if o == nil {
+			return nil, rrd.ErrNilOption
+		}
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Sync syncs all RRDs of the source below prefix. Failures to sync
+// individual RRDs don't stop the sync but are returned joined together.
+func (s *Syncer) Sync(ctx context.Context, prefix string) (*Result, error) {
+	var files []string
+	if err := s.src.Walk(ctx, prefix, func(name string) error {
+		files = append(files, name)
+		return nil
+	}, s.listOpts...); err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+
+	res := &Result{}
+	var errs []error
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		rows, created, err := s.SyncFile(ctx, f)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", f, err))
+			continue
+		}
+		res.Files++
+		res.Rows += rows
+		if created {
+			res.Created++
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+// SyncFile syncs the RRD filename, returning the number of rows replayed
+// and whether it was created on the destination.
+//
+// Rows are replayed at the resolution of the source archive which covers
+// them, so the heartbeat of the data sources must exceed it for the values
+// to be known. The per second rates of COUNTER, DERIVE and ABSOLUTE data
+// sources are converted back to the values which result in the same rates.
+// As the first update of a counter only sets its base, the first row
+// replayed to a created RRD is unknown for them.
+func (s *Syncer) SyncFile(ctx context.Context, filename string) (int, bool, error) {
+	src, err := s.src.InfoStructWithContext(ctx, filename)
+	if err != nil {
+		return 0, false, fmt.Errorf("source info: %w", err)
+	}
+
+	var created bool
+	from, bases, err := s.destination(ctx, filename, src)
+	if rrd.IsNotExist(err) {
+		from, err = s.create(ctx, filename, src)
+		created, bases = err == nil, make(map[string]float64)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if !src.LastUpdate.After(from) {
+		return 0, created, nil
+	}
+
+	r, err := s.src.FetchRangeWithContext(ctx, filename, s.cf, from, src.LastUpdate)
+	if err != nil {
+		return 0, created, fmt.Errorf("fetch: %w", err)
+	}
+	samples, err := r.Samples(src, from, bases)
+	if err != nil {
+		return 0, created, err
+	}
+
+	for i := 0; i < len(samples); i += maxUpdateSamples {
+		chunk := samples[i:min(i+maxUpdateSamples, len(samples))]
+		if err := s.dst.UpdateWithContext(ctx, filename, chunk...); err != nil {
+			return i, created, fmt.Errorf("update: %w", err)
+		}
+	}
+	s.logger.DebugContext(ctx, "synced rrd", "filename", filename, "rows", len(samples), "created", created)
+	return len(samples), created, nil
+}
+
+// destination returns the last update of the destination RRD filename and
+// the last values of its data sources, checking it has the data sources of
+// src.
+func (s *Syncer) destination(ctx context.Context, filename string, src *rrd.RRDInfo) (time.Time, map[string]float64, error) {
+	dst, err := s.dst.InfoStructWithContext(ctx, filename)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	bases := make(map[string]float64, len(dst.DS))
+	for name, sd := range src.DS {
+		dd, ok := dst.DS[name]
+		if !ok || dd.Index != sd.Index || dd.Type != sd.Type || len(dst.DS) != len(src.DS) {
+			return time.Time{}, nil, fmt.Errorf("%w: data source %v differs", rrd.ErrSchemaMismatch, name)
+		}
+		if v, err := strconv.ParseFloat(dd.LastDS, 64); err == nil {
+			bases[name] = v
+		}
+	}
+	return dst.LastUpdate, bases, nil
+}
+
+// create creates filename on the destination with the schema of src,
+// returning the time rows are replayed from.
+func (s *Syncer) create(ctx context.Context, filename string, src *rrd.RRDInfo) (time.Time, error) {
+	def, err := src.CreateRRD()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("clone schema: %w", err)
+	}
+
+	history := s.history
+	if history == 0 {
+		for _, a := range src.RRA {
+			if rrd.ConsolidationFunc(a.CF) != s.cf {
+				continue
+			}
+			span := src.Step * time.Duration(a.PDPPerRow*a.Rows)
+			if history == 0 || span < history {
+				history = span
+			}
+		}
+	}
+
+	start := src.LastUpdate.Add(-history).Unix()
+	from := time.Unix(start-start%int64(src.Step/time.Second), 0)
+	def.WithStart(from).WithNoOverwrite()
+	if err := s.dst.CreateFromWithContext(ctx, filename, def); err != nil {
+		return time.Time{}, fmt.Errorf("create: %w", err)
+	}
+	return from, nil
+}
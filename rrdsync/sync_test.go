@@ -0,0 +1,163 @@
+package rrdsync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+// newTestClient returns a client connected to a new rrdtest.Server.
+func newTestClient(t *testing.T) (*rrd.Client, *rrdtest.Server) {
+	t.Helper()
+
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+	})
+	s.HandleFunc("update", func(_ string, args []string) []string {
+		return []string{fmt.Sprintf("0 errors, enqueued %v value(s).", len(args)-1)}
+	})
+
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+	return c, s
+}
+
+// newTestSource returns a client connected to a source server on which
+// a.rrd has a GAUGE and a COUNTER data source with a minute step.
+func newTestSource(t *testing.T) *rrd.Client {
+	c, s := newTestClient(t)
+	s.Handle("list", "1 RRDs", "/a.rrd")
+	s.Handle("info",
+		"17 Info for a.rrd follows",
+		"filename 2 a.rrd",
+		"step 1 60",
+		"last_update 1 1000260",
+		"ds[watts].index 1 0",
+		"ds[watts].type 2 GAUGE",
+		"ds[watts].minimal_heartbeat 1 120",
+		"ds[watts].min 0 nan",
+		"ds[watts].max 0 nan",
+		"ds[hits].index 1 1",
+		"ds[hits].type 2 COUNTER",
+		"ds[hits].minimal_heartbeat 1 120",
+		"ds[hits].min 0 0",
+		"ds[hits].max 0 nan",
+		"rra[0].cf 2 AVERAGE",
+		"rra[0].rows 1 1440",
+		"rra[0].pdp_per_row 1 1",
+		"rra[0].xff 0 0.5",
+	)
+	s.Handle("fetch",
+		"10 Success",
+		"FlushVersion: 1",
+		"Start: 1000020",
+		"End: 1000260",
+		"Step: 60",
+		"DSCount: 2",
+		"DSName: watts hits",
+		"1000080: 1 0.5",
+		"1000140: 2 1",
+		"1000200: nan nan",
+		"1000260: 4 2",
+	)
+	return c
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    []string
+		created int
+		create  string
+		update  string
+	}{
+		{
+			name:    "create",
+			info:    []string{"-1 No such file: /a.rrd"},
+			created: 1,
+			create:  "create /a.rrd -s 60 -b 1000020 -O DS:watts:GAUGE:120:U:U DS:hits:COUNTER:120:0:U RRA:AVERAGE:0.5:1:1440",
+			update:  "update /a.rrd 1000080:1:30 1000140:2:90 1000200:U:U 1000260:4:210",
+		},
+		{
+			name: "incremental",
+			info: []string{
+				"6 Info for a.rrd follows",
+				"last_update 1 1000140",
+				"ds[watts].index 1 0",
+				"ds[watts].type 2 GAUGE",
+				"ds[hits].index 1 1",
+				"ds[hits].type 2 COUNTER",
+				"ds[hits].last_ds 2 1000",
+			},
+			update: "update /a.rrd 1000200:U:U 1000260:4:1120",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			src := newTestSource(t)
+			dst, ds := newTestClient(t)
+			ds.Handle("info", tc.info...)
+
+			s, err := NewSyncer(src, dst, History(time.Minute*4))
+			if !assert.NoError(t, err) {
+				return
+			}
+			res, err := s.Sync(context.Background(), "/")
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, &Result{Files: 1, Created: tc.created, Rows: 4 - 2*(1-tc.created)}, res)
+			if tc.create != "" {
+				assert.Equal(t, 1, ds.Count(tc.create), "%v", ds.Received())
+			}
+			assert.Equal(t, 1, ds.Count(tc.update), "%v", ds.Received())
+		})
+	}
+}
+
+func TestSyncMismatch(t *testing.T) {
+	src := newTestSource(t)
+	dst, ds := newTestClient(t)
+	ds.Handle("info",
+		"2 Info for a.rrd follows",
+		"ds[watts].index 1 0",
+		"ds[watts].type 2 GAUGE",
+	)
+
+	s, err := NewSyncer(src, dst)
+	if !assert.NoError(t, err) {
+		return
+	}
+	res, err := s.Sync(context.Background(), "/")
+	assert.ErrorIs(t, err, rrd.ErrSchemaMismatch)
+	assert.Equal(t, &Result{}, res)
+	assert.Equal(t, 0, ds.Count("update"))
+}
+
+func TestNewSyncer(t *testing.T) {
+	for _, o := range []func(*Syncer) error{
+		nil,
+		CF("SUM"),
+		History(0),
+		Logger(nil),
+		ListOptions(nil),
+	} {
+		_, err := NewSyncer(nil, nil, o)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,340 @@
+package rrdtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCassetteMismatch is the error returned when a client replaying a
+// cassette doesn't send the recorded data.
+var ErrCassetteMismatch = errors.New("rrdtest: cassette mismatch")
+
+// DialFunc is a function which establishes connections, compatible with
+// rrd.DialFunc.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// event is data sent or received on a connection.
+type event struct {
+	sent bool
+	data []byte
+}
+
+// track is the recording of a connection.
+type track struct {
+	network string
+	addr    string
+	events  []event
+}
+
+// add adds data to t, merging it with the last event if it was in the
+// same direction as the split of data into reads and writes isn't
+// deterministic.
+func (t *track) add(sent bool, data []byte) {
+	if n := len(t.events); n > 0 && t.events[n-1].sent == sent {
+		t.events[n-1].data = append(t.events[n-1].data, data...)
+		return
+	}
+	t.events = append(t.events, event{sent: sent, data: append([]byte(nil), data...)})
+}
+
+// Recorder records the connections it dials to a cassette.
+type Recorder struct {
+	dial DialFunc
+
+	m      sync.Mutex
+	tracks []*track
+}
+
+// NewRecorder returns a new Recorder which establishes connections with
+// dial, or net.Dialer if nil.
+func NewRecorder(dial DialFunc) *Recorder {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return &Recorder{dial: dial}
+}
+
+// Dial dials addr returning a connection which is recorded.
+func (r *Recorder) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := r.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &track{network: network, addr: addr}
+	r.m.Lock()
+	r.tracks = append(r.tracks, t)
+	r.m.Unlock()
+
+	return &recordConn{Conn: conn, r: r, t: t}, nil
+}
+
+// add records data on t.
+func (r *Recorder) add(t *track, sent bool, data []byte) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	t.add(sent, data)
+}
+
+// WriteTo writes the cassette recorded so far to w.
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var buf bytes.Buffer
+	for _, t := range r.tracks {
+		fmt.Fprintf(&buf, "conn %v %v\n", t.network, t.addr)
+		for _, e := range t.events {
+			dir := '<'
+			if e.sent {
+				dir = '>'
+			}
+			fmt.Fprintf(&buf, "%c %v\n", dir, strconv.Quote(string(e.data)))
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+// Save writes the cassette recorded so far to the file path.
+func (r *Recorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("rrdtest: save cassette: %w", err)
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close() // nolint: errcheck
+		return fmt.Errorf("rrdtest: save cassette: %w", err)
+	}
+	return f.Close()
+}
+
+// recordConn is a connection which records the data it transfers.
+type recordConn struct {
+	net.Conn
+	r *Recorder
+	t *track
+}
+
+func (c *recordConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.r.add(c.t, false, b[:n])
+	}
+	return n, err
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.r.add(c.t, true, b[:n])
+	}
+	return n, err
+}
+
+// Replayer replays a cassette, returning a connection replaying the next
+// recorded connection each time it's dialed.
+type Replayer struct {
+	m      sync.Mutex
+	tracks []*track
+	conns  []*replayConn
+	err    error
+}
+
+// NewReplayer returns a new Replayer for the cassette read from r.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	br := bufio.NewReader(r)
+	p := &Replayer{}
+	var t *track
+	for n := 1; ; n++ {
+		l, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("rrdtest: read cassette: %w", err)
+		}
+		if l = strings.TrimRight(l, "\r\n"); l != "" {
+			if t, err = p.parseLine(t, l); err != nil {
+				return nil, fmt.Errorf("rrdtest: cassette line %v: %w", n, err)
+			}
+		}
+		if err != nil {
+			return p, nil
+		}
+	}
+}
+
+// parseLine parses the cassette line l, adding it to t, returning the
+// current track.
+func (p *Replayer) parseLine(t *track, l string) (*track, error) {
+	typ, rest, _ := strings.Cut(l, " ")
+	switch typ {
+	case "conn":
+		network, addr, _ := strings.Cut(rest, " ")
+		t = &track{network: network, addr: addr}
+		p.tracks = append(p.tracks, t)
+		return t, nil
+	case ">", "<":
+		if t == nil {
+			return nil, errors.New("data before conn")
+		}
+		data, err := strconv.Unquote(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data: %w", err)
+		}
+		t.add(typ == ">", []byte(data))
+		return t, nil
+	}
+	return nil, fmt.Errorf("invalid type %q", typ)
+}
+
+// LoadCassette returns a new Replayer for the cassette file path.
+func LoadCassette(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("rrdtest: load cassette: %w", err)
+	}
+	defer f.Close() // nolint: errcheck
+	return NewReplayer(f)
+}
+
+// Dial returns a connection replaying the next recorded connection. The
+// network and addr are ignored so the server address needn't match the
+// recording.
+func (p *Replayer) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if len(p.conns) == len(p.tracks) {
+		return nil, fmt.Errorf("%w: unexpected dial %v %v", ErrCassetteMismatch, network, addr)
+	}
+	c := &replayConn{p: p, t: p.tracks[len(p.conns)]}
+	p.conns = append(p.conns, c)
+	return c, nil
+}
+
+// fail records err as the first replay failure, returning it.
+func (p *Replayer) fail(err error) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	return err
+}
+
+// Done returns an error if replaying failed or if not all the recorded
+// connections and data were replayed.
+func (p *Replayer) Done() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if len(p.conns) != len(p.tracks) {
+		return fmt.Errorf("%w: %v of %v connections dialed", ErrCassetteMismatch, len(p.conns), len(p.tracks))
+	}
+	for i, c := range p.conns {
+		if c.remaining() {
+			return fmt.Errorf("%w: connection %v not fully replayed", ErrCassetteMismatch, i)
+		}
+	}
+	return nil
+}
+
+// replayAddr is the address of replayed connections.
+type replayAddr string
+
+func (a replayAddr) Network() string { return "replay" }
+func (a replayAddr) String() string  { return string(a) }
+
+// replayConn is a connection which replays a recorded connection,
+// checking that the data written matches that recorded and returning the
+// recorded data when read.
+type replayConn struct {
+	p *Replayer
+	t *track
+
+	m      sync.Mutex
+	i      int
+	off    int
+	closed bool
+}
+
+// remaining returns true if c has data which hasn't been replayed.
+func (c *replayConn) remaining() bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.i < len(c.t.events)
+}
+
+// next advances c past the n bytes of the current event.
+func (c *replayConn) next(n int) {
+	c.off += n
+	if c.off == len(c.t.events[c.i].data) {
+		c.i++
+		c.off = 0
+	}
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	switch {
+	case c.closed:
+		return 0, net.ErrClosed
+	case c.i == len(c.t.events):
+		return 0, io.EOF
+	case c.t.events[c.i].sent:
+		// Reading would block forever on a real connection.
+		e := c.t.events[c.i]
+		return 0, c.p.fail(fmt.Errorf("%w: read before writing %q", ErrCassetteMismatch, e.data[c.off:]))
+	}
+
+	n := copy(b, c.t.events[c.i].data[c.off:])
+	c.next(n)
+	return n, nil
+}
+
+func (c *replayConn) Write(b []byte) (int, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+
+	var written int
+	for written < len(b) {
+		if c.i == len(c.t.events) || !c.t.events[c.i].sent {
+			return written, c.p.fail(fmt.Errorf("%w: unexpected write %q", ErrCassetteMismatch, b[written:]))
+		}
+		want := c.t.events[c.i].data[c.off:]
+		n := min(len(want), len(b)-written)
+		if !bytes.Equal(want[:n], b[written:written+n]) {
+			return written, c.p.fail(fmt.Errorf("%w: wrote %q expected %q", ErrCassetteMismatch, b[written:], want))
+		}
+		written += n
+		c.next(n)
+	}
+	return written, nil
+}
+
+func (c *replayConn) Close() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *replayConn) LocalAddr() net.Addr  { return replayAddr("local") }
+func (c *replayConn) RemoteAddr() net.Addr { return replayAddr(c.t.addr) }
+
+func (c *replayConn) SetDeadline(time.Time) error      { return nil }
+func (c *replayConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *replayConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,120 @@
+package rrdtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+)
+
+func TestCassette(t *testing.T) {
+	s, err := NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+	s.Handle("first", "0 1240782000")
+	s.Handle("info", "2 Info for test.rrd follows", "filename 2 test.rrd", "step 1 300")
+
+	r := NewRecorder(nil)
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2), rrd.Dialer(r.Dial))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	run := func(c *rrd.Client) {
+		t.Helper()
+		assert.NoError(t, c.Ping())
+		assert.NoError(t, c.Update("my test.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}))
+		first, err := c.First("test.rrd", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Unix(1240782000, 0), first)
+		info, err := c.InfoMap("test.rrd")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"filename": "test.rrd", "step": int64(300)}, info)
+	}
+	run(c)
+	assert.NoError(t, c.Close())
+
+	path := filepath.Join(t.TempDir(), "test.cassette")
+	if !assert.NoError(t, r.Save(path)) {
+		return
+	}
+
+	var buf bytes.Buffer
+	_, err = r.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(buf.String(), "conn tcp "+s.Addr+"\n> \"ping\\n\"\n< \"0 PONG\\n\"\n"), buf.String())
+
+	// Replay against an address which doesn't exist.
+	p, err := LoadCassette(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	c, err = rrd.NewClient("rrdcached.invalid:42217", rrd.Timeout(time.Second*2), rrd.Dialer(p.Dial))
+	if !assert.NoError(t, err) {
+		return
+	}
+	run(c)
+	assert.NoError(t, c.Close())
+	assert.NoError(t, p.Done())
+
+	// Commands which differ from the recording fail.
+	p, err = LoadCassette(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	c, err = rrd.NewClient("rrdcached.invalid:42217", rrd.Timeout(time.Second*2), rrd.Dialer(p.Dial))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, c.Ping())
+	assert.Error(t, c.Update("other.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}))
+	c.Close() // nolint: errcheck
+	assert.True(t, errors.Is(p.Done(), ErrCassetteMismatch))
+
+	// Incomplete replays are reported.
+	p, err = LoadCassette(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = p.Dial(context.Background(), "tcp", "rrdcached.invalid:42217")
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(p.Done(), ErrCassetteMismatch))
+	_, err = p.Dial(context.Background(), "tcp", "rrdcached.invalid:42217")
+	assert.True(t, errors.Is(err, ErrCassetteMismatch))
+}
+
+func TestNewReplayer(t *testing.T) {
+	tests := []struct {
+		name     string
+		cassette string
+		valid    bool
+	}{
+		{name: "valid", cassette: "conn tcp localhost:42217\n> \"ping\\n\"\n< \"0 PONG\\n\"\n", valid: true},
+		{name: "no-newline", cassette: "conn tcp localhost:42217\n> \"ping\\n\"", valid: true},
+		{name: "empty", cassette: "", valid: true},
+		{name: "no-conn", cassette: "> \"ping\\n\"\n"},
+		{name: "unquoted", cassette: "conn tcp localhost:42217\n> ping\n"},
+		{name: "type", cassette: "conn tcp localhost:42217\n= \"ping\\n\"\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewReplayer(strings.NewReader(tc.cassette))
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
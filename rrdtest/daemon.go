@@ -0,0 +1,290 @@
+package rrdtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+const (
+	// DefaultDaemonImage is the container image run by StartDaemon if
+	// rrdcached isn't installed.
+	DefaultDaemonImage = "crazymax/rrdcached:latest"
+
+	// DefaultDaemonTimeout is the time StartDaemon waits for the daemon to
+	// accept connections.
+	DefaultDaemonTimeout = time.Second * 30
+)
+
+// ErrNoDaemon is the error returned by StartDaemon if neither rrdcached nor
+// a container runtime are available.
+var ErrNoDaemon = errors.New("rrdtest: rrdcached and docker not found")
+
+// DaemonConfig configures the daemon started by StartDaemon.
+type DaemonConfig struct {
+	// Binary is the rrdcached binary, looked up in PATH if empty.
+	Binary string
+
+	// Docker is the container runtime CLI used if the binary isn't found,
+	// such as "podman", looked up in PATH if empty, "docker" by default.
+	Docker string
+
+	// Image is the container image to run, DefaultDaemonImage if empty.
+	Image string
+
+	// Timeout is the time to wait for the daemon to accept connections,
+	// DefaultDaemonTimeout if zero.
+	Timeout time.Duration
+}
+
+// Daemon is a real rrdcached started by StartDaemon with a temporary data
+// directory.
+type Daemon struct {
+	// Addr is the address of the daemon, which can be passed to
+	// rrd.NewClient as is.
+	Addr string
+
+	// Dir is the data directory of the daemon, which RRD file names passed
+	// to clients are relative to.
+	Dir string
+
+	cmd       *exec.Cmd
+	stderr    bytes.Buffer
+	exited    chan struct{}
+	docker    string
+	container string
+}
+
+// StartDaemon starts a rrdcached with a new temporary data directory,
+// running the local binary if installed otherwise a container, returning
+// once it accepts connections. If neither are available the error matches
+// ErrNoDaemon. The daemon must be closed when no longer needed.
+func StartDaemon(ctx context.Context, cfg *DaemonConfig) (*Daemon, error) {
+	if cfg == nil {
+		cfg = &DaemonConfig{}
+	}
+
+	dir, err := os.MkdirTemp("", "rrdtest")
+	if err != nil {
+		return nil, fmt.Errorf("rrdtest: failed to create data directory: %w", err)
+	}
+	d := &Daemon{Dir: filepath.Join(dir, "db")}
+	if err := os.Mkdir(d.Dir, 0777); err != nil {
+		os.RemoveAll(dir) // nolint: errcheck
+		return nil, fmt.Errorf("rrdtest: failed to create data directory: %w", err)
+	}
+
+	if err := d.start(ctx, cfg, dir); err != nil {
+		os.RemoveAll(dir) // nolint: errcheck
+		return nil, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultDaemonTimeout
+	}
+	if err := d.wait(ctx, timeout); err != nil {
+		d.Close() // nolint: errcheck
+		return nil, err
+	}
+	return d, nil
+}
+
+// start starts the daemon with its files in dir.
+func (d *Daemon) start(ctx context.Context, cfg *DaemonConfig, dir string) error {
+	if bin, err := lookPath(cfg.Binary, "rrdcached"); err == nil {
+		return d.startBinary(bin, dir)
+	}
+	if docker, err := lookPath(cfg.Docker, "docker"); err == nil {
+		return d.startContainer(ctx, docker, cfg.Image)
+	}
+	return ErrNoDaemon
+}
+
+// lookPath returns the path of the executable name, or def if empty.
+func lookPath(name, def string) (string, error) {
+	if name == "" {
+		name = def
+	}
+	return exec.LookPath(name)
+}
+
+// daemonArgs returns the rrdcached arguments to run in the foreground
+// listening on a unix socket in dir, storing data in dir/db.
+func daemonArgs(dir string) []string {
+	return []string{
+		"-g",
+		"-l", "unix:" + filepath.Join(dir, "rrdcached.sock"),
+		"-p", filepath.Join(dir, "rrdcached.pid"),
+		"-j", filepath.Join(dir, "journal"),
+		"-b", filepath.Join(dir, "db"),
+		"-B",
+		"-F",
+	}
+}
+
+// startBinary starts the local rrdcached bin with its files in dir.
+func (d *Daemon) startBinary(bin, dir string) error {
+	if err := os.Mkdir(filepath.Join(dir, "journal"), 0700); err != nil {
+		return fmt.Errorf("rrdtest: failed to create journal directory: %w", err)
+	}
+
+	d.Addr = "unix:" + filepath.Join(dir, "rrdcached.sock")
+	d.cmd = exec.Command(bin, daemonArgs(dir)...)
+	d.cmd.Stderr = &d.stderr
+	if err := d.cmd.Start(); err != nil {
+		return fmt.Errorf("rrdtest: failed to start %v: %w", bin, err)
+	}
+
+	d.exited = make(chan struct{})
+	go func() {
+		d.cmd.Wait() // nolint: errcheck
+		close(d.exited)
+	}()
+	return nil
+}
+
+// containerArgs returns the arguments to run image detached with dir as
+// its data directory and the rrdcached port published on localhost.
+func containerArgs(image, dir string) []string {
+	if image == "" {
+		image = DefaultDaemonImage
+	}
+	return []string{
+		"run", "--detach", "--rm",
+		"--publish", "127.0.0.1::" + fmt.Sprint(rrd.DefaultPort),
+		"--volume", dir + ":/data/db",
+		image,
+	}
+}
+
+// startContainer starts a container running image with docker.
+func (d *Daemon) startContainer(ctx context.Context, docker, image string) error {
+	// The container user may differ from ours.
+	if err := os.Chmod(d.Dir, 0777); err != nil {
+		return fmt.Errorf("rrdtest: failed to make data directory writable: %w", err)
+	}
+
+	d.docker = docker
+	out, err := d.run(ctx, containerArgs(image, d.Dir)...)
+	if err != nil {
+		return err
+	}
+	d.container = out
+
+	if out, err = d.run(ctx, "port", d.container, fmt.Sprintf("%v/tcp", rrd.DefaultPort)); err != nil {
+		return err
+	}
+	// Multiple lines are output if the port is also published on IPv6.
+	d.Addr, _, _ = strings.Cut(out, "\n")
+	return nil
+}
+
+// run runs docker with args, returning its trimmed output.
+func (d *Daemon) run(ctx context.Context, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, d.docker, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("rrdtest: %v %v: %w: %v", d.docker, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// wait waits for the daemon to respond to ping.
+func (d *Daemon) wait(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t := time.NewTicker(time.Millisecond * 50)
+	defer t.Stop()
+	for {
+		err := d.ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("rrdtest: daemon not ready: %w", err)
+		case <-d.exited:
+			return fmt.Errorf("rrdtest: daemon exited: %v: %v", d.cmd.ProcessState, strings.TrimSpace(d.stderr.String()))
+		case <-t.C:
+		}
+	}
+}
+
+// ping pings the daemon with a new client.
+func (d *Daemon) ping(ctx context.Context) error {
+	c, err := d.Client(rrd.Timeout(time.Second))
+	if err != nil {
+		return err
+	}
+	defer c.Close() // nolint: errcheck
+	return c.PingWithContext(ctx)
+}
+
+// Client returns a new client connected to the daemon with options.
+func (d *Daemon) Client(options ...func(*rrd.Client) error) (*rrd.Client, error) {
+	return rrd.NewClient(d.Addr, options...)
+}
+
+// Close stops the daemon and removes its data directory.
+func (d *Daemon) Close() error {
+	var err error
+	switch {
+	case d.cmd != nil:
+		if err = d.cmd.Process.Kill(); errors.Is(err, os.ErrProcessDone) {
+			err = nil
+		}
+		<-d.exited
+	case d.container != "":
+		_, err = d.run(context.Background(), "rm", "--force", d.container)
+	}
+
+	if err2 := os.RemoveAll(filepath.Dir(d.Dir)); err2 != nil && err == nil {
+		err = err2
+	}
+	return err
+}
+
+// NewDaemonClient starts a daemon with StartDaemon, returning a client
+// connected to it created with options. The client and daemon are closed
+// when the test completes. The test is skipped if neither rrdcached nor
+// docker are available.
+func NewDaemonClient(tb testing.TB, options ...func(*rrd.Client) error) (*rrd.Client, *Daemon) {
+	tb.Helper()
+
+	d, err := StartDaemon(context.Background(), nil)
+	if errors.Is(err, ErrNoDaemon) {
+		tb.Skip(err)
+	} else if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := d.Close(); err != nil {
+			tb.Error(err)
+		}
+	})
+
+	c, err := d.Client(options...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			tb.Error(err)
+		}
+	})
+	return c, d
+}
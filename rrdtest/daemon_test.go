@@ -0,0 +1,67 @@
+package rrdtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+)
+
+func TestDaemonArgs(t *testing.T) {
+	assert.Equal(t, []string{
+		"-g",
+		"-l", "unix:" + filepath.Join("tmp", "rrdcached.sock"),
+		"-p", filepath.Join("tmp", "rrdcached.pid"),
+		"-j", filepath.Join("tmp", "journal"),
+		"-b", filepath.Join("tmp", "db"),
+		"-B",
+		"-F",
+	}, daemonArgs("tmp"))
+
+	assert.Equal(t, []string{
+		"run", "--detach", "--rm",
+		"--publish", "127.0.0.1::42217",
+		"--volume", "/tmp/db:/data/db",
+		DefaultDaemonImage,
+	}, containerArgs("", "/tmp/db"))
+}
+
+func TestStartDaemon(t *testing.T) {
+	_, err := StartDaemon(context.Background(), &DaemonConfig{
+		Binary: filepath.Join(t.TempDir(), "rrdcached"),
+		Docker: filepath.Join(t.TempDir(), "docker"),
+	})
+	assert.True(t, errors.Is(err, ErrNoDaemon))
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	// A daemon which exits is reported without waiting for the timeout.
+	bin := filepath.Join(t.TempDir(), "rrdcached")
+	if !assert.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\nexit 1\n"), 0700)) {
+		return
+	}
+	start := time.Now()
+	_, err = StartDaemon(context.Background(), &DaemonConfig{Binary: bin})
+	assert.ErrorContains(t, err, "daemon exited")
+	assert.Less(t, time.Since(start), DefaultDaemonTimeout)
+}
+
+func TestNewDaemonClient(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts rrdcached")
+	}
+
+	c, d := NewDaemonClient(t, rrd.Timeout(time.Second*10))
+	assert.NoError(t, c.Ping())
+	assert.NoError(t, c.Create("test.rrd", []rrd.DS{rrd.NewGauge("watts", time.Minute, 0, 100)}, []rrd.RRA{rrd.NewAverage(0.5, 1, 10)}))
+	_, err := os.Stat(filepath.Join(d.Dir, "test.rrd"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,288 @@
+// Package rrdtest provides a fake rrdcached server for testing code which
+// uses rrd.Client, without requiring a real daemon.
+//
+// Alternatively cassettes record the bytes exchanged with a real server so
+// they can be replayed without it. A cassette is recorded by creating a
+// client with rrd.Dialer(r.Dial) for a Recorder r connected to a real
+// rrdcached and saving it once done:
+//
+//	r := rrdtest.NewRecorder(nil)
+//	c, err := rrd.NewClient(addr, rrd.Dialer(r.Dial))
+//	...
+//	err = r.Save("testdata/update.cassette")
+//
+// Tests then replay it offline with a Replayer:
+//
+//	p, err := rrdtest.LoadCassette("testdata/update.cassette")
+//	c, err := rrd.NewClient("rrdcached:42217", rrd.Dialer(p.Dial))
+//	...
+//	err = p.Done()
+//
+// The cassette is a text file with a conn line for each connection
+// followed by the data sent, prefixed by ">", and received, prefixed by
+// "<", as quoted Go strings.
+package rrdtest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ResponseFunc returns the response lines, including the status line, for
+// the command cmd, the lower case command verb, with the unescaped args.
+type ResponseFunc func(cmd string, args []string) []string
+
+// DefaultResponses are the responses used for commands which haven't been
+// scripted with Handle or HandleFunc.
+var DefaultResponses = map[string][]string{
+	"ping":       {"0 PONG"},
+	"update":     {"0 errors, enqueued 1 value(s)."},
+	"create":     {"0 RRD created OK"},
+	"flush":      {"0 Successfully flushed"},
+	"flushall":   {"0 Started flush."},
+	"forget":     {"0 Gone!"},
+	"pending":    {"0 updates pending"},
+	"queue":      {"0 in queue."},
+	"stats":      {"1 Statistics follow", "QueueLength: 0"},
+	"tune":       {"0 Success"},
+	"suspend":    {"0 Success"},
+	"resume":     {"0 Success"},
+	"suspendall": {"0 0 files suspended"},
+	"resumeall":  {"0 0 files resumed"},
+	"list":       {"0 RRDs"},
+	"wrote":      {"-1 Can't use 'wrote' here."},
+	".":          {"0 errors"},
+}
+
+// Server is a fake rrdcached server which speaks the line protocol, replying
+// to commands with scripted responses and recording the lines it receives.
+type Server struct {
+	// Addr is the address to connect to, a socket path for unix servers
+	// which requires clients to be created with rrd.Unix.
+	Addr string
+
+	// Network is the network of Addr, either "tcp" or "unix".
+	Network string
+
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	m         sync.Mutex
+	responses map[string]ResponseFunc
+	received  []string
+}
+
+// NewServer returns a new running Server listening on a local TCP port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		if l, err = net.Listen("tcp6", "[::1]:0"); err != nil {
+			return nil, fmt.Errorf("rrdtest: failed to listen: %w", err)
+		}
+	}
+	return newServer(l), nil
+}
+
+// NewUnixServer returns a new running Server listening on the unix socket path.
+func NewUnixServer(path string) (*Server, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("rrdtest: failed to listen: %w", err)
+	}
+	return newServer(l), nil
+}
+
+// newServer returns a new Server which is serving connections from l.
+func newServer(l net.Listener) *Server {
+	s := &Server{
+		Addr:      l.Addr().String(),
+		Network:   l.Addr().Network(),
+		listener:  l,
+		conns:     make(map[net.Conn]struct{}),
+		done:      make(chan struct{}),
+		responses: make(map[string]ResponseFunc),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Handle scripts the response lines, including the status line, to cmd.
+// Responses for the end of a batch are scripted with the cmd ".".
+func (s *Server) Handle(cmd string, lines ...string) {
+	s.HandleFunc(cmd, func(string, []string) []string { return lines })
+}
+
+// HandleFunc scripts f to generate the responses to cmd.
+func (s *Server) HandleFunc(cmd string, f ResponseFunc) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.responses[strings.ToLower(cmd)] = f
+}
+
+// Received returns the lines received from clients, in order.
+func (s *Server) Received() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+// Count returns the number of received lines which start with prefix.
+func (s *Server) Count(prefix string) int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var n int
+	for _, l := range s.received {
+		if strings.HasPrefix(l, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset clears the received lines.
+func (s *Server) Reset() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.received = nil
+}
+
+// Close closes the listener and all client connections and waits for
+// them to finish.
+func (s *Server) Close() error {
+	close(s.done)
+	err := s.listener.Close()
+	s.m.Lock()
+	for c := range s.conns {
+		if err2 := c.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	s.m.Unlock()
+	s.wg.Wait()
+
+	if s.Network == "unix" {
+		os.Remove(s.Addr) // nolint: errcheck
+	}
+
+	return err
+}
+
+// serve accepts connections until the server is closed.
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.m.Lock()
+		select {
+		case <-s.done:
+			s.m.Unlock()
+			conn.Close() // nolint: errcheck
+			return
+		default:
+		}
+		s.conns[conn] = struct{}{}
+		s.m.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle processes the commands sent by a client until it disconnects.
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		s.m.Lock()
+		delete(s.conns, conn)
+		s.m.Unlock()
+		conn.Close() // nolint: errcheck
+		s.wg.Done()
+	}()
+
+	sc := bufio.NewScanner(conn)
+	var batch bool
+	for sc.Scan() {
+		l := sc.Text()
+		s.m.Lock()
+		s.received = append(s.received, l)
+		s.m.Unlock()
+
+		fields := splitFields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		cmd := strings.ToLower(fields[0])
+		switch {
+		case cmd == "quit":
+			return
+		case batch && cmd != ".":
+			continue
+		}
+		batch = cmd == "batch"
+
+		resp := s.response(cmd, fields[1:])
+		if batch && len(resp) > 0 && strings.HasPrefix(resp[0], "-") {
+			batch = false
+		}
+		if _, err := conn.Write([]byte(strings.Join(resp, "\n") + "\n")); err != nil {
+			return
+		}
+	}
+}
+
+// response returns the response lines for cmd with args.
+func (s *Server) response(cmd string, args []string) []string {
+	s.m.Lock()
+	f, ok := s.responses[cmd]
+	s.m.Unlock()
+	switch {
+	case ok:
+		return f(cmd, args)
+	case cmd == "batch":
+		return []string{"0 Go ahead.  End with dot '.' on its own line."}
+	}
+
+	if resp, ok := DefaultResponses[cmd]; ok {
+		return resp
+	}
+	return []string{fmt.Sprintf("-1 Unknown command: %v", strings.ToUpper(cmd))}
+}
+
+// splitFields splits l into space separated fields, treating spaces and
+// backslashes escaped with a backslash as literals.
+func splitFields(l string) []string {
+	var fields []string
+	var b strings.Builder
+	var inField bool
+	for i := 0; i < len(l); i++ {
+		switch {
+		case l[i] == '\\' && i+1 < len(l):
+			i++
+			b.WriteByte(l[i])
+			inField = true
+		case l[i] == ' ':
+			if inField {
+				fields = append(fields, b.String())
+				b.Reset()
+				inField = false
+			}
+		default:
+			b.WriteByte(l[i])
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, b.String())
+	}
+	return fields
+}
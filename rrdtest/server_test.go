@@ -0,0 +1,144 @@
+package rrdtest
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+)
+
+func TestServer(t *testing.T) {
+	s, err := NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+	assert.Equal(t, "tcp", s.Network)
+
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+	assert.NoError(t, c.Update("my test.rrd", rrd.Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}}))
+
+	s.Handle("first", "0 1240782000")
+	first, err := c.First("test.rrd", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1240782000, 0), first)
+
+	var args []string
+	s.HandleFunc("FLUSH", func(cmd string, a []string) []string {
+		args = a
+		return []string{"-1 No such file: " + a[0]}
+	})
+	assert.True(t, rrd.IsNotExist(c.Flush("missing file.rrd")))
+	assert.Equal(t, []string{"missing file.rrd"}, args)
+
+	_, err = c.Exec("bogus")
+	assert.True(t, errors.Is(err, rrd.ErrNotSupported))
+
+	assert.Equal(t, []string{
+		"ping",
+		`update my\ test.rrd 1499968800:1`,
+		"first test.rrd 0",
+		`flush missing\ file.rrd`,
+		"bogus",
+	}, s.Received())
+	assert.Equal(t, 1, s.Count("update "))
+
+	s.Reset()
+	assert.Empty(t, s.Received())
+}
+
+func TestServerBatch(t *testing.T) {
+	s, err := NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	b := c.NewBatch()
+	b.UpdateRaw("a.rrd", "1499968800:1")
+	b.UpdateRaw("b.rrd", "1499968800:2")
+	assert.NoError(t, b.Exec())
+
+	s.Handle(".", "1 errors", "2 illegal attempt to update using time 1499968800.000000 when last update time is 1499968800.000000 (minimum one second step)")
+	b.UpdateRaw("a.rrd", "1499968800:1")
+	b.UpdateRaw("b.rrd", "1499968800:2")
+	assert.True(t, rrd.IsIllegalUpdate(b.Exec()))
+
+	// Commands after the batch are processed normally.
+	assert.NoError(t, c.Ping())
+	assert.Equal(t, 2, s.Count("batch"))
+	assert.Equal(t, 4, s.Count("update "))
+}
+
+func TestServerUnix(t *testing.T) {
+	s, err := NewUnixServer(filepath.Join(t.TempDir(), "rrdcached.sock"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+	assert.Equal(t, "unix", s.Network)
+
+	c, err := rrd.NewClient(s.Addr, rrd.Unix, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	assert.NoError(t, c.Ping())
+
+	u, err := rrd.NewClient("unix://"+s.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, u.Close())
+	}()
+
+	assert.NoError(t, u.Ping())
+}
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"", nil},
+		{"ping", []string{"ping"}},
+		{"update  test.rrd N:1", []string{"update", "test.rrd", "N:1"}},
+		{`flush my\ test.rrd`, []string{"flush", "my test.rrd"}},
+		{`flush a\\b`, []string{"flush", `a\b`}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.line, func(t *testing.T) {
+			assert.Equal(t, tc.expected, splitFields(tc.line))
+		})
+	}
+}
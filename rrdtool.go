@@ -0,0 +1,531 @@
+package rrd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RRDToolCmd is a rrdtool command run by an Executor.
+type RRDToolCmd struct {
+	// Args are the rrdtool arguments, starting with the command e.g. "dump".
+	Args []string
+
+	// Dir is the working directory of the command, if empty that of the
+	// current process.
+	Dir string
+
+	// Stdout receives the output of the command, if nil it's discarded.
+	Stdout io.Writer
+}
+
+// name returns the rrdtool command name of c.
+func (c *RRDToolCmd) name() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return c.Args[0]
+}
+
+// Executor runs rrdtool commands, for operations which rrdcached can't
+// perform such as Dump, Restore, Resize and Xport.
+type Executor interface {
+	Exec(ctx context.Context, cmd *RRDToolCmd) error
+}
+
+// RRDToolError is the error returned when rrdtool reports a failure.
+type RRDToolError struct {
+	// Cmd is the rrdtool command which failed e.g. "dump".
+	Cmd string
+
+	// Msg is the error message reported by rrdtool.
+	Msg string
+
+	// Err is the underlying error, if any, such as the exit status.
+	Err error
+}
+
+func (e *RRDToolError) Error() string {
+	switch {
+	case e.Msg == "":
+		return fmt.Sprintf("rrdtool %v: %v", e.Cmd, e.Err)
+	case e.Err == nil:
+		return fmt.Sprintf("rrdtool %v: %v", e.Cmd, e.Msg)
+	}
+	return fmt.Sprintf("rrdtool %v: %v: %v", e.Cmd, e.Msg, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RRDToolError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if target is the sentinel error which e represents, false
+// otherwise. The supported sentinels are ErrNotFound, ErrExist and
+// ErrPermissionDenied.
+func (e *RRDToolError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return strings.Contains(e.Msg, "No such file")
+	case ErrExist:
+		return strings.Contains(e.Msg, "File exists")
+	case ErrPermissionDenied:
+		return strings.Contains(e.Msg, "Permission denied")
+	}
+	return false
+}
+
+// RRDToolExec is an Executor which runs the rrdtool binary at Path for each
+// command.
+type RRDToolExec struct {
+	Path string
+}
+
+// Exec implements Executor.
+func (e *RRDToolExec) Exec(ctx context.Context, cmd *RRDToolCmd) error {
+	var stderr bytes.Buffer
+	c := exec.CommandContext(ctx, e.Path, cmd.Args...)
+	c.Dir = cmd.Dir
+	c.Stdout = cmd.Stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		msg := strings.TrimPrefix(strings.TrimSpace(stderr.String()), "ERROR: ")
+		return &RRDToolError{Cmd: cmd.name(), Msg: msg, Err: err}
+	}
+	return nil
+}
+
+// RRDToolPipe is an Executor which runs commands using a single rrdtool
+// process in remote control mode, "rrdtool -", avoiding the cost of starting
+// a process per command. Commands are run one at a time and only commands
+// with text output are supported.
+type RRDToolPipe struct {
+	path string
+
+	m      sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	cwd    string
+	closed bool
+}
+
+// NewRRDToolPipe returns a new RRDToolPipe running the rrdtool binary at
+// path. The process is started on first use and must be stopped with Close.
+func NewRRDToolPipe(path string) *RRDToolPipe {
+	return &RRDToolPipe{path: path}
+}
+
+// Exec implements Executor.
+func (p *RRDToolPipe) Exec(ctx context.Context, cmd *RRDToolCmd) error {
+	line, err := pipeLine(cmd.Args)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(cmd.Dir)
+	if err != nil {
+		return err
+	}
+	stdout := cmd.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if p.closed {
+		return os.ErrClosed
+	}
+	if err := p.start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		if dir != p.cwd {
+			cd, err := pipeLine([]string{"cd", dir})
+			if err == nil {
+				err = p.run("cd", cd, io.Discard)
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+			p.cwd = dir
+		}
+		done <- p.run(cmd.name(), line, stdout)
+	}()
+
+	select {
+	case err := <-done:
+		var rerr *RRDToolError
+		if err != nil && (!errors.As(err, &rerr) || rerr.Err != nil) {
+			// The state of the process is unknown.
+			p.stop()
+		}
+		return err
+	case <-ctx.Done():
+		p.stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// start starts the rrdtool process if it's not running.
+func (p *RRDToolPipe) start() error {
+	if p.cmd != nil {
+		return nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(p.path, "-")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return &RRDToolError{Cmd: "-", Err: err}
+	}
+
+	p.cmd, p.stdin, p.stdout, p.cwd = cmd, stdin, bufio.NewReader(stdout), cwd
+	return nil
+}
+
+// stop stops the rrdtool process if it's running.
+func (p *RRDToolPipe) stop() {
+	if p.cmd == nil {
+		return
+	}
+	p.stdin.Close()      // nolint: errcheck
+	p.cmd.Process.Kill() // nolint: errcheck
+	p.cmd.Wait()         // nolint: errcheck
+	p.cmd, p.stdin, p.stdout = nil, nil, nil
+}
+
+// run sends line for the command name to the process, copying its output to
+// w until the status line.
+func (p *RRDToolPipe) run(name, line string, w io.Writer) error {
+	if _, err := io.WriteString(p.stdin, line+"\n"); err != nil {
+		return &RRDToolError{Cmd: name, Err: err}
+	}
+
+	for {
+		l, err := p.stdout.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return &RRDToolError{Cmd: name, Err: err}
+		}
+		switch {
+		case strings.HasPrefix(l, "OK u:"):
+			return nil
+		case strings.HasPrefix(l, "ERROR: "):
+			return &RRDToolError{Cmd: name, Msg: strings.TrimSpace(l[len("ERROR: "):])}
+		}
+		if _, err := io.WriteString(w, l); err != nil {
+			return err
+		}
+	}
+}
+
+// Close stops the rrdtool process. Further commands fail with os.ErrClosed.
+func (p *RRDToolPipe) Close() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.closed = true
+	p.stop()
+	return nil
+}
+
+// pipeLine returns args as a line for rrdtool remote control mode, which
+// splits lines on spaces but treats quoted strings as a single argument.
+func pipeLine(args []string) (string, error) {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		switch {
+		case strings.ContainsAny(a, "\r\n\x00"):
+			return "", fmt.Errorf("%w: invalid character in %q", ErrInvalidArg, a)
+		case a != "" && !strings.ContainsAny(a, " \t'\""):
+			parts[i] = a
+		case !strings.Contains(a, "'"):
+			parts[i] = "'" + a + "'"
+		case !strings.Contains(a, `"`):
+			parts[i] = `"` + a + `"`
+		default:
+			return "", fmt.Errorf("%w: %q contains both quote characters", ErrInvalidArg, a)
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// RRDToolExecutor sets the Executor used for commands which rrdcached
+// doesn't support natively, by default rrdtool is run for each command.
+func RRDToolExecutor(e Executor) func(*Client) error {
+	return func(c *Client) error {
+		if e == nil {
+			return ErrNilOption
+		}
+		c.executor = e
+		return nil
+	}
+}
+
+// RRDToolFallback sets the client to run rrdtool when the server doesn't
+// support the create, first, last or info commands, such as rrdcached
+// versions before 1.5. The daemon is used for reads with --daemon, which
+// isn't supported if the client uses TLS, a Proxy or a Dialer, while
+// create is performed directly, so filenames must be valid on the local
+// host.
+func RRDToolFallback(c *Client) error {
+	c.rrdtoolFallback = true
+	return nil
+}
+
+// ExecRRDTool runs cmd with the clients Executor.
+func (c *Client) ExecRRDTool(ctx context.Context, cmd *RRDToolCmd) error {
+	e := c.executor
+	if e == nil {
+		e = &RRDToolExec{Path: c.rrdtool}
+	}
+	return e.Exec(ctx, cmd)
+}
+
+// Dump writes the XML representation of the RRD filename to w, as rrdtool
+// dump does, after flushing any pending updates. As rrdtool connects to the
+// server with --daemon, ErrNotSupported is returned if the client uses TLS,
+// a Proxy or a Dialer.
+func (c *Client) Dump(ctx context.Context, filename string, w io.Writer) error {
+	daemon, err := c.DaemonAddr()
+	if err != nil {
+		return fmt.Errorf("dump: %w", err)
+	}
+
+	if !c.readOnly {
+		if err := c.FlushWithContext(ctx, filename); err != nil && !IsNotExist(err) {
+			return fmt.Errorf("dump: failed to flush: %w", err)
+		}
+	}
+
+	if err := c.ExecRRDTool(ctx, &RRDToolCmd{
+		Args:   []string{"dump", "--daemon", daemon, filename},
+		Stdout: w,
+	}); err != nil {
+		return fmt.Errorf("dump: %w", err)
+	}
+	return nil
+}
+
+// Restore creates the RRD filename from the XML representation read from r,
+// as produced by Dump, replacing an existing file only if force is true.
+// The file is written directly by rrdtool so filename must be valid on the
+// local host, any updates pending for it are discarded.
+func (c *Client) Restore(ctx context.Context, filename string, r io.Reader, force bool) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	tmp, err := os.CreateTemp("", "rrd-restore-*.xml")
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	if err := c.ForgetWithContext(ctx, filename); err != nil && !IsNotExist(err) {
+		return fmt.Errorf("restore: failed to forget: %w", err)
+	}
+
+	args := []string{"restore"}
+	if force {
+		args = append(args, "-f")
+	}
+	if err := c.ExecRRDTool(ctx, &RRDToolCmd{Args: append(args, tmp.Name(), filename)}); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	c.InvalidateInfo(filename)
+	return nil
+}
+
+// Resize changes the number of rows of the archive rra of the RRD filename
+// by rows, adding rows if positive and removing them if negative, as
+// rrdtool resize does. Pending updates are flushed first and the resized
+// file replaces the original, so filename must be valid on the local host.
+func (c *Client) Resize(ctx context.Context, filename string, rra, rows int) error {
+	switch {
+	case c.readOnly:
+		return ErrReadOnly
+	case rra < 0:
+		return fmt.Errorf("resize: %w %v", ErrInvalidRRAIndex, rra)
+	case rows == 0:
+		return fmt.Errorf("resize: %w: rows 0", ErrInvalidArg)
+	}
+
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return fmt.Errorf("resize: %w", err)
+	}
+	if err := c.FlushWithContext(ctx, filename); err != nil {
+		return fmt.Errorf("resize: failed to flush: %w", err)
+	}
+
+	// rrdtool writes the result to resize.rrd in its working directory,
+	// which is created next to the file so it can be renamed over it.
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".resize-")
+	if err != nil {
+		return fmt.Errorf("resize: %w", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	op := "GROW"
+	if rows < 0 {
+		op, rows = "SHRINK", -rows
+	}
+	if err := c.ExecRRDTool(ctx, &RRDToolCmd{
+		Args: []string{"resize", path, strconv.Itoa(rra), op, strconv.Itoa(rows)},
+		Dir:  dir,
+	}); err != nil {
+		return fmt.Errorf("resize: %w", err)
+	}
+
+	if err := os.Rename(filepath.Join(dir, "resize.rrd"), path); err != nil {
+		return fmt.Errorf("resize: %w", err)
+	}
+	c.InvalidateInfo(filename)
+	return nil
+}
+
+// rrdtoolFallbacks are the commands which can be performed by rrdtool if
+// the server doesn't support them, keyed by name.
+var rrdtoolFallbacks = map[string]struct {
+	// daemon is true if rrdtool should use the server with --daemon.
+	daemon bool
+
+	// args returns the rrdtool arguments following the command name for
+	// the command arguments args.
+	args func(args []string) ([]string, error)
+
+	// line converts the rrdtool output line l to the response lines of
+	// the server, passing them to f.
+	line func(l string, f LineFunc) error
+}{
+	"create": {},
+	"first": {daemon: true, args: func(args []string) ([]string, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%w: first requires a filename and rra index", ErrInvalidArg)
+		}
+		return []string{args[0], "--rraindex", args[1]}, nil
+	}},
+	"last": {daemon: true},
+	"info": {daemon: true, line: infoLine},
+}
+
+// fallback performs cmd with rrdtool, calling f for each line of the
+// equivalent server response.
+func (c *Client) fallback(ctx context.Context, cmd *Cmd, f LineFunc) error {
+	fb := rrdtoolFallbacks[cmd.verb()]
+	var args []string
+	for _, a := range cmd.args {
+		if s, ok := a.(string); ok {
+			args = append(args, s)
+		} else {
+			// Options such as Step format as "-s 60".
+			args = append(args, strings.Fields(fmt.Sprint(a))...)
+		}
+	}
+	if fb.args != nil {
+		var err error
+		if args, err = fb.args(args); err != nil {
+			return err
+		}
+	}
+	if fb.daemon {
+		daemon, err := c.DaemonAddr()
+		if err != nil {
+			return err
+		}
+		args = append([]string{"--daemon", daemon}, args...)
+	}
+
+	var stdout bytes.Buffer
+	if err := c.ExecRRDTool(ctx, &RRDToolCmd{Args: append([]string{cmd.verb()}, args...), Stdout: &stdout}); err != nil {
+		return err
+	}
+
+	sc := bufio.NewScanner(&stdout)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" {
+			continue
+		}
+		var err error
+		if fb.line != nil {
+			err = fb.line(l, f)
+		} else {
+			err = f(l)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// canFallback returns true if cmd, which failed with err, can be performed
+// by rrdtool instead.
+func (c *Client) canFallback(cmd *Cmd, err error) bool {
+	if !c.rrdtoolFallback || !errors.Is(err, ErrNotSupported) || len(strings.Fields(cmd.cmd)) != 1 {
+		return false
+	}
+	_, ok := rrdtoolFallbacks[cmd.verb()]
+	return ok
+}
+
+// infoLine converts the rrdtool info line l, "key = value", to the
+// "key type value" form returned by the server.
+func infoLine(l string, f LineFunc) error {
+	key, v, ok := strings.Cut(l, " = ")
+	if !ok {
+		return NewInvalidResponseError("info: invalid line", l)
+	}
+
+	switch {
+	case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+		return f(key + " 2 " + v[1:len(v)-1])
+	case strings.EqualFold(v, "-nan"):
+		return f(key + " 0 NaN")
+	}
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return f(key + " 1 " + v)
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return f(key + " 0 " + v)
+	}
+	// Other types, such as blobs, aren't returned by the server.
+	return nil
+}
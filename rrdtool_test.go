@@ -0,0 +1,291 @@
+package rrd
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRRDTool is a fake rrdtool which records its arguments, one per line,
+// to the file args in its directory and emulates the commands used by the
+// tests.
+const testRRDTool = `#!/bin/sh
+dir=$(dirname "$0")
+printf '%s\n' "$@" > "$dir/args"
+case "$1" in
+dump) echo "<rrd/>" ;;
+restore) shift; [ "$1" = -f ] && shift; cp "$1" "$2" ;;
+resize) echo resized > resize.rrd ;;
+first|last) echo 1499909100 ;;
+info)
+	echo 'filename = "test.rrd"'
+	echo 'step = 300'
+	echo 'ds[watts].max = 1.0000000000e+02'
+	echo 'ds[watts].min = -nan'
+	echo 'rra[0].cf = "AVERAGE"'
+	;;
+create) ;;
+missing) echo "ERROR: opening 'missing.rrd': No such file or directory" >&2; exit 1 ;;
+esac
+`
+
+// newTestRRDTool writes testRRDTool to a temporary directory returning its
+// path.
+func newTestRRDTool(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	rrdtool := filepath.Join(t.TempDir(), "rrdtool")
+	if !assert.NoError(t, os.WriteFile(rrdtool, []byte(testRRDTool), 0700)) {
+		t.FailNow()
+	}
+	return rrdtool
+}
+
+// rrdtoolArgs returns the arguments of the last run of the rrdtool.
+func rrdtoolArgs(t *testing.T, rrdtool string) []string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(rrdtool), "args"))
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestPipeLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		expect string
+		err    error
+	}{
+		{name: "plain", args: []string{"dump", "test.rrd"}, expect: "dump test.rrd"},
+		{name: "space", args: []string{"dump", "my test.rrd"}, expect: "dump 'my test.rrd'"},
+		{name: "empty", args: []string{"graph", ""}, expect: "graph ''"},
+		{name: "single-quote", args: []string{"GPRINT:x:it's"}, expect: `"GPRINT:x:it's"`},
+		{name: "both-quotes", args: []string{`'"`}, err: ErrInvalidArg},
+		{name: "newline", args: []string{"a\nb"}, err: ErrInvalidArg},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := pipeLine(tc.args)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, l)
+		})
+	}
+}
+
+func TestRRDToolError(t *testing.T) {
+	err := &RRDToolError{Cmd: "dump", Msg: "opening 'test.rrd': No such file or directory"}
+	assert.True(t, IsNotExist(err))
+	assert.False(t, IsExist(err))
+	assert.Equal(t, "rrdtool dump: opening 'test.rrd': No such file or directory", err.Error())
+
+	err = &RRDToolError{Cmd: "restore", Msg: "creating 'test.rrd': File exists", Err: context.Canceled}
+	assert.True(t, IsExist(err))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRRDToolExec(t *testing.T) {
+	rrdtool := newTestRRDTool(t)
+	e := &RRDToolExec{Path: rrdtool}
+
+	var buf bytes.Buffer
+	assert.NoError(t, e.Exec(context.Background(), &RRDToolCmd{Args: []string{"dump", "a b.rrd"}, Stdout: &buf}))
+	assert.Equal(t, "<rrd/>\n", buf.String())
+	assert.Equal(t, []string{"dump", "a b.rrd"}, rrdtoolArgs(t, rrdtool))
+
+	err := e.Exec(context.Background(), &RRDToolCmd{Args: []string{"missing"}})
+	assert.True(t, IsNotExist(err))
+	assert.EqualError(t, err, "rrdtool missing: opening 'missing.rrd': No such file or directory: exit status 1")
+}
+
+func TestDumpRestoreResize(t *testing.T) {
+	rrdtool := newTestRRDTool(t)
+
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{"flush": {"0 Successfully flushed"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), RRDTool(rrdtool))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if assert.NoError(t, c.Dump(ctx, "test.rrd", &buf)) {
+		assert.Equal(t, "<rrd/>\n", buf.String())
+		assert.Equal(t, []string{"dump", "--daemon", s.Addr, "test.rrd"}, rrdtoolArgs(t, rrdtool))
+		assert.Equal(t, 1, s.count("flush test.rrd"))
+	}
+
+	path := filepath.Join(t.TempDir(), "test.rrd")
+	if assert.NoError(t, c.Restore(ctx, path, strings.NewReader("<rrd/>"), true)) {
+		args := rrdtoolArgs(t, rrdtool)
+		if assert.Len(t, args, 4) {
+			assert.Equal(t, []string{"restore", "-f"}, args[:2])
+			assert.Equal(t, path, args[3])
+		}
+		b, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "<rrd/>", string(b))
+		assert.Equal(t, 1, s.count("forget "+path))
+	}
+
+	if assert.NoError(t, c.Resize(ctx, path, 1, -10)) {
+		assert.Equal(t, []string{"resize", path, "1", "SHRINK", "10"}, rrdtoolArgs(t, rrdtool))
+		b, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "resized\n", string(b))
+		entries, err := os.ReadDir(filepath.Dir(path))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	}
+	assert.ErrorIs(t, c.Resize(ctx, path, -1, 10), ErrInvalidRRAIndex)
+	assert.ErrorIs(t, c.Resize(ctx, path, 0, 0), ErrInvalidArg)
+
+	c.readOnly = true
+	assert.ErrorIs(t, c.Restore(ctx, path, strings.NewReader("<rrd/>"), true), ErrReadOnly)
+	assert.ErrorIs(t, c.Resize(ctx, path, 0, 1), ErrReadOnly)
+}
+
+func TestRRDToolFallback(t *testing.T) {
+	rrdtool := newTestRRDTool(t)
+
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{}
+	for _, cmd := range []string{"create", "first", "last", "info", "tune"} {
+		s.responses[cmd] = []string{"-1 Unknown command: " + strings.ToUpper(cmd)}
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), RRDTool(rrdtool))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	_, err = c.Last("test.rrd")
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	c.rrdtoolFallback = true
+	last, err := c.Last("test.rrd")
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Unix(1499909100, 0), last)
+		assert.Equal(t, []string{"last", "--daemon", s.Addr, "test.rrd"}, rrdtoolArgs(t, rrdtool))
+	}
+
+	first, err := c.First("test.rrd", 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Unix(1499909100, 0), first)
+		assert.Equal(t, []string{"first", "--daemon", s.Addr, "test.rrd", "--rraindex", "1"}, rrdtoolArgs(t, rrdtool))
+	}
+
+	info, err := c.InfoMap("test.rrd")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test.rrd", info["filename"])
+		assert.Equal(t, int64(300), info["step"])
+		assert.Equal(t, 100.0, info["ds[watts].max"])
+		assert.True(t, math.IsNaN(info["ds[watts].min"].(float64)))
+		assert.Equal(t, "AVERAGE", info["rra[0].cf"])
+	}
+
+	err = c.Create("new.rrd", []DS{NewGauge("watts", time.Minute, 0, 100)}, []RRA{NewAverage(0.5, 1, 10)}, Step(time.Minute))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"create", "new.rrd", "-s", "60", "DS:watts:GAUGE:60:0:100", "RRA:AVERAGE:0.5:1:10"}, rrdtoolArgs(t, rrdtool))
+	}
+
+	// Commands without a fallback still fail.
+	_, err = c.ExecCmd(NewCmd("tune").WithArgs("test.rrd"))
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
+
+func TestRRDToolPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	// The fake rrdtool echoes commands, reporting the last cd.
+	script := `#!/bin/sh
+cwd=
+while read -r cmd args; do
+	case "$cmd" in
+	cd) cwd="$args" ;;
+	fail) echo "ERROR: failed"; continue ;;
+	sleep) sleep 10 ;;
+	*) echo "$cwd $cmd $args" ;;
+	esac
+	echo "OK u:0.00 s:0.00 r:0.00"
+done
+`
+	rrdtool := filepath.Join(t.TempDir(), "rrdtool")
+	if !assert.NoError(t, os.WriteFile(rrdtool, []byte(script), 0700)) {
+		return
+	}
+
+	p := NewRRDToolPipe(rrdtool)
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if assert.NoError(t, p.Exec(ctx, &RRDToolCmd{Args: []string{"dump", "a b.rrd"}, Stdout: &buf})) {
+		assert.Equal(t, " dump 'a b.rrd'\n", buf.String())
+	}
+
+	buf.Reset()
+	dir := t.TempDir()
+	if assert.NoError(t, p.Exec(ctx, &RRDToolCmd{Args: []string{"resize", "test.rrd"}, Dir: dir, Stdout: &buf})) {
+		assert.Equal(t, dir+" resize test.rrd\n", buf.String())
+	}
+
+	err := p.Exec(ctx, &RRDToolCmd{Args: []string{"fail"}})
+	assert.EqualError(t, err, "rrdtool fail: failed")
+
+	// The process survives rrdtool errors.
+	pid := p.cmd.Process.Pid
+	assert.NoError(t, p.Exec(ctx, &RRDToolCmd{Args: []string{"last", "test.rrd"}}))
+	assert.Equal(t, pid, p.cmd.Process.Pid)
+
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	assert.ErrorIs(t, p.Exec(tctx, &RRDToolCmd{Args: []string{"sleep"}}), context.DeadlineExceeded)
+	assert.Nil(t, p.cmd)
+
+	// The process is restarted after a failure.
+	buf.Reset()
+	if assert.NoError(t, p.Exec(ctx, &RRDToolCmd{Args: []string{"last", "test.rrd"}, Stdout: &buf})) {
+		assert.Equal(t, " last test.rrd\n", buf.String())
+	}
+
+	assert.NoError(t, p.Close())
+	assert.ErrorIs(t, p.Exec(ctx, &RRDToolCmd{Args: []string{"last", "test.rrd"}}), os.ErrClosed)
+}
@@ -0,0 +1,163 @@
+package rrdwhisper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	rrd "github.com/thz/go-rrd"
+)
+
+const (
+	// DefaultDSName is the default name of the data source of imported RRDs.
+	DefaultDSName = "value"
+
+	// DefaultBatchSize is the default number of samples sent in each batch.
+	DefaultBatchSize = 10000
+
+	// samplesPerUpdate is the number of samples sent in each update command.
+	samplesPerUpdate = 100
+)
+
+// cfs maps whisper aggregation methods to consolidation functions. Methods
+// without an equivalent use AVERAGE.
+var cfs = map[AggregationMethod]rrd.ConsolidationFunc{
+	Average: rrd.Average,
+	Last:    rrd.Last,
+	Max:     rrd.Max,
+	Min:     rrd.Min,
+}
+
+// Definition returns the definition of an RRD equivalent to f, with a
+// single GAUGE data source named ds and an RRA for each retention archive.
+func Definition(f *File, ds string) *rrd.CreateRRD {
+	step := f.Archives[0].SecondsPerPoint
+	cf, ok := cfs[f.AggregationMethod]
+	if !ok {
+		cf = rrd.Average
+	}
+
+	rras := make([]rrd.RRA, len(f.Archives))
+	for i, a := range f.Archives {
+		rras[i] = rrd.NewRRA(fmt.Sprintf("RRA:%v:%v:%v:%v", cf, f.XFilesFactor, a.SecondsPerPoint/step, a.Points))
+	}
+
+	heartbeat := 2 * step
+	return rrd.NewCreateRRD(
+		[]rrd.DS{rrd.NewDS(fmt.Sprintf("DS:%v:GAUGE:%v:U:U", ds, heartbeat))},
+		rras,
+	).WithStep(f.Archives[0].Step())
+}
+
+// Importer imports whisper files into RRDs.
+type Importer struct {
+	c           *rrd.Client
+	ds          string
+	batchSize   int
+	noOverwrite bool
+}
+
+// DSName sets the name of the data source of imported RRDs, which defaults
+// to DefaultDSName.
+func DSName(name string) func(*Importer) error {
+	return func(i *Importer) error {
+		if name == "" {
+			return fmt.Errorf("%w: empty ds name", rrd.ErrInvalidArg)
+		}
+		i.ds = name
+		return nil
+	}
+}
+
+// BatchSize sets the number of samples sent in each batch, which defaults
+// to DefaultBatchSize.
+func BatchSize(n int) func(*Importer) error {
+	return func(i *Importer) error {
+		if n < 1 {
+			return fmt.Errorf("%w: batch size %v", rrd.ErrInvalidArg, n)
+		}
+		i.batchSize = n
+		return nil
+	}
+}
+
+// NoOverwrite prevents existing RRDs being overwritten by an import.
+func NoOverwrite(i *Importer) error {
+	i.noOverwrite = true
+	return nil
+}
+
+// NewImporter returns a new Importer which creates and updates RRDs with c.
+func NewImporter(c *rrd.Client, opts ...func(*Importer) error) (*Importer, error) {
+	i := &Importer{
+		c:         c,
+		ds:        DefaultDSName,
+		batchSize: DefaultBatchSize,
+	}
+	for _, o := range opts {
+		if o == nil {
+			return nil, rrd.ErrNilOption
+		}
+		if err := o(i); err != nil {
+			return nil, err
+		}
+	}
+	return i, nil
+}
+
+// Import imports the whisper file src into the RRD dst.
+func (i *Importer) Import(ctx context.Context, src, dst string) error {
+	f, closer, err := Open(src)
+	if err != nil {
+		return err
+	}
+	defer closer.Close() // nolint: errcheck
+
+	return i.ImportFile(ctx, f, dst)
+}
+
+// ImportFile creates the RRD dst equivalent to f and replays its history
+// in batches of updates.
+func (i *Importer) ImportFile(ctx context.Context, f *File, dst string) error {
+	history, err := f.History()
+	if err != nil {
+		return err
+	}
+
+	def := Definition(f, i.ds)
+	if len(history) > 0 {
+		def.WithStart(history[0].Time.Add(-time.Second))
+	}
+	if i.noOverwrite {
+		def.WithNoOverwrite()
+	}
+	if err := i.c.CreateFromWithContext(ctx, dst, def); err != nil {
+		return fmt.Errorf("create %v: %w", dst, err)
+	}
+
+	for len(history) > 0 {
+		n := min(len(history), i.batchSize)
+		if err := i.update(ctx, dst, history[:n]); err != nil {
+			return fmt.Errorf("update %v: %w", dst, err)
+		}
+		history = history[n:]
+	}
+	return nil
+}
+
+// update sends points to dst in a single batch.
+func (i *Importer) update(ctx context.Context, dst string, points []Point) error {
+	b := i.c.NewBatch()
+	for len(points) > 0 {
+		n := min(len(points), samplesPerUpdate)
+		samples := make([]rrd.Sample, n)
+		for j, p := range points[:n] {
+			samples[j] = rrd.Sample{Time: p.Time, Values: []float64{p.Value}}
+		}
+		if err := b.Update(dst, samples...); err != nil {
+			return err
+		}
+		points = points[n:]
+	}
+	return b.ExecWithContext(ctx)
+}
@@ -0,0 +1,79 @@
+package rrdwhisper
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rrd "github.com/thz/go-rrd"
+	"github.com/thz/go-rrd/rrdtest"
+)
+
+func TestDefinition(t *testing.T) {
+	f, err := Parse(bytes.NewReader(testFile()))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	def := Definition(f, "load")
+	assert.Equal(t, []rrd.DS{"DS:load:GAUGE:120:U:U"}, def.DS)
+	assert.Equal(t, []rrd.RRA{"RRA:MAX:0.5:1:5", "RRA:MAX:0.5:5:4"}, def.RRA)
+	assert.Equal(t, []rrd.CreateOption{rrd.Step(time.Minute)}, def.Options)
+
+	f.AggregationMethod = Sum
+	assert.Equal(t, []rrd.RRA{"RRA:AVERAGE:0.5:1:5", "RRA:AVERAGE:0.5:5:4"}, Definition(f, "load").RRA)
+}
+
+func TestImporter(t *testing.T) {
+	_, err := NewImporter(nil, BatchSize(0))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+	_, err = NewImporter(nil, DSName(""))
+	assert.ErrorIs(t, err, rrd.ErrInvalidArg)
+	_, err = NewImporter(nil, nil)
+	assert.ErrorIs(t, err, rrd.ErrNilOption)
+
+	s, err := rrdtest.NewServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := rrd.NewClient(s.Addr, rrd.Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	path := filepath.Join(t.TempDir(), "test.wsp")
+	if !assert.NoError(t, os.WriteFile(path, testFile(), 0o600)) {
+		return
+	}
+
+	imp, err := NewImporter(c, BatchSize(4), NoOverwrite)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, imp.Import(context.Background(), path, "test.rrd"))
+
+	assert.Equal(t, []string{
+		"create test.rrd -s 60 -b 1499999399 -O DS:value:GAUGE:120:U:U RRA:MAX:0.5:1:5 RRA:MAX:0.5:5:4",
+		"batch",
+		"update test.rrd 1499999400:10 1499999700:11 1500000000:1 1500000060:2",
+		".",
+		"batch",
+		"update test.rrd 1500000120:3 1500000180:4 1500000240:5",
+		".",
+	}, s.Received())
+
+	s.Handle("create", "-1 RRD file already exists")
+	err = imp.Import(context.Background(), path, "test.rrd")
+	assert.Error(t, err)
+}
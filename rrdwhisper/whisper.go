@@ -0,0 +1,212 @@
+// Package rrdwhisper imports Graphite whisper files into RRDs via rrdcached,
+// mapping whisper retention archives to RRAs and replaying their points.
+package rrdwhisper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	metadataSize    = 16
+	archiveInfoSize = 12
+	pointSize       = 12
+)
+
+// ErrInvalidFile is returned when a file isn't a valid whisper file.
+var ErrInvalidFile = errors.New("invalid whisper file")
+
+// AggregationMethod is the method a whisper file uses to aggregate points
+// into lower precision archives.
+type AggregationMethod uint32
+
+// Whisper aggregation methods.
+const (
+	Average AggregationMethod = iota + 1
+	Sum
+	Last
+	Max
+	Min
+	AvgZero
+	AbsMax
+	AbsMin
+)
+
+var aggregationNames = map[AggregationMethod]string{
+	Average: "average",
+	Sum:     "sum",
+	Last:    "last",
+	Max:     "max",
+	Min:     "min",
+	AvgZero: "avg_zero",
+	AbsMax:  "absmax",
+	AbsMin:  "absmin",
+}
+
+func (m AggregationMethod) String() string {
+	if s, ok := aggregationNames[m]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(m))
+}
+
+// Archive describes a retention archive of a whisper file.
+type Archive struct {
+	SecondsPerPoint uint32
+	Points          uint32
+
+	offset uint32
+}
+
+// Step returns the interval between the points of a.
+func (a Archive) Step() time.Duration {
+	return time.Duration(a.SecondsPerPoint) * time.Second
+}
+
+// Retention returns the duration covered by a.
+func (a Archive) Retention() time.Duration {
+	return a.Step() * time.Duration(a.Points)
+}
+
+// Point is a value of a whisper archive.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// File is a whisper file.
+type File struct {
+	AggregationMethod AggregationMethod
+	MaxRetention      uint32
+	XFilesFactor      float32
+	Archives          []Archive
+
+	r io.ReaderAt
+}
+
+// Open opens the whisper file path, which must be closed by the caller
+// with the returned io.Closer once finished with.
+func Open(path string) (*File, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wf, err := Parse(f)
+	if err != nil {
+		f.Close() // nolint: errcheck
+		return nil, nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return wf, f, nil
+}
+
+// Parse parses the header of the whisper file read from r, which is used
+// to read points on demand.
+func Parse(r io.ReaderAt) (*File, error) {
+	var buf [metadataSize]byte
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidFile, err)
+	}
+
+	f := &File{
+		AggregationMethod: AggregationMethod(binary.BigEndian.Uint32(buf[0:])),
+		MaxRetention:      binary.BigEndian.Uint32(buf[4:]),
+		XFilesFactor:      math.Float32frombits(binary.BigEndian.Uint32(buf[8:])),
+		r:                 r,
+	}
+	count := binary.BigEndian.Uint32(buf[12:])
+	if count == 0 || count > 64 {
+		return nil, fmt.Errorf("%w: archive count %v", ErrInvalidFile, count)
+	}
+
+	f.Archives = make([]Archive, count)
+	for i := range f.Archives {
+		var ab [archiveInfoSize]byte
+		if _, err := r.ReadAt(ab[:], int64(metadataSize+i*archiveInfoSize)); err != nil {
+			return nil, fmt.Errorf("%w: archive %v: %v", ErrInvalidFile, i, err)
+		}
+		a := Archive{
+			offset:          binary.BigEndian.Uint32(ab[0:]),
+			SecondsPerPoint: binary.BigEndian.Uint32(ab[4:]),
+			Points:          binary.BigEndian.Uint32(ab[8:]),
+		}
+		if a.SecondsPerPoint == 0 || a.Points == 0 {
+			return nil, fmt.Errorf("%w: archive %v: empty", ErrInvalidFile, i)
+		}
+		if i > 0 && a.SecondsPerPoint <= f.Archives[i-1].SecondsPerPoint {
+			return nil, fmt.Errorf("%w: archive %v: precision not decreasing", ErrInvalidFile, i)
+		}
+		f.Archives[i] = a
+	}
+
+	return f, nil
+}
+
+// Points returns the points stored in archive i ordered by time.
+// Slots which have never been written are omitted.
+func (f *File) Points(i int) ([]Point, error) {
+	if i < 0 || i >= len(f.Archives) {
+		return nil, fmt.Errorf("archive %v out of range", i)
+	}
+
+	a := f.Archives[i]
+	buf := make([]byte, int(a.Points)*pointSize)
+	if _, err := f.r.ReadAt(buf, int64(a.offset)); err != nil {
+		return nil, fmt.Errorf("%w: archive %v points: %v", ErrInvalidFile, i, err)
+	}
+
+	points := make([]Point, 0, a.Points)
+	for off := 0; off < len(buf); off += pointSize {
+		ts := binary.BigEndian.Uint32(buf[off:])
+		if ts == 0 || ts%a.SecondsPerPoint != 0 {
+			continue
+		}
+		points = append(points, Point{
+			Time:  time.Unix(int64(ts), 0),
+			Value: math.Float64frombits(binary.BigEndian.Uint64(buf[off+4:])),
+		})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+
+	// Only keep the latest cycle of the ring buffer, older slots may
+	// contain stale points which have since been overwritten.
+	if len(points) > 0 {
+		oldest := points[len(points)-1].Time.Add(-a.Retention())
+		n := sort.Search(len(points), func(i int) bool {
+			return points[i].Time.After(oldest)
+		})
+		points = points[n:]
+	}
+
+	return points, nil
+}
+
+// History returns the points of all archives ordered by time, using the
+// highest precision archive available for each period.
+func (f *File) History() ([]Point, error) {
+	var history []Point
+	for i := range f.Archives {
+		points, err := f.Points(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(history) > 0 {
+			// Only keep points older than those of higher precision.
+			n := sort.Search(len(points), func(i int) bool {
+				return !points[i].Time.Before(history[0].Time)
+			})
+			points = points[:n]
+		}
+		history = append(points, history...)
+	}
+	return history, nil
+}
@@ -0,0 +1,153 @@
+package rrdwhisper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testArchive describes an archive of a whisper file built by newWhisper.
+type testArchive struct {
+	spp    uint32
+	points uint32
+	// values maps slot index to the point stored in it.
+	values map[int]Point
+}
+
+// newWhisper returns the contents of a whisper file with archives.
+func newWhisper(agg AggregationMethod, xff float32, archives ...testArchive) []byte {
+	var buf bytes.Buffer
+	var maxRet uint32
+	for _, a := range archives {
+		maxRet = max(maxRet, a.spp*a.points)
+	}
+	binary.Write(&buf, binary.BigEndian, uint32(agg))           // nolint: errcheck
+	binary.Write(&buf, binary.BigEndian, maxRet)                // nolint: errcheck
+	binary.Write(&buf, binary.BigEndian, math.Float32bits(xff)) // nolint: errcheck
+	binary.Write(&buf, binary.BigEndian, uint32(len(archives))) // nolint: errcheck
+	offset := uint32(metadataSize + archiveInfoSize*len(archives))
+	for _, a := range archives {
+		binary.Write(&buf, binary.BigEndian, offset)   // nolint: errcheck
+		binary.Write(&buf, binary.BigEndian, a.spp)    // nolint: errcheck
+		binary.Write(&buf, binary.BigEndian, a.points) // nolint: errcheck
+		offset += a.points * pointSize
+	}
+	for _, a := range archives {
+		for i := 0; i < int(a.points); i++ {
+			p, ok := a.values[i]
+			if !ok {
+				buf.Write(make([]byte, pointSize))
+				continue
+			}
+			binary.Write(&buf, binary.BigEndian, uint32(p.Time.Unix()))     // nolint: errcheck
+			binary.Write(&buf, binary.BigEndian, math.Float64bits(p.Value)) // nolint: errcheck
+		}
+	}
+	return buf.Bytes()
+}
+
+func point(ts int64, v float64) Point {
+	return Point{Time: time.Unix(ts, 0), Value: v}
+}
+
+// testFile returns a whisper file with a 60s archive of 5 points and a
+// 300s archive of 4 points.
+func testFile() []byte {
+	return newWhisper(Max, 0.5,
+		testArchive{spp: 60, points: 5, values: map[int]Point{
+			// Ring buffer which has wrapped.
+			0: point(1500000240, 5),
+			1: point(1500000000, 1),
+			2: point(1500000060, 2),
+			3: point(1500000120, 3),
+			4: point(1500000180, 4),
+		}},
+		testArchive{spp: 300, points: 4, values: map[int]Point{
+			0: point(1499999400, 10),
+			1: point(1499999700, 11),
+			2: point(1500000000, 12),
+		}},
+	)
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse(bytes.NewReader(testFile()))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, Max, f.AggregationMethod)
+	assert.Equal(t, "max", f.AggregationMethod.String())
+	assert.Equal(t, float32(0.5), f.XFilesFactor)
+	assert.Equal(t, uint32(1200), f.MaxRetention)
+	if assert.Len(t, f.Archives, 2) {
+		assert.Equal(t, time.Minute, f.Archives[0].Step())
+		assert.Equal(t, time.Minute*5, f.Archives[0].Retention())
+		assert.Equal(t, uint32(4), f.Archives[1].Points)
+	}
+
+	points, err := f.Points(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []Point{
+		point(1500000000, 1),
+		point(1500000060, 2),
+		point(1500000120, 3),
+		point(1500000180, 4),
+		point(1500000240, 5),
+	}, points)
+
+	_, err = f.Points(2)
+	assert.Error(t, err)
+
+	history, err := f.History()
+	assert.NoError(t, err)
+	assert.Equal(t, []Point{
+		point(1499999400, 10),
+		point(1499999700, 11),
+		point(1500000000, 1),
+		point(1500000060, 2),
+		point(1500000120, 3),
+		point(1500000180, 4),
+		point(1500000240, 5),
+	}, history)
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"short":       {0, 0, 0, 1},
+		"no-archives": newWhisper(Average, 0.5),
+		"increasing": newWhisper(Average, 0.5,
+			testArchive{spp: 60, points: 1},
+			testArchive{spp: 60, points: 1},
+		),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(bytes.NewReader(data))
+			assert.ErrorIs(t, err, ErrInvalidFile)
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.wsp")
+	if !assert.NoError(t, os.WriteFile(path, testFile(), 0o600)) {
+		return
+	}
+
+	f, closer, err := Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, f.Archives, 2)
+	assert.NoError(t, closer.Close())
+
+	_, _, err = Open(filepath.Join(t.TempDir(), "missing.wsp"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
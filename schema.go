@@ -0,0 +1,250 @@
+package rrd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resolution describes an archive storing values consolidated over Step
+// for Duration.
+type Resolution struct {
+	Step     time.Duration
+	Duration time.Duration
+}
+
+func (r Resolution) String() string {
+	return formatSpan(r.Step) + " for " + formatSpan(r.Duration)
+}
+
+// Schema describes a RRD by the resolutions it stores, from which the
+// step, data source heartbeat and archives are computed.
+type Schema struct {
+	// Step is the base step, the smallest step of Resolutions if zero.
+	Step time.Duration
+
+	// Heartbeat is the heartbeat of data sources, twice the base step if
+	// zero, so a single missed update isn't recorded as unknown.
+	Heartbeat time.Duration
+
+	// Resolutions are the resolutions to archive.
+	Resolutions []Resolution
+
+	// CFs are the consolidation functions each resolution is archived
+	// with, Average if empty.
+	CFs []ConsolidationFunc
+
+	// XFF is the xfiles factor of the archives, 0.5 if zero.
+	XFF float32
+}
+
+// ParseSchema returns a new Schema for the comma separated resolutions in
+// spec, such as "1m for 2d, 5m for 2w, 1h for 2y", each a step and the
+// duration to store it for. The durations may use the rrdtool suffixes
+// s, m, h, d, w, M (31 days) and y (366 days) or be any time.Duration.
+func ParseSchema(spec string) (*Schema, error) {
+	s := &Schema{}
+	for _, v := range strings.Split(spec, ",") {
+		v = strings.TrimSpace(v)
+		step, dur, ok := strings.Cut(v, " for ")
+		if !ok {
+			step, dur, ok = strings.Cut(v, ":")
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: resolution %q", ErrInvalidArg, v)
+		}
+
+		var r Resolution
+		var err error
+		if r.Step, err = parseSpan(strings.TrimSpace(step)); err != nil {
+			return nil, fmt.Errorf("resolution %q step: %w", v, err)
+		}
+		if r.Duration, err = parseSpan(strings.TrimSpace(dur)); err != nil {
+			return nil, fmt.Errorf("resolution %q duration: %w", v, err)
+		}
+		s.Resolutions = append(s.Resolutions, r)
+	}
+	return s, nil
+}
+
+// parseSpan parses the positive duration v.
+func parseSpan(v string) (time.Duration, error) {
+	if v != "" {
+		if mult, ok := durationUnits[v[len(v)-1]]; ok {
+			if n, err := strconv.ParseInt(v[:len(v)-1], 10, 32); err == nil && n > 0 {
+				return time.Duration(n*mult) * time.Second, nil
+			}
+		}
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: invalid duration %q", ErrInvalidArg, v)
+	}
+	return d, nil
+}
+
+// formatSpan formats d with the largest rrdtool suffix which divides it.
+func formatSpan(d time.Duration) string {
+	if d%time.Second != 0 || d <= 0 {
+		return d.String()
+	}
+	sec := int64(d / time.Second)
+	for _, u := range []byte("yMwdhm") {
+		if mult := durationUnits[u]; sec%mult == 0 {
+			return strconv.FormatInt(sec/mult, 10) + string(u)
+		}
+	}
+	return strconv.FormatInt(sec, 10) + "s"
+}
+
+// BaseStep returns the base step of the RRD described by s.
+func (s *Schema) BaseStep() time.Duration {
+	if s.Step > 0 {
+		return s.Step
+	}
+	var step time.Duration
+	for _, r := range s.Resolutions {
+		if step == 0 || r.Step < step {
+			step = r.Step
+		}
+	}
+	return step
+}
+
+// DSHeartbeat returns the heartbeat to create data sources with.
+func (s *Schema) DSHeartbeat() time.Duration {
+	if s.Heartbeat > 0 {
+		return s.Heartbeat
+	}
+	return 2 * s.BaseStep()
+}
+
+// RRAs returns the archives storing the resolutions of s, with the number
+// of steps and rows computed from the base step, rounding the rows up so
+// at least the requested duration is stored.
+func (s *Schema) RRAs() ([]RRA, error) {
+	step := s.BaseStep()
+	switch {
+	case len(s.Resolutions) == 0:
+		return nil, ErrNoRRA
+	case step < time.Second || step%time.Second != 0:
+		return nil, fmt.Errorf("%w: step %v isn't whole seconds", ErrInvalidArg, step)
+	case s.XFF < 0 || s.XFF >= 1:
+		return nil, fmt.Errorf("%w: xff %v", ErrInvalidArg, s.XFF)
+	}
+
+	xff := s.XFF
+	if xff == 0 {
+		xff = 0.5
+	}
+	cfs := s.CFs
+	if len(cfs) == 0 {
+		cfs = []ConsolidationFunc{Average}
+	}
+
+	var rras []RRA
+	for _, cf := range cfs {
+		cf, err := cf.normalize()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range s.Resolutions {
+			if r.Step < step || r.Step%step != 0 {
+				return nil, fmt.Errorf("%w: resolution %v isn't a multiple of step %v", ErrInvalidArg, r, step)
+			}
+			if r.Duration < r.Step {
+				return nil, fmt.Errorf("%w: resolution %v is shorter than its step", ErrInvalidArg, r)
+			}
+			steps := int(r.Step / step)
+			rows := int((r.Duration + r.Step - 1) / r.Step)
+			rras = append(rras, newRRA(string(cf), xff, steps, rows))
+		}
+	}
+	return rras, nil
+}
+
+// Create returns the definition of a RRD with the data sources ds and
+// the step and archives described by s. Data sources should be created
+// with the heartbeat returned by DSHeartbeat.
+func (s *Schema) Create(ds ...DS) (*CreateRRD, error) {
+	rras, err := s.RRAs()
+	if err != nil {
+		return nil, err
+	}
+	d := NewCreateRRD(ds, rras, Step(s.BaseStep()))
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Warnings returns descriptions of likely mistakes in the RRD defined by d,
+// such as a data source heartbeat less than twice the step, see
+// RRDInfo.Warnings.
+func (d *CreateRRD) Warnings() ([]string, error) {
+	info, err := d.Info()
+	if err != nil {
+		return nil, err
+	}
+	return info.Warnings(), nil
+}
+
+// Warnings returns descriptions of likely mistakes in the configuration of
+// r, such as:
+//   - a data source heartbeat less than twice the step, so a single late
+//     update is recorded as unknown.
+//   - no archive storing the base step, so it can't be fetched.
+//   - an archive which stores no more time than one of the same
+//     consolidation function with a finer resolution.
+func (r *RRDInfo) Warnings() []string {
+	var warnings []string
+	ds := make([]DSInfo, 0, len(r.DS))
+	for _, d := range r.DS {
+		ds = append(ds, d)
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i].Index < ds[j].Index })
+	for _, d := range ds {
+		switch {
+		case d.Type == Compute:
+		case d.MinimalHeartbeat < r.Step:
+			warnings = append(warnings, fmt.Sprintf("data source %v heartbeat %v is less than the step %v, so all updates will be unknown",
+				d.Name, d.MinimalHeartbeat, r.Step))
+		case d.MinimalHeartbeat < 2*r.Step:
+			warnings = append(warnings, fmt.Sprintf("data source %v heartbeat %v is less than twice the step %v, so a single late update will be unknown",
+				d.Name, d.MinimalHeartbeat, r.Step))
+		}
+	}
+
+	base := len(r.RRA) == 0
+	for i, a := range r.RRA {
+		if a.PDPPerRow == 1 {
+			base = true
+		}
+		if !ConsolidationFunc(a.CF).Valid() {
+			continue
+		}
+		for j, b := range r.RRA[:i] {
+			if b.CF != a.CF {
+				continue
+			}
+			switch {
+			case a.PDPPerRow == b.PDPPerRow:
+				warnings = append(warnings, fmt.Sprintf("rra[%v] has the same resolution as rra[%v]", i, j))
+			case a.PDPPerRow > b.PDPPerRow && a.PDPPerRow*a.Rows <= b.PDPPerRow*b.Rows,
+				a.PDPPerRow < b.PDPPerRow && a.PDPPerRow*a.Rows >= b.PDPPerRow*b.Rows:
+				coarse, fine := i, j
+				if a.PDPPerRow < b.PDPPerRow {
+					coarse, fine = j, i
+				}
+				warnings = append(warnings, fmt.Sprintf("rra[%v] stores no more time than rra[%v] with a finer resolution", coarse, fine))
+			}
+		}
+	}
+	if !base {
+		warnings = append(warnings, fmt.Sprintf("no archive stores the step %v resolution", r.Step))
+	}
+	return warnings
+}
@@ -0,0 +1,159 @@
+package rrd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchema(t *testing.T) {
+	tests := []struct {
+		spec   string
+		expect []Resolution
+		err    bool
+	}{
+		{spec: "1m for 2d, 5m for 2w, 1h for 2y", expect: []Resolution{
+			{time.Minute, 48 * time.Hour},
+			{5 * time.Minute, 14 * 24 * time.Hour},
+			{time.Hour, 2 * 366 * 24 * time.Hour},
+		}},
+		{spec: "10s:1h,1M:1y", expect: []Resolution{
+			{10 * time.Second, time.Hour},
+			{31 * 24 * time.Hour, 366 * 24 * time.Hour},
+		}},
+		{spec: "90s for 1h30m", expect: []Resolution{{90 * time.Second, 90 * time.Minute}}},
+		{spec: "", err: true},
+		{spec: "1m", err: true},
+		{spec: "1x for 2d", err: true},
+		{spec: "1m for -2d", err: true},
+		{spec: "0m for 2d", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			s, err := ParseSchema(tc.spec)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrInvalidArg)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expect, s.Resolutions)
+			}
+		})
+	}
+}
+
+func TestResolutionString(t *testing.T) {
+	assert.Equal(t, "1m for 2d", Resolution{time.Minute, 48 * time.Hour}.String())
+	assert.Equal(t, "90s for 2y", Resolution{90 * time.Second, 2 * 366 * 24 * time.Hour}.String())
+	assert.Equal(t, "500ms for 1h", Resolution{500 * time.Millisecond, time.Hour}.String())
+}
+
+func TestSchema(t *testing.T) {
+	s, err := ParseSchema("1m for 2d, 5m for 2w, 1h for 2y")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Minute, s.BaseStep())
+	assert.Equal(t, 2*time.Minute, s.DSHeartbeat())
+
+	d, err := s.Create(NewGauge("watts", s.DSHeartbeat(), 0, 1000))
+	if !assert.NoError(t, err) {
+		return
+	}
+	cmd, err := d.Cmd("power.rrd")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "create power.rrd -s 60 DS:watts:GAUGE:120:0:1000 "+
+		"RRA:AVERAGE:0.5:1:2880 RRA:AVERAGE:0.5:5:4032 RRA:AVERAGE:0.5:60:17568\n", cmd.String())
+	warnings, err := d.Warnings()
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	s.CFs = []ConsolidationFunc{Average, "max"}
+	s.XFF = 0.2
+	s.Step = 30 * time.Second
+	s.Heartbeat = 5 * time.Minute
+	s.Resolutions = []Resolution{{time.Minute, 100 * time.Second}}
+	rras, err := s.RRAs()
+	assert.NoError(t, err)
+	assert.Equal(t, []RRA{"RRA:AVERAGE:0.2:2:2", "RRA:MAX:0.2:2:2"}, rras)
+	assert.Equal(t, 5*time.Minute, s.DSHeartbeat())
+}
+
+func TestSchemaInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema Schema
+		expect error
+	}{
+		{"no-resolutions", Schema{}, ErrNoRRA},
+		{"step", Schema{Resolutions: []Resolution{{time.Millisecond * 1500, time.Hour}}}, ErrInvalidArg},
+		{"multiple", Schema{Step: time.Minute, Resolutions: []Resolution{{90 * time.Second, time.Hour}}}, ErrInvalidArg},
+		{"short", Schema{Resolutions: []Resolution{{time.Hour, time.Minute}}}, ErrInvalidArg},
+		{"xff", Schema{XFF: 1, Resolutions: []Resolution{{time.Minute, time.Hour}}}, ErrInvalidArg},
+		{"cf", Schema{CFs: []ConsolidationFunc{"avg"}, Resolutions: []Resolution{{time.Minute, time.Hour}}}, ErrInvalidCF},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.schema.Create(NewGauge("watts", time.Minute, 0, 1000))
+			assert.ErrorIs(t, err, tc.expect)
+		})
+	}
+}
+
+func TestCreateRRDWarnings(t *testing.T) {
+	tests := []struct {
+		name   string
+		def    *CreateRRD
+		expect []string
+	}{
+		{
+			"none",
+			NewCreateRRD([]DS{NewGauge("a", 10*time.Minute, 0, 1)}, []RRA{NewAverage(0.5, 1, 10)}),
+			nil,
+		},
+		{
+			"heartbeat",
+			NewCreateRRD([]DS{
+				NewGauge("a", 5*time.Minute, 0, 1),
+				NewGauge("b", 10*time.Minute, 0, 1),
+				NewGauge("c", 4*time.Minute, 0, 1),
+				NewCompute("d", "a,b,+"),
+			}, []RRA{NewAverage(0.5, 1, 10)}),
+			[]string{
+				"data source a heartbeat 5m0s is less than twice the step 5m0s, so a single late update will be unknown",
+				"data source c heartbeat 4m0s is less than the step 5m0s, so all updates will be unknown",
+			},
+		},
+		{
+			"archives",
+			NewCreateRRD([]DS{NewGauge("a", 10*time.Minute, 0, 1)}, []RRA{
+				NewAverage(0.5, 2, 100),
+				NewAverage(0.5, 10, 20),
+				NewMax(0.5, 10, 20),
+				NewAverage(0.5, 2, 50),
+				NewAverage(0.5, 60, 100),
+			}),
+			[]string{
+				"rra[1] stores no more time than rra[0] with a finer resolution",
+				"rra[3] has the same resolution as rra[0]",
+				"no archive stores the step 5m0s resolution",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			warnings, err := tc.def.Warnings()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, warnings)
+		})
+	}
+
+	_, err := NewCreateRRD(nil, nil).Warnings()
+	assert.ErrorIs(t, err, ErrNoDS)
+}
@@ -0,0 +1,167 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// SeriesSchema sets the definition used to create the RRD of a Series if it
+// doesn't exist when first written to, which must include the series data
+// source. Its start is set to precede the first sample so it mustn't be set.
+// Without a schema writes to a RRD which doesn't exist fail.
+func SeriesSchema(def *CreateRRD) func(*Series) error {
+	return func(s *Series) error {
+		if def == nil {
+			return fmt.Errorf("%w: nil schema", ErrInvalidArg)
+		}
+		if err := def.Validate(); err != nil {
+			return err
+		}
+		for _, d := range def.DS {
+			if d.Name() == s.ds {
+				s.schema = def
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: schema has no data source %q", ErrInvalidArg, s.ds)
+	}
+}
+
+// Series writes the values of a single data source of a RRD, hiding the
+// layout of the RRD and the update format from callers. The RRD is
+// created on the first write if a schema was supplied with SeriesSchema.
+// Other data sources of the RRD are updated as unknown, so a Series is best
+// suited to RRDs with a single data source.
+// A Series is safe for concurrent use.
+type Series struct {
+	c        *Client
+	filename string
+	ds       string
+	schema   *CreateRRD
+
+	m sync.Mutex
+	// index is the index of ds and count the number of data sources of the
+	// RRD, count is zero until the layout is known.
+	index int
+	count int
+}
+
+// Series returns a new Series which writes the data source ds of filename.
+func (c *Client) Series(filename, ds string, opts ...func(*Series) error) (*Series, error) {
+	if filename == "" || ds == "" {
+		return nil, fmt.Errorf("%w: empty filename or data source", ErrInvalidArg)
+	}
+
+	s := &Series{c: c, filename: filename, ds: ds}
+	for _, o := range opts {
+		if o == nil {
+			return nil, ErrNilOption
+		}
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Filename returns the filename of the RRD s writes to.
+func (s *Series) Filename() string {
+	return s.filename
+}
+
+// DS returns the name of the data source s writes to.
+func (s *Series) DS() string {
+	return s.ds
+}
+
+// Add writes v at t.
+func (s *Series) Add(t time.Time, v float64) error {
+	return s.AddWithContext(context.Background(), t, v)
+}
+
+// AddNow writes v at the current time.
+func (s *Series) AddNow(v float64) error {
+	return s.AddWithContext(context.Background(), time.Now(), v)
+}
+
+// AddWithContext writes v at t.
+func (s *Series) AddWithContext(ctx context.Context, t time.Time, v float64) error {
+	index, count, err := s.layout(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	values[index] = v
+	if err := s.c.UpdateWithContext(ctx, s.filename, Sample{Time: t, Values: values}); err != nil {
+		if IsNotExist(err) {
+			// Deleted since the layout was resolved, resolve it again
+			// on the next write.
+			s.forget()
+		}
+		return err
+	}
+	return nil
+}
+
+// layout returns the index of the series data source and the number of
+// data sources of the RRD, creating it to start before t if it doesn't
+// exist and s has a schema.
+func (s *Series) layout(ctx context.Context, t time.Time) (int, int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.count > 0 {
+		return s.index, s.count, nil
+	}
+
+	err := s.resolve(ctx)
+	if err == nil || !IsNotExist(err) || s.schema == nil {
+		return s.index, s.count, err
+	}
+
+	def := *s.schema
+	def.Options = append(append([]CreateOption{}, def.Options...), Start(t.Add(-time.Second)), NoOverwrite())
+	switch err := s.c.CreateFromWithContext(ctx, s.filename, &def); {
+	case IsExist(err):
+		// Created concurrently, use its layout.
+		err = s.resolve(ctx)
+		return s.index, s.count, err
+	case err != nil:
+		return 0, 0, fmt.Errorf("create %v: %w", s.filename, err)
+	}
+
+	for i, d := range def.DS {
+		if d.Name() == s.ds {
+			s.index = i
+		}
+	}
+	s.count = len(def.DS)
+	return s.index, s.count, nil
+}
+
+// resolve sets the layout of s from the info of the RRD.
+func (s *Series) resolve(ctx context.Context) error {
+	info, err := s.c.InfoStructWithContext(ctx, s.filename)
+	if err != nil {
+		return err
+	}
+	d, ok := info.DS[s.ds]
+	if !ok || d.Index < 0 || d.Index >= len(info.DS) {
+		return fmt.Errorf("%w: %v: %v", ErrUnknownDS, s.filename, s.ds)
+	}
+	s.index, s.count = d.Index, len(info.DS)
+	return nil
+}
+
+// forget discards the layout of the RRD.
+func (s *Series) forget() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.count = 0
+}
@@ -0,0 +1,102 @@
+package rrd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeries(t *testing.T) {
+	schema := NewCreateRRD(
+		[]DS{NewGauge("amps", time.Minute*2, 0, 100), NewGauge("watts", time.Minute*2, 0, 100)},
+		[]RRA{NewAverage(0.5, 1, 1440)},
+	).WithStep(time.Minute)
+	notExist := []string{"-1 No such file: /test.rrd"}
+	multi := []string{
+		"4 Info for test.rrd follows",
+		"ds[amps].index 1 1",
+		"ds[amps].type 2 GAUGE",
+		"ds[watts].index 1 0",
+		"ds[watts].type 2 GAUGE",
+	}
+
+	tests := []struct {
+		name      string
+		ds        string
+		opts      []func(*Series) error
+		responses map[string][]string
+		update    string
+		creates   int
+		err       error
+	}{
+		{"single", "watts", nil, nil, "update test.rrd 1000:1.5", 0, nil},
+		{"multi", "amps", nil, map[string][]string{"info": multi}, "update test.rrd 1000:U:1.5", 0, nil},
+		{"create", "watts", []func(*Series) error{SeriesSchema(schema)}, map[string][]string{"info": notExist}, "update test.rrd 1000:U:1.5", 1, nil},
+		{"no-schema", "watts", nil, map[string][]string{"info": notExist}, "", 0, ErrNotFound},
+		{"unknown-ds", "volts", nil, nil, "", 0, ErrUnknownDS},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerStopped(t)
+			if s == nil {
+				return
+			}
+			s.responses = tc.responses
+			s.Start()
+			defer func() {
+				assert.NoError(t, s.Close())
+			}()
+
+			c, err := NewClient(s.Addr, Timeout(time.Second*2))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			series, err := c.Series("test.rrd", tc.ds, tc.opts...)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "test.rrd", series.Filename())
+			assert.Equal(t, tc.ds, series.DS())
+
+			err = series.Add(time.Unix(1000, 0), 1.5)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, 1, s.count(tc.update))
+			assert.Equal(t, tc.creates, s.count("create test.rrd"))
+
+			// The layout is cached.
+			assert.NoError(t, series.Add(time.Unix(1060, 0), 2))
+			assert.Equal(t, 1, s.count("info"))
+		})
+	}
+}
+
+func TestSeriesOptions(t *testing.T) {
+	c := &Client{}
+	schema := NewCreateRRD([]DS{NewGauge("watts", time.Minute, 0, 100)}, []RRA{NewAverage(0.5, 1, 10)})
+
+	_, err := c.Series("", "watts")
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = c.Series("test.rrd", "watts", nil)
+	assert.ErrorIs(t, err, ErrNilOption)
+	_, err = c.Series("test.rrd", "watts", SeriesSchema(nil))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = c.Series("test.rrd", "amps", SeriesSchema(schema))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = c.Series("test.rrd", "watts", SeriesSchema(NewCreateRRD(nil, nil)))
+	assert.True(t, errors.Is(err, ErrNoDS) || errors.Is(err, ErrNoRRA), "unexpected error %v", err)
+	_, err = c.Series("test.rrd", "watts", SeriesSchema(schema))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,53 @@
+package rrd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// abstractSockets is true if the OS supports abstract unix sockets.
+var abstractSockets = runtime.GOOS == "linux" || runtime.GOOS == "android"
+
+// isAbstract returns true if the unix socket addr is in the abstract
+// namespace, such as "@rrdcached", as opposed to a file.
+func isAbstract(addr string) bool {
+	return strings.HasPrefix(addr, "@")
+}
+
+// dialContext is the default DialFunc, which checks unix sockets are
+// accessible before dialing them.
+func dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network == "unix" {
+		if err := checkSocket(addr); err != nil {
+			return nil, err
+		}
+	}
+	return (&net.Dialer{}).DialContext(ctx, network, addr)
+}
+
+// checkSocket returns a descriptive error if the unix socket file path
+// exists but isn't a socket or isn't writable by the current user, which
+// connecting requires. Other errors are left to the dial to report.
+func checkSocket(path string) error {
+	if isAbstract(path) {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%w: %v isn't a unix socket", ErrInvalidArg, path)
+	}
+	if !writable(path) {
+		return fmt.Errorf("%w: unix socket %v (mode %v) isn't writable by uid %v, "+
+			"check the rrdcached -m socket permissions or run as a member of its group",
+			os.ErrPermission, path, fi.Mode().Perm(), os.Getuid())
+	}
+	return nil
+}
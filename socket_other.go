@@ -0,0 +1,9 @@
+//go:build !unix
+
+package rrd
+
+// writable returns true as file permissions don't restrict unix socket
+// access on this OS.
+func writable(path string) bool {
+	return true
+}
@@ -0,0 +1,95 @@
+package rrd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newUnixServer returns a running server listening on the unix socket addr
+// or nil if an error occurred.
+func newUnixServer(t *testing.T, addr string) *server {
+	s := newServerStopped(t)
+	if s == nil {
+		return nil
+	}
+	assert.NoError(t, s.Listener.Close())
+
+	l, err := net.Listen("unix", addr)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	s.Listener = l
+	s.Addr = addr
+	s.Start()
+	return s
+}
+
+func TestClientAbstractSocket(t *testing.T) {
+	if !abstractSockets {
+		_, err := NewClient("@rrdcached")
+		assert.ErrorIs(t, err, ErrInvalidArg)
+		return
+	}
+
+	s := newUnixServer(t, fmt.Sprintf("@go-rrd-test-%v", os.Getpid()))
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	assert.Equal(t, "unix", c.network)
+	assert.NoError(t, c.Ping())
+}
+
+func TestCheckSocket(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "rrdcached.sock")
+	s := newUnixServer(t, sock)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	file := filepath.Join(dir, "file")
+	if !assert.NoError(t, os.WriteFile(file, nil, 0600)) {
+		return
+	}
+
+	assert.NoError(t, checkSocket(sock))
+	assert.NoError(t, checkSocket(filepath.Join(dir, "missing.sock")))
+	assert.NoError(t, checkSocket("@rrdcached"))
+	assert.ErrorIs(t, checkSocket(file), ErrInvalidArg)
+
+	_, err := NewClient("unix:" + file)
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	if !assert.NoError(t, os.Chmod(sock, 0500)) {
+		return
+	}
+	if writable(sock) {
+		t.Skip("permissions not enforced for this user")
+	}
+	err = checkSocket(sock)
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.ErrorContains(t, err, sock)
+
+	_, err = NewClient("unix:" + sock)
+	assert.ErrorIs(t, err, os.ErrPermission)
+}
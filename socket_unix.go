@@ -0,0 +1,13 @@
+//go:build unix
+
+package rrd
+
+import "syscall"
+
+// accessWrite is the access mode W_OK.
+const accessWrite = 0x2
+
+// writable returns true if the current user can write to path.
+func writable(path string) bool {
+	return syscall.Access(path, accessWrite) == nil
+}
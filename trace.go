@@ -0,0 +1,90 @@
+package rrd
+
+import (
+	"context"
+)
+
+// CommandInfo describes a command performed by a client.
+type CommandInfo struct {
+	// Cmd is the lower case command verb e.g. "update".
+	Cmd string
+
+	// Filename is the rrd the command operates on, empty if it doesn't
+	// operate on a single file or the client redacts arguments.
+	Filename string
+}
+
+// CommandResult describes the outcome of a command performed by a client.
+type CommandResult struct {
+	// Code is the status code of the response, negative if the server
+	// reported an error and zero if no response was read.
+	Code int
+
+	// Retries is the number of times the command was resent on a new
+	// connection after a write failure.
+	Retries int
+
+	// Err is the error the command failed with, nil if it succeeded.
+	Err error
+}
+
+// Tracer starts a Span for each command performed by a client, allowing
+// commands to be included in distributed traces.
+type Tracer interface {
+	// Start starts a span for the command described by info, returning the
+	// context used to perform it.
+	Start(ctx context.Context, info CommandInfo) (context.Context, Span)
+}
+
+// Span represents a traced command.
+type Span interface {
+	// End completes the span with the result of the command.
+	End(res CommandResult)
+}
+
+// Trace sets the Tracer used to trace commands performed by the client.
+func Trace(t Tracer) func(*Client) error {
+	return func(c *Client) error {
+		if t == nil {
+			return ErrNilOption
+		}
+		c.tracer = t
+		return nil
+	}
+}
+
+// cmdStatsKey is the context key for the cmdStats of a command.
+type cmdStatsKey struct{}
+
+// cmdStats records details of the execution of a command for tracing.
+type cmdStats struct {
+	code    int
+	retries int
+}
+
+// statsFrom returns the cmdStats of ctx, or nil if there are none.
+func statsFrom(ctx context.Context) *cmdStats {
+	st, _ := ctx.Value(cmdStatsKey{}).(*cmdStats)
+	return st
+}
+
+// instrument starts tracing cmd if the client has a tracer, returning the
+// context to perform cmd with and a function which must be called with the
+// result once cmd completes, to report it to the clients tracer and observer.
+func (c *Client) instrument(ctx context.Context, cmd *Cmd) (context.Context, func(err error)) {
+	done := c.observe(ctx, cmd)
+	if c.tracer == nil {
+		return ctx, done
+	}
+
+	info := CommandInfo{Cmd: cmd.verb()}
+	if !c.redact {
+		info.Filename = cmd.filename()
+	}
+	st := &cmdStats{}
+	ctx, span := c.tracer.Start(context.WithValue(ctx, cmdStatsKey{}, st), info)
+	return ctx, func(err error) {
+		done(err)
+		span.End(CommandResult{Code: st.code, Retries: st.retries, Err: err})
+	}
+}
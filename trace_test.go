@@ -0,0 +1,99 @@
+package rrd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tracedCmd is a command recorded by recordingTracer.
+type tracedCmd struct {
+	info CommandInfo
+	res  CommandResult
+}
+
+// recordingTracer is a Tracer which records the commands it traces.
+type recordingTracer struct {
+	cmds []*tracedCmd
+}
+
+func (t *recordingTracer) Start(ctx context.Context, info CommandInfo) (context.Context, Span) {
+	tc := &tracedCmd{info: info}
+	t.cmds = append(t.cmds, tc)
+	return ctx, recordingSpan{tc: tc}
+}
+
+// recordingSpan is a Span which records its result.
+type recordingSpan struct {
+	tc *tracedCmd
+}
+
+func (s recordingSpan) End(res CommandResult) {
+	s.tc.res = res
+}
+
+func TestClientTracer(t *testing.T) {
+	_, err := NewClient("", Trace(nil))
+	assert.Equal(t, ErrNilOption, err)
+
+	s := newServer(t)
+	if s == nil {
+		return
+	}
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	tests := []struct {
+		name     string
+		redact   bool
+		filename string
+	}{
+		{"plain", false, "test.rrd"},
+		{"redact", true, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := &recordingTracer{}
+			opts := []func(*Client) error{
+				Timeout(time.Second * 2),
+				Trace(tr),
+				Retry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+			}
+			if tc.redact {
+				opts = append(opts, Redact)
+			}
+			c, err := NewClient(s.Addr, opts...)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() {
+				assert.NoError(t, c.Close())
+			}()
+
+			assert.NoError(t, c.Flush("test.rrd"))
+			_, err = c.Exec("invalid")
+			assert.Error(t, err)
+
+			// Write on the closed connection is retried on a new one.
+			assert.NoError(t, c.conn.Close())
+			assert.NoError(t, c.Ping())
+
+			if !assert.Len(t, tr.cmds, 3) {
+				return
+			}
+			assert.Equal(t, CommandInfo{Cmd: "flush", Filename: tc.filename}, tr.cmds[0].info)
+			assert.Equal(t, CommandResult{}, tr.cmds[0].res)
+
+			assert.Equal(t, CommandInfo{Cmd: "invalid"}, tr.cmds[1].info)
+			assert.Equal(t, -1, tr.cmds[1].res.Code)
+			assert.Error(t, tr.cmds[1].res.Err)
+
+			assert.Equal(t, CommandInfo{Cmd: "ping"}, tr.cmds[2].info)
+			assert.Equal(t, CommandResult{Retries: 1}, tr.cmds[2].res)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package rrd
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var dsNameRe = regexp.MustCompile(`^[a-zA-Z0-9_]{1,19}$`)
+
+// TuneOption represents a rrd tune option.
+type TuneOption struct {
+	flag string
+
+	// ds is the data source the option applies to, if any.
+	ds string
+
+	// value is the formatted value of the option and num its numeric value.
+	value string
+	num   float64
+}
+
+func (o TuneOption) String() string {
+	switch {
+	case o.ds == "":
+		return fmt.Sprintf("%v %v", o.flag, o.value)
+	case o.value == "":
+		return fmt.Sprintf("%v %v", o.flag, o.ds)
+	default:
+		return fmt.Sprintf("%v %v:%v", o.flag, o.ds, o.value)
+	}
+}
+
+// tuneFloat returns v formatted as a tune value, with NaN as unknown.
+func tuneFloat(v float64) string {
+	if math.IsNaN(v) {
+		return "U"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// TuneHeartbeat returns a new tune option which sets the minimal heartbeat of ds.
+func TuneHeartbeat(ds string, heartbeat time.Duration) TuneOption {
+	secs := int64(heartbeat / time.Second)
+	return TuneOption{flag: "-h", ds: ds, value: strconv.FormatInt(secs, 10), num: float64(secs)}
+}
+
+// TuneMin returns a new tune option which sets the minimum value of ds.
+// A NaN min removes the limit.
+func TuneMin(ds string, min float64) TuneOption {
+	return TuneOption{flag: "-i", ds: ds, value: tuneFloat(min), num: min}
+}
+
+// TuneMax returns a new tune option which sets the maximum value of ds.
+// A NaN max removes the limit.
+func TuneMax(ds string, max float64) TuneOption {
+	return TuneOption{flag: "-a", ds: ds, value: tuneFloat(max), num: max}
+}
+
+// TuneDSType returns a new tune option which changes the type of ds to dst.
+func TuneDSType(ds, dst string) TuneOption {
+	return TuneOption{flag: "-d", ds: ds, value: dst}
+}
+
+// TuneRename returns a new tune option which renames the data source ds to name.
+func TuneRename(ds, name string) TuneOption {
+	return TuneOption{flag: "-r", ds: ds, value: name}
+}
+
+// TuneAberrantReset returns a new tune option which resets the Holt-Winters
+// aberrant behaviour detection state of ds.
+func TuneAberrantReset(ds string) TuneOption {
+	return TuneOption{flag: "--aberrant-reset", ds: ds}
+}
+
+// newTuneParam returns a new tune option which sets the Holt-Winters parameter flag.
+func newTuneParam(flag string, v float64) TuneOption {
+	return TuneOption{flag: flag, value: tuneFloat(v), num: v}
+}
+
+// TuneAlpha returns a new tune option which sets the Holt-Winters intercept adaption parameter.
+func TuneAlpha(v float64) TuneOption {
+	return newTuneParam("--alpha", v)
+}
+
+// TuneBeta returns a new tune option which sets the Holt-Winters slope adaption parameter.
+func TuneBeta(v float64) TuneOption {
+	return newTuneParam("--beta", v)
+}
+
+// TuneGamma returns a new tune option which sets the Holt-Winters seasonal adaption parameter.
+func TuneGamma(v float64) TuneOption {
+	return newTuneParam("--gamma", v)
+}
+
+// TuneGammaDeviation returns a new tune option which sets the Holt-Winters
+// seasonal deviation adaption parameter.
+func TuneGammaDeviation(v float64) TuneOption {
+	return newTuneParam("--gamma-deviation", v)
+}
+
+// TuneSmoothingWindow returns a new tune option which sets the fraction of
+// the season used to smooth SEASONAL archives.
+func TuneSmoothingWindow(v float64) TuneOption {
+	return newTuneParam("--smoothing-window", v)
+}
+
+// TuneSmoothingWindowDeviation returns a new tune option which sets the
+// fraction of the season used to smooth DEVSEASONAL archives.
+func TuneSmoothingWindowDeviation(v float64) TuneOption {
+	return newTuneParam("--smoothing-window-deviation", v)
+}
+
+// TuneDeltaPos returns a new tune option which sets the positive confidence
+// band scaling of FAILURES archives.
+func TuneDeltaPos(v float64) TuneOption {
+	return newTuneParam("--deltapos", v)
+}
+
+// TuneDeltaNeg returns a new tune option which sets the negative confidence
+// band scaling of FAILURES archives.
+func TuneDeltaNeg(v float64) TuneOption {
+	return newTuneParam("--deltaneg", v)
+}
+
+// TuneWindowLength returns a new tune option which sets the window length of
+// FAILURES archives.
+func TuneWindowLength(n int) TuneOption {
+	return newTuneParam("--window-length", float64(n))
+}
+
+// TuneFailureThreshold returns a new tune option which sets the failure
+// threshold of FAILURES archives.
+func TuneFailureThreshold(n int) TuneOption {
+	return newTuneParam("--failure-threshold", float64(n))
+}
+
+// validateTune returns an error if opts are invalid or conflict.
+func validateTune(opts []TuneOption) error {
+	if len(opts) == 0 {
+		return ErrNoTuneOptions
+	}
+
+	invalid := func(o TuneOption, reason string) error {
+		return fmt.Errorf("%w %q: %v", ErrInvalidTuneOption, o.String(), reason)
+	}
+
+	seen := make(map[string]bool, len(opts))
+	mins := make(map[string]float64)
+	maxs := make(map[string]float64)
+	params := make(map[string]float64)
+	for _, o := range opts {
+		key := o.flag + " " + o.ds
+		if seen[key] {
+			return invalid(o, "duplicate option")
+		}
+		seen[key] = true
+
+		if o.ds != "" && !dsNameRe.MatchString(o.ds) {
+			return invalid(o, "invalid data source name")
+		}
+
+		switch o.flag {
+		case "-h":
+			if o.num < 1 {
+				return invalid(o, "heartbeat must be at least one second")
+			}
+		case "-i":
+			mins[o.ds] = o.num
+		case "-a":
+			maxs[o.ds] = o.num
+		case "-d":
+			switch o.value {
+			case Gauge, Counter, DCounter, Derive, DDerive, Absolute:
+			default:
+				return invalid(o, "unsupported data source type")
+			}
+		case "-r":
+			if !dsNameRe.MatchString(o.value) {
+				return invalid(o, "invalid data source name")
+			}
+		case "--alpha", "--beta", "--gamma", "--gamma-deviation":
+			if !(o.num > 0 && o.num < 1) {
+				return invalid(o, "must be between 0 and 1")
+			}
+		case "--smoothing-window", "--smoothing-window-deviation":
+			if !(o.num >= 0 && o.num < 1) {
+				return invalid(o, "must be at least 0 and less than 1")
+			}
+		case "--window-length", "--failure-threshold":
+			if o.num < 1 || o.num > 28 {
+				return invalid(o, "must be between 1 and 28")
+			}
+			params[o.flag] = o.num
+		}
+	}
+
+	for ds, min := range mins {
+		if max, ok := maxs[ds]; ok && min > max {
+			return fmt.Errorf("%w: min %v greater than max %v for ds %v", ErrInvalidTuneOption, min, max, ds)
+		}
+	}
+
+	threshold, okT := params["--failure-threshold"]
+	window, okW := params["--window-length"]
+	if okT && okW && threshold > window {
+		return fmt.Errorf("%w: failure threshold %v greater than window length %v", ErrInvalidTuneOption, threshold, window)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package rrd
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTuneOption(t *testing.T) {
+	tests := []struct {
+		name   string
+		opt    TuneOption
+		expect string
+	}{
+		{"heartbeat", TuneHeartbeat("watts", time.Minute*10), "-h watts:600"},
+		{"min", TuneMin("watts", 0), "-i watts:0"},
+		{"max", TuneMax("watts", 24000.5), "-a watts:24000.5"},
+		{"max-unknown", TuneMax("watts", math.NaN()), "-a watts:U"},
+		{"ds-type", TuneDSType("watts", Counter), "-d watts:COUNTER"},
+		{"rename", TuneRename("watts", "power"), "-r watts:power"},
+		{"aberrant-reset", TuneAberrantReset("watts"), "--aberrant-reset watts"},
+		{"alpha", TuneAlpha(0.1), "--alpha 0.1"},
+		{"beta", TuneBeta(0.0035), "--beta 0.0035"},
+		{"gamma", TuneGamma(0.5), "--gamma 0.5"},
+		{"gamma-deviation", TuneGammaDeviation(0.5), "--gamma-deviation 0.5"},
+		{"smoothing-window", TuneSmoothingWindow(0.05), "--smoothing-window 0.05"},
+		{"smoothing-window-deviation", TuneSmoothingWindowDeviation(0), "--smoothing-window-deviation 0"},
+		{"deltapos", TuneDeltaPos(2), "--deltapos 2"},
+		{"deltaneg", TuneDeltaNeg(2), "--deltaneg 2"},
+		{"window-length", TuneWindowLength(9), "--window-length 9"},
+		{"failure-threshold", TuneFailureThreshold(7), "--failure-threshold 7"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, tc.opt.String())
+		})
+	}
+}
+
+func TestValidateTune(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []TuneOption
+		err  error
+	}{
+		{"ok", []TuneOption{TuneMin("watts", 0), TuneMax("watts", 10), TuneRename("amps", "current")}, nil},
+		{"unbounded", []TuneOption{TuneMin("watts", 10), TuneMax("watts", math.NaN())}, nil},
+		{"none", nil, ErrNoTuneOptions},
+		{"duplicate", []TuneOption{TuneMin("watts", 0), TuneMin("watts", 1)}, ErrInvalidTuneOption},
+		{"min-max", []TuneOption{TuneMin("watts", 10), TuneMax("watts", 1)}, ErrInvalidTuneOption},
+		{"heartbeat", []TuneOption{TuneHeartbeat("watts", time.Millisecond)}, ErrInvalidTuneOption},
+		{"ds-name", []TuneOption{TuneMin("bad name", 0)}, ErrInvalidTuneOption},
+		{"rename", []TuneOption{TuneRename("watts", "a-very-long-invalid-name")}, ErrInvalidTuneOption},
+		{"ds-type", []TuneOption{TuneDSType("watts", Compute)}, ErrInvalidTuneOption},
+		{"alpha", []TuneOption{TuneAlpha(1)}, ErrInvalidTuneOption},
+		{"smoothing-window", []TuneOption{TuneSmoothingWindow(1)}, ErrInvalidTuneOption},
+		{"window-length", []TuneOption{TuneWindowLength(29)}, ErrInvalidTuneOption},
+		{"threshold", []TuneOption{TuneWindowLength(5), TuneFailureThreshold(7)}, ErrInvalidTuneOption},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTune(tc.opts)
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package rrd
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -32,3 +35,119 @@ func NewUpdate(ts time.Time, val interface{}, values ...interface{}) Update {
 	}
 	return NewUpdateRaw(fmt.Sprintf("%v:%v", ts.Unix(), strings.Join(parts, ":")))
 }
+
+// Sample represents the values of a RRD update at a point in time.
+// A zero Time represents now and NaN values represent unknown.
+type Sample struct {
+	Time   time.Time
+	Values []float64
+}
+
+// Update returns the Update representation of s.
+func (s Sample) Update() Update {
+	t := s.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	b := make([]byte, 0, 11+len(s.Values)*12)
+	b = strconv.AppendInt(b, t.Unix(), 10)
+	for _, v := range s.Values {
+		b = append(b, ':')
+		if math.IsNaN(v) {
+			b = append(b, 'U')
+		} else {
+			b = strconv.AppendFloat(b, v, 'g', -1, 64)
+		}
+	}
+	return Update(b)
+}
+
+// ParseSample parses the update u, as returned by the pending command, into
+// a Sample. Fractional timestamps are supported and unknown values are
+// returned as math.NaN().
+func ParseSample(u string) (Sample, error) {
+	parts := strings.Split(u, ":")
+	if len(parts) < 2 {
+		return Sample{}, NewInvalidResponseError("sample: invalid update", u)
+	}
+
+	var s Sample
+	if parts[0] != "N" {
+		ts, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return Sample{}, NewInvalidResponseError("sample: invalid time", u)
+		}
+		sec, frac := math.Modf(ts)
+		s.Time = time.Unix(int64(sec), int64(math.Round(frac*1e6))*int64(time.Microsecond))
+	}
+
+	s.Values = make([]float64, len(parts)-1)
+	for i, v := range parts[1:] {
+		if v == "U" {
+			s.Values[i] = math.NaN()
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return Sample{}, NewInvalidResponseError("sample: invalid value", u)
+		}
+		s.Values[i] = f
+	}
+
+	return s, nil
+}
+
+// NamedSample represents the values of a RRD update at a point in time by
+// data source name, as with the rrdtool update --template option.
+// A zero Time represents now and NaN values represent unknown.
+type NamedSample struct {
+	Time   time.Time
+	Values map[string]float64
+}
+
+// Sample returns ns as a Sample with its values ordered by the data sources
+// of r. Data sources without a value are unknown. It returns an error
+// matching ErrUnknownDS if r doesn't have a named data source.
+func (r *RRDInfo) Sample(ns NamedSample) (Sample, error) {
+	return newSampler(r).sample(ns)
+}
+
+// sampler orders the values of named samples by the data sources of a RRD.
+type sampler struct {
+	index map[string]int
+	names []string
+}
+
+// newSampler returns a new sampler for the data sources of r, excluding
+// COMPUTE data sources which aren't updated.
+func newSampler(r *RRDInfo) *sampler {
+	s := &sampler{index: make(map[string]int, len(r.DS))}
+	for _, d := range r.DS {
+		if d.Type != Compute {
+			s.names = append(s.names, d.Name)
+		}
+	}
+	sort.Slice(s.names, func(i, j int) bool {
+		return r.DS[s.names[i]].Index < r.DS[s.names[j]].Index
+	})
+	for i, n := range s.names {
+		s.index[n] = i
+	}
+	return s
+}
+
+// sample returns ns as a Sample.
+func (s *sampler) sample(ns NamedSample) (Sample, error) {
+	values := make([]float64, len(s.names))
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	for name, v := range ns.Values {
+		i, ok := s.index[name]
+		if !ok {
+			return Sample{}, fmt.Errorf("%w: %v", ErrUnknownDS, name)
+		}
+		values[i] = v
+	}
+	return Sample{Time: ns.Time, Values: values}, nil
+}
@@ -0,0 +1,212 @@
+package rrd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBatchWindow is the default time an UpdateBatcher buffers
+	// samples before sending them.
+	DefaultBatchWindow = time.Second
+
+	// DefaultBatchMaxSamples is the default number of buffered samples at
+	// which an UpdateBatcher sends them without waiting for the window.
+	DefaultBatchMaxSamples = 10000
+)
+
+// BatchWindow sets the time an UpdateBatcher buffers samples, from the
+// first it receives, before sending them.
+func BatchWindow(d time.Duration) func(*UpdateBatcher) error {
+	return func(b *UpdateBatcher) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: batch window %v", ErrInvalidArg, d)
+		}
+		b.window = d
+		return nil
+	}
+}
+
+// BatchMaxSamples sets the number of buffered samples at which an
+// UpdateBatcher sends them without waiting for the window to end.
+func BatchMaxSamples(n int) func(*UpdateBatcher) error {
+	return func(b *UpdateBatcher) error {
+		if n < 1 {
+			return fmt.Errorf("%w: batch max samples %v", ErrInvalidArg, n)
+		}
+		b.maxSamples = n
+		return nil
+	}
+}
+
+// batchWindow are the samples an UpdateBatcher received during a window.
+type batchWindow struct {
+	coalescer
+	timer *time.Timer
+
+	// done is closed once the samples have been sent, with errs the
+	// errors of the files which failed.
+	done chan struct{}
+	errs map[string]error
+}
+
+// UpdateBatcher coalesces the updates it receives for a window, such as a
+// second, per file, sending them as one multi-sample update if they're all
+// for the same file, otherwise as a batch. This greatly reduces the
+// commands sent by collectors which update many files frequently.
+// Unlike AsyncWriter, callers wait for the samples to be sent and receive
+// the error for their file.
+// An UpdateBatcher is safe for concurrent use.
+type UpdateBatcher struct {
+	c          *Client
+	window     time.Duration
+	maxSamples int
+
+	m      sync.Mutex
+	cur    *batchWindow
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewUpdateBatcher returns a new UpdateBatcher which sends updates using c.
+// The batcher should be closed with Close to send the buffered samples.
+func NewUpdateBatcher(c *Client, options ...func(*UpdateBatcher) error) (*UpdateBatcher, error) {
+	b := &UpdateBatcher{
+		c:          c,
+		window:     DefaultBatchWindow,
+		maxSamples: DefaultBatchMaxSamples,
+	}
+	for _, f := range options {
+		if f == nil {
+			return nil, ErrNilOption
+		}
+		if err := f(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Update adds samples to filename, waiting until the window they are
+// buffered for has been sent. Samples with a zero time are given the
+// current time. If ctx is done first its error is returned, but the
+// samples are still sent.
+func (b *UpdateBatcher) Update(ctx context.Context, filename string, samples ...Sample) error {
+	if len(samples) == 0 {
+		return ErrNoSamples
+	}
+
+	values := make([]Update, len(samples))
+	now := time.Now()
+	for i, s := range samples {
+		if s.Time.IsZero() {
+			s.Time = now
+		}
+		values[i] = s.Update()
+	}
+
+	b.m.Lock()
+	if b.closed {
+		b.m.Unlock()
+		return ErrWriterClosed
+	}
+	w := b.cur
+	if w == nil {
+		w = &batchWindow{done: make(chan struct{})}
+		w.timer = time.AfterFunc(b.window, func() { b.send(w) })
+		b.cur = w
+		b.wg.Add(1)
+	}
+	w.add(filename, values...)
+	full := w.n >= b.maxSamples
+	b.m.Unlock()
+
+	if full && w.timer.Stop() {
+		go b.send(w)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.done:
+		return w.errs[filename]
+	}
+}
+
+// Flush sends the buffered samples without waiting for the window to end,
+// or waits for them if they're being sent, returning the errors of the
+// files which failed.
+func (b *UpdateBatcher) Flush() error {
+	b.m.Lock()
+	w := b.cur
+	b.m.Unlock()
+	if w == nil {
+		return nil
+	}
+
+	if w.timer.Stop() {
+		b.send(w)
+	}
+	<-w.done
+	errs := make([]error, 0, len(w.errs))
+	for _, f := range w.files {
+		if err := w.errs[f]; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close sends the buffered samples and stops the batcher, returning the
+// errors of the files which failed. Close doesn't close the client.
+func (b *UpdateBatcher) Close() error {
+	b.m.Lock()
+	b.closed = true
+	b.m.Unlock()
+
+	err := b.Flush()
+	b.wg.Wait()
+	return err
+}
+
+// send sends the samples of w, recording the error of each file.
+func (b *UpdateBatcher) send(w *batchWindow) {
+	defer b.wg.Done()
+	defer close(w.done)
+
+	b.m.Lock()
+	if b.cur == w {
+		b.cur = nil
+	}
+	b.m.Unlock()
+
+	ctx := context.Background()
+	w.errs = make(map[string]error)
+	if len(w.files) == 1 {
+		f := w.files[0]
+		values := w.values[f]
+		if err := b.c.UpdateRawWithContext(ctx, f, values[0], values[1:]...); err != nil {
+			w.errs[f] = err
+		}
+		return
+	}
+
+	cmds, files := w.cmds(b.c.maxCommandSize)
+	err := b.c.BatchWithContext(ctx, cmds...)
+	var berr *BatchError
+	switch {
+	case err == nil:
+	case errors.As(err, &berr):
+		for _, e := range berr.Errors {
+			f := files[e.Index]
+			w.errs[f] = errors.Join(w.errs[f], &CommandError{Cmd: e.Cmd, Err: e.Err})
+		}
+	default:
+		for _, f := range w.files {
+			w.errs[f] = err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package rrd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateBatcher(t *testing.T) {
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{
+		".": {
+			"1 errors",
+			"3 No such file: c.rrd",
+		},
+		"update a.rrd 1499968800:1 1499968801:1 1499968802:1": {"0 errors, enqueued 3 value(s)."},
+		"update d.rrd 1499968800:1 1499968860:2":              {"0 errors, enqueued 2 value(s)."},
+	}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	_, err = NewUpdateBatcher(c, nil)
+	assert.Equal(t, ErrNilOption, err)
+	_, err = NewUpdateBatcher(c, BatchWindow(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	_, err = NewUpdateBatcher(c, BatchMaxSamples(0))
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	b, err := NewUpdateBatcher(c, BatchWindow(time.Millisecond*50))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx := context.Background()
+	assert.Equal(t, ErrNoSamples, b.Update(ctx, "a.rrd"))
+
+	// Samples for a single file are sent as one update.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, b.Update(ctx, "a.rrd", Sample{Time: time.Unix(1499968800+int64(i), 0), Values: []float64{1}}))
+		}(i)
+		time.Sleep(time.Millisecond * 5)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, s.count("update a.rrd 1499968800:1 1499968801:1 1499968802:1"))
+
+	// Samples for multiple files are sent as a batch, each caller
+	// receiving the error of its file.
+	errs := make(map[string]error)
+	var m sync.Mutex
+	for _, f := range []string{"a.rrd", "b.rrd", "c.rrd", "a.rrd"} {
+		wg.Add(1)
+		go func(f string) {
+			defer wg.Done()
+			err := b.Update(ctx, f, Sample{Time: time.Unix(1499968900, 0), Values: []float64{2}})
+			m.Lock()
+			errs[f] = err
+			m.Unlock()
+		}(f)
+		time.Sleep(time.Millisecond * 5)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, s.count("batch"))
+	assert.Equal(t, 1, s.count("update a.rrd 1499968900:2 1499968900:2"))
+	assert.Equal(t, 1, s.count("update b.rrd 1499968900:2"))
+	assert.NoError(t, errs["a.rrd"])
+	assert.NoError(t, errs["b.rrd"])
+	assert.ErrorContains(t, errs["c.rrd"], "No such file")
+
+	// Reaching the max samples sends without waiting for the window.
+	b, err = NewUpdateBatcher(c, BatchWindow(time.Hour), BatchMaxSamples(2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, b.Update(ctx, "d.rrd",
+		Sample{Time: time.Unix(1499968800, 0), Values: []float64{1}},
+		Sample{Time: time.Unix(1499968860, 0), Values: []float64{2}},
+	))
+	assert.Equal(t, 1, s.count("update d.rrd 1499968800:1 1499968860:2"))
+
+	// Close sends the buffered samples.
+	ctx2, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, b.Update(ctx2, "e.rrd", Sample{Time: time.Unix(1499968800, 0), Values: []float64{3}}), context.Canceled)
+	assert.NoError(t, b.Close())
+	assert.Equal(t, 1, s.count("update e.rrd 1499968800:3"))
+	assert.Equal(t, ErrWriterClosed, b.Update(ctx, "e.rrd", Sample{Values: []float64{4}}))
+}
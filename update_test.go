@@ -1,6 +1,7 @@
 package rrd
 
 import (
+	"math"
 	"strconv"
 	"strings"
 	"testing"
@@ -47,3 +48,74 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestSample(t *testing.T) {
+	s := Sample{Time: time.Unix(1499995020, 0), Values: []float64{10, 0.3, math.NaN()}}
+	assert.Equal(t, Update("1499995020:10:0.3:U"), s.Update())
+
+	now := time.Now()
+	parts := strings.Split(string(Sample{Values: []float64{1}}.Update()), ":")
+	if assert.Len(t, parts, 2) {
+		ts, err := strconv.ParseInt(parts[0], 10, 64)
+		if assert.NoError(t, err) {
+			assert.True(t, ts >= now.Unix())
+		}
+		assert.Equal(t, "1", parts[1])
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	tests := []struct {
+		name     string
+		update   string
+		expected Sample
+		err      bool
+	}{
+		{"int", "1499995020:10:0.3", Sample{Time: time.Unix(1499995020, 0), Values: []float64{10, 0.3}}, false},
+		{"fraction", "1499995020.5:1", Sample{Time: time.Unix(1499995020, int64(time.Millisecond*500)), Values: []float64{1}}, false},
+		{"now", "N:1", Sample{Values: []float64{1}}, false},
+		{"no-values", "1499995020", Sample{}, true},
+		{"invalid-time", "x:1", Sample{}, true},
+		{"invalid-value", "1499995020:x", Sample{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ParseSample(tc.update)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, s)
+			}
+		})
+	}
+
+	s, err := ParseSample("1499995020:U")
+	if assert.NoError(t, err) && assert.Len(t, s.Values, 1) {
+		assert.True(t, math.IsNaN(s.Values[0]))
+	}
+}
+
+func TestRRDInfoSample(t *testing.T) {
+	info := &RRDInfo{DS: map[string]DSInfo{
+		"watts": {Name: "watts", Index: 2, Type: Gauge},
+		"amps":  {Name: "amps", Index: 0, Type: Gauge},
+		"total": {Name: "total", Index: 1, Type: Compute},
+		"volts": {Name: "volts", Index: 3, Type: Gauge},
+	}}
+	ts := time.Unix(1499995020, 0)
+
+	s, err := info.Sample(NamedSample{Time: ts, Values: map[string]float64{"watts": 10, "amps": 0.3}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, ts, s.Time)
+	assertNaNEqual(t, []float64{0.3, 10, math.NaN()}, s.Values)
+
+	_, err = info.Sample(NamedSample{Values: map[string]float64{"missing": 1}})
+	assert.ErrorIs(t, err, ErrUnknownDS)
+	_, err = info.Sample(NamedSample{Values: map[string]float64{"total": 1}})
+	assert.ErrorIs(t, err, ErrUnknownDS)
+}
@@ -0,0 +1,237 @@
+package rrd
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultRRDTool is the default rrdtool binary used by Xport and Dump.
+	DefaultRRDTool = "rrdtool"
+)
+
+// RRDTool sets the path of the rrdtool binary used for commands which rrdcached
+// doesn't support natively such as Xport, unless an RRDToolExecutor is set.
+func RRDTool(path string) func(*Client) error {
+	return func(c *Client) error {
+		c.rrdtool = path
+		return nil
+	}
+}
+
+// XportDef represents the definition of a rrdtool xport.
+type XportDef struct {
+	Start time.Time
+	End   time.Time
+
+	// Step is the requested resolution, if zero the highest available is used.
+	Step time.Duration
+
+	// Defs contains the DEF, CDEF, VDEF and XPORT definitions in rrdtool
+	// format e.g. "DEF:watts=test.rrd:watts:AVERAGE" or "XPORT:watts:Power".
+	Defs []string
+}
+
+// args returns the rrdtool xport arguments for d.
+func (d *XportDef) args() []string {
+	var args []string
+	if !d.Start.IsZero() {
+		args = append(args, "--start", strconv.FormatInt(d.Start.Unix(), 10))
+	}
+	if !d.End.IsZero() {
+		args = append(args, "--end", strconv.FormatInt(d.End.Unix(), 10))
+	}
+	if d.Step > 0 {
+		args = append(args, "--step", strconv.FormatInt(int64(d.Step/time.Second), 10))
+	}
+	return append(args, d.Defs...)
+}
+
+// files returns the RRD files referenced by the DEFs of d.
+func (d *XportDef) files() []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, def := range d.Defs {
+		if !strings.HasPrefix(def, "DEF:") {
+			continue
+		}
+		// DEF:<vname>=<rrdfile>:<ds-name>:<CF>[:step=<step>]...
+		i := strings.Index(def, "=")
+		if i == -1 {
+			continue
+		}
+		f := unescapedField(def[i+1:])
+		if f != "" && !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// unescapedField returns s up to the first colon which isn't escaped with a
+// backslash, with escaped colons unescaped.
+func unescapedField(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == ':':
+			b.WriteByte(':')
+			i++
+		case s[i] == ':':
+			return b.String()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// XportColumn represents a single exported series.
+type XportColumn struct {
+	Legend string
+	Values []float64
+}
+
+// XportResult represents the result of a rrdtool xport.
+// Unknown values are represented as math.NaN().
+type XportResult struct {
+	Start   time.Time
+	End     time.Time
+	Step    time.Duration
+	Times   []time.Time
+	Columns []XportColumn
+}
+
+// xportXML represents the XML output of rrdtool xport.
+type xportXML struct {
+	Meta struct {
+		Start   int64    `xml:"start"`
+		End     int64    `xml:"end"`
+		Step    int64    `xml:"step"`
+		Rows    int      `xml:"rows"`
+		Columns int      `xml:"columns"`
+		Legend  []string `xml:"legend>entry"`
+	} `xml:"meta"`
+	Rows []struct {
+		T int64    `xml:"t"`
+		V []string `xml:"v"`
+	} `xml:"data>row"`
+}
+
+// Xport exports the data described by def, which may span multiple RRDs and
+// include computed expressions, as aligned columns.
+// rrdcached has no native support for this so it's performed by running
+// rrdtool xport with --daemon pointing at the clients server, after the
+// referenced files have been flushed. rrdtool connects to the server
+// directly, so ErrNotSupported is returned if the client uses TLS, a Proxy
+// or a Dialer.
+func (c *Client) Xport(ctx context.Context, def *XportDef) (*XportResult, error) {
+	daemon, err := c.DaemonAddr()
+	if err != nil {
+		return nil, fmt.Errorf("xport: %w", err)
+	}
+
+	if files := def.files(); len(files) > 0 && !c.readOnly {
+		// Per file errors are reported by rrdtool itself.
+		if _, err := c.FlushMany(ctx, files); err != nil {
+			return nil, fmt.Errorf("xport: failed to flush: %w", err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if err := c.ExecRRDTool(ctx, &RRDToolCmd{
+		Args:   append([]string{"xport", "--daemon", daemon}, def.args()...),
+		Stdout: &stdout,
+	}); err != nil {
+		return nil, fmt.Errorf("xport: %w", err)
+	}
+
+	return parseXport(&stdout)
+}
+
+// DaemonAddr returns the address of the server in the format of the rrdtool
+// --daemon option. As rrdtool can only connect to it directly,
+// ErrNotSupported is returned if the client connects using TLS, a Proxy or
+// a Dialer, such as an SSH transport, which rrdtool would bypass.
+func (c *Client) DaemonAddr() (string, error) {
+	if c.tlsConfig != nil || c.proxy != nil || c.customDial {
+		return "", fmt.Errorf("%w: rrdtool --daemon can't use the clients transport", ErrNotSupported)
+	}
+	if c.network == "unix" {
+		return "unix:" + c.addr, nil
+	}
+	return c.addr, nil
+}
+
+// parseXport parses the XML output of rrdtool xport read from r.
+func parseXport(r io.Reader) (*XportResult, error) {
+	var x xportXML
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+	if err := dec.Decode(&x); err != nil {
+		return nil, fmt.Errorf("xport: invalid xml: %w", err)
+	}
+
+	res := &XportResult{
+		Start:   time.Unix(x.Meta.Start, 0),
+		End:     time.Unix(x.Meta.End, 0),
+		Step:    time.Duration(x.Meta.Step) * time.Second,
+		Times:   make([]time.Time, len(x.Rows)),
+		Columns: make([]XportColumn, len(x.Meta.Legend)),
+	}
+	for i, l := range x.Meta.Legend {
+		res.Columns[i] = XportColumn{Legend: l, Values: make([]float64, len(x.Rows))}
+	}
+
+	for i, row := range x.Rows {
+		if len(row.V) != len(res.Columns) {
+			return nil, NewInvalidResponseError("xport: invalid column count", row.V...)
+		}
+		res.Times[i] = time.Unix(row.T, 0)
+		for j, v := range row.V {
+			f, err := parseXportValue(v)
+			if err != nil {
+				return nil, NewInvalidResponseError("xport: invalid value", v)
+			}
+			res.Columns[j].Values[i] = f
+		}
+	}
+
+	return res, nil
+}
+
+// parseXportValue parses the xport value v, treating all NaN forms as math.NaN().
+func parseXportValue(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	switch strings.ToLower(v) {
+	case "nan", "-nan", "u":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// charsetReader supports the ISO-8859-1 encoding used by rrdtool's XML output.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1":
+		b, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 0, len(b))
+		for _, r := range b {
+			buf = utf8.AppendRune(buf, rune(r))
+		}
+		return bytes.NewReader(buf), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
@@ -0,0 +1,177 @@
+package rrd
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testXportXML = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<xport>
+  <meta>
+    <start>1020611700</start>
+    <step>300</step>
+    <end>1020612300</end>
+    <rows>2</rows>
+    <columns>2</columns>
+    <legend>
+      <entry>out bytes</entry>
+      <entry>in and out bits</entry>
+    </legend>
+  </meta>
+  <data>
+    <row><t>1020611700</t><v>3.4000000000e+00</v><v>5.4400000000e+01</v></row>
+    <row><t>1020612000</t><v>NaN</v><v>-nan</v></row>
+  </data>
+</xport>
+`
+
+func TestParseXport(t *testing.T) {
+	r, err := parseXport(strings.NewReader(testXportXML))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertTestXport(t, r)
+
+	_, err = parseXport(strings.NewReader("<xport><meta><legend><entry>a</entry></legend></meta><data><row><t>1</t></row></data></xport>"))
+	assert.Error(t, err)
+
+	_, err = parseXport(strings.NewReader("<xport><meta><legend><entry>a</entry></legend></meta><data><row><t>1</t><v>x</v></row></data></xport>"))
+	assert.Error(t, err)
+
+	_, err = parseXport(strings.NewReader("not xml"))
+	assert.Error(t, err)
+}
+
+// assertTestXport asserts that r matches testXportXML.
+func assertTestXport(t *testing.T, r *XportResult) {
+	assert.Equal(t, time.Unix(1020611700, 0), r.Start)
+	assert.Equal(t, time.Unix(1020612300, 0), r.End)
+	assert.Equal(t, time.Minute*5, r.Step)
+	assert.Equal(t, []time.Time{time.Unix(1020611700, 0), time.Unix(1020612000, 0)}, r.Times)
+	if !assert.Len(t, r.Columns, 2) {
+		return
+	}
+	assert.Equal(t, "out bytes", r.Columns[0].Legend)
+	assert.Equal(t, "in and out bits", r.Columns[1].Legend)
+	assert.Equal(t, 3.4, r.Columns[0].Values[0])
+	assert.Equal(t, 54.4, r.Columns[1].Values[0])
+	assert.True(t, math.IsNaN(r.Columns[0].Values[1]))
+	assert.True(t, math.IsNaN(r.Columns[1].Values[1]))
+}
+
+func TestXportDef(t *testing.T) {
+	def := &XportDef{
+		Start: time.Unix(1020611700, 0),
+		End:   time.Unix(1020612300, 0),
+		Step:  time.Minute * 5,
+		Defs: []string{
+			"DEF:out=if.rrd:out:AVERAGE",
+			`DEF:in=c\:/if.rrd:in:AVERAGE`,
+			"DEF:out2=if.rrd:out:MAX",
+			"CDEF:bits=out,in,+,8,*",
+			"XPORT:out:out bytes",
+			"XPORT:bits:in and out bits",
+		},
+	}
+
+	assert.Equal(t, []string{"if.rrd", "c:/if.rrd"}, def.files())
+	assert.Equal(t, append([]string{"--start", "1020611700", "--end", "1020612300", "--step", "300"}, def.Defs...), def.args())
+}
+
+func TestXport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	s := newServerStopped(t)
+	if s == nil {
+		return
+	}
+	s.responses = map[string][]string{".": {"0 errors"}}
+	s.Start()
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	xmlFile := filepath.Join(dir, "xport.xml")
+	rrdtool := filepath.Join(dir, "rrdtool")
+	if !assert.NoError(t, os.WriteFile(xmlFile, []byte(testXportXML), 0600)) {
+		return
+	}
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\ncat " + xmlFile + "\n"
+	if !assert.NoError(t, os.WriteFile(rrdtool, []byte(script), 0700)) {
+		return
+	}
+
+	c, err := NewClient(s.Addr, Timeout(time.Second*2), RRDTool(rrdtool))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+
+	r, err := c.Xport(context.Background(), &XportDef{
+		Defs: []string{"DEF:out=if.rrd:out:AVERAGE", "XPORT:out:out bytes"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertTestXport(t, r)
+
+	args, err := os.ReadFile(argsFile)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "xport --daemon "+s.Addr+" DEF:out=if.rrd:out:AVERAGE XPORT:out:out bytes\n", string(args))
+	}
+
+	c.rrdtool = filepath.Join(dir, "missing")
+	_, err = c.Xport(context.Background(), &XportDef{Defs: []string{"XPORT:out:out bytes"}})
+	assert.Error(t, err)
+}
+
+func TestDaemonAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*Client) error
+		expect  string
+	}{
+		{"tcp", nil, "localhost:42217"},
+		{"unix", []func(*Client) error{Unix}, "unix:localhost:42217"},
+		{"tls", []func(*Client) error{TLS(&tls.Config{})}, ""},
+		{"proxy", []func(*Client) error{Proxy("socks5://proxy")}, ""},
+		{"dialer", []func(*Client) error{Dialer(dialContext)}, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Client{network: "tcp", addr: "localhost:42217"}
+			for _, o := range tc.options {
+				assert.NoError(t, o(c))
+			}
+			addr, err := c.DaemonAddr()
+			if tc.expect == "" {
+				assert.ErrorIs(t, err, ErrNotSupported)
+				_, err = c.Xport(context.Background(), &XportDef{})
+				assert.ErrorIs(t, err, ErrNotSupported)
+				assert.ErrorIs(t, c.Dump(context.Background(), "test.rrd", io.Discard), ErrNotSupported)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expect, addr)
+			}
+		})
+	}
+}